@@ -11,6 +11,7 @@ export class Spxlc {
     reject: (error: any) => void
   }>()
   private notificationHandlers = new Map<string, (params: any) => void>()
+  private requestHandlers = new Map<string, (params: any) => any>()
 
   /**
    * Creates a new client instance.
@@ -27,7 +28,8 @@ export class Spxlc {
    * @param message Message from the server.
    * @throws Error if the message type is unknown.
    */
-  private handleMessage(message: ResponseMessage | NotificationMessage): void {
+  private handleMessage(message: ResponseMessage | NotificationMessage | RequestMessage): void {
+    if ('id' in message && 'method' in message) return this.handleRequestMessage(message)
     if ('id' in message) return this.handleResponseMessage(message)
     if ('method' in message) return this.handleNotificationMessage(message)
     throw new Error('unknown message type')
@@ -52,6 +54,27 @@ export class Spxlc {
     else pending.resolve(message.result)
   }
 
+  /**
+   * Handles request messages from the language server and replies with the
+   * result of the registered handler.
+   * @param message Request message from the server.
+   */
+  private async handleRequestMessage(message: RequestMessage): Promise<void> {
+    const response: ResponseMessage = { jsonrpc: '2.0', id: message.id }
+    const handler = this.requestHandlers.get(message.method)
+    if (handler == null) {
+      response.error = { code: -32601, message: `method not found: ${message.method}` }
+    } else {
+      try {
+        response.result = (await handler(message.params)) ?? null
+      } catch (e) {
+        response.error = { code: -32603, message: e instanceof Error ? e.message : String(e) }
+      }
+    }
+    const err = this.ls.handleMessage(response)
+    if (err != null) console.warn(`[LSP] failed to reply to ${message.method}:`, err)
+  }
+
   /**
    * Handles notification messages from the language server.
    * @param message Notification message from the server.
@@ -67,6 +90,17 @@ export class Spxlc {
     handler(message.params)
   }
 
+  /**
+   * Performs the LSP initialization handshake, which must complete before any other messages are sent.
+   * @param params Initialize parameters.
+   * @returns Promise that resolves with the initialize result.
+   */
+  async initialize<T>(params: any): Promise<T> {
+    const result = await this.request<T>('initialize', params)
+    this.notify('initialized', {})
+    return result
+  }
+
   /**
    * Sends a request to the language server and waits for response.
    * @param method LSP method name.
@@ -128,12 +162,22 @@ export class Spxlc {
     this.notificationHandlers.set(method, handler)
   }
 
+  /**
+   * Registers a handler for server requests, e.g., `workspace/applyEdit`.
+   * @param method LSP method name.
+   * @param handler Function to handle the request, returning its result.
+   */
+  onRequest(method: string, handler: (params: any) => any): void {
+    this.requestHandlers.set(method, handler)
+  }
+
   /**
    * Cleans up client resources.
    */
   dispose(): void {
     this.pendingRequests.clear()
     this.notificationHandlers.clear()
+    this.requestHandlers.clear()
   }
 }
 
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/goplus/goxlsw/xgoanalysis"
+)
+
+// Exit codes of the check subcommand.
+const (
+	checkExitOK       = 0 // No errors are found.
+	checkExitErrors   = 1 // Errors are found in the project.
+	checkExitFailures = 2 // The project cannot be checked, e.g., for bad arguments.
+)
+
+// checkReport is the JSON output of the check subcommand.
+type checkReport struct {
+	Diagnostics []xgoanalysis.Diagnostic `json:"diagnostics"`
+	Errors      int                      `json:"errors"`
+	Warnings    int                      `json:"warnings"`
+}
+
+// runCheck runs the check subcommand with the given arguments, e.g.,
+// ["-format=json", "./game"], which prints the diagnostics of the project in
+// the given directory and returns the exit code.
+func runCheck(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("goxlsw check", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "text", "output format, text, json or sarif")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: goxlsw check [-format=text|json|sarif] <projectdir>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return checkExitFailures
+	}
+	if flags.NArg() != 1 || (*format != "text" && *format != "json" && *format != "sarif") {
+		flags.Usage()
+		return checkExitFailures
+	}
+	dir := flags.Arg(0)
+
+	project, err := xgoanalysis.LoadProject(os.DirFS(dir))
+	if err != nil {
+		fmt.Fprintf(stderr, "goxlsw check: failed to analyze project %q: %v\n", dir, err)
+		return checkExitFailures
+	}
+	report := checkReport{Diagnostics: []xgoanalysis.Diagnostic{}}
+	for _, diag := range project.Diagnostics {
+		switch diag.Severity {
+		case xgoanalysis.SeverityError:
+			report.Errors++
+		case xgoanalysis.SeverityWarning:
+			report.Warnings++
+		}
+		report.Diagnostics = append(report.Diagnostics, diag)
+	}
+
+	switch *format {
+	case "json":
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(stderr, "goxlsw check: failed to write report: %v\n", err)
+			return checkExitFailures
+		}
+	case "sarif":
+		// Prefix the files with the project directory so that they are
+		// relative to the working directory, which is usually the root of the
+		// repository scanned by code scanning.
+		for i := range report.Diagnostics {
+			report.Diagnostics[i].File = path.Join(filepath.ToSlash(dir), report.Diagnostics[i].File)
+		}
+		if err := xgoanalysis.WriteSARIF(stdout, report.Diagnostics); err != nil {
+			fmt.Fprintf(stderr, "goxlsw check: failed to write report: %v\n", err)
+			return checkExitFailures
+		}
+	default:
+		for _, diag := range report.Diagnostics {
+			// Prefix the files with the project directory so that they are
+			// relative to the working directory, like those of go vet.
+			diag.File = filepath.Join(dir, filepath.FromSlash(diag.File))
+			fmt.Fprintln(stdout, diag)
+		}
+		fmt.Fprintf(stderr, "%s, %s\n", pluralize(report.Errors, "error", "errors"), pluralize(report.Warnings, "warning", "warnings"))
+	}
+	if report.Errors > 0 {
+		return checkExitErrors
+	}
+	return checkExitOK
+}
+
+// pluralize returns the count followed by the singular or plural form of a
+// noun, e.g., "1 error" or "2 errors".
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCheckProject writes an spx project with the given main.spx to a
+// temporary directory and returns the directory.
+func writeCheckProject(t *testing.T, mainSpx string) string {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.spx"), []byte(mainSpx), 0o644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "assets"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "assets", "index.json"), []byte(`{}`), 0o644))
+	return dir
+}
+
+func TestRunCheck(t *testing.T) {
+	t.Run("Text", func(t *testing.T) {
+		dir := writeCheckProject(t, `undefinedVar
+run "assets", {}
+`)
+		var stdout, stderr bytes.Buffer
+		code := runCheck([]string{dir}, &stdout, &stderr)
+		assert.Equal(t, checkExitErrors, code)
+		assert.Equal(t, filepath.Join(dir, "main.spx")+":1:1: error: undefinedVar is not defined (spx0017)\n", stdout.String())
+		assert.Equal(t, "1 error, 0 warnings\n", stderr.String())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		dir := writeCheckProject(t, `undefinedVar
+run "assets", {}
+`)
+		var stdout, stderr bytes.Buffer
+		code := runCheck([]string{"-format=json", dir}, &stdout, &stderr)
+		assert.Equal(t, checkExitErrors, code)
+
+		var report checkReport
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+		assert.Equal(t, 1, report.Errors)
+		require.Len(t, report.Diagnostics, 1)
+		assert.Equal(t, "main.spx", report.Diagnostics[0].File)
+		assert.Equal(t, "spx0017", report.Diagnostics[0].Code)
+		assert.Contains(t, stdout.String(), `"severity": "error"`)
+	})
+
+	t.Run("SARIF", func(t *testing.T) {
+		dir := writeCheckProject(t, `undefinedVar
+run "assets", {}
+`)
+		var stdout, stderr bytes.Buffer
+		code := runCheck([]string{"-format=sarif", dir}, &stdout, &stderr)
+		assert.Equal(t, checkExitErrors, code)
+		assert.Contains(t, stdout.String(), `"version": "2.1.0"`)
+		assert.Contains(t, stdout.String(), `"uri": "`+filepath.ToSlash(dir)+`/main.spx"`)
+		assert.Contains(t, stdout.String(), `"ruleId": "spx0017"`)
+	})
+
+	t.Run("NoErrors", func(t *testing.T) {
+		dir := writeCheckProject(t, `run "assets", {}`)
+		var stdout, stderr bytes.Buffer
+		code := runCheck([]string{"-format=json", dir}, &stdout, &stderr)
+		assert.Equal(t, checkExitOK, code)
+		assert.JSONEq(t, `{"diagnostics": [], "errors": 0, "warnings": 0}`, stdout.String())
+	})
+
+	t.Run("NoProject", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCheck([]string{t.TempDir()}, &stdout, &stderr)
+		assert.Equal(t, checkExitFailures, code)
+		assert.Contains(t, stderr.String(), "failed to analyze project")
+	})
+
+	t.Run("BadArguments", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, checkExitFailures, runCheck(nil, &stdout, &stderr))
+		assert.Equal(t, checkExitFailures, runCheck([]string{"-format=xml", "."}, &stdout, &stderr))
+		assert.Contains(t, stderr.String(), "usage: goxlsw check")
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/goplus/goxlsw/xgoanalysis"
+)
+
+// Exit codes of the index subcommand.
+const (
+	indexExitOK       = 0
+	indexExitFailures = 1
+)
+
+// runIndex runs the index subcommand with the given arguments, e.g.,
+// ["-o", "dump.lsif", "./game"], which writes an LSIF dump of the project in
+// the given directory and returns the exit code. The dump is written to stdout
+// if no output file is given.
+func runIndex(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("goxlsw index", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	output := flags.String("o", "", "output file of the LSIF dump; stdout if empty")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: goxlsw index [-o dump.lsif] <projectdir>")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return indexExitFailures
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return indexExitFailures
+	}
+	dir := flags.Arg(0)
+
+	if err := writeIndex(dir, *output, stdout); err != nil {
+		fmt.Fprintf(stderr, "goxlsw index: %v\n", err)
+		return indexExitFailures
+	}
+	return indexExitOK
+}
+
+// writeIndex writes an LSIF dump of the project in the given directory to the
+// given output file, or stdout if it is empty.
+func writeIndex(dir, output string, stdout io.Writer) (err error) {
+	rootURI, err := fileURIForDir(dir)
+	if err != nil {
+		return err
+	}
+	project, err := xgoanalysis.LoadProject(os.DirFS(dir))
+	if err != nil {
+		return fmt.Errorf("failed to analyze project %q: %w", dir, err)
+	}
+
+	w := stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer func() {
+			if closeErr := f.Close(); err == nil && closeErr != nil {
+				err = fmt.Errorf("failed to close output file: %w", closeErr)
+			}
+		}()
+		w = f
+	}
+	if err := project.WriteLSIF(w, string(rootURI)); err != nil {
+		return fmt.Errorf("failed to write LSIF dump: %w", err)
+	}
+	return nil
+}
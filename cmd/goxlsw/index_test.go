@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunIndex(t *testing.T) {
+	t.Run("Stdout", func(t *testing.T) {
+		dir := writeCheckProject(t, `run "assets", {}`)
+		rootURI, err := fileURIForDir(dir)
+		require.NoError(t, err)
+
+		var stdout, stderr bytes.Buffer
+		code := runIndex([]string{dir}, &stdout, &stderr)
+		assert.Equal(t, indexExitOK, code)
+		assert.Empty(t, stderr.String())
+		assert.Contains(t, stdout.String(), `"label":"metaData"`)
+		assert.Contains(t, stdout.String(), `"uri":"`+string(rootURI)+`main.spx"`)
+	})
+
+	t.Run("OutputFile", func(t *testing.T) {
+		dir := writeCheckProject(t, `run "assets", {}`)
+		output := filepath.Join(t.TempDir(), "dump.lsif")
+
+		var stdout, stderr bytes.Buffer
+		code := runIndex([]string{"-o", output, dir}, &stdout, &stderr)
+		assert.Equal(t, indexExitOK, code)
+		assert.Empty(t, stdout.String())
+		dump, err := os.ReadFile(output)
+		require.NoError(t, err)
+		assert.Contains(t, string(dump), `"label":"document"`)
+	})
+
+	t.Run("NoProject", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runIndex([]string{t.TempDir()}, &stdout, &stderr)
+		assert.Equal(t, indexExitFailures, code)
+		assert.Contains(t, stderr.String(), "failed to analyze project")
+	})
+
+	t.Run("BadArguments", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.Equal(t, indexExitFailures, runIndex(nil, &stdout, &stderr))
+		assert.Contains(t, stderr.String(), "usage: goxlsw index")
+	})
+}
@@ -0,0 +1,102 @@
+// Command goxlsw runs the Go+ language server for spx. By default it speaks
+// LSP over stdio, so that it can be used by desktop editors such as VS Code
+// and Neovim. With -listen=host:port it serves multiple concurrent sessions
+// over TCP instead, and with -listen=ws://host:port/path it serves browser
+// clients over WebSocket. WebSocket connections from browsers are only
+// accepted from the same origin, or from the comma-separated origins given by
+// -origin.
+//
+// With the check subcommand, i.e., "goxlsw check [-format=text|json|sarif]
+// <projectdir>", it prints the diagnostics of the project in the given
+// directory instead, and exits with status 1 if any of them is an error, so
+// that it can gate submissions in CI. With the index subcommand, i.e.,
+// "goxlsw index [-o dump.lsif] <projectdir>", it writes an LSIF dump of the
+// project for offline code navigation.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "check":
+			os.Exit(runCheck(os.Args[2:], os.Stdout, os.Stderr))
+		case "index":
+			os.Exit(runIndex(os.Args[2:], os.Stdout, os.Stderr))
+		}
+	}
+
+	dir := flag.String("dir", ".", "root directory of the workspace")
+	listen := flag.String("listen", "", "address to listen on, such as :8080 or ws://localhost:8080/lsp; serves over stdio if empty")
+	origin := flag.String("origin", "", "comma-separated origins allowed to connect over WebSocket in addition to the same origin, such as https://example.com, or * for any")
+	verbose := flag.Bool("v", false, "log debug messages")
+	flag.Parse()
+
+	level := slog.LevelInfo
+	if *verbose {
+		level = slog.LevelDebug
+	}
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
+
+	if err := run(*listen, *dir, parseOrigins(*origin)); err != nil {
+		slog.Error("failed to run", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run serves the workspace in the given directory at the given listen address,
+// or over stdio if the address is empty. The allowed origins only apply to
+// WebSocket connections.
+func run(listen, dir string, allowedOrigins []string) error {
+	if listen == "" {
+		return serveStream(jsonrpc2.NewHeaderStream(stdio{}), dir)
+	}
+	if !strings.Contains(listen, "://") {
+		return listenAndServeTCP(listen, dir)
+	}
+	u, err := url.Parse(listen)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %w", listen, err)
+	}
+	switch u.Scheme {
+	case "tcp":
+		return listenAndServeTCP(u.Host, dir)
+	case "ws":
+		return listenAndServeWebSocket(u, dir, allowedOrigins)
+	default:
+		return fmt.Errorf("unsupported listen address %q", listen)
+	}
+}
+
+// parseOrigins parses the given comma-separated origins.
+func parseOrigins(s string) []string {
+	var origins []string
+	for _, origin := range strings.Split(s, ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// stdio is an [io.ReadWriteCloser] over the standard input and output.
+type stdio struct{}
+
+// Read implements [io.Reader].
+func (stdio) Read(p []byte) (int, error) { return os.Stdin.Read(p) }
+
+// Write implements [io.Writer].
+func (stdio) Write(p []byte) (int, error) { return os.Stdout.Write(p) }
+
+// Close implements [io.Closer].
+func (stdio) Close() error { return errors.Join(os.Stdin.Close(), os.Stdout.Close()) }
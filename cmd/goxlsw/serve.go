@@ -0,0 +1,78 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+	"github.com/goplus/goxlsw/internal/server"
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// session is an LSP session served over a [jsonrpc2.Stream].
+type session struct {
+	stream  jsonrpc2.Stream
+	writeMu sync.Mutex
+}
+
+// ReplyMessage implements [server.MessageReplier].
+func (s *session) ReplyMessage(m jsonrpc2.Message) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	return s.stream.Write(m)
+}
+
+// serveStream serves an LSP session over the given stream for the workspace
+// in the given directory. It returns once the client sends exit or the stream
+// is closed.
+func serveStream(stream jsonrpc2.Stream, dir string) error {
+	rootURI, err := fileURIForDir(dir)
+	if err != nil {
+		return err
+	}
+	sess := &session{stream: stream}
+	s := server.New(
+		vfs.NewLocalMapFS(dir),
+		sess,
+		server.WithWorkspaceRootURI(rootURI),
+		server.WithLogger(slog.Default()),
+	)
+	for {
+		m, err := stream.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("failed to read message: %w", err)
+		}
+		if err := s.HandleMessage(m); err != nil {
+			slog.Error("failed to handle message", "error", err)
+		}
+		if n, ok := m.(*jsonrpc2.Notification); ok && n.Method() == "exit" {
+			return nil
+		}
+	}
+}
+
+// fileURIForDir returns the file URI of the given directory, which always
+// ends with "/".
+func fileURIForDir(dir string) (server.DocumentURI, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to get absolute path of %q: %w", dir, err)
+	}
+	p := filepath.ToSlash(absDir)
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p // Windows paths start with drive letters.
+	}
+	if !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return server.DocumentURI((&url.URL{Scheme: "file", Path: p}).String()), nil
+}
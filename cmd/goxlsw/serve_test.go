@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+	"github.com/goplus/goxlsw/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeStream(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "main.spx"), []byte(`
+onStart => {
+	echo "Hello"
+}
+
+run "assets", {Title: "My Game"}
+`), 0o644))
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "assets"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "assets", "index.json"), []byte(`{}`), 0o644))
+		rootURI, err := fileURIForDir(dir)
+		require.NoError(t, err)
+
+		serverConn, clientConn := net.Pipe()
+		done := make(chan error, 1)
+		go func() {
+			done <- serveStream(jsonrpc2.NewHeaderStream(serverConn), dir)
+		}()
+		client := jsonrpc2.NewHeaderStream(clientConn)
+		defer client.Close()
+
+		call, err := jsonrpc2.NewCall(jsonrpc2.NewIntID(1), "initialize", server.InitializeParams{})
+		require.NoError(t, err)
+		require.NoError(t, client.Write(call))
+		m, err := client.Read()
+		require.NoError(t, err)
+		resp, ok := m.(*jsonrpc2.Response)
+		require.True(t, ok)
+		require.NoError(t, resp.Err())
+		var result server.InitializeResult
+		require.NoError(t, json.Unmarshal(resp.Result(), &result))
+		require.NotNil(t, result.ServerInfo)
+		assert.Equal(t, "goxlsw", result.ServerInfo.Name)
+
+		call, err = jsonrpc2.NewCall(jsonrpc2.NewIntID(2), "textDocument/documentSymbol", server.DocumentSymbolParams{
+			TextDocument: server.TextDocumentIdentifier{URI: rootURI + "main.spx"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, client.Write(call))
+		m, err = client.Read()
+		require.NoError(t, err)
+		resp, ok = m.(*jsonrpc2.Response)
+		require.True(t, ok)
+		require.NoError(t, resp.Err())
+		assert.Contains(t, string(resp.Result()), `"onStart"`)
+
+		exit, err := jsonrpc2.NewNotification("exit", nil)
+		require.NoError(t, err)
+		require.NoError(t, client.Write(exit))
+		require.NoError(t, <-done)
+	})
+
+	t.Run("ClosedStream", func(t *testing.T) {
+		serverConn, clientConn := net.Pipe()
+		done := make(chan error, 1)
+		go func() {
+			done <- serveStream(jsonrpc2.NewHeaderStream(serverConn), t.TempDir())
+		}()
+		require.NoError(t, clientConn.Close())
+		require.NoError(t, <-done)
+	})
+
+	t.Run("InvalidHeader", func(t *testing.T) {
+		serverConn, clientConn := net.Pipe()
+		done := make(chan error, 1)
+		go func() {
+			done <- serveStream(jsonrpc2.NewHeaderStream(serverConn), t.TempDir())
+		}()
+		_, err := clientConn.Write([]byte("Content-Length\r\n\r\n"))
+		require.NoError(t, err)
+		assert.ErrorContains(t, <-done, `invalid header line "Content-Length"`)
+		clientConn.Close()
+	})
+}
+
+func TestFileURIForDir(t *testing.T) {
+	uri, err := fileURIForDir(t.TempDir())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(uri), "file:///"))
+	assert.True(t, strings.HasSuffix(string(uri), "/"))
+}
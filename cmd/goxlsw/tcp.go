@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+)
+
+// listenAndServeTCP serves LSP sessions over TCP at the given address for the
+// workspace in the given directory.
+func listenAndServeTCP(addr, dir string) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	slog.Info("listening", "address", ln.Addr().String())
+	return serveListener(ln, dir)
+}
+
+// serveListener serves an LSP session over each connection accepted from the
+// given listener for the workspace in the given directory. Sessions run
+// concurrently, and each has its own server with isolated workspace state. It
+// returns once the listener is closed.
+func serveListener(ln net.Listener, dir string) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			stream := jsonrpc2.NewHeaderStream(conn)
+			defer stream.Close()
+			if err := serveStream(stream, dir); err != nil {
+				slog.Error("failed to serve connection", "remoteAddr", conn.RemoteAddr().String(), "error", err)
+			}
+		}()
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+	"github.com/goplus/goxlsw/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeListener(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "main.spx"), []byte(`
+onStart => {
+	echo "Hello"
+}
+
+run "assets", {Title: "My Game"}
+`), 0o644))
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "assets"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "assets", "index.json"), []byte(`{}`), 0o644))
+		rootURI, err := fileURIForDir(dir)
+		require.NoError(t, err)
+
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		done := make(chan error, 1)
+		go func() {
+			done <- serveListener(ln, dir)
+		}()
+
+		dial := func() jsonrpc2.Stream {
+			conn, err := net.Dial("tcp", ln.Addr().String())
+			require.NoError(t, err)
+			return jsonrpc2.NewHeaderStream(conn)
+		}
+		readResponse := func(stream jsonrpc2.Stream) *jsonrpc2.Response {
+			for {
+				m, err := stream.Read()
+				require.NoError(t, err)
+				if resp, ok := m.(*jsonrpc2.Response); ok {
+					return resp
+				}
+			}
+		}
+		documentSymbols := func(stream jsonrpc2.Stream, id int64) string {
+			call, err := jsonrpc2.NewCall(jsonrpc2.NewIntID(id), "textDocument/documentSymbol", server.DocumentSymbolParams{
+				TextDocument: server.TextDocumentIdentifier{URI: rootURI + "main.spx"},
+			})
+			require.NoError(t, err)
+			require.NoError(t, stream.Write(call))
+			resp := readResponse(stream)
+			require.NoError(t, resp.Err())
+			return string(resp.Result())
+		}
+
+		clientA := dial()
+		defer clientA.Close()
+		clientB := dial()
+		defer clientB.Close()
+
+		didOpen, err := jsonrpc2.NewNotification("textDocument/didOpen", server.DidOpenTextDocumentParams{
+			TextDocument: server.TextDocumentItem{
+				URI:     rootURI + "main.spx",
+				Version: 1,
+				Text: `
+onClick => {
+	echo "Hello"
+}
+
+run "assets", {Title: "My Game"}
+`,
+			},
+		})
+		require.NoError(t, err)
+		require.NoError(t, clientA.Write(didOpen))
+
+		assert.Contains(t, documentSymbols(clientA, 1), `"onClick"`)
+		symbolsB := documentSymbols(clientB, 1)
+		assert.Contains(t, symbolsB, `"onStart"`)
+		assert.NotContains(t, symbolsB, `"onClick"`)
+
+		require.NoError(t, ln.Close())
+		require.NoError(t, <-done)
+	})
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+	"golang.org/x/net/websocket"
+)
+
+// wsStream is a [jsonrpc2.Stream] over a WebSocket connection. Each message is
+// transferred as a single text frame without any headers.
+type wsStream struct {
+	conn *websocket.Conn
+}
+
+// Read implements [jsonrpc2.Stream].
+func (s *wsStream) Read() (jsonrpc2.Message, error) {
+	var data []byte
+	if err := websocket.Message.Receive(s.conn, &data); err != nil {
+		return nil, err
+	}
+	return jsonrpc2.DecodeMessage(data)
+}
+
+// Write implements [jsonrpc2.Stream].
+func (s *wsStream) Write(msg jsonrpc2.Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshaling message: %v", err)
+	}
+	return websocket.Message.Send(s.conn, string(data))
+}
+
+// Close implements [jsonrpc2.Stream].
+func (s *wsStream) Close() error {
+	return s.conn.Close()
+}
+
+// newWebSocketHandler returns an [http.Handler] that serves an LSP session
+// over each WebSocket connection for the workspace in the given directory.
+//
+// As any web page the user visits can open WebSocket connections to local
+// addresses, connections from browsers are only accepted from the same origin
+// or from the given allowed origins. See [checkWebSocketOrigin].
+func newWebSocketHandler(dir string, allowedOrigins []string) http.Handler {
+	return websocket.Server{
+		Handshake: func(_ *websocket.Config, req *http.Request) error {
+			return checkWebSocketOrigin(req, allowedOrigins)
+		},
+		Handler: func(conn *websocket.Conn) {
+			stream := &wsStream{conn: conn}
+			defer stream.Close()
+			if err := serveStream(stream, dir); err != nil {
+				slog.Error("failed to serve connection", "remoteAddr", conn.Request().RemoteAddr, "error", err)
+			}
+		},
+	}
+}
+
+// checkWebSocketOrigin checks whether the origin of the given WebSocket
+// handshake request is allowed. Requests without an Origin header come from
+// non-browser clients and are always allowed. Otherwise, the origin must be
+// the same as the requested host or one of the given allowed origins, such as
+// "https://example.com", where "*" allows any origin.
+func checkWebSocketOrigin(req *http.Request, allowedOrigins []string) error {
+	origin := req.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	for _, allowed := range allowedOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return nil
+		}
+	}
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && strings.EqualFold(u.Host, req.Host) {
+		return nil
+	}
+	slog.Warn("rejected WebSocket connection from disallowed origin", "remoteAddr", req.RemoteAddr, "origin", origin)
+	return fmt.Errorf("origin %q is not allowed", origin)
+}
+
+// listenAndServeWebSocket serves LSP sessions over WebSocket at the given
+// ws:// URL for the workspace in the given directory. See
+// [newWebSocketHandler] for the allowed origins.
+func listenAndServeWebSocket(u *url.URL, dir string, allowedOrigins []string) error {
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	mux := http.NewServeMux()
+	mux.Handle(path, newWebSocketHandler(dir, allowedOrigins))
+	slog.Info("listening", "address", u.String())
+	return http.ListenAndServe(u.Host, mux)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+	"github.com/goplus/goxlsw/internal/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/websocket"
+)
+
+func TestWebSocketHandler(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		ts := httptest.NewServer(newWebSocketHandler(t.TempDir(), nil))
+		defer ts.Close()
+
+		conn, err := websocket.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), "", ts.URL)
+		require.NoError(t, err)
+		client := &wsStream{conn: conn}
+		defer client.Close()
+
+		call, err := jsonrpc2.NewCall(jsonrpc2.NewIntID(1), "initialize", server.InitializeParams{})
+		require.NoError(t, err)
+		require.NoError(t, client.Write(call))
+		m, err := client.Read()
+		require.NoError(t, err)
+		resp, ok := m.(*jsonrpc2.Response)
+		require.True(t, ok)
+		require.NoError(t, resp.Err())
+		var result server.InitializeResult
+		require.NoError(t, json.Unmarshal(resp.Result(), &result))
+		require.NotNil(t, result.ServerInfo)
+		assert.Equal(t, "goxlsw", result.ServerInfo.Name)
+	})
+
+	t.Run("InvalidMessage", func(t *testing.T) {
+		ts := httptest.NewServer(newWebSocketHandler(t.TempDir(), nil))
+		defer ts.Close()
+
+		conn, err := websocket.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), "", ts.URL)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		require.NoError(t, websocket.Message.Send(conn, "not json"))
+		var data []byte
+		assert.Error(t, websocket.Message.Receive(conn, &data))
+	})
+
+	t.Run("CrossOrigin", func(t *testing.T) {
+		ts := httptest.NewServer(newWebSocketHandler(t.TempDir(), nil))
+		defer ts.Close()
+
+		_, err := websocket.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), "", "http://evil.example.com")
+		assert.ErrorContains(t, err, "bad status")
+	})
+
+	t.Run("AllowedOrigin", func(t *testing.T) {
+		ts := httptest.NewServer(newWebSocketHandler(t.TempDir(), []string{"https://builder.example.com"}))
+		defer ts.Close()
+
+		conn, err := websocket.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), "", "https://builder.example.com")
+		require.NoError(t, err)
+		conn.Close()
+
+		_, err = websocket.Dial("ws"+strings.TrimPrefix(ts.URL, "http"), "", "https://other.example.com")
+		assert.ErrorContains(t, err, "bad status")
+	})
+}
+
+func TestCheckWebSocketOrigin(t *testing.T) {
+	for _, tt := range []struct {
+		name           string
+		origin         string
+		allowedOrigins []string
+		wantErr        bool
+	}{
+		{"NoOrigin", "", nil, false},
+		{"SameOrigin", "http://localhost:8080", nil, false},
+		{"CrossOrigin", "https://evil.example.com", nil, true},
+		{"DifferentPort", "http://localhost:3000", nil, true},
+		{"AllowedOrigin", "https://builder.example.com", []string{"https://builder.example.com/"}, false},
+		{"AllowedOriginCaseInsensitive", "https://Builder.example.com", []string{"https://builder.example.com"}, false},
+		{"NotAllowedOrigin", "https://evil.example.com", []string{"https://builder.example.com"}, true},
+		{"AnyOrigin", "https://evil.example.com", []string{"*"}, false},
+		{"NullOrigin", "null", nil, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "http://localhost:8080/lsp", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			err := checkWebSocketOrigin(req, tt.allowedOrigins)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestParseOrigins(t *testing.T) {
+	assert.Nil(t, parseOrigins(""))
+	assert.Equal(t, []string{"https://a.example.com", "*"}, parseOrigins(" https://a.example.com, ,*"))
+}
+
+func TestRun(t *testing.T) {
+	t.Run("UnsupportedListenAddress", func(t *testing.T) {
+		err := run("http://localhost:8080", t.TempDir(), nil)
+		assert.EqualError(t, err, `unsupported listen address "http://localhost:8080"`)
+	})
+}
@@ -8,6 +8,7 @@ require (
 	github.com/goplus/mod v0.13.17
 	github.com/goplus/spx v1.1.1-0.20250214074125-e9e1f6362499
 	github.com/stretchr/testify v1.9.0
+	golang.org/x/net v0.35.0
 	golang.org/x/tools v0.30.0
 )
 
@@ -33,7 +34,6 @@ require (
 	golang.org/x/image v0.18.0 // indirect
 	golang.org/x/mobile v0.0.0-20220518205345-8578da9835fd // indirect
 	golang.org/x/mod v0.23.0 // indirect
-	golang.org/x/net v0.35.0 // indirect
 	golang.org/x/sync v0.11.0 // indirect
 	golang.org/x/sys v0.30.0 // indirect
 	golang.org/x/text v0.22.0 // indirect
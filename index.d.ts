@@ -6,8 +6,28 @@ export interface Spxls {
    * Handles incoming LSP messages from the client.
    *
    * @param message - The message to process. Any required response will be sent via the messageReplier callback.
+   *                  Responses to server-initiated requests are passed back through this method too.
    */
-  handleMessage(message: RequestMessage | NotificationMessage): Error | null
+  handleMessage(message: RequestMessage | NotificationMessage | ResponseMessage): SpxlsError | null
+}
+
+/**
+ * An error returned by the language server API. Errors returned by `NewSpxls` and `Spxls.handleMessage` are always
+ * instances of `SpxlsError`.
+ */
+export interface SpxlsError extends Error {
+  name: 'SpxlsError'
+
+  /**
+   * A JSON-RPC error code indicating the type of failure, e.g., -32602 for invalid arguments and -32700 for messages
+   * that cannot be decoded. Errors without a specific code use -32001.
+   */
+  code: number
+
+  /**
+   * Additional information about the error. Can be omitted.
+   */
+  data?: string | number | boolean | any[] | object | null
 }
 
 
@@ -15,14 +35,51 @@ declare global {
   /**
    * Creates a new instance of the spx language server.
    *
+   * `NewSpxls` is available as soon as `go.run(instance)` has been called on the instantiated WebAssembly module. Once
+   * created, the client must complete the LSP initialization handshake before sending other messages:
+   *
+   * 1. Send an `initialize` request and wait for its response.
+   * 2. Send an `initialized` notification.
+   *
    * @param filesProvider - Function that provides access to the workspace files. All paths in the returned Files are
    *                       relative to the workspace root. This will be called whenever the language server needs to
    *                       access the file system.
    *
    * @param messageReplier - Function called when the language server needs to reply to the client. The client should
    *                        handle these messages according to the LSP specification.
+   *
+   * @param packageLoader - Optional function that supplies the export data of packages not bundled with the language
+   *                       server, e.g., `math/rand` or community packages, such as `.pkgexport` blobs fetched over
+   *                       HTTP. It may return a Promise, and returns or resolves to `null` if the package is not
+   *                       available. Loaded packages are cached by the language server.
+   *
+   * @param cache - Optional storage persisting data of the language server across sessions, e.g., backed by IndexedDB,
+   *               so that reopening a project does not load the same packages via `packageLoader` or parse the same
+   *               resource metadata files again.
+   */
+  function NewSpxls(filesProvider: () => Files, messageReplier: (message: ResponseMessage | NotificationMessage | RequestMessage) => void, packageLoader?: PackageLoader, cache?: Cache): Spxls | SpxlsError
+}
+
+/**
+ * Function that supplies the export data of the package with the given path, in the format of the `.pkgexport` files
+ * of the bundled package data.
+ */
+export type PackageLoader = (pkgPath: string) => Uint8Array | ArrayBuffer | null | Promise<Uint8Array | ArrayBuffer | null>
+
+/**
+ * Storage persisting data of the language server across sessions. Entries are keyed by hex-encoded SHA-256 hashes of
+ * the inputs they are derived from, so the data of a key never changes and entries can be evicted at any time.
+ */
+export interface Cache {
+  /**
+   * Returns the data stored with the given key, or `null` if there is none.
    */
-  function NewSpxls(filesProvider: () => Files, messageReplier: (message: ResponseMessage | NotificationMessage) => void): Spxls | Error
+  get(key: string): Uint8Array | ArrayBuffer | null | Promise<Uint8Array | ArrayBuffer | null>
+
+  /**
+   * Stores the data with the given key.
+   */
+  put(key: string, data: Uint8Array): void | Promise<void>
 }
 
 /**
@@ -126,8 +183,12 @@ export type Files = {
 
 /**
  * A file in the workspace.
+ *
+ * The content can be an `ArrayBuffer`, so that files transferred from another context via `postMessage` can be passed
+ * without copying them first. Contents are cached by modification time and size, so the modification time must change
+ * whenever the content changes.
  */
 export type File = {
-  content: Uint8Array
+  content: Uint8Array | ArrayBuffer
   modTime: number // unix timestamp in milliseconds
 }
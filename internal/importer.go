@@ -3,6 +3,8 @@ package internal
 import (
 	"fmt"
 	"go/types"
+	"io"
+	"maps"
 	"sync"
 
 	goptoken "github.com/goplus/gop/token"
@@ -49,5 +51,28 @@ func (imp *importer) Import(path string) (*types.Package, error) {
 	return pkg, nil
 }
 
+// ImportExport imports the package with the given path from the given export
+// data, which is in the format of the package data, i.e., written by
+// [gcexportdata.Write]. Its dependencies are resolved against the given
+// packages and those imported from the package data. Unlike [importer.Import],
+// the decoded packages are not remembered, so that the callers can manage
+// their lifetimes.
+func (imp *importer) ImportExport(path string, export io.Reader, deps map[string]*types.Package) (*types.Package, error) {
+	imp.mu.Lock()
+	defer imp.mu.Unlock()
+
+	imports := maps.Clone(imp.loaded)
+	for depPath, dep := range deps {
+		if _, ok := imports[depPath]; !ok {
+			imports[depPath] = dep
+		}
+	}
+	pkg, err := gcexportdata.Read(export, imp.fset, imports, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse package export data: %w", err)
+	}
+	return pkg, nil
+}
+
 // Importer is the global instance of [importer].
 var Importer = newImporter()
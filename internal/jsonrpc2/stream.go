@@ -0,0 +1,107 @@
+// Copyright 2018 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package jsonrpc2
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Stream abstracts the transport mechanics from the JSON RPC protocol.
+// Each call to Read or Write fully transfers a single message, or returns an
+// error.
+// A stream is not safe for concurrent use, it is expected it will be used by
+// a single reader and a single writer in a safe manner.
+type Stream interface {
+	// Read gets the next message from the stream.
+	Read() (Message, error)
+	// Write sends a message to the stream.
+	Write(Message) error
+	// Close closes the connection.
+	// Any blocked Read or Write operations will be unblocked and return errors.
+	Close() error
+}
+
+// NewHeaderStream returns a Stream built on top of an [io.ReadWriteCloser].
+// The messages are sent with HTTP content length and MIME type headers.
+// This is the format used by LSP and others.
+func NewHeaderStream(conn io.ReadWriteCloser) Stream {
+	return &headerStream{
+		conn: conn,
+		in:   bufio.NewReader(conn),
+	}
+}
+
+type headerStream struct {
+	conn io.ReadWriteCloser
+	in   *bufio.Reader
+}
+
+func (s *headerStream) Read() (Message, error) {
+	var total, length int64
+	// read the header, stop on the first empty line
+	for {
+		line, err := s.in.ReadString('\n')
+		total += int64(len(line))
+		if err != nil {
+			if err == io.EOF {
+				if total == 0 {
+					return nil, io.EOF
+				}
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, fmt.Errorf("failed reading header line: %w", err)
+		}
+		line = strings.TrimSpace(line)
+		// check we have a header line
+		if line == "" {
+			break
+		}
+		colon := strings.IndexRune(line, ':')
+		if colon < 0 {
+			return nil, fmt.Errorf("invalid header line %q", line)
+		}
+		name, value := line[:colon], strings.TrimSpace(line[colon+1:])
+		switch name {
+		case "Content-Length":
+			if length, err = strconv.ParseInt(value, 10, 32); err != nil {
+				return nil, fmt.Errorf("failed parsing Content-Length: %v", value)
+			}
+			if length <= 0 {
+				return nil, fmt.Errorf("invalid Content-Length: %v", length)
+			}
+		default:
+			// ignoring unknown headers
+		}
+	}
+	if length == 0 {
+		return nil, fmt.Errorf("missing Content-Length header")
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.in, data); err != nil {
+		return nil, err
+	}
+	return DecodeMessage(data)
+}
+
+func (s *headerStream) Write(msg Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("marshaling message: %v", err)
+	}
+	if _, err := fmt.Fprintf(s.conn, "Content-Length: %v\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err = s.conn.Write(data)
+	return err
+}
+
+func (s *headerStream) Close() error {
+	return s.conn.Close()
+}
@@ -31,6 +31,9 @@ var (
 	//ErrServerOverloaded is returned when a message was refused due to a
 	//server being temporarily unable to accept any new messages.
 	ErrServerOverloaded = NewError(-32000, "JSON RPC overloaded")
+	// ErrRequestCancelled is returned when a call was canceled by the client
+	// via $/cancelRequest, as defined by the language server protocol.
+	ErrRequestCancelled = NewError(-32800, "JSON RPC request cancelled")
 )
 
 // wireRequest is sent to a server to represent a Call or Notify operation.
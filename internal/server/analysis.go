@@ -0,0 +1,166 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"go/types"
+	"slices"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	goptypesutil "github.com/goplus/gop/x/typesutil"
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// ErrNoProject is the error returned by [Analyze] if there is no project
+// classfile, e.g., main.spx.
+var ErrNoProject = errNoMainSpxFile
+
+// Analysis is the result of analyzing a project without a client, see
+// [Analyze].
+type Analysis struct {
+	// ClassfileKind is the name of the classfile kind of the project, e.g.,
+	// "spx".
+	ClassfileKind string
+
+	// MainFile is the project classfile, e.g., "main.spx".
+	MainFile string
+
+	// Fset is the file set of the classfiles.
+	Fset *goptoken.FileSet
+
+	// Files maps the classfiles to their ASTs.
+	Files map[string]*gopast.File
+
+	// Pkg is the main package of the project.
+	Pkg *types.Package
+
+	// TypeInfo is the type information of the main package.
+	TypeInfo *goptypesutil.Info
+
+	// Resources are the IDs of the backdrops, sounds, sprites and widgets of
+	// an spx project, sorted by their URIs.
+	Resources []SpxResourceID
+
+	// ResourceRefs are the references to spx resources in the classfiles.
+	ResourceRefs []AnalysisResourceRef
+
+	// UnusedResources are the IDs of the backdrops, sounds and sprite costumes
+	// of an spx project that are never referenced from the classfiles or other
+	// resource metadata, sorted by their URIs. Resources selected by
+	// non-constant names cannot be detected, so they are only candidates for
+	// removal.
+	UnusedResources []SpxResourceID
+
+	// Diagnostics are the diagnostics of the files of the project, the same
+	// as those published to clients, sorted by their files and ranges.
+	Diagnostics []AnalysisDiagnostic
+
+	result *compileResult
+}
+
+// AnalysisResourceRef is a reference to an spx resource in [Analysis].
+type AnalysisResourceRef struct {
+	// ID is the ID of the referenced resource.
+	ID SpxResourceID
+
+	// Kind is the kind of the reference.
+	Kind SpxResourceRefKind
+
+	// File is the classfile of the reference.
+	File string
+
+	// Range is the range of the reference in the file.
+	Range Range
+}
+
+// AnalysisDiagnostic is a diagnostic in [Analysis].
+type AnalysisDiagnostic struct {
+	// File is the file of the diagnostic relative to the project root.
+	File string
+
+	Diagnostic
+}
+
+// Analyze runs the same compile, type-check and resource-resolution pipeline
+// as the server over the project in the given file system, for batch tools
+// not speaking LSP. The options are those of [New], e.g.,
+// [WithPackageLoader] and [WithClassfileKind]. It returns an error wrapping
+// [ErrNoProject] if there is no project classfile.
+func Analyze(ctx context.Context, mapFS *vfs.MapFS, opts ...Option) (*Analysis, error) {
+	s := New(mapFS, nil, opts...)
+	result, err := s.compileWithContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	analysis := &Analysis{
+		ClassfileKind: result.classfileKind.Name,
+		MainFile:      result.mainSpxFile,
+		Fset:          result.fset,
+		Files:         result.mainASTPkg.Files,
+		Pkg:           result.mainPkg,
+		TypeInfo:      result.typeInfo,
+		Resources:     result.spxResourceSet.ids(),
+		result:        result,
+	}
+	for _, ref := range result.spxResourceRefs {
+		analysis.ResourceRefs = append(analysis.ResourceRefs, AnalysisResourceRef{
+			ID:    ref.ID,
+			Kind:  ref.Kind,
+			File:  result.nodeFilename(ref.Node),
+			Range: result.rangeForNode(ref.Node),
+		})
+	}
+	for _, unused := range result.spxUnusedResources() {
+		analysis.UnusedResources = append(analysis.UnusedResources, unused.id)
+	}
+
+	analysis.Diagnostics = s.analysisDiagnosticsOf([]*compileResult{result})
+	return analysis, nil
+}
+
+// analysisDiagnosticsOf returns the diagnostics of the given compile results
+// as those published to clients, with their files relative to the workspace
+// root, sorted by their files and ranges.
+func (s *Server) analysisDiagnosticsOf(results []*compileResult) []AnalysisDiagnostic {
+	config := s.config()
+	var diagnostics []AnalysisDiagnostic
+	for documentURI, fileDiags := range allDiagnostics(results) {
+		file := strings.TrimPrefix(string(documentURI), string(s.workspaceRootURI))
+		for _, diag := range s.describeDiagnostics(config.applyDiagnosticSeverityOverrides(fileDiags)) {
+			diagnostics = append(diagnostics, AnalysisDiagnostic{File: file, Diagnostic: diag})
+		}
+	}
+	slices.SortStableFunc(diagnostics, func(a, b AnalysisDiagnostic) int {
+		return cmp.Or(
+			cmp.Compare(a.File, b.File),
+			cmp.Compare(a.Range.Start.Line, b.Range.Start.Line),
+			cmp.Compare(a.Range.Start.Character, b.Range.Start.Character),
+		)
+	})
+	return diagnostics
+}
+
+// ids returns the IDs of the backdrops, sounds, sprites and widgets in the
+// set, sorted by their URIs.
+func (set *SpxResourceSet) ids() []SpxResourceID {
+	var ids []SpxResourceID
+	for _, backdrop := range set.backdrops {
+		ids = append(ids, backdrop.ID)
+	}
+	for _, sound := range set.sounds {
+		ids = append(ids, sound.ID)
+	}
+	for _, sprite := range set.sprites {
+		ids = append(ids, sprite.ID)
+	}
+	for _, widget := range set.widgets {
+		ids = append(ids, widget.ID)
+	}
+	slices.SortFunc(ids, func(a, b SpxResourceID) int {
+		return cmp.Compare(a.URI(), b.URI())
+	})
+	return ids
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"slices"
+)
+
+// analysisRequirement is a set of parts of a [compileResult] required by an
+// [analyzer].
+type analysisRequirement uint
+
+const (
+	// requireAST requires the ASTs of the spx files.
+	requireAST analysisRequirement = 1 << iota
+
+	// requireTypeInfo requires the type information of the main package.
+	requireTypeInfo
+
+	// requireResourceSet requires the successfully loaded spx resource set,
+	// along with the spx resource auto-bindings.
+	requireResourceSet
+)
+
+// analyzer is an analysis pass reporting diagnostics over a [compileResult],
+// similar to an analyzer of golang.org/x/tools/go/analysis.
+type analyzer struct {
+	// name is the name of the analyzer, by which it can be disabled in
+	// [Config.Analyzers].
+	name string
+
+	// requires is the parts of the compile result required by the analyzer.
+	// The analyzer does not run if any of them is unavailable.
+	requires analysisRequirement
+
+	// run runs the analyzer. It may run concurrently with other analyzers, so
+	// it must not modify the compile result except the states owned by it.
+	run func(pass *analysisPass)
+}
+
+// defaultAnalyzers are the analyzers run by default.
+var defaultAnalyzers = []*analyzer{
+	unusedImportAnalyzer,
+	spxMessageAnalyzer,
+	unusedSymbolAnalyzer,
+	controlFlowAnalyzer,
+	shadowedVariableAnalyzer,
+	loopVariableCaptureAnalyzer,
+	spriteAutoBindingAnalyzer,
+	gameConfigAnalyzer,
+}
+
+// analysisPass is a run of an [analyzer] over a [compileResult].
+type analysisPass struct {
+	result      *compileResult
+	config      *Config
+	diagnostics []analysisDiagnostic
+}
+
+// analysisDiagnostic is a diagnostic reported by an [analysisPass].
+type analysisDiagnostic struct {
+	spxFile string
+	diag    Diagnostic
+}
+
+// report reports a diagnostic in the given spx file. It is dropped if its code
+// is disabled in [Config.Analyzers].
+func (p *analysisPass) report(spxFile string, diag Diagnostic) {
+	if code, ok := diag.Code.(string); ok && !p.config.analyzerEnabled(code) {
+		return
+	}
+	p.diagnostics = append(p.diagnostics, analysisDiagnostic{spxFile: spxFile, diag: diag})
+}
+
+// useAnalyzer adds the given analyzer to run after the default ones.
+func (s *Server) useAnalyzer(a *analyzer) {
+	s.analyzersMu.Lock()
+	defer s.analyzersMu.Unlock()
+	s.extraAnalyzers = append(s.extraAnalyzers, a)
+}
+
+// analyzers returns the analyzers to run over compile results.
+func (s *Server) analyzers() []*analyzer {
+	s.analyzersMu.Lock()
+	defer s.analyzersMu.Unlock()
+	return append(slices.Clone(defaultAnalyzers), s.extraAnalyzers...)
+}
+
+// runAnalyzers concurrently runs the enabled analyzers whose requirements are
+// satisfied by the given compile result with at most [Server.analysisWorkers]
+// workers, and then adds their diagnostics to it in the order of the
+// analyzers.
+func (s *Server) runAnalyzers(result *compileResult) {
+	config := s.config()
+	analyzers := s.analyzers()
+	passes := make([]*analysisPass, len(analyzers))
+	forEachConcurrently(s.analysisWorkers, len(analyzers), func(i int) {
+		a := analyzers[i]
+		if !config.analyzerEnabled(a.name) || !result.satisfiesAnalysisRequirement(a.requires) {
+			return
+		}
+		pass := &analysisPass{result: result, config: config}
+		a.run(pass)
+		passes[i] = pass
+	})
+
+	for _, pass := range passes {
+		if pass == nil {
+			continue
+		}
+		for _, d := range pass.diagnostics {
+			result.addDiagnosticsForSpxFile(d.spxFile, d.diag)
+		}
+	}
+}
+
+// satisfiesAnalysisRequirement reports whether the compile result provides all
+// parts in the given requirement.
+func (r *compileResult) satisfiesAnalysisRequirement(req analysisRequirement) bool {
+	if req&requireAST != 0 && (r.mainASTPkg == nil || len(r.mainASTPkg.Files) == 0) {
+		return false
+	}
+	if req&requireTypeInfo != 0 && (r.mainPkg == nil || r.typeInfo == nil) {
+		return false
+	}
+	// The resource maps are only created once the resource set is loaded.
+	if req&requireResourceSet != 0 && r.spxResourceSet.sprites == nil {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerRunAnalyzers(t *testing.T) {
+	newServer := func(files map[string][]byte) *Server {
+		s := New(newMapFSWithoutModTime(files), nil)
+		s.useAnalyzer(&analyzer{
+			name:     "test",
+			requires: requireAST | requireResourceSet,
+			run: func(pass *analysisPass) {
+				pass.report(pass.result.mainSpxFile, Diagnostic{
+					Severity: SeverityHint,
+					Code:     "testFinding",
+					Message:  "test finding",
+				})
+			},
+		})
+		return s
+	}
+	diagnosticsFor := func(t *testing.T, s *Server) []Diagnostic {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		return fullReport.Items
+	}
+	mainSpx := []byte(`
+func f() int {
+}
+
+onStart => {
+	echo f()
+}
+run "assets", {Title: "My Game"}
+`)
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer(map[string][]byte{
+			"main.spx":          mainSpx,
+			"assets/index.json": []byte(`{}`),
+		})
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 2)
+		assert.Equal(t, DiagnosticCodeMissingReturn, diags[0].Code)
+		assert.Equal(t, "testFinding", diags[1].Code)
+	})
+
+	t.Run("DisabledByName", func(t *testing.T) {
+		s := newServer(map[string][]byte{
+			"main.spx":          mainSpx,
+			"assets/index.json": []byte(`{}`),
+		})
+		err := s.workspaceDidChangeConfiguration(&DidChangeConfigurationParams{
+			Settings: map[string]any{"analyzers": map[string]any{
+				"controlFlow": false,
+				"test":        false,
+			}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, diagnosticsFor(t, s))
+	})
+
+	t.Run("DisabledByCode", func(t *testing.T) {
+		s := newServer(map[string][]byte{
+			"main.spx":          mainSpx,
+			"assets/index.json": []byte(`{}`),
+		})
+		err := s.workspaceDidChangeConfiguration(&DidChangeConfigurationParams{
+			Settings: map[string]any{"analyzers": map[string]any{"testFinding": false}},
+		})
+		require.NoError(t, err)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, DiagnosticCodeMissingReturn, diags[0].Code)
+	})
+
+	t.Run("UnsatisfiedRequirement", func(t *testing.T) {
+		s := newServer(map[string][]byte{
+			"main.spx":          mainSpx,
+			"assets/index.json": []byte(`{`),
+		})
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 2)
+		assert.Equal(t, DiagnosticCodeInvalidResourceSet, diags[0].Code)
+		assert.Equal(t, DiagnosticCodeMissingReturn, diags[1].Code)
+	})
+}
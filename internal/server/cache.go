@@ -0,0 +1,51 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Cache persists data of the server across sessions, e.g., in IndexedDB in
+// browsers or in a directory on disk, so that reopening a project does not
+// repeat expensive work like fetching the export data of packages via
+// [PackageLoader]. Entries are keyed by hex-encoded SHA-256 hashes of the
+// inputs they are derived from, so stored data never changes for a key and
+// entries can be evicted at any time. Implementations must be safe for
+// concurrent use.
+type Cache interface {
+	// Get returns the data stored with the given key. It returns an error
+	// wrapping [fs.ErrNotExist] if there is none. It may block, e.g., while
+	// reading from disk.
+	Get(key string) ([]byte, error)
+
+	// Put stores the data with the given key. Failures are logged and
+	// otherwise ignored, as the data can always be derived again.
+	Put(key string, data []byte) error
+}
+
+// WithCache sets the cache persisting data of the server across sessions. It
+// keeps the export data of packages loaded by [PackageLoader] and the parsed
+// metadata files of spx resources.
+func WithCache(cache Cache) Option {
+	return func(s *Server) {
+		s.cache = cache
+	}
+}
+
+// Kinds of data persisted in [Cache].
+const (
+	cacheKindPackageExport = "pkgexport"
+	cacheKindSpxResources  = "spxresources"
+)
+
+// cacheKey returns the [Cache] key of the data of the given kind derived from
+// the given inputs.
+func cacheKey(kind string, inputs ...string) string {
+	h := sha256.New()
+	h.Write([]byte(kind))
+	for _, input := range inputs {
+		h.Write([]byte{0})
+		h.Write([]byte(input))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,292 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mapCache is a [Cache] backed by a map.
+type mapCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	getErr  error
+	puts    int
+}
+
+// Get implements [Cache].
+func (c *mapCache) Get(key string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.getErr != nil {
+		return nil, c.getErr
+	}
+	data, ok := c.entries[key]
+	if !ok {
+		return nil, fmt.Errorf("no cache entry %s: %w", key, fs.ErrNotExist)
+	}
+	return data, nil
+}
+
+// Put implements [Cache].
+func (c *mapCache) Put(key string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string][]byte)
+	}
+	c.entries[key] = data
+	c.puts++
+	return nil
+}
+
+func TestCacheKey(t *testing.T) {
+	key := cacheKey(cacheKindPackageExport, "example.com/greet")
+	assert.Len(t, key, 64)
+	assert.Equal(t, key, cacheKey(cacheKindPackageExport, "example.com/greet"))
+	assert.NotEqual(t, key, cacheKey(cacheKindPackageExport, "example.com/other"))
+	assert.NotEqual(t, key, cacheKey("other", "example.com/greet"))
+	assert.NotEqual(t, cacheKey("kind", "a", "bc"), cacheKey("kind", "ab", "c"))
+}
+
+func TestServerWithCache(t *testing.T) {
+	files := map[string][]byte{
+		"main.spx": []byte(`import "example.com/greet"
+
+echo greet.hello
+run "assets", {}
+`),
+		"assets/index.json": []byte(`{}`),
+		"go.mod":            []byte("module example.com/game\n\ngo 1.23\n\nrequire example.com/greet v1.0.0\n"),
+	}
+	newServer := func(t *testing.T, cache Cache, loads *[]string) *Server {
+		return New(newMapFSWithoutModTime(files), nil, WithCache(cache), WithPackageLoader(PackageLoaderFunc(func(pkgPath string) ([]byte, error) {
+			*loads = append(*loads, pkgPath)
+			if pkgPath != "example.com/greet" {
+				return nil, fs.ErrNotExist
+			}
+			return newTestPackageExport(t, pkgPath), nil
+		}), 0))
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		cache := &mapCache{}
+
+		var loads []string
+		result, err := newServer(t, cache, &loads).compile()
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics["file:///main.spx"])
+		assert.Equal(t, []string{"example.com/greet"}, loads)
+		// The export data of the package and the parsed resource metadata.
+		assert.Equal(t, 2, cache.puts)
+
+		// A new session reuses the persisted export data.
+		loads = nil
+		result, err = newServer(t, cache, &loads).compile()
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics["file:///main.spx"])
+		assert.Empty(t, loads)
+		assert.Equal(t, 2, cache.puts)
+	})
+
+	t.Run("CorruptedEntry", func(t *testing.T) {
+		cache := &mapCache{entries: map[string][]byte{
+			cacheKey(cacheKindPackageExport, "example.com/greet", "v1.0.0"): []byte("corrupted"),
+		}}
+
+		var loads []string
+		result, err := newServer(t, cache, &loads).compile()
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics["file:///main.spx"])
+		assert.Equal(t, []string{"example.com/greet"}, loads)
+		assert.Equal(t, 2, cache.puts)
+		assert.NotEqual(t, []byte("corrupted"), cache.entries[cacheKey(cacheKindPackageExport, "example.com/greet", "v1.0.0")])
+	})
+
+	t.Run("UnknownModuleVersion", func(t *testing.T) {
+		cache := &mapCache{}
+		unversioned := maps.Clone(files)
+		delete(unversioned, "go.mod")
+		newUnversionedServer := func(loads *[]string) *Server {
+			return New(newMapFSWithoutModTime(unversioned), nil, WithCache(cache), WithPackageLoader(PackageLoaderFunc(func(pkgPath string) ([]byte, error) {
+				*loads = append(*loads, pkgPath)
+				return newTestPackageExport(t, pkgPath), nil
+			}), 0))
+		}
+
+		var loads []string
+		result, err := newUnversionedServer(&loads).compile()
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics["file:///main.spx"])
+		assert.Equal(t, []string{"example.com/greet"}, loads)
+		// Only the parsed resource metadata is persisted, as nothing would
+		// tell stale export data of the package apart.
+		assert.Equal(t, 1, cache.puts)
+		assert.NotContains(t, cache.entries, cacheKey(cacheKindPackageExport, "example.com/greet", ""))
+
+		// A new session loads the package again.
+		loads = nil
+		result, err = newUnversionedServer(&loads).compile()
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics["file:///main.spx"])
+		assert.Equal(t, []string{"example.com/greet"}, loads)
+		assert.Equal(t, 1, cache.puts)
+	})
+
+	t.Run("ModuleVersionChange", func(t *testing.T) {
+		cache := &mapCache{}
+		withGoMod := func(version string) map[string][]byte {
+			versioned := maps.Clone(files)
+			versioned["go.mod"] = []byte("module example.com/game\n\ngo 1.23\n\nrequire example.com/greet " + version + "\n")
+			return versioned
+		}
+		newVersionedServer := func(version string, loads *[]string) *Server {
+			return New(newMapFSWithoutModTime(withGoMod(version)), nil, WithCache(cache), WithPackageLoader(PackageLoaderFunc(func(pkgPath string) ([]byte, error) {
+				*loads = append(*loads, pkgPath)
+				return newTestPackageExport(t, pkgPath), nil
+			}), 0))
+		}
+
+		var loads []string
+		result, err := newVersionedServer("v1.0.0", &loads).compile()
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics["file:///main.spx"])
+		assert.Equal(t, []string{"example.com/greet"}, loads)
+		assert.Contains(t, cache.entries, cacheKey(cacheKindPackageExport, "example.com/greet", "v1.0.0"))
+
+		// Requiring another version of the module loads the package again
+		// instead of reusing the persisted export data of the old version.
+		loads = nil
+		s := newVersionedServer("v1.1.0", &loads)
+		result, err = s.compile()
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics["file:///main.spx"])
+		assert.Equal(t, []string{"example.com/greet"}, loads)
+		assert.Contains(t, cache.entries, cacheKey(cacheKindPackageExport, "example.com/greet", "v1.1.0"))
+		assert.Equal(t, 3, cache.puts)
+
+		// So does the in-memory cache of the server.
+		loads = nil
+		s.workspaceRootFS = newMapFSWithoutModTime(withGoMod("v1.2.0"))
+		result, err = s.compile()
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics["file:///main.spx"])
+		assert.Equal(t, []string{"example.com/greet"}, loads)
+		assert.Equal(t, 4, cache.puts)
+
+		// Switching back reuses the persisted export data.
+		loads = nil
+		result, err = newVersionedServer("v1.0.0", &loads).compile()
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics["file:///main.spx"])
+		assert.Empty(t, loads)
+		assert.Equal(t, 4, cache.puts)
+	})
+
+	t.Run("SpxResources", func(t *testing.T) {
+		resourceFiles := map[string][]byte{
+			"main.spx":                           []byte("var (\n\tMySprite Sprite\n)\nrun \"assets\", {}\n"),
+			"MySprite.spx":                       []byte("onStart => {\n\tsetCostume \"c1\"\n}\n"),
+			"assets/index.json":                  []byte(`{"backdrops":[{"name":"b1","path":"b1.png"}],"zorder":["MySprite",{"name":"score","type":"monitor"}]}`),
+			"assets/sounds/s1/index.json":        []byte(`{"path":"s1.wav"}`),
+			"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"c0","path":"c0.png"},{"name":"c1","path":"c1.png"},{"name":"c2","path":"c2.png"}],"fAnimations":{"walk":{"frameFrom":"c0","frameTo":"c1"}}}`),
+		}
+		cache := &mapCache{}
+
+		result, err := New(newMapFSWithoutModTime(resourceFiles), nil, WithCache(cache)).compile()
+		require.NoError(t, err)
+		assert.Equal(t, 1, cache.puts)
+		want := result.spxResourceSet
+
+		// A new session decodes the persisted resources instead of parsing
+		// the metadata files again.
+		result, err = New(newMapFSWithoutModTime(resourceFiles), nil, WithCache(cache)).compile()
+		require.NoError(t, err)
+		assert.Equal(t, 1, cache.puts)
+		assert.Equal(t, want, result.spxResourceSet)
+		sprite := result.spxResourceSet.Sprite("MySprite")
+		require.NotNil(t, sprite)
+		require.Len(t, sprite.Animations, 1)
+		assert.Equal(t, 0, *sprite.Animations[0].FromIndex)
+		assert.Equal(t, []SpxSpriteCostumeResource{sprite.Costumes[2]}, sprite.NormalCostumes)
+		assert.NotNil(t, result.spxResourceSet.Widget("score"))
+		assert.NotNil(t, result.spxResourceSet.Sound("s1"))
+
+		// Changing a metadata file parses them again.
+		changed := maps.Clone(resourceFiles)
+		changed["assets/sprites/MySprite/index.json"] = []byte(`{"costumes":[{"name":"c1","path":"c1.png"}]}`)
+		result, err = New(newMapFSWithoutModTime(changed), nil, WithCache(cache)).compile()
+		require.NoError(t, err)
+		assert.Equal(t, 2, cache.puts)
+		sprite = result.spxResourceSet.Sprite("MySprite")
+		require.NotNil(t, sprite)
+		assert.Len(t, sprite.Costumes, 1)
+
+		// Corrupted entries are parsed again and replaced.
+		for key := range cache.entries {
+			cache.entries[key] = []byte("corrupted")
+		}
+		result, err = New(newMapFSWithoutModTime(resourceFiles), nil, WithCache(cache)).compile()
+		require.NoError(t, err)
+		assert.Equal(t, 3, cache.puts)
+		assert.Equal(t, want, result.spxResourceSet)
+	})
+
+	t.Run("GetError", func(t *testing.T) {
+		cache := &mapCache{getErr: errors.New("storage unavailable")}
+
+		var loads []string
+		result, err := newServer(t, cache, &loads).compile()
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics["file:///main.spx"])
+		assert.Equal(t, []string{"example.com/greet"}, loads)
+	})
+}
+
+// BenchmarkSpxResourceSetCache benchmarks creating the resource set of a
+// project of 50 sprites with 20 costumes each, with and without the parsed
+// metadata files in the cache.
+func BenchmarkSpxResourceSetCache(b *testing.B) {
+	files := map[string][]byte{
+		"assets/index.json": []byte(`{"backdrops":[{"name":"b1","path":"b1.png"}],"map":{"width":480,"height":360}}`),
+	}
+	for i := range 50 {
+		sprite := `{"heading":90,"x":0,"y":0,"size":1,"visible":true,"costumeIndex":0,"costumes":[`
+		for j := range 20 {
+			if j > 0 {
+				sprite += ","
+			}
+			sprite += fmt.Sprintf(`{"name":"c%d","path":"c%d.svg","x":10,"y":20,"faceRight":0,"bitmapResolution":2}`, j, j)
+		}
+		sprite += `],"fAnimations":{"walk":{"frameFrom":"c0","frameTo":"c9"},"run":{"frameFrom":"c10","frameTo":"c19"}},"defaultAnimation":"walk"}`
+		files[fmt.Sprintf("assets/sprites/Sprite%d/index.json", i)] = []byte(sprite)
+	}
+	rootFS := newMapFSWithoutModTime(files).Snapshot().Sub("assets")
+
+	b.Run("Parse", func(b *testing.B) {
+		for range b.N {
+			if _, err := newSpxResourceSet(rootFS, 1, nil, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Cached", func(b *testing.B) {
+		cache := &mapCache{}
+		if _, err := newSpxResourceSet(rootFS, 1, cache, nil); err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for range b.N {
+			if _, err := newSpxResourceSet(rootFS, 1, cache, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
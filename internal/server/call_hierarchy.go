@@ -0,0 +1,244 @@
+package server
+
+import (
+	"cmp"
+	"go/types"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+	"github.com/goplus/goxlsw/internal/util"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_prepareCallHierarchy
+func (s *Server) textDocumentPrepareCallHierarchy(params *CallHierarchyPrepareParams) ([]CallHierarchyItem, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+	position := result.toPosition(astFile, params.Position)
+
+	ident := result.identAtASTFilePosition(astFile, position)
+	if ident == nil {
+		return nil, nil
+	}
+	if callExpr := result.spxEventHandlerCallExprFor(astFile, ident); callExpr != nil {
+		return []CallHierarchyItem{result.callHierarchyItemForSpxEventHandler(callExpr)}, nil
+	}
+	fun, ok := result.typeInfo.ObjectOf(ident).(*types.Func)
+	if !ok {
+		return nil, nil
+	}
+	item, ok := result.callHierarchyItemForFunc(fun)
+	if !ok {
+		return nil, nil
+	}
+	return []CallHierarchyItem{item}, nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#callHierarchy_incomingCalls
+func (s *Server) callHierarchyIncomingCalls(params *CallHierarchyIncomingCallsParams) ([]CallHierarchyIncomingCall, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.Item.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+	position := result.toPosition(astFile, params.Item.SelectionRange.Start)
+
+	// Event handlers are called by the spx runtime only.
+	fun, ok := result.typeInfo.ObjectOf(result.identAtASTFilePosition(astFile, position)).(*types.Func)
+	if !ok {
+		return nil, nil
+	}
+
+	var (
+		calls     []CallHierarchyIncomingCall
+		callIndex = make(map[gopast.Node]int)
+	)
+	refIdents := result.refIdentsFor(fun)
+	slices.SortFunc(refIdents, func(a, b *gopast.Ident) int {
+		return cmp.Compare(a.Pos(), b.Pos())
+	})
+	for _, refIdent := range refIdents {
+		refASTFile := result.nodeASTFile(refIdent)
+		if refASTFile == nil {
+			continue
+		}
+		caller, item, ok := result.callHierarchyCallerOf(refASTFile, refIdent)
+		if !ok {
+			continue
+		}
+		i, ok := callIndex[caller]
+		if !ok {
+			i = len(calls)
+			callIndex[caller] = i
+			calls = append(calls, CallHierarchyIncomingCall{From: item})
+		}
+		calls[i].FromRanges = append(calls[i].FromRanges, result.rangeForNode(refIdent))
+	}
+	return calls, nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#callHierarchy_outgoingCalls
+func (s *Server) callHierarchyOutgoingCalls(params *CallHierarchyOutgoingCallsParams) ([]CallHierarchyOutgoingCall, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.Item.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+	position := result.toPosition(astFile, params.Item.SelectionRange.Start)
+
+	ident := result.identAtASTFilePosition(astFile, position)
+	if ident == nil {
+		return nil, nil
+	}
+	var body gopast.Node
+	if callExpr := result.spxEventHandlerCallExprFor(astFile, ident); callExpr != nil {
+		body = callExpr
+	} else if funcDecl, ok := result.mainASTPkgIdentToFuncDecl[ident]; ok && !funcDecl.Shadow {
+		body = funcDecl.Body
+	}
+	if body == nil {
+		return nil, nil
+	}
+
+	var (
+		calls     []CallHierarchyOutgoingCall
+		callIndex = make(map[*types.Func]int)
+	)
+	gopast.Inspect(body, func(node gopast.Node) bool {
+		var funcIdent *gopast.Ident
+		switch node := node.(type) {
+		case *gopast.CallExpr:
+			funcIdent = funcIdentOf(node.Fun)
+		case *gopast.ExprStmt:
+			// Command-style calls without arguments, e.g., "foo".
+			funcIdent = funcIdentOf(node.X)
+		}
+		if funcIdent == nil {
+			return true
+		}
+		callee, ok := result.typeInfo.ObjectOf(funcIdent).(*types.Func)
+		if !ok {
+			return true
+		}
+		i, ok := callIndex[callee]
+		if !ok {
+			item, ok := result.callHierarchyItemForFunc(callee)
+			if !ok {
+				return true
+			}
+			i = len(calls)
+			callIndex[callee] = i
+			calls = append(calls, CallHierarchyOutgoingCall{To: item})
+		}
+		if fromRange := result.rangeForNode(funcIdent); !slices.Contains(calls[i].FromRanges, fromRange) {
+			calls[i].FromRanges = append(calls[i].FromRanges, fromRange)
+		}
+		return true
+	})
+	return calls, nil
+}
+
+// callHierarchyItemForFunc returns the call hierarchy item for the given
+// function. It returns false if the function is not defined in the main
+// package.
+func (r *compileResult) callHierarchyItemForFunc(fun *types.Func) (CallHierarchyItem, bool) {
+	if !isMainPkgObject(fun) {
+		return CallHierarchyItem{}, false
+	}
+	defIdent := r.defIdentFor(fun)
+	if defIdent == nil || !r.isInFset(defIdent.Pos()) {
+		return CallHierarchyItem{}, false
+	}
+	funcDecl, ok := r.mainASTPkgIdentToFuncDecl[defIdent]
+	if !ok || funcDecl.Shadow {
+		return CallHierarchyItem{}, false
+	}
+
+	kind := Function
+	if funcDecl.Recv != nil && len(funcDecl.Recv.List) > 0 {
+		kind = Method
+	}
+	return CallHierarchyItem{
+		Name:           fun.Name(),
+		Kind:           kind,
+		Detail:         getSimplifiedTypeString(fun.Type()),
+		URI:            r.nodeDocumentURI(funcDecl),
+		Range:          r.rangeForNode(funcDecl),
+		SelectionRange: r.rangeForNode(defIdent),
+	}, true
+}
+
+// callHierarchyItemForSpxEventHandler returns the call hierarchy item for the
+// spx event handler registered by the given call expression.
+func (r *compileResult) callHierarchyItemForSpxEventHandler(callExpr *gopast.CallExpr) CallHierarchyItem {
+	funcIdent := callExpr.Fun.(*gopast.Ident)
+	item := CallHierarchyItem{
+		Name:           funcIdent.Name,
+		Kind:           Event,
+		URI:            r.nodeDocumentURI(callExpr),
+		Range:          r.rangeForNode(callExpr),
+		SelectionRange: r.rangeForNode(funcIdent),
+	}
+	for _, arg := range callExpr.Args {
+		if tv, ok := r.typeInfo.Types[arg]; ok && tv.Value != nil && types.AssignableTo(tv.Type, types.Typ[types.String]) {
+			item.Detail = tv.Value.ExactString()
+			break
+		}
+	}
+	return item
+}
+
+// spxEventHandlerCallExprFor returns the call expression that registers an spx
+// event handler if the given identifier is its function, or nil otherwise.
+func (r *compileResult) spxEventHandlerCallExprFor(astFile *gopast.File, ident *gopast.Ident) *gopast.CallExpr {
+	if !isSpxEventHandlerFuncName(ident.Name) || !isSpxPkgObject(r.typeInfo.ObjectOf(ident)) {
+		return nil
+	}
+	path, _ := util.PathEnclosingInterval(astFile, ident.Pos(), ident.End())
+	if len(path) < 2 {
+		return nil
+	}
+	callExpr, ok := path[1].(*gopast.CallExpr)
+	if !ok || callExpr.Fun != ident {
+		return nil
+	}
+	return callExpr
+}
+
+// callHierarchyCallerOf returns the innermost spx event handler call
+// expression or function declaration enclosing the given identifier, along
+// with its call hierarchy item.
+func (r *compileResult) callHierarchyCallerOf(astFile *gopast.File, ident *gopast.Ident) (gopast.Node, CallHierarchyItem, bool) {
+	path, _ := util.PathEnclosingInterval(astFile, ident.Pos(), ident.End())
+	for _, node := range path[1:] {
+		switch node := node.(type) {
+		case *gopast.CallExpr:
+			funcIdent, ok := node.Fun.(*gopast.Ident)
+			if !ok || funcIdent == ident {
+				continue
+			}
+			if r.spxEventHandlerCallExprFor(astFile, funcIdent) == node {
+				return node, r.callHierarchyItemForSpxEventHandler(node), true
+			}
+		case *gopast.FuncDecl:
+			if node.Shadow {
+				return nil, CallHierarchyItem{}, false
+			}
+			fun, ok := r.typeInfo.Defs[node.Name].(*types.Func)
+			if !ok {
+				return nil, CallHierarchyItem{}, false
+			}
+			item, ok := r.callHierarchyItemForFunc(fun)
+			return node, item, ok
+		}
+	}
+	return nil, CallHierarchyItem{}, false
+}
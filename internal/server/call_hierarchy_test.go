@@ -0,0 +1,204 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCallHierarchyTestFileMap() map[string][]byte {
+	return map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+
+func addScore(n int) {
+	println n
+}
+
+func reset() {
+	addScore 0
+}
+
+onStart => {
+	addScore 1
+	reset
+}
+
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx": []byte(`
+onMsg "hit", => {
+	addScore 10
+	addScore 20
+}
+`),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{}`),
+	}
+}
+
+func TestServerTextDocumentPrepareCallHierarchy(t *testing.T) {
+	t.Run("Func", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newCallHierarchyTestFileMap()), nil)
+		items, err := s.textDocumentPrepareCallHierarchy(&CallHierarchyPrepareParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 2, Character: 2},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []CallHierarchyItem{
+			{
+				Name:   "addScore",
+				Kind:   Method,
+				Detail: "func(n int)",
+				URI:    "file:///main.spx",
+				Range: Range{
+					Start: Position{Line: 5, Character: 0},
+					End:   Position{Line: 7, Character: 1},
+				},
+				SelectionRange: Range{
+					Start: Position{Line: 5, Character: 5},
+					End:   Position{Line: 5, Character: 13},
+				},
+			},
+		}, items)
+	})
+
+	t.Run("EventHandler", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newCallHierarchyTestFileMap()), nil)
+		items, err := s.textDocumentPrepareCallHierarchy(&CallHierarchyPrepareParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 1, Character: 2},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []CallHierarchyItem{
+			{
+				Name:   "onMsg",
+				Kind:   Event,
+				Detail: `"hit"`,
+				URI:    "file:///MySprite.spx",
+				Range: Range{
+					Start: Position{Line: 1, Character: 0},
+					End:   Position{Line: 4, Character: 1},
+				},
+				SelectionRange: Range{
+					Start: Position{Line: 1, Character: 0},
+					End:   Position{Line: 1, Character: 5},
+				},
+			},
+		}, items)
+	})
+
+	t.Run("NonFunc", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newCallHierarchyTestFileMap()), nil)
+		items, err := s.textDocumentPrepareCallHierarchy(&CallHierarchyPrepareParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 2, Character: 1},
+			},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, items)
+	})
+}
+
+func TestServerCallHierarchyIncomingCalls(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newCallHierarchyTestFileMap()), nil)
+		items, err := s.textDocumentPrepareCallHierarchy(&CallHierarchyPrepareParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 5, Character: 6},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+
+		calls, err := s.callHierarchyIncomingCalls(&CallHierarchyIncomingCallsParams{Item: items[0]})
+		require.NoError(t, err)
+		require.Len(t, calls, 3)
+
+		callers := make(map[string][]Range)
+		for _, call := range calls {
+			callers[string(call.From.URI)+"#"+call.From.Name] = call.FromRanges
+		}
+		assert.Equal(t, map[string][]Range{
+			"file:///main.spx#onStart": {
+				{Start: Position{Line: 14, Character: 1}, End: Position{Line: 14, Character: 9}},
+			},
+			"file:///main.spx#reset": {
+				{Start: Position{Line: 10, Character: 1}, End: Position{Line: 10, Character: 9}},
+			},
+			"file:///MySprite.spx#onMsg": {
+				{Start: Position{Line: 2, Character: 1}, End: Position{Line: 2, Character: 9}},
+				{Start: Position{Line: 3, Character: 1}, End: Position{Line: 3, Character: 9}},
+			},
+		}, callers)
+	})
+
+	t.Run("EventHandler", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newCallHierarchyTestFileMap()), nil)
+		calls, err := s.callHierarchyIncomingCalls(&CallHierarchyIncomingCallsParams{
+			Item: CallHierarchyItem{
+				Name: "onMsg",
+				URI:  "file:///MySprite.spx",
+				SelectionRange: Range{
+					Start: Position{Line: 1, Character: 0},
+					End:   Position{Line: 1, Character: 5},
+				},
+			},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, calls)
+	})
+}
+
+func TestServerCallHierarchyOutgoingCalls(t *testing.T) {
+	t.Run("EventHandler", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newCallHierarchyTestFileMap()), nil)
+		items, err := s.textDocumentPrepareCallHierarchy(&CallHierarchyPrepareParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 13, Character: 2},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+
+		calls, err := s.callHierarchyOutgoingCalls(&CallHierarchyOutgoingCallsParams{Item: items[0]})
+		require.NoError(t, err)
+		require.Len(t, calls, 2)
+		assert.Equal(t, "addScore", calls[0].To.Name)
+		assert.Equal(t, []Range{
+			{Start: Position{Line: 14, Character: 1}, End: Position{Line: 14, Character: 9}},
+		}, calls[0].FromRanges)
+		assert.Equal(t, "reset", calls[1].To.Name)
+		assert.Equal(t, []Range{
+			{Start: Position{Line: 15, Character: 1}, End: Position{Line: 15, Character: 6}},
+		}, calls[1].FromRanges)
+	})
+
+	t.Run("Func", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newCallHierarchyTestFileMap()), nil)
+		items, err := s.textDocumentPrepareCallHierarchy(&CallHierarchyPrepareParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 9, Character: 6},
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, items, 1)
+
+		calls, err := s.callHierarchyOutgoingCalls(&CallHierarchyOutgoingCallsParams{Item: items[0]})
+		require.NoError(t, err)
+		require.Len(t, calls, 1)
+		assert.Equal(t, "addScore", calls[0].To.Name)
+		assert.Equal(t, "file:///main.spx", string(calls[0].To.URI))
+	})
+}
@@ -0,0 +1,157 @@
+package server
+
+import (
+	"fmt"
+	"go/types"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	"github.com/goplus/goxlsw/internal/util"
+)
+
+// refactorRewriteCallStyle is the kind of code actions converting calls
+// between the command style, e.g., `play "explosion", true`, and the
+// expression style, e.g., `play("explosion", true)`.
+const refactorRewriteCallStyle CodeActionKind = RefactorRewrite + ".callStyle"
+
+// callStyleConversion is the conversion of a call statement to the other call
+// style.
+type callStyleConversion struct {
+	// toCommand reports whether the call is converted to the command style.
+	toCommand bool
+
+	// edit is the text edit converting the call.
+	edit TextEdit
+}
+
+// callStyleCodeActions returns the code actions converting the call statement
+// at the given position in the given file to the other call style, and, if
+// there are more calls in the same style in the file, converting all of them.
+func (r *compileResult) callStyleCodeActions(spxFile string, astFile *gopast.File, position Position) []CodeAction {
+	pos := r.posAt(astFile, position)
+	path, _ := util.PathEnclosingInterval(astFile, pos, pos)
+	var exprStmt *gopast.ExprStmt
+	for _, node := range path {
+		if stmt, ok := node.(*gopast.ExprStmt); ok {
+			exprStmt = stmt
+			break
+		}
+	}
+	if exprStmt == nil {
+		return nil
+	}
+	conversion, ok := r.callStyleConversionOf(astFile, exprStmt)
+	if !ok {
+		return nil
+	}
+
+	style := "expression"
+	if conversion.toCommand {
+		style = "command"
+	}
+	documentURI := r.documentURIs[spxFile]
+	actions := []CodeAction{{
+		Title: fmt.Sprintf("Convert to %s-style call", style),
+		Kind:  refactorRewriteCallStyle,
+		Edit: &WorkspaceEdit{
+			Changes: map[DocumentURI][]TextEdit{documentURI: {conversion.edit}},
+		},
+	}}
+
+	var edits []TextEdit
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		stmt, ok := node.(*gopast.ExprStmt)
+		if !ok {
+			return true
+		}
+		if c, ok := r.callStyleConversionOf(astFile, stmt); ok && c.toCommand == conversion.toCommand {
+			edits = append(edits, c.edit)
+		}
+		return true
+	})
+	if len(edits) > 1 {
+		actions = append(actions, CodeAction{
+			Title: fmt.Sprintf("Convert all calls in file to %s style", style),
+			Kind:  refactorRewriteCallStyle,
+			Edit: &WorkspaceEdit{
+				Changes: map[DocumentURI][]TextEdit{documentURI: edits},
+			},
+		})
+	}
+	return actions
+}
+
+// callStyleConversionOf returns the conversion of the call of the given
+// expression statement to the other call style. It reports false if the
+// statement is not a call that can be converted, e.g., it registers an event
+// handler like `onStart => { ... }`, spans multiple lines, or its first
+// argument would be ambiguous in the command style, like `-1` in `turn(-1)`.
+func (r *compileResult) callStyleConversionOf(astFile *gopast.File, stmt *gopast.ExprStmt) (callStyleConversion, bool) {
+	if r.fset.Position(stmt.Pos()).Line != r.fset.Position(stmt.End()).Line {
+		return callStyleConversion{}, false
+	}
+
+	var fun gopast.Expr
+	switch x := stmt.X.(type) {
+	case *gopast.Ident, *gopast.SelectorExpr:
+		// A command-style call without arguments, e.g., `hide`.
+		fun = x
+	case *gopast.CallExpr:
+		fun = x.Fun
+	default:
+		return callStyleConversion{}, false
+	}
+	switch fun.(type) {
+	case *gopast.Ident, *gopast.SelectorExpr:
+	default:
+		return callStyleConversion{}, false
+	}
+	if tv, ok := r.typeInfo.Types[fun]; ok && tv.IsType() {
+		// A type conversion.
+		return callStyleConversion{}, false
+	}
+	if _, ok := r.typeInfo.TypeOf(fun).(*types.Signature); !ok {
+		return callStyleConversion{}, false
+	}
+	funText := string(r.nodeText(astFile, fun))
+
+	callExpr, ok := stmt.X.(*gopast.CallExpr)
+	if !ok {
+		return callStyleConversion{
+			edit: TextEdit{Range: r.rangeForNode(stmt.X), NewText: funText + "()"},
+		}, true
+	}
+	if callExpr.Ellipsis.IsValid() {
+		return callStyleConversion{}, false
+	}
+	for _, arg := range callExpr.Args {
+		switch arg.(type) {
+		case *gopast.FuncLit, *gopast.LambdaExpr, *gopast.LambdaExpr2:
+			return callStyleConversion{}, false
+		}
+	}
+
+	if callExpr.IsCommand() {
+		var argsText string
+		if len(callExpr.Args) > 0 {
+			argsText = string(astFile.Code[r.fset.Position(callExpr.Args[0].Pos()).Offset:r.fset.Position(callExpr.Args[len(callExpr.Args)-1].End()).Offset])
+		}
+		return callStyleConversion{
+			edit: TextEdit{Range: r.rangeForNode(callExpr), NewText: funText + "(" + argsText + ")"},
+		}, true
+	}
+
+	newText := funText
+	if len(callExpr.Args) > 0 {
+		argsText := string(astFile.Code[r.fset.Position(callExpr.Lparen).Offset+1 : r.fset.Position(callExpr.Rparen).Offset])
+		argsText = strings.TrimSuffix(strings.TrimSpace(argsText), ",")
+		if argsText == "" || strings.ContainsAny(argsText[:1], "([{-+*&^!<") {
+			return callStyleConversion{}, false
+		}
+		newText += " " + argsText
+	}
+	return callStyleConversion{
+		toCommand: true,
+		edit:      TextEdit{Range: r.rangeForNode(callExpr), NewText: newText},
+	}, true
+}
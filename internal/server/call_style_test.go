@@ -0,0 +1,106 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerCallStyleCodeActions(t *testing.T) {
+	const spx = `onStart => {
+	hide
+	MyAircraft.turn 10
+	play("biu", true)
+	show()
+	turn(-1)
+}
+`
+	codeActionsFor := func(t *testing.T, position Position) []CodeAction {
+		fileMap := newTestFileMap()
+		fileMap["MyAircraft.spx"] = []byte(spx)
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MyAircraft.spx"},
+			Range:        Range{Start: position, End: position},
+			Context:      CodeActionContext{Only: []CodeActionKind{RefactorRewrite}},
+		})
+		require.NoError(t, err)
+		return actions
+	}
+
+	t.Run("ToExpressionStyle", func(t *testing.T) {
+		actions := codeActionsFor(t, Position{Line: 2, Character: 13})
+		require.Len(t, actions, 2)
+		assert.Equal(t, CodeAction{
+			Title: "Convert to expression-style call",
+			Kind:  refactorRewriteCallStyle,
+			Edit: &WorkspaceEdit{
+				Changes: map[DocumentURI][]TextEdit{
+					"file:///MyAircraft.spx": {{
+						Range: Range{
+							Start: Position{Line: 2, Character: 1},
+							End:   Position{Line: 2, Character: 19},
+						},
+						NewText: "MyAircraft.turn(10)",
+					}},
+				},
+			},
+		}, actions[0])
+		assert.Equal(t, "Convert all calls in file to expression style", actions[1].Title)
+		assert.Equal(t, []TextEdit{
+			{
+				Range: Range{
+					Start: Position{Line: 1, Character: 1},
+					End:   Position{Line: 1, Character: 5},
+				},
+				NewText: "hide()",
+			},
+			{
+				Range: Range{
+					Start: Position{Line: 2, Character: 1},
+					End:   Position{Line: 2, Character: 19},
+				},
+				NewText: "MyAircraft.turn(10)",
+			},
+		}, actions[1].Edit.Changes["file:///MyAircraft.spx"])
+	})
+
+	t.Run("ToCommandStyle", func(t *testing.T) {
+		actions := codeActionsFor(t, Position{Line: 3, Character: 2})
+		require.Len(t, actions, 2)
+		assert.Equal(t, "Convert to command-style call", actions[0].Title)
+		assert.Equal(t, []TextEdit{{
+			Range: Range{
+				Start: Position{Line: 3, Character: 1},
+				End:   Position{Line: 3, Character: 18},
+			},
+			NewText: `play "biu", true`,
+		}}, actions[0].Edit.Changes["file:///MyAircraft.spx"])
+		assert.Equal(t, "Convert all calls in file to command style", actions[1].Title)
+		assert.Equal(t, []TextEdit{
+			{
+				Range: Range{
+					Start: Position{Line: 3, Character: 1},
+					End:   Position{Line: 3, Character: 18},
+				},
+				NewText: `play "biu", true`,
+			},
+			{
+				Range: Range{
+					Start: Position{Line: 4, Character: 1},
+					End:   Position{Line: 4, Character: 7},
+				},
+				NewText: "show",
+			},
+		}, actions[1].Edit.Changes["file:///MyAircraft.spx"])
+	})
+
+	t.Run("AmbiguousArgument", func(t *testing.T) {
+		assert.Empty(t, codeActionsFor(t, Position{Line: 5, Character: 2}))
+	})
+
+	t.Run("EventHandlerRegistration", func(t *testing.T) {
+		assert.Empty(t, codeActionsFor(t, Position{Line: 0, Character: 2}))
+	})
+}
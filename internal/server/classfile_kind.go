@@ -0,0 +1,318 @@
+package server
+
+import (
+	"fmt"
+	"go/constant"
+	"go/types"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	goptypesutil "github.com/goplus/gop/x/typesutil"
+	"github.com/goplus/goxlsw/internal/pkgdata"
+	"github.com/goplus/mod/gopmod"
+)
+
+// ClassfileKind describes a Go+ classfile framework served by the server, like
+// spx. A project of the kind consists of the classfiles with its extension in
+// the root of a workspace folder, among which the one named "main", e.g.,
+// "main.spx", is the project classfile, and the others are work classfiles.
+//
+// spx is always registered. Other kinds can be registered via
+// [WithClassfileKind], and are used by the workspace folders having their
+// project classfiles.
+type ClassfileKind struct {
+	// Name is the name of the kind, e.g., "spx".
+	Name string
+
+	// Ext is the extension of the classfiles, e.g., ".spx".
+	Ext string
+
+	// PkgPath is the path of the framework package, which must be bundled
+	// with the server or supplied by a [PackageLoader].
+	PkgPath string
+
+	// ProjectClass is the name of the base class of the project classfile in
+	// the framework package, e.g., "Game".
+	ProjectClass string
+
+	// WorkClass is the name of the base class of the work classfiles in the
+	// framework package, e.g., "SpriteImpl".
+	WorkClass string
+
+	// FilterDefinition reports whether the given exported member of the
+	// framework package is offered by completions, which allows hiding those
+	// only used by generated code. All of them are offered if it is nil.
+	FilterDefinition func(obj types.Object) bool
+
+	// LoadResources loads the resources of a project from the file system of
+	// its workspace folder, like the sprites and sounds of spx. The names of
+	// the resources are completed for the parameters of their types, and
+	// unknown names of constant arguments are reported.
+	LoadResources func(fsys fs.FS) ([]ClassfileResource, error)
+
+	// Diagnose reports additional diagnostics of a project once it is type
+	// checked.
+	Diagnose func(project *ClassfileProject) []ClassfileDiagnostic
+}
+
+// ClassfileResource is a resource of a project of a [ClassfileKind].
+type ClassfileResource struct {
+	// Type is the qualified name of the string type of the resource names,
+	// e.g., "github.com/goplus/spx.SoundName".
+	Type string
+
+	// Name is the name of the resource.
+	Name string
+
+	// File is the file of the resource relative to the workspace folder, if
+	// any.
+	File string
+}
+
+// ClassfileProject is a type-checked project of a [ClassfileKind], passed to
+// [ClassfileKind.Diagnose].
+type ClassfileProject struct {
+	// Fset is the file set of the classfiles.
+	Fset *goptoken.FileSet
+
+	// Files maps the classfiles to their ASTs.
+	Files map[string]*gopast.File
+
+	// Pkg is the main package of the project.
+	Pkg *types.Package
+
+	// TypeInfo is the type information of the main package.
+	TypeInfo *goptypesutil.Info
+
+	// Resources are the resources of the project loaded by
+	// [ClassfileKind.LoadResources].
+	Resources []ClassfileResource
+}
+
+// ClassfileDiagnostic is a diagnostic reported by [ClassfileKind.Diagnose].
+type ClassfileDiagnostic struct {
+	// File is the classfile the diagnostic is reported in.
+	File string
+
+	Diagnostic
+}
+
+// spxClassfileKind is the built-in [ClassfileKind] of spx, whose resources
+// and diagnostics are handled by the server itself.
+var spxClassfileKind = &ClassfileKind{
+	Name:         "spx",
+	Ext:          ".spx",
+	PkgPath:      "github.com/goplus/spx",
+	ProjectClass: "Game",
+	WorkClass:    "SpriteImpl",
+}
+
+// WithClassfileKind registers the given classfile kind. Kinds registered
+// earlier take precedence when a workspace folder has the project classfiles
+// of multiple kinds. It panics if the kind has no name or extension, or has
+// the extension of another kind.
+func WithClassfileKind(kind ClassfileKind) Option {
+	return func(s *Server) {
+		if kind.Name == "" || !strings.HasPrefix(kind.Ext, ".") {
+			panic(fmt.Errorf("invalid classfile kind %q with extension %q", kind.Name, kind.Ext))
+		}
+		if slices.ContainsFunc(s.classfileKinds(), func(k *ClassfileKind) bool { return k.Ext == kind.Ext }) {
+			panic(fmt.Errorf("classfile extension %q is already registered", kind.Ext))
+		}
+		s.extraClassfileKinds = append(s.extraClassfileKinds, &kind)
+	}
+}
+
+// classfileKinds returns all registered classfile kinds, spx first.
+func (s *Server) classfileKinds() []*ClassfileKind {
+	return append([]*ClassfileKind{spxClassfileKind}, s.extraClassfileKinds...)
+}
+
+// classfileKindFor returns the classfile kind of the project in the given
+// snapshot of a workspace folder, i.e., the first registered kind other than
+// spx whose project classfile exists, or spx if there is none.
+func (s *Server) classfileKindFor(snapshot fs.FS) *ClassfileKind {
+	for _, kind := range s.extraClassfileKinds {
+		if _, err := fs.Stat(snapshot, kind.projectFile()); err == nil {
+			return kind
+		}
+	}
+	return spxClassfileKind
+}
+
+// isClassfile reports whether the given file has the extension of any
+// registered classfile kind.
+func (s *Server) isClassfile(file string) bool {
+	ext := path.Ext(file)
+	return slices.ContainsFunc(s.classfileKinds(), func(kind *ClassfileKind) bool {
+		return kind.Ext == ext
+	})
+}
+
+// classfileExts returns the extensions of all registered classfile kinds,
+// e.g., ".spx or .yap".
+func (s *Server) classfileExts() string {
+	exts := make([]string, 0, len(s.extraClassfileKinds)+1)
+	for _, kind := range s.classfileKinds() {
+		exts = append(exts, kind.Ext)
+	}
+	return strings.Join(exts, " or ")
+}
+
+// isSpx reports whether the kind is spx.
+func (k *ClassfileKind) isSpx() bool {
+	return k == spxClassfileKind
+}
+
+// projectFile returns the project classfile of the kind, e.g., "main.spx".
+func (k *ClassfileKind) projectFile() string {
+	return "main" + k.Ext
+}
+
+// project returns the classfile project of the kind to be registered in the
+// module.
+func (k *ClassfileKind) project() *gopmod.Project {
+	return &gopmod.Project{
+		Ext:      k.Ext,
+		Class:    k.ProjectClass,
+		Works:    []*gopmod.Class{{Ext: k.Ext, Class: k.WorkClass}},
+		PkgPaths: []string{k.PkgPath},
+	}
+}
+
+// pkgDefinitions returns the definitions of the exported members of the
+// framework package of the kind accepted by [ClassfileKind.FilterDefinition],
+// which are offered by completions without qualifiers.
+func (k *ClassfileKind) pkgDefinitions(importer types.Importer) []SpxDefinition {
+	if k.isSpx() {
+		return GetSpxPkgDefinitions()
+	}
+	pkg, err := importer.Import(k.PkgPath)
+	if err != nil {
+		return nil
+	}
+	pkgDoc, _ := pkgdata.GetPkgDoc(k.PkgPath)
+	var defs []SpxDefinition
+	for _, name := range pkg.Scope().Names() {
+		obj := pkg.Scope().Lookup(name)
+		if !obj.Exported() || (k.FilterDefinition != nil && !k.FilterDefinition(obj)) {
+			continue
+		}
+		defs = append(defs, spxDefinitionsForPkgMember(obj, pkgDoc)...)
+	}
+	return defs
+}
+
+// inspectForClassfileResources loads the resources of the project of a
+// classfile kind other than spx, and runs its diagnostics.
+func (s *Server) inspectForClassfileResources(snapshot fs.FS, result *compileResult) {
+	kind := result.classfileKind
+	if kind.LoadResources != nil {
+		resources, err := kind.LoadResources(snapshot)
+		if err != nil {
+			result.addDiagnosticsForSpxFile(result.mainSpxFile, Diagnostic{
+				Severity: SeverityError,
+				Code:     DiagnosticCodeInvalidResourceSet,
+				Message:  fmt.Sprintf("failed to load %s resources: %v", kind.Name, err),
+			})
+		}
+		result.classfileResources = resources
+		s.inspectForClassfileResourceRefs(result)
+	}
+	if kind.Diagnose != nil {
+		diags := kind.Diagnose(&ClassfileProject{
+			Fset:      result.fset,
+			Files:     result.mainASTPkg.Files,
+			Pkg:       result.mainPkg,
+			TypeInfo:  result.typeInfo,
+			Resources: result.classfileResources,
+		})
+		for _, diag := range diags {
+			result.addDiagnosticsForSpxFile(diag.File, diag.Diagnostic)
+		}
+	}
+}
+
+// inspectForClassfileResourceRefs reports the constant string arguments of
+// resource name types whose resources do not exist.
+func (s *Server) inspectForClassfileResourceRefs(result *compileResult) {
+	names := make(map[string]map[string]bool)
+	for _, resource := range result.classfileResources {
+		if names[resource.Type] == nil {
+			names[resource.Type] = make(map[string]bool)
+		}
+		names[resource.Type][resource.Name] = true
+	}
+	for spxFile, astFile := range result.mainASTPkg.Files {
+		gopast.Inspect(astFile, func(node gopast.Node) bool {
+			callExpr, ok := node.(*gopast.CallExpr)
+			if !ok {
+				return true
+			}
+			sig, ok := result.typeInfo.TypeOf(callExpr.Fun).(*types.Signature)
+			if !ok {
+				return true
+			}
+			for i, arg := range callExpr.Args {
+				if i >= sig.Params().Len() {
+					break
+				}
+				typeName := qualifiedTypeNameOf(sig.Params().At(i).Type())
+				if names[typeName] == nil {
+					continue
+				}
+				tv, ok := result.typeInfo.Types[arg]
+				if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+					continue
+				}
+				if name := constant.StringVal(tv.Value); !names[typeName][name] {
+					result.addDiagnosticsForSpxFile(spxFile, Diagnostic{
+						Severity: SeverityError,
+						Code:     DiagnosticCodeResourceNotFound,
+						Range:    result.rangeForNode(arg),
+						Message:  fmt.Sprintf("%s resource %q not found", typeName[strings.LastIndex(typeName, ".")+1:], name),
+					})
+				}
+			}
+			return true
+		})
+	}
+}
+
+// classfileResourcesForType returns the resources of the given name type.
+func (r *compileResult) classfileResourcesForType(typ types.Type) []ClassfileResource {
+	typeName := qualifiedTypeNameOf(typ)
+	if typeName == "" {
+		return nil
+	}
+	var resources []ClassfileResource
+	for _, resource := range r.classfileResources {
+		if resource.Type == typeName {
+			resources = append(resources, resource)
+		}
+	}
+	return resources
+}
+
+// qualifiedTypeNameOf returns the qualified name of the given named or alias
+// type, e.g., "github.com/goplus/spx.SoundName". It returns an empty string
+// for other types.
+func qualifiedTypeNameOf(typ types.Type) string {
+	var obj *types.TypeName
+	switch typ := typ.(type) {
+	case *types.Named:
+		obj = typ.Obj()
+	case *types.Alias:
+		obj = typ.Obj()
+	default:
+		return ""
+	}
+	if obj.Pkg() == nil {
+		return obj.Name()
+	}
+	return obj.Pkg().Path() + "." + obj.Name()
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/gcexportdata"
+)
+
+// newTestClassfileFrameworkExport returns the export data of a classfile
+// framework package "example.com/yap" with the base classes App and Handler,
+// both having a method Render taking a TemplateName, and a method Main called
+// by the generated code.
+func newTestClassfileFrameworkExport(t *testing.T) []byte {
+	pkg := types.NewPackage("example.com/yap", "yap")
+	templateName := types.NewNamed(types.NewTypeName(token.NoPos, pkg, "TemplateName", nil), types.Typ[types.String], nil)
+	pkg.Scope().Insert(templateName.Obj())
+	for _, className := range []string{"App", "Handler"} {
+		class := types.NewNamed(types.NewTypeName(token.NoPos, pkg, className, nil), types.NewStruct(nil, nil), nil)
+		recv := types.NewVar(token.NoPos, pkg, "p", types.NewPointer(class))
+		params := types.NewTuple(types.NewVar(token.NoPos, pkg, "name", templateName))
+		class.AddMethod(types.NewFunc(token.NoPos, pkg, "Render", types.NewSignatureType(recv, nil, nil, params, nil, false)))
+		class.AddMethod(types.NewFunc(token.NoPos, pkg, "Main", types.NewSignatureType(recv, nil, nil, nil, nil, false)))
+		pkg.Scope().Insert(class.Obj())
+	}
+	for _, funcName := range []string{"Hello", "Debug"} {
+		pkg.Scope().Insert(types.NewFunc(token.NoPos, pkg, funcName, types.NewSignatureType(nil, nil, nil, nil, nil, false)))
+	}
+	pkg.MarkComplete()
+
+	var buf bytes.Buffer
+	require.NoError(t, gcexportdata.Write(&buf, token.NewFileSet(), pkg))
+	return buf.Bytes()
+}
+
+func TestServerWithClassfileKind(t *testing.T) {
+	newServer := func(t *testing.T, files map[string][]byte) *Server {
+		export := newTestClassfileFrameworkExport(t)
+		return New(newMapFSWithoutModTime(files), nil,
+			WithPackageLoader(PackageLoaderFunc(func(pkgPath string) ([]byte, error) {
+				if pkgPath != "example.com/yap" {
+					return nil, fs.ErrNotExist
+				}
+				return export, nil
+			}), 0),
+			WithClassfileKind(ClassfileKind{
+				Name:         "yap",
+				Ext:          ".yap",
+				PkgPath:      "example.com/yap",
+				ProjectClass: "App",
+				WorkClass:    "Handler",
+				FilterDefinition: func(obj types.Object) bool {
+					return obj.Name() != "Debug"
+				},
+				LoadResources: func(fsys fs.FS) ([]ClassfileResource, error) {
+					entries, err := fs.ReadDir(fsys, "templates")
+					if err != nil {
+						return nil, err
+					}
+					var resources []ClassfileResource
+					for _, entry := range entries {
+						resources = append(resources, ClassfileResource{
+							Type: "example.com/yap.TemplateName",
+							Name: strings.TrimSuffix(entry.Name(), ".html"),
+							File: "templates/" + entry.Name(),
+						})
+					}
+					return resources, nil
+				},
+				Diagnose: func(project *ClassfileProject) []ClassfileDiagnostic {
+					if len(project.Files) < 2 {
+						return []ClassfileDiagnostic{{
+							File: "main.yap",
+							Diagnostic: Diagnostic{
+								Severity: SeverityWarning,
+								Message:  "project has no handlers",
+							},
+						}}
+					}
+					return nil
+				},
+			}),
+		)
+	}
+
+	t.Run("Diagnostics", func(t *testing.T) {
+		s := newServer(t, map[string][]byte{
+			"main.yap": []byte(`render "index"
+render "missing"
+`),
+			"templates/index.html": []byte(`<html></html>`),
+		})
+		result, err := s.compile()
+		require.NoError(t, err)
+		assert.Equal(t, "main.yap", result.mainSpxFile)
+		assert.Equal(t, []Diagnostic{
+			{
+				Severity: SeverityError,
+				Code:     DiagnosticCodeResourceNotFound,
+				Range: Range{
+					Start: Position{Line: 1, Character: 7},
+					End:   Position{Line: 1, Character: 16},
+				},
+				Message: `TemplateName resource "missing" not found`,
+			},
+			{
+				Severity: SeverityWarning,
+				Message:  "project has no handlers",
+			},
+		}, result.diagnostics["file:///main.yap"])
+	})
+
+	t.Run("WorkClassfile", func(t *testing.T) {
+		s := newServer(t, map[string][]byte{
+			"main.yap":             []byte(`hello`),
+			"home.yap":             []byte(`render "index"`),
+			"templates/index.html": []byte(`<html></html>`),
+		})
+		result, err := s.compile()
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics["file:///main.yap"])
+		assert.Empty(t, result.diagnostics["file:///home.yap"])
+		require.Len(t, result.mainPkgSpriteTypes, 1)
+		assert.Equal(t, "home", result.mainPkgSpriteTypes[0].Obj().Name())
+	})
+
+	t.Run("Completion", func(t *testing.T) {
+		s := newServer(t, map[string][]byte{
+			"main.yap": []byte(`render ""
+he`),
+			"home.yap":             []byte(`render "index"`),
+			"templates/index.html": []byte(`<html></html>`),
+		})
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.yap"},
+				Position:     Position{Line: 0, Character: 8},
+			},
+		})
+		require.NoError(t, err)
+		assert.True(t, containsCompletionItemLabel(items, "index"))
+
+		items, err = s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.yap"},
+				Position:     Position{Line: 1, Character: 2},
+			},
+		})
+		require.NoError(t, err)
+		assert.True(t, containsCompletionItemLabel(items, "hello"))
+		assert.False(t, containsCompletionItemLabel(items, "debug"))
+	})
+
+	t.Run("WorkspaceSymbol", func(t *testing.T) {
+		s := newServer(t, map[string][]byte{
+			"main.yap":             []byte(`hello`),
+			"home.yap":             []byte(`render "index"`),
+			"templates/index.html": []byte(`<html></html>`),
+		})
+		symbols, err := s.workspaceSymbol(context.Background(), &WorkspaceSymbolParams{Query: "home"})
+		require.NoError(t, err)
+		require.Len(t, symbols, 1)
+		assert.Equal(t, "home", symbols[0].Name)
+		assert.Equal(t, Class, symbols[0].Kind)
+		assert.Equal(t, "home.yap", symbols[0].ContainerName)
+	})
+
+	t.Run("SpxProject", func(t *testing.T) {
+		s := newServer(t, map[string][]byte{
+			"main.spx":          []byte(`run "assets", {}`),
+			"assets/index.json": []byte(`{}`),
+		})
+		result, err := s.compile()
+		require.NoError(t, err)
+		assert.Same(t, spxClassfileKind, result.classfileKind)
+		assert.Equal(t, "main.spx", result.mainSpxFile)
+	})
+
+	t.Run("DuplicateExt", func(t *testing.T) {
+		assert.Panics(t, func() {
+			New(newMapFSWithoutModTime(nil), nil, WithClassfileKind(ClassfileKind{Name: "other", Ext: ".spx"}))
+		})
+	})
+}
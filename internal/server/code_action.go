@@ -0,0 +1,466 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io/fs"
+	"path"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_codeAction
+func (s *Server) textDocumentCodeAction(params *CodeActionParams) ([]CodeAction, error) {
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	folder, _, err := s.workspaceFolderFor(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	content, err := fs.ReadFile(s.workspaceFolderSnapshot(folder), spxFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spx source file: %w", err)
+	}
+
+	// Defer computing edits to codeAction/resolve if the client supports it.
+	resolveEditLazily := s.clientSupportsCodeActionResolve("edit")
+
+	actions := []CodeAction{}
+	for _, diag := range params.Context.Diagnostics {
+		if diag.Data == nil {
+			continue
+		}
+		var data DiagnosticData
+		if err := json.Unmarshal(*diag.Data, &data); err != nil {
+			continue
+		}
+
+		if data.Suggestion != "" {
+			actions = append(actions, CodeAction{
+				Title:       fmt.Sprintf("Change to %s", data.Suggestion),
+				Kind:        QuickFix,
+				Diagnostics: []Diagnostic{diag},
+				IsPreferred: true,
+				Edit: &WorkspaceEdit{
+					Changes: map[DocumentURI][]TextEdit{
+						params.TextDocument.URI: {{Range: diag.Range, NewText: data.Suggestion}},
+					},
+				},
+			})
+		}
+
+		action, ok := result.quickFixCodeAction(data)
+		if !ok {
+			continue
+		}
+		action.Diagnostics = []Diagnostic{diag}
+		if resolveEditLazily {
+			action.Data = makeCodeActionData(CodeActionData{
+				URI:        params.TextDocument.URI,
+				Diagnostic: data,
+			})
+		} else {
+			action.Edit = result.quickFixEdit(spxFile, astFile, data)
+			if action.Edit == nil {
+				continue
+			}
+		}
+		actions = append(actions, action)
+	}
+	for _, diag := range params.Context.Diagnostics {
+		if action, ok := ignoreDiagnosticCodeAction(params.TextDocument.URI, content, diag, result.positionEncoding); ok {
+			actions = append(actions, action)
+		}
+	}
+	if spxFile != result.mainSpxFile && result.spxClassTypeFor(spxFile) != nil {
+		actions = append(actions, result.spxEventHandlerStubCodeActions(params.TextDocument.URI, astFile, content)...)
+	}
+	if action, ok := result.extractFunctionCodeAction(spxFile, astFile, params.Range); ok {
+		actions = append(actions, action)
+	}
+	actions = append(actions, result.inlineCodeActions(spxFile, astFile, params.Range.Start)...)
+	actions = append(actions, result.callStyleCodeActions(spxFile, astFile, params.Range.Start)...)
+	return slices.DeleteFunc(actions, func(action CodeAction) bool {
+		return !codeActionKindRequested(params.Context.Only, action.Kind)
+	}), nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#codeAction_resolve
+func (s *Server) codeActionResolve(params *CodeAction) (*CodeAction, error) {
+	if params.Edit != nil || params.Data == nil {
+		return params, nil
+	}
+	var data CodeActionData
+	if err := json.Unmarshal(*params.Data, &data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal code action data: %w", err)
+	}
+
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(data.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return params, nil
+	}
+
+	action := *params
+	action.Edit = result.quickFixEdit(spxFile, astFile, data.Diagnostic)
+	return &action, nil
+}
+
+// clientSupportsCodeActionResolve reports whether the client supports lazily
+// resolving the given property of code actions via codeAction/resolve.
+func (s *Server) clientSupportsCodeActionResolve(property string) bool {
+	clientCapabilities := s.clientCapabilities.Load()
+	if clientCapabilities == nil {
+		return false
+	}
+	resolveSupport := clientCapabilities.TextDocument.CodeAction.ResolveSupport
+	return resolveSupport != nil && slices.Contains(resolveSupport.Properties, property)
+}
+
+// makeDiagnosticData makes the raw data of a diagnostic from the given data.
+func makeDiagnosticData(data DiagnosticData) *json.RawMessage {
+	return makeRawMessage(data)
+}
+
+// makeCodeActionData makes the raw data of a code action from the given data.
+func makeCodeActionData(data CodeActionData) *json.RawMessage {
+	return makeRawMessage(data)
+}
+
+// makeRawMessage marshals the given value into a raw JSON message. It returns
+// nil if the value cannot be marshaled.
+func makeRawMessage(v any) *json.RawMessage {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	rawMsg := json.RawMessage(raw)
+	return &rawMsg
+}
+
+// quickFixCodeAction returns the quick fix code action without its edit for
+// the diagnostic with the given data.
+func (r *compileResult) quickFixCodeAction(data DiagnosticData) (CodeAction, bool) {
+	action := CodeAction{Kind: QuickFix}
+	switch data.Fix {
+	case DiagnosticFixDeclareVar:
+		if r.spxResourceSet.Sprite(data.Name) != nil {
+			action.Title = fmt.Sprintf("Add sprite auto-binding var %q to main.spx", data.Name)
+			action.IsPreferred = true
+		} else {
+			action.Title = fmt.Sprintf("Declare variable %q", data.Name)
+		}
+	case DiagnosticFixRemoveUnusedImport:
+		action.Title = fmt.Sprintf("Remove unused import %q", data.Name)
+		action.IsPreferred = true
+	case DiagnosticFixRemoveDeclaration:
+		action.Title = fmt.Sprintf("Remove unused declaration %q", data.Name)
+	case DiagnosticFixCreateResource:
+		id, err := ParseSpxResourceURI(SpxResourceURI(data.Name))
+		if err != nil {
+			return CodeAction{}, false
+		}
+		switch id.(type) {
+		case SpxSpriteResourceID:
+			action.Title = fmt.Sprintf("Create sprite %q", id.Name())
+		case SpxSoundResourceID:
+			action.Title = fmt.Sprintf("Create sound %q", id.Name())
+		default:
+			return CodeAction{}, false
+		}
+	case DiagnosticFixUpdateMapSize:
+		action.Title = fmt.Sprintf("Update map %s in index.json to match the game config", data.Name)
+	case DiagnosticFixRemoveZorderEntry:
+		action.Title = fmt.Sprintf("Remove sprite %q from the zorder in index.json", data.Name)
+		action.IsPreferred = true
+	default:
+		return CodeAction{}, false
+	}
+	return action, true
+}
+
+// quickFixEdit computes the workspace edit of the quick fix for the diagnostic
+// with the given data in the given file. It returns nil if the quick fix is
+// not applicable.
+func (r *compileResult) quickFixEdit(spxFile string, astFile *gopast.File, data DiagnosticData) *WorkspaceEdit {
+	switch data.Fix {
+	case DiagnosticFixDeclareVar:
+		if r.spxResourceSet.Sprite(data.Name) != nil {
+			return r.addSpriteAutoBindingEdit(data.Name)
+		}
+		return r.declareVarEdit(spxFile, astFile, data.Name)
+	case DiagnosticFixRemoveUnusedImport:
+		return r.removeUnusedImportEdit(spxFile, astFile, data.Name)
+	case DiagnosticFixRemoveDeclaration:
+		return r.removeDeclarationEdit(spxFile, astFile, data.Name)
+	case DiagnosticFixCreateResource:
+		return r.createSpxResourceStubEdit(SpxResourceURI(data.Name))
+	case DiagnosticFixUpdateMapSize:
+		return r.updateMapSizeEdit(data.Name)
+	case DiagnosticFixRemoveZorderEntry:
+		return r.removeZorderEntryEdit(data.Name)
+	}
+	return nil
+}
+
+// addSpriteAutoBindingEdit returns a workspace edit that adds an auto-binding
+// var for the sprite with the given name to main.spx.
+func (r *compileResult) addSpriteAutoBindingEdit(spriteName string) *WorkspaceEdit {
+	mainASTFile := r.mainASTPkg.Files[r.mainSpxFile]
+	if mainASTFile == nil {
+		return nil
+	}
+
+	spriteType := "Sprite"
+	for _, named := range r.mainPkgSpriteTypes {
+		if named.Obj().Name() == spriteName {
+			spriteType = spriteName
+			break
+		}
+	}
+
+	edit := r.insertFirstVarBlockFieldEdit(mainASTFile, spriteName+" "+spriteType)
+	return &WorkspaceEdit{
+		Changes: map[DocumentURI][]TextEdit{
+			r.documentURIs[r.mainSpxFile]: {edit},
+		},
+	}
+}
+
+// declareVarEdit returns a workspace edit that declares a missing variable
+// with the given name in the first var block of the given file.
+func (r *compileResult) declareVarEdit(spxFile string, astFile *gopast.File, name string) *WorkspaceEdit {
+	var varType string
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		if varType != "" {
+			return false
+		}
+		assignStmt, ok := node.(*gopast.AssignStmt)
+		if !ok || assignStmt.Tok != goptoken.ASSIGN || len(assignStmt.Lhs) != len(assignStmt.Rhs) {
+			return true
+		}
+		for i, lhs := range assignStmt.Lhs {
+			ident, ok := lhs.(*gopast.Ident)
+			if !ok || ident.Name != name {
+				continue
+			}
+			typ := r.typeInfo.TypeOf(assignStmt.Rhs[i])
+			if typ == nil || typ == types.Typ[types.Invalid] {
+				if basicLit, ok := assignStmt.Rhs[i].(*gopast.BasicLit); ok {
+					typ = basicLitDefaultType(basicLit)
+				}
+			}
+			if typ == nil || typ == types.Typ[types.Invalid] {
+				continue
+			}
+			varType = getSimplifiedTypeString(types.Default(typ))
+			return false
+		}
+		return true
+	})
+	if varType == "" {
+		varType = "any"
+	}
+
+	edit := r.insertFirstVarBlockFieldEdit(astFile, name+" "+varType)
+	return &WorkspaceEdit{
+		Changes: map[DocumentURI][]TextEdit{
+			r.documentURIs[spxFile]: {edit},
+		},
+	}
+}
+
+// basicLitDefaultType returns the default type of the given basic literal, or
+// nil if it is unknown.
+func basicLitDefaultType(basicLit *gopast.BasicLit) types.Type {
+	switch basicLit.Kind {
+	case goptoken.INT:
+		return types.Typ[types.Int]
+	case goptoken.FLOAT:
+		return types.Typ[types.Float64]
+	case goptoken.IMAG:
+		return types.Typ[types.Complex128]
+	case goptoken.CHAR:
+		return types.Universe.Lookup("rune").Type()
+	case goptoken.STRING:
+		return types.Typ[types.String]
+	}
+	return nil
+}
+
+// insertFirstVarBlockFieldEdit returns a text edit that inserts the given field
+// declaration into the first var block of the given file. A new var block is
+// created if the file does not have one.
+func (r *compileResult) insertFirstVarBlockFieldEdit(astFile *gopast.File, field string) TextEdit {
+	if firstVarBlock := r.firstVarBlocks[astFile]; firstVarBlock != nil && firstVarBlock.Rparen.IsValid() {
+		pos := Position{Line: uint32(r.fset.Position(firstVarBlock.Rparen).Line - 1)}
+		return TextEdit{
+			Range:   Range{Start: pos, End: pos},
+			NewText: "\t" + field + "\n",
+		}
+	}
+
+	var pos Position
+	for _, decl := range astFile.Decls {
+		if genDecl, ok := decl.(*gopast.GenDecl); ok && genDecl.Tok == goptoken.IMPORT {
+			pos = Position{Line: uint32(r.fset.Position(genDecl.End()).Line)}
+			continue
+		}
+		if pos == (Position{}) && decl.Pos().IsValid() {
+			pos = Position{Line: uint32(r.fset.Position(decl.Pos()).Line - 1)}
+		}
+		break
+	}
+	return TextEdit{
+		Range:   Range{Start: pos, End: pos},
+		NewText: "var (\n\t" + field + "\n)\n",
+	}
+}
+
+// removeUnusedImportEdit returns a workspace edit that removes the import of
+// the given package path from the given file.
+func (r *compileResult) removeUnusedImportEdit(spxFile string, astFile *gopast.File, pkgPath string) *WorkspaceEdit {
+	for _, decl := range astFile.Decls {
+		genDecl, ok := decl.(*gopast.GenDecl)
+		if !ok || genDecl.Tok != goptoken.IMPORT {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			importSpec, ok := spec.(*gopast.ImportSpec)
+			if !ok || importSpec.Path == nil || importSpec.Path.Value != fmt.Sprintf("%q", pkgPath) {
+				continue
+			}
+
+			var node gopast.Node = importSpec
+			if !genDecl.Lparen.IsValid() {
+				node = genDecl
+			}
+			return &WorkspaceEdit{
+				Changes: map[DocumentURI][]TextEdit{
+					r.documentURIs[spxFile]: {
+						{
+							Range: Range{
+								Start: Position{Line: uint32(r.fset.Position(node.Pos()).Line - 1)},
+								End:   Position{Line: uint32(r.fset.Position(node.End()).Line)},
+							},
+							NewText: "",
+						},
+					},
+				},
+			}
+		}
+	}
+	return nil
+}
+
+// removeDeclarationEdit returns a workspace edit that removes the top-level
+// declaration of the variable or function with the given name from the given
+// file, including its doc comment. A variable is only removed if it is the
+// only one declared by its spec. It returns nil if there is no such
+// declaration.
+func (r *compileResult) removeDeclarationEdit(spxFile string, astFile *gopast.File, name string) *WorkspaceEdit {
+	var (
+		node gopast.Node
+		doc  *gopast.CommentGroup
+	)
+	for _, decl := range astFile.Decls {
+		switch decl := decl.(type) {
+		case *gopast.GenDecl:
+			if decl.Tok != goptoken.VAR {
+				continue
+			}
+			for _, spec := range decl.Specs {
+				valueSpec, ok := spec.(*gopast.ValueSpec)
+				if !ok || len(valueSpec.Names) != 1 || valueSpec.Names[0].Name != name {
+					continue
+				}
+				node, doc = valueSpec, valueSpec.Doc
+				if !decl.Lparen.IsValid() || len(decl.Specs) == 1 {
+					node, doc = decl, decl.Doc
+				}
+			}
+		case *gopast.FuncDecl:
+			if !decl.Shadow && decl.IsClass && decl.Name != nil && decl.Name.Name == name {
+				node, doc = decl, decl.Doc
+			}
+		}
+		if node != nil {
+			break
+		}
+	}
+	if node == nil {
+		return nil
+	}
+
+	start := node.Pos()
+	if doc != nil {
+		start = doc.Pos()
+	}
+	return &WorkspaceEdit{
+		Changes: map[DocumentURI][]TextEdit{
+			r.documentURIs[spxFile]: {
+				{
+					Range: Range{
+						Start: Position{Line: uint32(r.fset.Position(start).Line - 1)},
+						End:   Position{Line: uint32(r.fset.Position(node.End()).Line)},
+					},
+					NewText: "",
+				},
+			},
+		},
+	}
+}
+
+// createSpxResourceStubEdit returns a workspace edit that creates a stub of the
+// spx resource with the given URI, i.e., an empty metadata file in the
+// directory of the resource. Only sprites and sounds are supported, as other
+// resources are not stored in their own directories.
+func (r *compileResult) createSpxResourceStubEdit(uri SpxResourceURI) *WorkspaceEdit {
+	id, err := ParseSpxResourceURI(uri)
+	if err != nil {
+		return nil
+	}
+	var metadataFile string
+	switch id := id.(type) {
+	case SpxSpriteResourceID:
+		metadataFile = path.Join(r.spxResourceRootDir, "sprites", id.SpriteName, "index.json")
+	case SpxSoundResourceID:
+		metadataFile = path.Join(r.spxResourceRootDir, "sounds", id.SoundName, "index.json")
+	default:
+		return nil
+	}
+
+	metadataFileURI := r.toDocumentURI(metadataFile)
+	return &WorkspaceEdit{
+		DocumentChanges: []DocumentChange{
+			{
+				CreateFile: &CreateFile{
+					Kind:    string(Create),
+					URI:     metadataFileURI,
+					Options: &CreateFileOptions{IgnoreIfExists: true},
+				},
+			},
+			{
+				TextDocumentEdit: &TextDocumentEdit{
+					TextDocument: OptionalVersionedTextDocumentIdentifier{
+						TextDocumentIdentifier: TextDocumentIdentifier{URI: metadataFileURI},
+					},
+					Edits: []Or_TextDocumentEdit_edits_Elem{
+						{Value: TextEdit{NewText: "{}\n"}},
+					},
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,429 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentCodeAction(t *testing.T) {
+	diagnosticsFor := func(t *testing.T, s *Server, uri DocumentURI) []Diagnostic {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, report)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		return fullReport.Items
+	}
+
+	t.Run("DeclareVar", func(t *testing.T) {
+		fileMap := newTestFileMap()
+		fileMap["main.spx"] = []byte(`
+var (
+	MyAircraft MyAircraft
+	Bullet     Bullet
+)
+score = 1
+run "assets", {Title: "Bullet (by Go+)"}
+`)
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		diags := diagnosticsFor(t, s, "file:///main.spx")
+		require.Len(t, diags, 1)
+		assert.Equal(t, "score is not defined", diags[0].Message)
+
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Context:      CodeActionContext{Diagnostics: diags, Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		require.Len(t, actions, 1)
+		assert.Equal(t, `Declare variable "score"`, actions[0].Title)
+		assert.Equal(t, QuickFix, actions[0].Kind)
+		assert.Equal(t, diags, actions[0].Diagnostics)
+		require.NotNil(t, actions[0].Edit)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///main.spx": {
+				{
+					Range: Range{
+						Start: Position{Line: 4, Character: 0},
+						End:   Position{Line: 4, Character: 0},
+					},
+					NewText: "\tscore int\n",
+				},
+			},
+		}, actions[0].Edit.Changes)
+	})
+
+	t.Run("DeclareVarWithoutVarBlock", func(t *testing.T) {
+		fileMap := newTestFileMap()
+		fileMap["MyAircraft.spx"] = []byte(`
+onStart => {
+	count = "hello"
+}
+`)
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		diags := diagnosticsFor(t, s, "file:///MyAircraft.spx")
+		require.Len(t, diags, 1)
+
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MyAircraft.spx"},
+			Context:      CodeActionContext{Diagnostics: diags, Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		require.Len(t, actions, 1)
+		require.NotNil(t, actions[0].Edit)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///MyAircraft.spx": {
+				{
+					Range: Range{
+						Start: Position{Line: 1, Character: 0},
+						End:   Position{Line: 1, Character: 0},
+					},
+					NewText: "var (\n\tcount string\n)\n",
+				},
+			},
+		}, actions[0].Edit.Changes)
+	})
+
+	t.Run("AddSpriteAutoBinding", func(t *testing.T) {
+		fileMap := newTestFileMap()
+		fileMap["MyAircraft.spx"] = []byte(`
+onStart => {
+	Enemy.show
+}
+`)
+		fileMap["assets/sprites/Enemy/index.json"] = []byte(`{"heading":90,"x":0,"y":0,"size":1,"rotationStyle":"normal","costumeIndex":0,"visible":true,"isDraggable":false,"pivot":{"x":0,"y":0},"costumes":[{"x":8,"y":20,"faceRight":90,"bitmapResolution":2,"name":"enemy","path":"enemy.png"}],"fAnimations":{},"animBindings":{}}`)
+		fileMap["assets/sprites/Enemy/enemy.png"] = nil
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		diags := diagnosticsFor(t, s, "file:///MyAircraft.spx")
+		require.Len(t, diags, 1)
+		assert.Equal(t, "Enemy is not defined", diags[0].Message)
+
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MyAircraft.spx"},
+			Context:      CodeActionContext{Diagnostics: diags, Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		require.Len(t, actions, 1)
+		assert.Equal(t, `Add sprite auto-binding var "Enemy" to main.spx`, actions[0].Title)
+		assert.True(t, actions[0].IsPreferred)
+		require.NotNil(t, actions[0].Edit)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///main.spx": {
+				{
+					Range: Range{
+						Start: Position{Line: 4, Character: 0},
+						End:   Position{Line: 4, Character: 0},
+					},
+					NewText: "\tEnemy Sprite\n",
+				},
+			},
+		}, actions[0].Edit.Changes)
+	})
+
+	t.Run("RemoveUnusedImport", func(t *testing.T) {
+		fileMap := newTestFileMap()
+		fileMap["main.spx"] = []byte(`
+import "fmt"
+
+var (
+	MyAircraft MyAircraft
+	Bullet     Bullet
+)
+run "assets", {Title: "Bullet (by Go+)"}
+`)
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		diags := diagnosticsFor(t, s, "file:///main.spx")
+		require.Len(t, diags, 1)
+		assert.Equal(t, SeverityWarning, diags[0].Severity)
+		assert.Equal(t, `"fmt" imported and not used`, diags[0].Message)
+
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Context:      CodeActionContext{Diagnostics: diags, Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		require.Len(t, actions, 2)
+		assert.Equal(t, `Remove unused import "fmt"`, actions[0].Title)
+		require.NotNil(t, actions[0].Edit)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///main.spx": {
+				{
+					Range: Range{
+						Start: Position{Line: 1, Character: 0},
+						End:   Position{Line: 2, Character: 0},
+					},
+				},
+			},
+		}, actions[0].Edit.Changes)
+		assert.Equal(t, "Ignore spx0008 on this line", actions[1].Title)
+	})
+
+	t.Run("RemoveDeclaration", func(t *testing.T) {
+		fileMap := newTestFileMap()
+		fileMap["MyAircraft.spx"] = []byte(`
+var (
+	speed int
+	count int
+)
+
+// jump makes the aircraft jump.
+func jump() {
+	echo count
+}
+`)
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		diags := diagnosticsFor(t, s, "file:///MyAircraft.spx")
+		require.Len(t, diags, 2)
+		assert.Equal(t, `variable "speed" is unused`, diags[0].Message)
+		assert.Equal(t, `function "jump" is unused`, diags[1].Message)
+
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MyAircraft.spx"},
+			Context:      CodeActionContext{Diagnostics: diags, Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		require.Len(t, actions, 4)
+		assert.Equal(t, `Remove unused declaration "speed"`, actions[0].Title)
+		require.NotNil(t, actions[0].Edit)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///MyAircraft.spx": {
+				{
+					Range: Range{
+						Start: Position{Line: 2, Character: 0},
+						End:   Position{Line: 3, Character: 0},
+					},
+				},
+			},
+		}, actions[0].Edit.Changes)
+		assert.Equal(t, `Remove unused declaration "jump"`, actions[1].Title)
+		require.NotNil(t, actions[1].Edit)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///MyAircraft.spx": {
+				{
+					Range: Range{
+						Start: Position{Line: 6, Character: 0},
+						End:   Position{Line: 10, Character: 0},
+					},
+				},
+			},
+		}, actions[1].Edit.Changes)
+		assert.Equal(t, "Ignore spx0012 on this line", actions[2].Title)
+		assert.Equal(t, "Ignore spx0012 on this line", actions[3].Title)
+	})
+
+	t.Run("UndefinedIdentSuggestion", func(t *testing.T) {
+		fileMap := newTestFileMap()
+		fileMap["MyAircraft.spx"] = []byte(`
+onStart => {
+	count := 1
+	trun 90
+	echo cuont
+}
+`)
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		diags := diagnosticsFor(t, s, "file:///MyAircraft.spx")
+		require.Len(t, diags, 2)
+		assert.Equal(t, "trun is not defined", diags[0].Message)
+		assert.Equal(t, Range{
+			Start: Position{Line: 3, Character: 1},
+			End:   Position{Line: 3, Character: 5},
+		}, diags[0].Range)
+		assert.Equal(t, "cuont is not defined", diags[1].Message)
+
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MyAircraft.spx"},
+			Context:      CodeActionContext{Diagnostics: diags, Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		require.Len(t, actions, 4)
+		assert.Equal(t, "Change to turn", actions[0].Title)
+		assert.True(t, actions[0].IsPreferred)
+		require.NotNil(t, actions[0].Edit)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///MyAircraft.spx": {
+				{
+					Range: Range{
+						Start: Position{Line: 3, Character: 1},
+						End:   Position{Line: 3, Character: 5},
+					},
+					NewText: "turn",
+				},
+			},
+		}, actions[0].Edit.Changes)
+		assert.Equal(t, `Declare variable "trun"`, actions[1].Title)
+		assert.Equal(t, "Change to count", actions[2].Title)
+		assert.Equal(t, `Declare variable "cuont"`, actions[3].Title)
+	})
+
+	t.Run("ResourceNotFound", func(t *testing.T) {
+		fileMap := newTestFileMap()
+		fileMap["MyAircraft.spx"] = []byte(`
+onStart => {
+	play "bui"
+}
+`)
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		diags := diagnosticsFor(t, s, "file:///MyAircraft.spx")
+		require.Len(t, diags, 1)
+		assert.Equal(t, DiagnosticCodeResourceNotFound, diags[0].Code)
+
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MyAircraft.spx"},
+			Context:      CodeActionContext{Diagnostics: diags, Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		require.Len(t, actions, 2)
+
+		assert.Equal(t, `Change to "biu"`, actions[0].Title)
+		assert.True(t, actions[0].IsPreferred)
+		require.NotNil(t, actions[0].Edit)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///MyAircraft.spx": {
+				{
+					Range: Range{
+						Start: Position{Line: 2, Character: 6},
+						End:   Position{Line: 2, Character: 11},
+					},
+					NewText: `"biu"`,
+				},
+			},
+		}, actions[0].Edit.Changes)
+
+		assert.Equal(t, `Create sound "bui"`, actions[1].Title)
+		assert.False(t, actions[1].IsPreferred)
+		require.NotNil(t, actions[1].Edit)
+		require.Len(t, actions[1].Edit.DocumentChanges, 2)
+		require.NotNil(t, actions[1].Edit.DocumentChanges[0].CreateFile)
+		assert.Equal(t, DocumentURI("file:///assets/sounds/bui/index.json"), actions[1].Edit.DocumentChanges[0].CreateFile.URI)
+		require.NotNil(t, actions[1].Edit.DocumentChanges[1].TextDocumentEdit)
+		assert.Equal(t, DocumentURI("file:///assets/sounds/bui/index.json"), actions[1].Edit.DocumentChanges[1].TextDocumentEdit.TextDocument.URI)
+	})
+
+	t.Run("ResourceNotFoundWithoutSuggestion", func(t *testing.T) {
+		fileMap := newTestFileMap()
+		fileMap["MyAircraft.spx"] = []byte(`
+onStart => {
+	setCostume "villain"
+}
+`)
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		diags := diagnosticsFor(t, s, "file:///MyAircraft.spx")
+		require.Len(t, diags, 1)
+		assert.Equal(t, DiagnosticCodeResourceNotFound, diags[0].Code)
+		assert.Nil(t, diags[0].Data)
+
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MyAircraft.spx"},
+			Context:      CodeActionContext{Diagnostics: diags, Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, actions)
+	})
+
+	t.Run("DiagnosticWithoutData", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Context: CodeActionContext{Diagnostics: []Diagnostic{
+				{Severity: SeverityError, Message: "some error"},
+			}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, actions)
+	})
+
+	t.Run("NonSpxFile", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///notexist.gop"},
+		})
+		require.EqualError(t, err, `file "notexist.gop" does not have .spx extension`)
+		assert.Nil(t, actions)
+	})
+}
+
+func TestServerCodeActionResolve(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		fileMap := newTestFileMap()
+		fileMap["main.spx"] = []byte(`
+import "fmt"
+
+var (
+	MyAircraft MyAircraft
+	Bullet     Bullet
+)
+run "assets", {Title: "Bullet (by Go+)"}
+`)
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		_, err := s.initialize(&InitializeParams{
+			XInitializeParams: XInitializeParams{
+				Capabilities: ClientCapabilities{
+					TextDocument: TextDocumentClientCapabilities{
+						CodeAction: CodeActionClientCapabilities{
+							ResolveSupport: &ClientCodeActionResolveOptions{
+								Properties: []string{"edit"},
+							},
+						},
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		diags := report.Value.(RelatedFullDocumentDiagnosticReport).Items
+		require.Len(t, diags, 1)
+
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Context:      CodeActionContext{Diagnostics: diags, Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		require.Len(t, actions, 2)
+		assert.Equal(t, `Remove unused import "fmt"`, actions[0].Title)
+		assert.Equal(t, QuickFix, actions[0].Kind)
+		assert.Nil(t, actions[0].Edit)
+		require.NotNil(t, actions[0].Data)
+
+		resolved, err := s.codeActionResolve(&actions[0])
+		require.NoError(t, err)
+		require.NotNil(t, resolved)
+		assert.Equal(t, actions[0].Title, resolved.Title)
+		require.NotNil(t, resolved.Edit)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///main.spx": {
+				{
+					Range: Range{
+						Start: Position{Line: 1, Character: 0},
+						End:   Position{Line: 2, Character: 0},
+					},
+				},
+			},
+		}, resolved.Edit.Changes)
+	})
+
+	t.Run("AlreadyResolved", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+		action := &CodeAction{Title: "Some fix", Edit: &WorkspaceEdit{}}
+		resolved, err := s.codeActionResolve(action)
+		require.NoError(t, err)
+		assert.Equal(t, action, resolved)
+	})
+
+	t.Run("InvalidData", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+		data := json.RawMessage(`"invalid"`)
+		resolved, err := s.codeActionResolve(&CodeAction{Title: "Some fix", Data: &data})
+		require.Error(t, err)
+		assert.Nil(t, resolved)
+	})
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+)
+
+// Commands of code lenses. They are handled by the client.
+const (
+	// codeLensCommandRunProject runs the project.
+	codeLensCommandRunProject = "spx.runProject"
+
+	// codeLensCommandShowReferences shows the given locations, with arguments
+	// of the document URI, the position and the locations.
+	codeLensCommandShowReferences = "spx.showReferences"
+)
+
+// spxEventHandlerTriggers describes when spx event handlers are triggered.
+// The message event handler is described by the broadcasts triggering it.
+var spxEventHandlerTriggers = map[string]string{
+	"onStart":      "Triggered when the game starts",
+	"onClick":      "Triggered when clicked",
+	"onAnyKey":     "Triggered when any key is pressed",
+	"onKey":        "Triggered when the key is pressed",
+	"onBackdrop":   "Triggered when the backdrop switches",
+	"onCloned":     "Triggered when cloned",
+	"onMoving":     "Triggered while moving",
+	"onTurning":    "Triggered while turning",
+	"onTouchStart": "Triggered when touching starts",
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_codeLens
+func (s *Server) textDocumentCodeLens(params *CodeLensParams) ([]CodeLens, error) {
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	lenses := []CodeLens{}
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		switch node := node.(type) {
+		case *gopast.CallExpr:
+			funcIdent, ok := node.Fun.(*gopast.Ident)
+			if !ok {
+				return true
+			}
+			if spxFile == result.mainSpxFile && funcIdent.Name == "run" && isSpxPkgObject(result.typeInfo.ObjectOf(funcIdent)) {
+				lenses = append(lenses, CodeLens{
+					Range: result.rangeForNode(funcIdent),
+					Command: &Command{
+						Title:   "▶ Run project",
+						Command: codeLensCommandRunProject,
+					},
+				})
+				return true
+			}
+			if result.spxEventHandlerCallExprFor(astFile, funcIdent) != node {
+				return true
+			}
+			if lens, ok := result.spxEventHandlerCodeLens(params.TextDocument.URI, node); ok {
+				lenses = append(lenses, lens)
+			}
+		case *gopast.FuncDecl:
+			if node.Shadow {
+				return true
+			}
+			if _, ok := result.typeInfo.Defs[node.Name].(*types.Func); !ok {
+				return true
+			}
+			nameRange := result.rangeForNode(node.Name)
+			lenses = append(lenses, CodeLens{
+				Range: nameRange,
+				Data: CodeLensData{
+					Kind:     CodeLensDataKindReferences,
+					URI:      params.TextDocument.URI,
+					Position: nameRange.Start,
+				},
+			})
+		}
+		return true
+	})
+	return lenses, nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#codeLens_resolve
+func (s *Server) codeLensResolve(params *CodeLens) (*CodeLens, error) {
+	if params.Command != nil {
+		return params, nil
+	}
+	data, ok := codeLensDataOf(params)
+	if !ok {
+		return params, nil
+	}
+
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(data.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return params, nil
+	}
+
+	var (
+		locations []Location
+		title     string
+	)
+	switch data.Kind {
+	case CodeLensDataKindReferences:
+		ident := result.identAtASTFilePosition(astFile, result.toPosition(astFile, data.Position))
+		fun, ok := result.typeInfo.ObjectOf(ident).(*types.Func)
+		if !ok {
+			return params, nil
+		}
+		for _, refIdent := range result.refIdentsFor(fun) {
+			locations = append(locations, result.locationForNode(refIdent))
+		}
+		title = pluralize(len(locations), "reference", "references")
+	case CodeLensDataKindBroadcast:
+		for _, callExpr := range result.spxBroadcastCallExprs(data.Message) {
+			locations = append(locations, result.locationForNode(callExpr))
+		}
+		if len(locations) == 0 {
+			title = fmt.Sprintf("Never triggered by broadcast %q", data.Message)
+		} else {
+			title = fmt.Sprintf("Triggered by %s %q", pluralize(len(locations), "broadcast", "broadcasts"), data.Message)
+		}
+	default:
+		return params, nil
+	}
+	slices.SortFunc(locations, func(a, b Location) int {
+		if c := cmp.Compare(a.URI, b.URI); c != 0 {
+			return c
+		}
+		return comparePositions(a.Range.Start, b.Range.Start)
+	})
+
+	lens := *params
+	lens.Command = &Command{
+		Title:   title,
+		Command: codeLensCommandShowReferences,
+		Arguments: []json.RawMessage{
+			*makeRawMessage(data.URI),
+			*makeRawMessage(data.Position),
+			*makeRawMessage(locations),
+		},
+	}
+	return &lens, nil
+}
+
+// codeLensDataOf returns the data of the given code lens. The data is decoded
+// from JSON if the code lens comes from the client.
+func codeLensDataOf(lens *CodeLens) (*CodeLensData, bool) {
+	switch data := lens.Data.(type) {
+	case nil:
+		return nil, false
+	case CodeLensData:
+		return &data, true
+	case *CodeLensData:
+		return data, true
+	}
+	raw, err := json.Marshal(lens.Data)
+	if err != nil {
+		return nil, false
+	}
+	var data CodeLensData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, false
+	}
+	return &data, true
+}
+
+// spxEventHandlerCodeLens returns the code lens for the spx event handler
+// registered by the given call expression. The lens of a message event handler
+// is resolved lazily by counting the broadcasts of the message.
+func (r *compileResult) spxEventHandlerCodeLens(uri DocumentURI, callExpr *gopast.CallExpr) (CodeLens, bool) {
+	funcIdent := callExpr.Fun.(*gopast.Ident)
+	lensRange := r.rangeForNode(funcIdent)
+	if funcIdent.Name != "onMsg" {
+		trigger, ok := spxEventHandlerTriggers[funcIdent.Name]
+		if !ok {
+			return CodeLens{}, false
+		}
+		return CodeLens{
+			Range:   lensRange,
+			Command: &Command{Title: trigger},
+		}, true
+	}
+
+	msg, ok := r.spxMessageArg(callExpr)
+	if !ok {
+		return CodeLens{
+			Range:   lensRange,
+			Command: &Command{Title: "Triggered by any broadcast"},
+		}, true
+	}
+	return CodeLens{
+		Range: lensRange,
+		Data: CodeLensData{
+			Kind:     CodeLensDataKindBroadcast,
+			URI:      uri,
+			Position: lensRange.Start,
+			Message:  msg,
+		},
+	}, true
+}
+
+// spxBroadcastCallExprs returns all calls in the main package that broadcast
+// the given message.
+func (r *compileResult) spxBroadcastCallExprs(msg string) []*gopast.CallExpr {
+	var callExprs []*gopast.CallExpr
+	for _, astFile := range r.mainASTPkg.Files {
+		gopast.Inspect(astFile, func(node gopast.Node) bool {
+			callExpr, ok := node.(*gopast.CallExpr)
+			if !ok {
+				return true
+			}
+			funcIdent := funcIdentOf(callExpr.Fun)
+			if funcIdent == nil || funcIdent.Name != "broadcast" || !isSpxPkgObject(r.typeInfo.ObjectOf(funcIdent)) {
+				return true
+			}
+			if m, ok := r.spxMessageArg(callExpr); ok && m == msg {
+				callExprs = append(callExprs, callExpr)
+			}
+			return true
+		})
+	}
+	return callExprs
+}
+
+// spxMessageArg returns the message of the given broadcast or message event
+// handler call, which is its first argument of string constant.
+func (r *compileResult) spxMessageArg(callExpr *gopast.CallExpr) (string, bool) {
+	if len(callExpr.Args) == 0 {
+		return "", false
+	}
+	arg := callExpr.Args[0]
+	tv, ok := r.typeInfo.Types[arg]
+	if !ok || tv.Value == nil || !types.AssignableTo(tv.Type, types.Typ[types.String]) {
+		return "", false
+	}
+	return getStringLitOrConstValue(arg, tv)
+}
+
+// pluralize returns the count with the singular or plural form of a noun,
+// e.g., "1 reference" and "2 references".
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, singular)
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
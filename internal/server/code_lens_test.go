@@ -0,0 +1,172 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentCodeLens(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+func greet() {
+	broadcast "hello"
+}
+
+onStart => {
+	greet
+}
+onMsg "hello", => {}
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		lenses, err := s.textDocumentCodeLens(&CodeLensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		require.Len(t, lenses, 4)
+		assert.Contains(t, lenses, CodeLens{
+			Range: Range{
+				Start: Position{Line: 1, Character: 5},
+				End:   Position{Line: 1, Character: 10},
+			},
+			Data: CodeLensData{
+				Kind:     CodeLensDataKindReferences,
+				URI:      "file:///main.spx",
+				Position: Position{Line: 1, Character: 5},
+			},
+		})
+		assert.Contains(t, lenses, CodeLens{
+			Range: Range{
+				Start: Position{Line: 5, Character: 0},
+				End:   Position{Line: 5, Character: 7},
+			},
+			Command: &Command{Title: "Triggered when the game starts"},
+		})
+		assert.Contains(t, lenses, CodeLens{
+			Range: Range{
+				Start: Position{Line: 8, Character: 0},
+				End:   Position{Line: 8, Character: 5},
+			},
+			Data: CodeLensData{
+				Kind:     CodeLensDataKindBroadcast,
+				URI:      "file:///main.spx",
+				Position: Position{Line: 8, Character: 0},
+				Message:  "hello",
+			},
+		})
+		assert.Contains(t, lenses, CodeLens{
+			Range: Range{
+				Start: Position{Line: 9, Character: 0},
+				End:   Position{Line: 9, Character: 3},
+			},
+			Command: &Command{
+				Title:   "▶ Run project",
+				Command: codeLensCommandRunProject,
+			},
+		})
+	})
+
+	t.Run("NonMainSpxFile", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onClick => {}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		lenses, err := s.textDocumentCodeLens(&CodeLensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []CodeLens{
+			{
+				Range: Range{
+					Start: Position{Line: 1, Character: 0},
+					End:   Position{Line: 1, Character: 7},
+				},
+				Command: &Command{Title: "Triggered when clicked"},
+			},
+		}, lenses)
+	})
+}
+
+func TestServerCodeLensResolve(t *testing.T) {
+	s := New(newMapFSWithoutModTime(map[string][]byte{
+		"main.spx": []byte(`
+func greet() {
+	broadcast "hello"
+}
+
+onStart => {
+	greet
+	greet
+}
+onMsg "hello", => {}
+onMsg "bye", => {}
+run "assets", {Title: "My Game"}
+`),
+		"assets/index.json": []byte(`{}`),
+	}), nil)
+
+	t.Run("References", func(t *testing.T) {
+		lens, err := s.codeLensResolve(&CodeLens{
+			Data: map[string]any{
+				"kind":     "references",
+				"uri":      "file:///main.spx",
+				"position": map[string]any{"line": 1, "character": 5},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, lens)
+		require.NotNil(t, lens.Command)
+		assert.Equal(t, "2 references", lens.Command.Title)
+		assert.Equal(t, codeLensCommandShowReferences, lens.Command.Command)
+		assert.Len(t, lens.Command.Arguments, 3)
+	})
+
+	t.Run("Broadcast", func(t *testing.T) {
+		lens, err := s.codeLensResolve(&CodeLens{
+			Data: CodeLensData{
+				Kind:     CodeLensDataKindBroadcast,
+				URI:      "file:///main.spx",
+				Position: Position{Line: 9, Character: 0},
+				Message:  "hello",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, lens)
+		require.NotNil(t, lens.Command)
+		assert.Equal(t, `Triggered by 1 broadcast "hello"`, lens.Command.Title)
+	})
+
+	t.Run("NeverBroadcast", func(t *testing.T) {
+		lens, err := s.codeLensResolve(&CodeLens{
+			Data: CodeLensData{
+				Kind:     CodeLensDataKindBroadcast,
+				URI:      "file:///main.spx",
+				Position: Position{Line: 10, Character: 0},
+				Message:  "bye",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, lens)
+		require.NotNil(t, lens.Command)
+		assert.Equal(t, `Never triggered by broadcast "bye"`, lens.Command.Title)
+	})
+
+	t.Run("WithCommand", func(t *testing.T) {
+		params := &CodeLens{Command: &Command{Title: "Triggered when clicked"}}
+		lens, err := s.codeLensResolve(params)
+		require.NoError(t, err)
+		assert.Equal(t, params, lens)
+	})
+}
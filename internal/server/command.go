@@ -1,41 +1,235 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"go/types"
+	"maps"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/goplus/goxlsw/internal/util"
 )
 
-// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#workspace_executeCommand
-func (s *Server) workspaceExecuteCommand(params *ExecuteCommandParams) (any, error) {
-	switch params.Command {
-	case "spx.renameResources":
-		var cmdParams []SpxRenameResourceParams
-		for _, arg := range params.Arguments {
-			var cmdParam SpxRenameResourceParams
-			if err := json.Unmarshal(arg, &cmdParam); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal command argument as SpxRenameResourceParams: %w", err)
-			}
-			cmdParams = append(cmdParams, cmdParam)
-		}
-		return s.spxRenameResources(cmdParams)
-	case "spx.getDefinitions":
-		var cmdParams []SpxGetDefinitionsParams
-		for _, arg := range params.Arguments {
-			var cmdParam SpxGetDefinitionsParams
-			if err := json.Unmarshal(arg, &cmdParam); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal command argument as SpxGetDefinitionsParams: %w", err)
+// commandHandler handles a workspace/executeCommand request with the raw
+// arguments of the command.
+type commandHandler func(ctx context.Context, s *Server, args []json.RawMessage) (any, error)
+
+// commandMiddleware wraps the handler of the command with the given name, e.g.,
+// for logging, metrics, and cancellation.
+type commandMiddleware func(name string, next commandHandler) commandHandler
+
+// commandRegistry is a registry of commands executable by
+// workspace/executeCommand.
+type commandRegistry struct {
+	names    []string
+	handlers map[string]commandHandler
+}
+
+// newCommandRegistry creates a new [commandRegistry].
+func newCommandRegistry() *commandRegistry {
+	return &commandRegistry{handlers: make(map[string]commandHandler)}
+}
+
+// register registers the handler of the command with the given name. It panics
+// if the command is already registered.
+func (r *commandRegistry) register(name string, handler commandHandler) {
+	if _, ok := r.handlers[name]; ok {
+		panic(fmt.Sprintf("command %q already registered", name))
+	}
+	r.names = append(r.names, name)
+	r.handlers[name] = handler
+}
+
+// commandNames returns the names of all registered commands in registration
+// order, which are advertised in the initialize response.
+func (r *commandRegistry) commandNames() []string {
+	return slices.Clone(r.names)
+}
+
+// typedCommandHandler makes a [commandHandler] that decodes each argument of
+// the command as P before calling fn.
+func typedCommandHandler[P, R any](fn func(s *Server, params []P) (R, error)) commandHandler {
+	return contextTypedCommandHandler(func(ctx context.Context, s *Server, params []P) (R, error) {
+		return fn(s, params)
+	})
+}
+
+// contextTypedCommandHandler is like [typedCommandHandler] but passes the
+// context of the request to fn.
+func contextTypedCommandHandler[P, R any](fn func(ctx context.Context, s *Server, params []P) (R, error)) commandHandler {
+	return func(ctx context.Context, s *Server, args []json.RawMessage) (any, error) {
+		params := make([]P, 0, len(args))
+		for _, arg := range args {
+			var param P
+			if err := json.Unmarshal(arg, &param); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal command argument as %T: %w", param, err)
 			}
-			cmdParams = append(cmdParams, cmdParam)
+			params = append(params, param)
+		}
+		return fn(ctx, s, params)
+	}
+}
+
+// spxCommands is the registry of spx commands, along with the custom ones
+// registered by [RegisterCommand].
+var spxCommands = newCommandRegistry()
+
+func init() {
+	spxCommands.register("spx.renameResources", typedCommandHandler((*Server).spxRenameResources))
+	spxCommands.register("spx.getDefinitions", typedCommandHandler((*Server).spxGetDefinitions))
+	spxCommands.register("spx.getDefinitionsPage", typedCommandHandler((*Server).spxGetDefinitionsPage))
+	spxCommands.register("spx.getResourceReferences", typedCommandHandler((*Server).spxGetResourceReferences))
+	spxCommands.register("spx.getResourceUsages", func(ctx context.Context, s *Server, _ []json.RawMessage) (any, error) {
+		return s.spxGetResourceUsages()
+	})
+	spxCommands.register("spx.checkResourceDeletion", typedCommandHandler((*Server).spxCheckResourceDeletion))
+	spxCommands.register("spx.getInputSlots", typedCommandHandler((*Server).spxGetInputSlots))
+	spxCommands.register("spx.getDiagnosticsSummary", func(ctx context.Context, s *Server, _ []json.RawMessage) (any, error) {
+		return s.spxGetDiagnosticsSummary(ctx)
+	})
+	spxCommands.register("spx.exportDiagnostics", func(ctx context.Context, s *Server, _ []json.RawMessage) (any, error) {
+		return s.spxExportDiagnostics(ctx)
+	})
+	spxCommands.register("spx.getMessageGraph", func(ctx context.Context, s *Server, _ []json.RawMessage) (any, error) {
+		return s.spxGetMessageGraph()
+	})
+	spxCommands.register("spx.getProjectStats", func(ctx context.Context, s *Server, _ []json.RawMessage) (any, error) {
+		return s.spxGetProjectStats()
+	})
+	spxCommands.register("spx.getUnusedResources", func(ctx context.Context, s *Server, _ []json.RawMessage) (any, error) {
+		return s.spxGetUnusedResources()
+	})
+	spxCommands.register("spx.getMemoryStats", func(ctx context.Context, s *Server, _ []json.RawMessage) (any, error) {
+		return s.spxGetMemoryStats()
+	})
+	spxCommands.register("spx.prewarm", func(ctx context.Context, s *Server, _ []json.RawMessage) (any, error) {
+		return nil, s.Prewarm(ctx)
+	})
+	spxCommands.register("spx.applyRenameResources", contextTypedCommandHandler(func(ctx context.Context, s *Server, params []SpxRenameResourceParams) (*ApplyWorkspaceEditResult, error) {
+		return s.spxApplyRenameResources(ctx, params)
+	}))
+}
+
+// CommandHandler handles a custom command registered by [RegisterCommand],
+// given the server executing it and the raw arguments of the command.
+type CommandHandler func(ctx context.Context, s *Server, args []json.RawMessage) (any, error)
+
+// RegisterCommand registers the handler of a custom command with the given
+// name, which lets embedders add their own commands executable by
+// workspace/executeCommand and advertised in the initialize response. Names
+// with the "spx." prefix are reserved for the predefined commands.
+//
+// It must be called before any server is created, e.g., in an init function.
+// It panics if the name is reserved or already registered.
+func RegisterCommand(name string, handler CommandHandler) {
+	if strings.HasPrefix(name, "spx.") {
+		panic(fmt.Sprintf("command %q uses the reserved prefix \"spx.\"", name))
+	}
+	spxCommands.register(name, commandHandler(handler))
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#workspace_executeCommand
+func (s *Server) workspaceExecuteCommand(ctx context.Context, params *ExecuteCommandParams) (any, error) {
+	handler, ok := spxCommands.handlers[params.Command]
+	if !ok {
+		return nil, fmt.Errorf("unknown command: %s", params.Command)
+	}
+	middlewares := s.commandMiddlewares()
+	for _, middleware := range slices.Backward(middlewares) {
+		handler = middleware(params.Command, handler)
+	}
+	return handler(ctx, s, params.Arguments)
+}
+
+// commandMiddlewares returns the middlewares wrapping command handlers.
+// Middlewares earlier in the list run first.
+func (s *Server) commandMiddlewares() []commandMiddleware {
+	return []commandMiddleware{
+		cancellationCommandMiddleware,
+		loggingCommandMiddleware,
+		s.commandMetrics.middleware,
+	}
+}
+
+// cancellationCommandMiddleware is a [commandMiddleware] that skips commands
+// whose requests are already canceled.
+func cancellationCommandMiddleware(name string, next commandHandler) commandHandler {
+	return func(ctx context.Context, s *Server, args []json.RawMessage) (any, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("command %s canceled: %w", name, err)
 		}
-		return s.spxGetDefinitions(cmdParams)
+		return next(ctx, s, args)
 	}
-	return nil, fmt.Errorf("unknown command: %s", params.Command)
+}
+
+// loggingCommandMiddleware is a [commandMiddleware] that logs the execution
+// of each command with the logger of the request.
+func loggingCommandMiddleware(name string, next commandHandler) commandHandler {
+	return func(ctx context.Context, s *Server, args []json.RawMessage) (any, error) {
+		logger := s.loggerFor(ctx).With("command", name)
+		start := time.Now()
+		result, err := next(ctx, s, args)
+		duration := time.Since(start)
+		if err != nil {
+			logger.Debug("command failed", "error", err, "duration", duration)
+		} else {
+			logger.Debug("executed command", "duration", duration)
+		}
+		return result, err
+	}
+}
+
+// commandMetrics collects execution metrics of commands.
+type commandMetrics struct {
+	mu    sync.Mutex
+	stats map[string]commandStats
+}
+
+// commandStats is the execution statistics of a command.
+type commandStats struct {
+	Calls    int
+	Errors   int
+	Duration time.Duration
+}
+
+// middleware is a [commandMiddleware] that records the execution statistics of
+// each command.
+func (m *commandMetrics) middleware(name string, next commandHandler) commandHandler {
+	return func(ctx context.Context, s *Server, args []json.RawMessage) (any, error) {
+		start := time.Now()
+		result, err := next(ctx, s, args)
+		m.record(name, time.Since(start), err)
+		return result, err
+	}
+}
+
+// record records an execution of the command with the given name.
+func (m *commandMetrics) record(name string, duration time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.stats == nil {
+		m.stats = make(map[string]commandStats)
+	}
+	stats := m.stats[name]
+	stats.Calls++
+	if err != nil {
+		stats.Errors++
+	}
+	stats.Duration += duration
+	m.stats[name] = stats
+}
+
+// snapshot returns a copy of the execution statistics of all commands.
+func (m *commandMetrics) snapshot() map[string]commandStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(m.stats)
 }
 
 // spxRenameResources renames spx resources in the workspace.
@@ -44,16 +238,28 @@ func (s *Server) spxRenameResources(params []SpxRenameResourceParams) (*Workspac
 	if err != nil {
 		return nil, err
 	}
-	return s.spxRenameResourcesWithCompileResult(result, params)
+	return s.spxRenameResourcesWithCompileResult(result, params, nil)
 }
 
 // spxRenameResourcesWithCompileResult renames spx resources in the workspace with the given compile result.
-func (s *Server) spxRenameResourcesWithCompileResult(result *compileResult, params []SpxRenameResourceParams) (*WorkspaceEdit, error) {
+//
+// The progress is reported via a work done progress of the given token, or a
+// server-initiated one if the token is nil.
+func (s *Server) spxRenameResourcesWithCompileResult(result *compileResult, params []SpxRenameResourceParams, progressToken ProgressToken) (*WorkspaceEdit, error) {
+	progress := s.beginWorkDoneProgress(progressToken, "Renaming resources")
+	defer progress.end("")
+
 	workspaceEdit := WorkspaceEdit{
 		Changes: make(map[DocumentURI][]TextEdit),
 	}
 	seenTextEdits := make(map[DocumentURI]map[TextEdit]struct{})
-	for _, param := range params {
+	var (
+		renameFiles bool
+		fileRenames []RenameFile
+	)
+	for i, param := range params {
+		progress.report(fmt.Sprintf("Renaming %s (%d/%d)", param.Resource.URI, i+1, len(params)), uint32(i*100/len(params)))
+
 		id, err := ParseSpxResourceURI(param.Resource.URI)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse spx resource URI: %w", err)
@@ -91,18 +297,201 @@ func (s *Server) spxRenameResourcesWithCompileResult(result *compileResult, para
 				workspaceEdit.Changes[documentURI] = append(workspaceEdit.Changes[documentURI], textEdit)
 			}
 		}
+		if param.RenameFiles {
+			renameFiles = true
+			fileRenames = append(fileRenames, s.spxRenameResourceFiles(result, id, param.NewName)...)
+		}
+	}
+	if !renameFiles {
+		return &workspaceEdit, nil
+	}
+	return documentChangesWorkspaceEdit(workspaceEdit.Changes, fileRenames), nil
+}
+
+// documentChangesWorkspaceEdit returns a [WorkspaceEdit] that applies the given
+// text edits and then the given file renames as [WorkspaceEdit.DocumentChanges].
+// Text edits come first as they address documents by their URIs before being
+// renamed.
+func documentChangesWorkspaceEdit(changes map[DocumentURI][]TextEdit, fileRenames []RenameFile) *WorkspaceEdit {
+	documentChanges := make([]DocumentChange, 0, len(changes)+len(fileRenames))
+	for _, documentURI := range slices.Sorted(maps.Keys(changes)) {
+		edits := make([]Or_TextDocumentEdit_edits_Elem, 0, len(changes[documentURI]))
+		for _, textEdit := range changes[documentURI] {
+			edits = append(edits, Or_TextDocumentEdit_edits_Elem{Value: textEdit})
+		}
+		documentChanges = append(documentChanges, DocumentChange{
+			TextDocumentEdit: &TextDocumentEdit{
+				TextDocument: OptionalVersionedTextDocumentIdentifier{
+					TextDocumentIdentifier: TextDocumentIdentifier{URI: documentURI},
+				},
+				Edits: edits,
+			},
+		})
+	}
+	for _, fileRename := range fileRenames {
+		documentChanges = append(documentChanges, DocumentChange{RenameFile: &fileRename})
+	}
+	return &WorkspaceEdit{DocumentChanges: documentChanges}
+}
+
+// spxApplyRenameResources renames spx resources in the workspace like
+// [Server.spxRenameResources], but pushes the edit to the client via
+// workspace/applyEdit instead of returning it.
+func (s *Server) spxApplyRenameResources(ctx context.Context, params []SpxRenameResourceParams) (*ApplyWorkspaceEditResult, error) {
+	edit, err := s.spxRenameResources(params)
+	if err != nil {
+		return nil, err
+	}
+	if edit == nil || (len(edit.Changes) == 0 && len(edit.DocumentChanges) == 0) {
+		return &ApplyWorkspaceEditResult{Applied: true}, nil
+	}
+	return s.workspaceApplyEdit(ctx, "Rename resources", *edit)
+}
+
+// workspaceApplyEdit asks the client to apply the given edit and returns the
+// result. It returns an error if the client does not support
+// workspace/applyEdit or fails to apply the edit.
+//
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#workspace_applyEdit
+func (s *Server) workspaceApplyEdit(ctx context.Context, label string, edit WorkspaceEdit) (*ApplyWorkspaceEditResult, error) {
+	if caps := s.clientCapabilities.Load(); caps == nil || !caps.Workspace.ApplyEdit {
+		return nil, errors.New("client does not support workspace/applyEdit")
+	}
+
+	var result ApplyWorkspaceEditResult
+	if err := s.call(ctx, "workspace/applyEdit", &ApplyWorkspaceEditParams{
+		Label: label,
+		Edit:  edit,
+	}, &result); err != nil {
+		return nil, err
+	}
+	if !result.Applied {
+		if result.FailureReason != "" {
+			return &result, fmt.Errorf("client failed to apply edit: %s", result.FailureReason)
+		}
+		return &result, errors.New("client failed to apply edit")
+	}
+	return &result, nil
+}
+
+// spxGetDefinitionsPage gets spx definitions like [Server.spxGetDefinitions],
+// but returns them page by page, and skips them if they are unchanged since a
+// previous result.
+func (s *Server) spxGetDefinitionsPage(params []SpxGetDefinitionsPageParams) (*SpxDefinitionsPage, error) {
+	if l := len(params); l == 0 {
+		return nil, nil
+	} else if l > 1 {
+		return nil, errors.New("spx.getDefinitionsPage only supports one document at a time")
+	}
+	param := params[0]
+	if param.Limit < 0 {
+		return nil, fmt.Errorf("invalid limit %d", param.Limit)
+	}
+
+	defs, err := s.spxGetDefinitions([]SpxGetDefinitionsParams{param.SpxGetDefinitionsParams})
+	if err != nil {
+		return nil, err
+	}
+	resultID := contentResultID(defs)
+
+	var offset int
+	if param.Cursor != "" {
+		cursorResultID, cursorOffset, ok := strings.Cut(param.Cursor, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid cursor %q", param.Cursor)
+		}
+		if offset, err = strconv.Atoi(cursorOffset); err != nil || offset < 0 || offset > len(defs) {
+			return nil, fmt.Errorf("invalid cursor %q", param.Cursor)
+		}
+		if cursorResultID != resultID {
+			return nil, fmt.Errorf("stale cursor %q: definitions have changed", param.Cursor)
+		}
+	} else if param.PreviousResultID != "" && param.PreviousResultID == resultID {
+		return &SpxDefinitionsPage{
+			Kind:     SpxDefinitionsPageUnchanged,
+			ResultID: resultID,
+		}, nil
+	}
+
+	page := &SpxDefinitionsPage{
+		Kind:     SpxDefinitionsPageFull,
+		ResultID: resultID,
+	}
+	end := len(defs)
+	if param.Limit > 0 && offset+param.Limit < end {
+		end = offset + param.Limit
+		page.NextCursor = resultID + ":" + strconv.Itoa(end)
+	}
+	page.Items = defs[offset:end]
+	return page, nil
+}
+
+// spxGetResourceReferences gets the references to an spx resource in the
+// workspace.
+func (s *Server) spxGetResourceReferences(params []SpxGetResourceReferencesParams) ([]SpxResourceReference, error) {
+	if l := len(params); l == 0 {
+		return nil, nil
+	} else if l > 1 {
+		return nil, errors.New("spx.getResourceReferences only supports one resource at a time")
 	}
-	return &workspaceEdit, nil
+	param := params[0]
+
+	id, err := ParseSpxResourceURI(param.Resource.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse spx resource URI: %w", err)
+	}
+	result, err := s.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return result.spxResourceReferences(result.spxResourceRefsFor(id)), nil
+}
+
+// spxCheckResourceDeletion checks whether deleting an spx resource would break
+// code in the workspace. Deleting a sprite also deletes its costumes and
+// animations, so references to them are reported too.
+func (s *Server) spxCheckResourceDeletion(params []SpxCheckResourceDeletionParams) (*SpxResourceDeletionCheck, error) {
+	if l := len(params); l == 0 {
+		return nil, nil
+	} else if l > 1 {
+		return nil, errors.New("spx.checkResourceDeletion only supports one resource at a time")
+	}
+	param := params[0]
+
+	id, err := ParseSpxResourceURI(param.Resource.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse spx resource URI: %w", err)
+	}
+	result, err := s.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	references := result.spxResourceReferences(result.spxResourceRefsFunc(func(refID SpxResourceID) bool {
+		return spxResourceContains(id, refID)
+	}))
+	return &SpxResourceDeletionCheck{
+		Safe:       len(references) == 0,
+		References: references,
+	}, nil
 }
 
 // spxGetDefinitions gets spx definitions at a specific position in a document.
-func (s *Server) spxGetDefinitions(params []SpxGetDefinitionsParams) ([]SpxDefinitionIdentifier, error) {
+func (s *Server) spxGetDefinitions(params []SpxGetDefinitionsParams) ([]SpxDefinitionInfo, error) {
 	if l := len(params); l == 0 {
 		return nil, nil
 	} else if l > 1 {
 		return nil, errors.New("spx.getDefinitions only supports one document at a time")
 	}
 	param := params[0]
+	for _, kind := range param.Kinds {
+		switch kind {
+		case SpxDefinitionKindEventHandler, SpxDefinitionKindSpriteMethod, SpxDefinitionKindUser:
+		default:
+			return nil, fmt.Errorf("unknown spx definition kind %q", kind)
+		}
+	}
 
 	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(param.TextDocument.URI)
 	if err != nil {
@@ -124,20 +513,24 @@ func (s *Server) spxGetDefinitions(params []SpxGetDefinitionsParams) ([]SpxDefin
 	}
 	isInSpxEventHandler := result.isInSpxEventHandler(pos)
 
-	var defIDs []SpxDefinitionIdentifier
+	var defInfos []SpxDefinitionInfo
 	seenDefIDs := make(map[string]struct{})
-	addDefID := func(defID SpxDefinitionIdentifier) {
-		if _, ok := seenDefIDs[defID.String()]; ok {
+	addDef := func(def SpxDefinition) {
+		if _, ok := seenDefIDs[def.ID.String()]; ok {
 			return
 		}
-		seenDefIDs[defID.String()] = struct{}{}
-		defIDs = append(defIDs, defID)
-	}
-	addDefs := func(defs ...SpxDefinition) {
-		defIDs = slices.Grow(defIDs, len(defs))
-		for _, def := range defs {
-			addDefID(def.ID)
+		seenDefIDs[def.ID.String()] = struct{}{}
+		if len(param.Kinds) > 0 && !slices.ContainsFunc(param.Kinds, func(kind SpxDefinitionKind) bool {
+			return result.spxDefinitionIsOfKind(def.ID, kind)
+		}) {
+			return
+		}
+		defInfo := SpxDefinitionInfo{SpxDefinitionIdentifier: def.ID}
+		if param.IncludeDocumentation {
+			defInfo.Overview = def.Overview
+			defInfo.Detail = def.Detail
 		}
+		defInfos = append(defInfos, defInfo)
 	}
 
 	// Add local definitions from innermost scope and its parents.
@@ -148,13 +541,20 @@ func (s *Server) spxGetDefinitions(params []SpxGetDefinitionsParams) ([]SpxDefin
 			if obj == nil {
 				continue
 			}
-			addDefID(SpxDefinitionIdentifier{
+			def := SpxDefinition{ID: SpxDefinitionIdentifier{
 				Package: util.ToPtr(obj.Pkg().Name()),
 				Name:    util.ToPtr(obj.Name()),
-			})
+			}}
+			if param.IncludeDocumentation {
+				if objDefs := result.spxDefinitionsFor(obj, ""); len(objDefs) > 0 {
+					def.Overview = objDefs[0].Overview
+					def.Detail = objDefs[0].Detail
+				}
+			}
+			addDef(def)
 
 			isThis := name == "this"
-			isSpxFileMatch := spxFile == name+".spx" || (spxFile == result.mainSpxFile && name == "Game")
+			isSpxFileMatch := spxFile == name+result.classfileKind.Ext || (spxFile == result.mainSpxFile && name == "Game")
 			isMainScopeObj := isInMainScope && isSpxFileMatch
 			if !isThis && !isMainScopeObj {
 				continue
@@ -174,18 +574,12 @@ func (s *Server) spxGetDefinitions(params []SpxGetDefinitionsParams) ([]SpxDefin
 						continue
 					}
 				}
-				addDefID(def.ID)
+				addDef(def)
 			}
 		}
 	}
 
-	// Add other definitions.
-	addDefs(GetSpxPkgDefinitions()...)
-	addDefs(GetBuiltinSpxDefinitions()...)
-	addDefs(GeneralSpxDefinitions...)
-	if innermostScope == astFileScope {
-		addDefs(FileScopeSpxDefinitions...)
-	}
-
-	return defIDs, nil
+	// Add other definitions from the shared catalog.
+	defInfos = appendSpxDefinitionCatalogInfos(defInfos, param.Kinds, innermostScope == astFileScope, param.IncludeDocumentation, seenDefIDs)
+	return defInfos, nil
 }
@@ -1,14 +1,403 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
 	"slices"
 	"testing"
 
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
 	"github.com/goplus/goxlsw/internal/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
+func TestServerWorkspaceExecuteCommand(t *testing.T) {
+	newServer := func(opts ...Option) *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx":                       []byte(``),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil, opts...)
+	}
+	getDefinitionsParams := &ExecuteCommandParams{
+		Command: "spx.getDefinitions",
+		Arguments: []json.RawMessage{
+			*makeRawMessage(SpxGetDefinitionsParams{
+				TextDocumentPositionParams: TextDocumentPositionParams{
+					TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+					Position:     Position{Line: 0, Character: 0},
+				},
+			}),
+		},
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer()
+		result, err := s.workspaceExecuteCommand(context.Background(), getDefinitionsParams)
+		require.NoError(t, err)
+		assert.IsType(t, []SpxDefinitionInfo{}, result)
+		assert.NotEmpty(t, result)
+
+		stats := s.commandMetrics.snapshot()
+		assert.Equal(t, 1, stats["spx.getDefinitions"].Calls)
+		assert.Zero(t, stats["spx.getDefinitions"].Errors)
+	})
+
+	t.Run("UnknownCommand", func(t *testing.T) {
+		s := newServer()
+		result, err := s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{Command: "spx.unknown"})
+		require.EqualError(t, err, "unknown command: spx.unknown")
+		assert.Nil(t, result)
+	})
+
+	t.Run("InvalidArgument", func(t *testing.T) {
+		s := newServer()
+		result, err := s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{
+			Command:   "spx.renameResources",
+			Arguments: []json.RawMessage{json.RawMessage(`"foo"`)},
+		})
+		require.ErrorContains(t, err, "failed to unmarshal command argument as server.SpxRenameResourceParams")
+		assert.Nil(t, result)
+
+		stats := s.commandMetrics.snapshot()
+		assert.Equal(t, 1, stats["spx.renameResources"].Calls)
+		assert.Equal(t, 1, stats["spx.renameResources"].Errors)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		s := newServer()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		result, err := s.workspaceExecuteCommand(ctx, getDefinitionsParams)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, result)
+	})
+
+	t.Run("Logging", func(t *testing.T) {
+		var buf bytes.Buffer
+		s := newServer(WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))))
+		_, err := s.workspaceExecuteCommand(context.Background(), getDefinitionsParams)
+		require.NoError(t, err)
+		assert.Contains(t, buf.String(), `msg="executed command" command=spx.getDefinitions duration=`)
+
+		buf.Reset()
+		_, err = s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{
+			Command:   "spx.renameResources",
+			Arguments: []json.RawMessage{json.RawMessage(`"foo"`)},
+		})
+		require.Error(t, err)
+		assert.Contains(t, buf.String(), `msg="command failed" command=spx.renameResources error=`)
+	})
+}
+
+func TestCommandRegistry(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		r := newCommandRegistry()
+		r.register("spx.foo", typedCommandHandler(func(s *Server, params []string) (string, error) {
+			return fmt.Sprint(params), nil
+		}))
+		r.register("spx.bar", typedCommandHandler(func(s *Server, params []int) (int, error) {
+			return len(params), nil
+		}))
+		assert.Equal(t, []string{"spx.foo", "spx.bar"}, r.commandNames())
+
+		result, err := r.handlers["spx.foo"](context.Background(), nil, []json.RawMessage{
+			json.RawMessage(`"a"`),
+			json.RawMessage(`"b"`),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "[a b]", result)
+	})
+
+	t.Run("DuplicateCommand", func(t *testing.T) {
+		r := newCommandRegistry()
+		r.register("spx.foo", typedCommandHandler(func(s *Server, params []string) (string, error) {
+			return "", nil
+		}))
+		assert.Panics(t, func() {
+			r.register("spx.foo", typedCommandHandler(func(s *Server, params []string) (string, error) {
+				return "", nil
+			}))
+		})
+	})
+}
+
+func TestRegisterCommand(t *testing.T) {
+	// Register into a copy of the registry to leave the predefined one intact.
+	origCommands := spxCommands
+	t.Cleanup(func() { spxCommands = origCommands })
+	spxCommands = newCommandRegistry()
+	for _, name := range origCommands.commandNames() {
+		spxCommands.register(name, origCommands.handlers[name])
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		RegisterCommand("builder.echo", func(ctx context.Context, s *Server, args []json.RawMessage) (any, error) {
+			var params []string
+			for _, arg := range args {
+				var param string
+				if err := UnmarshalJSON(arg, &param); err != nil {
+					return nil, err
+				}
+				params = append(params, param)
+			}
+			return params, nil
+		})
+
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		result, err := s.initialize(&InitializeParams{})
+		require.NoError(t, err)
+		assert.Contains(t, result.Capabilities.ExecuteCommandProvider.Commands, "builder.echo")
+
+		echoed, err := s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{
+			Command:   "builder.echo",
+			Arguments: []json.RawMessage{json.RawMessage(`"a"`), json.RawMessage(`"b"`)},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, echoed)
+		assert.Equal(t, 1, s.commandMetrics.snapshot()["builder.echo"].Calls)
+	})
+
+	t.Run("ReservedPrefix", func(t *testing.T) {
+		assert.Panics(t, func() {
+			RegisterCommand("spx.custom", func(ctx context.Context, s *Server, args []json.RawMessage) (any, error) {
+				return nil, nil
+			})
+		})
+	})
+
+	t.Run("DuplicateCommand", func(t *testing.T) {
+		RegisterCommand("builder.dup", func(ctx context.Context, s *Server, args []json.RawMessage) (any, error) {
+			return nil, nil
+		})
+		assert.Panics(t, func() {
+			RegisterCommand("builder.dup", func(ctx context.Context, s *Server, args []json.RawMessage) (any, error) {
+				return nil, nil
+			})
+		})
+	})
+}
+
+func TestServerSpxRenameResources(t *testing.T) {
+	newServer := func() *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+play "Sound1"
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx":                       []byte(``),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sounds/Sound1/index.json":    []byte(`{"path":"sound1.wav"}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer()
+		edit, err := s.spxRenameResources([]SpxRenameResourceParams{
+			{
+				Resource: SpxResourceIdentifier{URI: "spx://resources/sounds/Sound1"},
+				NewName:  "Sound2",
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, edit)
+		assert.Empty(t, edit.DocumentChanges)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///main.spx": {
+				{
+					Range: Range{
+						Start: Position{Line: 4, Character: 6},
+						End:   Position{Line: 4, Character: 12},
+					},
+					NewText: "Sound2",
+				},
+			},
+		}, edit.Changes)
+	})
+
+	t.Run("RenameFiles", func(t *testing.T) {
+		s := newServer()
+		edit, err := s.spxRenameResources([]SpxRenameResourceParams{
+			{
+				Resource:    SpxResourceIdentifier{URI: "spx://resources/sprites/MySprite"},
+				NewName:     "MySprite2",
+				RenameFiles: true,
+			},
+			{
+				Resource:    SpxResourceIdentifier{URI: "spx://resources/sounds/Sound1"},
+				NewName:     "Sound2",
+				RenameFiles: true,
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, edit)
+		assert.Nil(t, edit.Changes)
+		require.Len(t, edit.DocumentChanges, 4)
+
+		textDocumentEdit := edit.DocumentChanges[0].TextDocumentEdit
+		require.NotNil(t, textDocumentEdit)
+		assert.Equal(t, DocumentURI("file:///main.spx"), textDocumentEdit.TextDocument.URI)
+		assert.Nil(t, textDocumentEdit.TextDocument.Version)
+		assert.NotEmpty(t, textDocumentEdit.Edits)
+
+		var fileRenames []RenameFile
+		for _, documentChange := range edit.DocumentChanges[1:] {
+			require.NotNil(t, documentChange.RenameFile)
+			fileRenames = append(fileRenames, *documentChange.RenameFile)
+		}
+		assert.Equal(t, []RenameFile{
+			{Kind: "rename", OldURI: "file:///assets/sprites/MySprite", NewURI: "file:///assets/sprites/MySprite2"},
+			{Kind: "rename", OldURI: "file:///MySprite.spx", NewURI: "file:///MySprite2.spx"},
+			{Kind: "rename", OldURI: "file:///assets/sounds/Sound1", NewURI: "file:///assets/sounds/Sound2"},
+		}, fileRenames)
+
+		data, err := json.Marshal(edit)
+		require.NoError(t, err)
+		assert.Contains(t, string(data), `"textDocument":{"version":null,"uri":"file:///main.spx"}`)
+		assert.Contains(t, string(data), `{"kind":"rename","oldUri":"file:///MySprite.spx","newUri":"file:///MySprite2.spx"}`)
+	})
+
+	t.Run("RenameFilesWithoutFiles", func(t *testing.T) {
+		s := newServer()
+		edit, err := s.spxRenameResources([]SpxRenameResourceParams{
+			{
+				Resource:    SpxResourceIdentifier{URI: "spx://resources/sprites/MySprite/costumes/costume1"},
+				NewName:     "costume2",
+				RenameFiles: true,
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, edit)
+		assert.Nil(t, edit.Changes)
+		assert.Empty(t, edit.DocumentChanges)
+	})
+}
+
+func TestServerSpxApplyRenameResources(t *testing.T) {
+	newServer := func(applyEditResult any) (*Server, *[]ApplyWorkspaceEditParams) {
+		var (
+			s           *Server
+			applyEdits  []ApplyWorkspaceEditParams
+			replyErrors = make(chan error, 1)
+		)
+		replier := messageReplierFunc(func(m jsonrpc2.Message) error {
+			c, ok := m.(*jsonrpc2.Call)
+			if !ok || c.Method() != "workspace/applyEdit" {
+				return nil
+			}
+			var params ApplyWorkspaceEditParams
+			if err := UnmarshalJSON(c.Params(), &params); err != nil {
+				return err
+			}
+			applyEdits = append(applyEdits, params)
+			resp, err := jsonrpc2.NewResponse(c.ID(), applyEditResult, nil)
+			if err != nil {
+				return err
+			}
+			go func() { replyErrors <- s.HandleMessage(resp) }()
+			return nil
+		})
+		s = New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+play "Sound1"
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":               []byte(`{}`),
+			"assets/sounds/Sound1/index.json": []byte(`{"path":"sound1.wav"}`),
+		}), replier)
+		s.clientCapabilities.Store(&ClientCapabilities{
+			Workspace: WorkspaceClientCapabilities{ApplyEdit: true},
+		})
+		return s, &applyEdits
+	}
+	params := []SpxRenameResourceParams{
+		{
+			Resource: SpxResourceIdentifier{URI: "spx://resources/sounds/Sound1"},
+			NewName:  "Sound2",
+		},
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s, applyEdits := newServer(ApplyWorkspaceEditResult{Applied: true})
+		result, err := s.spxApplyRenameResources(context.Background(), params)
+		require.NoError(t, err)
+		assert.Equal(t, &ApplyWorkspaceEditResult{Applied: true}, result)
+		require.Len(t, *applyEdits, 1)
+		assert.Equal(t, "Rename resources", (*applyEdits)[0].Label)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///main.spx": {
+				{
+					Range: Range{
+						Start: Position{Line: 1, Character: 6},
+						End:   Position{Line: 1, Character: 12},
+					},
+					NewText: "Sound2",
+				},
+			},
+		}, (*applyEdits)[0].Edit.Changes)
+	})
+
+	t.Run("NotApplied", func(t *testing.T) {
+		s, _ := newServer(ApplyWorkspaceEditResult{FailureReason: "document changed"})
+		result, err := s.spxApplyRenameResources(context.Background(), params)
+		require.EqualError(t, err, "client failed to apply edit: document changed")
+		require.NotNil(t, result)
+		assert.False(t, result.Applied)
+	})
+
+	t.Run("ApplyEditUnsupported", func(t *testing.T) {
+		s, applyEdits := newServer(ApplyWorkspaceEditResult{Applied: true})
+		s.clientCapabilities.Store(&ClientCapabilities{})
+		result, err := s.spxApplyRenameResources(context.Background(), params)
+		require.EqualError(t, err, "client does not support workspace/applyEdit")
+		assert.Nil(t, result)
+		assert.Empty(t, *applyEdits)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+play "Sound1"
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":               []byte(`{}`),
+			"assets/sounds/Sound1/index.json": []byte(`{"path":"sound1.wav"}`),
+		}), messageReplierFunc(func(m jsonrpc2.Message) error { return nil }))
+		s.clientCapabilities.Store(&ClientCapabilities{
+			Workspace: WorkspaceClientCapabilities{ApplyEdit: true},
+		})
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		result, err := s.spxApplyRenameResources(ctx, params)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, result)
+		assert.Empty(t, s.pendingCalls)
+	})
+}
+
+func TestServerHandleResponse(t *testing.T) {
+	t.Run("UnknownID", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		resp, err := jsonrpc2.NewResponse(jsonrpc2.NewIntID(42), nil, nil)
+		require.NoError(t, err)
+		require.EqualError(t, s.HandleMessage(resp), "no pending call found for response ID: 42")
+	})
+}
+
 func TestServerSpxGetDefinitions(t *testing.T) {
 	t.Run("Normal", func(t *testing.T) {
 		s := New(newMapFSWithoutModTime(map[string][]byte{
@@ -320,14 +709,383 @@ onTouchStart "" => {
 			OverloadID: util.ToPtr("0"),
 		}))
 	})
+
+	t.Run("Kinds", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+func fire() {}
+
+onStart => {
+	fire
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+		getDefs := func(kinds ...SpxDefinitionKind) []SpxDefinitionInfo {
+			defs, err := s.spxGetDefinitions([]SpxGetDefinitionsParams{
+				{
+					TextDocumentPositionParams: TextDocumentPositionParams{
+						TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+						Position:     Position{Line: 0, Character: 0},
+					},
+					Kinds: kinds,
+				},
+			})
+			require.NoError(t, err)
+			return defs
+		}
+
+		eventHandlerDefs := getDefs(SpxDefinitionKindEventHandler)
+		require.NotEmpty(t, eventHandlerDefs)
+		assert.True(t, spxDefinitionIdentifierSliceContains(eventHandlerDefs, SpxDefinitionIdentifier{
+			Package: util.ToPtr(GetSpxPkg().Path()),
+			Name:    util.ToPtr("Sprite.onStart"),
+		}))
+		for _, def := range eventHandlerDefs {
+			assert.Regexp(t, `(^|\.)on[A-Z]\w*$`, *def.Name)
+		}
+
+		spriteMethodDefs := getDefs(SpxDefinitionKindSpriteMethod)
+		assert.True(t, spxDefinitionIdentifierSliceContains(spriteMethodDefs, SpxDefinitionIdentifier{
+			Package: util.ToPtr("main"),
+			Name:    util.ToPtr("MySprite.fire"),
+		}))
+		assert.True(t, spxDefinitionIdentifierSliceContains(spriteMethodDefs, SpxDefinitionIdentifier{
+			Package:    util.ToPtr(GetSpxPkg().Path()),
+			Name:       util.ToPtr("Sprite.turn"),
+			OverloadID: util.ToPtr("1"),
+		}))
+		assert.False(t, spxDefinitionIdentifierSliceContains(spriteMethodDefs, SpxDefinitionIdentifier{
+			Package: util.ToPtr("builtin"),
+			Name:    util.ToPtr("println"),
+		}))
+
+		userDefs := getDefs(SpxDefinitionKindUser)
+		require.NotEmpty(t, userDefs)
+		for _, def := range userDefs {
+			assert.Equal(t, "main", *def.Package)
+		}
+		assert.True(t, spxDefinitionIdentifierSliceContains(userDefs, SpxDefinitionIdentifier{
+			Package: util.ToPtr("main"),
+			Name:    util.ToPtr("MySprite.fire"),
+		}))
+
+		assert.Len(t, getDefs(SpxDefinitionKindEventHandler, SpxDefinitionKindUser), len(eventHandlerDefs)+len(userDefs))
+	})
+
+	t.Run("IncludeDocumentation", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+		params := SpxGetDefinitionsParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 0, Character: 0},
+			},
+		}
+		forIterateDef := SpxDefinitionIdentifier{Name: util.ToPtr("for_iterate")}
+		findDef := func(defs []SpxDefinitionInfo) SpxDefinitionInfo {
+			i := slices.IndexFunc(defs, func(d SpxDefinitionInfo) bool {
+				return spxDefinitionIdentifierSliceContains([]SpxDefinitionInfo{d}, forIterateDef)
+			})
+			require.GreaterOrEqual(t, i, 0)
+			return defs[i]
+		}
+
+		defs, err := s.spxGetDefinitions([]SpxGetDefinitionsParams{params})
+		require.NoError(t, err)
+		def := findDef(defs)
+		assert.Empty(t, def.Overview)
+		assert.Empty(t, def.Detail)
+
+		params.IncludeDocumentation = true
+		defs, err = s.spxGetDefinitions([]SpxGetDefinitionsParams{params})
+		require.NoError(t, err)
+		def = findDef(defs)
+		assert.Equal(t, "for i, v <- set { ... }", def.Overview)
+		assert.Equal(t, "Iterate within given set", def.Detail)
+	})
+
+	t.Run("UnknownKind", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+		defs, err := s.spxGetDefinitions([]SpxGetDefinitionsParams{{Kinds: []SpxDefinitionKind{"unknown"}}})
+		require.EqualError(t, err, `unknown spx definition kind "unknown"`)
+		assert.Nil(t, defs)
+	})
 }
 
-// spxDefinitionIdentifierSliceContains reports whether a slice of [SpxDefinitionIdentifier]
+// spxDefinitionIdentifierSliceContains reports whether a slice of [SpxDefinitionInfo]
 // contains a specific [SpxDefinitionIdentifier].
-func spxDefinitionIdentifierSliceContains(defs []SpxDefinitionIdentifier, def SpxDefinitionIdentifier) bool {
-	return slices.ContainsFunc(defs, func(d SpxDefinitionIdentifier) bool {
+func spxDefinitionIdentifierSliceContains(defs []SpxDefinitionInfo, def SpxDefinitionIdentifier) bool {
+	return slices.ContainsFunc(defs, func(d SpxDefinitionInfo) bool {
 		return util.FromPtr(d.Package) == util.FromPtr(def.Package) &&
 			util.FromPtr(d.Name) == util.FromPtr(def.Name) &&
 			util.FromPtr(d.OverloadID) == util.FromPtr(def.OverloadID)
 	})
 }
+
+func TestServerSpxGetDefinitionsPage(t *testing.T) {
+	newServer := func() *Server {
+		return New(newMapFSWithoutModTime(newTestFileMap()), nil)
+	}
+	definitionsParams := SpxGetDefinitionsParams{
+		TextDocumentPositionParams: TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 0, Character: 0},
+		},
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer()
+		allDefs, err := s.spxGetDefinitions([]SpxGetDefinitionsParams{definitionsParams})
+		require.NoError(t, err)
+		require.Greater(t, len(allDefs), 10)
+
+		var (
+			pagedDefs []SpxDefinitionInfo
+			cursor    string
+			resultID  string
+		)
+		for {
+			page, err := s.spxGetDefinitionsPage([]SpxGetDefinitionsPageParams{{
+				SpxGetDefinitionsParams: definitionsParams,
+				Limit:                   10,
+				Cursor:                  cursor,
+			}})
+			require.NoError(t, err)
+			require.NotNil(t, page)
+			assert.Equal(t, SpxDefinitionsPageFull, page.Kind)
+			assert.LessOrEqual(t, len(page.Items), 10)
+			if resultID == "" {
+				resultID = page.ResultID
+			}
+			assert.Equal(t, resultID, page.ResultID)
+			pagedDefs = append(pagedDefs, page.Items...)
+			if page.NextCursor == "" {
+				break
+			}
+			cursor = page.NextCursor
+		}
+		assert.Equal(t, allDefs, pagedDefs)
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		s := newServer()
+		page, err := s.spxGetDefinitionsPage([]SpxGetDefinitionsPageParams{{SpxGetDefinitionsParams: definitionsParams}})
+		require.NoError(t, err)
+		require.NotNil(t, page)
+		assert.Equal(t, SpxDefinitionsPageFull, page.Kind)
+		assert.NotEmpty(t, page.Items)
+		assert.Empty(t, page.NextCursor)
+
+		unchangedPage, err := s.spxGetDefinitionsPage([]SpxGetDefinitionsPageParams{{
+			SpxGetDefinitionsParams: definitionsParams,
+			PreviousResultID:        page.ResultID,
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, &SpxDefinitionsPage{
+			Kind:     SpxDefinitionsPageUnchanged,
+			ResultID: page.ResultID,
+		}, unchangedPage)
+
+		changedPage, err := s.spxGetDefinitionsPage([]SpxGetDefinitionsPageParams{{
+			SpxGetDefinitionsParams: SpxGetDefinitionsParams{
+				TextDocumentPositionParams: definitionsParams.TextDocumentPositionParams,
+				Kinds:                      []SpxDefinitionKind{SpxDefinitionKindUser},
+			},
+			PreviousResultID: page.ResultID,
+		}})
+		require.NoError(t, err)
+		assert.Equal(t, SpxDefinitionsPageFull, changedPage.Kind)
+		assert.NotEqual(t, page.ResultID, changedPage.ResultID)
+	})
+
+	t.Run("StaleCursor", func(t *testing.T) {
+		s := newServer()
+		page, err := s.spxGetDefinitionsPage([]SpxGetDefinitionsPageParams{{
+			SpxGetDefinitionsParams: SpxGetDefinitionsParams{
+				TextDocumentPositionParams: definitionsParams.TextDocumentPositionParams,
+				Kinds:                      []SpxDefinitionKind{SpxDefinitionKindUser},
+			},
+			Limit: 1,
+		}})
+		require.NoError(t, err)
+		require.NotEmpty(t, page.NextCursor)
+
+		page, err = s.spxGetDefinitionsPage([]SpxGetDefinitionsPageParams{{
+			SpxGetDefinitionsParams: definitionsParams,
+			Cursor:                  page.NextCursor,
+		}})
+		require.ErrorContains(t, err, "definitions have changed")
+		assert.Nil(t, page)
+	})
+
+	t.Run("InvalidCursor", func(t *testing.T) {
+		s := newServer()
+		page, err := s.spxGetDefinitionsPage([]SpxGetDefinitionsPageParams{{
+			SpxGetDefinitionsParams: definitionsParams,
+			Cursor:                  "foo",
+		}})
+		require.EqualError(t, err, `invalid cursor "foo"`)
+		assert.Nil(t, page)
+	})
+}
+
+func TestServerSpxGetResourceReferences(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+
+		references, err := s.spxGetResourceReferences([]SpxGetResourceReferencesParams{
+			{Resource: SpxResourceIdentifier{URI: "spx://resources/sounds/biu"}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []SpxResourceReference{
+			{
+				Kind: SpxResourceRefKindStringLiteral,
+				Location: Location{
+					URI: "file:///MyAircraft.spx",
+					Range: Range{
+						Start: Position{Line: 5, Character: 7},
+						End:   Position{Line: 5, Character: 12},
+					},
+				},
+			},
+		}, references)
+
+		references, err = s.spxGetResourceReferences([]SpxGetResourceReferencesParams{
+			{Resource: SpxResourceIdentifier{URI: "spx://resources/sprites/MyAircraft"}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []SpxResourceReference{
+			{
+				Kind: SpxResourceRefKindAutoBinding,
+				Location: Location{
+					URI: "file:///main.spx",
+					Range: Range{
+						Start: Position{Line: 2, Character: 1},
+						End:   Position{Line: 2, Character: 11},
+					},
+				},
+			},
+		}, references)
+	})
+
+	t.Run("Unreferenced", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+		references, err := s.spxGetResourceReferences([]SpxGetResourceReferencesParams{
+			{Resource: SpxResourceIdentifier{URI: "spx://resources/backdrops/backdrop1"}},
+		})
+		require.NoError(t, err)
+		assert.NotNil(t, references)
+		assert.Empty(t, references)
+	})
+
+	t.Run("InvalidResourceURI", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+		_, err := s.spxGetResourceReferences([]SpxGetResourceReferencesParams{
+			{Resource: SpxResourceIdentifier{URI: "spx://resources/unknown/foo"}},
+		})
+		require.ErrorContains(t, err, "failed to parse spx resource URI")
+	})
+
+	t.Run("MultipleResources", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+		_, err := s.spxGetResourceReferences([]SpxGetResourceReferencesParams{
+			{Resource: SpxResourceIdentifier{URI: "spx://resources/sounds/biu"}},
+			{Resource: SpxResourceIdentifier{URI: "spx://resources/sprites/MyAircraft"}},
+		})
+		require.EqualError(t, err, "spx.getResourceReferences only supports one resource at a time")
+	})
+}
+
+func TestServerSpxCheckResourceDeletion(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+		check, err := s.spxCheckResourceDeletion([]SpxCheckResourceDeletionParams{
+			{Resource: SpxResourceIdentifier{URI: "spx://resources/sounds/biu"}},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, check)
+		assert.False(t, check.Safe)
+		assert.Equal(t, []SpxResourceReference{
+			{
+				Kind: SpxResourceRefKindStringLiteral,
+				Location: Location{
+					URI: "file:///MyAircraft.spx",
+					Range: Range{
+						Start: Position{Line: 5, Character: 7},
+						End:   Position{Line: 5, Character: 12},
+					},
+				},
+			},
+		}, check.References)
+	})
+
+	t.Run("Safe", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+		check, err := s.spxCheckResourceDeletion([]SpxCheckResourceDeletionParams{
+			{Resource: SpxResourceIdentifier{URI: "spx://resources/backdrops/backdrop1"}},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, check)
+		assert.True(t, check.Safe)
+		assert.Empty(t, check.References)
+	})
+
+	t.Run("NestedResources", func(t *testing.T) {
+		files := newTestFileMap()
+		files["MyAircraft.spx"] = []byte(`
+onStart => {
+	setCostume "hero"
+}
+`)
+		s := New(newMapFSWithoutModTime(files), nil)
+		check, err := s.spxCheckResourceDeletion([]SpxCheckResourceDeletionParams{
+			{Resource: SpxResourceIdentifier{URI: "spx://resources/sprites/MyAircraft"}},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, check)
+		assert.False(t, check.Safe)
+		assert.Equal(t, []SpxResourceReference{
+			{
+				Kind: SpxResourceRefKindStringLiteral,
+				Location: Location{
+					URI: "file:///MyAircraft.spx",
+					Range: Range{
+						Start: Position{Line: 2, Character: 12},
+						End:   Position{Line: 2, Character: 18},
+					},
+				},
+			},
+			{
+				Kind: SpxResourceRefKindAutoBinding,
+				Location: Location{
+					URI: "file:///main.spx",
+					Range: Range{
+						Start: Position{Line: 2, Character: 1},
+						End:   Position{Line: 2, Character: 11},
+					},
+				},
+			},
+		}, check.References)
+	})
+
+	t.Run("MultipleResources", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+		_, err := s.spxCheckResourceDeletion([]SpxCheckResourceDeletionParams{
+			{Resource: SpxResourceIdentifier{URI: "spx://resources/sounds/biu"}},
+			{Resource: SpxResourceIdentifier{URI: "spx://resources/sprites/MyAircraft"}},
+		})
+		require.EqualError(t, err, "spx.checkResourceDeletion only supports one resource at a time")
+	})
+}
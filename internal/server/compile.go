@@ -1,17 +1,21 @@
 package server
 
 import (
-	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"go/types"
 	"io/fs"
 	"maps"
 	"path"
+	"regexp"
 	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/goplus/gogen"
@@ -20,13 +24,21 @@ import (
 	gopscanner "github.com/goplus/gop/scanner"
 	goptoken "github.com/goplus/gop/token"
 	goptypesutil "github.com/goplus/gop/x/typesutil"
-	"github.com/goplus/goxlsw/internal"
 	"github.com/goplus/goxlsw/internal/pkgdata"
 	"github.com/goplus/goxlsw/internal/pkgdoc"
 	"github.com/goplus/goxlsw/internal/util"
 	"github.com/goplus/goxlsw/internal/vfs"
-	"github.com/goplus/mod/gopmod"
-	gopmodload "github.com/goplus/mod/modload"
+)
+
+// undefinedIdentErrMsgRE is the regular expression of the type checker error
+// message for undefined identifiers.
+var undefinedIdentErrMsgRE = regexp.MustCompile(`^undefined: (\w+)$`)
+
+// Percentages of the compilation progress reached after parsing and type
+// checking respectively.
+const (
+	compileParsePercentage     = 20
+	compileTypeCheckPercentage = 90
 )
 
 // errNoMainSpxFile is the error returned when no valid main.spx file is found
@@ -62,6 +74,17 @@ type compileResult struct {
 	// mainPkgDoc is the documentation for the main package.
 	mainPkgDoc *pkgdoc.PkgDoc
 
+	// module is the module of the workspace folder, loaded from its go.mod
+	// and gop.mod files.
+	module *spxModule
+
+	// classfileKind is the classfile kind of the project.
+	classfileKind *ClassfileKind
+
+	// classfileResources are the resources of the project loaded by
+	// [ClassfileKind.LoadResources], for classfile kinds other than spx.
+	classfileResources []ClassfileResource
+
 	// mainSpxFile is the main.spx file path.
 	mainSpxFile string
 
@@ -72,9 +95,28 @@ type compileResult struct {
 	// process.
 	typeInfo *goptypesutil.Info
 
+	// spxResourceRootDir is the root directory of spx resources, relative to
+	// the workspace root.
+	spxResourceRootDir string
+
 	// spxResourceSet is the set of spx resources.
 	spxResourceSet SpxResourceSet
 
+	// spxResourceSetKey identifies the metadata files spxResourceSet is
+	// created from, or is empty if it could not be created. See
+	// [spxResourceIndexFiles.cacheKey].
+	spxResourceSetKey string
+
+	// spxResourceMetadataValidations are the results of validating the
+	// metadata files of spx resources, keyed by their paths relative to the
+	// spx resource root directory.
+	spxResourceMetadataValidations map[string]spxResourceMetadataValidation
+
+	// malformedSpxResourceMetadataFile is the path of the malformed metadata
+	// file of spx resources relative to the workspace folder, or empty if
+	// there is none.
+	malformedSpxResourceMetadataFile string
+
 	// spxResourceRefs stores spx resource references.
 	spxResourceRefs []SpxResourceRef
 
@@ -88,6 +130,9 @@ type compileResult struct {
 	// spxSpriteResourceAutoBindings stores spx sprite resource auto-bindings.
 	spxSpriteResourceAutoBindings map[types.Object]struct{}
 
+	// unusedImportSpecs stores import specs that are not used in the code.
+	unusedImportSpecs map[*gopast.ImportSpec]struct{}
+
 	// diagnostics stores diagnostic messages for each document.
 	diagnostics map[DocumentURI][]Diagnostic
 
@@ -103,6 +148,13 @@ type compileResult struct {
 
 	// documentURIs maps each spx file path to its document URI.
 	documentURIs map[string]DocumentURI
+
+	// rootURI is the URI of the workspace folder being compiled.
+	rootURI DocumentURI
+
+	// positionEncoding is the position encoding of the protocol positions of
+	// the result, e.g., the ranges of its diagnostics.
+	positionEncoding PositionEncodingKind
 }
 
 // compileResultComputedCache represents the computed cache for [compileResult].
@@ -118,6 +170,9 @@ type compileResultComputedCache struct {
 
 	// semanticTokens stores semantic tokens for each document URI.
 	semanticTokens sync.Map // map[DocumentURI][]uint32
+
+	// lineIndexes stores the line index of each AST file.
+	lineIndexes sync.Map // map[*gopast.File]*lineIndex
 }
 
 // astFileLine represents an AST file line.
@@ -126,9 +181,11 @@ type astFileLine struct {
 	line    int
 }
 
-// newCompileResult creates a new [compileResult].
-func newCompileResult() *compileResult {
+// newCompileResult creates a new [compileResult] for the workspace folder of
+// the given URI.
+func newCompileResult(rootURI DocumentURI) *compileResult {
 	return &compileResult{
+		rootURI:                   rootURI,
 		fset:                      goptoken.NewFileSet(),
 		mainPkg:                   types.NewPackage("main", "main"),
 		mainASTPkg:                &gopast.Package{Name: "main", Files: make(map[string]*gopast.File)},
@@ -145,6 +202,7 @@ func newCompileResult() *compileResult {
 		},
 		spxSoundResourceAutoBindings:  make(map[types.Object]struct{}),
 		spxSpriteResourceAutoBindings: make(map[types.Object]struct{}),
+		unusedImportSpecs:             make(map[*gopast.ImportSpec]struct{}),
 		diagnostics:                   make(map[DocumentURI][]Diagnostic),
 		documentURIs:                  make(map[string]DocumentURI),
 	}
@@ -447,6 +505,44 @@ func (r *compileResult) spxDefinitionsForNamedStruct(named *types.Named) (defs [
 	return
 }
 
+// spxDefinitionIsOfKind reports whether the spx definition with the given ID
+// is of the given kind.
+func (r *compileResult) spxDefinitionIsOfKind(id SpxDefinitionIdentifier, kind SpxDefinitionKind) bool {
+	return spxDefinitionIsOfKind(id, kind, r.mainPkgSpriteTypes)
+}
+
+// spxDefinitionIsOfKind reports whether the spx definition with the given ID
+// is of the given kind, where mainPkgSpriteTypes are the sprite types of the
+// main package.
+func spxDefinitionIsOfKind(id SpxDefinitionIdentifier, kind SpxDefinitionKind, mainPkgSpriteTypes []*types.Named) bool {
+	if id.Package == nil || id.Name == nil {
+		return false
+	}
+	pkg := *id.Package
+	selector, member, ok := strings.Cut(*id.Name, ".")
+	if !ok {
+		selector, member = "", selector
+	}
+
+	switch kind {
+	case SpxDefinitionKindEventHandler:
+		return isSpxEventHandlerFuncName(member)
+	case SpxDefinitionKindSpriteMethod:
+		if selector == "" {
+			return false
+		}
+		if pkg == GetSpxPkg().Path() {
+			return selector == GetSpxSpriteType().Obj().Name()
+		}
+		return pkg == "main" && slices.ContainsFunc(mainPkgSpriteTypes, func(named *types.Named) bool {
+			return named.Obj().Name() == selector
+		})
+	case SpxDefinitionKindUser:
+		return pkg == "main"
+	}
+	return false
+}
+
 // isInSpxEventHandler checks if the given position is inside an spx event
 // handler callback.
 func (r *compileResult) isInSpxEventHandler(pos goptoken.Pos) bool {
@@ -503,6 +599,127 @@ func (r *compileResult) spxResourceRefAtASTFilePosition(astFile *gopast.File, po
 	return bestRef
 }
 
+// spxResourceRefsFor returns the references to the spx resource with the given
+// ID, sorted by their positions.
+func (r *compileResult) spxResourceRefsFor(id SpxResourceID) []SpxResourceRef {
+	return r.spxResourceRefsFunc(func(refID SpxResourceID) bool {
+		return refID == id
+	})
+}
+
+// spxResourceRefsFunc returns the references to the spx resources whose IDs
+// satisfy match, sorted by their positions.
+func (r *compileResult) spxResourceRefsFunc(match func(id SpxResourceID) bool) []SpxResourceRef {
+	var refs []SpxResourceRef
+	for _, ref := range r.spxResourceRefs {
+		if match(ref.ID) {
+			refs = append(refs, ref)
+		}
+	}
+	slices.SortFunc(refs, func(a, b SpxResourceRef) int {
+		aPos := r.fset.Position(a.Node.Pos())
+		bPos := r.fset.Position(b.Node.Pos())
+		return cmp.Or(strings.Compare(aPos.Filename, bPos.Filename), cmp.Compare(aPos.Offset, bPos.Offset))
+	})
+	return refs
+}
+
+// spxResourceReferences converts the given spx resource references to
+// [SpxResourceReference]s.
+func (r *compileResult) spxResourceReferences(refs []SpxResourceRef) []SpxResourceReference {
+	references := make([]SpxResourceReference, 0, len(refs))
+	for _, ref := range refs {
+		references = append(references, SpxResourceReference{
+			Kind:     ref.Kind,
+			Location: r.locationForNode(ref.Node),
+		})
+	}
+	return references
+}
+
+// spxResourceIDsForNameType returns the IDs of the spx resources whose names
+// are of the given spx resource name type, e.g., [GetSpxSoundNameType]. Sprite
+// costumes and animations are limited to the given sprite if it is not nil. It
+// returns nil if typ is not an spx resource name type.
+func (r *compileResult) spxResourceIDsForNameType(typ types.Type, spxSprite *SpxSpriteResource) []SpxResourceID {
+	var ids []SpxResourceID
+	switch typ {
+	case GetSpxBackdropNameType():
+		ids = slices.Grow(ids, len(r.spxResourceSet.backdrops))
+		for spxBackdropName := range r.spxResourceSet.backdrops {
+			ids = append(ids, SpxBackdropResourceID{spxBackdropName})
+		}
+	case GetSpxSpriteNameType():
+		ids = slices.Grow(ids, len(r.spxResourceSet.sprites))
+		for spxSpriteName := range r.spxResourceSet.sprites {
+			ids = append(ids, SpxSpriteResourceID{spxSpriteName})
+		}
+	case GetSpxSpriteCostumeNameType():
+		for _, s := range r.spxResourceSet.sprites {
+			if spxSprite == nil || s == spxSprite {
+				ids = slices.Grow(ids, len(s.NormalCostumes))
+				for _, spxSpriteCostume := range s.NormalCostumes {
+					ids = append(ids, SpxSpriteCostumeResourceID{s.Name, spxSpriteCostume.Name})
+				}
+			}
+		}
+	case GetSpxSpriteAnimationNameType():
+		for _, s := range r.spxResourceSet.sprites {
+			if spxSprite == nil || s == spxSprite {
+				ids = slices.Grow(ids, len(s.Animations))
+				for _, spxSpriteAnimation := range s.Animations {
+					ids = append(ids, SpxSpriteAnimationResourceID{s.Name, spxSpriteAnimation.Name})
+				}
+			}
+		}
+	case GetSpxSoundNameType():
+		ids = slices.Grow(ids, len(r.spxResourceSet.sounds))
+		for spxSoundName := range r.spxResourceSet.sounds {
+			ids = append(ids, SpxSoundResourceID{spxSoundName})
+		}
+	case GetSpxWidgetNameType():
+		ids = slices.Grow(ids, len(r.spxResourceSet.widgets))
+		for spxWidgetName := range r.spxResourceSet.widgets {
+			ids = append(ids, SpxWidgetResourceID{spxWidgetName})
+		}
+	}
+	return ids
+}
+
+// spxSpriteResourceForCall returns the [SpxSpriteResource] whose methods are
+// called by the given call expression in the given spx file. It returns nil if
+// no [SpxSpriteResource] can be inferred.
+func (r *compileResult) spxSpriteResourceForCall(spxFile string, callExpr *gopast.CallExpr) *SpxSpriteResource {
+	sel, ok := callExpr.Fun.(*gopast.SelectorExpr)
+	if !ok {
+		if spxFile == "main.spx" {
+			return nil
+		}
+		return r.spxResourceSet.sprites[strings.TrimSuffix(spxFile, ".spx")]
+	}
+
+	ident, ok := sel.X.(*gopast.Ident)
+	if !ok {
+		return nil
+	}
+	obj := r.typeInfo.ObjectOf(ident)
+	if obj == nil {
+		return nil
+	}
+	named, ok := obj.Type().(*types.Named)
+	if !ok {
+		return nil
+	}
+
+	if named == GetSpxSpriteType() {
+		return r.spxResourceSet.sprites[ident.Name]
+	}
+	if slices.Contains(r.mainPkgSpriteTypes, named) {
+		return r.spxResourceSet.sprites[obj.Name()]
+	}
+	return nil
+}
+
 // spxImportsAtASTFilePosition returns the import at the given position in the given AST file.
 func (r *compileResult) spxImportsAtASTFilePosition(astFile *gopast.File, position goptoken.Position) *SpxReferencePkg {
 	for _, imp := range astFile.Imports {
@@ -603,6 +820,12 @@ func (r *compileResult) posDocumentURI(pos goptoken.Pos) DocumentURI {
 	return r.documentURIs[r.posFilename(pos)]
 }
 
+// toDocumentURI returns the [DocumentURI] for a path relative to the compiled
+// workspace folder.
+func (r *compileResult) toDocumentURI(path string) DocumentURI {
+	return DocumentURI(string(r.rootURI) + path)
+}
+
 // nodeDocumentURI returns the [DocumentURI] for the given node.
 func (r *compileResult) nodeDocumentURI(node gopast.Node) DocumentURI {
 	return r.posDocumentURI(node.Pos())
@@ -610,17 +833,10 @@ func (r *compileResult) nodeDocumentURI(node gopast.Node) DocumentURI {
 
 // fromPosition converts a [goptoken.Position] to a protocol [Position].
 func (r *compileResult) fromPosition(astFile *gopast.File, position goptoken.Position) Position {
-	tokenFile := r.fset.File(astFile.Pos())
-
-	line := position.Line
-	lineStart := int(tokenFile.LineStart(line))
-	relLineStart := lineStart - tokenFile.Base()
-	lineContent := astFile.Code[relLineStart : relLineStart+position.Column]
-	utf16Offset := utf8OffsetToUTF16(string(lineContent), position.Column-1)
-
+	line := position.Line - 1
 	return Position{
-		Line:      uint32(position.Line - 1),
-		Character: uint32(utf16Offset),
+		Line:      uint32(line),
+		Character: uint32(r.lineIndexOf(astFile).character(line, position.Column-1)),
 	}
 }
 
@@ -631,11 +847,7 @@ func (r *compileResult) toPosition(astFile *gopast.File, position Position) gopt
 	line := min(int(position.Line)+1, tokenFile.LineCount())
 	lineStart := int(tokenFile.LineStart(line))
 	relLineStart := lineStart - tokenFile.Base()
-	lineContent := astFile.Code[relLineStart:]
-	if i := bytes.IndexByte(lineContent, '\n'); i >= 0 {
-		lineContent = lineContent[:i]
-	}
-	utf8Offset := utf16OffsetToUTF8(string(lineContent), int(position.Character))
+	utf8Offset := r.lineIndexOf(astFile).byteColumn(line-1, int(position.Character))
 	column := utf8Offset + 1
 
 	return goptoken.Position{
@@ -697,16 +909,114 @@ func (r *compileResult) locationForNode(node gopast.Node) Location {
 }
 
 // compileCache represents a cache for compilation results.
+//
+// A cached result is reused as a whole as long as none of the files changed
+// since the snapshot it is compiled at is an input of it. See
+// [Server.isCompileInput]. Otherwise, the next compilation still reuses the spx
+// resource set and the validations of the metadata files of the cached result
+// for unchanged metadata files, while imported packages are kept by the
+// server-wide importer and [packageCache] anyway.
+//
+// The ASTs of unchanged spx files are parsed again, as the type checker
+// mutates the ASTs it checks in ways that cannot be repeated, e.g., by
+// appending declarations to classfiles, prepending statements to the bodies
+// of some range loops and dropping the parameters of unary operator methods.
+// Their types cannot be reused either, as the type checker checks all files of
+// the main package at once, generating the Game and sprite classes from them.
+// A change to any spx file thus type checks the whole main package again,
+// which takes most of the time of the compilation and grows with the number
+// of sprites, e.g., generating Game.Main checks every sprite class against
+// spx.Sprite. See BenchmarkCompileAfterSpxFileChange.
 type compileCache struct {
-	result          *compileResult
-	spxFileModTimes map[string]time.Time
+	result *compileResult
+
+	// snapshot is the snapshot of the workspace folder the result is compiled
+	// at. Checking it for changes only reads and hashes files replaced since
+	// then, as copies of unchanged files share their hashes across snapshots.
+	// See [vfs.MapFS.ChangedSince].
+	snapshot *vfs.MapFS
+
+	// estimatedBytes is the estimated memory used by the result, and
+	// lastUsed is when it was last used. See [WithMemoryBudget].
+	estimatedBytes int64
+	lastUsed       time.Time
+}
+
+// isCompileInput reports whether changes to the given file of a workspace
+// folder may change the given compile result of it, i.e., whether the file is
+// a classfile, a module file, including those of replaced modules in the
+// workspace folder, or a metadata file of spx resources.
+func (s *Server) isCompileInput(result *compileResult, file string) bool {
+	if s.isClassfile(file) || slices.Contains(moduleFiles, path.Base(file)) {
+		return true
+	}
+	_, _, ok := result.spxResourceMetadataFileFor(file)
+	return ok
+}
+
+// compileInputsChanged reports whether any input of the cached compile result
+// has changed at the given snapshot. See [Server.isCompileInput].
+func (s *Server) compileInputsChanged(cache *compileCache, snapshot *vfs.MapFS) bool {
+	changes := snapshot.ChangedSince(cache.snapshot)
+	for _, files := range [][]string{changes.Added, changes.Removed, changes.Modified} {
+		if slices.ContainsFunc(files, func(file string) bool {
+			return s.isCompileInput(cache.result, file)
+		}) {
+			return true
+		}
+	}
+	return false
 }
 
 // compile compiles spx source files and returns compile result. It uses cached
 // result if available.
 func (s *Server) compile() (*compileResult, error) {
-	snapshot := s.workspaceRootFS.Snapshot()
-	spxFiles, err := listSpxFiles(snapshot)
+	return s.compileWithContext(context.Background())
+}
+
+// compileWithContext is like [Server.compile] but abandons the compilation
+// once ctx is done, e.g., when the request is canceled.
+func (s *Server) compileWithContext(ctx context.Context) (*compileResult, error) {
+	return s.compileWorkspaceFolder(ctx, s.defaultWorkspaceFolder())
+}
+
+// compileWorkspaceFolders compiles spx source files in all workspace folders
+// and returns their compile results. Workspace folders without spx source
+// files are skipped, and [errNoMainSpxFile] is returned if every folder is
+// skipped.
+func (s *Server) compileWorkspaceFolders(ctx context.Context) ([]*compileResult, error) {
+	var results []*compileResult
+	for _, folder := range s.getWorkspaceFolders() {
+		result, err := s.compileWorkspaceFolder(ctx, folder)
+		if err != nil {
+			if errors.Is(err, errNoMainSpxFile) {
+				continue
+			}
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if len(results) == 0 {
+		return nil, errNoMainSpxFile
+	}
+	return results, nil
+}
+
+// compileWorkspaceFolder compiles spx source files in the given workspace
+// folder and returns compile result. It uses the cached result of the
+// workspace folder if available, and abandons the compilation once the
+// workspace folder is removed.
+func (s *Server) compileWorkspaceFolder(ctx context.Context, folder *workspaceFolder) (*compileResult, error) {
+	if err := folder.ctx.Err(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := context.AfterFunc(folder.ctx, cancel)
+	defer stop()
+
+	snapshot := s.workspaceFolderSnapshot(folder)
+	spxFiles, err := listClassfiles(snapshot, s.classfileKindFor(snapshot).Ext)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get spx files: %w", err)
 	}
@@ -715,90 +1025,130 @@ func (s *Server) compile() (*compileResult, error) {
 	}
 	slices.Sort(spxFiles)
 
-	s.lastCompileCacheMu.Lock()
-	defer s.lastCompileCacheMu.Unlock()
-
-	// Try to use cache first.
-	if cache := s.lastCompileCache; cache != nil {
-		// Check if spx file set has changed.
-		cachedSpxFiles := slices.Sorted(maps.Keys(cache.spxFileModTimes))
-		if slices.Equal(spxFiles, cachedSpxFiles) {
-			// Check if any spx file has been modified.
-			modified := false
-			for _, spxFile := range spxFiles {
-				fi, err := fs.Stat(snapshot, spxFile)
-				if err != nil {
-					return nil, fmt.Errorf("failed to stat file %q: %w", spxFile, err)
-				}
-				if cachedModTime, ok := cache.spxFileModTimes[spxFile]; !ok || !fi.ModTime().Equal(cachedModTime) {
-					modified = true
-					break
-				}
-			}
-			if !modified {
-				return cache.result, nil
-			}
-		}
+	folder.lastCompileCacheMu.Lock()
+	defer folder.lastCompileCacheMu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	// Compile at the given snapshot if cache is not used.
-	result, err := s.compileAt(snapshot)
+	// Try to use cache first.
+	if cache := folder.lastCompileCache; cache != nil &&
+		cache.result.positionEncoding == s.positionEncoding() &&
+		!s.compileInputsChanged(cache, snapshot) {
+		cache.lastUsed = time.Now()
+		return cache.result, nil
+	}
+
+	// Compile at the given snapshot if cache is not used. Report progress for
+	// the first compilation, which is the slowest one.
+	var progress *workDoneProgress
+	if folder.lastCompileCache == nil {
+		progress = s.beginWorkDoneProgress(nil, "Analyzing project")
+	}
+	logger := s.loggerFor(ctx).With("workspaceFolder", string(folder.uri))
+	start := time.Now()
+	var prev *compileResult
+	if folder.lastCompileCache != nil {
+		prev = folder.lastCompileCache.result
+	}
+	result, err := s.compileAtWithContext(ctx, folder, snapshot, prev, progress)
 	if err != nil {
+		progress.end("Failed to analyze project")
+		duration := time.Since(start)
+		logger.Debug("failed to analyze project", "error", err, "duration", duration)
+		s.telemetry.AnalysisCompleted(folder.uri, len(spxFiles), duration, err)
 		return nil, err
 	}
+	progress.end(fmt.Sprintf("Analyzed %s", pluralize(len(spxFiles), "file", "files")))
+	duration := time.Since(start)
+	logger.Debug("analyzed project", "files", len(spxFiles), "duration", duration)
+	s.telemetry.AnalysisCompleted(folder.uri, len(spxFiles), duration, nil)
+	s.promptMalformedSpxResourceMetadataFile(folder, result.malformedSpxResourceMetadataFile)
 
 	// Update cache.
-	modTimes := make(map[string]time.Time, len(spxFiles))
-	for _, spxFile := range spxFiles {
-		fi, err := fs.Stat(snapshot, spxFile)
-		if err != nil {
-			return nil, fmt.Errorf("failed to stat file %q: %w", spxFile, err)
-		}
-		modTimes[spxFile] = fi.ModTime()
-	}
-	s.lastCompileCache = &compileCache{
-		result:          result,
-		spxFileModTimes: modTimes,
+	folder.lastCompileCache = &compileCache{
+		result:         result,
+		snapshot:       snapshot,
+		estimatedBytes: result.estimatedBytes(),
+		lastUsed:       time.Now(),
 	}
+	s.enforceMemoryBudget(folder)
 
 	return result, nil
 }
 
-// compileAt compiles spx source files at the given snapshot and returns the
-// compile result.
-func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
-	spxFiles, err := listSpxFiles(snapshot)
+// compileAt compiles spx source files at the given snapshot of the given
+// workspace folder and returns the compile result.
+func (s *Server) compileAt(folder *workspaceFolder, snapshot *vfs.MapFS) (*compileResult, error) {
+	return s.compileAtWithContext(context.Background(), folder, snapshot, nil, nil)
+}
+
+// compileAtWithContext is like [Server.compileAt] but reports the progress of
+// parsing and type checking via the given progress, and abandons the
+// compilation between its stages once ctx is done. The spx resources of the
+// given previous result, if any, are reused if their metadata files are
+// unchanged. See [compileCache].
+func (s *Server) compileAtWithContext(ctx context.Context, folder *workspaceFolder, snapshot *vfs.MapFS, prev *compileResult, progress *workDoneProgress) (*compileResult, error) {
+	kind := s.classfileKindFor(snapshot)
+	spxFiles, err := listClassfiles(snapshot, kind.Ext)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get spx files: %w", err)
 	}
 	if len(spxFiles) == 0 {
 		return nil, errNoMainSpxFile
 	}
+	module, err := loadSpxModule(snapshot, kind, s.positionEncoding())
+	if err != nil {
+		return nil, err
+	}
+	module.packageCache = s.packageCache
 
 	var (
-		result      = newCompileResult()
+		result      = newCompileResult(folder.uri)
 		gpfs        = vfs.NewGopParserFS(snapshot)
 		spriteNames = make([]string, 0, len(spxFiles)-1)
 	)
-	for _, spxFile := range spxFiles {
-		documentURI := s.toDocumentURI(spxFile)
+	result.module = module
+	result.classfileKind = kind
+	result.positionEncoding = s.positionEncoding()
+
+	// Spx files are parsed concurrently, as they are independent of each
+	// other, and then registered in order.
+	type parsedSpxFile struct {
+		astFile *gopast.File
+		err     error
+	}
+	parsedSpxFiles := make([]parsedSpxFile, len(spxFiles))
+	var parsedCount atomic.Int64
+	forEachConcurrently(s.analysisWorkers, len(spxFiles), func(i int) {
+		if ctx.Err() != nil {
+			return
+		}
+		spxFile := spxFiles[i]
+		n := int(parsedCount.Add(1))
+		progress.report(fmt.Sprintf("Parsing %s (%d/%d)", spxFile, n, len(spxFiles)), uint32((n-1)*compileParsePercentage/len(spxFiles)))
+
+		parsed := &parsedSpxFiles[i]
+		defer func() {
+			if r := recover(); r != nil {
+				parsed.err = fmt.Errorf("parser panic: %v", r)
+			}
+		}()
+		parsed.astFile, parsed.err = gopparser.ParseFSEntry(result.fset, gpfs, spxFile, nil, gopparser.Config{
+			ClassKind: module.mod.ClassKind,
+			Mode:      gopparser.ParseComments | gopparser.AllErrors | gopparser.ParseGoPlusClass,
+		})
+	})
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, spxFile := range spxFiles {
+		documentURI := result.toDocumentURI(spxFile)
 		result.diagnostics[documentURI] = []Diagnostic{}
 		result.documentURIs[spxFile] = documentURI
 
-		var (
-			astFile *gopast.File
-			err     error
-		)
-		func() {
-			defer func() {
-				if r := recover(); r != nil {
-					err = fmt.Errorf("parser panic: %v", r)
-				}
-			}()
-			astFile, err = gopparser.ParseFSEntry(result.fset, gpfs, spxFile, nil, gopparser.Config{
-				Mode: gopparser.ParseComments | gopparser.AllErrors | gopparser.ParseGoPlusClass,
-			})
-		}()
+		astFile, err := parsedSpxFiles[i].astFile, parsedSpxFiles[i].err
 		if err != nil {
 			var (
 				errorList gopscanner.ErrorList
@@ -809,6 +1159,7 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 				for _, e := range errorList {
 					result.addDiagnostics(documentURI, Diagnostic{
 						Severity: SeverityError,
+						Code:     DiagnosticCodeParseError,
 						Range:    result.rangeForASTFilePosition(astFile, e.Pos),
 						Message:  e.Msg,
 					})
@@ -817,6 +1168,7 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 				// Handle code generation errors.
 				result.addDiagnostics(documentURI, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeParseError,
 					Range:    result.rangeForPos(codeError.Pos),
 					Message:  codeError.Error(),
 				})
@@ -824,6 +1176,7 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 				// Handle unknown errors (including recovered panics).
 				result.addDiagnostics(documentURI, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeParseError,
 					Message:  fmt.Sprintf("failed to parse spx file: %v", err),
 				})
 			}
@@ -834,6 +1187,7 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 		if astFile.Name.Name != "main" {
 			result.addDiagnostics(documentURI, Diagnostic{
 				Severity: SeverityError,
+				Code:     DiagnosticCodeInvalidPackageName,
 				Range:    result.rangeForASTFileNode(astFile, astFile.Name),
 				Message:  "package name must be main",
 			})
@@ -841,10 +1195,10 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 		}
 
 		result.mainASTPkg.Files[spxFile] = astFile
-		if spxFileBaseName := path.Base(spxFile); spxFileBaseName == "main.spx" {
+		if spxFileBaseName := path.Base(spxFile); spxFileBaseName == kind.projectFile() {
 			result.mainSpxFile = spxFile
 		} else {
-			spriteNames = append(spriteNames, strings.TrimSuffix(spxFileBaseName, ".spx"))
+			spriteNames = append(spriteNames, strings.TrimSuffix(spxFileBaseName, kind.Ext))
 		}
 
 		for _, decl := range astFile.Decls {
@@ -871,28 +1225,35 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 
 	result.mainPkgDoc = pkgdoc.NewForSpxMainPackage(result.mainASTPkg)
 
-	mod := gopmod.New(gopmodload.Default)
-	if err := mod.ImportClasses(); err != nil {
-		return nil, fmt.Errorf("failed to import classes: %w", err)
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
+	progress.report("Type checking", compileParsePercentage)
+	for _, moduleFile := range moduleFiles {
+		if _, err := fs.Stat(snapshot, moduleFile); err == nil {
+			result.diagnostics[result.toDocumentURI(moduleFile)] = []Diagnostic{}
+		}
+	}
+	for moduleFile, diags := range module.diagnostics {
+		result.addDiagnostics(result.toDocumentURI(moduleFile), diags...)
+	}
+	// Type errors are collected and reported after type checking, as
+	// spelling suggestions for undefined identifiers require complete type
+	// information.
+	var typeErrs []types.Error
 	if err := goptypesutil.NewChecker(
 		&types.Config{
 			Error: func(err error) {
 				if typeErr, ok := err.(types.Error); ok {
-					position := typeErr.Fset.Position(typeErr.Pos)
-					result.addDiagnosticsForSpxFile(position.Filename, Diagnostic{
-						Severity: SeverityError,
-						Range:    result.rangeForPos(typeErr.Pos),
-						Message:  typeErr.Msg,
-					})
+					typeErrs = append(typeErrs, typeErr)
 				}
 			},
-			Importer: internal.Importer,
+			Importer: module,
 		},
 		&goptypesutil.Config{
 			Types: result.mainPkg,
 			Fset:  result.fset,
-			Mod:   mod,
+			Mod:   module.mod,
 		},
 		nil,
 		result.typeInfo,
@@ -911,24 +1272,132 @@ func (s *Server) compileAt(snapshot *vfs.MapFS) (*compileResult, error) {
 		}
 	}
 
-	s.inspectForSpxResourceSet(snapshot, result)
-	s.inspectForSpxResourceRefs(result)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	progress.report("Inspecting resources", compileTypeCheckPercentage)
+	if kind.isSpx() {
+		s.inspectForSpxResourceSet(snapshot, result, prev)
+	}
+	for _, typeErr := range typeErrs {
+		result.addTypeErrorDiagnostic(typeErr)
+	}
+	if kind.isSpx() {
+		s.inspectForSpxResourceRefs(result)
+	} else {
+		s.inspectForClassfileResources(snapshot, result)
+	}
+	s.runAnalyzers(result)
+	result.rewordTypeErrorDiagnostics(s.config())
+	result.suppressIgnoredDiagnostics()
 
 	return result, nil
 }
 
+// addTypeErrorDiagnostic adds the diagnostic for the given type error. The
+// diagnostic of an undefined identifier covers the identifier, which can be
+// fixed by declaring it as a variable, or by changing it to the nearest name
+// that can be referenced at its position.
+func (r *compileResult) addTypeErrorDiagnostic(typeErr types.Error) {
+	if !typeErr.Pos.IsValid() {
+		// Errors without positions, e.g., of a classfile framework lacking
+		// methods required by the generated code, are reported at the
+		// beginning of the main spx file.
+		r.addDiagnosticsForSpxFile(r.mainSpxFile, Diagnostic{
+			Severity: SeverityError,
+			Code:     DiagnosticCodeTypeError,
+			Message:  typeErr.Msg,
+		})
+		return
+	}
+	diag := Diagnostic{
+		Severity: SeverityError,
+		Code:     DiagnosticCodeTypeError,
+		Range:    r.rangeForPos(typeErr.Pos),
+		Message:  typeErr.Msg,
+	}
+	if matches := undefinedIdentErrMsgRE.FindStringSubmatch(typeErr.Msg); len(matches) == 2 {
+		data := DiagnosticData{
+			Fix:  DiagnosticFixDeclareVar,
+			Name: matches[1],
+		}
+		if suggestion, ok := spellingSuggestionFor(data.Name, r.identNamesAt(typeErr.Pos)); ok {
+			data.Suggestion = suggestion
+		}
+		diag.Range.End = r.rangeForPos(typeErr.Pos + goptoken.Pos(len(data.Name))).End
+		diag.Data = makeDiagnosticData(data)
+	}
+	r.addDiagnosticsForSpxFile(typeErr.Fset.Position(typeErr.Pos).Filename, diag)
+}
+
+// identNamesAt returns the names that can be referenced by identifiers at the
+// given position, i.e., objects in the enclosing scopes, members of the class
+// of the spx file, names of spx sprite resources and builtin objects, in
+// descending order of their closeness to the position.
+func (r *compileResult) identNamesAt(pos goptoken.Pos) []string {
+	var names []string
+	for scope := r.innermostScopeAt(pos); scope != nil && scope != types.Universe; scope = scope.Parent() {
+		isLocalScope := scope != r.mainPkg.Scope()
+		for _, name := range scope.Names() {
+			obj := scope.Lookup(name)
+			if !isExportedOrMainPkgObject(obj) {
+				continue
+			}
+			if isLocalScope && obj.Pos() > pos {
+				continue
+			}
+			names = append(names, name)
+		}
+	}
+
+	if classType := r.spxClassTypeFor(r.posFilename(pos)); classType != nil {
+		for _, def := range r.spxDefinitionsForNamedStruct(classType) {
+			if def.CompletionItemLabel != "" {
+				names = append(names, def.CompletionItemLabel)
+			}
+		}
+	}
+
+	names = append(names, slices.Sorted(maps.Keys(r.spxResourceSet.sprites))...)
+	names = append(names, types.Universe.Names()...)
+	return names
+}
+
+// spxClassTypeFor returns the class type of the given spx file, i.e., the
+// game type for the main spx file and the sprite type for others. It returns
+// nil if not found.
+func (r *compileResult) spxClassTypeFor(spxFile string) *types.Named {
+	if spxFile == r.mainSpxFile {
+		return r.mainPkgGameType
+	}
+	spxSpriteName := strings.TrimSuffix(path.Base(spxFile), ".spx")
+	for _, named := range r.mainPkgSpriteTypes {
+		if named.Obj().Name() == spxSpriteName {
+			return named
+		}
+	}
+	return nil
+}
+
 // compileAndGetASTFileForDocumentURI handles common compilation and file
 // retrieval logic for a given document URI. The returned astFile is probably
 // nil even if the compilation succeeded.
 func (s *Server) compileAndGetASTFileForDocumentURI(uri DocumentURI) (result *compileResult, spxFile string, astFile *gopast.File, err error) {
-	spxFile, err = s.fromDocumentURI(uri)
+	return s.compileAndGetASTFileForDocumentURIWithContext(context.Background(), uri)
+}
+
+// compileAndGetASTFileForDocumentURIWithContext is like
+// [Server.compileAndGetASTFileForDocumentURI] but abandons the compilation once
+// ctx is done.
+func (s *Server) compileAndGetASTFileForDocumentURIWithContext(ctx context.Context, uri DocumentURI) (result *compileResult, spxFile string, astFile *gopast.File, err error) {
+	folder, spxFile, err := s.workspaceFolderFor(uri)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to get file path from document URI %q: %w", uri, err)
 	}
-	if path.Ext(spxFile) != ".spx" {
-		return nil, "", nil, fmt.Errorf("file %q does not have .spx extension", spxFile)
+	if !s.isClassfile(spxFile) {
+		return nil, "", nil, fmt.Errorf("file %q does not have %s extension", spxFile, s.classfileExts())
 	}
-	result, err = s.compile()
+	result, err = s.compileWorkspaceFolder(ctx, folder)
 	if err != nil {
 		return nil, "", nil, fmt.Errorf("failed to compile: %w", err)
 	}
@@ -936,55 +1405,144 @@ func (s *Server) compileAndGetASTFileForDocumentURI(uri DocumentURI) (result *co
 	return
 }
 
-// inspectForSpxResourceSet inspects for spx resource set in main.spx.
-func (s *Server) inspectForSpxResourceSet(snapshot *vfs.MapFS, result *compileResult) {
+// inspectForSpxResourceSet inspects for spx resource set in main.spx. The
+// resource set and the validations of the metadata files of the given
+// previous result, if any, are reused for unchanged metadata files.
+func (s *Server) inspectForSpxResourceSet(snapshot *vfs.MapFS, result *compileResult, prev *compileResult) {
 	var spxResourceRootDir string
-	gopast.Inspect(result.mainASTPkg.Files[result.mainSpxFile], func(node gopast.Node) bool {
-		callExpr, ok := node.(*gopast.CallExpr)
-		if !ok {
-			return true
-		}
-		ident, ok := callExpr.Fun.(*gopast.Ident)
-		if !ok || ident.Name != "run" {
-			return true
-		}
-
-		if len(callExpr.Args) == 0 {
-			return true
-		}
+	if callExpr := result.spxRunCall(); callExpr != nil {
 		firstArg := callExpr.Args[0]
-		firstArgTV, ok := result.typeInfo.Types[firstArg]
-		if !ok {
-			return true
-		}
-
-		if types.AssignableTo(firstArgTV.Type, types.Typ[types.String]) {
-			spxResourceRootDir, _ = getStringLitOrConstValue(firstArg, firstArgTV)
-		} else {
-			result.addDiagnosticsForSpxFile(result.mainSpxFile, Diagnostic{
-				Severity: SeverityError,
-				Range:    result.rangeForNode(firstArg),
-				Message:  "first argument of run must be a string literal or constant",
-			})
+		if firstArgTV, ok := result.typeInfo.Types[firstArg]; ok {
+			if types.AssignableTo(firstArgTV.Type, types.Typ[types.String]) {
+				spxResourceRootDir, _ = getStringLitOrConstValue(firstArg, firstArgTV)
+			} else {
+				result.addDiagnosticsForSpxFile(result.mainSpxFile, Diagnostic{
+					Severity: SeverityError,
+					Code:     DiagnosticCodeInvalidRunArgument,
+					Range:    result.rangeForNode(firstArg),
+					Message:  "first argument of run must be a string literal or constant",
+				})
+			}
 		}
-		return false
-	})
+	}
 	if spxResourceRootDir == "" {
 		spxResourceRootDir = "assets"
 	}
-	spxResourceRootFS, _ := fs.Sub(snapshot, spxResourceRootDir)
+	result.spxResourceRootDir = spxResourceRootDir
+	spxResourceRootFS := snapshot.Sub(spxResourceRootDir)
+	var prevValidations map[string]spxResourceMetadataValidation
+	if prev != nil {
+		prevValidations = prev.spxResourceMetadataValidations
+	}
+	result.inspectForInvalidSpxResourceMetadata(spxResourceRootFS, s.analysisWorkers, prevValidations)
 
-	spxResourceSet, err := NewSpxResourceSet(spxResourceRootFS)
+	files, err := readSpxResourceIndexFiles(spxResourceRootFS)
+	var spxResourceSet *SpxResourceSet
+	if err == nil {
+		key := files.cacheKey(spxResourceRootFS)
+		if prev != nil && prev.spxResourceSetKey == key {
+			spxResourceSet = &prev.spxResourceSet
+		} else {
+			spxResourceSet, err = files.resourceSet(spxResourceRootFS, s.analysisWorkers, s.cache, s.logger)
+		}
+		if err == nil {
+			result.spxResourceSetKey = key
+		}
+	}
 	if err != nil {
 		result.addDiagnosticsForSpxFile(result.mainSpxFile, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeInvalidResourceSet,
 			Message:  fmt.Sprintf("failed to create spx resource set: %v", err),
 		})
+		var metadataErr *SpxResourceMetadataError
+		if errors.As(err, &metadataErr) {
+			result.malformedSpxResourceMetadataFile = path.Join(spxResourceRootDir, metadataErr.Path)
+		}
 		return
 	}
 	result.spxResourceSet = *spxResourceSet
 }
 
+// spxRunCall returns the first call to run with arguments in main.spx, like
+// `run "assets", {Title: "My Game"}`, or nil if there is none.
+func (r *compileResult) spxRunCall() *gopast.CallExpr {
+	var runCall *gopast.CallExpr
+	gopast.Inspect(r.mainASTPkg.Files[r.mainSpxFile], func(node gopast.Node) bool {
+		if runCall != nil {
+			return false
+		}
+		callExpr, ok := node.(*gopast.CallExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := callExpr.Fun.(*gopast.Ident); ok && ident.Name == "run" && len(callExpr.Args) > 0 {
+			runCall = callExpr
+			return false
+		}
+		return true
+	})
+	return runCall
+}
+
+// unusedImportAnalyzer is an [analyzer] for imports that are not used in the
+// code. It also records the unused imports for formatting.
+var unusedImportAnalyzer = &analyzer{
+	name:     "unusedImport",
+	requires: requireAST | requireTypeInfo,
+	run:      inspectForUnusedImports,
+}
+
+// inspectForUnusedImports inspects for imports that are not used in the code.
+func inspectForUnusedImports(pass *analysisPass) {
+	result := pass.result
+	usedPkgNames := make(map[types.Object]struct{})
+	for _, obj := range result.typeInfo.Uses {
+		if pkgName, ok := obj.(*types.PkgName); ok {
+			usedPkgNames[pkgName] = struct{}{}
+		}
+	}
+
+	for spxFile, astFile := range result.mainASTPkg.Files {
+		for _, importSpec := range astFile.Imports {
+			if importSpec.Path == nil {
+				continue
+			}
+			var pkgName types.Object
+			if importSpec.Name != nil {
+				if importSpec.Name.Name == "_" || importSpec.Name.Name == "." {
+					continue
+				}
+				pkgName = result.typeInfo.Defs[importSpec.Name]
+			} else {
+				pkgName = result.typeInfo.Implicits[importSpec]
+			}
+			if pkgName == nil {
+				continue
+			}
+			if _, ok := usedPkgNames[pkgName]; ok {
+				continue
+			}
+			result.unusedImportSpecs[importSpec] = struct{}{}
+
+			pkgPath, err := strconv.Unquote(importSpec.Path.Value)
+			if err != nil {
+				continue
+			}
+			pass.report(spxFile, Diagnostic{
+				Severity: SeverityWarning,
+				Code:     DiagnosticCodeUnusedImport,
+				Range:    result.rangeForNode(importSpec),
+				Message:  fmt.Sprintf("%q imported and not used", pkgPath),
+				Data: makeDiagnosticData(DiagnosticData{
+					Fix:  DiagnosticFixRemoveUnusedImport,
+					Name: pkgPath,
+				}),
+			})
+		}
+	}
+}
+
 // inspectForSpxResourceRefs inspects for spx resource references in the code.
 func (s *Server) inspectForSpxResourceRefs(result *compileResult) {
 	mainSpxFileScope := result.typeInfo.Scopes[result.mainASTPkg.Files[result.mainSpxFile]]
@@ -1042,6 +1600,7 @@ func (s *Server) inspectForSpxResourceRefs(result *compileResult) {
 		if !result.isDefinedInFirstVarBlock(obj) {
 			result.addDiagnosticsForSpxFile(spxFile, Diagnostic{
 				Severity: SeverityWarning,
+				Code:     DiagnosticCodeInvalidAutoBinding,
 				Range:    result.rangeForNode(ident),
 				Message:  "resources must be defined in the first var block for auto-binding",
 			})
@@ -1214,6 +1773,33 @@ func (s *Server) inspectSpxResourceRefForTypeAtExpr(result *compileResult, expr
 	}
 }
 
+// spxResourceNotFoundDiagnosticData returns the data of the diagnostic for
+// the reference to the missing spx resource with the given ID at the given
+// expression. Sprites and sounds can be created as stubs, and string literal
+// references can be fixed to the nearest name of existing resources of the
+// given name type. It returns nil if no quick fix is available.
+func (r *compileResult) spxResourceNotFoundDiagnosticData(expr gopast.Expr, id SpxResourceID, nameType types.Type, spxSprite *SpxSpriteResource) *json.RawMessage {
+	var data DiagnosticData
+	switch id.(type) {
+	case SpxSpriteResourceID, SpxSoundResourceID:
+		data.Fix = DiagnosticFixCreateResource
+		data.Name = string(id.URI())
+	}
+	if basicLit, ok := expr.(*gopast.BasicLit); ok && basicLit.Kind == goptoken.STRING {
+		var names []string
+		for _, candidate := range r.spxResourceIDsForNameType(nameType, spxSprite) {
+			names = append(names, candidate.Name())
+		}
+		if suggestion, ok := spellingSuggestionFor(id.Name(), names); ok {
+			data.Suggestion = strconv.Quote(suggestion)
+		}
+	}
+	if data.Fix == "" && data.Suggestion == "" {
+		return nil
+	}
+	return makeDiagnosticData(data)
+}
+
 // inspectSpxBackdropResourceRefAtExpr inspects an spx backdrop resource
 // reference at an expression. It returns the spx backdrop resource if it was
 // successfully retrieved.
@@ -1229,6 +1815,7 @@ func (s *Server) inspectSpxBackdropResourceRefAtExpr(result *compileResult, expr
 	if spxBackdropName == "" {
 		result.addDiagnostics(exprDocumentURI, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeEmptyResourceName,
 			Range:    exprRange,
 			Message:  "backdrop resource name cannot be empty",
 		})
@@ -1248,8 +1835,10 @@ func (s *Server) inspectSpxBackdropResourceRefAtExpr(result *compileResult, expr
 	if spxBackdropResource == nil {
 		result.addDiagnostics(exprDocumentURI, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeResourceNotFound,
 			Range:    exprRange,
 			Message:  fmt.Sprintf("backdrop resource %q not found", spxBackdropName),
+			Data:     result.spxResourceNotFoundDiagnosticData(expr, SpxBackdropResourceID{BackdropName: spxBackdropName}, GetSpxBackdropNameType(), nil),
 		})
 		return nil
 	}
@@ -1290,6 +1879,7 @@ func (s *Server) inspectSpxSpriteResourceRefAtExpr(result *compileResult, expr g
 		if spxSpriteName == "" {
 			result.addDiagnostics(exprDocumentURI, Diagnostic{
 				Severity: SeverityError,
+				Code:     DiagnosticCodeEmptyResourceName,
 				Range:    exprRange,
 				Message:  "sprite resource name cannot be empty",
 			})
@@ -1335,6 +1925,7 @@ func (s *Server) inspectSpxSpriteResourceRefAtExpr(result *compileResult, expr g
 		if spxSpriteName == "" {
 			result.addDiagnostics(exprDocumentURI, Diagnostic{
 				Severity: SeverityError,
+				Code:     DiagnosticCodeEmptyResourceName,
 				Range:    exprRange,
 				Message:  "sprite resource name cannot be empty",
 			})
@@ -1351,8 +1942,10 @@ func (s *Server) inspectSpxSpriteResourceRefAtExpr(result *compileResult, expr g
 	if spxSpriteResource == nil {
 		result.addDiagnostics(exprDocumentURI, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeResourceNotFound,
 			Range:    exprRange,
 			Message:  fmt.Sprintf("sprite resource %q not found", spxSpriteName),
+			Data:     result.spxResourceNotFoundDiagnosticData(expr, SpxSpriteResourceID{SpriteName: spxSpriteName}, GetSpxSpriteNameType(), nil),
 		})
 		return nil
 	}
@@ -1395,6 +1988,7 @@ func (s *Server) inspectSpxSpriteCostumeResourceRefAtExpr(result *compileResult,
 	if spxSpriteCostumeName == "" {
 		result.addDiagnostics(exprDocumentURI, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeEmptyResourceName,
 			Range:    exprRange,
 			Message:  "sprite costume resource name cannot be empty",
 		})
@@ -1410,8 +2004,10 @@ func (s *Server) inspectSpxSpriteCostumeResourceRefAtExpr(result *compileResult,
 	if spxSpriteCostumeResource == nil {
 		result.addDiagnostics(exprDocumentURI, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeResourceNotFound,
 			Range:    exprRange,
 			Message:  fmt.Sprintf("costume resource %q not found in sprite %q", spxSpriteCostumeName, spxSpriteResource.Name),
+			Data:     result.spxResourceNotFoundDiagnosticData(expr, SpxSpriteCostumeResourceID{SpriteName: spxSpriteResource.Name, CostumeName: spxSpriteCostumeName}, GetSpxSpriteCostumeNameType(), spxSpriteResource),
 		})
 		return nil
 	}
@@ -1454,6 +2050,7 @@ func (s *Server) inspectSpxSpriteAnimationResourceRefAtExpr(result *compileResul
 	if spxSpriteAnimationName == "" {
 		result.addDiagnostics(exprDocumentURI, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeEmptyResourceName,
 			Range:    exprRange,
 			Message:  "sprite animation resource name cannot be empty",
 		})
@@ -1469,8 +2066,10 @@ func (s *Server) inspectSpxSpriteAnimationResourceRefAtExpr(result *compileResul
 	if spxSpriteAnimationResource == nil {
 		result.addDiagnostics(exprDocumentURI, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeResourceNotFound,
 			Range:    exprRange,
 			Message:  fmt.Sprintf("animation resource %q not found in sprite %q", spxSpriteAnimationName, spxSpriteResource.Name),
+			Data:     result.spxResourceNotFoundDiagnosticData(expr, SpxSpriteAnimationResourceID{SpriteName: spxSpriteResource.Name, AnimationName: spxSpriteAnimationName}, GetSpxSpriteAnimationNameType(), spxSpriteResource),
 		})
 		return nil
 	}
@@ -1527,6 +2126,7 @@ func (s *Server) inspectSpxSoundResourceRefAtExpr(result *compileResult, expr go
 	if spxSoundName == "" {
 		result.addDiagnostics(exprDocumentURI, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeEmptyResourceName,
 			Range:    exprRange,
 			Message:  "sound resource name cannot be empty",
 		})
@@ -1542,8 +2142,10 @@ func (s *Server) inspectSpxSoundResourceRefAtExpr(result *compileResult, expr go
 	if spxSoundResource == nil {
 		result.addDiagnostics(exprDocumentURI, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeResourceNotFound,
 			Range:    exprRange,
 			Message:  fmt.Sprintf("sound resource %q not found", spxSoundName),
+			Data:     result.spxResourceNotFoundDiagnosticData(expr, SpxSoundResourceID{SoundName: spxSoundName}, GetSpxSoundNameType(), nil),
 		})
 		return nil
 	}
@@ -1586,6 +2188,7 @@ func (s *Server) inspectSpxWidgetResourceRefAtExpr(result *compileResult, expr g
 	if spxWidgetName == "" {
 		result.addDiagnostics(exprDocumentURI, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeEmptyResourceName,
 			Range:    exprRange,
 			Message:  "widget resource name cannot be empty",
 		})
@@ -1601,8 +2204,10 @@ func (s *Server) inspectSpxWidgetResourceRefAtExpr(result *compileResult, expr g
 	if spxWidgetResource == nil {
 		result.addDiagnostics(exprDocumentURI, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeResourceNotFound,
 			Range:    exprRange,
 			Message:  fmt.Sprintf("widget resource %q not found", spxWidgetName),
+			Data:     result.spxResourceNotFoundDiagnosticData(expr, SpxWidgetResourceID{WidgetName: spxWidgetName}, GetSpxWidgetNameType(), nil),
 		})
 		return nil
 	}
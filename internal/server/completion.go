@@ -1,6 +1,8 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"go/types"
 	"path"
@@ -18,8 +20,18 @@ import (
 )
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_completion
-func (s *Server) textDocumentCompletion(params *CompletionParams) ([]CompletionItem, error) {
-	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+func (s *Server) textDocumentCompletion(reqCtx context.Context, params *CompletionParams) ([]CompletionItem, error) {
+	if file, ok := s.spxResourceMetadataFileOf(params.TextDocument.URI); ok {
+		items := spxResourceMetadataCompletion(file, params.Position)
+		if !s.clientSupportsCompletionDocumentationMarkdown() {
+			for i := range items {
+				items[i].Documentation = plainTextCompletionDocumentation(items[i].Documentation)
+			}
+		}
+		return items, nil
+	}
+
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURIWithContext(reqCtx, params.TextDocument.URI)
 	if err != nil {
 		return nil, err
 	}
@@ -37,7 +49,6 @@ func (s *Server) textDocumentCompletion(params *CompletionParams) ([]CompletionI
 	}
 
 	ctx := &completionContext{
-		itemSet:        newCompletionItemSet(),
 		result:         result,
 		spxFile:        spxFile,
 		astFile:        astFile,
@@ -46,11 +57,151 @@ func (s *Server) textDocumentCompletion(params *CompletionParams) ([]CompletionI
 		pos:            pos,
 		innermostScope: innermostScope,
 	}
+	ctx.itemSet = newCompletionItemSet(newCompletionRanker(ctx))
 	ctx.analyze()
+	ctx.snippetSupport = s.clientSupportsCompletionSnippets()
+	ctx.itemSet.spxEventHandlerSnippets = ctx.snippetSupport && ctx.isLineStart()
+	if err := reqCtx.Err(); err != nil {
+		return nil, err
+	}
 	if err := ctx.collect(); err != nil {
 		return nil, fmt.Errorf("failed to collect completion items: %w", err)
 	}
-	return ctx.sortedItems(), nil
+	if err := reqCtx.Err(); err != nil {
+		return nil, err
+	}
+	items := ctx.sortedItems()
+	if maxItems := s.config().Completion.MaxItems; maxItems > 0 && len(items) > maxItems {
+		items = items[:maxItems]
+	}
+	if !s.clientSupportsCompletionDocumentationMarkdown() {
+		for i := range items {
+			items[i].Documentation = plainTextCompletionDocumentation(items[i].Documentation)
+		}
+	}
+
+	// Defer sending documentation and details to completionItem/resolve if
+	// the client supports it.
+	if s.clientSupportsCompletionItemResolve("documentation") {
+		resultID := s.storeCompletionResult(items)
+		items = slices.Clone(items)
+		resolveAdditionalTextEdits := s.clientSupportsCompletionItemResolve("additionalTextEdits")
+		for i := range items {
+			data := CompletionItemData{ResultID: resultID, Index: i}
+			if itemData, ok := items[i].Data.(*CompletionItemData); ok {
+				data.Definition = itemData.Definition
+			}
+			items[i].Detail = ""
+			items[i].Documentation = nil
+			if resolveAdditionalTextEdits {
+				items[i].AdditionalTextEdits = nil
+			}
+			items[i].Data = &data
+		}
+	}
+	return items, nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#completionItem_resolve
+func (s *Server) completionItemResolve(params *CompletionItem) (*CompletionItem, error) {
+	data, ok := completionItemDataOf(params)
+	if !ok || data.ResultID == "" {
+		return params, nil
+	}
+
+	s.lastCompletionMu.Lock()
+	lastCompletion := s.lastCompletion
+	s.lastCompletionMu.Unlock()
+	if lastCompletion.id != data.ResultID {
+		return params, nil // Outdated completion item.
+	}
+	i := data.Index
+	if i < 0 || i >= len(lastCompletion.items) || lastCompletion.items[i].Label != params.Label {
+		return params, nil
+	}
+
+	item := *params
+	item.Detail = lastCompletion.items[i].Detail
+	item.Documentation = lastCompletion.items[i].Documentation
+	item.AdditionalTextEdits = lastCompletion.items[i].AdditionalTextEdits
+	return &item, nil
+}
+
+// completionItemDataOf returns the [CompletionItemData] of the given item. It
+// returns false if the item has no data or the data is malformed.
+func completionItemDataOf(item *CompletionItem) (*CompletionItemData, bool) {
+	switch data := item.Data.(type) {
+	case nil:
+		return nil, false
+	case *CompletionItemData:
+		return data, true
+	}
+	raw, err := json.Marshal(item.Data)
+	if err != nil {
+		return nil, false
+	}
+	var data CompletionItemData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, false
+	}
+	return &data, true
+}
+
+// completionResult is a completion result recorded for lazily resolving its
+// items.
+type completionResult struct {
+	id    string
+	items []CompletionItem
+}
+
+// storeCompletionResult records the given items as the latest completion
+// result and returns its result ID.
+func (s *Server) storeCompletionResult(items []CompletionItem) string {
+	resultID := strconv.FormatUint(s.completionResultID.Add(1), 10)
+
+	s.lastCompletionMu.Lock()
+	defer s.lastCompletionMu.Unlock()
+	s.lastCompletion = completionResult{id: resultID, items: items}
+	return resultID
+}
+
+// clientSupportsCompletionSnippets reports whether the client supports snippets
+// as insert text of completion items.
+func (s *Server) clientSupportsCompletionSnippets() bool {
+	clientCapabilities := s.clientCapabilities.Load()
+	return clientCapabilities != nil && clientCapabilities.TextDocument.Completion.CompletionItem.SnippetSupport
+}
+
+// clientSupportsCompletionDocumentationMarkdown reports whether the client
+// supports markdown documentation of completion items.
+func (s *Server) clientSupportsCompletionDocumentationMarkdown() bool {
+	clientCapabilities := s.clientCapabilities.Load()
+	return clientCapabilities == nil || supportsMarkdown(clientCapabilities.TextDocument.Completion.CompletionItem.DocumentationFormat)
+}
+
+// plainTextCompletionDocumentation converts the given markdown documentation
+// of a completion item to plain text.
+func plainTextCompletionDocumentation(documentation *Or_CompletionItem_documentation) *Or_CompletionItem_documentation {
+	if documentation == nil {
+		return nil
+	}
+	content, ok := documentation.Value.(MarkupContent)
+	if !ok || content.Kind != Markdown {
+		return documentation
+	}
+	return &Or_CompletionItem_documentation{Value: markupContentFor(content.Value, false)}
+}
+
+// clientSupportsCompletionItemResolve reports whether the client supports
+// lazily resolving the given property of completion items via
+// completionItem/resolve.
+func (s *Server) clientSupportsCompletionItemResolve(property string) bool {
+	clientCapabilities := s.clientCapabilities.Load()
+	if clientCapabilities == nil {
+		return false
+	}
+	resolveSupport := clientCapabilities.TextDocument.Completion.CompletionItem.ResolveSupport
+	return resolveSupport != nil && slices.Contains(resolveSupport.Properties, property)
 }
 
 // completionKind represents different kinds of completion contexts.
@@ -90,13 +241,17 @@ type completionContext struct {
 	selectorExpr       *gopast.SelectorExpr
 	expectedTypes      []types.Type
 	expectedStructType *types.Struct
-	assignTargets      []*gopast.Ident
-	declValueSpec      *gopast.ValueSpec
-	switchTag          gopast.Expr
-	returnIndex        int
+	// expectedStructTypeName is the name of expectedStructType, or empty if
+	// it is an unnamed struct type.
+	expectedStructTypeName string
+	assignTargets          []*gopast.Ident
+	declValueSpec          *gopast.ValueSpec
+	switchTag              gopast.Expr
+	returnIndex            int
 
 	inStringLit       bool
 	inSpxEventHandler bool
+	snippetSupport    bool
 }
 
 // analyze analyzes the completion context to determine the kind of completion needed.
@@ -115,16 +270,20 @@ func (ctx *completionContext) analyze() {
 			ctx.kind = completionKindCall
 			ctx.enclosingNode = node
 		case *gopast.CompositeLit:
-			tv, ok := ctx.result.typeInfo.Types[node]
-			if !ok {
+			st, typeName := ctx.compositeLitStructType(node, path[i+1:])
+			if st == nil {
 				continue
 			}
-			st, ok := tv.Type.Underlying().(*types.Struct)
-			if !ok {
+			if fieldType := ctx.compositeLitFieldValueType(node, st); fieldType != nil {
+				// Completing the value of a field, e.g., `{Title: |}`.
+				ctx.kind = completionKindGeneral
+				ctx.expectedTypes = []types.Type{fieldType}
 				continue
 			}
 			ctx.kind = completionKindStructLit
+			ctx.enclosingNode = node
 			ctx.expectedStructType = st
+			ctx.expectedStructTypeName = typeName
 		case *gopast.AssignStmt:
 			if node.Tok != goptoken.ASSIGN && node.Tok != goptoken.DEFINE {
 				continue
@@ -199,6 +358,14 @@ func (ctx *completionContext) analyze() {
 			ctx.kind = completionKindUnknown
 		}
 	}
+	if ctx.kind == completionKindUnknown {
+		if callExpr := ctx.commandStyleCallExpr(); callExpr != nil {
+			// Completing the first argument of a command-style call, e.g.,
+			// `turn |`, which is parsed as an expression statement.
+			ctx.kind = completionKindCall
+			ctx.enclosingNode = callExpr
+		}
+	}
 	if ctx.kind == completionKindUnknown {
 		switch {
 		case ctx.isInComment():
@@ -214,6 +381,130 @@ func (ctx *completionContext) analyze() {
 	ctx.inSpxEventHandler = ctx.result.isInSpxEventHandler(ctx.pos)
 }
 
+// commandStyleCallExpr returns a call expression without arguments for the
+// function before the position on the same line if it is used as an
+// expression statement, e.g., `turn` in `turn |`. It returns nil otherwise.
+func (ctx *completionContext) commandStyleCallExpr() *gopast.CallExpr {
+	var callExpr *gopast.CallExpr
+	gopast.Inspect(ctx.astFile, func(node gopast.Node) bool {
+		if callExpr != nil || node == nil || node.Pos() > ctx.pos {
+			return false
+		}
+		exprStmt, ok := node.(*gopast.ExprStmt)
+		if !ok || exprStmt.End() >= ctx.pos {
+			// Shadow declarations, e.g., of the Main method, have no
+			// positions but contain the statements of the file.
+			return !node.End().IsValid() || node.End() >= ctx.pos
+		}
+		fun := exprStmt.X
+		if ctx.tokenFile.Line(fun.End()) != ctx.tokenFile.Line(ctx.pos) {
+			return false
+		}
+		switch fun.(type) {
+		case *gopast.Ident, *gopast.SelectorExpr:
+			if _, ok := ctx.result.typeInfo.TypeOf(fun).(*types.Signature); ok {
+				callExpr = &gopast.CallExpr{Fun: fun}
+			}
+		}
+		return false
+	})
+	return callExpr
+}
+
+// compositeLitStructType returns the struct type of the given composite
+// literal with the given enclosing path, along with the type name if it is a
+// named type. If the composite literal has not been type-checked, e.g.,
+// because it is incomplete, the type is inferred from the parameters of the
+// enclosing call. It returns nil if the type is not a struct.
+func (ctx *completionContext) compositeLitStructType(compositeLit *gopast.CompositeLit, path []gopast.Node) (*types.Struct, string) {
+	structTypeOf := func(typ types.Type) (*types.Struct, string) {
+		if typ == nil {
+			return nil, ""
+		}
+		typ = unwrapPointerType(typ)
+		st, ok := typ.Underlying().(*types.Struct)
+		if !ok {
+			return nil, ""
+		}
+		if named, ok := typ.(*types.Named); ok {
+			return st, named.Obj().Name()
+		}
+		return st, ""
+	}
+
+	if st, typeName := structTypeOf(ctx.result.typeInfo.TypeOf(compositeLit)); st != nil {
+		return st, typeName
+	}
+	if len(path) == 0 {
+		return nil, ""
+	}
+	callExpr, ok := path[0].(*gopast.CallExpr)
+	if !ok {
+		return nil, ""
+	}
+	argIndex := slices.Index(callExpr.Args, gopast.Expr(compositeLit))
+	funcIdent := funcIdentOf(callExpr.Fun)
+	if argIndex < 0 || funcIdent == nil {
+		return nil, ""
+	}
+	fun, ok := ctx.result.typeInfo.ObjectOf(funcIdent).(*types.Func)
+	if !ok {
+		return nil, ""
+	}
+	for _, overload := range ctx.result.funcOverloadsFor(funcIdent, fun) {
+		paramIndex := argIndex
+		if strings.HasPrefix(overload.Name(), util.GoptPrefix) {
+			paramIndex++ // Skip the receiver parameter of Go+ template methods.
+		}
+		if st, typeName := structTypeOf(paramTypeAt(overload.Type().(*types.Signature), paramIndex)); st != nil {
+			return st, typeName
+		}
+	}
+	return nil, ""
+}
+
+// paramTypeAt returns the type of the parameter of the given signature
+// corresponding to the argument at the given index, or nil if there is none.
+func paramTypeAt(sig *types.Signature, argIndex int) types.Type {
+	params := sig.Params()
+	if params.Len() == 0 {
+		return nil
+	}
+	if sig.Variadic() && argIndex >= params.Len()-1 {
+		if slice, ok := params.At(params.Len() - 1).Type().(*types.Slice); ok {
+			return slice.Elem()
+		}
+		return nil
+	}
+	if argIndex >= params.Len() {
+		return nil
+	}
+	return params.At(argIndex).Type()
+}
+
+// compositeLitFieldValueType returns the type of the field whose value is
+// being completed in the given composite literal of the given struct type. It
+// returns nil if the position is not in a field value.
+func (ctx *completionContext) compositeLitFieldValueType(compositeLit *gopast.CompositeLit, st *types.Struct) types.Type {
+	for _, elt := range compositeLit.Elts {
+		kv, ok := elt.(*gopast.KeyValueExpr)
+		if !ok || ctx.pos <= kv.Colon || ctx.pos > kv.End() {
+			continue
+		}
+		key, ok := kv.Key.(*gopast.Ident)
+		if !ok {
+			return nil
+		}
+		for i := range st.NumFields() {
+			if field := st.Field(i); field.Name() == key.Name {
+				return field.Type()
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
 // isInComment reports whether the position of the current completion context
 // is inside a comment.
 func (ctx *completionContext) isInComment() bool {
@@ -405,7 +696,7 @@ func (ctx *completionContext) collectGeneral() error {
 			ctx.itemSet.addSpxDefs(ctx.result.spxDefinitionsFor(obj, "")...)
 
 			isThis := name == "this"
-			isSpxFileMatch := ctx.spxFile == name+".spx" || (ctx.spxFile == ctx.result.mainSpxFile && name == "Game")
+			isSpxFileMatch := ctx.spxFile == name+ctx.result.classfileKind.Ext || (ctx.spxFile == ctx.result.mainSpxFile && name == "Game")
 			isMainScopeObj := isInMainScope && isSpxFileMatch
 			if !isThis && !isMainScopeObj {
 				continue
@@ -464,8 +755,10 @@ func (ctx *completionContext) collectGeneral() error {
 		})
 	}
 
+	ctx.collectUnimportedPkgs()
+
 	// Add other definitions.
-	ctx.itemSet.addSpxDefs(GetSpxPkgDefinitions()...)
+	ctx.itemSet.addSpxDefs(ctx.result.classfileKind.pkgDefinitions(ctx.result.module)...)
 	ctx.itemSet.addSpxDefs(GetBuiltinSpxDefinitions()...)
 	ctx.itemSet.addSpxDefs(GeneralSpxDefinitions...)
 	if ctx.innermostScope == ctx.astFileScope {
@@ -509,10 +802,13 @@ func (ctx *completionContext) collectDot() error {
 	}
 
 	if ident, ok := ctx.selectorExpr.X.(*gopast.Ident); ok {
-		if obj := ctx.result.typeInfo.ObjectOf(ident); obj != nil {
-			if pkgName, ok := obj.(*types.PkgName); ok {
-				return ctx.collectPackageMembers(pkgName.Imported())
-			}
+		obj := ctx.result.typeInfo.ObjectOf(ident)
+		if obj == nil {
+			ctx.collectUnimportedPkgMembers(ident.Name)
+			return nil
+		}
+		if pkgName, ok := obj.(*types.PkgName); ok {
+			return ctx.collectPackageMembers(pkgName.Imported())
 		}
 	}
 
@@ -520,6 +816,10 @@ func (ctx *completionContext) collectDot() error {
 	if !ok {
 		return nil
 	}
+	if tv.IsValue() && ctx.snippetSupport && ctx.isLineStart() {
+		ctx.collectPostfix(tv.Type)
+	}
+
 	typ := unwrapPointerType(tv.Type)
 	if named, ok := typ.(*types.Named); ok && isSpxPkgObject(named.Obj()) && named.Obj().Name() == "Sprite" {
 		typ = GetSpxSpriteImplType()
@@ -541,6 +841,65 @@ func (ctx *completionContext) collectDot() error {
 	return nil
 }
 
+// collectPostfix collects postfix completions that rewrite the receiver
+// expression of the selector into a statement, e.g., `cond.if` into
+// `if cond {}`.
+func (ctx *completionContext) collectPostfix(typ types.Type) {
+	if _, ok := typ.(*types.Tuple); ok {
+		return
+	}
+	x := ctx.selectorExpr.X
+	fileBase := goptoken.Pos(ctx.tokenFile.Base())
+	start, end := int(x.Pos()-fileBase), int(x.End()-fileBase)
+	if start < 0 || end > len(ctx.astFile.Code) || ctx.pos < x.End() {
+		return
+	}
+	xText := string(ctx.astFile.Code[start:end])
+	escapedXText := escapeSnippetText(xText)
+	editRange := Range{
+		Start: ctx.result.fromPosition(ctx.astFile, ctx.result.fset.Position(x.Pos())),
+		End:   ctx.result.fromPosition(ctx.astFile, ctx.result.fset.Position(ctx.pos)),
+	}
+	addPostfix := func(label, detail, snippet string) {
+		ctx.itemSet.add(CompletionItem{
+			Label:            label,
+			Kind:             SnippetCompletion,
+			Detail:           detail,
+			FilterText:       xText + "." + label,
+			InsertTextFormat: util.ToPtr(SnippetTextFormat),
+			TextEdit: &Or_CompletionItem_textEdit{Value: TextEdit{
+				Range:   editRange,
+				NewText: snippet,
+			}},
+		})
+	}
+
+	switch typ := typ.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case typ.Info()&types.IsBoolean != 0:
+			addPostfix("if", "if expr { ... }", "if "+escapedXText+" {\n\t$0\n}")
+			addPostfix("not", "!expr", "!"+escapedXText)
+		case typ.Info()&types.IsInteger != 0:
+			addPostfix("for", "for i <- 0:expr { ... }", "for ${1:i} <- 0:"+escapedXText+" {\n\t$0\n}")
+		}
+	case *types.Slice, *types.Array:
+		addPostfix("for", "for i, v <- expr { ... }", "for ${1:i}, ${2:v} <- "+escapedXText+" {\n\t$0\n}")
+		addPostfix("foreach", "for v <- expr { ... }", "for ${1:v} <- "+escapedXText+" {\n\t$0\n}")
+	case *types.Map:
+		addPostfix("for", "for k, v <- expr { ... }", "for ${1:k}, ${2:v} <- "+escapedXText+" {\n\t$0\n}")
+		addPostfix("foreach", "for v <- expr { ... }", "for ${1:v} <- "+escapedXText+" {\n\t$0\n}")
+	}
+	addPostfix("var", "name := expr", "${1:name} := "+escapedXText)
+	addPostfix("echo", "echo expr", "echo "+escapedXText)
+}
+
+// escapeSnippetText escapes the given text so that it is inserted literally in
+// a snippet.
+func escapeSnippetText(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `$`, `\$`, `}`, `\}`).Replace(text)
+}
+
 // collectPackageMembers collects members of a package.
 func (ctx *completionContext) collectPackageMembers(pkg *types.Package) error {
 	if pkg == nil {
@@ -593,6 +952,11 @@ func (ctx *completionContext) collectCall() error {
 			fun, _ = obj.(*types.Func)
 		}
 	}
+	if fun != nil && argIndex == 0 && isSpxPkgObject(fun) {
+		if funcName, _ := parseGopFuncName(fun.Name()); funcName == "broadcast" || funcName == "onMsg" {
+			ctx.collectSpxMessages()
+		}
+	}
 	if fun != nil {
 		funcOverloads := expandGopOverloadableFunc(fun)
 		if len(funcOverloads) > 0 {
@@ -618,6 +982,31 @@ func (ctx *completionContext) collectCall() error {
 	return ctx.collectGeneral()
 }
 
+// collectSpxMessages collects the spx messages broadcast or listened to in
+// the project, except the one being completed.
+func (ctx *completionContext) collectSpxMessages() {
+	broadcasts, listeners := ctx.result.spxMessageEvents()
+	seen := make(map[string]bool)
+	for _, event := range slices.Concat(broadcasts, listeners) {
+		if event.message == "" || seen[event.message] || (event.messageExpr.Pos() <= ctx.pos && ctx.pos <= event.messageExpr.End()) {
+			continue
+		}
+		seen[event.message] = true
+
+		name := event.message
+		if !ctx.inStringLit {
+			name = strconv.Quote(name)
+		}
+		ctx.itemSet.add(CompletionItem{
+			Label:            name,
+			Kind:             TextCompletion,
+			Detail:           "spx message",
+			InsertText:       name,
+			InsertTextFormat: util.ToPtr(PlainTextTextFormat),
+		})
+	}
+}
+
 // getCurrentArgIndex gets the current argument index in a function call.
 func (ctx *completionContext) getCurrentArgIndex(callExpr *gopast.CallExpr) int {
 	if len(callExpr.Args) == 0 {
@@ -655,61 +1044,28 @@ func (ctx *completionContext) collectTypeSpecific(typ types.Type) error {
 		return nil
 	}
 
-	var spxResourceIds []SpxResourceID
 	switch typ {
-	case GetSpxBackdropNameType():
-		spxResourceIds = slices.Grow(spxResourceIds, len(ctx.result.spxResourceSet.backdrops))
-		for spxBackdropName := range ctx.result.spxResourceSet.backdrops {
-			spxResourceIds = append(spxResourceIds, SpxBackdropResourceID{spxBackdropName})
-		}
 	case GetSpxSpriteType(), GetSpxSpriteImplType():
 		for spxSprite := range ctx.result.spxSpriteResourceAutoBindings {
 			if spxSprite.Type() == typ {
 				ctx.itemSet.addSpxDefs(ctx.result.spxDefinitionsFor(spxSprite, "Game")...)
 			}
 		}
-	case GetSpxSpriteNameType():
-		spxResourceIds = slices.Grow(spxResourceIds, len(ctx.result.spxResourceSet.sprites))
-		for spxSpriteName := range ctx.result.spxResourceSet.sprites {
-			spxResourceIds = append(spxResourceIds, SpxSpriteResourceID{spxSpriteName})
-		}
-	case GetSpxSpriteCostumeNameType():
-		expectedSpxSprite := ctx.getSpxSpriteResource()
-		for _, spxSprite := range ctx.result.spxResourceSet.sprites {
-			if expectedSpxSprite == nil || spxSprite == expectedSpxSprite {
-				spxResourceIds = slices.Grow(spxResourceIds, len(spxSprite.NormalCostumes))
-				for _, spxSpriteCostume := range spxSprite.NormalCostumes {
-					spxResourceIds = append(spxResourceIds, SpxSpriteCostumeResourceID{spxSprite.Name, spxSpriteCostume.Name})
-				}
-			}
-		}
-	case GetSpxSpriteAnimationNameType():
-		expectedSpxSprite := ctx.getSpxSpriteResource()
-		for _, spxSprite := range ctx.result.spxResourceSet.sprites {
-			if expectedSpxSprite == nil || spxSprite == expectedSpxSprite {
-				spxResourceIds = slices.Grow(spxResourceIds, len(spxSprite.Animations))
-				for _, spxSpriteAnimation := range spxSprite.Animations {
-					spxResourceIds = append(spxResourceIds, SpxSpriteAnimationResourceID{spxSprite.Name, spxSpriteAnimation.Name})
-				}
-			}
-		}
 	case GetSpxSoundType():
 		for spxSound := range ctx.result.spxSoundResourceAutoBindings {
 			if spxSound.Type() == typ {
 				ctx.itemSet.addSpxDefs(ctx.result.spxDefinitionsFor(spxSound, "Game")...)
 			}
 		}
-	case GetSpxSoundNameType():
-		spxResourceIds = slices.Grow(spxResourceIds, len(ctx.result.spxResourceSet.sounds))
-		for spxSoundName := range ctx.result.spxResourceSet.sounds {
-			spxResourceIds = append(spxResourceIds, SpxSoundResourceID{spxSoundName})
-		}
-	case GetSpxWidgetNameType():
-		spxResourceIds = slices.Grow(spxResourceIds, len(ctx.result.spxResourceSet.widgets))
-		for spxWidgetName := range ctx.result.spxResourceSet.widgets {
-			spxResourceIds = append(spxResourceIds, SpxWidgetResourceID{spxWidgetName})
+	}
+
+	var expectedSpxSprite *SpxSpriteResource
+	if ctx.kind == completionKindCall {
+		if callExpr, ok := ctx.enclosingNode.(*gopast.CallExpr); ok {
+			expectedSpxSprite = ctx.result.spxSpriteResourceForCall(ctx.spxFile, callExpr)
 		}
 	}
+	spxResourceIds := ctx.result.spxResourceIDsForNameType(typ, expectedSpxSprite)
 	for _, spxResourceId := range spxResourceIds {
 		name := spxResourceId.Name()
 		if !ctx.inStringLit {
@@ -723,46 +1079,18 @@ func (ctx *completionContext) collectTypeSpecific(typ types.Type) error {
 			InsertTextFormat: util.ToPtr(PlainTextTextFormat),
 		})
 	}
-	return nil
-}
-
-// getSpxSpriteResource returns a [SpxSpriteResource] for the current context.
-// It returns nil if no [SpxSpriteResource] can be inferred.
-func (ctx *completionContext) getSpxSpriteResource() *SpxSpriteResource {
-	if ctx.kind != completionKindCall {
-		return nil
-	}
-
-	callExpr, ok := ctx.enclosingNode.(*gopast.CallExpr)
-	if !ok {
-		return nil
-	}
-	sel, ok := callExpr.Fun.(*gopast.SelectorExpr)
-	if !ok {
-		if ctx.spxFile == "main.spx" {
-			return nil
+	for _, resource := range ctx.result.classfileResourcesForType(typ) {
+		name := resource.Name
+		if !ctx.inStringLit {
+			name = strconv.Quote(name)
 		}
-		return ctx.result.spxResourceSet.sprites[strings.TrimSuffix(ctx.spxFile, ".spx")]
-	}
-
-	ident, ok := sel.X.(*gopast.Ident)
-	if !ok {
-		return nil
-	}
-	obj := ctx.result.typeInfo.ObjectOf(ident)
-	if obj == nil {
-		return nil
-	}
-	named, ok := obj.Type().(*types.Named)
-	if !ok {
-		return nil
-	}
-
-	if named == GetSpxSpriteType() {
-		return ctx.result.spxResourceSet.sprites[ident.Name]
-	}
-	if slices.Contains(ctx.result.mainPkgSpriteTypes, named) {
-		return ctx.result.spxResourceSet.sprites[obj.Name()]
+		ctx.itemSet.add(CompletionItem{
+			Label:            name,
+			Kind:             TextCompletion,
+			Detail:           resource.File,
+			InsertText:       name,
+			InsertTextFormat: util.ToPtr(PlainTextTextFormat),
+		})
 	}
 	return nil
 }
@@ -775,9 +1103,12 @@ func (ctx *completionContext) collectStructLit() error {
 
 	seenFields := make(map[string]struct{})
 
-	// Collect already used fields.
+	// Collect already used fields, except the one being completed.
 	if composite, ok := ctx.enclosingNode.(*gopast.CompositeLit); ok {
 		for _, elem := range composite.Elts {
+			if elem.Pos() <= ctx.pos && ctx.pos <= elem.End() {
+				continue
+			}
 			if kv, ok := elem.(*gopast.KeyValueExpr); ok {
 				if ident, ok := kv.Key.(*gopast.Ident); ok {
 					seenFields[ident.Name] = struct{}{}
@@ -796,9 +1127,8 @@ func (ctx *completionContext) collectStructLit() error {
 			continue
 		}
 
-		selectorTypeName := ctx.result.selectorTypeNameForIdent(ctx.result.defIdentFor(field))
 		forceVar := ctx.result.isDefinedInFirstVarBlock(field)
-		spxDef := GetSpxDefinitionForVar(field, selectorTypeName, forceVar, ctx.result.mainPkgDoc)
+		spxDef := GetSpxDefinitionForVar(field, ctx.expectedStructTypeName, forceVar, ctx.result.mainPkgDoc)
 		spxDef.CompletionItemInsertText = field.Name() + ": ${1:}"
 		spxDef.CompletionItemInsertTextFormat = SnippetTextFormat
 		ctx.itemSet.addSpxDefs(spxDef)
@@ -884,31 +1214,33 @@ var completionItemKindPriority = map[CompletionItemKind]int{
 	InterfaceCompletion: 7,
 	ModuleCompletion:    8,
 	KeywordCompletion:   9,
+	SnippetCompletion:   10,
 }
 
-// sortedItems returns the sorted items.
+// sortedItems returns the items ranked by the completion context.
 func (ctx *completionContext) sortedItems() []CompletionItem {
-	slices.SortStableFunc(ctx.itemSet.items, func(a, b CompletionItem) int {
-		if p1, p2 := completionItemKindPriority[a.Kind], completionItemKindPriority[b.Kind]; p1 != p2 {
-			return p1 - p2
-		}
-		return strings.Compare(a.Label, b.Label)
-	})
-	return ctx.itemSet.items
+	return ctx.itemSet.ranker.rankedItems(ctx.itemSet.items, ctx.itemSet.scores)
 }
 
 // completionItemSet is a set of completion items.
 type completionItemSet struct {
 	items                         []CompletionItem
+	scores                        []int
+	ranker                        *completionRanker
 	seenSpxDefs                   map[string]struct{}
 	supportedKinds                map[CompletionItemKind]struct{}
 	isCompatibleWithExpectedTypes func(typ types.Type) bool
+
+	// spxEventHandlerSnippets indicates whether spx event handlers should be
+	// completed as snippets expanding to full handler skeletons.
+	spxEventHandlerSnippets bool
 }
 
 // newCompletionItemSet creates a new [completionItemSet].
-func newCompletionItemSet() *completionItemSet {
+func newCompletionItemSet(ranker *completionRanker) *completionItemSet {
 	return &completionItemSet{
 		items:       []CompletionItem{},
+		ranker:      ranker,
 		seenSpxDefs: make(map[string]struct{}),
 	}
 }
@@ -930,6 +1262,9 @@ func (s *completionItemSet) setExpectedTypes(expectedTypes []types.Type) {
 	if len(expectedTypes) == 0 {
 		return
 	}
+	if s.ranker != nil {
+		s.ranker.expectedTypes = expectedTypes
+	}
 
 	s.isCompatibleWithExpectedTypes = func(typ types.Type) bool {
 		for _, expectedType := range expectedTypes {
@@ -944,17 +1279,38 @@ func (s *completionItemSet) setExpectedTypes(expectedTypes []types.Type) {
 // add adds items to the set.
 func (s *completionItemSet) add(items ...CompletionItem) {
 	for _, item := range items {
-		if s.supportedKinds != nil {
-			if _, ok := s.supportedKinds[item.Kind]; !ok {
-				continue
-			}
+		s.addWithTypeHint(item, nil)
+	}
+}
+
+// addWithTypeHint adds an item with the given type hint, which is used to rank
+// the item and may be nil.
+func (s *completionItemSet) addWithTypeHint(item CompletionItem, typeHint types.Type) {
+	if s.supportedKinds != nil {
+		if _, ok := s.supportedKinds[item.Kind]; !ok {
+			return
 		}
-		s.items = append(s.items, item)
 	}
+	var score int
+	if s.ranker != nil {
+		var ok bool
+		score, ok = s.ranker.score(item, typeHint)
+		if !ok {
+			return
+		}
+	}
+	s.items = append(s.items, item)
+	s.scores = append(s.scores, score)
 }
 
 // addSpxDefs adds spx definitions to the set.
 func (s *completionItemSet) addSpxDefs(spxDefs ...SpxDefinition) {
+	s.addSpxDefsWithAdditionalTextEdits(nil, spxDefs...)
+}
+
+// addSpxDefsWithAdditionalTextEdits adds spx definitions to the set, whose
+// completion items apply the given additional text edits when accepted.
+func (s *completionItemSet) addSpxDefsWithAdditionalTextEdits(additionalTextEdits []TextEdit, spxDefs ...SpxDefinition) {
 	for _, spxDef := range spxDefs {
 		if s.isCompatibleWithExpectedTypes != nil && !s.isCompatibleWithExpectedTypes(spxDef.TypeHint) {
 			continue
@@ -966,6 +1322,94 @@ func (s *completionItemSet) addSpxDefs(spxDefs ...SpxDefinition) {
 		}
 		s.seenSpxDefs[spxDefIDKey] = struct{}{}
 
-		s.add(spxDef.CompletionItem())
+		if s.spxEventHandlerSnippets {
+			if snippet, ok := makeSpxEventHandlerSnippet(spxDef); ok {
+				spxDef.CompletionItemInsertText = snippet
+				spxDef.CompletionItemInsertTextFormat = SnippetTextFormat
+			}
+		}
+		item := spxDef.CompletionItem()
+		item.AdditionalTextEdits = additionalTextEdits
+		s.addWithTypeHint(item, spxDef.TypeHint)
+	}
+}
+
+// makeSpxEventHandlerSnippet makes a snippet that expands to the full handler
+// skeleton for the given spx event handler definition, e.g.,
+// `onMsg "${1:msg}", => {\n\t$0\n}`. It returns false if the definition is
+// not an spx event handler.
+func makeSpxEventHandlerSnippet(spxDef SpxDefinition) (string, bool) {
+	if spxDef.ID.Package == nil || *spxDef.ID.Package != GetSpxPkg().Path() ||
+		!isSpxEventHandlerFuncName(spxDef.CompletionItemLabel) {
+		return "", false
+	}
+	sig, ok := spxDef.TypeHint.(*types.Signature)
+	if !ok || sig.Variadic() || sig.Params().Len() == 0 {
+		return "", false
+	}
+	params := sig.Params()
+	handlerSig, ok := params.At(params.Len() - 1).Type().Underlying().(*types.Signature)
+	if !ok {
+		return "", false
+	}
+
+	var (
+		sb         strings.Builder
+		tabStop    int
+		addTabStop = func(name string, quoted bool) {
+			tabStop++
+			if quoted {
+				fmt.Fprintf(&sb, `"${%d:%s}"`, tabStop, name)
+			} else {
+				fmt.Fprintf(&sb, "${%d:%s}", tabStop, name)
+			}
+		}
+	)
+	sb.WriteString(spxDef.CompletionItemLabel)
+	for i := range params.Len() - 1 {
+		param := params.At(i)
+		if i == 0 {
+			sb.WriteString(" ")
+		}
+		isString := types.Identical(param.Type().Underlying(), types.Typ[types.String])
+		addTabStop(snippetPlaceholderName(param, i), isString)
+		sb.WriteString(", ")
+	}
+	if params.Len() == 1 {
+		sb.WriteString(" ")
+	}
+
+	handlerParams := handlerSig.Params()
+	if handlerParams.Len() > 1 {
+		sb.WriteString("(")
+	}
+	for i := range handlerParams.Len() {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		addTabStop(snippetPlaceholderName(handlerParams.At(i), i), false)
+	}
+	if handlerParams.Len() > 1 {
+		sb.WriteString(")")
+	}
+	if handlerParams.Len() > 0 {
+		sb.WriteString(" ")
+	}
+	sb.WriteString("=> {\n\t$0\n}")
+	return sb.String(), true
+}
+
+// snippetPlaceholderName returns the placeholder name of the given parameter
+// used in snippets.
+func snippetPlaceholderName(param *types.Var, index int) string {
+	if name := param.Name(); name != "" && name != "_" {
+		return name
+	}
+	switch typ := unwrapPointerType(param.Type()).(type) {
+	case *types.Alias:
+		return toLowerCamelCase(typ.Obj().Name())
+	case *types.Named:
+		return toLowerCamelCase(typ.Obj().Name())
 	}
+	return fmt.Sprintf("arg%d", index+1)
 }
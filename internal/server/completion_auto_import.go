@@ -0,0 +1,146 @@
+package server
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/internal"
+	"github.com/goplus/goxlsw/internal/pkgdata"
+	"github.com/goplus/goxlsw/internal/pkgdoc"
+)
+
+// autoImportablePkg is a package that can be imported automatically when its
+// members are completed.
+type autoImportablePkg struct {
+	path string
+	doc  *pkgdoc.PkgDoc
+}
+
+// autoImportablePkgs returns the packages that can be imported automatically,
+// i.e., all packages in pkgdata except the spx package and the Go+ builtin
+// packages, which are always available without imports.
+var autoImportablePkgs = sync.OnceValue(func() []autoImportablePkg {
+	pkgPaths, err := pkgdata.ListPkgs()
+	if err != nil {
+		return nil
+	}
+	var pkgs []autoImportablePkg
+	for _, pkgPath := range pkgPaths {
+		if pkgPath == GetSpxPkg().Path() || pkgPath == "github.com/goplus/gop/builtin" || strings.HasPrefix(pkgPath, "github.com/goplus/gop/builtin/") {
+			continue
+		}
+		pkgDoc, err := pkgdata.GetPkgDoc(pkgPath)
+		if err != nil || pkgDoc.Name == "" {
+			continue
+		}
+		pkgs = append(pkgs, autoImportablePkg{path: pkgPath, doc: pkgDoc})
+	}
+	return pkgs
+})
+
+// unimportedPkgs returns the auto-importable packages that are not imported
+// by the current file, and whose names are not taken in the current scope.
+func (ctx *completionContext) unimportedPkgs() []autoImportablePkg {
+	imported := make(map[string]struct{}, len(ctx.astFile.Imports))
+	for _, importSpec := range ctx.astFile.Imports {
+		if importSpec.Path == nil {
+			continue
+		}
+		if pkgPath, err := strconv.Unquote(importSpec.Path.Value); err == nil {
+			imported[pkgPath] = struct{}{}
+		}
+	}
+	var pkgs []autoImportablePkg
+	for _, pkg := range autoImportablePkgs() {
+		if _, ok := imported[pkg.path]; ok {
+			continue
+		}
+		if _, obj := ctx.innermostScope.LookupParent(pkg.doc.Name, ctx.pos); obj != nil {
+			continue
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	return pkgs
+}
+
+// collectUnimportedPkgs collects completions of the packages not imported yet,
+// which add the imports when accepted. They are only collected once a prefix
+// of the package name is typed, to avoid flooding the completion list.
+func (ctx *completionContext) collectUnimportedPkgs() {
+	if ctx.identPrefix() == "" {
+		return
+	}
+	for _, pkg := range ctx.unimportedPkgs() {
+		ctx.itemSet.addSpxDefsWithAdditionalTextEdits([]TextEdit{ctx.result.importPkgEdit(ctx.astFile, pkg.path)}, SpxDefinition{
+			ID: SpxDefinitionIdentifier{
+				Package: &pkg.path,
+			},
+			Overview: "import " + strconv.Quote(pkg.path),
+			Detail:   pkg.doc.Doc,
+
+			CompletionItemLabel:            pkg.doc.Name,
+			CompletionItemKind:             ModuleCompletion,
+			CompletionItemInsertText:       pkg.doc.Name,
+			CompletionItemInsertTextFormat: PlainTextTextFormat,
+		})
+	}
+}
+
+// collectUnimportedPkgMembers collects completions of the members of the
+// packages not imported yet with the given name, e.g., `strings` in
+// `strings.|`, which add the imports when accepted.
+func (ctx *completionContext) collectUnimportedPkgMembers(pkgName string) {
+	for _, pkg := range ctx.unimportedPkgs() {
+		if pkg.doc.Name != pkgName {
+			continue
+		}
+		typesPkg, err := internal.Importer.Import(pkg.path)
+		if err != nil {
+			continue
+		}
+		edits := []TextEdit{ctx.result.importPkgEdit(ctx.astFile, pkg.path)}
+		ctx.itemSet.addSpxDefsWithAdditionalTextEdits(edits, GetSpxDefinitionsForPkg(typesPkg, pkg.doc)...)
+	}
+}
+
+// importPkgEdit returns a text edit that imports the package with the given
+// path in the given file. The import is added to the last import declaration,
+// or a new import declaration is added before the first declaration.
+func (r *compileResult) importPkgEdit(astFile *gopast.File, pkgPath string) TextEdit {
+	quotedPkgPath := strconv.Quote(pkgPath)
+	var lastImportDecl *gopast.GenDecl
+	for _, decl := range astFile.Decls {
+		if genDecl, ok := decl.(*gopast.GenDecl); ok && genDecl.Tok == goptoken.IMPORT {
+			lastImportDecl = genDecl
+		}
+	}
+	if lastImportDecl != nil {
+		if lastImportDecl.Rparen.IsValid() && r.fset.Position(lastImportDecl.Lparen).Line != r.fset.Position(lastImportDecl.Rparen).Line {
+			pos := Position{Line: uint32(r.fset.Position(lastImportDecl.Rparen).Line - 1)}
+			return TextEdit{
+				Range:   Range{Start: pos, End: pos},
+				NewText: "\t" + quotedPkgPath + "\n",
+			}
+		}
+		pos := Position{Line: uint32(r.fset.Position(lastImportDecl.End()).Line)}
+		return TextEdit{
+			Range:   Range{Start: pos, End: pos},
+			NewText: "import " + quotedPkgPath + "\n",
+		}
+	}
+
+	var pos Position
+	if i := slices.IndexFunc(astFile.Decls, func(decl gopast.Decl) bool {
+		return topLevelDeclStartOf(decl).IsValid()
+	}); i >= 0 {
+		pos = Position{Line: uint32(r.fset.Position(topLevelDeclStartOf(astFile.Decls[i])).Line - 1)}
+	}
+	return TextEdit{
+		Range:   Range{Start: pos, End: pos},
+		NewText: "import " + quotedPkgPath + "\n\n",
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentCompletionAutoImport(t *testing.T) {
+	completionItemsAt := func(t *testing.T, s *Server, position Position) []CompletionItem {
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     position,
+			},
+		})
+		require.NoError(t, err)
+		return items
+	}
+	completionItemFor := func(t *testing.T, items []CompletionItem, label string) CompletionItem {
+		i := slices.IndexFunc(items, func(item CompletionItem) bool {
+			return item.Label == label
+		})
+		require.GreaterOrEqual(t, i, 0, "completion item %q not found", label)
+		return items[i]
+	}
+
+	t.Run("UnimportedPkgMember", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+strings.
+`),
+		}), nil)
+
+		items := completionItemsAt(t, s, Position{Line: 1, Character: 8})
+		item := completionItemFor(t, items, "toUpper")
+		assert.Equal(t, []TextEdit{{
+			Range: Range{
+				Start: Position{Line: 1, Character: 0},
+				End:   Position{Line: 1, Character: 0},
+			},
+			NewText: "import \"strings\"\n\n",
+		}}, item.AdditionalTextEdits)
+	})
+
+	t.Run("UnimportedPkgMemberWithImportDecl", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+import "fmt"
+
+fmt.println "hi"
+strings.
+`),
+		}), nil)
+
+		items := completionItemsAt(t, s, Position{Line: 4, Character: 8})
+		item := completionItemFor(t, items, "toUpper")
+		assert.Equal(t, []TextEdit{{
+			Range: Range{
+				Start: Position{Line: 2, Character: 0},
+				End:   Position{Line: 2, Character: 0},
+			},
+			NewText: "import \"strings\"\n",
+		}}, item.AdditionalTextEdits)
+	})
+
+	t.Run("UnimportedPkgMemberWithImportGroup", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+import (
+	"fmt"
+)
+
+fmt.println "hi"
+strings.
+`),
+		}), nil)
+
+		items := completionItemsAt(t, s, Position{Line: 6, Character: 8})
+		item := completionItemFor(t, items, "toUpper")
+		assert.Equal(t, []TextEdit{{
+			Range: Range{
+				Start: Position{Line: 3, Character: 0},
+				End:   Position{Line: 3, Character: 0},
+			},
+			NewText: "\t\"strings\"\n",
+		}}, item.AdditionalTextEdits)
+	})
+
+	t.Run("UnimportedPkgName", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+import "fmt"
+
+fmt.println "hi"
+strin
+`),
+		}), nil)
+
+		items := completionItemsAt(t, s, Position{Line: 4, Character: 5})
+		item := completionItemFor(t, items, "strings")
+		assert.Equal(t, ModuleCompletion, item.Kind)
+		assert.Equal(t, `import "strings"`, item.Detail)
+		assert.Equal(t, []TextEdit{{
+			Range: Range{
+				Start: Position{Line: 2, Character: 0},
+				End:   Position{Line: 2, Character: 0},
+			},
+			NewText: "import \"strings\"\n",
+		}}, item.AdditionalTextEdits)
+	})
+
+	t.Run("WithoutPrefix", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+onStart => {
+
+}
+`),
+		}), nil)
+
+		items := completionItemsAt(t, s, Position{Line: 2, Character: 0})
+		assert.False(t, containsCompletionItemLabel(items, "strings"))
+	})
+
+	t.Run("WithResolveSupport", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+strings.
+`),
+		}), nil)
+		_, err := s.initialize(&InitializeParams{
+			XInitializeParams: XInitializeParams{
+				Capabilities: ClientCapabilities{
+					TextDocument: TextDocumentClientCapabilities{
+						Completion: CompletionClientCapabilities{
+							CompletionItem: ClientCompletionItemOptions{
+								ResolveSupport: &ClientCompletionItemResolveOptions{
+									Properties: []string{"documentation", "detail"},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		items := completionItemsAt(t, s, Position{Line: 1, Character: 8})
+		item := completionItemFor(t, items, "toUpper")
+		assert.NotEmpty(t, item.AdditionalTextEdits)
+	})
+}
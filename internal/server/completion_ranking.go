@@ -0,0 +1,169 @@
+package server
+
+import (
+	"fmt"
+	"go/types"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// Scores of the signals used to rank completion items in addition to the
+// fuzzy match score of [fuzzyMatch]. Items with higher scores are ranked
+// first.
+const (
+	// completionScoreExpectedType is the score of items whose type is
+	// identical to one of the expected types, e.g., of the current call slot.
+	completionScoreExpectedType = 8
+
+	// completionScoreSpriteMember is the score of sprite members when
+	// completing in a sprite file.
+	completionScoreSpriteMember = 4
+
+	// completionScoreUsedNearby is the score of identifiers used within
+	// completionNearbyLines lines around the completion position.
+	completionScoreUsedNearby = 3
+
+	// completionScoreUsedInFile is the score of identifiers used elsewhere in
+	// the current file.
+	completionScoreUsedInFile = 1
+)
+
+// completionNearbyLines is the number of lines around the completion position
+// within which identifier uses are considered recent.
+const completionNearbyLines = 10
+
+// completionRanker scores completion items by context.
+type completionRanker struct {
+	// prefix is the partial identifier typed before the completion position.
+	// Items not fuzzy matching it are dropped.
+	prefix string
+
+	// spriteTypeName is the name of the sprite type when completing in a
+	// sprite file, or empty otherwise.
+	spriteTypeName string
+
+	// expectedTypes are the types expected at the completion position.
+	expectedTypes []types.Type
+
+	// identLineDistances maps names of identifiers used in the current file
+	// to the minimum line distance between their uses and the completion
+	// position.
+	identLineDistances map[string]int
+}
+
+// newCompletionRanker creates a new [completionRanker] for the given
+// completion context.
+func newCompletionRanker(ctx *completionContext) *completionRanker {
+	r := &completionRanker{
+		prefix:             ctx.identPrefix(),
+		identLineDistances: make(map[string]int),
+	}
+	if ctx.spxFile != ctx.result.mainSpxFile {
+		r.spriteTypeName = strings.TrimSuffix(ctx.spxFile, ".spx")
+	}
+
+	fileBase := goptoken.Pos(ctx.tokenFile.Base())
+	fileEnd := fileBase + goptoken.Pos(ctx.tokenFile.Size())
+	line := ctx.tokenFile.Line(ctx.pos)
+	recordIdent := func(ident *gopast.Ident) {
+		if ident.Pos() < fileBase || ident.Pos() >= fileEnd {
+			return
+		}
+		if ident.Pos() <= ctx.pos && ctx.pos <= ident.End() {
+			return // Skip the identifier being completed.
+		}
+		distance := max(line-ctx.tokenFile.Line(ident.Pos()), ctx.tokenFile.Line(ident.Pos())-line)
+		if d, ok := r.identLineDistances[ident.Name]; !ok || distance < d {
+			r.identLineDistances[ident.Name] = distance
+		}
+	}
+	for ident := range ctx.result.typeInfo.Uses {
+		recordIdent(ident)
+	}
+	return r
+}
+
+// score returns the score of the given item with the given type hint, which
+// may be nil. It returns false if the item does not match the prefix.
+func (r *completionRanker) score(item CompletionItem, typeHint types.Type) (int, bool) {
+	filterText := item.FilterText
+	if filterText == "" {
+		filterText = item.Label
+	}
+	score, ok := fuzzyMatch(r.prefix, filterText)
+	if !ok {
+		return 0, false
+	}
+	if typeHint != nil && slices.ContainsFunc(r.expectedTypes, func(expectedType types.Type) bool {
+		return types.Identical(typeHint, expectedType)
+	}) {
+		score += completionScoreExpectedType
+	}
+	if r.spriteTypeName != "" {
+		if data, ok := item.Data.(*CompletionItemData); ok && data.Definition != nil && data.Definition.Name != nil {
+			name := *data.Definition.Name
+			if strings.HasPrefix(name, "Sprite.") || strings.HasPrefix(name, r.spriteTypeName+".") {
+				score += completionScoreSpriteMember
+			}
+		}
+	}
+	if distance, ok := r.identLineDistances[item.Label]; ok {
+		if distance <= completionNearbyLines {
+			score += completionScoreUsedNearby
+		} else {
+			score += completionScoreUsedInFile
+		}
+	}
+	return score, true
+}
+
+// identPrefix returns the partial identifier before the completion position,
+// e.g., "tch" in "onTch|".
+func (ctx *completionContext) identPrefix() string {
+	offset := ctx.tokenFile.Offset(ctx.pos)
+	if offset < 0 || offset > len(ctx.astFile.Code) {
+		return ""
+	}
+	code := string(ctx.astFile.Code[:offset])
+	start := len(code)
+	for start > 0 {
+		r, size := utf8.DecodeLastRuneInString(code[:start])
+		if !isIdentRune(r) {
+			break
+		}
+		start -= size
+	}
+	return code[start:]
+}
+
+// rankedItems returns the items sorted by their scores, falling back to kind
+// priority and label. The sort text of each item is set to its rank so that
+// clients keep the order.
+func (r *completionRanker) rankedItems(items []CompletionItem, scores []int) []CompletionItem {
+	indexes := make([]int, len(items))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	slices.SortStableFunc(indexes, func(i, j int) int {
+		if scores[i] != scores[j] {
+			return scores[j] - scores[i]
+		}
+		a, b := items[i], items[j]
+		if p1, p2 := completionItemKindPriority[a.Kind], completionItemKindPriority[b.Kind]; p1 != p2 {
+			return p1 - p2
+		}
+		return strings.Compare(a.Label, b.Label)
+	})
+
+	ranked := make([]CompletionItem, 0, len(items))
+	for rank, i := range indexes {
+		item := items[i]
+		item.SortText = fmt.Sprintf("%05d", rank)
+		ranked = append(ranked, item)
+	}
+	return ranked
+}
@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentCompletionRanking(t *testing.T) {
+	t.Run("ExpectedType", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	numA int
+	numZ any
+)
+
+func show(v any) {
+}
+
+onStart => {
+	show num
+}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 10, Character: 9},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, items)
+		assert.Less(t, completionItemIndex(items, "numZ"), completionItemIndex(items, "numA"))
+	})
+
+	t.Run("SpriteMember", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 2, Character: 0},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, items)
+		assert.Less(t, completionItemIndex(items, "turn"), completionItemIndex(items, "println"))
+		assert.Less(t, completionItemIndex(items, "turn"), completionItemIndex(items, "MySprite"))
+	})
+
+	t.Run("UsedNearby", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	aaa int
+	bbb int
+)
+
+bbb = 1
+
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 7, Character: 0},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, items)
+		assert.Less(t, completionItemIndex(items, "bbb"), completionItemIndex(items, "aaa"))
+	})
+
+	t.Run("FuzzyMatch", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+`),
+			"MySprite.spx": []byte(`
+onTchSt
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 1, Character: 7},
+			},
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, items)
+		assert.Equal(t, "onTouchStart", items[0].Label)
+		assert.False(t, containsCompletionItemLabel(items, "println"))
+		assert.False(t, containsCompletionItemLabel(items, "onStart"))
+	})
+
+	t.Run("SortText", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	aaa int
+)
+
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 4, Character: 0},
+			},
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, items)
+		assert.True(t, slices.IsSortedFunc(items, func(a, b CompletionItem) int {
+			return strings.Compare(a.SortText, b.SortText)
+		}))
+	})
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	for _, tt := range []struct {
+		pattern   string
+		candidate string
+		wantOK    bool
+	}{
+		{"", "onStart", true},
+		{"tchst", "onTouchStart", true},
+		{"stcos", "setCostume", true},
+		{"STCOS", "setCostume", true},
+		{"onst", "onStart", true},
+		{"tsc", "onTouchStart", false},
+		{"onStarts", "onStart", false},
+	} {
+		_, ok := fuzzyMatch(tt.pattern, tt.candidate)
+		assert.Equal(t, tt.wantOK, ok, "fuzzyMatch(%q, %q)", tt.pattern, tt.candidate)
+	}
+
+	prefixScore, _ := fuzzyMatch("on", "onStart")
+	wordStartScore, _ := fuzzyMatch("st", "onStart")
+	scatteredScore, _ := fuzzyMatch("nt", "onStart")
+	assert.Greater(t, prefixScore, scatteredScore)
+	assert.Greater(t, wordStartScore, scatteredScore)
+
+	humpsScore, _ := fuzzyMatch("tch", "onTouchStart")
+	catchScore, _ := fuzzyMatch("tch", "catch")
+	assert.Greater(t, humpsScore, catchScore)
+}
+
+func completionItemIndex(items []CompletionItem, label string) int {
+	i := slices.IndexFunc(items, func(item CompletionItem) bool {
+		return item.Label == label
+	})
+	if i < 0 {
+		return len(items)
+	}
+	return i
+}
@@ -1,7 +1,10 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"slices"
+	"strings"
 	"testing"
 
 	"github.com/goplus/goxlsw/internal/util"
@@ -29,7 +32,7 @@ onStart => {
 			"assets/sprites/MySprite/index.json": []byte(`{}`),
 		}), nil)
 
-		emptyLineItems, err := s.textDocumentCompletion(&CompletionParams{
+		emptyLineItems, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 4, Character: 0},
@@ -44,7 +47,7 @@ onStart => {
 			Name:    util.ToPtr("MySprite"),
 		}))
 
-		assert.Contains(t, emptyLineItems, SpxDefinition{
+		assert.Contains(t, withoutCompletionItemSortTexts(emptyLineItems), SpxDefinition{
 			ID: SpxDefinitionIdentifier{
 				Package: util.ToPtr("github.com/goplus/spx"),
 				Name:    util.ToPtr("Game.getWidget"),
@@ -57,7 +60,7 @@ onStart => {
 			CompletionItemInsertTextFormat: PlainTextTextFormat,
 		}.CompletionItem())
 
-		mySpriteDotItems, err := s.textDocumentCompletion(&CompletionParams{
+		mySpriteDotItems, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 5, Character: 9},
@@ -104,7 +107,7 @@ run "assets", {Title: "My Game"}
 `),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 1},
@@ -130,7 +133,7 @@ run "a
 `),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 6},
@@ -149,7 +152,7 @@ run "assets", {Title: "My Game"}
 `),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 11},
@@ -167,7 +170,7 @@ run "a
 `),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 6},
@@ -185,7 +188,7 @@ import "f
 `),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 9},
@@ -205,7 +208,7 @@ import (
 `),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 3},
@@ -225,7 +228,7 @@ fmt.
 `),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 4},
@@ -248,7 +251,7 @@ run "assets", {Title: "My Game"}
 `),
 		}), nil)
 
-		items1, err := s.textDocumentCompletion(&CompletionParams{
+		items1, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 1},
@@ -259,7 +262,7 @@ run "assets", {Title: "My Game"}
 		assert.NotEmpty(t, items1)
 		assert.True(t, containsCompletionItemLabel(items1, "len"))
 
-		items2, err := s.textDocumentCompletion(&CompletionParams{
+		items2, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 12},
@@ -269,7 +272,7 @@ run "assets", {Title: "My Game"}
 		require.NotNil(t, items2)
 		assert.Empty(t, items2)
 
-		items3, err := s.textDocumentCompletion(&CompletionParams{
+		items3, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 3, Character: 1},
@@ -296,7 +299,7 @@ run "assets", {Title: "My Game"}
 			"assets/sprites/MySprite/index.json": []byte(`{}`),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 3, Character: 8},
@@ -327,7 +330,7 @@ run "assets", {Title: "My Game"}
 			"assets/sprites/MySprite/index.json": []byte(`{}`),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 22},
@@ -349,7 +352,7 @@ run "assets", {Title: "My Game"}
 			"assets/sounds/recording/index.json": []byte(`{}`),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 1, Character: 7},
@@ -375,7 +378,7 @@ run "assets", {Title: "My Game"}
 			"assets/sounds/recording/index.json": []byte(`{}`),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 5, Character: 6},
@@ -402,7 +405,7 @@ onClick => {
 			"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume"}]}`),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 				Position:     Position{Line: 2, Character: 14},
@@ -430,7 +433,7 @@ run "assets", {Title: "My Game"}
 			"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume"}]}`),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 5, Character: 22},
@@ -463,7 +466,7 @@ onClick => {
 			"assets/sprites/Sprite2/index.json": []byte(`{"costumes":[{"name":"Sprite2Costume"}]}`),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///Sprite1.spx"},
 				Position:     Position{Line: 2, Character: 22},
@@ -484,7 +487,7 @@ onClick => {
 `),
 		}), nil)
 
-		items, err := s.textDocumentCompletion(&CompletionParams{
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 3},
@@ -512,7 +515,7 @@ onClick => {
 			"assets/sprites/MySprite/index.json": []byte(`{}`),
 		}), nil)
 
-		items1, err := s.textDocumentCompletion(&CompletionParams{
+		items1, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 4, Character: 14},
@@ -523,7 +526,7 @@ onClick => {
 		assert.NotEmpty(t, items1)
 		assert.True(t, containsCompletionItemLabel(items1, "setCostume"))
 
-		items2, err := s.textDocumentCompletion(&CompletionParams{
+		items2, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 				Position:     Position{Line: 2, Character: 15},
@@ -551,7 +554,7 @@ onClick => {
 			"assets/sprites/MySprite/index.json": []byte(`{}`),
 		}), nil)
 
-		items1, err := s.textDocumentCompletion(&CompletionParams{
+		items1, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 				Position:     Position{Line: 2, Character: 9},
@@ -562,7 +565,7 @@ onClick => {
 		assert.NotEmpty(t, items1)
 		assert.True(t, containsCompletionItemLabel(items1, "int128"))
 
-		items2, err := s.textDocumentCompletion(&CompletionParams{
+		items2, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
 			TextDocumentPositionParams: TextDocumentPositionParams{
 				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
 				Position:     Position{Line: 2, Character: 3},
@@ -573,6 +576,475 @@ onClick => {
 		assert.NotEmpty(t, items2)
 		assert.True(t, containsCompletionItemLabel(items2, "echo"))
 	})
+
+	t.Run("StructLitFields", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(`run "assets", {Title: "My Game", }`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 0, Character: 32},
+			},
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, items)
+		assert.True(t, containsCompletionItemLabel(items, "Width"))
+		assert.True(t, containsCompletionItemLabel(items, "Height"))
+		assert.False(t, containsCompletionItemLabel(items, "Title"))
+		assert.False(t, containsCompletionItemLabel(items, "println"))
+		assert.True(t, containsCompletionSpxDefinitionID(items, SpxDefinitionIdentifier{
+			Package: util.ToPtr("github.com/goplus/spx"),
+			Name:    util.ToPtr("Config.Width"),
+		}))
+	})
+
+	t.Run("StructLitIncompleteField", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(`run "assets", {Title: "My Game", Wi}`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 0, Character: 34},
+			},
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, items)
+		assert.True(t, containsCompletionItemLabel(items, "Width"))
+		assert.False(t, containsCompletionItemLabel(items, "Title"))
+		assert.False(t, containsCompletionItemLabel(items, "println"))
+	})
+
+	t.Run("StructLitFieldValue", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(`run "assets", {Title: "My Game", Width: }`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 0, Character: 40},
+			},
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, items)
+		assert.False(t, containsCompletionItemLabel(items, "Height"))
+		assert.False(t, containsCompletionItemLabel(items, "Title"))
+	})
+
+	t.Run("CommandStyleCallArgument", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	turnTo 
+}
+`),
+			"assets/index.json":                  []byte(`{"zorder":["MySprite"]}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 2, Character: 8},
+			},
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, items)
+		for _, name := range []string{"Left", "Right", "Up", "Down", "Mouse"} {
+			assert.True(t, containsCompletionSpxDefinitionID(items, SpxDefinitionIdentifier{
+				Package: util.ToPtr("github.com/goplus/spx"),
+				Name:    util.ToPtr(name),
+			}), name)
+		}
+		assert.False(t, containsCompletionItemLabel(items, "turnTo"))
+	})
+
+	t.Run("SpxKeyConstants", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+onKey K, => {}
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 7},
+			},
+		})
+		require.NoError(t, err)
+		idx := slices.IndexFunc(items, func(item CompletionItem) bool {
+			return item.Label == "KeySpace"
+		})
+		require.GreaterOrEqual(t, idx, 0)
+		assert.Equal(t, "const KeySpace = 116", items[idx].Detail)
+		require.NotNil(t, items[idx].Documentation)
+		assert.Contains(t, items[idx].Documentation.Value.(MarkupContent).Value, "The space key")
+		assert.True(t, containsCompletionItemLabel(items, "KeyA"))
+		assert.True(t, containsCompletionItemLabel(items, "Key0"))
+	})
+
+	t.Run("SpxResourceNamesInStringLit", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+
+onStart => {
+	broadcast "start"
+	play ""
+}
+onMsg "", => {}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	setCostume ""
+	onMsg "over", => {}
+}
+`),
+			"assets/index.json":                  []byte(`{"zorder":["MySprite"]}`),
+			"assets/sounds/Meow/index.json":      []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1"}]}`),
+		}), nil)
+
+		completeAt := func(t *testing.T, uri DocumentURI, position Position) []CompletionItem {
+			items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+				TextDocumentPositionParams: TextDocumentPositionParams{
+					TextDocument: TextDocumentIdentifier{URI: uri},
+					Position:     position,
+				},
+			})
+			require.NoError(t, err)
+			return items
+		}
+
+		soundItems := completeAt(t, "file:///main.spx", Position{Line: 7, Character: 7})
+		require.Len(t, soundItems, 1)
+		assert.Equal(t, "Meow", soundItems[0].Label)
+
+		costumeItems := completeAt(t, "file:///MySprite.spx", Position{Line: 2, Character: 13})
+		require.Len(t, costumeItems, 1)
+		assert.Equal(t, "costume1", costumeItems[0].Label)
+
+		messageItems := completeAt(t, "file:///main.spx", Position{Line: 9, Character: 7})
+		require.Len(t, messageItems, 2)
+		assert.True(t, containsCompletionItemLabel(messageItems, "start"))
+		assert.True(t, containsCompletionItemLabel(messageItems, "over"))
+		assert.Equal(t, "spx message", messageItems[0].Detail)
+	})
+}
+
+func TestServerCompletionItemResolve(t *testing.T) {
+	newServer := func(t *testing.T) *Server {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+
+MySprite.
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	MySprite.turn Right
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+		_, err := s.initialize(&InitializeParams{
+			XInitializeParams: XInitializeParams{
+				Capabilities: ClientCapabilities{
+					TextDocument: TextDocumentClientCapabilities{
+						Completion: CompletionClientCapabilities{
+							CompletionItem: ClientCompletionItemOptions{
+								ResolveSupport: &ClientCompletionItemResolveOptions{
+									Properties: []string{"documentation", "detail"},
+								},
+							},
+						},
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+		return s
+	}
+	completionParams := &CompletionParams{
+		TextDocumentPositionParams: TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 5, Character: 9},
+		},
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer(t)
+
+		items, err := s.textDocumentCompletion(context.Background(), completionParams)
+		require.NoError(t, err)
+		i := slices.IndexFunc(items, func(item CompletionItem) bool {
+			return item.Label == "turn"
+		})
+		require.GreaterOrEqual(t, i, 0)
+		item := items[i]
+		assert.Nil(t, item.Documentation)
+		assert.Empty(t, item.Detail)
+		assert.True(t, containsCompletionSpxDefinitionID(items, SpxDefinitionIdentifier{
+			Package:    util.ToPtr("github.com/goplus/spx"),
+			Name:       util.ToPtr("Sprite.turn"),
+			OverloadID: util.ToPtr("0"),
+		}))
+
+		// Simulate the client sending the item back as JSON.
+		raw, err := json.Marshal(item)
+		require.NoError(t, err)
+		var clientItem CompletionItem
+		require.NoError(t, json.Unmarshal(raw, &clientItem))
+
+		resolved, err := s.completionItemResolve(&clientItem)
+		require.NoError(t, err)
+		require.NotNil(t, resolved)
+		assert.Equal(t, "turn", resolved.Label)
+		assert.NotEmpty(t, resolved.Detail)
+		require.NotNil(t, resolved.Documentation)
+		assert.Contains(t, resolved.Documentation.Value.(MarkupContent).Value, "Sprite.turn")
+	})
+
+	t.Run("OutdatedResult", func(t *testing.T) {
+		s := newServer(t)
+
+		items, err := s.textDocumentCompletion(context.Background(), completionParams)
+		require.NoError(t, err)
+		require.NotEmpty(t, items)
+		item := items[0]
+		_, err = s.textDocumentCompletion(context.Background(), completionParams)
+		require.NoError(t, err)
+
+		resolved, err := s.completionItemResolve(&item)
+		require.NoError(t, err)
+		assert.Equal(t, &item, resolved)
+	})
+
+	t.Run("WithoutResolveSupport", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+
+MySprite.
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		items, err := s.textDocumentCompletion(context.Background(), completionParams)
+		require.NoError(t, err)
+		require.NotEmpty(t, items)
+		for _, item := range items {
+			assert.NotNil(t, item.Documentation)
+		}
+	})
+}
+
+func TestServerTextDocumentCompletionSpxEventHandlerSnippets(t *testing.T) {
+	newServer := func(t *testing.T, snippetSupport bool) *Server {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+
+on
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+		_, err := s.initialize(&InitializeParams{
+			XInitializeParams: XInitializeParams{
+				Capabilities: ClientCapabilities{
+					TextDocument: TextDocumentClientCapabilities{
+						Completion: CompletionClientCapabilities{
+							CompletionItem: ClientCompletionItemOptions{
+								SnippetSupport: snippetSupport,
+							},
+						},
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+		return s
+	}
+	params := &CompletionParams{
+		TextDocumentPositionParams: TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 5, Character: 2},
+		},
+	}
+	insertTextsOf := func(items []CompletionItem, label string) (insertTexts []string) {
+		for _, item := range items {
+			if item.Label == label {
+				insertTexts = append(insertTexts, item.InsertText)
+				require.NotNil(t, item.InsertTextFormat)
+				if strings.Contains(item.InsertText, "$0") {
+					assert.Equal(t, SnippetTextFormat, *item.InsertTextFormat)
+				}
+			}
+		}
+		return
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer(t, true)
+
+		items, err := s.textDocumentCompletion(context.Background(), params)
+		require.NoError(t, err)
+		assert.Contains(t, insertTextsOf(items, "onStart"), "onStart => {\n\t$0\n}")
+		assert.Contains(t, insertTextsOf(items, "onMsg"), `onMsg "${1:msg}", => {`+"\n\t$0\n}")
+		assert.Contains(t, insertTextsOf(items, "onKey"), "onKey ${1:key}, => {\n\t$0\n}")
+	})
+
+	t.Run("WithoutSnippetSupport", func(t *testing.T) {
+		s := newServer(t, false)
+
+		items, err := s.textDocumentCompletion(context.Background(), params)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"onStart"}, insertTextsOf(items, "onStart"))
+	})
+}
+
+func TestServerTextDocumentCompletionPostfix(t *testing.T) {
+	newServer := func(t *testing.T, mainSpx string) *Server {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(mainSpx),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+		_, err := s.initialize(&InitializeParams{
+			XInitializeParams: XInitializeParams{
+				Capabilities: ClientCapabilities{
+					TextDocument: TextDocumentClientCapabilities{
+						Completion: CompletionClientCapabilities{
+							CompletionItem: ClientCompletionItemOptions{
+								SnippetSupport: true,
+							},
+						},
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+		return s
+	}
+	postfixEditsOf := func(items []CompletionItem) map[string]TextEdit {
+		edits := make(map[string]TextEdit)
+		for _, item := range items {
+			if item.Kind != SnippetCompletion || item.TextEdit == nil {
+				continue
+			}
+			edits[item.Label] = item.TextEdit.Value.(TextEdit)
+		}
+		return edits
+	}
+
+	t.Run("Bool", func(t *testing.T) {
+		s := newServer(t, `
+var enabled bool
+
+onStart => {
+	enabled.
+}
+`)
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 4, Character: 9},
+			},
+		})
+		require.NoError(t, err)
+		edits := postfixEditsOf(items)
+		require.Contains(t, edits, "if")
+		assert.Equal(t, TextEdit{
+			Range: Range{
+				Start: Position{Line: 4, Character: 1},
+				End:   Position{Line: 4, Character: 9},
+			},
+			NewText: "if enabled {\n\t$0\n}",
+		}, edits["if"])
+		assert.Contains(t, edits, "not")
+		assert.Contains(t, edits, "var")
+		assert.NotContains(t, edits, "foreach")
+	})
+
+	t.Run("Slice", func(t *testing.T) {
+		s := newServer(t, `
+var scores []int
+
+onStart => {
+	scores.fo
+}
+`)
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 4, Character: 10},
+			},
+		})
+		require.NoError(t, err)
+		edits := postfixEditsOf(items)
+		require.Contains(t, edits, "foreach")
+		assert.Equal(t, TextEdit{
+			Range: Range{
+				Start: Position{Line: 4, Character: 1},
+				End:   Position{Line: 4, Character: 10},
+			},
+			NewText: "for ${1:v} <- scores {\n\t$0\n}",
+		}, edits["foreach"])
+		assert.Equal(t, "for ${1:i}, ${2:v} <- scores {\n\t$0\n}", edits["for"].NewText)
+		assert.NotContains(t, edits, "if")
+	})
+
+	t.Run("NotStatement", func(t *testing.T) {
+		s := newServer(t, `
+var enabled bool
+
+onStart => {
+	echo enabled.
+}
+`)
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 4, Character: 14},
+			},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, postfixEditsOf(items))
+	})
 }
 
 func containsCompletionItemLabel(items []CompletionItem, label string) bool {
@@ -581,6 +1053,16 @@ func containsCompletionItemLabel(items []CompletionItem, label string) bool {
 	})
 }
 
+// withoutCompletionItemSortTexts returns a copy of the given items with their
+// sort texts cleared.
+func withoutCompletionItemSortTexts(items []CompletionItem) []CompletionItem {
+	items = slices.Clone(items)
+	for i := range items {
+		items[i].SortText = ""
+	}
+	return items
+}
+
 func containsCompletionSpxDefinitionID(items []CompletionItem, id SpxDefinitionIdentifier) bool {
 	return slices.ContainsFunc(items, func(item CompletionItem) bool {
 		itemData, ok := item.Data.(*CompletionItemData)
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// configSection is the section of the server settings in the client
+// configuration.
+const configSection = "goxlsw"
+
+// Config is the configuration of the server. It is set by the client via the
+// initialization options and workspace/didChangeConfiguration.
+type Config struct {
+	Diagnostics DiagnosticsConfig `json:"diagnostics"`
+	Completion  CompletionConfig  `json:"completion"`
+	Formatting  FormattingConfig  `json:"formatting"`
+
+	// Analyzers enables or disables analyzers by their names, e.g.,
+	// "controlFlow", or the diagnostics they report by codes, e.g.,
+	// [DiagnosticCodeMissingReturn], or names of the codes, e.g.,
+	// "missingReturn". Analyzers and diagnostics not listed are enabled.
+	Analyzers map[string]bool `json:"analyzers,omitempty"`
+
+	// LogLevel is the minimum level of logs forwarded to the client via
+	// window/logMessage. Valid levels are "error", "warning", "info", "debug"
+	// and "off", where "off" or empty disables forwarding.
+	LogLevel string `json:"logLevel,omitempty"`
+}
+
+// DiagnosticsConfig is the configuration of diagnostics.
+type DiagnosticsConfig struct {
+	// SeverityOverrides overrides the severities of diagnostics by their
+	// codes or names of the codes. Valid severities are "error", "warning", "information", "hint"
+	// and "off", where "off" suppresses the diagnostics.
+	SeverityOverrides map[string]string `json:"severityOverrides,omitempty"`
+
+	// Messages overrides the beginner-friendly messages of reworded type
+	// errors by their codes or names of the codes, e.g., "typeMismatch".
+	// Placeholders like {type} are replaced with the corresponding parts of
+	// the original messages, and "off" keeps the original messages.
+	Messages map[string]string `json:"messages,omitempty"`
+}
+
+// CompletionConfig is the configuration of completion.
+type CompletionConfig struct {
+	// MaxItems is the maximum number of completion items. Zero means no
+	// limit.
+	MaxItems int `json:"maxItems,omitempty"`
+}
+
+// FormattingConfig is the configuration of formatting.
+type FormattingConfig struct {
+	// KeepUnusedImports keeps unused imports when formatting on save.
+	KeepUnusedImports bool `json:"keepUnusedImports,omitempty"`
+}
+
+// diagnosticSeverityOff suppresses diagnostics in severity overrides.
+const diagnosticSeverityOff = "off"
+
+// diagnosticMessageOff keeps the original messages of type errors in message
+// overrides.
+const diagnosticMessageOff = "off"
+
+// diagnosticSeverities maps severity names to diagnostic severities.
+var diagnosticSeverities = map[string]DiagnosticSeverity{
+	"error":       SeverityError,
+	"warning":     SeverityWarning,
+	"information": SeverityInformation,
+	"hint":        SeverityHint,
+}
+
+// parseConfig parses the config from the given settings, which are either the
+// server settings or the client configuration containing them in the
+// [configSection] section.
+func parseConfig(settings any) (*Config, error) {
+	raw, err := json.Marshal(settings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal settings: %w", err)
+	}
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &sections); err == nil {
+		if section, ok := sections[configSection]; ok {
+			raw = section
+		}
+	}
+
+	var config Config
+	if string(raw) != "null" {
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal settings: %w", err)
+		}
+	}
+	for code, severity := range config.Diagnostics.SeverityOverrides {
+		if _, ok := diagnosticSeverities[severity]; !ok && severity != diagnosticSeverityOff {
+			return nil, fmt.Errorf("invalid severity %q for diagnostic code %q", severity, code)
+		}
+	}
+	for code := range config.Diagnostics.Messages {
+		if !isTypeErrorRewordingCode(diagnosticCodeFor(code)) {
+			return nil, fmt.Errorf("invalid diagnostic code %q for message override", code)
+		}
+	}
+	config.Diagnostics.SeverityOverrides = diagnosticCodeKeyed(config.Diagnostics.SeverityOverrides)
+	config.Diagnostics.Messages = diagnosticCodeKeyed(config.Diagnostics.Messages)
+	if _, ok := logLevels[config.LogLevel]; !ok && config.LogLevel != "" && config.LogLevel != logLevelOff {
+		return nil, fmt.Errorf("invalid log level %q", config.LogLevel)
+	}
+	if config.Completion.MaxItems < 0 {
+		return nil, fmt.Errorf("invalid completion max items: %d", config.Completion.MaxItems)
+	}
+	return &config, nil
+}
+
+// diagnosticCodeKeyed returns the given map keyed by diagnostic codes or their
+// names with the names replaced by the codes.
+func diagnosticCodeKeyed(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	keyed := make(map[string]string, len(m))
+	for nameOrCode, value := range m {
+		keyed[diagnosticCodeFor(nameOrCode)] = value
+	}
+	return keyed
+}
+
+// analyzerEnabled reports whether the analyzer with the given name, or the
+// diagnostics of the given code, are enabled. Diagnostics can also be
+// disabled by the names of their codes.
+func (c *Config) analyzerEnabled(nameOrCode string) bool {
+	if enabled, ok := c.Analyzers[nameOrCode]; ok {
+		return enabled
+	}
+	if info, ok := diagnosticCodeInfos[nameOrCode]; ok {
+		if enabled, ok := c.Analyzers[info.name]; ok {
+			return enabled
+		}
+	}
+	return true
+}
+
+// applyDiagnosticSeverityOverrides returns the diagnostics with severities
+// overridden by the config. The given diagnostics are not modified.
+func (c *Config) applyDiagnosticSeverityOverrides(diagnostics []Diagnostic) []Diagnostic {
+	if len(c.Diagnostics.SeverityOverrides) == 0 {
+		return diagnostics
+	}
+	overridden := make([]Diagnostic, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		code, _ := diagnostic.Code.(string)
+		severity, ok := c.Diagnostics.SeverityOverrides[code]
+		if !ok {
+			overridden = append(overridden, diagnostic)
+			continue
+		}
+		if severity == diagnosticSeverityOff {
+			continue
+		}
+		diagnostic.Severity = diagnosticSeverities[severity]
+		overridden = append(overridden, diagnostic)
+	}
+	return overridden
+}
+
+// config returns the current config of the server.
+func (s *Server) config() *Config {
+	if config := s.currentConfig.Load(); config != nil {
+		return config
+	}
+	return &Config{}
+}
+
+// setConfig sets the config of the server and invalidates the analysis
+// results depending on it.
+func (s *Server) setConfig(config *Config) {
+	s.currentConfig.Store(config)
+	for _, folder := range s.getWorkspaceFolders() {
+		folder.resetCompileCache()
+	}
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#workspace_didChangeConfiguration
+func (s *Server) workspaceDidChangeConfiguration(params *DidChangeConfigurationParams) error {
+	config, err := parseConfig(params.Settings)
+	if err != nil {
+		return err
+	}
+	s.setConfig(config)
+
+	// Diagnostics may be affected by the new config.
+	s.refreshDiagnostics()
+	return nil
+}
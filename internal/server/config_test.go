@@ -0,0 +1,242 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		config, err := parseConfig(map[string]any{
+			"diagnostics": map[string]any{
+				"severityOverrides": map[string]any{DiagnosticCodeUnusedImport: "hint"},
+			},
+			"completion": map[string]any{"maxItems": 10},
+			"formatting": map[string]any{"keepUnusedImports": true},
+			"analyzers":  map[string]any{DiagnosticCodeUnusedImport: false},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, &Config{
+			Diagnostics: DiagnosticsConfig{
+				SeverityOverrides: map[string]string{DiagnosticCodeUnusedImport: "hint"},
+			},
+			Completion: CompletionConfig{MaxItems: 10},
+			Formatting: FormattingConfig{KeepUnusedImports: true},
+			Analyzers:  map[string]bool{DiagnosticCodeUnusedImport: false},
+		}, config)
+	})
+
+	t.Run("CodeNames", func(t *testing.T) {
+		config, err := parseConfig(map[string]any{
+			"diagnostics": map[string]any{
+				"severityOverrides": map[string]any{"unusedImport": "hint"},
+				"messages":          map[string]any{"typeMismatch": "off"},
+			},
+			"analyzers": map[string]any{"missingReturn": false},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{DiagnosticCodeUnusedImport: "hint"}, config.Diagnostics.SeverityOverrides)
+		assert.Equal(t, map[string]string{DiagnosticCodeTypeMismatch: "off"}, config.Diagnostics.Messages)
+		assert.False(t, config.analyzerEnabled(DiagnosticCodeMissingReturn))
+		assert.True(t, config.analyzerEnabled(DiagnosticCodeUnreachableCode))
+	})
+
+	t.Run("Section", func(t *testing.T) {
+		config, err := parseConfig(map[string]any{
+			"goxlsw": map[string]any{"completion": map[string]any{"maxItems": 10}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 10, config.Completion.MaxItems)
+	})
+
+	t.Run("Nil", func(t *testing.T) {
+		config, err := parseConfig(nil)
+		require.NoError(t, err)
+		assert.Equal(t, &Config{}, config)
+	})
+
+	t.Run("InvalidSeverity", func(t *testing.T) {
+		_, err := parseConfig(map[string]any{
+			"diagnostics": map[string]any{
+				"severityOverrides": map[string]any{"unusedImport": "fatal"},
+			},
+		})
+		require.EqualError(t, err, `invalid severity "fatal" for diagnostic code "unusedImport"`)
+	})
+
+	t.Run("InvalidMessageOverride", func(t *testing.T) {
+		_, err := parseConfig(map[string]any{
+			"diagnostics": map[string]any{
+				"messages": map[string]any{"unusedImport": "unused"},
+			},
+		})
+		require.EqualError(t, err, `invalid diagnostic code "unusedImport" for message override`)
+	})
+
+	t.Run("InvalidMaxItems", func(t *testing.T) {
+		_, err := parseConfig(map[string]any{"completion": map[string]any{"maxItems": -1}})
+		require.EqualError(t, err, "invalid completion max items: -1")
+	})
+
+	t.Run("InvalidLogLevel", func(t *testing.T) {
+		_, err := parseConfig(map[string]any{"logLevel": "verbose"})
+		require.EqualError(t, err, `invalid log level "verbose"`)
+	})
+
+	t.Run("InvalidType", func(t *testing.T) {
+		_, err := parseConfig(map[string]any{"completion": "all"})
+		require.Error(t, err)
+	})
+}
+
+func TestConfigApplyDiagnosticSeverityOverrides(t *testing.T) {
+	diagnostics := []Diagnostic{
+		{Severity: SeverityWarning, Code: DiagnosticCodeUnusedImport, Message: "unused"},
+		{Severity: SeverityError, Code: DiagnosticCodeTypeError, Message: "type error"},
+		{Severity: SeverityError, Code: DiagnosticCodeEmptyResourceName, Message: "empty"},
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		config := &Config{Diagnostics: DiagnosticsConfig{SeverityOverrides: map[string]string{
+			DiagnosticCodeUnusedImport:      "hint",
+			DiagnosticCodeEmptyResourceName: "off",
+		}}}
+		assert.Equal(t, []Diagnostic{
+			{Severity: SeverityHint, Code: DiagnosticCodeUnusedImport, Message: "unused"},
+			{Severity: SeverityError, Code: DiagnosticCodeTypeError, Message: "type error"},
+		}, config.applyDiagnosticSeverityOverrides(diagnostics))
+		assert.Equal(t, SeverityWarning, diagnostics[0].Severity)
+	})
+
+	t.Run("NoOverrides", func(t *testing.T) {
+		config := &Config{}
+		assert.Equal(t, diagnostics, config.applyDiagnosticSeverityOverrides(diagnostics))
+	})
+}
+
+func TestServerWorkspaceDidChangeConfiguration(t *testing.T) {
+	newServer := func(replier MessageReplier) *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`import "fmt"
+
+onStart => {
+	echo "Hello"
+}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), replier)
+	}
+	diagnosticsFor := func(t *testing.T, s *Server) []Diagnostic {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		return fullReport.Items
+	}
+
+	t.Run("SeverityOverrides", func(t *testing.T) {
+		s := newServer(nil)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, SeverityWarning, diags[0].Severity)
+
+		err := s.workspaceDidChangeConfiguration(&DidChangeConfigurationParams{
+			Settings: map[string]any{"goxlsw": map[string]any{
+				"diagnostics": map[string]any{
+					"severityOverrides": map[string]any{DiagnosticCodeUnusedImport: "hint"},
+				},
+			}},
+		})
+		require.NoError(t, err)
+		diags = diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, SeverityHint, diags[0].Severity)
+	})
+
+	t.Run("DisabledAnalyzer", func(t *testing.T) {
+		s := newServer(nil)
+		require.Len(t, diagnosticsFor(t, s), 1)
+
+		err := s.workspaceDidChangeConfiguration(&DidChangeConfigurationParams{
+			Settings: map[string]any{"analyzers": map[string]any{DiagnosticCodeUnusedImport: false}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, diagnosticsFor(t, s))
+	})
+
+	t.Run("KeepUnusedImports", func(t *testing.T) {
+		s := newServer(nil)
+		err := s.workspaceDidChangeConfiguration(&DidChangeConfigurationParams{
+			Settings: map[string]any{"formatting": map[string]any{"keepUnusedImports": true}},
+		})
+		require.NoError(t, err)
+
+		edits, err := s.textDocumentWillSaveWaitUntil(&WillSaveTextDocumentParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Reason:       Manual,
+		})
+		require.NoError(t, err)
+		assert.Empty(t, edits)
+	})
+
+	t.Run("CompletionMaxItems", func(t *testing.T) {
+		s := newServer(nil)
+		err := s.workspaceDidChangeConfiguration(&DidChangeConfigurationParams{
+			Settings: map[string]any{"completion": map[string]any{"maxItems": 3}},
+		})
+		require.NoError(t, err)
+
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 3, Character: 0},
+			},
+		})
+		require.NoError(t, err)
+		assert.Len(t, items, 3)
+	})
+
+	t.Run("RefreshDiagnostics", func(t *testing.T) {
+		methods := make(chan string, 1)
+		var s *Server
+		s = newServer(messageReplierFunc(func(m jsonrpc2.Message) error {
+			if call, ok := m.(*jsonrpc2.Call); ok {
+				methods <- call.Method()
+				resp, err := jsonrpc2.NewResponse(call.ID(), nil, nil)
+				require.NoError(t, err)
+				go s.HandleMessage(resp)
+			}
+			return nil
+		}))
+		s.clientCapabilities.Store(&ClientCapabilities{
+			Workspace: WorkspaceClientCapabilities{
+				Diagnostics: &DiagnosticWorkspaceClientCapabilities{RefreshSupport: true},
+			},
+		})
+
+		err := s.workspaceDidChangeConfiguration(&DidChangeConfigurationParams{Settings: map[string]any{}})
+		require.NoError(t, err)
+		select {
+		case method := <-methods:
+			assert.Equal(t, "workspace/diagnostic/refresh", method)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for workspace/diagnostic/refresh")
+		}
+	})
+
+	t.Run("InvalidSettings", func(t *testing.T) {
+		s := newServer(nil)
+		err := s.workspaceDidChangeConfiguration(&DidChangeConfigurationParams{
+			Settings: map[string]any{"completion": map[string]any{"maxItems": -1}},
+		})
+		require.Error(t, err)
+		assert.Equal(t, &Config{}, s.config())
+	})
+}
@@ -0,0 +1,210 @@
+package server
+
+import (
+	"maps"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// controlFlowAnalyzer is an [analyzer] for control flow issues.
+var controlFlowAnalyzer = &analyzer{
+	name:     "controlFlow",
+	requires: requireAST | requireTypeInfo,
+	run:      inspectForControlFlow,
+}
+
+// inspectForControlFlow inspects for unreachable code and functions with
+// results that may end without returning, like the unreachable and missing
+// return checks of go vet and the Go type checker.
+func inspectForControlFlow(pass *analysisPass) {
+	result := pass.result
+	for _, spxFile := range slices.Sorted(maps.Keys(result.mainASTPkg.Files)) {
+		astFile := result.mainASTPkg.Files[spxFile]
+		gopast.Inspect(astFile, func(node gopast.Node) bool {
+			var (
+				funcType *gopast.FuncType
+				body     *gopast.BlockStmt
+				stmts    []gopast.Stmt
+			)
+			switch node := node.(type) {
+			case *gopast.FuncDecl:
+				if !node.Shadow {
+					funcType, body = node.Type, node.Body
+				}
+			case *gopast.FuncLit:
+				funcType, body = node.Type, node.Body
+			case *gopast.BlockStmt:
+				stmts = node.List
+			case *gopast.CaseClause:
+				stmts = node.Body
+			case *gopast.CommClause:
+				stmts = node.Body
+			}
+
+			if funcType != nil && funcType.Results != nil && len(funcType.Results.List) > 0 && body != nil && !result.isTerminatingStmt(body, "") {
+				pass.report(spxFile, Diagnostic{
+					Severity: SeverityError,
+					Code:     DiagnosticCodeMissingReturn,
+					Range:    result.rangeForPos(body.Rbrace),
+					Message:  "missing return",
+				})
+			}
+			if unreachable := result.unreachableStmts(stmts); len(unreachable) > 0 {
+				pass.report(spxFile, Diagnostic{
+					Severity: SeverityWarning,
+					Code:     DiagnosticCodeUnreachableCode,
+					Range: Range{
+						Start: result.rangeForNode(unreachable[0]).Start,
+						End:   result.rangeForNode(unreachable[len(unreachable)-1]).End,
+					},
+					Message: "unreachable code",
+				})
+			}
+			return true
+		})
+	}
+}
+
+// unreachableStmts returns the statements in the given statement list that
+// follow a terminating statement or a branch statement and thus can never be
+// executed. Labeled statements may be jumped to, so they and the statements
+// following them are reachable.
+func (r *compileResult) unreachableStmts(stmts []gopast.Stmt) []gopast.Stmt {
+	for i, stmt := range stmts {
+		_, isBranch := stmt.(*gopast.BranchStmt)
+		if !isBranch && !r.isTerminatingStmt(stmt, "") {
+			continue
+		}
+		unreachable := stmts[i+1:]
+		for j, stmt := range unreachable {
+			if _, ok := stmt.(*gopast.LabeledStmt); ok {
+				unreachable = unreachable[:j]
+				break
+			}
+		}
+		for len(unreachable) > 0 {
+			if _, ok := unreachable[0].(*gopast.EmptyStmt); !ok {
+				break
+			}
+			unreachable = unreachable[1:]
+		}
+		return unreachable
+	}
+	return nil
+}
+
+// isTerminatingStmt reports whether the given statement is a terminating
+// statement as defined by the Go spec, i.e., the statements following it are
+// never executed. The label is the one of the statement if labeled.
+func (r *compileResult) isTerminatingStmt(stmt gopast.Stmt, label string) bool {
+	switch stmt := stmt.(type) {
+	case *gopast.ReturnStmt:
+		return true
+	case *gopast.BranchStmt:
+		return stmt.Tok == goptoken.GOTO || stmt.Tok == goptoken.FALLTHROUGH
+	case *gopast.ExprStmt:
+		callExpr, ok := stmt.X.(*gopast.CallExpr)
+		if !ok {
+			return false
+		}
+		ident, ok := callExpr.Fun.(*gopast.Ident)
+		if !ok {
+			return false
+		}
+		obj := r.typeInfo.ObjectOf(ident)
+		return isBuiltinObject(obj) && obj.Name() == "panic"
+	case *gopast.BlockStmt:
+		return r.isTerminatingStmtList(stmt.List)
+	case *gopast.IfStmt:
+		return stmt.Else != nil && r.isTerminatingStmt(stmt.Body, "") && r.isTerminatingStmt(stmt.Else, "")
+	case *gopast.LabeledStmt:
+		return r.isTerminatingStmt(stmt.Stmt, stmt.Label.Name)
+	case *gopast.ForStmt:
+		return stmt.Cond == nil && !hasBreakStmt(stmt.Body, label)
+	case *gopast.SwitchStmt:
+		return r.isTerminatingCaseClauses(stmt.Body, label)
+	case *gopast.TypeSwitchStmt:
+		return r.isTerminatingCaseClauses(stmt.Body, label)
+	case *gopast.SelectStmt:
+		for _, clause := range stmt.Body.List {
+			commClause, ok := clause.(*gopast.CommClause)
+			if !ok || !r.isTerminatingStmtList(commClause.Body) || hasBreakStmtInList(commClause.Body, label) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// isTerminatingStmtList reports whether the given statement list ends with a
+// terminating statement, ignoring trailing empty statements.
+func (r *compileResult) isTerminatingStmtList(stmts []gopast.Stmt) bool {
+	for i := len(stmts) - 1; i >= 0; i-- {
+		if _, ok := stmts[i].(*gopast.EmptyStmt); ok {
+			continue
+		}
+		return r.isTerminatingStmt(stmts[i], "")
+	}
+	return false
+}
+
+// isTerminatingCaseClauses reports whether the given switch body is
+// terminating, i.e., it has a default case, no break statements referring to
+// the switch, and each case ends with a terminating statement.
+func (r *compileResult) isTerminatingCaseClauses(body *gopast.BlockStmt, label string) bool {
+	hasDefault := false
+	for _, clause := range body.List {
+		caseClause, ok := clause.(*gopast.CaseClause)
+		if !ok || !r.isTerminatingStmtList(caseClause.Body) || hasBreakStmtInList(caseClause.Body, label) {
+			return false
+		}
+		if caseClause.List == nil {
+			hasDefault = true
+		}
+	}
+	return hasDefault
+}
+
+// hasBreakStmt reports whether the given statement contains a break statement
+// referring to the statement enclosing it, i.e., an unlabeled break not nested
+// in another breakable statement, or a break with the given label.
+func hasBreakStmt(stmt gopast.Stmt, label string) bool {
+	return hasBreakStmtRef(stmt, label, true)
+}
+
+// hasBreakStmtInList is like [hasBreakStmt] but for a statement list.
+func hasBreakStmtInList(stmts []gopast.Stmt, label string) bool {
+	return slices.ContainsFunc(stmts, func(stmt gopast.Stmt) bool {
+		return hasBreakStmt(stmt, label)
+	})
+}
+
+// hasBreakStmtRef reports whether the given node contains a break statement
+// with the given label, or an unlabeled one if unlabeled is true. Unlabeled
+// break statements nested in breakable statements refer to them instead.
+func hasBreakStmtRef(node gopast.Node, label string, unlabeled bool) bool {
+	found := false
+	gopast.Inspect(node, func(node gopast.Node) bool {
+		if found {
+			return false
+		}
+		switch node := node.(type) {
+		case *gopast.BranchStmt:
+			if node.Tok == goptoken.BREAK {
+				found = (node.Label == nil && unlabeled) || (node.Label != nil && node.Label.Name == label)
+			}
+		case *gopast.ForStmt, *gopast.RangeStmt, *gopast.ForPhraseStmt, *gopast.SwitchStmt, *gopast.TypeSwitchStmt, *gopast.SelectStmt:
+			if unlabeled {
+				found = label != "" && hasBreakStmtRef(node, label, false)
+				return false
+			}
+		case *gopast.FuncLit, *gopast.LambdaExpr2:
+			return false
+		}
+		return true
+	})
+	return found
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerInspectForControlFlow(t *testing.T) {
+	diagnosticsFor := func(t *testing.T, s *Server) []Diagnostic {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		return fullReport.Items
+	}
+	newServer := func(mainSpx string) *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(mainSpx),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+	}
+
+	t.Run("UnreachableAfterInfiniteLoop", func(t *testing.T) {
+		s := newServer(`
+onStart => {
+	for {
+		wait 1
+	}
+	echo "never"
+	echo "again"
+}
+run "assets", {Title: "My Game"}
+`)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, Diagnostic{
+			Severity: SeverityWarning,
+			Code:     DiagnosticCodeUnreachableCode,
+			Range: Range{
+				Start: Position{Line: 5, Character: 1},
+				End:   Position{Line: 6, Character: 13},
+			},
+			Message: "unreachable code",
+			Tags:    []DiagnosticTag{Unnecessary},
+		}, diags[0])
+	})
+
+	t.Run("ReachableAfterLoopWithBreak", func(t *testing.T) {
+		s := newServer(`
+onStart => {
+	for {
+		if true {
+			break
+		}
+	}
+	echo "reached"
+outer:
+	for {
+		for {
+			break outer
+		}
+	}
+	echo "reached"
+}
+run "assets", {Title: "My Game"}
+`)
+		assert.Empty(t, diagnosticsFor(t, s))
+	})
+
+	t.Run("UnreachableAfterReturn", func(t *testing.T) {
+		s := newServer(`
+func f() int {
+	return 1
+	echo "never"
+}
+
+onStart => {
+	echo f()
+}
+run "assets", {Title: "My Game"}
+`)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 2)
+		assert.Equal(t, DiagnosticCodeMissingReturn, diags[0].Code)
+		assert.Equal(t, DiagnosticCodeUnreachableCode, diags[1].Code)
+		assert.Equal(t, Range{
+			Start: Position{Line: 3, Character: 1},
+			End:   Position{Line: 3, Character: 13},
+		}, diags[1].Range)
+	})
+
+	t.Run("MissingReturn", func(t *testing.T) {
+		s := newServer(`
+func f(x int) int {
+	if x > 0 {
+		return 1
+	}
+}
+
+func g(x int) int {
+	if x > 0 {
+		return 1
+	} else {
+		return 2
+	}
+}
+
+func h(x int) int {
+	switch x {
+	case 1:
+		return 1
+	default:
+		panic("unexpected")
+	}
+}
+
+func loop() int {
+	for {
+	}
+}
+
+onStart => {
+	echo f(1), g(1), h(1), loop()
+}
+run "assets", {Title: "My Game"}
+`)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, Diagnostic{
+			Severity: SeverityError,
+			Code:     DiagnosticCodeMissingReturn,
+			Range: Range{
+				Start: Position{Line: 5, Character: 0},
+				End:   Position{Line: 5, Character: 0},
+			},
+			Message: "missing return",
+		}, diags[0])
+	})
+
+	t.Run("DisabledAnalyzers", func(t *testing.T) {
+		s := newServer(`
+func f() int {
+	return 1
+	echo "never"
+}
+
+func g() int {
+}
+
+onStart => {
+	echo f(), g()
+}
+run "assets", {Title: "My Game"}
+`)
+		err := s.workspaceDidChangeConfiguration(&DidChangeConfigurationParams{
+			Settings: map[string]any{"analyzers": map[string]any{
+				DiagnosticCodeUnreachableCode: false,
+				DiagnosticCodeMissingReturn:   false,
+			}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, diagnosticsFor(t, s))
+	})
+}
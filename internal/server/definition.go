@@ -1,6 +1,11 @@
 package server
 
-import "go/types"
+import (
+	"encoding/json"
+	"go/types"
+	"path"
+	"slices"
+)
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_declaration
 func (s *Server) textDocumentDeclaration(params *DeclarationParams) (any, error) {
@@ -22,6 +27,13 @@ func (s *Server) textDocumentDefinition(params *DefinitionParams) (any, error) {
 	}
 	position := result.toPosition(astFile, params.Position)
 
+	if spxResourceRef := result.spxResourceRefAtASTFilePosition(astFile, position); spxResourceRef != nil && spxResourceRef.Kind == SpxResourceRefKindStringLiteral {
+		if location, ok := result.spxResourceMetadataLocation(spxResourceRef.ID); ok {
+			return location, nil
+		}
+		return nil, nil
+	}
+
 	obj := result.typeInfo.ObjectOf(result.identAtASTFilePosition(astFile, position))
 	if !isMainPkgObject(obj) {
 		return nil, nil
@@ -61,6 +73,9 @@ func (s *Server) textDocumentTypeDefinition(params *TypeDefinitionParams) (any,
 	if !ok {
 		return nil, nil
 	}
+	if location, ok := result.classfileLocationForType(obj, named); ok {
+		return location, nil
+	}
 
 	objPos := named.Obj().Pos()
 	if !result.isInFset(objPos) {
@@ -68,3 +83,106 @@ func (s *Server) textDocumentTypeDefinition(params *TypeDefinitionParams) (any,
 	}
 	return result.locationForPos(objPos), nil
 }
+
+// classfileLocationForType returns the location of the spx classfile that
+// declares the given type of the given object, e.g., "MySprite.spx" for a
+// sprite auto-binding var "MySprite". Classfile types are generated by the
+// compiler, so the location is the start of the classfile.
+func (r *compileResult) classfileLocationForType(obj types.Object, named *types.Named) (Location, bool) {
+	var spxFile string
+	switch {
+	case named == r.mainPkgGameType:
+		spxFile = r.mainSpxFile
+	case slices.Contains(r.mainPkgSpriteTypes, named):
+		spxFile = named.Obj().Name() + ".spx"
+	case isSpxPkgObject(named.Obj()) && (named == GetSpxSpriteType() || named == GetSpxSpriteImplType()):
+		// Sprite auto-binding vars may be declared with the Sprite type,
+		// e.g., `MySprite Sprite`.
+		spxFile = obj.Name() + ".spx"
+	default:
+		return Location{}, false
+	}
+	if _, ok := r.mainASTPkg.Files[spxFile]; !ok {
+		return Location{}, false
+	}
+	uri, ok := r.documentURIs[spxFile]
+	if !ok {
+		return Location{}, false
+	}
+	return Location{URI: uri}, true
+}
+
+// spxResourceMetadataLocation returns the location where the spx resource with
+// the given ID is defined in the metadata files, i.e., its entry in the index.json
+// for backdrops, costumes, animations and widgets, or the index.json of its own
+// for sprites and sounds.
+func (r *compileResult) spxResourceMetadataLocation(id SpxResourceID) (Location, bool) {
+	set := &r.spxResourceSet
+	var (
+		file     = "index.json"
+		metadata = set.metadata
+		span     *jsonSpan
+	)
+	switch id := id.(type) {
+	case SpxBackdropResourceID:
+		if set.Backdrop(id.BackdropName) == nil {
+			return Location{}, false
+		}
+		span = jsonArrayElementSpanByName(metadata, id.BackdropName, "backdrops")
+	case SpxWidgetResourceID:
+		if set.Widget(id.WidgetName) == nil {
+			return Location{}, false
+		}
+		span = jsonArrayElementSpanByName(metadata, id.WidgetName, "zorder")
+	case SpxSoundResourceID:
+		if set.Sound(id.SoundName) == nil {
+			return Location{}, false
+		}
+		file = path.Join("sounds", id.SoundName, "index.json")
+	case SpxSpriteResourceID:
+		if set.Sprite(id.SpriteName) == nil {
+			return Location{}, false
+		}
+		file = path.Join("sprites", id.SpriteName, "index.json")
+	case SpxSpriteCostumeResourceID:
+		sprite := set.Sprite(id.SpriteName)
+		if sprite == nil || sprite.Costume(id.CostumeName) == nil {
+			return Location{}, false
+		}
+		file, metadata = path.Join("sprites", id.SpriteName, "index.json"), sprite.metadata
+		span = jsonArrayElementSpanByName(metadata, id.CostumeName, "costumes")
+	case SpxSpriteAnimationResourceID:
+		sprite := set.Sprite(id.SpriteName)
+		if sprite == nil || sprite.Animation(id.AnimationName) == nil {
+			return Location{}, false
+		}
+		file, metadata = path.Join("sprites", id.SpriteName, "index.json"), sprite.metadata
+		if s, ok := jsonObjectValueSpan(metadata, "fAnimations", id.AnimationName); ok {
+			span = &s
+		}
+	default:
+		return Location{}, false
+	}
+
+	location := Location{URI: r.toDocumentURI(path.Join(r.spxResourceRootDir, file))}
+	if span != nil {
+		location.Range = span.rangeIn(metadata, r.positionEncoding)
+	}
+	return location, true
+}
+
+// jsonArrayElementSpanByName returns the span of the first object element with
+// the given name of the array at the given path of object keys in the given
+// JSON document, or nil if there is none.
+func jsonArrayElementSpanByName(content []byte, name string, keys ...string) *jsonSpan {
+	spans, _ := jsonArrayElementSpans(content, keys...)
+	for _, span := range spans {
+		var elem struct {
+			Name string `json:"name"`
+		}
+		if json.Unmarshal(content[span.start:span.end], &elem) == nil && elem.Name == name {
+			return &span
+		}
+	}
+	return nil
+}
@@ -172,6 +172,76 @@ fmt2.println "Hello, spx!"
 			},
 		}, def.(Location))
 	})
+
+	t.Run("SpxResourceStringLiteral", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+onStart => {
+	play "explosion"
+	startBackdrop "backdrop2"
+}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	setCostume "costume2"
+	animate "walk"
+	clone "Ghost"
+}
+`),
+			"assets/index.json": []byte(`{
+  "backdrops": [
+    {"name": "backdrop1", "path": "backdrop1.png"},
+    {"name": "backdrop2", "path": "backdrop2.png"}
+  ],
+  "zorder": ["MySprite"]
+}`),
+			"assets/sounds/explosion/index.json": []byte(`{"path": "explosion.wav"}`),
+			"assets/sprites/MySprite/index.json": []byte(`{
+  "costumes": [{"name": "costume1"}, {"name": "costume2"}],
+  "fAnimations": {"walk": {"frameFrom": "costume1", "frameTo": "costume2"}}
+}`),
+		}), nil)
+		definitionAt := func(t *testing.T, uri DocumentURI, position Position) any {
+			def, err := s.textDocumentDefinition(&DefinitionParams{
+				TextDocumentPositionParams: TextDocumentPositionParams{
+					TextDocument: TextDocumentIdentifier{URI: uri},
+					Position:     position,
+				},
+			})
+			require.NoError(t, err)
+			return def
+		}
+
+		assert.Equal(t, Location{
+			URI: "file:///assets/sounds/explosion/index.json",
+		}, definitionAt(t, "file:///main.spx", Position{Line: 5, Character: 8}))
+		assert.Equal(t, Location{
+			URI: "file:///assets/index.json",
+			Range: Range{
+				Start: Position{Line: 3, Character: 4},
+				End:   Position{Line: 3, Character: 50},
+			},
+		}, definitionAt(t, "file:///main.spx", Position{Line: 6, Character: 17}))
+		assert.Equal(t, Location{
+			URI: "file:///assets/sprites/MySprite/index.json",
+			Range: Range{
+				Start: Position{Line: 1, Character: 37},
+				End:   Position{Line: 1, Character: 57},
+			},
+		}, definitionAt(t, "file:///MySprite.spx", Position{Line: 2, Character: 14}))
+		assert.Equal(t, Location{
+			URI: "file:///assets/sprites/MySprite/index.json",
+			Range: Range{
+				Start: Position{Line: 2, Character: 26},
+				End:   Position{Line: 2, Character: 74},
+			},
+		}, definitionAt(t, "file:///MySprite.spx", Position{Line: 3, Character: 11}))
+		assert.Nil(t, definitionAt(t, "file:///MySprite.spx", Position{Line: 4, Character: 9}))
+	})
 }
 
 func TestServerTextDocumentTypeDefinition(t *testing.T) {
@@ -224,6 +294,47 @@ var (
 		require.Nil(t, def)
 	})
 
+	t.Run("SpriteClassfile", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+	Other    Sprite
+)
+
+MySprite.turn Left
+Other.turn Left
+`),
+			"MySprite.spx": []byte(`
+onStart => {}
+`),
+			"Other.spx": []byte(`
+onStart => {}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+			"assets/sprites/Other/index.json":    []byte(`{}`),
+		}), nil)
+
+		def, err := s.textDocumentTypeDefinition(&TypeDefinitionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 6, Character: 1},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, Location{URI: "file:///MySprite.spx"}, def)
+
+		def, err = s.textDocumentTypeDefinition(&TypeDefinitionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 7, Character: 1},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, Location{URI: "file:///Other.spx"}, def)
+	})
+
 	t.Run("BuiltinType", func(t *testing.T) {
 		s := New(newMapFSWithoutModTime(map[string][]byte{
 			"main.spx": []byte(`
@@ -1,38 +1,196 @@
 package server
 
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"iter"
+	"maps"
+	"slices"
+	"time"
+)
+
+// diagnosticRefreshTimeout is the timeout of asking the client to refresh
+// diagnostics.
+const diagnosticRefreshTimeout = 5 * time.Second
+
+// diagnosticsSummaryTopCodes is the maximum number of diagnostic codes listed
+// in [SpxDiagnosticsSummary.TopCodes].
+const diagnosticsSummaryTopCodes = 5
+
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_diagnostic
-func (s *Server) textDocumentDiagnostic(params *DocumentDiagnosticParams) (*DocumentDiagnosticReport, error) {
-	result, err := s.compile()
+func (s *Server) textDocumentDiagnostic(ctx context.Context, params *DocumentDiagnosticParams) (*DocumentDiagnosticReport, error) {
+	folder, _, err := s.workspaceFolderFor(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	result, err := s.compileWorkspaceFolder(ctx, folder)
 	if err != nil {
 		return nil, err
 	}
 
+	diagnostics := s.describeDiagnostics(s.config().applyDiagnosticSeverityOverrides(result.diagnostics[params.TextDocument.URI]))
+	resultID := diagnosticsResultID(diagnostics)
+	if resultID != "" && params.PreviousResultID == resultID {
+		return &DocumentDiagnosticReport{Value: RelatedUnchangedDocumentDiagnosticReport{
+			UnchangedDocumentDiagnosticReport: UnchangedDocumentDiagnosticReport{
+				Kind:     string(DiagnosticUnchanged),
+				ResultID: resultID,
+			},
+		}}, nil
+	}
 	return &DocumentDiagnosticReport{Value: RelatedFullDocumentDiagnosticReport{
 		FullDocumentDiagnosticReport: FullDocumentDiagnosticReport{
-			Kind:  string(DiagnosticFull),
-			Items: result.diagnostics[params.TextDocument.URI],
+			Kind:     string(DiagnosticFull),
+			ResultID: resultID,
+			Items:    diagnostics,
 		},
 	}}, nil
 }
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#workspace_diagnostic
-func (s *Server) workspaceDiagnostic(params *WorkspaceDiagnosticParams) (*WorkspaceDiagnosticReport, error) {
-	result, err := s.compile()
+func (s *Server) workspaceDiagnostic(ctx context.Context, params *WorkspaceDiagnosticParams) (*WorkspaceDiagnosticReport, error) {
+	results, err := s.compileWorkspaceFolders(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	items := make([]WorkspaceDocumentDiagnosticReport, 0, len(result.diagnostics))
-	for file, fileDiags := range result.diagnostics {
+	previousResultIDs := make(map[DocumentURI]string, len(params.PreviousResultIds))
+	for _, previousResultID := range params.PreviousResultIds {
+		previousResultIDs[previousResultID.URI] = previousResultID.Value
+	}
+
+	config := s.config()
+	items := []WorkspaceDocumentDiagnosticReport{}
+	for file, fileDiags := range allDiagnostics(results) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		fileDiags = s.describeDiagnostics(config.applyDiagnosticSeverityOverrides(fileDiags))
+		resultID := diagnosticsResultID(fileDiags)
+		if resultID != "" && previousResultIDs[file] == resultID {
+			items = append(items, WorkspaceDocumentDiagnosticReport{
+				Value: WorkspaceUnchangedDocumentDiagnosticReport{
+					URI: DocumentURI(file),
+					UnchangedDocumentDiagnosticReport: UnchangedDocumentDiagnosticReport{
+						Kind:     string(DiagnosticUnchanged),
+						ResultID: resultID,
+					},
+				},
+			})
+			continue
+		}
 		items = append(items, WorkspaceDocumentDiagnosticReport{
 			Value: WorkspaceFullDocumentDiagnosticReport{
 				URI: DocumentURI(file),
 				FullDocumentDiagnosticReport: FullDocumentDiagnosticReport{
-					Kind:  string(DiagnosticFull),
-					Items: fileDiags,
+					Kind:     string(DiagnosticFull),
+					ResultID: resultID,
+					Items:    fileDiags,
 				},
 			},
 		})
 	}
 	return &WorkspaceDiagnosticReport{Items: items}, nil
 }
+
+// refreshDiagnostics asks the client to pull diagnostics again if it supports
+// refreshing. It is used after changes that are not made to open documents.
+func (s *Server) refreshDiagnostics() {
+	if !s.clientPullsDiagnostics() {
+		for _, folder := range s.getWorkspaceFolders() {
+			s.scheduleDiagnostics(folder)
+		}
+		return
+	}
+	caps := s.clientCapabilities.Load()
+	if caps == nil || caps.Workspace.Diagnostics == nil || !caps.Workspace.Diagnostics.RefreshSupport {
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), diagnosticRefreshTimeout)
+		defer cancel()
+		s.call(ctx, "workspace/diagnostic/refresh", nil, nil)
+	}()
+}
+
+// spxGetDiagnosticsSummary gets a summary of the diagnostics in all workspace
+// folders, so clients can render the health of the project without collecting
+// the diagnostics of every document.
+func (s *Server) spxGetDiagnosticsSummary(ctx context.Context) (*SpxDiagnosticsSummary, error) {
+	results, err := s.compileWorkspaceFolders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	config := s.config()
+	summary := &SpxDiagnosticsSummary{
+		Documents: []SpxDocumentDiagnosticsSummary{},
+		TopCodes:  []SpxDiagnosticCodeCount{},
+	}
+	codeCounts := make(map[string]int)
+	for file, fileDiags := range allDiagnostics(results) {
+		fileDiags = config.applyDiagnosticSeverityOverrides(fileDiags)
+		if len(fileDiags) == 0 {
+			continue
+		}
+		document := SpxDocumentDiagnosticsSummary{URI: file}
+		for _, diag := range fileDiags {
+			document.add(diag.Severity)
+			summary.add(diag.Severity)
+			if diag.Code != nil {
+				codeCounts[fmt.Sprint(diag.Code)]++
+			}
+		}
+		summary.Documents = append(summary.Documents, document)
+	}
+	slices.SortFunc(summary.Documents, func(a, b SpxDocumentDiagnosticsSummary) int {
+		return cmp.Compare(a.URI, b.URI)
+	})
+
+	for _, code := range slices.Sorted(maps.Keys(codeCounts)) {
+		summary.TopCodes = append(summary.TopCodes, SpxDiagnosticCodeCount{Code: code, Count: codeCounts[code]})
+	}
+	slices.SortStableFunc(summary.TopCodes, func(a, b SpxDiagnosticCodeCount) int {
+		return cmp.Compare(b.Count, a.Count)
+	})
+	if len(summary.TopCodes) > diagnosticsSummaryTopCodes {
+		summary.TopCodes = summary.TopCodes[:diagnosticsSummaryTopCodes]
+	}
+	return summary, nil
+}
+
+// add counts a diagnostic with the given severity.
+func (c *SpxDiagnosticCounts) add(severity DiagnosticSeverity) {
+	switch severity {
+	case SeverityWarning:
+		c.Warnings++
+	case SeverityInformation:
+		c.Information++
+	case SeverityHint:
+		c.Hints++
+	default:
+		c.Errors++
+	}
+}
+
+// allDiagnostics returns an iterator over the diagnostics of all documents in
+// the given compile results.
+func allDiagnostics(results []*compileResult) iter.Seq2[DocumentURI, []Diagnostic] {
+	return func(yield func(DocumentURI, []Diagnostic) bool) {
+		for _, result := range results {
+			for file, fileDiags := range result.diagnostics {
+				if !yield(file, fileDiags) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// diagnosticsResultID returns the result ID of the given diagnostics. Equal
+// diagnostics always have the same result ID, so that clients can skip
+// unchanged reports.
+func diagnosticsResultID(diagnostics []Diagnostic) string {
+	return contentResultID(diagnostics)
+}
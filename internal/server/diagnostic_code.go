@@ -0,0 +1,106 @@
+package server
+
+import "slices"
+
+// diagnosticCodeDescriptionBaseURL is the base URL of the explanations of
+// diagnostic codes, which are anchored by the codes.
+const diagnosticCodeDescriptionBaseURL = "https://github.com/goplus/goxlsw/blob/main/doc/diagnostics.md#"
+
+// diagnosticCodeInfo describes a diagnostic code.
+type diagnosticCodeInfo struct {
+	// name is the name of the code, e.g., "resourceNotFound", which can be used
+	// in place of the code in [Config].
+	name string
+
+	// tags are the tags of all diagnostics with the code.
+	tags []DiagnosticTag
+}
+
+// diagnosticCodeInfos maps diagnostic codes to their descriptions.
+var diagnosticCodeInfos = map[string]diagnosticCodeInfo{
+	DiagnosticCodeResourceNotFound:         {name: "resourceNotFound"},
+	DiagnosticCodeUnhandledBroadcast:       {name: "unhandledBroadcast"},
+	DiagnosticCodeParseError:               {name: "parseError"},
+	DiagnosticCodeInvalidPackageName:       {name: "invalidPackageName"},
+	DiagnosticCodeTypeError:                {name: "typeError"},
+	DiagnosticCodeInvalidRunArgument:       {name: "invalidRunArgument"},
+	DiagnosticCodeInvalidResourceSet:       {name: "invalidResourceSet"},
+	DiagnosticCodeUnusedImport:             {name: "unusedImport", tags: []DiagnosticTag{Unnecessary}},
+	DiagnosticCodeInvalidAutoBinding:       {name: "invalidAutoBinding"},
+	DiagnosticCodeEmptyResourceName:        {name: "emptyResourceName"},
+	DiagnosticCodeUnusedMessageHandler:     {name: "unusedMessageHandler", tags: []DiagnosticTag{Unnecessary}},
+	DiagnosticCodeUnusedSymbol:             {name: "unusedSymbol", tags: []DiagnosticTag{Unnecessary}},
+	DiagnosticCodeUnreachableCode:          {name: "unreachableCode", tags: []DiagnosticTag{Unnecessary}},
+	DiagnosticCodeMissingReturn:            {name: "missingReturn"},
+	DiagnosticCodeShadowedVariable:         {name: "shadowedVariable"},
+	DiagnosticCodeLoopVariableCapture:      {name: "loopVariableCapture"},
+	DiagnosticCodeUndefinedName:            {name: "undefinedName"},
+	DiagnosticCodeTypeMismatch:             {name: "typeMismatch"},
+	DiagnosticCodeTooManyArguments:         {name: "tooManyArguments"},
+	DiagnosticCodeNotEnoughArguments:       {name: "notEnoughArguments"},
+	DiagnosticCodeMismatchedTypes:          {name: "mismatchedTypes"},
+	DiagnosticCodeUnknownMember:            {name: "unknownMember"},
+	DiagnosticCodeNotCallable:              {name: "notCallable"},
+	DiagnosticCodeMissingSpriteAutoBinding: {name: "missingSpriteAutoBinding"},
+	DiagnosticCodeInvalidModule:            {name: "invalidModule"},
+	DiagnosticCodeMapSizeMismatch:          {name: "mapSizeMismatch"},
+	DiagnosticCodeZorderSpriteNotFound:     {name: "zorderSpriteNotFound"},
+	DiagnosticCodeInvalidResourceMetadata:  {name: "invalidResourceMetadata"},
+}
+
+// diagnosticCodesByName maps the names of diagnostic codes to the codes.
+var diagnosticCodesByName = func() map[string]string {
+	codes := make(map[string]string, len(diagnosticCodeInfos))
+	for code, info := range diagnosticCodeInfos {
+		codes[info.name] = code
+	}
+	return codes
+}()
+
+// diagnosticCodeFor returns the diagnostic code for the given code or its
+// name. Unknown ones are returned as is.
+func diagnosticCodeFor(nameOrCode string) string {
+	if code, ok := diagnosticCodesByName[nameOrCode]; ok {
+		return code
+	}
+	return nameOrCode
+}
+
+// describeDiagnostics returns the diagnostics with the tags of their codes
+// and, if the client supports them, the descriptions of their codes. The
+// given diagnostics are not modified.
+func (s *Server) describeDiagnostics(diagnostics []Diagnostic) []Diagnostic {
+	withCodeDescriptions := s.clientSupportsDiagnosticCodeDescription()
+	described := make([]Diagnostic, 0, len(diagnostics))
+	for _, diagnostic := range diagnostics {
+		code, _ := diagnostic.Code.(string)
+		info, ok := diagnosticCodeInfos[code]
+		if !ok {
+			described = append(described, diagnostic)
+			continue
+		}
+		for _, tag := range info.tags {
+			if !slices.Contains(diagnostic.Tags, tag) {
+				diagnostic.Tags = append(slices.Clip(diagnostic.Tags), tag)
+			}
+		}
+		if withCodeDescriptions {
+			diagnostic.CodeDescription = &CodeDescription{Href: URI(diagnosticCodeDescriptionBaseURL + code)}
+		}
+		described = append(described, diagnostic)
+	}
+	return described
+}
+
+// clientSupportsDiagnosticCodeDescription reports whether the client supports
+// the code descriptions of diagnostics.
+func (s *Server) clientSupportsDiagnosticCodeDescription() bool {
+	clientCapabilities := s.clientCapabilities.Load()
+	if clientCapabilities == nil {
+		return false
+	}
+	if diagnostic := clientCapabilities.TextDocument.Diagnostic; diagnostic != nil && diagnostic.CodeDescriptionSupport {
+		return true
+	}
+	return clientCapabilities.TextDocument.PublishDiagnostics.CodeDescriptionSupport
+}
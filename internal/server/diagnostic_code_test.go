@@ -0,0 +1,62 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnosticCodeFor(t *testing.T) {
+	assert.Equal(t, DiagnosticCodeResourceNotFound, diagnosticCodeFor("resourceNotFound"))
+	assert.Equal(t, DiagnosticCodeUnhandledBroadcast, diagnosticCodeFor(DiagnosticCodeUnhandledBroadcast))
+	assert.Equal(t, "unknown", diagnosticCodeFor("unknown"))
+}
+
+func TestServerDescribeDiagnostics(t *testing.T) {
+	diagnosticsFor := func(t *testing.T, s *Server) []Diagnostic {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		return fullReport.Items
+	}
+	newServer := func() *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+import "fmt"
+
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer()
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, DiagnosticCodeUnusedImport, diags[0].Code)
+		assert.Equal(t, []DiagnosticTag{Unnecessary}, diags[0].Tags)
+		assert.Nil(t, diags[0].CodeDescription)
+	})
+
+	t.Run("CodeDescriptionSupport", func(t *testing.T) {
+		s := newServer()
+		s.clientCapabilities.Store(&ClientCapabilities{
+			TextDocument: TextDocumentClientCapabilities{
+				Diagnostic: &DiagnosticClientCapabilities{
+					DiagnosticsCapabilities: DiagnosticsCapabilities{CodeDescriptionSupport: true},
+				},
+			},
+		})
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, &CodeDescription{
+			Href: "https://github.com/goplus/goxlsw/blob/main/doc/diagnostics.md#spx0008",
+		}, diags[0].CodeDescription)
+	})
+}
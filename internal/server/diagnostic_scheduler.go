@@ -0,0 +1,144 @@
+package server
+
+import (
+	"context"
+	"time"
+)
+
+// defaultDiagnosticsDebounceDelay is the default delay of background
+// diagnostics after the last change to a workspace folder, which coalesces
+// bursts of changes, e.g., typing, into a single analysis.
+const defaultDiagnosticsDebounceDelay = 200 * time.Millisecond
+
+// clientPullsDiagnostics reports whether the client pulls diagnostics via
+// textDocument/diagnostic and workspace/diagnostic, in which case diagnostics
+// are never pushed to it. Clients that have not been initialized are assumed
+// to pull diagnostics.
+func (s *Server) clientPullsDiagnostics() bool {
+	caps := s.clientCapabilities.Load()
+	return caps == nil || caps.TextDocument.Diagnostic != nil || caps.Workspace.Diagnostics != nil
+}
+
+// scheduleDiagnostics schedules publishing the diagnostics of the given
+// workspace folder in the background if the client does not pull diagnostics.
+// A scheduled run is postponed by every call within the debounce delay, and an
+// in-flight run is canceled, as its results are superseded by the next one.
+func (s *Server) scheduleDiagnostics(folder *workspaceFolder) {
+	if s.replier == nil || s.clientPullsDiagnostics() {
+		return
+	}
+
+	folder.diagnosticsMu.Lock()
+	defer folder.diagnosticsMu.Unlock()
+	if folder.diagnosticsTimer != nil {
+		folder.diagnosticsTimer.Stop()
+	}
+	if folder.cancelDiagnostics != nil {
+		folder.cancelDiagnostics()
+		folder.cancelDiagnostics = nil
+	}
+	folder.diagnosticsGeneration++
+	generation := folder.diagnosticsGeneration
+	folder.diagnosticsTimer = time.AfterFunc(s.diagnosticsDebounceDelay, func() {
+		s.runScheduledDiagnostics(folder, generation)
+	})
+}
+
+// runScheduledDiagnostics analyzes the given workspace folder and publishes
+// the diagnostics of its documents that have changed since they were last
+// published, unless the run of the given generation has been superseded.
+func (s *Server) runScheduledDiagnostics(folder *workspaceFolder, generation uint64) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	folder.diagnosticsMu.Lock()
+	if generation != folder.diagnosticsGeneration {
+		folder.diagnosticsMu.Unlock()
+		return
+	}
+	folder.cancelDiagnostics = cancel
+	folder.diagnosticsMu.Unlock()
+
+	// Versions are captured before compiling, so they never claim newer
+	// contents than the ones analyzed. Newer changes schedule another run.
+	versions := s.openDocumentVersions()
+	result, err := s.compileWorkspaceFolder(ctx, folder)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.logger.Debug("failed to analyze project for background diagnostics", "workspaceFolder", string(folder.uri), "error", err)
+		}
+		return
+	}
+
+	// Notifications are built under the lock, so that superseded runs publish
+	// nothing, but sent after releasing it, so that a slow client never blocks
+	// scheduling the next run.
+	notifications := s.buildScheduledDiagnostics(folder, generation, versions, result)
+	for _, n := range notifications {
+		if err := s.publishDiagnostics(n.URI, n.Version, n.Diagnostics); err != nil {
+			s.logger.Debug("failed to publish diagnostics", "uri", string(n.URI), "error", err)
+		}
+	}
+}
+
+// buildScheduledDiagnostics returns the diagnostics notifications of the
+// documents of the given workspace folder whose diagnostics have changed since
+// they were last published, and records them as published. It returns nil if
+// the run of the given generation has been superseded.
+func (s *Server) buildScheduledDiagnostics(folder *workspaceFolder, generation uint64, versions map[string]int32, result *compileResult) []PublishDiagnosticsParams {
+	folder.diagnosticsMu.Lock()
+	defer folder.diagnosticsMu.Unlock()
+	if generation != folder.diagnosticsGeneration {
+		return nil
+	}
+	folder.cancelDiagnostics = nil
+
+	var notifications []PublishDiagnosticsParams
+	config := s.config()
+	published := make(map[DocumentURI]string, len(result.diagnostics))
+	for documentURI, diags := range result.diagnostics {
+		diags = s.describeDiagnostics(config.applyDiagnosticSeverityOverrides(diags))
+		resultID := diagnosticsResultID(diags)
+		published[documentURI] = resultID
+		if lastResultID, ok := folder.publishedDiagnostics[documentURI]; ok && lastResultID == resultID {
+			continue
+		}
+		var version int32
+		if file, err := s.fromDocumentURI(documentURI); err == nil {
+			version = versions[file]
+		}
+		notifications = append(notifications, PublishDiagnosticsParams{
+			URI:         documentURI,
+			Version:     version,
+			Diagnostics: diags,
+		})
+	}
+
+	// Clear diagnostics of documents that are no longer analyzed, e.g.,
+	// deleted ones.
+	for documentURI := range folder.publishedDiagnostics {
+		if _, ok := published[documentURI]; ok {
+			continue
+		}
+		notifications = append(notifications, PublishDiagnosticsParams{
+			URI:         documentURI,
+			Diagnostics: []Diagnostic{},
+		})
+	}
+	folder.publishedDiagnostics = published
+	return notifications
+}
+
+// stopDiagnostics stops the scheduled and in-flight background diagnostics of
+// the workspace folder.
+func (f *workspaceFolder) stopDiagnostics() {
+	f.diagnosticsMu.Lock()
+	defer f.diagnosticsMu.Unlock()
+	if f.diagnosticsTimer != nil {
+		f.diagnosticsTimer.Stop()
+	}
+	if f.cancelDiagnostics != nil {
+		f.cancelDiagnostics()
+		f.cancelDiagnostics = nil
+	}
+	f.diagnosticsGeneration++
+}
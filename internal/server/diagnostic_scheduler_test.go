@@ -0,0 +1,153 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerScheduleDiagnostics(t *testing.T) {
+	newServer := func(t *testing.T, caps *ClientCapabilities) (*Server, chan PublishDiagnosticsParams) {
+		published := make(chan PublishDiagnosticsParams, 10)
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(`run "assets", {Title: "My Game"}`),
+			"assets/index.json": []byte(`{}`),
+		}), messageReplierFunc(func(m jsonrpc2.Message) error {
+			if n, ok := m.(*jsonrpc2.Notification); ok && n.Method() == "textDocument/publishDiagnostics" {
+				var params PublishDiagnosticsParams
+				require.NoError(t, json.Unmarshal(n.Params(), &params))
+				published <- params
+			}
+			return nil
+		}))
+		s.clientCapabilities.Store(caps)
+		s.diagnosticsDebounceDelay = 20 * time.Millisecond
+		return s, published
+	}
+	openMainSpx := func(t *testing.T, s *Server, text string) {
+		err := s.textDocumentDidOpen(&DidOpenTextDocumentParams{
+			TextDocument: TextDocumentItem{
+				URI:     "file:///main.spx",
+				Version: 1,
+				Text:    text,
+			},
+		})
+		require.NoError(t, err)
+	}
+	changeMainSpx := func(t *testing.T, s *Server, version int32, text string) {
+		err := s.textDocumentDidChange(&DidChangeTextDocumentParams{
+			TextDocument: VersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Version:                version,
+			},
+			ContentChanges: []TextDocumentContentChangeEvent{{Text: text}},
+		})
+		require.NoError(t, err)
+	}
+	nextPublished := func(t *testing.T, published chan PublishDiagnosticsParams, uri DocumentURI) PublishDiagnosticsParams {
+		timeout := time.After(time.Second)
+		for {
+			select {
+			case params := <-published:
+				if params.URI == uri {
+					return params
+				}
+			case <-timeout:
+				t.Fatalf("timed out waiting for diagnostics of %s", uri)
+			}
+		}
+	}
+	assertNothingPublished := func(t *testing.T, published chan PublishDiagnosticsParams, uri DocumentURI) {
+		timeout := time.After(200 * time.Millisecond)
+		for {
+			select {
+			case params := <-published:
+				assert.NotEqual(t, uri, params.URI, "unexpected diagnostics of %s", uri)
+			case <-timeout:
+				return
+			}
+		}
+	}
+
+	t.Run("Debounced", func(t *testing.T) {
+		s, published := newServer(t, &ClientCapabilities{})
+		openMainSpx(t, s, "onStart => {\n\tfoo\n}\nrun \"assets\", {Title: \"My Game\"}\n")
+		changeMainSpx(t, s, 2, "onStart => {\n\tba\n}\nrun \"assets\", {Title: \"My Game\"}\n")
+		changeMainSpx(t, s, 3, "onStart => {\n\tbar\n}\nrun \"assets\", {Title: \"My Game\"}\n")
+
+		params := nextPublished(t, published, "file:///main.spx")
+		assert.Equal(t, int32(3), params.Version)
+		require.Len(t, params.Diagnostics, 1)
+		assert.Contains(t, params.Diagnostics[0].Message, "bar")
+		assertNothingPublished(t, published, "file:///main.spx")
+
+		// Unchanged diagnostics are not published again.
+		changeMainSpx(t, s, 4, "onStart => {\n\tbar\n}\nrun \"assets\", {Title: \"My Game\"}\n")
+		assertNothingPublished(t, published, "file:///main.spx")
+
+		changeMainSpx(t, s, 5, "onStart => {\n}\nrun \"assets\", {Title: \"My Game\"}\n")
+		params = nextPublished(t, published, "file:///main.spx")
+		assert.Equal(t, int32(5), params.Version)
+		assert.Empty(t, params.Diagnostics)
+	})
+
+	t.Run("SlowClient", func(t *testing.T) {
+		s, published := newServer(t, &ClientCapabilities{})
+		publishing, unblock := make(chan struct{}, 1), make(chan struct{})
+		replier := s.replier
+		s.replier = messageReplierFunc(func(m jsonrpc2.Message) error {
+			select {
+			case publishing <- struct{}{}:
+			default:
+			}
+			<-unblock
+			return replier.ReplyMessage(m)
+		})
+		openMainSpx(t, s, "onStart => {\n\tfoo\n}\nrun \"assets\", {Title: \"My Game\"}\n")
+		select {
+		case <-publishing:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for diagnostics to be published")
+		}
+
+		// Publishing never blocks scheduling the next run.
+		changed := make(chan struct{})
+		go func() {
+			changeMainSpx(t, s, 2, "onStart => {\n\tbar\n}\nrun \"assets\", {Title: \"My Game\"}\n")
+			close(changed)
+		}()
+		select {
+		case <-changed:
+		case <-time.After(time.Second):
+			t.Fatal("timed out scheduling diagnostics while publishing")
+		}
+		close(unblock)
+
+		params := nextPublished(t, published, "file:///main.spx")
+		assert.Equal(t, int32(1), params.Version)
+		params = nextPublished(t, published, "file:///main.spx")
+		assert.Equal(t, int32(2), params.Version)
+	})
+
+	t.Run("PullingClient", func(t *testing.T) {
+		s, published := newServer(t, &ClientCapabilities{
+			TextDocument: TextDocumentClientCapabilities{
+				Diagnostic: &DiagnosticClientCapabilities{},
+			},
+		})
+		openMainSpx(t, s, "onStart => {\n\tfoo\n}\n")
+		assertNothingPublished(t, published, "file:///main.spx")
+	})
+
+	t.Run("StoppedOnUnload", func(t *testing.T) {
+		s, published := newServer(t, &ClientCapabilities{})
+		s.diagnosticsDebounceDelay = 50 * time.Millisecond
+		openMainSpx(t, s, "onStart => {\n\tfoo\n}\n")
+		s.defaultWorkspaceFolder().stopDiagnostics()
+		assertNothingPublished(t, published, "file:///main.spx")
+	})
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+
+	gopast "github.com/goplus/gop/ast"
+)
+
+// diagnosticSuppressionCommentPrefix is the prefix of comments suppressing
+// diagnostics on the next line, e.g., `//xlsw:ignore spx0002`.
+const diagnosticSuppressionCommentPrefix = "//xlsw:ignore"
+
+// parseDiagnosticSuppressionComment parses the given comment text suppressing
+// diagnostics on the next line. Codes or names of the codes are separated by
+// spaces or commas, and the returned codes are empty if all diagnostics are
+// suppressed. It reports false if the comment is not a suppression comment.
+func parseDiagnosticSuppressionComment(text string) (codes []string, ok bool) {
+	rest, ok := strings.CutPrefix(text, diagnosticSuppressionCommentPrefix)
+	if !ok || (rest != "" && !unicode.IsSpace(rune(rest[0]))) {
+		return nil, false
+	}
+	for _, nameOrCode := range strings.FieldsFunc(rest, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}) {
+		codes = append(codes, diagnosticCodeFor(nameOrCode))
+	}
+	return codes, true
+}
+
+// diagnosticSuppressionsFor returns the codes of diagnostics suppressed by
+// comments in the given AST file, keyed by the zero-based lines they are
+// suppressed on. Empty codes suppress all diagnostics on the line.
+func (r *compileResult) diagnosticSuppressionsFor(astFile *gopast.File) map[uint32][]string {
+	var suppressions map[uint32][]string
+	for _, commentGroup := range astFile.Comments {
+		for _, comment := range commentGroup.List {
+			codes, ok := parseDiagnosticSuppressionComment(comment.Text)
+			if !ok {
+				continue
+			}
+			if suppressions == nil {
+				suppressions = make(map[uint32][]string)
+			}
+			// The one-based line of the comment is the zero-based next line.
+			suppressions[uint32(r.fset.Position(comment.Pos()).Line)] = codes
+		}
+	}
+	return suppressions
+}
+
+// suppressIgnoredDiagnostics removes the diagnostics suppressed by comments
+// from the compile result.
+func (r *compileResult) suppressIgnoredDiagnostics() {
+	suppressed := false
+	for spxFile, astFile := range r.mainASTPkg.Files {
+		suppressions := r.diagnosticSuppressionsFor(astFile)
+		if len(suppressions) == 0 {
+			continue
+		}
+		documentURI := r.documentURIs[spxFile]
+		r.diagnostics[documentURI] = slices.DeleteFunc(r.diagnostics[documentURI], func(diag Diagnostic) bool {
+			codes, ok := suppressions[diag.Range.Start.Line]
+			if !ok {
+				return false
+			}
+			code, _ := diag.Code.(string)
+			if len(codes) > 0 && !slices.Contains(codes, code) {
+				return false
+			}
+			suppressed = true
+			return true
+		})
+	}
+	if !suppressed {
+		return
+	}
+
+	r.hasErrorSeverityDiagnostic = false
+	for _, diags := range r.diagnostics {
+		if slices.ContainsFunc(diags, func(diag Diagnostic) bool {
+			return diag.Severity == SeverityError
+		}) {
+			r.hasErrorSeverityDiagnostic = true
+			break
+		}
+	}
+}
+
+// ignoreDiagnosticCodeAction returns the code action suppressing the given
+// diagnostic with a comment on the line before it in the given document
+// content. An existing suppression comment on that line is extended with the
+// code of the diagnostic. Only diagnostics with codes and without error
+// severity can be suppressed by code actions. Positions are in the given
+// position encoding.
+func ignoreDiagnosticCodeAction(documentURI DocumentURI, content []byte, diag Diagnostic, encoding PositionEncodingKind) (CodeAction, bool) {
+	code, ok := diag.Code.(string)
+	if !ok || code == "" || diag.Severity == SeverityError {
+		return CodeAction{}, false
+	}
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	line := diag.Range.Start.Line
+	if int(line) >= len(lines) {
+		return CodeAction{}, false
+	}
+
+	var edit TextEdit
+	if prevLine := line - 1; line > 0 {
+		prevLineText := strings.TrimRight(string(lines[prevLine]), "\r\n")
+		if _, ok := parseDiagnosticSuppressionComment(strings.TrimSpace(prevLineText)); ok {
+			end := Position{Line: prevLine, Character: uint32(utf8OffsetToEncoding(prevLineText, len(prevLineText), encoding))}
+			edit = TextEdit{Range: Range{Start: end, End: end}, NewText: " " + code}
+		}
+	}
+	if edit.NewText == "" {
+		lineText := string(lines[line])
+		indent := lineText[:len(lineText)-len(strings.TrimLeft(lineText, " \t"))]
+		start := Position{Line: line}
+		edit = TextEdit{
+			Range:   Range{Start: start, End: start},
+			NewText: indent + diagnosticSuppressionCommentPrefix + " " + code + "\n",
+		}
+	}
+	return CodeAction{
+		Title:       fmt.Sprintf("Ignore %s on this line", code),
+		Kind:        QuickFix,
+		Diagnostics: []Diagnostic{diag},
+		Edit: &WorkspaceEdit{
+			Changes: map[DocumentURI][]TextEdit{documentURI: {edit}},
+		},
+	}, true
+}
@@ -0,0 +1,129 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDiagnosticSuppressionComment(t *testing.T) {
+	codes, ok := parseDiagnosticSuppressionComment("//xlsw:ignore spx0002, unusedSymbol")
+	require.True(t, ok)
+	assert.Equal(t, []string{DiagnosticCodeUnhandledBroadcast, DiagnosticCodeUnusedSymbol}, codes)
+
+	codes, ok = parseDiagnosticSuppressionComment("//xlsw:ignore")
+	require.True(t, ok)
+	assert.Empty(t, codes)
+
+	_, ok = parseDiagnosticSuppressionComment("//xlsw:ignored spx0002")
+	assert.False(t, ok)
+	_, ok = parseDiagnosticSuppressionComment("// xlsw:ignore spx0002")
+	assert.False(t, ok)
+}
+
+func TestServerSuppressIgnoredDiagnostics(t *testing.T) {
+	diagnosticsFor := func(t *testing.T, s *Server) []Diagnostic {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		return fullReport.Items
+	}
+	newServer := func(mainSpx string) *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(mainSpx),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer(`
+//xlsw:ignore spx0008
+import "fmt"
+
+onStart => {
+	//xlsw:ignore unhandledBroadcast
+	broadcast "intro"
+	broadcast "outro"
+}
+run "assets", {Title: "My Game"}
+`)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, DiagnosticCodeUnhandledBroadcast, diags[0].Code)
+		assert.Equal(t, uint32(7), diags[0].Range.Start.Line)
+	})
+
+	t.Run("AllCodes", func(t *testing.T) {
+		s := newServer(`
+onStart => {
+	//xlsw:ignore
+	broadcast "intro"
+}
+run "assets", {Title: "My Game"}
+`)
+		assert.Empty(t, diagnosticsFor(t, s))
+	})
+
+	t.Run("OtherCode", func(t *testing.T) {
+		s := newServer(`
+onStart => {
+	//xlsw:ignore spx0012
+	broadcast "intro"
+}
+run "assets", {Title: "My Game"}
+`)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, DiagnosticCodeUnhandledBroadcast, diags[0].Code)
+	})
+
+	t.Run("CodeAction", func(t *testing.T) {
+		s := newServer(`
+onStart => {
+	broadcast "intro"
+	//xlsw:ignore spx0012
+	broadcast "outro"
+}
+run "assets", {Title: "My Game"}
+`)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 2)
+
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Context:      CodeActionContext{Diagnostics: diags},
+		})
+		require.NoError(t, err)
+		require.Len(t, actions, 2)
+		assert.Equal(t, CodeAction{
+			Title:       "Ignore spx0002 on this line",
+			Kind:        QuickFix,
+			Diagnostics: []Diagnostic{diags[0]},
+			Edit: &WorkspaceEdit{
+				Changes: map[DocumentURI][]TextEdit{
+					"file:///main.spx": {{
+						Range: Range{
+							Start: Position{Line: 2, Character: 0},
+							End:   Position{Line: 2, Character: 0},
+						},
+						NewText: "\t//xlsw:ignore spx0002\n",
+					}},
+				},
+			},
+		}, actions[0])
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///main.spx": {{
+				Range: Range{
+					Start: Position{Line: 3, Character: 22},
+					End:   Position{Line: 3, Character: 22},
+				},
+				NewText: " spx0002",
+			}},
+		}, actions[1].Edit.Changes)
+	})
+}
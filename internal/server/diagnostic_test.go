@@ -1,6 +1,7 @@
 package server
 
 import (
+	"context"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -57,7 +58,7 @@ func TestServerTextDocumentDiagnostic(t *testing.T) {
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		report, err := s.textDocumentDiagnostic(params)
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
 		require.NoError(t, err)
 		require.NotNil(t, report)
 
@@ -79,7 +80,7 @@ var (
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		report, err := s.textDocumentDiagnostic(params)
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
 		require.NoError(t, err)
 		require.NotNil(t, report)
 
@@ -89,6 +90,7 @@ var (
 		require.Len(t, fullReport.Items, 2)
 		assert.Contains(t, fullReport.Items, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeParseError,
 			Message:  "expected ')', found 'EOF'",
 			Range: Range{
 				Start: Position{Line: 3, Character: 23},
@@ -97,6 +99,7 @@ var (
 		})
 		assert.Contains(t, fullReport.Items, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeParseError,
 			Message:  "expected ';', found 'EOF'",
 			Range: Range{
 				Start: Position{Line: 3, Character: 23},
@@ -113,7 +116,7 @@ var (
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.gop"},
 		}
 
-		report, err := s.textDocumentDiagnostic(params)
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
 		require.NoError(t, err)
 		require.NotNil(t, report)
 
@@ -131,7 +134,7 @@ var (
 			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
 		}
 
-		report, err := s.textDocumentDiagnostic(params)
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
 		require.NoError(t, err)
 		require.NotNil(t, report)
 
@@ -141,6 +144,7 @@ var (
 		require.Len(t, fullReport.Items, 1)
 		assert.Contains(t, fullReport.Items, Diagnostic{
 			Severity: SeverityError,
+			Code:     DiagnosticCodeInvalidPackageName,
 			Message:  "package name must be main",
 			Range: Range{
 				Start: Position{Line: 0, Character: 8},
@@ -155,7 +159,7 @@ var (
 			TextDocument: TextDocumentIdentifier{URI: "file:///notexist.spx"},
 		}
 
-		report, err := s.textDocumentDiagnostic(params)
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
 		require.NoError(t, err)
 		require.NotNil(t, report)
 
@@ -164,13 +168,54 @@ var (
 		assert.Equal(t, string(DiagnosticFull), fullReport.Kind)
 		assert.Empty(t, fullReport.Items)
 	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+		params := &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		}
+
+		report, err := s.textDocumentDiagnostic(context.Background(), params)
+		require.NoError(t, err)
+		require.NotNil(t, report)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok, "expected RelatedFullDocumentDiagnosticReport")
+		require.NotEmpty(t, fullReport.ResultID)
+
+		params.PreviousResultID = fullReport.ResultID
+		report, err = s.textDocumentDiagnostic(context.Background(), params)
+		require.NoError(t, err)
+		require.NotNil(t, report)
+		unchangedReport, ok := report.Value.(RelatedUnchangedDocumentDiagnosticReport)
+		require.True(t, ok, "expected RelatedUnchangedDocumentDiagnosticReport")
+		assert.Equal(t, string(DiagnosticUnchanged), unchangedReport.Kind)
+		assert.Equal(t, fullReport.ResultID, unchangedReport.ResultID)
+
+		fileMap := newTestFileMap()
+		fileMap["main.spx"] = []byte(`
+var (
+	MyAircraft MyAircraft
+	Bullet     Bullet
+)
+run "assets", {Title: "Bullet (by Go+)"}
+echo undefinedVar
+`)
+		s = New(newMapFSWithoutModTime(fileMap), nil)
+		report, err = s.textDocumentDiagnostic(context.Background(), params)
+		require.NoError(t, err)
+		require.NotNil(t, report)
+		fullReport2, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok, "expected RelatedFullDocumentDiagnosticReport")
+		assert.NotEqual(t, fullReport.ResultID, fullReport2.ResultID)
+		assert.NotEmpty(t, fullReport2.Items)
+	})
 }
 
 func TestServerWorkspaceDiagnostic(t *testing.T) {
 	t.Run("Normal", func(t *testing.T) {
 		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 3)
@@ -195,11 +240,15 @@ func TestServerWorkspaceDiagnostic(t *testing.T) {
 var (
 	MyAircraft MyAircraft
 `),
-			"MyAircraft.spx":    []byte(`var x int`),
+			"MyAircraft.spx": []byte(`var x int
+onStart => {
+	echo x
+}
+`),
 			"assets/index.json": []byte(`{}`),
 		}), nil)
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -209,6 +258,7 @@ var (
 				require.Len(t, fullReport.Items, 2)
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeParseError,
 					Message:  "expected ')', found 'EOF'",
 					Range: Range{
 						Start: Position{Line: 3, Character: 23},
@@ -217,6 +267,7 @@ var (
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeParseError,
 					Message:  "expected ';', found 'EOF'",
 					Range: Range{
 						Start: Position{Line: 3, Character: 23},
@@ -232,7 +283,7 @@ var (
 	t.Run("EmptyWorkspace", func(t *testing.T) {
 		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.EqualError(t, err, "no valid main.spx file found in main package")
 		require.Nil(t, report)
 	})
@@ -261,11 +312,12 @@ onStart => {
 	play Sound1
 	play Sound2
 }
+
 `),
 			"assets/index.json": []byte(`{}`),
 		}), nil)
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -277,6 +329,7 @@ onStart => {
 				require.Len(t, fullReport.Items, 3)
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeEmptyResourceName,
 					Message:  "sound resource name cannot be empty",
 					Range: Range{
 						Start: Position{Line: 8, Character: 6},
@@ -285,7 +338,9 @@ onStart => {
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeResourceNotFound,
 					Message:  `sound resource "ConstSoundName" not found`,
+					Data:     makeDiagnosticData(DiagnosticData{Fix: DiagnosticFixCreateResource, Name: "spx://resources/sounds/ConstSoundName"}),
 					Range: Range{
 						Start: Position{Line: 9, Character: 6},
 						End:   Position{Line: 9, Character: 20},
@@ -293,7 +348,9 @@ onStart => {
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeResourceNotFound,
 					Message:  `sound resource "LiteralSoundName" not found`,
+					Data:     makeDiagnosticData(DiagnosticData{Fix: DiagnosticFixCreateResource, Name: "spx://resources/sounds/LiteralSoundName"}),
 					Range: Range{
 						Start: Position{Line: 10, Character: 6},
 						End:   Position{Line: 10, Character: 24},
@@ -325,7 +382,7 @@ onStart => {
 			"assets/index.json": []byte(`{}`),
 		}), nil)
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -337,6 +394,7 @@ onStart => {
 				require.Len(t, fullReport.Items, 2)
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeEmptyResourceName,
 					Message:  "backdrop resource name cannot be empty",
 					Range: Range{
 						Start: Position{Line: 1, Character: 11},
@@ -345,6 +403,7 @@ onStart => {
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeResourceNotFound,
 					Message:  `backdrop resource "NonExistentBackdrop" not found`,
 					Range: Range{
 						Start: Position{Line: 2, Character: 11},
@@ -355,6 +414,7 @@ onStart => {
 				require.Len(t, fullReport.Items, 2)
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeResourceNotFound,
 					Message:  `backdrop resource "ConstBackdropName" not found`,
 					Range: Range{
 						Start: Position{Line: 5, Character: 12},
@@ -363,6 +423,7 @@ onStart => {
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeResourceNotFound,
 					Message:  `backdrop resource "LiteralBackdropName" not found`,
 					Range: Range{
 						Start: Position{Line: 6, Character: 12},
@@ -401,7 +462,7 @@ onStart => {
 			"assets/index.json": []byte(`{}`),
 		}), nil)
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 3)
@@ -412,7 +473,9 @@ onStart => {
 			case "file:///MySprite1.spx":
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeResourceNotFound,
 					Message:  `sprite resource "MySprite1" not found`,
+					Data:     makeDiagnosticData(DiagnosticData{Fix: DiagnosticFixCreateResource, Name: "spx://resources/sprites/MySprite1"}),
 					Range: Range{
 						Start: Position{Line: 3, Character: 1},
 						End:   Position{Line: 3, Character: 18},
@@ -420,7 +483,9 @@ onStart => {
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeResourceNotFound,
 					Message:  `sprite resource "MySprite2" not found`,
+					Data:     makeDiagnosticData(DiagnosticData{Fix: DiagnosticFixCreateResource, Name: "spx://resources/sprites/MySprite2"}),
 					Range: Range{
 						Start: Position{Line: 4, Character: 1},
 						End:   Position{Line: 4, Character: 10},
@@ -429,7 +494,9 @@ onStart => {
 			case "file:///MySprite2.spx":
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeResourceNotFound,
 					Message:  `sprite resource "MySprite2" not found`,
+					Data:     makeDiagnosticData(DiagnosticData{Fix: DiagnosticFixCreateResource, Name: "spx://resources/sprites/MySprite2"}),
 					Range: Range{
 						Start: Position{Line: 3, Character: 1},
 						End:   Position{Line: 3, Character: 18},
@@ -437,7 +504,9 @@ onStart => {
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeResourceNotFound,
 					Message:  `sprite resource "MySprite2" not found`,
+					Data:     makeDiagnosticData(DiagnosticData{Fix: DiagnosticFixCreateResource, Name: "spx://resources/sprites/MySprite2"}),
 					Range: Range{
 						Start: Position{Line: 4, Character: 1},
 						End:   Position{Line: 4, Character: 10},
@@ -452,6 +521,9 @@ onStart => {
 	t.Run("SpriteCostumeResourceNotFound", func(t *testing.T) {
 		s := New(newMapFSWithoutModTime(map[string][]byte{
 			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
 run "assets", {Title: "My Game"}
 `),
 			"MySprite.spx": []byte(`
@@ -464,7 +536,7 @@ onStart => {
 			"assets/sprites/MySprite/index.json": []byte(`{}`),
 		}), nil)
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -476,6 +548,7 @@ onStart => {
 				require.Len(t, fullReport.Items, 2)
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeEmptyResourceName,
 					Message:  "sprite costume resource name cannot be empty",
 					Range: Range{
 						Start: Position{Line: 2, Character: 12},
@@ -484,6 +557,7 @@ onStart => {
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeResourceNotFound,
 					Message:  `costume resource "NonExistentCostume" not found in sprite "MySprite"`,
 					Range: Range{
 						Start: Position{Line: 3, Character: 12},
@@ -499,6 +573,9 @@ onStart => {
 	t.Run("SpriteAnimationResourceNotFound", func(t *testing.T) {
 		s := New(newMapFSWithoutModTime(map[string][]byte{
 			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
 run "assets", {Title: "My Game"}
 `),
 			"MySprite.spx": []byte(`
@@ -511,7 +588,7 @@ onStart => {
 			"assets/sprites/MySprite/index.json": []byte(`{}`),
 		}), nil)
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -523,6 +600,7 @@ onStart => {
 				require.Len(t, fullReport.Items, 2)
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeEmptyResourceName,
 					Message:  "sprite animation resource name cannot be empty",
 					Range: Range{
 						Start: Position{Line: 2, Character: 9},
@@ -531,6 +609,7 @@ onStart => {
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeResourceNotFound,
 					Message:  `animation resource "roll-in" not found in sprite "MySprite"`,
 					Range: Range{
 						Start: Position{Line: 3, Character: 9},
@@ -546,6 +625,9 @@ onStart => {
 	t.Run("WidgetResourceNotFound", func(t *testing.T) {
 		s := New(newMapFSWithoutModTime(map[string][]byte{
 			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
 run "assets", {Title: "My Game"}
 `),
 			"MySprite.spx": []byte(`
@@ -562,7 +644,7 @@ onStart => {
 			"assets/index.json": []byte(`{}`),
 		}), nil)
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -574,6 +656,7 @@ onStart => {
 				require.Len(t, fullReport.Items, 3)
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeEmptyResourceName,
 					Message:  "widget resource name cannot be empty",
 					Range: Range{
 						Start: Position{Line: 5, Character: 20},
@@ -582,6 +665,7 @@ onStart => {
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeResourceNotFound,
 					Message:  `widget resource "ConstWidgetName" not found`,
 					Range: Range{
 						Start: Position{Line: 6, Character: 20},
@@ -590,6 +674,7 @@ onStart => {
 				})
 				assert.Contains(t, fullReport.Items, Diagnostic{
 					Severity: SeverityError,
+					Code:     DiagnosticCodeResourceNotFound,
 					Message:  `widget resource "LiteralWidgetName" not found`,
 					Range: Range{
 						Start: Position{Line: 7, Character: 20},
@@ -605,6 +690,9 @@ onStart => {
 	t.Run("WithNonBasicTypeAliases", func(t *testing.T) {
 		s := New(newMapFSWithoutModTime(map[string][]byte{
 			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
 run "assets", {Title: "My Game"}
 `),
 			"MySprite.spx": []byte(`
@@ -618,7 +706,7 @@ onStart => {
 			"assets/sprites/MySprite/index.json": []byte(`{}`),
 		}), nil)
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -641,7 +729,7 @@ run "assets", {Title: "My Game"}
 			"assets/index.json": []byte(`{}`),
 		}), nil)
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 1)
@@ -667,7 +755,7 @@ run "assets", {Title: "My Game"}
 			"assets/sprites/MySprite/index.json": []byte(`{}`),
 		}), nil)
 
-		report, err := s.workspaceDiagnostic(&WorkspaceDiagnosticParams{})
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
 		require.NoError(t, err)
 		require.NotNil(t, report)
 		assert.Len(t, report.Items, 2)
@@ -677,4 +765,101 @@ run "assets", {Title: "My Game"}
 			assert.Empty(t, fullReport.Items)
 		}
 	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+
+		report, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
+		require.NoError(t, err)
+		require.NotNil(t, report)
+
+		var previousResultIDs []PreviousResultID
+		for _, item := range report.Items {
+			fullReport := item.Value.(WorkspaceFullDocumentDiagnosticReport)
+			if fullReport.URI == "file:///main.spx" {
+				previousResultIDs = append(previousResultIDs, PreviousResultID{
+					URI:   fullReport.URI,
+					Value: fullReport.ResultID,
+				})
+			}
+		}
+		require.Len(t, previousResultIDs, 1)
+
+		report, err = s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{PreviousResultIds: previousResultIDs})
+		require.NoError(t, err)
+		require.NotNil(t, report)
+		assert.Len(t, report.Items, 3)
+		for _, item := range report.Items {
+			switch item := item.Value.(type) {
+			case WorkspaceUnchangedDocumentDiagnosticReport:
+				assert.Equal(t, DocumentURI("file:///main.spx"), item.URI)
+				assert.Equal(t, string(DiagnosticUnchanged), item.Kind)
+				assert.Equal(t, previousResultIDs[0].Value, item.ResultID)
+			case WorkspaceFullDocumentDiagnosticReport:
+				assert.NotEqual(t, DocumentURI("file:///main.spx"), item.URI)
+				assert.Equal(t, string(DiagnosticFull), item.Kind)
+			default:
+				t.Fatalf("unexpected report type: %T", item)
+			}
+		}
+	})
+}
+
+func TestServerSpxGetDiagnosticsSummary(t *testing.T) {
+	newServer := func() *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+import "fmt"
+
+var (
+	MySprite MySprite
+)
+play "Sound1"
+play "Sound2"
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	setCostume "costume1"
+}
+`),
+			"Bullet.spx":                         []byte(``),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer()
+		summary, err := s.spxGetDiagnosticsSummary(context.Background())
+		require.NoError(t, err)
+		require.NotNil(t, summary)
+		assert.Equal(t, SpxDiagnosticCounts{Errors: 3, Warnings: 1}, summary.SpxDiagnosticCounts)
+		assert.Equal(t, []SpxDocumentDiagnosticsSummary{
+			{URI: "file:///MySprite.spx", SpxDiagnosticCounts: SpxDiagnosticCounts{Errors: 1}},
+			{URI: "file:///main.spx", SpxDiagnosticCounts: SpxDiagnosticCounts{Errors: 2, Warnings: 1}},
+		}, summary.Documents)
+		assert.Equal(t, []SpxDiagnosticCodeCount{
+			{Code: DiagnosticCodeResourceNotFound, Count: 3},
+			{Code: DiagnosticCodeUnusedImport, Count: 1},
+		}, summary.TopCodes)
+	})
+
+	t.Run("SeverityOverrides", func(t *testing.T) {
+		s := newServer()
+		s.currentConfig.Store(&Config{Diagnostics: DiagnosticsConfig{SeverityOverrides: map[string]string{
+			DiagnosticCodeUnusedImport: "error",
+		}}})
+		summary, err := s.spxGetDiagnosticsSummary(context.Background())
+		require.NoError(t, err)
+		require.NotNil(t, summary)
+		assert.Equal(t, SpxDiagnosticCounts{Errors: 4}, summary.SpxDiagnosticCounts)
+	})
+
+	t.Run("EmptyWorkspace", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		summary, err := s.spxGetDiagnosticsSummary(context.Background())
+		require.EqualError(t, err, "no valid main.spx file found in main package")
+		assert.Nil(t, summary)
+	})
 }
@@ -1,11 +1,22 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
+	"io/fs"
+	"path"
+	"regexp"
 	"slices"
+	"strconv"
+	"strings"
 
 	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
 )
 
+// commentURLRE matches URLs in comments.
+var commentURLRE = regexp.MustCompile(`https?://[^\s<>"']*[^\s<>"'.,;:!?)\]}]`)
+
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_documentLink
 func (s *Server) textDocumentDocumentLink(params *DocumentLinkParams) (links []DocumentLink, err error) {
 	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
@@ -64,5 +75,96 @@ func (s *Server) textDocumentDocumentLink(params *DocumentLinkParams) (links []D
 	for ident := range result.typeInfo.Uses {
 		addLinksForIdent(ident)
 	}
+
+	// Add links for asset paths. Their targets are computed lazily in
+	// documentLink/resolve.
+	folder, _, err := s.workspaceFolderFor(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := s.workspaceFolderSnapshot(folder)
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		basicLit, ok := node.(*gopast.BasicLit)
+		if !ok || basicLit.Kind != goptoken.STRING {
+			return true
+		}
+		assetPath, err := strconv.Unquote(basicLit.Value)
+		if err != nil || !result.isSpxResourcePath(assetPath) {
+			return true
+		}
+		if _, err := fs.Stat(snapshot, assetPath); err != nil {
+			return true
+		}
+		links = append(links, DocumentLink{
+			Range: result.rangeForNode(basicLit),
+			Data:  AssetPathDocumentLinkData{Path: path.Join(folder.dir, assetPath)},
+		})
+		return true
+	})
+
+	// Add links for URLs in comments.
+	for _, commentGroup := range astFile.Comments {
+		for _, comment := range commentGroup.List {
+			for _, loc := range commentURLRE.FindAllStringIndex(comment.Text, -1) {
+				target := URI(comment.Text[loc[0]:loc[1]])
+				links = append(links, DocumentLink{
+					Range: Range{
+						Start: result.rangeForPos(comment.Pos() + goptoken.Pos(loc[0])).Start,
+						End:   result.rangeForPos(comment.Pos() + goptoken.Pos(loc[1])).Start,
+					},
+					Target: &target,
+				})
+			}
+		}
+	}
 	return
 }
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#documentLink_resolve
+func (s *Server) documentLinkResolve(params *DocumentLink) (*DocumentLink, error) {
+	if params.Target != nil || params.Data == nil {
+		return params, nil
+	}
+	raw, err := json.Marshal(params.Data)
+	if err != nil {
+		return params, nil
+	}
+	var data AssetPathDocumentLinkData
+	if err := json.Unmarshal(raw, &data); err != nil || data.Path == "" {
+		return params, nil
+	}
+
+	link := *params
+	link.Target = s.assetPathTarget(data.Path)
+	return &link, nil
+}
+
+// assetPathTarget returns the link target for the given asset path. Paths of
+// spx resources are mapped to their spx resource URIs so that the client can
+// open them in its asset views. Other paths are mapped to file URIs.
+func (s *Server) assetPathTarget(assetPath string) *URI {
+	documentURI := s.toDocumentURI(assetPath)
+	if folder, folderAssetPath, err := s.workspaceFolderFor(documentURI); err == nil {
+		if result, err := s.compileWorkspaceFolder(context.Background(), folder); err == nil && result.spxResourceRootDir != "" {
+			relPath := strings.TrimPrefix(folderAssetPath, result.spxResourceRootDir+"/")
+			if relPath != folderAssetPath {
+				uri := SpxResourceURI("spx://resources/" + relPath)
+				if _, err := ParseSpxResourceURI(uri); err == nil {
+					target := URI(uri)
+					return &target
+				}
+			}
+		}
+	}
+	target := URI(documentURI)
+	return &target
+}
+
+// isSpxResourcePath reports whether the given path refers to the spx resource
+// root directory or a file within it.
+func (r *compileResult) isSpxResourcePath(p string) bool {
+	if r.spxResourceRootDir == "" || p != path.Clean(p) {
+		return false
+	}
+	return p == r.spxResourceRootDir || strings.HasPrefix(p, r.spxResourceRootDir+"/")
+}
@@ -40,7 +40,14 @@ onStart => {
 		}
 		linksForMainSpx, err := s.textDocumentDocumentLink(paramsForMainSpx)
 		require.NoError(t, err)
-		require.Len(t, linksForMainSpx, 14)
+		require.Len(t, linksForMainSpx, 15)
+		assert.Contains(t, linksForMainSpx, DocumentLink{
+			Range: Range{
+				Start: Position{Line: 7, Character: 4},
+				End:   Position{Line: 7, Character: 12},
+			},
+			Data: AssetPathDocumentLinkData{Path: "assets"},
+		})
 		assert.Contains(t, linksForMainSpx, DocumentLink{
 			Range: Range{
 				Start: Position{Line: 1, Character: 6},
@@ -257,6 +264,45 @@ onStart => {
 		})
 	})
 
+	t.Run("AssetPathsAndURLs", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+// See https://goplus.org/spx for details.
+echo "assets/sprites/MySprite"
+echo "assets/sprites/Missing"
+echo "other/file.txt"
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+			"other/file.txt":                     []byte(``),
+		}), nil)
+
+		links, err := s.textDocumentDocumentLink(&DocumentLinkParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		assert.Contains(t, links, DocumentLink{
+			Range: Range{
+				Start: Position{Line: 1, Character: 7},
+				End:   Position{Line: 1, Character: 29},
+			},
+			Target: toURI("https://goplus.org/spx"),
+		})
+		assert.Contains(t, links, DocumentLink{
+			Range: Range{
+				Start: Position{Line: 2, Character: 5},
+				End:   Position{Line: 2, Character: 30},
+			},
+			Data: AssetPathDocumentLinkData{Path: "assets/sprites/MySprite"},
+		})
+		for _, link := range links {
+			if data, ok := link.Data.(AssetPathDocumentLinkData); ok {
+				assert.NotEqual(t, "assets/sprites/Missing", data.Path)
+				assert.NotEqual(t, "other/file.txt", data.Path)
+			}
+		}
+	})
+
 	t.Run("NonSpxFile", func(t *testing.T) {
 		s := New(newMapFSWithoutModTime(map[string][]byte{
 			"main.gop": []byte(`echo "Hello, Go+!"`),
@@ -324,3 +370,36 @@ var (
 		})
 	})
 }
+
+func TestServerDocumentLinkResolve(t *testing.T) {
+	s := New(newMapFSWithoutModTime(map[string][]byte{
+		"main.spx":                           []byte(`run "assets", {Title: "My Game"}`),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{}`),
+	}), nil)
+
+	t.Run("SpxResource", func(t *testing.T) {
+		link, err := s.documentLinkResolve(&DocumentLink{
+			Data: map[string]any{"path": "assets/sprites/MySprite"},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, link)
+		assert.Equal(t, toURI("spx://resources/sprites/MySprite"), link.Target)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		link, err := s.documentLinkResolve(&DocumentLink{
+			Data: AssetPathDocumentLinkData{Path: "assets/sprites/MySprite/index.json"},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, link)
+		assert.Equal(t, toURI("file:///assets/sprites/MySprite/index.json"), link.Target)
+	})
+
+	t.Run("WithTarget", func(t *testing.T) {
+		params := &DocumentLink{Target: toURI("https://goplus.org")}
+		link, err := s.documentLinkResolve(params)
+		require.NoError(t, err)
+		assert.Equal(t, params, link)
+	})
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+)
+
+// sourceAddEventHandler is the kind of code actions adding spx event handler
+// stubs.
+const sourceAddEventHandler CodeActionKind = "source.addEventHandler"
+
+// spxEventHandlerStub is the skeleton of an spx event handler of sprites.
+type spxEventHandlerStub struct {
+	// name is the name of the event handler, e.g., "onStart".
+	name string
+
+	// code is the skeleton of the event handler.
+	code string
+
+	// repeatable reports whether the event handler is usually registered more
+	// than once with different arguments, so its stub is always available.
+	repeatable bool
+}
+
+// spxEventHandlerStubs are the stubs of spx event handlers of sprites, in the
+// order they are offered.
+var spxEventHandlerStubs = []spxEventHandlerStub{
+	{name: "onStart", code: "onStart => {\n}\n"},
+	{name: "onClick", code: "onClick => {\n}\n"},
+	{name: "onCloned", code: "onCloned => {\n}\n"},
+	{name: "onTouchStart", code: "onTouchStart => {\n}\n"},
+	{name: "onMoving", code: "onMoving => {\n}\n"},
+	{name: "onTurning", code: "onTurning => {\n}\n"},
+	{name: "onAnyKey", code: "onAnyKey key => {\n}\n"},
+	{name: "onKey", code: "onKey KeyA, => {\n}\n", repeatable: true},
+	{name: "onMsg", code: "onMsg \"message\", => {\n}\n", repeatable: true},
+}
+
+// spxEventHandlerStubCodeActions returns the code actions adding stubs of the
+// spx event handlers that are not defined yet in the given sprite file. The
+// stubs are appended to the given content of the file, separated from the
+// existing code by a blank line.
+func (r *compileResult) spxEventHandlerStubCodeActions(documentURI DocumentURI, astFile *gopast.File, content []byte) []CodeAction {
+	defined := make(map[string]struct{})
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		callExpr, ok := node.(*gopast.CallExpr)
+		if !ok {
+			return true
+		}
+		funcIdent := funcIdentOf(callExpr.Fun)
+		if funcIdent == nil {
+			return true
+		}
+		funcObj := r.typeInfo.ObjectOf(funcIdent)
+		if !isSpxPkgObject(funcObj) {
+			return true
+		}
+		funcName, _ := parseGopFuncName(funcObj.Name())
+		defined[funcName] = struct{}{}
+		return true
+	})
+
+	var prefix string
+	if len(content) > 0 {
+		if !bytes.HasSuffix(content, []byte("\n")) {
+			prefix = "\n\n"
+		} else if !bytes.HasSuffix(content, []byte("\n\n")) {
+			prefix = "\n"
+		}
+	}
+	lastLineStart := bytes.LastIndexByte(content, '\n') + 1
+	end := Position{
+		Line:      uint32(bytes.Count(content, []byte("\n"))),
+		Character: uint32(utf8OffsetToEncoding(string(content[lastLineStart:]), len(content)-lastLineStart, r.positionEncoding)),
+	}
+
+	var actions []CodeAction
+	for _, stub := range spxEventHandlerStubs {
+		if _, ok := defined[stub.name]; ok && !stub.repeatable {
+			continue
+		}
+		actions = append(actions, CodeAction{
+			Title: fmt.Sprintf("Add %s event handler", stub.name),
+			Kind:  sourceAddEventHandler,
+			Edit: &WorkspaceEdit{
+				Changes: map[DocumentURI][]TextEdit{
+					documentURI: {{
+						Range:   Range{Start: end, End: end},
+						NewText: prefix + stub.code,
+					}},
+				},
+			},
+		})
+	}
+	return actions
+}
+
+// codeActionKindRequested reports whether code actions of the given kind are
+// requested by the given kinds the client is interested in. All kinds are
+// requested if no kind is given.
+func codeActionKindRequested(only []CodeActionKind, kind CodeActionKind) bool {
+	if len(only) == 0 {
+		return true
+	}
+	for _, o := range only {
+		if kind == o || strings.HasPrefix(string(kind), string(o)+".") {
+			return true
+		}
+	}
+	return false
+}
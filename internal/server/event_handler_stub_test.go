@@ -0,0 +1,138 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSpxEventHandlerStubCodeActions(t *testing.T) {
+	codeActionsFor := func(t *testing.T, s *Server, uri DocumentURI) []CodeAction {
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+			Context:      CodeActionContext{Only: []CodeActionKind{sourceAddEventHandler}},
+		})
+		require.NoError(t, err)
+		return actions
+	}
+	titlesOf := func(actions []CodeAction) []string {
+		titles := make([]string, 0, len(actions))
+		for _, action := range actions {
+			titles = append(titles, action.Title)
+		}
+		return titles
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		fileMap := newTestFileMap()
+		fileMap["MyAircraft.spx"] = []byte(`onStart => {
+	onMsg "hit", => {
+		hide
+	}
+}
+`)
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		actions := codeActionsFor(t, s, "file:///MyAircraft.spx")
+		assert.Equal(t, []string{
+			"Add onClick event handler",
+			"Add onCloned event handler",
+			"Add onTouchStart event handler",
+			"Add onMoving event handler",
+			"Add onTurning event handler",
+			"Add onAnyKey event handler",
+			"Add onKey event handler",
+			"Add onMsg event handler",
+		}, titlesOf(actions))
+
+		assert.Equal(t, CodeAction{
+			Title: "Add onClick event handler",
+			Kind:  sourceAddEventHandler,
+			Edit: &WorkspaceEdit{
+				Changes: map[DocumentURI][]TextEdit{
+					"file:///MyAircraft.spx": {{
+						Range: Range{
+							Start: Position{Line: 5, Character: 0},
+							End:   Position{Line: 5, Character: 0},
+						},
+						NewText: "\nonClick => {\n}\n",
+					}},
+				},
+			},
+		}, actions[0])
+		assert.Equal(t, "\nonMsg \"message\", => {\n}\n", actions[7].Edit.Changes["file:///MyAircraft.spx"][0].NewText)
+	})
+
+	t.Run("WithoutTrailingNewline", func(t *testing.T) {
+		fileMap := newTestFileMap()
+		fileMap["MyAircraft.spx"] = []byte(`onStart => {
+}`)
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		actions := codeActionsFor(t, s, "file:///MyAircraft.spx")
+		require.NotEmpty(t, actions)
+		assert.Equal(t, []TextEdit{{
+			Range: Range{
+				Start: Position{Line: 1, Character: 1},
+				End:   Position{Line: 1, Character: 1},
+			},
+			NewText: "\n\nonClick => {\n}\n",
+		}}, actions[0].Edit.Changes["file:///MyAircraft.spx"])
+	})
+
+	t.Run("WithTrailingBlankLine", func(t *testing.T) {
+		fileMap := newTestFileMap()
+		fileMap["MyAircraft.spx"] = []byte("onStart => {\n}\n\n")
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		actions := codeActionsFor(t, s, "file:///MyAircraft.spx")
+		require.NotEmpty(t, actions)
+		assert.Equal(t, []TextEdit{{
+			Range: Range{
+				Start: Position{Line: 3, Character: 0},
+				End:   Position{Line: 3, Character: 0},
+			},
+			NewText: "onClick => {\n}\n",
+		}}, actions[0].Edit.Changes["file:///MyAircraft.spx"])
+	})
+
+	t.Run("EmptyFile", func(t *testing.T) {
+		fileMap := newTestFileMap()
+		fileMap["MyAircraft.spx"] = []byte(``)
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		actions := codeActionsFor(t, s, "file:///MyAircraft.spx")
+		require.Len(t, actions, len(spxEventHandlerStubs))
+		assert.Equal(t, []TextEdit{{
+			Range:   Range{Start: Position{}, End: Position{}},
+			NewText: "onStart => {\n}\n",
+		}}, actions[0].Edit.Changes["file:///MyAircraft.spx"])
+	})
+
+	t.Run("KindFilter", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MyAircraft.spx"},
+			Context:      CodeActionContext{Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, actions)
+
+		actions, err = s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MyAircraft.spx"},
+			Context:      CodeActionContext{Only: []CodeActionKind{"source"}},
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, actions)
+
+		actions, err = s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MyAircraft.spx"},
+		})
+		require.NoError(t, err)
+		assert.NotEmpty(t, actions)
+	})
+
+	t.Run("MainSpx", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newTestFileMap()), nil)
+		actions := codeActionsFor(t, s, "file:///main.spx")
+		assert.Empty(t, actions)
+	})
+}
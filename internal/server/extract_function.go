@@ -0,0 +1,307 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"go/types"
+	"slices"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// refactorExtractFunction is the kind of code actions extracting statements
+// into functions.
+const refactorExtractFunction CodeActionKind = RefactorExtract + ".function"
+
+// extractedFunctionName is the base name of functions extracted by
+// [compileResult.extractFunctionCodeAction].
+const extractedFunctionName = "newFunction"
+
+// extractFunctionCodeAction returns the code action extracting the statements
+// selected by the given range in the given file into a new function of the
+// class of the file. The statements must be in the same block of an spx event
+// handler, e.g., `onStart => { ... }`. The local variables they use from the
+// handler become parameters of the new function, and the statements are
+// replaced by a call to it.
+//
+// It reports false if the statements cannot be extracted without changing
+// their behavior, i.e., if they return from the handler, break out of or
+// continue loops around them, assign local variables declared before them, or
+// declare variables used after them.
+func (r *compileResult) extractFunctionCodeAction(spxFile string, astFile *gopast.File, selection Range) (CodeAction, bool) {
+	if selection.Start == selection.End {
+		return CodeAction{}, false
+	}
+	classType := r.spxClassTypeFor(spxFile)
+	if classType == nil {
+		return CodeAction{}, false
+	}
+	stmts, block, path := r.selectedStmtsIn(astFile, r.posAt(astFile, selection.Start), r.posAt(astFile, selection.End))
+	if len(stmts) == 0 || innermostFuncLitIn(path) == nil {
+		return CodeAction{}, false
+	}
+	start, end := stmts[0].Pos(), stmts[len(stmts)-1].End()
+	if !r.isInSpxEventHandler(start) {
+		return CodeAction{}, false
+	}
+
+	// Variables declared by the statements are told apart by their defining
+	// identifiers, as some of them, e.g., those of `for x <- list`, do not have
+	// valid positions.
+	declared := make(map[*types.Var]bool)
+	for _, stmt := range stmts {
+		gopast.Inspect(stmt, func(node gopast.Node) bool {
+			if ident, ok := node.(*gopast.Ident); ok {
+				if v, ok := r.typeInfo.Defs[ident].(*types.Var); ok {
+					declared[v] = true
+				}
+			}
+			return true
+		})
+	}
+
+	var (
+		params     []*types.Var
+		isParam    = make(map[*types.Var]bool)
+		isOuterVar = func(v *types.Var) bool {
+			return v.Pkg() == r.mainPkg && !v.IsField() && v.Parent() != nil && v.Parent() != r.mainPkg.Scope() && !declared[v]
+		}
+		extractable = true
+		stack       []gopast.Node
+	)
+	for _, stmt := range stmts {
+		gopast.Inspect(stmt, func(node gopast.Node) bool {
+			if !extractable {
+				return false
+			}
+			if node == nil {
+				stack = stack[:len(stack)-1]
+				return true
+			}
+			stack = append(stack, node)
+
+			switch node := node.(type) {
+			case *gopast.ReturnStmt:
+				extractable = innermostFuncLitIn(stack) != nil
+			case *gopast.BranchStmt:
+				extractable = isBranchTargetIn(stack, node)
+			case *gopast.AssignStmt:
+				for _, lhs := range node.Lhs {
+					if ident, ok := lhs.(*gopast.Ident); ok {
+						if v, ok := r.typeInfo.Uses[ident].(*types.Var); ok && isOuterVar(v) {
+							extractable = false
+						}
+					}
+				}
+			case *gopast.IncDecStmt:
+				if ident, ok := node.X.(*gopast.Ident); ok {
+					if v, ok := r.typeInfo.Uses[ident].(*types.Var); ok && isOuterVar(v) {
+						extractable = false
+					}
+				}
+			case *gopast.Ident:
+				v, ok := r.typeInfo.Uses[node].(*types.Var)
+				if !ok || !isOuterVar(v) || isParam[v] {
+					break
+				}
+				if typ := v.Type(); typ == nil || typ == types.Typ[types.Invalid] {
+					extractable = false
+					break
+				}
+				isParam[v] = true
+				params = append(params, v)
+			}
+			return extractable
+		})
+	}
+	if !extractable || r.usesVarsAfter(block, end, declared) {
+		return CodeAction{}, false
+	}
+
+	funcName := extractedFunctionName
+	for i := 1; r.isNameTakenAt(classType, funcName, start); i++ {
+		funcName = fmt.Sprintf("%s%d", extractedFunctionName, i)
+	}
+	paramList := make([]string, 0, len(params))
+	argList := make([]string, 0, len(params))
+	for _, param := range params {
+		paramList = append(paramList, param.Name()+" "+getSimplifiedTypeString(param.Type()))
+		argList = append(argList, param.Name())
+	}
+	call := funcName
+	if len(argList) > 0 {
+		call += " " + strings.Join(argList, ", ")
+	}
+
+	// Re-indent the statements by one tab, as the body of a top-level function.
+	code := astFile.Code
+	startOffset, endOffset := r.fset.Position(start).Offset, r.fset.Position(end).Offset
+	lineStartOffset := bytes.LastIndexByte(code[:startOffset], '\n') + 1
+	indent := string(code[lineStartOffset:startOffset])
+	if strings.TrimLeft(indent, " \t") != "" {
+		indent = ""
+	}
+	lines := strings.Split(string(code[startOffset:endOffset]), "\n")
+	for i, line := range lines {
+		if i > 0 {
+			line = strings.TrimPrefix(line, indent)
+		}
+		if strings.TrimSpace(line) != "" {
+			line = "\t" + line
+		}
+		lines[i] = line
+	}
+
+	insertPos := Position{Line: uint32(r.fset.Position(topLevelDeclStartOf(path[1])).Line - 1)}
+	documentURI := r.documentURIs[spxFile]
+	return CodeAction{
+		Title: fmt.Sprintf("Extract function %q", funcName),
+		Kind:  refactorExtractFunction,
+		Edit: &WorkspaceEdit{
+			Changes: map[DocumentURI][]TextEdit{
+				documentURI: {
+					{
+						Range:   Range{Start: insertPos, End: insertPos},
+						NewText: fmt.Sprintf("func %s(%s) {\n%s\n}\n\n", funcName, strings.Join(paramList, ", "), strings.Join(lines, "\n")),
+					},
+					{
+						Range: Range{
+							Start: r.fromPosition(astFile, r.fset.Position(start)),
+							End:   r.fromPosition(astFile, r.fset.Position(end)),
+						},
+						NewText: call,
+					},
+				},
+			},
+		},
+	}, true
+}
+
+// selectedStmtsIn returns the statements of the outermost block in the given
+// AST file that are completely in the given range, along with the block and
+// the path of nodes from the AST file to the block. It returns no statements
+// if the range partially covers any other statement of the block.
+func (r *compileResult) selectedStmtsIn(astFile *gopast.File, start, end goptoken.Pos) ([]gopast.Stmt, *gopast.BlockStmt, []gopast.Node) {
+	var (
+		stmts []gopast.Stmt
+		block *gopast.BlockStmt
+		path  []gopast.Node
+		done  bool
+	)
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		if done {
+			return false
+		}
+		if node == nil {
+			path = path[:len(path)-1]
+			return true
+		}
+		path = append(path, node)
+
+		blockStmt, ok := node.(*gopast.BlockStmt)
+		if !ok || blockStmt.Pos() > start || blockStmt.End() < end {
+			return true
+		}
+		var (
+			selected []gopast.Stmt
+			partial  bool
+		)
+		for _, stmt := range blockStmt.List {
+			if stmt.Pos() >= start && stmt.End() <= end {
+				selected = append(selected, stmt)
+			} else if stmt.Pos() < end && stmt.End() > start {
+				partial = true
+			}
+		}
+		if len(selected) == 0 {
+			return true
+		}
+		done = true
+		if !partial {
+			stmts, block = selected, blockStmt
+			path = slices.Clone(path)
+		}
+		return false
+	})
+	return stmts, block, path
+}
+
+// isBranchTargetIn reports whether the target of the given branch statement is
+// in the given path of nodes leading to it.
+func isBranchTargetIn(path []gopast.Node, branchStmt *gopast.BranchStmt) bool {
+	if branchStmt.Label != nil || (branchStmt.Tok != goptoken.BREAK && branchStmt.Tok != goptoken.CONTINUE) {
+		return false
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		switch path[i].(type) {
+		case *gopast.ForStmt, *gopast.RangeStmt, *gopast.ForPhraseStmt:
+			return true
+		case *gopast.SwitchStmt, *gopast.TypeSwitchStmt, *gopast.SelectStmt:
+			if branchStmt.Tok == goptoken.BREAK {
+				return true
+			}
+		case *gopast.FuncLit, *gopast.LambdaExpr, *gopast.LambdaExpr2:
+			return false
+		}
+	}
+	return false
+}
+
+// usesVarsAfter reports whether any of the given variables is used by the
+// statements after the given position in the given block.
+func (r *compileResult) usesVarsAfter(block *gopast.BlockStmt, pos goptoken.Pos, vars map[*types.Var]bool) bool {
+	used := false
+	for _, stmt := range block.List {
+		if stmt.Pos() < pos {
+			continue
+		}
+		gopast.Inspect(stmt, func(node gopast.Node) bool {
+			if used {
+				return false
+			}
+			if ident, ok := node.(*gopast.Ident); ok {
+				if v, ok := r.typeInfo.Uses[ident].(*types.Var); ok && vars[v] {
+					used = true
+				}
+			}
+			return true
+		})
+	}
+	return used
+}
+
+// isNameTakenAt reports whether the given name is already used by a member of
+// the given class type, or by an object in scope at the given position.
+func (r *compileResult) isNameTakenAt(classType *types.Named, name string, pos goptoken.Pos) bool {
+	if obj, _, _ := types.LookupFieldOrMethod(classType, true, r.mainPkg, name); obj != nil {
+		return true
+	}
+	if scope := r.innermostScopeAt(pos); scope != nil {
+		if _, obj := scope.LookupParent(name, pos); obj != nil {
+			return true
+		}
+	}
+	return r.mainPkg.Scope().Lookup(name) != nil || types.Universe.Lookup(name) != nil
+}
+
+// topLevelDeclStartOf returns the start position of the given top-level
+// declaration, including its doc comment. For the shadow entry of an spx file,
+// it is the position of the first top-level statement.
+func topLevelDeclStartOf(decl gopast.Node) goptoken.Pos {
+	switch decl := decl.(type) {
+	case *gopast.FuncDecl:
+		if decl.Shadow && decl.Body != nil && len(decl.Body.List) > 0 {
+			return decl.Body.List[0].Pos()
+		}
+		if decl.Doc != nil {
+			return decl.Doc.Pos()
+		}
+	case *gopast.GenDecl:
+		if decl.Doc != nil {
+			return decl.Doc.Pos()
+		}
+	}
+	return decl.Pos()
+}
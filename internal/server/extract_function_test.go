@@ -0,0 +1,125 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerExtractFunctionCodeAction(t *testing.T) {
+	codeActionsFor := func(t *testing.T, spx string, selection Range) []CodeAction {
+		fileMap := newTestFileMap()
+		fileMap["MyAircraft.spx"] = []byte(spx)
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MyAircraft.spx"},
+			Range:        selection,
+			Context:      CodeActionContext{Only: []CodeActionKind{RefactorExtract}},
+		})
+		require.NoError(t, err)
+		return actions
+	}
+	lines := func(startLine, endLine, endCharacter uint32) Range {
+		return Range{
+			Start: Position{Line: startLine},
+			End:   Position{Line: endLine, Character: endCharacter},
+		}
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		actions := codeActionsFor(t, `var (
+	speed int
+)
+
+onStart => {
+	n := 1
+	msg := "hi"
+	say msg
+	if n > 0 {
+		step n
+		turn speed
+	}
+}
+`, lines(7, 11, 2))
+		require.Len(t, actions, 1)
+		assert.Equal(t, CodeAction{
+			Title: `Extract function "newFunction"`,
+			Kind:  refactorExtractFunction,
+			Edit: &WorkspaceEdit{
+				Changes: map[DocumentURI][]TextEdit{
+					"file:///MyAircraft.spx": {
+						{
+							Range: Range{
+								Start: Position{Line: 4, Character: 0},
+								End:   Position{Line: 4, Character: 0},
+							},
+							NewText: "func newFunction(msg string, n int) {\n\tsay msg\n\tif n > 0 {\n\t\tstep n\n\t\tturn speed\n\t}\n}\n\n",
+						},
+						{
+							Range: Range{
+								Start: Position{Line: 7, Character: 1},
+								End:   Position{Line: 11, Character: 2},
+							},
+							NewText: "newFunction msg, n",
+						},
+					},
+				},
+			},
+		}, actions[0])
+	})
+
+	t.Run("NestedBlock", func(t *testing.T) {
+		actions := codeActionsFor(t, `onClick => {
+	for i <- [1, 2, 3] {
+		step i
+		wait 0.1
+	}
+}
+`, lines(2, 3, 10))
+		require.Len(t, actions, 1)
+		edits := actions[0].Edit.Changes["file:///MyAircraft.spx"]
+		require.Len(t, edits, 2)
+		assert.Equal(t, "func newFunction(i int) {\n\tstep i\n\twait 0.1\n}\n\n", edits[0].NewText)
+		assert.Equal(t, "newFunction i", edits[1].NewText)
+	})
+
+	t.Run("UniqueName", func(t *testing.T) {
+		actions := codeActionsFor(t, `func newFunction() {
+}
+
+onStart => {
+	newFunction
+	say "hi"
+}
+`, lines(5, 5, 9))
+		require.Len(t, actions, 1)
+		assert.Equal(t, `Extract function "newFunction1"`, actions[0].Title)
+	})
+
+	t.Run("NotExtractable", func(t *testing.T) {
+		for _, tt := range []struct {
+			name      string
+			spx       string
+			selection Range
+		}{
+			{"EmptySelection", "onStart => {\n\tsay \"hi\"\n}\n", Range{Start: Position{Line: 1, Character: 1}, End: Position{Line: 1, Character: 1}}},
+			{"PartialStatement", "onStart => {\n\tsay \"hi\"\n\tif true {\n\t\tsay \"yes\"\n\t}\n}\n", lines(1, 3, 5)},
+			{"NotInEventHandler", "func greet() {\n\tsay \"hi\"\n}\n\nonStart => {\n\tgreet\n}\n", lines(1, 1, 9)},
+			{"VarUsedAfter", "onStart => {\n\tn := 1\n\tsay n\n}\n", lines(1, 1, 7)},
+			{"Return", "onStart => {\n\tsay \"hi\"\n\treturn\n}\n", lines(1, 2, 7)},
+			{"Break", "onStart => {\n\tfor {\n\t\tsay \"hi\"\n\t\tbreak\n\t}\n}\n", lines(2, 3, 7)},
+			{"AssignOuterVar", "onStart => {\n\tn := 1\n\tn = 2\n\tsay n\n}\n", lines(2, 2, 6)},
+			{"IncDecOuterVar", "onStart => {\n\tn := 1\n\tn++\n\tsay n\n}\n", lines(2, 2, 4)},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Empty(t, codeActionsFor(t, tt.spx, tt.selection))
+			})
+		}
+	})
+
+	t.Run("BreakInSelectedLoop", func(t *testing.T) {
+		actions := codeActionsFor(t, "onStart => {\n\tfor {\n\t\tsay \"hi\"\n\t\tbreak\n\t}\n}\n", lines(1, 4, 2))
+		require.Len(t, actions, 1)
+	})
+}
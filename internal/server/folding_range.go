@@ -0,0 +1,64 @@
+package server
+
+import (
+	"cmp"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_foldingRange
+func (s *Server) textDocumentFoldingRange(params *FoldingRangeParams) ([]FoldingRange, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	foldingRanges := []FoldingRange{}
+	addFoldingRange := func(start, end goptoken.Pos, kind FoldingRangeKind, keepEndLine bool) {
+		if !start.IsValid() || !end.IsValid() {
+			return
+		}
+		startLine := result.fset.Position(start).Line - 1
+		endLine := result.fset.Position(end).Line - 1
+		if keepEndLine {
+			// Keep the line of the closing bracket visible.
+			endLine--
+		}
+		if endLine <= startLine {
+			return
+		}
+		foldingRanges = append(foldingRanges, FoldingRange{
+			StartLine: uint32(startLine),
+			EndLine:   uint32(endLine),
+			Kind:      string(kind),
+		})
+	}
+
+	for _, commentGroup := range astFile.Comments {
+		addFoldingRange(commentGroup.Pos(), commentGroup.End(), Comment, false)
+	}
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		switch node := node.(type) {
+		case *gopast.GenDecl:
+			var kind FoldingRangeKind
+			if node.Tok == goptoken.IMPORT {
+				kind = Imports
+			}
+			addFoldingRange(node.Lparen, node.Rparen, kind, true)
+		case *gopast.BlockStmt:
+			addFoldingRange(node.Lbrace, node.Rbrace, "", true)
+		case *gopast.CompositeLit:
+			addFoldingRange(node.Lbrace, node.Rbrace, "", true)
+		}
+		return true
+	})
+	slices.SortStableFunc(foldingRanges, func(a, b FoldingRange) int {
+		return cmp.Compare(a.StartLine, b.StartLine)
+	})
+	return foldingRanges, nil
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentFoldingRange(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`import (
+	"fmt"
+	"strings"
+)
+
+// Game state.
+// Updated by event handlers.
+var (
+	MySprite Sprite
+	score    int
+)
+
+const (
+	maxScore = 100
+)
+
+onStart => {
+	fmt.println strings.toUpper("start")
+	if score < maxScore {
+		score++
+	}
+}
+
+onMsg "hi", => {
+	println "hi"
+}
+`),
+			"MySprite.spx": []byte(``),
+		}), nil)
+		params := &FoldingRangeParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		}
+
+		foldingRanges, err := s.textDocumentFoldingRange(params)
+		require.NoError(t, err)
+		assert.Equal(t, []FoldingRange{
+			{StartLine: 0, EndLine: 2, Kind: string(Imports)},
+			{StartLine: 5, EndLine: 6, Kind: string(Comment)},
+			{StartLine: 7, EndLine: 9},
+			{StartLine: 12, EndLine: 13},
+			{StartLine: 16, EndLine: 20},
+			{StartLine: 18, EndLine: 19},
+			{StartLine: 23, EndLine: 24},
+		}, foldingRanges)
+	})
+
+	t.Run("SingleLineBlocks", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`var score int
+onStart => { score++ }
+`),
+		}), nil)
+		params := &FoldingRangeParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		}
+
+		foldingRanges, err := s.textDocumentFoldingRange(params)
+		require.NoError(t, err)
+		assert.Empty(t, foldingRanges)
+	})
+
+	t.Run("NonSpxFile", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		params := &FoldingRangeParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///notexist.gop"},
+		}
+
+		foldingRanges, err := s.textDocumentFoldingRange(params)
+		require.EqualError(t, err, `file "notexist.gop" does not have .spx extension`)
+		assert.Nil(t, foldingRanges)
+	})
+}
@@ -7,17 +7,19 @@ import (
 	"io/fs"
 	"path"
 	"slices"
+	"strings"
 	"time"
 
 	gopast "github.com/goplus/gop/ast"
 	gopfmt "github.com/goplus/gop/format"
+	gopscanner "github.com/goplus/gop/scanner"
 	goptoken "github.com/goplus/gop/token"
 	"github.com/goplus/goxlsw/internal/vfs"
 )
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_formatting
 func (s *Server) textDocumentFormatting(params *DocumentFormattingParams) ([]TextEdit, error) {
-	spxFile, err := s.fromDocumentURI(params.TextDocument.URI)
+	folder, spxFile, err := s.workspaceFolderFor(params.TextDocument.URI)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get file path from document uri %q: %w", params.TextDocument.URI, err)
 	}
@@ -25,13 +27,13 @@ func (s *Server) textDocumentFormatting(params *DocumentFormattingParams) ([]Tex
 		return nil, nil // Not an spx source file.
 	}
 
-	snapshot := s.workspaceRootFS.Snapshot()
+	snapshot := s.workspaceFolderSnapshot(folder)
 	original, err := fs.ReadFile(snapshot, spxFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read spx source file: %w", err)
 	}
 
-	formatted, err := s.formatSpx(snapshot, spxFile)
+	formatted, err := s.formatSpx(folder, snapshot, spxFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to format spx source file: %w", err)
 	}
@@ -40,31 +42,313 @@ func (s *Server) textDocumentFormatting(params *DocumentFormattingParams) ([]Tex
 		return nil, nil // No changes.
 	}
 
-	// Simply replace the entire document.
-	lines := bytes.Count(original, []byte("\n"))
-	lastNewLine := bytes.LastIndex(original, []byte("\n"))
-	lastLineContent := original
-	if lastNewLine >= 0 {
-		lastLineContent = lastLineContent[lastNewLine+1:]
+	return computeTextEdits(original, formatted, s.positionEncoding()), nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_willSaveWaitUntil
+func (s *Server) textDocumentWillSaveWaitUntil(params *WillSaveTextDocumentParams) ([]TextEdit, error) {
+	folder, spxFile, err := s.workspaceFolderFor(params.TextDocument.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file path from document uri %q: %w", params.TextDocument.URI, err)
+	}
+	if path.Ext(spxFile) != ".spx" {
+		return nil, nil // Not an spx source file.
+	}
+
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+	original := []byte(astFile.Code)
+
+	// Remove unused imports before formatting, so that the formatter can
+	// clean up the import declarations left behind.
+	organized := original
+	if !s.config().Formatting.KeepUnusedImports {
+		organized = applyTextEditsToContent(original, result.unusedImportsEdits(astFile), result.positionEncoding)
+	}
+	snapshot := s.workspaceFolderSnapshot(folder).WithOverlay(map[string]vfs.MapFile{
+		spxFile: {
+			Content: organized,
+			ModTime: time.Now(),
+		},
+	}).Snapshot()
+	formatted, err := s.formatSpx(folder, snapshot, spxFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to format spx source file: %w", err)
+	}
+	if formatted == nil {
+		formatted = organized
+	}
+
+	if bytes.Equal(formatted, original) {
+		return nil, nil // No changes.
+	}
+
+	return computeTextEdits(original, formatted, s.positionEncoding()), nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_rangeFormatting
+func (s *Server) textDocumentRangeFormatting(params *DocumentRangeFormattingParams) ([]TextEdit, error) {
+	folder, spxFile, err := s.workspaceFolderFor(params.TextDocument.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file path from document uri %q: %w", params.TextDocument.URI, err)
+	}
+	if path.Ext(spxFile) != ".spx" {
+		return nil, nil // Not an spx source file.
+	}
+
+	snapshot := s.workspaceFolderSnapshot(folder)
+	original, err := fs.ReadFile(snapshot, spxFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spx source file: %w", err)
+	}
+
+	// Declarations are not reordered, as moving them produces pairs of
+	// deletions and insertions that may not both overlap the range, so
+	// that keeping only one of them would lose code.
+	formatted, err := s.applySpxFormatters(folder, snapshot, spxFile, []spxFormatter{
+		s.formatSpxGop,
+		s.formatSpxLambda,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to format spx source file: %w", err)
+	}
+
+	if formatted == nil || bytes.Equal(formatted, original) {
+		return nil, nil // No changes.
+	}
+
+	var rangeEdits []TextEdit
+	for _, edit := range computeTextEdits(original, formatted, s.positionEncoding()) {
+		if isRangeOverlapping(edit.Range, params.Range) {
+			rangeEdits = append(rangeEdits, edit)
+		}
+	}
+	return rangeEdits, nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_onTypeFormatting
+func (s *Server) textDocumentOnTypeFormatting(params *DocumentOnTypeFormattingParams) ([]TextEdit, error) {
+	folder, spxFile, err := s.workspaceFolderFor(params.TextDocument.URI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file path from document uri %q: %w", params.TextDocument.URI, err)
+	}
+	if path.Ext(spxFile) != ".spx" {
+		return nil, nil // Not an spx source file.
+	}
+
+	content, err := fs.ReadFile(s.workspaceFolderSnapshot(folder), spxFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spx source file: %w", err)
+	}
+
+	// The code is usually incomplete while typing, so instead of running the
+	// formatter, only the indentation of the current line is adjusted.
+	lines := bytes.SplitAfter(content, []byte("\n"))
+	line := int(params.Position.Line)
+	if line >= len(lines) {
+		return nil, nil
+	}
+	lineStart := len(bytes.Join(lines[:line], nil))
+	lineContent := string(bytes.TrimRight(lines[line], "\r\n"))
+	trimmedLineContent := strings.TrimLeft(lineContent, " \t")
+
+	depth := bracketDepth(spxFile, content[:lineStart])
+	if strings.HasPrefix(trimmedLineContent, "}") ||
+		strings.HasPrefix(trimmedLineContent, ")") ||
+		strings.HasPrefix(trimmedLineContent, "]") {
+		depth--
+	}
+	// Go+ formatter always indents with tabs, regardless of the options.
+	indent := strings.Repeat("\t", max(depth, 0))
+	currentIndent := lineContent[:len(lineContent)-len(trimmedLineContent)]
+	if currentIndent == indent {
+		return nil, nil
 	}
-	utf16Offset := utf8OffsetToUTF16(string(lastLineContent), len(lastLineContent))
 	return []TextEdit{
 		{
 			Range: Range{
-				Start: Position{Line: 0, Character: 0},
+				Start: Position{Line: uint32(line), Character: 0},
 				End: Position{
-					Line:      uint32(lines),
-					Character: uint32(utf16Offset),
+					Line:      uint32(line),
+					Character: uint32(utf8OffsetToEncoding(currentIndent, len(currentIndent), s.positionEncoding())),
 				},
 			},
-			NewText: string(formatted),
+			NewText: indent,
 		},
 	}, nil
 }
 
+// unusedImportsEdits returns text edits that remove unused imports from the
+// given file. Import declarations whose imports are all unused are removed as
+// a whole.
+func (r *compileResult) unusedImportsEdits(astFile *gopast.File) []TextEdit {
+	var edits []TextEdit
+	removeLines := func(node gopast.Node) {
+		edits = append(edits, TextEdit{
+			Range: Range{
+				Start: Position{Line: uint32(r.fset.Position(node.Pos()).Line - 1)},
+				End:   Position{Line: uint32(r.fset.Position(node.End()).Line)},
+			},
+			NewText: "",
+		})
+	}
+	for _, decl := range astFile.Decls {
+		genDecl, ok := decl.(*gopast.GenDecl)
+		if !ok || genDecl.Tok != goptoken.IMPORT {
+			continue
+		}
+		var unusedSpecs []gopast.Spec
+		for _, spec := range genDecl.Specs {
+			if _, ok := r.unusedImportSpecs[spec.(*gopast.ImportSpec)]; ok {
+				unusedSpecs = append(unusedSpecs, spec)
+			}
+		}
+		if len(unusedSpecs) == len(genDecl.Specs) {
+			if len(unusedSpecs) > 0 {
+				removeLines(genDecl)
+			}
+			continue
+		}
+		for _, spec := range unusedSpecs {
+			removeLines(spec)
+		}
+	}
+	return edits
+}
+
+// bracketDepth returns the number of unclosed brackets in the given source.
+// Brackets in comments and string literals are ignored.
+func bracketDepth(filename string, src []byte) int {
+	fset := goptoken.NewFileSet()
+	file := fset.AddFile(filename, -1, len(src))
+
+	var (
+		sc    gopscanner.Scanner
+		depth int
+	)
+	sc.Init(file, src, nil, 0)
+	for {
+		_, tok, _ := sc.Scan()
+		switch tok {
+		case goptoken.EOF:
+			return depth
+		case goptoken.LBRACE, goptoken.LPAREN, goptoken.LBRACK:
+			depth++
+		case goptoken.RBRACE, goptoken.RPAREN, goptoken.RBRACK:
+			if depth > 0 {
+				depth--
+			}
+		}
+	}
+}
+
+// maxLineDiffCells is the maximum number of cells of the table used for
+// computing line diffs. Beyond this, the changed lines are replaced as a whole.
+const maxLineDiffCells = 1 << 22
+
+// computeTextEdits computes minimal line-based text edits that transform the
+// original content into the formatted content, with positions in the given
+// position encoding.
+func computeTextEdits(original, formatted []byte, encoding PositionEncodingKind) []TextEdit {
+	oldLines := bytes.SplitAfter(original, []byte("\n"))
+	newLines := bytes.SplitAfter(formatted, []byte("\n"))
+	if len(oldLines[len(oldLines)-1]) == 0 {
+		oldLines = oldLines[:len(oldLines)-1]
+	}
+	if len(newLines[len(newLines)-1]) == 0 {
+		newLines = newLines[:len(newLines)-1]
+	}
+
+	// Trim common prefix and suffix lines.
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && bytes.Equal(oldLines[prefix], newLines[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		bytes.Equal(oldLines[len(oldLines)-1-suffix], newLines[len(newLines)-1-suffix]) {
+		suffix++
+	}
+	oldMid := oldLines[prefix : len(oldLines)-suffix]
+	newMid := newLines[prefix : len(newLines)-suffix]
+
+	// Compute the longest common subsequence of the remaining lines.
+	n, m := len(oldMid), len(newMid)
+	var lcs [][]int
+	if (n+1)*(m+1) <= maxLineDiffCells {
+		lcs = make([][]int, n+1)
+		for i := range lcs {
+			lcs[i] = make([]int, m+1)
+		}
+		for i := n - 1; i >= 0; i-- {
+			for j := m - 1; j >= 0; j-- {
+				if bytes.Equal(oldMid[i], newMid[j]) {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+	}
+
+	var edits []TextEdit
+	addEdit := func(oldStart, oldEnd, newStart, newEnd int) {
+		if oldStart == oldEnd && newStart == newEnd {
+			return
+		}
+		edits = append(edits, TextEdit{
+			Range: Range{
+				Start: linesEndPosition(oldLines, prefix+oldStart, encoding),
+				End:   linesEndPosition(oldLines, prefix+oldEnd, encoding),
+			},
+			NewText: string(bytes.Join(newMid[newStart:newEnd], nil)),
+		})
+	}
+	if lcs == nil {
+		addEdit(0, n, 0, m)
+		return edits
+	}
+	i, j := 0, 0
+	hunkOldStart, hunkNewStart := 0, 0
+	for i < n && j < m {
+		switch {
+		case bytes.Equal(oldMid[i], newMid[j]):
+			addEdit(hunkOldStart, i, hunkNewStart, j)
+			i++
+			j++
+			hunkOldStart, hunkNewStart = i, j
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	addEdit(hunkOldStart, n, hunkNewStart, m)
+	return edits
+}
+
+// linesEndPosition returns the position right after the first n lines of the
+// given lines in the given position encoding, where each line includes its
+// trailing newline if any.
+func linesEndPosition(lines [][]byte, n int, encoding PositionEncodingKind) Position {
+	if n < len(lines) || n == 0 || bytes.HasSuffix(lines[n-1], []byte("\n")) {
+		return Position{Line: uint32(n), Character: 0}
+	}
+	lastLine := string(lines[n-1])
+	return Position{
+		Line:      uint32(n - 1),
+		Character: uint32(utf8OffsetToEncoding(lastLine, len(lastLine), encoding)),
+	}
+}
+
 // spxFormatter defines a function that formats an spx source file in the given
-// root file system snapshot.
-type spxFormatter func(snapshot *vfs.MapFS, spxFile string) (formatted []byte, err error)
+// file system snapshot of the workspace folder.
+type spxFormatter func(folder *workspaceFolder, snapshot *vfs.MapFS, spxFile string) (formatted []byte, err error)
 
 // formatSpx applies a series of formatters to an spx source file in order.
 //
@@ -72,14 +356,20 @@ type spxFormatter func(snapshot *vfs.MapFS, spxFile string) (formatted []byte, e
 //  1. Go+ formatter
 //  2. Lambda parameter elimination
 //  3. Declaration reordering
-func (s *Server) formatSpx(snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
-	var formatted []byte
-	for _, formatter := range []spxFormatter{
+func (s *Server) formatSpx(folder *workspaceFolder, snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
+	return s.applySpxFormatters(folder, snapshot, spxFile, []spxFormatter{
 		s.formatSpxGop,
 		s.formatSpxLambda,
 		s.formatSpxDecls,
-	} {
-		subFormatted, err := formatter(snapshot, spxFile)
+	})
+}
+
+// applySpxFormatters applies the given formatters to an spx source file in
+// order. It returns nil if none of them changes the file.
+func (s *Server) applySpxFormatters(folder *workspaceFolder, snapshot *vfs.MapFS, spxFile string, formatters []spxFormatter) ([]byte, error) {
+	var formatted []byte
+	for _, formatter := range formatters {
+		subFormatted, err := formatter(folder, snapshot, spxFile)
 		if err != nil {
 			return nil, err
 		}
@@ -97,7 +387,7 @@ func (s *Server) formatSpx(snapshot *vfs.MapFS, spxFile string) ([]byte, error)
 }
 
 // formatSpxGop formats an spx source file with Go+ formatter.
-func (s *Server) formatSpxGop(snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
+func (s *Server) formatSpxGop(folder *workspaceFolder, snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
 	original, err := fs.ReadFile(snapshot, spxFile)
 	if err != nil {
 		return nil, err
@@ -106,8 +396,8 @@ func (s *Server) formatSpxGop(snapshot *vfs.MapFS, spxFile string) ([]byte, erro
 }
 
 // formatSpxLambda formats an spx source file by eliminating unused lambda parameters.
-func (s *Server) formatSpxLambda(snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
-	compileResult, err := s.compileAt(snapshot)
+func (s *Server) formatSpxLambda(folder *workspaceFolder, snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
+	compileResult, err := s.compileAt(folder, snapshot)
 	if err != nil {
 		return nil, err
 	}
@@ -133,8 +423,8 @@ func (s *Server) formatSpxLambda(snapshot *vfs.MapFS, spxFile string) ([]byte, e
 }
 
 // formatSpxDecls formats an spx source file by reordering declarations.
-func (s *Server) formatSpxDecls(snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
-	compileResult, err := s.compileAt(snapshot)
+func (s *Server) formatSpxDecls(folder *workspaceFolder, snapshot *vfs.MapFS, spxFile string) ([]byte, error) {
+	compileResult, err := s.compileAt(folder, snapshot)
 	if err != nil {
 		return nil, err
 	}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"io/fs"
+	"slices"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,13 +29,7 @@ run "assets",    { Title:    "Bullet (by Go+)" }
 
 		edits, err := s.textDocumentFormatting(params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 9, Character: 0},
-			},
-			NewText: `// An spx game.
+		assert.Equal(t, `// An spx game.
 
 type Score int
 
@@ -44,8 +39,36 @@ var (
 )
 
 run "assets", {Title: "Bullet (by Go+)"}
-`,
-		})
+`, applyTextEditsToFile(t, s, "main.spx", edits))
+	})
+
+	t.Run("MinimalEdits", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`// An spx game.
+
+var (
+	MyAircraft MyAircraft
+	Bullet     Bullet
+)
+
+run "assets",    { Title:    "Bullet (by Go+)" }
+`),
+		}), nil)
+		params := &DocumentFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		}
+
+		edits, err := s.textDocumentFormatting(params)
+		require.NoError(t, err)
+		assert.Equal(t, []TextEdit{
+			{
+				Range: Range{
+					Start: Position{Line: 7, Character: 0},
+					End:   Position{Line: 8, Character: 0},
+				},
+				NewText: "run \"assets\", {Title: \"Bullet (by Go+)\"}\n",
+			},
+		}, edits)
 	})
 
 	t.Run("NonSpxFile", func(t *testing.T) {
@@ -99,21 +122,14 @@ var MyAircraft MyAircraft
 
 		edits, err := s.textDocumentFormatting(params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 4, Character: 0},
-			},
-			NewText: `// An spx game.
+		assert.Equal(t, `// An spx game.
 
 var (
 	MyAircraft MyAircraft
 )
 
 !InvalidSyntax
-`,
-		})
+`, applyTextEditsToFile(t, s, "main.spx", edits))
 	})
 
 	t.Run("WithFormatSpx", func(t *testing.T) {
@@ -156,13 +172,7 @@ var (
 
 		edits, err := s.textDocumentFormatting(params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 30, Character: 0},
-			},
-			NewText: `// An spx game.
+		assert.Equal(t, `// An spx game.
 
 var (
 	// The first var block.
@@ -197,8 +207,7 @@ var (
 
 	// Trailing comment for the last var block.
 )
-`,
-		})
+`, applyTextEditsToFile(t, s, "main.spx", edits))
 	})
 
 	t.Run("VarBlockWithoutDoc", func(t *testing.T) {
@@ -219,13 +228,7 @@ var Bullet Bullet
 
 		edits, err := s.textDocumentFormatting(params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 8, Character: 0},
-			},
-			NewText: `// An spx game.
+		assert.Equal(t, `// An spx game.
 
 var (
 	// The aircraft.
@@ -233,8 +236,7 @@ var (
 
 	Bullet Bullet
 )
-`,
-		})
+`, applyTextEditsToFile(t, s, "main.spx", edits))
 	})
 
 	t.Run("NoTypeSpriteVarDeclaration", func(t *testing.T) {
@@ -296,13 +298,7 @@ onKey [KeyLeft, KeyRight], (key) => {
 
 		edits, err := s.textDocumentFormatting(params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 8, Character: 0},
-			},
-			NewText: `// An spx game.
+		assert.Equal(t, `// An spx game.
 
 onKey [KeyLeft, KeyRight], () => {
 	println "key"
@@ -311,8 +307,7 @@ onKey [KeyLeft, KeyRight], () => {
 onKey [KeyLeft, KeyRight], (key) => {
 	println key
 }
-`,
-		})
+`, applyTextEditsToFile(t, s, "main.spx", edits))
 	})
 
 	t.Run("WithUnusedLambdaParamsForSprite", func(t *testing.T) {
@@ -339,13 +334,7 @@ onTouchStart 123, (s) => { // type mismatch
 
 		edits, err := s.textDocumentFormatting(params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 13, Character: 0},
-			},
-			NewText: `// An spx game.
+		assert.Equal(t, `// An spx game.
 
 onKey [KeyLeft, KeyRight], () => {
 	println "key"
@@ -361,8 +350,7 @@ onTouchStart (s, t) => { // type mismatch
 }
 onTouchStart 123, (s) => { // type mismatch
 }
-`,
-		})
+`, applyTextEditsToFile(t, s, "MySprite.spx", edits))
 	})
 
 	t.Run("EmptyFile", func(t *testing.T) {
@@ -432,13 +420,7 @@ run "assets", {Title: "My Game"}
 
 		edits, err := s.textDocumentFormatting(params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 24, Character: 0},
-			},
-			NewText: `import "fmt"
+		assert.Equal(t, `import "fmt"
 
 // floating comment1
 
@@ -464,8 +446,7 @@ func test() {
 run "assets", {Title: "My Game"}
 
 // floating comment5
-`,
-		})
+`, applyTextEditsToFile(t, s, "main.spx", edits))
 	})
 
 	t.Run("WithTrailingComments", func(t *testing.T) {
@@ -485,13 +466,7 @@ func test() {} // trailing comment for func test
 
 		edits, err := s.textDocumentFormatting(params)
 		require.NoError(t, err)
-		require.Len(t, edits, 1)
-		assert.Contains(t, edits, TextEdit{
-			Range: Range{
-				Start: Position{Line: 0, Character: 0},
-				End:   Position{Line: 7, Character: 0},
-			},
-			NewText: `import "fmt" // trailing comment for import "fmt"
+		assert.Equal(t, `import "fmt" // trailing comment for import "fmt"
 
 const foo = "bar" // trailing comment for const foo
 
@@ -500,7 +475,386 @@ var (
 )
 
 func test() {} // trailing comment for func test
-`,
+`, applyTextEditsToFile(t, s, "main.spx", edits))
+	})
+}
+
+func TestServerTextDocumentRangeFormatting(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`var (
+	MyAircraft MyAircraft
+)
+
+onStart => {
+  println  "start"
+}
+
+onClick => {
+  println  "click"
+}
+`),
+		}), nil)
+		params := &DocumentRangeFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range: Range{
+				Start: Position{Line: 8, Character: 0},
+				End:   Position{Line: 10, Character: 1},
+			},
+		}
+
+		edits, err := s.textDocumentRangeFormatting(params)
+		require.NoError(t, err)
+		assert.Equal(t, []TextEdit{
+			{
+				Range: Range{
+					Start: Position{Line: 9, Character: 0},
+					End:   Position{Line: 10, Character: 0},
+				},
+				NewText: "\tprintln \"click\"\n",
+			},
+		}, edits)
+	})
+
+	t.Run("NoChangesInRange", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`onStart => {
+  println  "start"
+}
+
+onClick => {
+	println "click"
+}
+`),
+		}), nil)
+		params := &DocumentRangeFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range: Range{
+				Start: Position{Line: 4, Character: 0},
+				End:   Position{Line: 6, Character: 1},
+			},
+		}
+
+		edits, err := s.textDocumentRangeFormatting(params)
+		require.NoError(t, err)
+		assert.Empty(t, edits)
+	})
+
+	t.Run("DeclsOutOfOrder", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`func f() {
+  println  "f"
+}
+
+var (
+	MyAircraft MyAircraft
+)
+
+onStart => {
+	f
+}
+`),
+		}), nil)
+		params := &DocumentRangeFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range: Range{
+				Start: Position{Line: 0, Character: 0},
+				End:   Position{Line: 2, Character: 1},
+			},
+		}
+
+		edits, err := s.textDocumentRangeFormatting(params)
+		require.NoError(t, err)
+		assert.Equal(t, `func f() {
+	println "f"
+}
+
+var (
+	MyAircraft MyAircraft
+)
+
+onStart => {
+	f
+}
+`, applyTextEditsToFile(t, s, "main.spx", edits))
+	})
+
+	t.Run("NonSpxFile", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.gop": []byte(`echo "Hello, Go+!"`),
+		}), nil)
+		params := &DocumentRangeFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.gop"},
+		}
+
+		edits, err := s.textDocumentRangeFormatting(params)
+		require.NoError(t, err)
+		assert.Nil(t, edits)
+	})
+}
+
+func TestServerTextDocumentOnTypeFormatting(t *testing.T) {
+	t.Run("Newline", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte("onStart => {\n\tonClick => {\n\n"),
+		}), nil)
+		params := &DocumentOnTypeFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 2, Character: 0},
+			Ch:           "\n",
+		}
+
+		edits, err := s.textDocumentOnTypeFormatting(params)
+		require.NoError(t, err)
+		assert.Equal(t, []TextEdit{
+			{
+				Range: Range{
+					Start: Position{Line: 2, Character: 0},
+					End:   Position{Line: 2, Character: 0},
+				},
+				NewText: "\t\t",
+			},
+		}, edits)
+	})
+
+	t.Run("ClosingBrace", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte("onStart => {\n\tprintln \"{\"\n\t\t}\n"),
+		}), nil)
+		params := &DocumentOnTypeFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 2, Character: 3},
+			Ch:           "}",
+		}
+
+		edits, err := s.textDocumentOnTypeFormatting(params)
+		require.NoError(t, err)
+		assert.Equal(t, []TextEdit{
+			{
+				Range: Range{
+					Start: Position{Line: 2, Character: 0},
+					End:   Position{Line: 2, Character: 2},
+				},
+				NewText: "",
+			},
+		}, edits)
+	})
+
+	t.Run("AlreadyIndented", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte("onStart => {\n\tprintln \"start\"\n"),
+		}), nil)
+		params := &DocumentOnTypeFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 1, Character: 0},
+			Ch:           "\n",
+		}
+
+		edits, err := s.textDocumentOnTypeFormatting(params)
+		require.NoError(t, err)
+		assert.Nil(t, edits)
+	})
+
+	t.Run("FileNotFound", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		params := &DocumentOnTypeFormattingParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///notexist.spx"},
+			Ch:           "}",
+		}
+
+		edits, err := s.textDocumentOnTypeFormatting(params)
+		require.ErrorIs(t, err, fs.ErrNotExist)
+		assert.Nil(t, edits)
+	})
+}
+
+func TestServerTextDocumentWillSaveWaitUntil(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`import (
+	"fmt"
+	"math"
+)
+
+onStart => {
+echo math.Abs(-1)
+}
+`),
+		}), nil)
+		params := &WillSaveTextDocumentParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Reason:       Manual,
+		}
+
+		edits, err := s.textDocumentWillSaveWaitUntil(params)
+		require.NoError(t, err)
+		assert.Equal(t, `import (
+	"math"
+)
+
+onStart => {
+	echo math.Abs(-1)
+}
+`, applyTextEditsToFile(t, s, "main.spx", edits))
+	})
+
+	t.Run("RemoveImportDecl", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`import "fmt"
+
+onStart => {
+	echo "Hello"
+}
+`),
+		}), nil)
+		params := &WillSaveTextDocumentParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Reason:       Manual,
+		}
+
+		edits, err := s.textDocumentWillSaveWaitUntil(params)
+		require.NoError(t, err)
+		assert.Equal(t, `onStart => {
+	echo "Hello"
+}
+`, applyTextEditsToFile(t, s, "main.spx", edits))
+	})
+
+	t.Run("NoChange", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`onStart => {
+	echo "Hello"
+}
+`),
+		}), nil)
+		params := &WillSaveTextDocumentParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Reason:       Manual,
+		}
+
+		edits, err := s.textDocumentWillSaveWaitUntil(params)
+		require.NoError(t, err)
+		assert.Nil(t, edits)
+	})
+
+	t.Run("NonSpxFile", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		params := &WillSaveTextDocumentParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.gop"},
+			Reason:       Manual,
+		}
+
+		edits, err := s.textDocumentWillSaveWaitUntil(params)
+		require.NoError(t, err)
+		assert.Nil(t, edits)
+	})
+}
+
+func TestComputeTextEdits(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		original  string
+		formatted string
+		want      []TextEdit
+	}{
+		{
+			name:      "NoChanges",
+			original:  "a\nb\n",
+			formatted: "a\nb\n",
+			want:      nil,
+		},
+		{
+			name:      "ReplaceLine",
+			original:  "a\nb\nc\n",
+			formatted: "a\nB\nc\n",
+			want: []TextEdit{
+				{
+					Range: Range{
+						Start: Position{Line: 1, Character: 0},
+						End:   Position{Line: 2, Character: 0},
+					},
+					NewText: "B\n",
+				},
+			},
+		},
+		{
+			name:      "InsertAndDeleteLines",
+			original:  "a\nb\nc\nd\n",
+			formatted: "x\na\nc\nd\n",
+			want: []TextEdit{
+				{
+					Range: Range{
+						Start: Position{Line: 0, Character: 0},
+						End:   Position{Line: 0, Character: 0},
+					},
+					NewText: "x\n",
+				},
+				{
+					Range: Range{
+						Start: Position{Line: 1, Character: 0},
+						End:   Position{Line: 2, Character: 0},
+					},
+					NewText: "",
+				},
+			},
+		},
+		{
+			name:      "AddTrailingNewline",
+			original:  "a\nb",
+			formatted: "a\nb\n",
+			want: []TextEdit{
+				{
+					Range: Range{
+						Start: Position{Line: 1, Character: 0},
+						End:   Position{Line: 1, Character: 1},
+					},
+					NewText: "b\n",
+				},
+			},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			edits := computeTextEdits([]byte(tt.original), []byte(tt.formatted), UTF16)
+			assert.Equal(t, tt.want, edits)
+			assert.Equal(t, tt.formatted, applyTextEdits(t, tt.original, edits))
 		})
+	}
+}
+
+// applyTextEditsToFile applies the given text edits to the content of the
+// given file in the workspace of the server and returns the result.
+func applyTextEditsToFile(t *testing.T, s *Server, name string, edits []TextEdit) string {
+	content, err := fs.ReadFile(s.workspaceRootFS, name)
+	require.NoError(t, err)
+	return applyTextEdits(t, string(content), edits)
+}
+
+// applyTextEdits applies the given non-overlapping text edits to the content.
+func applyTextEdits(t *testing.T, content string, edits []TextEdit) string {
+	lineOffsets := []int{0}
+	for i, c := range content {
+		if c == '\n' {
+			lineOffsets = append(lineOffsets, i+1)
+		}
+	}
+	offsetOf := func(pos Position) int {
+		require.Less(t, int(pos.Line), len(lineOffsets))
+		lineStart := lineOffsets[pos.Line]
+		lineEnd := len(content)
+		if int(pos.Line)+1 < len(lineOffsets) {
+			lineEnd = lineOffsets[pos.Line+1]
+		}
+		return lineStart + encodingOffsetToUTF8(content[lineStart:lineEnd], int(pos.Character), UTF16)
+	}
+
+	sortedEdits := slices.Clone(edits)
+	slices.SortStableFunc(sortedEdits, func(a, b TextEdit) int {
+		return offsetOf(b.Range.Start) - offsetOf(a.Range.Start)
 	})
+	for _, edit := range sortedEdits {
+		start, end := offsetOf(edit.Range.Start), offsetOf(edit.Range.End)
+		require.LessOrEqual(t, start, end)
+		content = content[:start] + edit.NewText + content[end:]
+	}
+	return content
 }
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/constant"
+	"path"
+	"strconv"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// gameConfigAnalyzer is an [analyzer] for mismatches between the game config
+// passed to run in main.spx and the index.json of spx resources.
+var gameConfigAnalyzer = &analyzer{
+	name:     "gameConfig",
+	requires: requireAST | requireTypeInfo | requireResourceSet,
+	run:      inspectForGameConfigMismatches,
+}
+
+// inspectForGameConfigMismatches inspects for window sizes in the game config
+// literal of `run "assets", {...}` that exceed the map size in index.json, as
+// spx clamps the window to the map, and for zorder entries in index.json that
+// refer to missing sprites, as spx panics on them when the game starts.
+func inspectForGameConfigMismatches(pass *analysisPass) {
+	result := pass.result
+	runCall := result.spxRunCall()
+	if runCall == nil {
+		return
+	}
+	set := &result.spxResourceSet
+	indexFile := path.Join(result.spxResourceRootDir, "index.json")
+	indexFileURI := result.toDocumentURI(indexFile)
+
+	for _, key := range []string{"width", "height"} {
+		mapSize := set.mapWidth
+		if key == "height" {
+			mapSize = set.mapHeight
+		}
+		if mapSize <= 0 {
+			continue
+		}
+		valueExpr, size, ok := result.spxGameConfigSize(runCall, key)
+		if !ok || size <= mapSize {
+			continue
+		}
+
+		diag := Diagnostic{
+			Severity: SeverityWarning,
+			Code:     DiagnosticCodeMapSizeMismatch,
+			Range:    result.rangeForNode(valueExpr),
+			Message:  fmt.Sprintf("window %s %d exceeds the map %s %d in %s", key, size, key, mapSize, indexFile),
+			Data: makeDiagnosticData(DiagnosticData{
+				Fix:        DiagnosticFixUpdateMapSize,
+				Name:       key,
+				Suggestion: strconv.Itoa(mapSize),
+			}),
+		}
+		if span, ok := jsonObjectValueSpan(set.metadata, "map", key); ok {
+			diag.RelatedInformation = []DiagnosticRelatedInformation{{
+				Location: Location{URI: indexFileURI, Range: span.rangeIn(set.metadata, result.positionEncoding)},
+				Message:  fmt.Sprintf("map %s is defined here", key),
+			}}
+		}
+		pass.report(result.mainSpxFile, diag)
+	}
+
+	zorderSpans, _ := jsonArrayElementSpans(set.metadata, "zorder")
+	for i, spriteName := range set.zorder {
+		if spriteName == "" || set.Sprite(spriteName) != nil {
+			continue
+		}
+		diag := Diagnostic{
+			Severity: SeverityError,
+			Code:     DiagnosticCodeZorderSpriteNotFound,
+			Range:    result.rangeForNode(runCall.Args[0]),
+			Message:  fmt.Sprintf("sprite %q in the zorder of %s does not exist", spriteName, indexFile),
+			Data: makeDiagnosticData(DiagnosticData{
+				Fix:  DiagnosticFixRemoveZorderEntry,
+				Name: spriteName,
+			}),
+		}
+		if i < len(zorderSpans) {
+			diag.RelatedInformation = []DiagnosticRelatedInformation{{
+				Location: Location{URI: indexFileURI, Range: zorderSpans[i].rangeIn(set.metadata, result.positionEncoding)},
+				Message:  fmt.Sprintf("sprite %q is in the zorder here", spriteName),
+			}}
+		}
+		pass.report(result.mainSpxFile, diag)
+	}
+}
+
+// spxGameConfigSize returns the value expression and the constant value of the
+// window size field with the given JSON key, i.e., "width" or "height", in the
+// game config literal passed to the given run call.
+func (r *compileResult) spxGameConfigSize(runCall *gopast.CallExpr, key string) (gopast.Expr, int, bool) {
+	if len(runCall.Args) < 2 {
+		return nil, 0, false
+	}
+	configExpr := runCall.Args[1]
+	if unaryExpr, ok := configExpr.(*gopast.UnaryExpr); ok && unaryExpr.Op == goptoken.AND {
+		configExpr = unaryExpr.X
+	}
+	compositeLit, ok := configExpr.(*gopast.CompositeLit)
+	if !ok {
+		return nil, 0, false
+	}
+	for _, elt := range compositeLit.Elts {
+		kvExpr, ok := elt.(*gopast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		keyIdent, ok := kvExpr.Key.(*gopast.Ident)
+		if !ok || !strings.EqualFold(keyIdent.Name, key) {
+			continue
+		}
+		tv, ok := r.typeInfo.Types[kvExpr.Value]
+		if !ok || tv.Value == nil || tv.Value.Kind() != constant.Int {
+			return nil, 0, false
+		}
+		size, ok := constant.Int64Val(tv.Value)
+		if !ok {
+			return nil, 0, false
+		}
+		return kvExpr.Value, int(size), true
+	}
+	return nil, 0, false
+}
+
+// updateMapSizeEdit returns a workspace edit that updates the map dimension
+// with the given JSON key in index.json to the window size in the game config.
+func (r *compileResult) updateMapSizeEdit(key string) *WorkspaceEdit {
+	runCall := r.spxRunCall()
+	if runCall == nil {
+		return nil
+	}
+	_, size, ok := r.spxGameConfigSize(runCall, key)
+	if !ok {
+		return nil
+	}
+	metadata := r.spxResourceSet.metadata
+	span, ok := jsonObjectValueSpan(metadata, "map", key)
+	if !ok {
+		return nil
+	}
+	return &WorkspaceEdit{
+		Changes: map[DocumentURI][]TextEdit{
+			r.toDocumentURI(path.Join(r.spxResourceRootDir, "index.json")): {{
+				Range:   span.rangeIn(metadata, r.positionEncoding),
+				NewText: strconv.Itoa(size),
+			}},
+		},
+	}
+}
+
+// removeZorderEntryEdit returns a workspace edit that removes the sprite with
+// the given name from the zorder in index.json, along with its separator.
+func (r *compileResult) removeZorderEntryEdit(spriteName string) *WorkspaceEdit {
+	metadata := r.spxResourceSet.metadata
+	spans, ok := jsonArrayElementSpans(metadata, "zorder")
+	if !ok || len(spans) != len(r.spxResourceSet.zorder) {
+		return nil
+	}
+	for i, name := range r.spxResourceSet.zorder {
+		if name != spriteName {
+			continue
+		}
+		span := spans[i]
+		switch {
+		case i+1 < len(spans):
+			span.end = spans[i+1].start
+		case i > 0:
+			span.start = spans[i-1].end
+		}
+		return &WorkspaceEdit{
+			Changes: map[DocumentURI][]TextEdit{
+				r.toDocumentURI(path.Join(r.spxResourceRootDir, "index.json")): {{
+					Range:   span.rangeIn(metadata, r.positionEncoding),
+					NewText: "",
+				}},
+			},
+		}
+	}
+	return nil
+}
+
+// jsonSpan is the span of a value in a JSON document in UTF-8 byte offsets.
+type jsonSpan struct {
+	start, end int
+}
+
+// rangeIn returns the range of the span in the given JSON document in the
+// given position encoding.
+func (s jsonSpan) rangeIn(content []byte, encoding PositionEncodingKind) Range {
+	return Range{
+		Start: offsetPosition(string(content), s.start, encoding),
+		End:   offsetPosition(string(content), s.end, encoding),
+	}
+}
+
+// jsonObjectValueSpan returns the span of the value at the given path of object
+// keys in the given JSON document, e.g., `480` for "map", "width" in
+// `{"map":{"width":480}}`.
+func jsonObjectValueSpan(content []byte, keys ...string) (jsonSpan, bool) {
+	dec, ok := jsonDecoderAt(content, keys)
+	if !ok {
+		return jsonSpan{}, false
+	}
+	return decodeJSONSpan(dec)
+}
+
+// jsonArrayElementSpans returns the spans of the elements of the array at the
+// given path of object keys in the given JSON document.
+func jsonArrayElementSpans(content []byte, keys ...string) ([]jsonSpan, bool) {
+	dec, ok := jsonDecoderAt(content, keys)
+	if !ok {
+		return nil, false
+	}
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('[') {
+		return nil, false
+	}
+	var spans []jsonSpan
+	for dec.More() {
+		span, ok := decodeJSONSpan(dec)
+		if !ok {
+			return nil, false
+		}
+		spans = append(spans, span)
+	}
+	return spans, true
+}
+
+// jsonDecoderAt returns a decoder of the given JSON document whose next value
+// is the one at the given path of object keys.
+func jsonDecoderAt(content []byte, keys []string) (*json.Decoder, bool) {
+	dec := json.NewDecoder(bytes.NewReader(content))
+	for _, key := range keys {
+		if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+			return nil, false
+		}
+		for {
+			if !dec.More() {
+				return nil, false
+			}
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, false
+			}
+			if tok == key {
+				break
+			}
+			if _, ok := decodeJSONSpan(dec); !ok {
+				return nil, false
+			}
+		}
+	}
+	return dec, true
+}
+
+// decodeJSONSpan decodes the next value of the given decoder and returns its
+// span.
+func decodeJSONSpan(dec *json.Decoder) (jsonSpan, bool) {
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return jsonSpan{}, false
+	}
+	end := int(dec.InputOffset())
+	return jsonSpan{start: end - len(raw), end: end}, true
+}
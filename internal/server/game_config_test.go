@@ -0,0 +1,199 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerInspectForGameConfigMismatches(t *testing.T) {
+	diagnosticsFor := func(t *testing.T, s *Server) []Diagnostic {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		return fullReport.Items
+	}
+	codeActionsFor := func(t *testing.T, s *Server, diags []Diagnostic) []CodeAction {
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Context:      CodeActionContext{Diagnostics: diags, Only: []CodeActionKind{QuickFix}},
+		})
+		require.NoError(t, err)
+		return actions
+	}
+
+	t.Run("MapSizeMismatch", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game", Width: 800, Height: 360}
+`),
+			"assets/index.json": []byte(`{
+  "map": {"width": 480, "height": 360}
+}`),
+		}), nil)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, Diagnostic{
+			Severity: SeverityWarning,
+			Code:     DiagnosticCodeMapSizeMismatch,
+			Range: Range{
+				Start: Position{Line: 1, Character: 40},
+				End:   Position{Line: 1, Character: 43},
+			},
+			Message: "window width 800 exceeds the map width 480 in assets/index.json",
+			RelatedInformation: []DiagnosticRelatedInformation{{
+				Location: Location{
+					URI: "file:///assets/index.json",
+					Range: Range{
+						Start: Position{Line: 1, Character: 19},
+						End:   Position{Line: 1, Character: 22},
+					},
+				},
+				Message: "map width is defined here",
+			}},
+			Data: makeDiagnosticData(DiagnosticData{
+				Fix:        DiagnosticFixUpdateMapSize,
+				Name:       "width",
+				Suggestion: "480",
+			}),
+		}, diags[0])
+
+		actions := codeActionsFor(t, s, diags)
+		require.Len(t, actions, 3)
+		assert.Equal(t, "Change to 480", actions[0].Title)
+		assert.Equal(t, "Update map width in index.json to match the game config", actions[1].Title)
+		require.NotNil(t, actions[1].Edit)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///assets/index.json": {{
+				Range: Range{
+					Start: Position{Line: 1, Character: 19},
+					End:   Position{Line: 1, Character: 22},
+				},
+				NewText: "800",
+			}},
+		}, actions[1].Edit.Changes)
+	})
+
+	t.Run("WindowWithinMap", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game", Width: 480, Height: 360}
+`),
+			"assets/index.json": []byte(`{"map":{"width":960,"height":720}}`),
+		}), nil)
+		assert.Empty(t, diagnosticsFor(t, s))
+	})
+
+	t.Run("WithoutMapSize", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game", Width: 800, Height: 600}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+		assert.Empty(t, diagnosticsFor(t, s))
+	})
+
+	t.Run("ZorderSpriteNotFound", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx":                       []byte(`onStart => {}`),
+			"assets/index.json":                  []byte(`{"zorder":["Ghost","MySprite"]}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, Diagnostic{
+			Severity: SeverityError,
+			Code:     DiagnosticCodeZorderSpriteNotFound,
+			Range: Range{
+				Start: Position{Line: 4, Character: 4},
+				End:   Position{Line: 4, Character: 12},
+			},
+			Message: `sprite "Ghost" in the zorder of assets/index.json does not exist`,
+			RelatedInformation: []DiagnosticRelatedInformation{{
+				Location: Location{
+					URI: "file:///assets/index.json",
+					Range: Range{
+						Start: Position{Line: 0, Character: 11},
+						End:   Position{Line: 0, Character: 18},
+					},
+				},
+				Message: `sprite "Ghost" is in the zorder here`,
+			}},
+			Data: makeDiagnosticData(DiagnosticData{
+				Fix:  DiagnosticFixRemoveZorderEntry,
+				Name: "Ghost",
+			}),
+		}, diags[0])
+
+		actions := codeActionsFor(t, s, diags)
+		require.Len(t, actions, 1)
+		assert.Equal(t, `Remove sprite "Ghost" from the zorder in index.json`, actions[0].Title)
+		require.NotNil(t, actions[0].Edit)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///assets/index.json": {{
+				Range: Range{
+					Start: Position{Line: 0, Character: 11},
+					End:   Position{Line: 0, Character: 19},
+				},
+				NewText: "",
+			}},
+		}, actions[0].Edit.Changes)
+	})
+
+	t.Run("LastZorderSpriteNotFound", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx":                       []byte(`onStart => {}`),
+			"assets/index.json":                  []byte(`{"zorder":["MySprite", "Ghost"]}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+		result, err := s.compile()
+		require.NoError(t, err)
+		edit := result.removeZorderEntryEdit("Ghost")
+		require.NotNil(t, edit)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///assets/index.json": {{
+				Range: Range{
+					Start: Position{Line: 0, Character: 21},
+					End:   Position{Line: 0, Character: 30},
+				},
+				NewText: "",
+			}},
+		}, edit.Changes)
+	})
+}
+
+func TestJSONObjectValueSpan(t *testing.T) {
+	content := []byte(`{"run": {"width": 1}, "map": {"mode": "fill", "width": 480}}`)
+
+	span, ok := jsonObjectValueSpan(content, "map", "width")
+	require.True(t, ok)
+	assert.Equal(t, "480", string(content[span.start:span.end]))
+
+	span, ok = jsonObjectValueSpan(content, "run")
+	require.True(t, ok)
+	assert.Equal(t, `{"width": 1}`, string(content[span.start:span.end]))
+
+	_, ok = jsonObjectValueSpan(content, "map", "height")
+	assert.False(t, ok)
+
+	_, ok = jsonObjectValueSpan([]byte(`[]`), "map")
+	assert.False(t, ok)
+}
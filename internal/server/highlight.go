@@ -4,7 +4,6 @@ import (
 	"slices"
 
 	gopast "github.com/goplus/gop/ast"
-	goptoken "github.com/goplus/gop/token"
 	"github.com/goplus/goxlsw/internal/util"
 )
 
@@ -42,113 +41,105 @@ func (s *Server) textDocumentDocumentHighlight(params *DocumentHighlightParams)
 			return true
 		}
 
-		kind := Text
+		highlights = append(highlights, DocumentHighlight{
+			Range: result.rangeForNode(ident),
+			Kind:  documentHighlightKindFor(ident, path),
+		})
+		return true
+	})
+	return &highlights, nil
+}
 
-		for _, parent := range slices.Backward(path[:len(path)-1]) {
-			switch p := parent.(type) {
-			case *gopast.ValueSpec:
-				for _, name := range p.Names {
-					if name == ident {
-						kind = Write
-						break
-					}
-				}
-			case *gopast.Field:
-				if p.Names != nil {
-					for _, name := range p.Names {
-						if name == ident {
-							kind = Write
-							break
-						}
-					}
-				}
-			case *gopast.FuncDecl:
-				if p.Name == ident {
-					kind = Write
-				}
-			case *gopast.TypeSpec:
-				if p.Name == ident {
-					kind = Write
-				}
-			case *gopast.LabeledStmt:
-				if p.Label == ident {
-					kind = Write
-				}
-			case *gopast.AssignStmt:
-				switch p.Tok {
-				case goptoken.ASSIGN:
-					for _, lhs := range p.Lhs {
-						if lhs == ident {
-							kind = Write
-							break
-						}
-					}
-					if kind != Write {
-						for _, rhs := range p.Rhs {
-							if rhs == ident {
-								kind = Read
-								break
-							}
-						}
-					}
-				case goptoken.DEFINE:
-					for _, lhs := range p.Lhs {
-						if lhs == ident {
-							kind = Write
-							break
-						}
-					}
-				default:
-					kind = Write
-				}
-			case *gopast.IncDecStmt:
-				if p.X == ident {
-					kind = Write
+// documentHighlightKindFor returns the [DocumentHighlightKind] of the given
+// identifier, where path is the path from the identifier to the root of the
+// AST file as returned by [util.PathEnclosingInterval].
+func documentHighlightKindFor(ident *gopast.Ident, path []gopast.Node) DocumentHighlightKind {
+	// expr is the innermost expression where the identifier is accessed as a
+	// whole, e.g., the selector expression "a.b" for the identifier "b".
+	var expr gopast.Node = ident
+	for _, parent := range path[1 : len(path)-1] {
+		switch p := parent.(type) {
+		case *gopast.ParenExpr:
+			expr = p
+			continue
+		case *gopast.SelectorExpr:
+			if p.Sel == expr {
+				expr = p
+				continue
+			}
+			return Read
+		case *gopast.ValueSpec:
+			if slices.Contains(p.Names, ident) {
+				return Write
+			}
+			return Read
+		case *gopast.Field:
+			if slices.Contains(p.Names, ident) {
+				return Write
+			}
+		case *gopast.FuncDecl:
+			if p.Name == ident {
+				return Write
+			}
+		case *gopast.TypeSpec:
+			if p.Name == ident {
+				return Write
+			}
+		case *gopast.LabeledStmt:
+			if p.Label == ident {
+				return Write
+			}
+		case *gopast.AssignStmt:
+			for _, lhs := range p.Lhs {
+				if lhs == expr {
+					return Write
 				}
-			case *gopast.RangeStmt:
-				if p.X == ident {
-					kind = Read
-				} else if p.Key == ident || p.Value == ident {
-					kind = Write
+			}
+			for _, rhs := range p.Rhs {
+				if rhs == expr {
+					return Read
 				}
-			case *gopast.TypeSwitchStmt:
-				if p.Assign != nil {
-					if assign, ok := p.Assign.(*gopast.AssignStmt); ok {
-						for _, lhs := range assign.Lhs {
-							if lhs == ident {
-								kind = Write
-								break
-							}
-						}
+			}
+		case *gopast.IncDecStmt:
+			if p.X == expr {
+				return Write
+			}
+		case *gopast.RangeStmt:
+			if p.X == expr {
+				return Read
+			} else if p.Key == expr || p.Value == expr {
+				return Write
+			}
+		case *gopast.TypeSwitchStmt:
+			if assign, ok := p.Assign.(*gopast.AssignStmt); ok {
+				for _, lhs := range assign.Lhs {
+					if lhs == expr {
+						return Write
 					}
 				}
-			case *gopast.BinaryExpr,
-				*gopast.UnaryExpr,
-				*gopast.CallExpr,
-				*gopast.CompositeLit,
-				*gopast.IndexExpr,
-				*gopast.ReturnStmt,
-				*gopast.SendStmt:
-				kind = Read
-			case *gopast.KeyValueExpr:
-				if p.Key == ident || p.Value == ident {
-					kind = Read
-				}
-			case *gopast.SelectorExpr:
-				if p.X == ident {
-					kind = Read
-				}
 			}
-			if kind != Text {
-				break
+		case *gopast.BinaryExpr,
+			*gopast.UnaryExpr,
+			*gopast.CallExpr,
+			*gopast.CompositeLit,
+			*gopast.IndexExpr,
+			*gopast.ReturnStmt,
+			*gopast.SendStmt,
+			*gopast.ExprStmt,
+			*gopast.IfStmt,
+			*gopast.ForStmt,
+			*gopast.SwitchStmt,
+			*gopast.CaseClause,
+			*gopast.StarExpr,
+			*gopast.SliceExpr,
+			*gopast.TypeAssertExpr:
+			return Read
+		case *gopast.KeyValueExpr:
+			if p.Key == expr || p.Value == expr {
+				return Read
 			}
 		}
-
-		highlights = append(highlights, DocumentHighlight{
-			Range: result.rangeForNode(ident),
-			Kind:  kind,
-		})
-		return true
-	})
-	return &highlights, nil
+		return Text
+	}
+	return Text
 }
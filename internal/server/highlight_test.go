@@ -67,4 +67,77 @@ onStart => {
 			Kind: Read,
 		})
 	})
+
+	t.Run("ReadWriteInEventHandler", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	score int
+	step  int
+)
+onStart => {
+	score = score + 1
+	score += step
+	score++
+	println score
+}
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		scoreHighlights, err := s.textDocumentDocumentHighlight(&DocumentHighlightParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 2, Character: 1},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, scoreHighlights)
+		assert.Equal(t, []DocumentHighlight{
+			{
+				Range: Range{Start: Position{Line: 2, Character: 1}, End: Position{Line: 2, Character: 6}},
+				Kind:  Write,
+			},
+			{
+				Range: Range{Start: Position{Line: 6, Character: 1}, End: Position{Line: 6, Character: 6}},
+				Kind:  Write,
+			},
+			{
+				Range: Range{Start: Position{Line: 6, Character: 9}, End: Position{Line: 6, Character: 14}},
+				Kind:  Read,
+			},
+			{
+				Range: Range{Start: Position{Line: 7, Character: 1}, End: Position{Line: 7, Character: 6}},
+				Kind:  Write,
+			},
+			{
+				Range: Range{Start: Position{Line: 8, Character: 1}, End: Position{Line: 8, Character: 6}},
+				Kind:  Write,
+			},
+			{
+				Range: Range{Start: Position{Line: 9, Character: 9}, End: Position{Line: 9, Character: 14}},
+				Kind:  Read,
+			},
+		}, *scoreHighlights)
+
+		stepHighlights, err := s.textDocumentDocumentHighlight(&DocumentHighlightParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 7, Character: 11},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, stepHighlights)
+		assert.Equal(t, []DocumentHighlight{
+			{
+				Range: Range{Start: Position{Line: 3, Character: 1}, End: Position{Line: 3, Character: 5}},
+				Kind:  Write,
+			},
+			{
+				Range: Range{Start: Position{Line: 7, Character: 10}, End: Position{Line: 7, Character: 14}},
+				Kind:  Read,
+			},
+		}, *stepHighlights)
+	})
 }
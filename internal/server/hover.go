@@ -1,12 +1,21 @@
 package server
 
 import (
+	"fmt"
 	"go/doc"
+	"go/types"
+	"path"
 	"strings"
+
+	gopast "github.com/goplus/gop/ast"
 )
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#textDocument_hover
 func (s *Server) textDocumentHover(params *HoverParams) (*Hover, error) {
+	if file, ok := s.spxResourceMetadataFileOf(params.TextDocument.URI); ok {
+		return s.spxResourceMetadataHover(file, params.Position), nil
+	}
+
 	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
 	if err != nil {
 		return nil, err
@@ -15,14 +24,16 @@ func (s *Server) textDocumentHover(params *HoverParams) (*Hover, error) {
 		return nil, nil
 	}
 	position := result.toPosition(astFile, params.Position)
+	markdown := s.clientSupportsHoverMarkdown()
 
 	if spxResourceRef := result.spxResourceRefAtASTFilePosition(astFile, position); spxResourceRef != nil {
+		value := spxResourceRef.ID.URI().HTML()
+		if spxResourceRef.Kind == SpxResourceRefKindStringLiteral {
+			value = s.spxResourcePreviewHTML(result, spxResourceRef.ID)
+		}
 		return &Hover{
-			Contents: MarkupContent{
-				Kind:  Markdown,
-				Value: spxResourceRef.ID.URI().HTML(),
-			},
-			Range: result.rangeForNode(spxResourceRef.Node),
+			Contents: markupContentFor(value, markdown),
+			Range:    result.rangeForNode(spxResourceRef.Node),
 		}, nil
 	}
 
@@ -33,30 +44,173 @@ func (s *Server) textDocumentHover(params *HoverParams) (*Hover, error) {
 		rpkg := result.spxImportsAtASTFilePosition(astFile, position)
 		if rpkg != nil {
 			return &Hover{
-				Contents: MarkupContent{
-					Kind:  Markdown,
-					Value: doc.Synopsis(rpkg.Pkg.Doc),
-				},
-				Range: result.rangeForNode(rpkg.Node),
+				Contents: markupContentFor(doc.Synopsis(rpkg.Pkg.Doc), markdown),
+				Range:    result.rangeForNode(rpkg.Node),
 			}, nil
 		}
 		return nil, nil
 	}
 
-	spxDefs := result.spxDefinitionsForIdent(ident)
-	if spxDefs == nil {
+	hoverHTML, ok := result.hoverHTMLForIdent(ident)
+	if !ok {
 		return nil, nil
 	}
+	return &Hover{
+		Contents: markupContentFor(hoverHTML, markdown),
+		Range:    result.rangeForNode(ident),
+	}, nil
+}
+
+// hoverHTMLForIdent returns the hover content of the given identifier in HTML,
+// i.e., the overload group it refers to or its spx definitions. It returns
+// false if there is nothing to show.
+func (r *compileResult) hoverHTMLForIdent(ident *gopast.Ident) (string, bool) {
+	if overloadsHTML, ok := r.spxOverloadGroupHTML(ident); ok {
+		return overloadsHTML, true
+	}
+
+	spxDefs := r.spxDefinitionsForIdent(ident)
+	if spxDefs == nil {
+		return "", false
+	}
 
 	var hoverContent strings.Builder
 	for _, spxDef := range spxDefs {
 		hoverContent.WriteString(spxDef.HTML())
 	}
-	return &Hover{
-		Contents: MarkupContent{
-			Kind:  Markdown,
-			Value: hoverContent.String(),
-		},
-		Range: result.rangeForNode(ident),
-	}, nil
+	return hoverContent.String(), true
+}
+
+// clientSupportsHoverMarkdown reports whether the client supports markdown
+// contents of hovers.
+func (s *Server) clientSupportsHoverMarkdown() bool {
+	clientCapabilities := s.clientCapabilities.Load()
+	if clientCapabilities == nil || clientCapabilities.TextDocument.Hover == nil {
+		return true
+	}
+	return supportsMarkdown(clientCapabilities.TextDocument.Hover.ContentFormat)
+}
+
+// spxOverloadGroupHTML returns the HTML representation of all overloads of the
+// Go+ overloaded function referenced by the given identifier, with the overload
+// resolved by the type checker highlighted. It returns false if the identifier
+// does not reference an overloaded function.
+func (r *compileResult) spxOverloadGroupHTML(ident *gopast.Ident) (string, bool) {
+	fun, ok := r.typeInfo.ObjectOf(ident).(*types.Func)
+	if !ok {
+		return "", false
+	}
+	overloads := r.funcOverloadsFor(ident, fun)
+	if len(overloads) < 2 {
+		return "", false
+	}
+	selectorTypeName := r.selectorTypeNameForIdent(ident)
+
+	var (
+		overviews []string
+		first     SpxDefinition
+		resolved  SpxDefinition
+		detail    string
+	)
+	for _, overload := range overloads {
+		defs := r.spxDefinitionsFor(overload, selectorTypeName)
+		if len(defs) == 0 {
+			continue
+		}
+		def := defs[0]
+		if len(overviews) == 0 {
+			first = def
+		}
+		overview := "`" + def.Overview + "`"
+		if overload == fun || (overload.Name() == fun.Name() && overload.Pkg() == fun.Pkg()) {
+			resolved = def
+			overview = "**" + overview + "**"
+		}
+		overviews = append(overviews, "- "+overview)
+		if detail == "" {
+			detail = def.Detail
+		}
+	}
+	if len(overviews) == 0 {
+		return "", false
+	}
+	if resolved.ID.Name == nil {
+		// None of the overloads is resolved by the type checker.
+		resolved = first
+	}
+	if resolved.Detail != "" {
+		detail = resolved.Detail
+	}
+	resolved.Detail = strings.Join(overviews, "\n") + "\n\n" + detail
+	return resolved.HTML(), true
+}
+
+// spxResourcePreviewHTML returns the HTML representation of the spx resource
+// with the given ID, including its metadata from the resource set and the URI
+// of its image or audio file for the client to render.
+func (s *Server) spxResourcePreviewHTML(result *compileResult, id SpxResourceID) string {
+	var (
+		kind  string
+		file  string
+		attrs []string
+	)
+	switch id := id.(type) {
+	case SpxBackdropResourceID:
+		kind = "backdrop"
+		if backdrop := result.spxResourceSet.Backdrop(id.BackdropName); backdrop != nil && backdrop.Path != "" {
+			file = backdrop.Path
+		}
+	case SpxSoundResourceID:
+		kind = "sound"
+		if sound := result.spxResourceSet.Sound(id.SoundName); sound != nil && sound.Path != "" {
+			file = path.Join("sounds", id.SoundName, sound.Path)
+		}
+	case SpxSpriteResourceID:
+		kind = "sprite"
+		if sprite := result.spxResourceSet.Sprite(id.SpriteName); sprite != nil {
+			attrs = append(attrs, fmt.Sprintf("costumes=%s", attr(fmt.Sprint(len(sprite.NormalCostumes)))))
+			attrs = append(attrs, fmt.Sprintf("animations=%s", attr(fmt.Sprint(len(sprite.Animations)))))
+			if i := sprite.CostumeIndex; i >= 0 && i < len(sprite.Costumes) && sprite.Costumes[i].Path != "" {
+				file = path.Join("sprites", id.SpriteName, sprite.Costumes[i].Path)
+			}
+		}
+	case SpxSpriteCostumeResourceID:
+		kind = "costume"
+		attrs = append(attrs, fmt.Sprintf("sprite=%s", attr(id.SpriteName)))
+		if sprite := result.spxResourceSet.Sprite(id.SpriteName); sprite != nil {
+			if costume := sprite.Costume(id.CostumeName); costume != nil && costume.Path != "" {
+				file = path.Join("sprites", id.SpriteName, costume.Path)
+			}
+		}
+	case SpxSpriteAnimationResourceID:
+		kind = "animation"
+		attrs = append(attrs, fmt.Sprintf("sprite=%s", attr(id.SpriteName)))
+		if sprite := result.spxResourceSet.Sprite(id.SpriteName); sprite != nil {
+			if animation := sprite.Animation(id.AnimationName); animation != nil && animation.FromIndex != nil {
+				if costume := sprite.Costumes[*animation.FromIndex]; costume.Path != "" {
+					file = path.Join("sprites", id.SpriteName, costume.Path)
+				}
+			}
+		}
+	case SpxWidgetResourceID:
+		kind = "widget"
+		if widget := result.spxResourceSet.Widget(id.WidgetName); widget != nil {
+			attrs = append(attrs, fmt.Sprintf("widget-type=%s", attr(widget.Type)))
+			if widget.Label != "" {
+				attrs = append(attrs, fmt.Sprintf("label=%s", attr(widget.Label)))
+			}
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<resource-preview resource=%s kind=%s name=%s", attr(string(id.URI())), attr(kind), attr(id.Name()))
+	for _, a := range attrs {
+		sb.WriteString(" " + a)
+	}
+	if file != "" {
+		src := result.toDocumentURI(path.Join(result.spxResourceRootDir, file))
+		fmt.Fprintf(&sb, " src=%s", attr(string(src)))
+	}
+	sb.WriteString(" />\n")
+	return sb.String()
 }
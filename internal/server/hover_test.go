@@ -1,6 +1,7 @@
 package server
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -295,7 +296,7 @@ onTouchStart ["MySprite"], => {}
 		assert.Equal(t, &Hover{
 			Contents: MarkupContent{
 				Kind:  Markdown,
-				Value: "<resource-preview resource=\"spx://resources/sprites/MySprite/costumes/costume1\" />\n",
+				Value: "<resource-preview resource=\"spx://resources/sprites/MySprite/costumes/costume1\" kind=\"costume\" name=\"costume1\" sprite=\"MySprite\" />\n",
 			},
 			Range: Range{
 				Start: Position{Line: 37, Character: 20},
@@ -455,7 +456,7 @@ onTouchStart ["MySprite"], => {}
 		assert.Equal(t, &Hover{
 			Contents: MarkupContent{
 				Kind:  Markdown,
-				Value: "<resource-preview resource=\"spx://resources/sprites/MySprite\" />\n",
+				Value: "<resource-preview resource=\"spx://resources/sprites/MySprite\" kind=\"sprite\" name=\"MySprite\" costumes=\"1\" animations=\"0\" />\n",
 			},
 			Range: Range{
 				Start: Position{Line: 8, Character: 14},
@@ -464,6 +465,124 @@ onTouchStart ["MySprite"], => {}
 		}, onTouchStartFirstArgHover)
 	})
 
+	t.Run("SpxResourcePreview", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+
+play "MySound"
+MySprite.setCostume "costume1"
+startBackdrop "backdrop1"
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx":                       []byte(``),
+			"assets/index.json":                  []byte(`{"backdrops":[{"name":"backdrop1","path":"backdrop1.png"}]}`),
+			"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1","path":"costume1.png"}]}`),
+			"assets/sounds/MySound/index.json":   []byte(`{"path":"MySound.wav"}`),
+		}), nil)
+
+		for _, tt := range []struct {
+			position Position
+			want     string
+		}{
+			{
+				position: Position{Line: 5, Character: 6},
+				want:     "<resource-preview resource=\"spx://resources/sounds/MySound\" kind=\"sound\" name=\"MySound\" src=\"file:///assets/sounds/MySound/MySound.wav\" />\n",
+			},
+			{
+				position: Position{Line: 6, Character: 21},
+				want:     "<resource-preview resource=\"spx://resources/sprites/MySprite/costumes/costume1\" kind=\"costume\" name=\"costume1\" sprite=\"MySprite\" src=\"file:///assets/sprites/MySprite/costume1.png\" />\n",
+			},
+			{
+				position: Position{Line: 7, Character: 15},
+				want:     "<resource-preview resource=\"spx://resources/backdrops/backdrop1\" kind=\"backdrop\" name=\"backdrop1\" src=\"file:///assets/backdrop1.png\" />\n",
+			},
+		} {
+			hover, err := s.textDocumentHover(&HoverParams{
+				TextDocumentPositionParams: TextDocumentPositionParams{
+					TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+					Position:     tt.position,
+				},
+			})
+			require.NoError(t, err)
+			require.NotNil(t, hover, "position %v", tt.position)
+			assert.Equal(t, tt.want, hover.Contents.Value)
+		}
+	})
+
+	t.Run("OverloadGroup", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySound Sound
+)
+
+play MySound, true
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":                []byte(`{}`),
+			"assets/sounds/MySound/index.json": []byte(`{}`),
+		}), nil)
+
+		hover, err := s.textDocumentHover(&HoverParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 5, Character: 1},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, hover)
+		assert.Equal(t, Range{
+			Start: Position{Line: 5, Character: 0},
+			End:   Position{Line: 5, Character: 4},
+		}, hover.Range)
+
+		value := hover.Contents.Value
+		assert.True(t, strings.HasPrefix(value, `<definition-item def-id="gop:github.com/goplus/spx?Game.play#1" overview="func play(media Sound, wait bool)">`))
+		assert.Contains(t, value, "- `func play(media Sound)`\n")
+		assert.Contains(t, value, "- **`func play(media Sound, wait bool)`**\n")
+		assert.Contains(t, value, "- `func play(media SoundName, action *PlayOptions)`\n")
+		assert.Contains(t, value, "Play func:")
+		assert.NotContains(t, value, "Gop_")
+	})
+
+	t.Run("PlainText", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySound Sound
+)
+
+play MySound, true
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":                []byte(`{}`),
+			"assets/sounds/MySound/index.json": []byte(`{}`),
+		}), nil)
+		s.clientCapabilities.Store(&ClientCapabilities{
+			TextDocument: TextDocumentClientCapabilities{
+				Hover: &HoverClientCapabilities{ContentFormat: []MarkupKind{PlainText}},
+			},
+		})
+
+		hover, err := s.textDocumentHover(&HoverParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 5, Character: 1},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, hover)
+		assert.Equal(t, PlainText, hover.Contents.Kind)
+
+		value := hover.Contents.Value
+		assert.NotContains(t, value, "<definition-item")
+		assert.NotContains(t, value, "`")
+		assert.Contains(t, value, "- func play(media Sound, wait bool)\n")
+	})
+
 	t.Run("InvalidPosition", func(t *testing.T) {
 		s := New(newMapFSWithoutModTime(map[string][]byte{
 			"main.spx": []byte(`var x int`),
@@ -624,4 +743,45 @@ onStart => {
 			End:   Position{Line: 4, Character: 18},
 		}, hover3.Range)
 	})
+
+	t.Run("SpxSpecialConstants", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+
+onKey KeyA, => {}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	turnTo Left
+}
+`),
+			"assets/index.json":                  []byte(`{"zorder":["MySprite"]}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		keyHover, err := s.textDocumentHover(&HoverParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 5, Character: 6},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, keyHover)
+		assert.Equal(t, "<definition-item def-id=\"gop:github.com/goplus/spx?KeyA\" overview=\"const KeyA = 0\">\nThe A key, e.g., `onKey KeyA, => {}`.</definition-item>\n", keyHover.Contents.Value)
+
+		dirHover, err := s.textDocumentHover(&HoverParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 2, Character: 9},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, dirHover)
+		assert.Contains(t, dirHover.Contents.Value, `overview="const Left = -90"`)
+		assert.Contains(t, dirHover.Contents.Value, "The direction pointing left, i.e., -90 degrees")
+	})
 }
@@ -0,0 +1,107 @@
+package server
+
+import "fmt"
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#initialize
+func (s *Server) initialize(params *InitializeParams) (*InitializeResult, error) {
+	s.clientCapabilities.Store(&params.Capabilities)
+	if err := s.setWorkspaceFolders(params.WorkspaceFolders); err != nil {
+		return nil, fmt.Errorf("invalid workspace folders: %w", err)
+	}
+	if params.InitializationOptions != nil {
+		config, err := parseConfig(params.InitializationOptions)
+		if err != nil {
+			return nil, fmt.Errorf("invalid initialization options: %w", err)
+		}
+		s.setConfig(config)
+	}
+	if params.Trace != nil {
+		if err := s.setTrace(&SetTraceParams{Value: *params.Trace}); err != nil {
+			return nil, err
+		}
+	}
+
+	return &InitializeResult{
+		Capabilities: s.serverCapabilities(),
+		ServerInfo: &ServerInfo{
+			Name: "goxlsw",
+		},
+	}, nil
+}
+
+// serverCapabilities returns the capabilities provided by the server.
+func (s *Server) serverCapabilities() ServerCapabilities {
+	positionEncoding := s.positionEncoding()
+	return ServerCapabilities{
+		PositionEncoding: &positionEncoding,
+		TextDocumentSync: TextDocumentSyncOptions{
+			OpenClose:         true,
+			Change:            Incremental,
+			WillSaveWaitUntil: true,
+		},
+		CompletionProvider: &CompletionOptions{
+			TriggerCharacters: []string{".", `"`},
+			ResolveProvider:   true,
+		},
+		HoverProvider: &Or_ServerCapabilities_hoverProvider{Value: true},
+		SignatureHelpProvider: &SignatureHelpOptions{
+			TriggerCharacters: []string{"(", ",", " "},
+		},
+		DeclarationProvider:       &Or_ServerCapabilities_declarationProvider{Value: true},
+		DefinitionProvider:        &Or_ServerCapabilities_definitionProvider{Value: true},
+		TypeDefinitionProvider:    &Or_ServerCapabilities_typeDefinitionProvider{Value: true},
+		ImplementationProvider:    &Or_ServerCapabilities_implementationProvider{Value: true},
+		ReferencesProvider:        &Or_ServerCapabilities_referencesProvider{Value: true},
+		DocumentHighlightProvider: &Or_ServerCapabilities_documentHighlightProvider{Value: true},
+		DocumentSymbolProvider:    &Or_ServerCapabilities_documentSymbolProvider{Value: true},
+		CodeLensProvider:          &CodeLensOptions{ResolveProvider: true},
+		DocumentLinkProvider:      &DocumentLinkOptions{ResolveProvider: true},
+		FoldingRangeProvider:      &Or_ServerCapabilities_foldingRangeProvider{Value: true},
+		SelectionRangeProvider:    &Or_ServerCapabilities_selectionRangeProvider{Value: true},
+		CallHierarchyProvider:     &Or_ServerCapabilities_callHierarchyProvider{Value: true},
+		LinkedEditingRangeProvider: &Or_ServerCapabilities_linkedEditingRangeProvider{
+			Value: true,
+		},
+		WorkspaceSymbolProvider: &Or_ServerCapabilities_workspaceSymbolProvider{Value: true},
+		CodeActionProvider: CodeActionOptions{
+			CodeActionKinds: []CodeActionKind{QuickFix, sourceAddEventHandler, refactorExtractFunction, refactorInlineVariable, refactorInlineCall, refactorRewriteCallStyle},
+			ResolveProvider: true,
+		},
+		DocumentFormattingProvider: &Or_ServerCapabilities_documentFormattingProvider{
+			Value: true,
+		},
+		DocumentRangeFormattingProvider: &Or_ServerCapabilities_documentRangeFormattingProvider{
+			Value: true,
+		},
+		DocumentOnTypeFormattingProvider: &DocumentOnTypeFormattingOptions{
+			FirstTriggerCharacter: "}",
+			MoreTriggerCharacter:  []string{"\n"},
+		},
+		RenameProvider: RenameOptions{
+			PrepareProvider:         true,
+			WorkDoneProgressOptions: WorkDoneProgressOptions{WorkDoneProgress: true},
+		},
+		ExecuteCommandProvider: &ExecuteCommandOptions{
+			Commands: spxCommands.commandNames(),
+		},
+		SemanticTokensProvider: SemanticTokensOptions{
+			Legend: s.clientSemanticTokensLegend(),
+			Full: &Or_SemanticTokensOptions_full{
+				Value: SemanticTokensFullDelta{Delta: true},
+			},
+		},
+		InlayHintProvider: InlayHintOptions{},
+		Workspace: &WorkspaceOptions{
+			WorkspaceFolders: &WorkspaceFolders5Gn{
+				Supported:           true,
+				ChangeNotifications: workspaceFoldersRegistrationID,
+			},
+		},
+		DiagnosticProvider: &Or_ServerCapabilities_diagnosticProvider{
+			Value: DiagnosticOptions{
+				InterFileDependencies: true,
+				WorkspaceDiagnostics:  true,
+			},
+		},
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerInitialize(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+
+		result, err := s.initialize(&InitializeParams{
+			XInitializeParams: XInitializeParams{
+				Capabilities: ClientCapabilities{
+					TextDocument: TextDocumentClientCapabilities{
+						SemanticTokens: SemanticTokensClientCapabilities{
+							MultilineTokenSupport: true,
+						},
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		require.NotNil(t, result.ServerInfo)
+		assert.Equal(t, "goxlsw", result.ServerInfo.Name)
+
+		clientCapabilities := s.clientCapabilities.Load()
+		require.NotNil(t, clientCapabilities)
+		assert.True(t, clientCapabilities.TextDocument.SemanticTokens.MultilineTokenSupport)
+
+		textDocumentSyncOptions, ok := result.Capabilities.TextDocumentSync.(TextDocumentSyncOptions)
+		require.True(t, ok)
+		assert.True(t, textDocumentSyncOptions.OpenClose)
+		assert.Equal(t, Incremental, textDocumentSyncOptions.Change)
+		assert.True(t, textDocumentSyncOptions.WillSaveWaitUntil)
+
+		semanticTokensOptions, ok := result.Capabilities.SemanticTokensProvider.(SemanticTokensOptions)
+		require.True(t, ok)
+		assert.Equal(t, SemanticTokensFullDelta{Delta: true}, semanticTokensOptions.Full.Value)
+		assert.Len(t, semanticTokensOptions.Legend.TokenTypes, len(semanticTokenTypesLegend))
+		assert.Contains(t, semanticTokensOptions.Legend.TokenTypes, string(EventType))
+		assert.Contains(t, semanticTokensOptions.Legend.TokenModifiers, string(modSpxResource))
+
+		require.NotNil(t, result.Capabilities.ExecuteCommandProvider)
+		assert.Contains(t, result.Capabilities.ExecuteCommandProvider.Commands, "spx.renameResources")
+		assert.Contains(t, result.Capabilities.ExecuteCommandProvider.Commands, "spx.getDefinitions")
+	})
+	t.Run("PositionEncoding", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+
+		result, err := s.initialize(&InitializeParams{})
+		require.NoError(t, err)
+		require.NotNil(t, result.Capabilities.PositionEncoding)
+		assert.Equal(t, UTF16, *result.Capabilities.PositionEncoding)
+
+		result, err = s.initialize(&InitializeParams{
+			XInitializeParams: XInitializeParams{
+				Capabilities: ClientCapabilities{
+					General: &GeneralClientCapabilities{
+						PositionEncodings: []PositionEncodingKind{UTF8, UTF16},
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, result.Capabilities.PositionEncoding)
+		assert.Equal(t, UTF8, *result.Capabilities.PositionEncoding)
+	})
+	t.Run("TailoredSemanticTokensLegend", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+
+		result, err := s.initialize(&InitializeParams{
+			XInitializeParams: XInitializeParams{
+				Capabilities: ClientCapabilities{
+					TextDocument: TextDocumentClientCapabilities{
+						SemanticTokens: SemanticTokensClientCapabilities{
+							TokenTypes: []string{"keyword", "function"},
+						},
+					},
+				},
+			},
+		})
+		require.NoError(t, err)
+
+		semanticTokensOptions, ok := result.Capabilities.SemanticTokensProvider.(SemanticTokensOptions)
+		require.True(t, ok)
+		assert.Equal(t, []string{"function", "keyword"}, semanticTokensOptions.Legend.TokenTypes)
+	})
+}
@@ -0,0 +1,117 @@
+package server
+
+import (
+	"go/types"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_inlayHint
+func (s *Server) textDocumentInlayHint(params *InlayHintParams) ([]InlayHint, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+	rangeStart := result.posAt(astFile, params.Range.Start)
+	rangeEnd := result.posAt(astFile, params.Range.End)
+
+	hints := []InlayHint{}
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		if node == nil || !node.Pos().IsValid() {
+			return true
+		}
+		if node.End() < rangeStart || node.Pos() > rangeEnd {
+			return false
+		}
+
+		switch node := node.(type) {
+		case *gopast.CallExpr:
+			hints = append(hints, result.parameterNameInlayHints(astFile, node)...)
+		case *gopast.AssignStmt:
+			if node.Tok == goptoken.DEFINE {
+				hints = append(hints, result.variableTypeInlayHints(astFile, node)...)
+			}
+		}
+		return true
+	})
+	return hints, nil
+}
+
+// parameterNameInlayHints returns the parameter name inlay hints for the
+// arguments of the given call expression.
+func (r *compileResult) parameterNameInlayHints(astFile *gopast.File, callExpr *gopast.CallExpr) []InlayHint {
+	var sig *types.Signature
+	if funcIdent := funcIdentOf(callExpr.Fun); funcIdent != nil {
+		if fun, ok := r.typeInfo.ObjectOf(funcIdent).(*types.Func); ok {
+			sig, _ = fun.Type().(*types.Signature)
+		}
+	}
+	if sig == nil {
+		sig, _ = r.typeInfo.TypeOf(callExpr.Fun).(*types.Signature)
+	}
+	if sig == nil {
+		return nil
+	}
+
+	var hints []InlayHint
+	for i, arg := range callExpr.Args {
+		if i >= sig.Params().Len() || (sig.Variadic() && i >= sig.Params().Len()-1) {
+			break
+		}
+		param := sig.Params().At(i)
+		paramName := param.Name()
+		if paramName == "" || paramName == "_" || strings.HasPrefix(paramName, "__") {
+			continue
+		}
+		if ident, ok := arg.(*gopast.Ident); ok && strings.EqualFold(ident.Name, paramName) {
+			continue
+		}
+		hints = append(hints, InlayHint{
+			Position:     r.fromPosition(astFile, r.fset.Position(arg.Pos())),
+			Label:        []InlayHintLabelPart{{Value: paramName + ":"}},
+			Kind:         Parameter,
+			PaddingRight: true,
+		})
+	}
+	return hints
+}
+
+// variableTypeInlayHints returns the inferred type inlay hints for the
+// variables defined by the given short variable declaration.
+func (r *compileResult) variableTypeInlayHints(astFile *gopast.File, assignStmt *gopast.AssignStmt) []InlayHint {
+	var hints []InlayHint
+	for _, lhs := range assignStmt.Lhs {
+		ident, ok := lhs.(*gopast.Ident)
+		if !ok || ident.Name == "_" {
+			continue
+		}
+		obj := r.typeInfo.Defs[ident]
+		if obj == nil || obj.Type() == nil || obj.Type() == types.Typ[types.Invalid] {
+			continue
+		}
+		hints = append(hints, InlayHint{
+			Position:    r.fromPosition(astFile, r.fset.Position(ident.End())),
+			Label:       []InlayHintLabelPart{{Value: getSimplifiedTypeString(obj.Type())}},
+			Kind:        Type,
+			PaddingLeft: true,
+		})
+	}
+	return hints
+}
+
+// funcIdentOf returns the identifier of the function being called by the
+// given call expression function, or nil if there is none.
+func funcIdentOf(fun gopast.Expr) *gopast.Ident {
+	switch fun := fun.(type) {
+	case *gopast.Ident:
+		return fun
+	case *gopast.SelectorExpr:
+		return fun.Sel
+	}
+	return nil
+}
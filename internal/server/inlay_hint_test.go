@@ -0,0 +1,108 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentInlayHint(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+
+func add(a, b int) int {
+	return a + b
+}
+
+MySprite.turn Left
+sum := add(1, 2)
+b := 3
+println add(sum, b)
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		hints, err := s.textDocumentInlayHint(&InlayHintParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range: Range{
+				Start: Position{Line: 9, Character: 0},
+				End:   Position{Line: 13, Character: 0},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, hints)
+
+		assert.Contains(t, hints, InlayHint{
+			Position:     Position{Line: 9, Character: 14},
+			Label:        []InlayHintLabelPart{{Value: "dir:"}},
+			Kind:         Parameter,
+			PaddingRight: true,
+		})
+		assert.Contains(t, hints, InlayHint{
+			Position:     Position{Line: 10, Character: 11},
+			Label:        []InlayHintLabelPart{{Value: "a:"}},
+			Kind:         Parameter,
+			PaddingRight: true,
+		})
+		assert.Contains(t, hints, InlayHint{
+			Position:     Position{Line: 10, Character: 14},
+			Label:        []InlayHintLabelPart{{Value: "b:"}},
+			Kind:         Parameter,
+			PaddingRight: true,
+		})
+		assert.Contains(t, hints, InlayHint{
+			Position:    Position{Line: 10, Character: 3},
+			Label:       []InlayHintLabelPart{{Value: "int"}},
+			Kind:        Type,
+			PaddingLeft: true,
+		})
+		assert.Contains(t, hints, InlayHint{
+			Position:    Position{Line: 11, Character: 1},
+			Label:       []InlayHintLabelPart{{Value: "int"}},
+			Kind:        Type,
+			PaddingLeft: true,
+		})
+
+		// The argument b matches the parameter name, so no hint for it.
+		assert.Contains(t, hints, InlayHint{
+			Position:     Position{Line: 12, Character: 12},
+			Label:        []InlayHintLabelPart{{Value: "a:"}},
+			Kind:         Parameter,
+			PaddingRight: true,
+		})
+		assert.NotContains(t, hints, InlayHint{
+			Position:     Position{Line: 12, Character: 17},
+			Label:        []InlayHintLabelPart{{Value: "b:"}},
+			Kind:         Parameter,
+			PaddingRight: true,
+		})
+	})
+
+	t.Run("OutOfRange", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+x := 1
+println x
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		hints, err := s.textDocumentInlayHint(&InlayHintParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Range: Range{
+				Start: Position{Line: 0, Character: 0},
+				End:   Position{Line: 0, Character: 0},
+			},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, hints)
+	})
+}
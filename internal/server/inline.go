@@ -0,0 +1,439 @@
+package server
+
+import (
+	"cmp"
+	"fmt"
+	"go/types"
+	"slices"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/internal/util"
+)
+
+const (
+	// refactorInlineVariable is the kind of code actions inlining variables.
+	refactorInlineVariable CodeActionKind = RefactorInline + ".variable"
+
+	// refactorInlineCall is the kind of code actions inlining function calls.
+	refactorInlineCall CodeActionKind = RefactorInline + ".call"
+)
+
+// inlineCodeActions returns the code actions inlining the variable or the
+// function call at the given position in the given file.
+func (r *compileResult) inlineCodeActions(spxFile string, astFile *gopast.File, position Position) []CodeAction {
+	ident := r.identAtASTFilePosition(astFile, r.toPosition(astFile, position))
+	if ident == nil {
+		return nil
+	}
+	var (
+		action CodeAction
+		ok     bool
+	)
+	switch obj := r.typeInfo.ObjectOf(ident).(type) {
+	case *types.Var:
+		action, ok = r.inlineVariableCodeAction(spxFile, astFile, obj)
+	case *types.Func:
+		action, ok = r.inlineCallCodeAction(spxFile, astFile, ident, obj)
+	}
+	if !ok {
+		return nil
+	}
+	return []CodeAction{action}
+}
+
+// inlineVariableCodeAction returns the code action replacing all uses of the
+// given local variable with its initial value and removing its declaration.
+// The variable must be declared alone by `v := value` or `var v = value`, and
+// never be assigned again.
+//
+// It reports false if inlining may change the behavior of the code, i.e., if
+// evaluating the value has side effects, the variables it uses may change
+// after the declaration, or the names it uses refer to other objects at the
+// uses of the variable.
+func (r *compileResult) inlineVariableCodeAction(spxFile string, astFile *gopast.File, v *types.Var) (CodeAction, bool) {
+	if v.IsField() || v.Pkg() != r.mainPkg || v.Parent() == nil || v.Parent() == r.mainPkg.Scope() {
+		return CodeAction{}, false
+	}
+	defIdent := r.defIdentFor(v)
+	if defIdent == nil || r.nodeASTFile(defIdent) != astFile {
+		return CodeAction{}, false
+	}
+	path, _ := util.PathEnclosingInterval(astFile, defIdent.Pos(), defIdent.End())
+	var (
+		value    gopast.Expr
+		declStmt gopast.Stmt
+		parent   gopast.Node
+	)
+	switch {
+	case len(path) > 2:
+		if assignStmt, ok := path[1].(*gopast.AssignStmt); ok && assignStmt.Tok == goptoken.DEFINE &&
+			len(assignStmt.Lhs) == 1 && len(assignStmt.Rhs) == 1 {
+			value, declStmt, parent = assignStmt.Rhs[0], assignStmt, path[2]
+			break
+		}
+		if len(path) <= 4 {
+			break
+		}
+		valueSpec, ok := path[1].(*gopast.ValueSpec)
+		if !ok || len(valueSpec.Names) != 1 || len(valueSpec.Values) != 1 {
+			break
+		}
+		if genDecl, ok := path[2].(*gopast.GenDecl); !ok || len(genDecl.Specs) != 1 {
+			break
+		}
+		if stmt, ok := path[3].(*gopast.DeclStmt); ok {
+			value, declStmt, parent = valueSpec.Values[0], stmt, path[4]
+		}
+	}
+	if value == nil || !r.isStmtOnOwnLines(astFile, declStmt) {
+		return CodeAction{}, false
+	}
+	if _, ok := parent.(*gopast.BlockStmt); !ok {
+		return CodeAction{}, false
+	}
+	if !r.isPureExpr(value) {
+		return CodeAction{}, false
+	}
+
+	refIdents := r.refIdentsFor(v)
+	if len(refIdents) == 0 {
+		return CodeAction{}, false
+	}
+	slices.SortFunc(refIdents, func(a, b *gopast.Ident) int {
+		return cmp.Compare(a.Pos(), b.Pos())
+	})
+	valueText := string(r.nodeText(astFile, value))
+	edits := []TextEdit{{
+		Range: Range{
+			Start: Position{Line: uint32(r.fset.Position(declStmt.Pos()).Line - 1)},
+			End:   Position{Line: uint32(r.fset.Position(declStmt.End()).Line)},
+		},
+	}}
+	for _, refIdent := range refIdents {
+		refPath, _ := util.PathEnclosingInterval(astFile, refIdent.Pos(), refIdent.End())
+		if len(refPath) < 2 || isAddressTaken(refPath) || documentHighlightKindFor(refIdent, refPath) == Write {
+			return CodeAction{}, false
+		}
+		if !r.resolvesSameAt(value, nil, refIdent.Pos()) {
+			return CodeAction{}, false
+		}
+		edits = append(edits, TextEdit{
+			Range:   r.rangeForNode(refIdent),
+			NewText: parenthesizeIfNeeded(valueText, value, refIdent, refPath[1]),
+		})
+	}
+	return CodeAction{
+		Title: fmt.Sprintf("Inline variable %q", v.Name()),
+		Kind:  refactorInlineVariable,
+		Edit: &WorkspaceEdit{
+			Changes: map[DocumentURI][]TextEdit{r.documentURIs[spxFile]: edits},
+		},
+	}, true
+}
+
+// inlineCallCodeAction returns the code action replacing the call of the given
+// function by the given identifier with the body of the function. The
+// function must be declared in the given file with a body of a single line,
+// which is either a return statement of a single result, or an expression
+// statement if the function has no results.
+//
+// It reports false if inlining may change the behavior of the code, i.e., if
+// the side effects of the arguments would be evaluated more than once, not at
+// all, or in a different order, or if the names used by the body refer to
+// other objects at the call.
+func (r *compileResult) inlineCallCodeAction(spxFile string, astFile *gopast.File, ident *gopast.Ident, fn *types.Func) (CodeAction, bool) {
+	if fn.Pkg() != r.mainPkg {
+		return CodeAction{}, false
+	}
+	path, _ := util.PathEnclosingInterval(astFile, ident.Pos(), ident.End())
+	if len(path) < 3 {
+		return CodeAction{}, false
+	}
+	callExpr, ok := path[1].(*gopast.CallExpr)
+	if !ok || callExpr.Fun != ident {
+		return CodeAction{}, false
+	}
+	sig, ok := fn.Type().(*types.Signature)
+	if !ok || sig.Variadic() || sig.Params().Len() != len(callExpr.Args) {
+		return CodeAction{}, false
+	}
+
+	var funcDecl *gopast.FuncDecl
+	for _, decl := range astFile.Decls {
+		if decl, ok := decl.(*gopast.FuncDecl); ok && !decl.Shadow && decl.Name != nil && r.typeInfo.Defs[decl.Name] == fn {
+			funcDecl = decl
+			break
+		}
+	}
+	if funcDecl == nil || funcDecl.Body == nil || len(funcDecl.Body.List) != 1 {
+		return CodeAction{}, false
+	}
+	var body gopast.Expr
+	_, isExprStmtCall := path[2].(*gopast.ExprStmt)
+	switch stmt := funcDecl.Body.List[0].(type) {
+	case *gopast.ReturnStmt:
+		if sig.Results().Len() == 1 && len(stmt.Results) == 1 && !isExprStmtCall {
+			body = stmt.Results[0]
+		}
+	case *gopast.ExprStmt:
+		if sig.Results().Len() == 0 && isExprStmtCall {
+			body = stmt.X
+		}
+	}
+	if body == nil || r.fset.Position(body.Pos()).Line != r.fset.Position(body.End()).Line {
+		return CodeAction{}, false
+	}
+
+	params := make(map[types.Object]int, sig.Params().Len())
+	for i := range sig.Params().Len() {
+		params[sig.Params().At(i)] = i
+	}
+	var (
+		paramUses   = make([]int, len(callExpr.Args))
+		impureIndex = -1
+		inlinable   = true
+	)
+	for i, arg := range callExpr.Args {
+		if !r.isPureExpr(arg) {
+			if impureIndex >= 0 {
+				return CodeAction{}, false
+			}
+			impureIndex = i
+		}
+	}
+	var replacements []textReplacement
+	gopast.Inspect(body, func(node gopast.Node) bool {
+		ident, ok := node.(*gopast.Ident)
+		if !ok || !inlinable {
+			return inlinable
+		}
+		obj := r.typeInfo.Uses[ident]
+		if obj == nil {
+			return true
+		}
+		if obj == fn {
+			inlinable = false // Recursive.
+			return false
+		}
+		i, ok := params[obj]
+		if !ok {
+			inlinable = r.resolvesSameAt(ident, body, callExpr.Pos())
+			return inlinable
+		}
+		identPath, _ := util.PathEnclosingInterval(astFile, ident.Pos(), ident.End())
+		if len(identPath) < 2 || isAddressTaken(identPath) || documentHighlightKindFor(ident, identPath) == Write {
+			inlinable = false
+			return false
+		}
+		paramUses[i]++
+		arg := callExpr.Args[i]
+		replacements = append(replacements, textReplacement{
+			start: ident.Pos(),
+			end:   ident.End(),
+			text:  parenthesizeIfNeeded(string(r.nodeText(astFile, arg)), arg, ident, identPath[1]),
+		})
+		return true
+	})
+	if !inlinable || (impureIndex >= 0 && paramUses[impureIndex] != 1) {
+		// The side effects of the argument would be dropped or repeated.
+		return CodeAction{}, false
+	}
+
+	bodyText := r.nodeTextWithReplacements(astFile, body, replacements)
+	return CodeAction{
+		Title: fmt.Sprintf("Inline call to %q", ident.Name),
+		Kind:  refactorInlineCall,
+		Edit: &WorkspaceEdit{
+			Changes: map[DocumentURI][]TextEdit{
+				r.documentURIs[spxFile]: {{
+					Range:   r.rangeForNode(callExpr),
+					NewText: parenthesizeIfNeeded(bodyText, body, callExpr, path[2]),
+				}},
+			},
+		},
+	}, true
+}
+
+// isPureExpr reports whether evaluating the given expression has no side
+// effects and always gives the same result during the execution of spx event
+// handlers. It only consists of literals, constants, type conversions, and
+// variables that are never assigned after their declarations.
+func (r *compileResult) isPureExpr(expr gopast.Expr) bool {
+	pure := true
+	gopast.Inspect(expr, func(node gopast.Node) bool {
+		if !pure {
+			return false
+		}
+		switch node := node.(type) {
+		case nil, *gopast.BasicLit, *gopast.ParenExpr, *gopast.SelectorExpr, *gopast.CompositeLit, *gopast.KeyValueExpr:
+		case *gopast.BinaryExpr:
+			pure = node.Op != goptoken.ARROW
+		case *gopast.UnaryExpr:
+			pure = node.Op != goptoken.ARROW && node.Op != goptoken.AND
+		case *gopast.CallExpr:
+			tv, ok := r.typeInfo.Types[node.Fun]
+			pure = ok && tv.IsType()
+		case *gopast.Ident:
+			switch obj := r.typeInfo.ObjectOf(node).(type) {
+			case *types.Var:
+				if obj.Pkg() != r.mainPkg {
+					pure = false
+					break
+				}
+				for _, refIdent := range r.refIdentsFor(obj) {
+					astFile := r.nodeASTFile(refIdent)
+					if astFile == nil {
+						continue
+					}
+					refPath, _ := util.PathEnclosingInterval(astFile, refIdent.Pos(), refIdent.End())
+					if len(refPath) < 2 || isAddressTaken(refPath) || documentHighlightKindFor(refIdent, refPath) == Write {
+						pure = false
+						break
+					}
+				}
+			case *types.Func:
+				// Methods are only pure as type conversions, which are
+				// handled above.
+				pure = false
+			}
+		case *gopast.ArrayType, *gopast.MapType, *gopast.StructType, *gopast.InterfaceType, *gopast.StarExpr:
+		default:
+			pure = false
+		}
+		return pure
+	})
+	return pure
+}
+
+// resolvesSameAt reports whether the identifiers used by the given expression
+// still refer to the same objects when the expression is moved to the given
+// position. The identifiers declared in the given scope node, e.g., the
+// parameters of the function being inlined, are not checked.
+func (r *compileResult) resolvesSameAt(node gopast.Node, scopeNode gopast.Node, pos goptoken.Pos) bool {
+	scope := r.innermostScopeAt(pos)
+	if scope == nil {
+		return false
+	}
+	same := true
+	gopast.Inspect(node, func(node gopast.Node) bool {
+		ident, ok := node.(*gopast.Ident)
+		if !ok || !same {
+			return same
+		}
+		obj := r.typeInfo.Uses[ident]
+		if obj == nil {
+			return true
+		}
+		if scopeNode != nil && obj.Pos() >= scopeNode.Pos() && obj.Pos() < scopeNode.End() {
+			return true
+		}
+		if v, ok := obj.(*types.Var); ok && v.IsField() {
+			// Fields are resolved through the class, so they can only be
+			// shadowed by other objects.
+			_, found := scope.LookupParent(ident.Name, pos)
+			same = found == nil || found == obj
+			return same
+		}
+		if _, ok := obj.(*types.Func); ok && obj.Parent() == nil {
+			// Methods of the class.
+			_, found := scope.LookupParent(ident.Name, pos)
+			same = found == nil
+			return same
+		}
+		_, found := scope.LookupParent(ident.Name, pos)
+		same = found == obj
+		return same
+	})
+	return same
+}
+
+// isAddressTaken reports whether the address of the identifier is taken in
+// the given path from the identifier to the root of the AST file as returned
+// by [util.PathEnclosingInterval].
+func isAddressTaken(path []gopast.Node) bool {
+	for _, node := range path[1:] {
+		switch node := node.(type) {
+		case *gopast.ParenExpr:
+			continue
+		case *gopast.UnaryExpr:
+			return node.Op == goptoken.AND
+		}
+		return false
+	}
+	return false
+}
+
+// parenthesizeIfNeeded returns the given text of the given expression, wrapped
+// in parentheses if it is needed to keep the meaning of the expression when it
+// replaces the given node, a child of the given parent node.
+func parenthesizeIfNeeded(text string, expr gopast.Expr, replaced, parent gopast.Node) string {
+	var needed bool
+	switch expr.(type) {
+	case *gopast.BinaryExpr, *gopast.UnaryExpr, *gopast.StarExpr:
+		switch parent := parent.(type) {
+		case *gopast.BinaryExpr, *gopast.UnaryExpr, *gopast.StarExpr:
+			_, isBinary := expr.(*gopast.BinaryExpr)
+			needed = isBinary
+		case *gopast.SelectorExpr:
+			needed = parent.X == replaced
+		case *gopast.IndexExpr:
+			needed = parent.X == replaced
+		case *gopast.SliceExpr:
+			needed = parent.X == replaced
+		case *gopast.CallExpr:
+			needed = parent.Fun == replaced
+		}
+	}
+	if needed {
+		return "(" + text + ")"
+	}
+	return text
+}
+
+// textReplacement is a replacement of the source text between two positions.
+type textReplacement struct {
+	start, end goptoken.Pos
+	text       string
+}
+
+// nodeText returns the source text of the given node in the given AST file.
+func (r *compileResult) nodeText(astFile *gopast.File, node gopast.Node) []byte {
+	return astFile.Code[r.fset.Position(node.Pos()).Offset:r.fset.Position(node.End()).Offset]
+}
+
+// nodeTextWithReplacements returns the source text of the given node in the
+// given AST file with the given non-overlapping replacements applied.
+func (r *compileResult) nodeTextWithReplacements(astFile *gopast.File, node gopast.Node, replacements []textReplacement) string {
+	slices.SortFunc(replacements, func(a, b textReplacement) int {
+		return cmp.Compare(a.start, b.start)
+	})
+	var sb strings.Builder
+	offset := r.fset.Position(node.Pos()).Offset
+	for _, replacement := range replacements {
+		sb.Write(astFile.Code[offset:r.fset.Position(replacement.start).Offset])
+		sb.WriteString(replacement.text)
+		offset = r.fset.Position(replacement.end).Offset
+	}
+	sb.Write(astFile.Code[offset:r.fset.Position(node.End()).Offset])
+	return sb.String()
+}
+
+// isStmtOnOwnLines reports whether the given statement does not share its
+// lines with other code in the given AST file.
+func (r *compileResult) isStmtOnOwnLines(astFile *gopast.File, stmt gopast.Stmt) bool {
+	code := astFile.Code
+	start, end := r.fset.Position(stmt.Pos()).Offset, r.fset.Position(stmt.End()).Offset
+	for i := start - 1; i >= 0 && code[i] != '\n'; i-- {
+		if code[i] != ' ' && code[i] != '\t' {
+			return false
+		}
+	}
+	for i := end; i < len(code) && code[i] != '\n'; i++ {
+		if code[i] != ' ' && code[i] != '\t' && code[i] != '\r' {
+			return false
+		}
+	}
+	return true
+}
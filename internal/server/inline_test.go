@@ -0,0 +1,154 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerInlineCodeActions(t *testing.T) {
+	codeActionsFor := func(t *testing.T, spx string, position Position) []CodeAction {
+		fileMap := newTestFileMap()
+		fileMap["MyAircraft.spx"] = []byte(spx)
+		s := New(newMapFSWithoutModTime(fileMap), nil)
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MyAircraft.spx"},
+			Range:        Range{Start: position, End: position},
+			Context:      CodeActionContext{Only: []CodeActionKind{RefactorInline}},
+		})
+		require.NoError(t, err)
+		return actions
+	}
+
+	t.Run("InlineVariable", func(t *testing.T) {
+		actions := codeActionsFor(t, `const step = 2
+
+onStart => {
+	n := step + 1
+	say n
+	turn n * 10
+}
+`, Position{Line: 3, Character: 1})
+		require.Len(t, actions, 1)
+		assert.Equal(t, CodeAction{
+			Title: `Inline variable "n"`,
+			Kind:  refactorInlineVariable,
+			Edit: &WorkspaceEdit{
+				Changes: map[DocumentURI][]TextEdit{
+					"file:///MyAircraft.spx": {
+						{
+							Range: Range{
+								Start: Position{Line: 3, Character: 0},
+								End:   Position{Line: 4, Character: 0},
+							},
+							NewText: "",
+						},
+						{
+							Range: Range{
+								Start: Position{Line: 4, Character: 5},
+								End:   Position{Line: 4, Character: 6},
+							},
+							NewText: "step + 1",
+						},
+						{
+							Range: Range{
+								Start: Position{Line: 5, Character: 6},
+								End:   Position{Line: 5, Character: 7},
+							},
+							NewText: "(step + 1)",
+						},
+					},
+				},
+			},
+		}, actions[0])
+	})
+
+	t.Run("InlineVariableAtUse", func(t *testing.T) {
+		actions := codeActionsFor(t, `onStart => {
+	var msg = "hi"
+	say msg
+}
+`, Position{Line: 2, Character: 6})
+		require.Len(t, actions, 1)
+		assert.Equal(t, `Inline variable "msg"`, actions[0].Title)
+		edits := actions[0].Edit.Changes["file:///MyAircraft.spx"]
+		require.Len(t, edits, 2)
+		assert.Equal(t, Range{Start: Position{Line: 1}, End: Position{Line: 2}}, edits[0].Range)
+		assert.Equal(t, `"hi"`, edits[1].NewText)
+	})
+
+	t.Run("InlineCallWithExpressionBody", func(t *testing.T) {
+		actions := codeActionsFor(t, `func double(n int) int {
+	return n * 2
+}
+
+onStart => {
+	x := 1
+	say double(x + 1)
+}
+`, Position{Line: 6, Character: 6})
+		require.Len(t, actions, 1)
+		assert.Equal(t, CodeAction{
+			Title: `Inline call to "double"`,
+			Kind:  refactorInlineCall,
+			Edit: &WorkspaceEdit{
+				Changes: map[DocumentURI][]TextEdit{
+					"file:///MyAircraft.spx": {{
+						Range: Range{
+							Start: Position{Line: 6, Character: 5},
+							End:   Position{Line: 6, Character: 18},
+						},
+						NewText: "(x + 1) * 2",
+					}},
+				},
+			},
+		}, actions[0])
+	})
+
+	t.Run("InlineCallWithStatementBody", func(t *testing.T) {
+		actions := codeActionsFor(t, `func greet(name string) {
+	say "hi " + name
+}
+
+onStart => {
+	greet "Go+"
+}
+`, Position{Line: 5, Character: 2})
+		require.Len(t, actions, 1)
+		assert.Equal(t, `Inline call to "greet"`, actions[0].Title)
+		assert.Equal(t, []TextEdit{{
+			Range: Range{
+				Start: Position{Line: 5, Character: 1},
+				End:   Position{Line: 5, Character: 12},
+			},
+			NewText: `say "hi " + "Go+"`,
+		}}, actions[0].Edit.Changes["file:///MyAircraft.spx"])
+	})
+
+	t.Run("NotInlinable", func(t *testing.T) {
+		for _, tt := range []struct {
+			name     string
+			spx      string
+			position Position
+		}{
+			{"ReassignedVariable", "onStart => {\n\tn := 1\n\tn = 2\n\tsay n\n}\n", Position{Line: 1, Character: 1}},
+			{"IncrementedVariable", "onStart => {\n\tn := 1\n\tn++\n\tsay n\n}\n", Position{Line: 1, Character: 1}},
+			{"AddressTakenVariable", "onStart => {\n\tn := 1\n\tp := &n\n\tsay p\n}\n", Position{Line: 1, Character: 1}},
+			{"ValueWithSideEffects", "func next() int {\n\treturn 1\n}\n\nonStart => {\n\tn := next()\n\tsay n\n\tsay n\n}\n", Position{Line: 5, Character: 1}},
+			{"ValueUsingMutableVariable", "onStart => {\n\tm := 1\n\tn := m\n\tm = 2\n\tsay n\n}\n", Position{Line: 2, Character: 1}},
+			{"ValueShadowedAtUse", "onStart => {\n\tm := 1\n\tn := m\n\tonClick => {\n\t\tm := \"s\"\n\t\tsay m, n\n\t}\n}\n", Position{Line: 2, Character: 1}},
+			{"MultipleNames", "onStart => {\n\ta, b := 1, 2\n\tsay a, b\n}\n", Position{Line: 1, Character: 1}},
+			{"Field", "var (\n\tspeed int\n)\n\nonStart => {\n\tsay speed\n}\n", Position{Line: 5, Character: 6}},
+			{"CallOfMultiStatementFunc", "func greet() {\n\tsay \"hi\"\n\tsay \"bye\"\n}\n\nonStart => {\n\tgreet\n}\n", Position{Line: 6, Character: 2}},
+			{"CallWithRepeatedImpureArgument", "func next() int {\n\treturn 1\n}\n\nfunc double(n int) int {\n\treturn n + n\n}\n\nonStart => {\n\tsay double(next())\n}\n", Position{Line: 9, Character: 6}},
+			{"CallWithDroppedImpureArgument", "func next() int {\n\treturn 1\n}\n\nfunc one(n int) int {\n\treturn 1\n}\n\nonStart => {\n\tsay one(next())\n}\n", Position{Line: 9, Character: 6}},
+			{"RecursiveCall", "func loop(n int) {\n\tloop n\n}\n\nonStart => {\n\tloop 1\n}\n", Position{Line: 5, Character: 2}},
+			{"CallWithShadowedName", "var (\n\tspeed int\n)\n\nfunc getSpeed() int {\n\treturn speed\n}\n\nonStart => {\n\tspeed := 1\n\tsay getSpeed(), speed\n}\n", Position{Line: 10, Character: 6}},
+		} {
+			t.Run(tt.name, func(t *testing.T) {
+				assert.Empty(t, codeActionsFor(t, tt.spx, tt.position))
+			})
+		}
+	})
+}
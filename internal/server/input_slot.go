@@ -0,0 +1,155 @@
+package server
+
+import (
+	"errors"
+	"go/types"
+	"slices"
+	"strconv"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// spxGetInputSlots gets the input slots of command-style calls in a range of a
+// document, so they can be rendered as widgets over the code.
+func (s *Server) spxGetInputSlots(params []SpxGetInputSlotsParams) ([]SpxInputSlot, error) {
+	if l := len(params); l == 0 {
+		return nil, nil
+	} else if l > 1 {
+		return nil, errors.New("spx.getInputSlots only supports one document at a time")
+	}
+	param := params[0]
+
+	result, spxFile, astFile, err := s.compileAndGetASTFileForDocumentURI(param.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+	rangeStart := result.posAt(astFile, param.Range.Start)
+	rangeEnd := result.posAt(astFile, param.Range.End)
+
+	slots := []SpxInputSlot{}
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		if node == nil || !node.Pos().IsValid() {
+			return true
+		}
+		if node.End() < rangeStart || node.Pos() > rangeEnd {
+			return false
+		}
+
+		callExpr, ok := node.(*gopast.CallExpr)
+		if !ok || !callExpr.IsCommand() {
+			return true
+		}
+		for i, arg := range callExpr.Args {
+			if arg.End() < rangeStart || arg.Pos() > rangeEnd {
+				continue
+			}
+			if slot, ok := result.spxInputSlotFor(spxFile, callExpr, i); ok {
+				slots = append(slots, slot)
+			}
+		}
+		return true
+	})
+	return slots, nil
+}
+
+// spxInputSlotFor returns the input slot for the argument at the given index
+// of the given call expression in the given spx file. It returns false if the
+// argument is not an input slot.
+func (r *compileResult) spxInputSlotFor(spxFile string, callExpr *gopast.CallExpr, argIndex int) (SpxInputSlot, bool) {
+	acceptedTypes := r.acceptedArgTypes(callExpr, argIndex)
+	if len(acceptedTypes) == 0 {
+		return SpxInputSlot{}, false
+	}
+	slot := SpxInputSlot{
+		Range:         r.rangeForNode(callExpr.Args[argIndex]),
+		AcceptedTypes: make([]string, 0, len(acceptedTypes)),
+	}
+	for _, typ := range acceptedTypes {
+		slot.AcceptedTypes = append(slot.AcceptedTypes, getSimplifiedTypeString(typ))
+	}
+
+	switch arg := callExpr.Args[argIndex].(type) {
+	case *gopast.BasicLit:
+		slot.Kind = SpxInputSlotKindLiteral
+		slot.Value = arg.Value
+		if arg.Kind != goptoken.STRING {
+			break
+		}
+		for _, typ := range acceptedTypes {
+			ids := r.spxResourceIDsForNameType(typ, r.spxSpriteResourceForCall(spxFile, callExpr))
+			if ids == nil {
+				continue
+			}
+			slot.Kind = SpxInputSlotKindResourceName
+			slot.Candidates = make([]string, 0, len(ids))
+			for _, id := range ids {
+				slot.Candidates = append(slot.Candidates, strconv.Quote(id.Name()))
+			}
+			slices.Sort(slot.Candidates)
+			break
+		}
+	case *gopast.UnaryExpr:
+		lit, ok := arg.X.(*gopast.BasicLit)
+		if !ok || (arg.Op != goptoken.SUB && arg.Op != goptoken.ADD) || (lit.Kind != goptoken.INT && lit.Kind != goptoken.FLOAT) {
+			return SpxInputSlot{}, false
+		}
+		slot.Kind = SpxInputSlotKindLiteral
+		slot.Value = arg.Op.String() + lit.Value
+	case *gopast.Ident:
+		c, ok := r.typeInfo.ObjectOf(arg).(*types.Const)
+		if !ok || c.Pkg() == nil {
+			return SpxInputSlot{}, false
+		}
+		switch c.Type().(type) {
+		case *types.Named, *types.Alias:
+		default:
+			return SpxInputSlot{}, false
+		}
+		slot.Kind = SpxInputSlotKindConstant
+		slot.Value = arg.Name
+		scope := c.Pkg().Scope()
+		for _, name := range scope.Names() {
+			if candidate, ok := scope.Lookup(name).(*types.Const); ok && candidate.Exported() && candidate.Type() == c.Type() {
+				slot.Candidates = append(slot.Candidates, name)
+			}
+		}
+	default:
+		return SpxInputSlot{}, false
+	}
+	return slot, true
+}
+
+// acceptedArgTypes returns the types accepted by the argument at the given
+// index of the given call expression. All overloads of the called function are
+// taken into account.
+func (r *compileResult) acceptedArgTypes(callExpr *gopast.CallExpr, argIndex int) []types.Type {
+	var sigs []*types.Signature
+	if funcIdent := funcIdentOf(callExpr.Fun); funcIdent != nil {
+		if fun, ok := r.typeInfo.ObjectOf(funcIdent).(*types.Func); ok {
+			for _, funcOverload := range r.funcOverloadsFor(funcIdent, fun) {
+				sigs = append(sigs, funcOverload.Type().(*types.Signature))
+			}
+		}
+	}
+	if len(sigs) == 0 {
+		if sig, ok := r.typeInfo.TypeOf(callExpr.Fun).(*types.Signature); ok {
+			sigs = append(sigs, sig)
+		}
+	}
+
+	var acceptedTypes []types.Type
+	for _, sig := range sigs {
+		typ := paramTypeAt(sig, argIndex)
+		if typ == nil {
+			continue
+		}
+		if !slices.ContainsFunc(acceptedTypes, func(t types.Type) bool { return types.Identical(t, typ) }) {
+			acceptedTypes = append(acceptedTypes, typ)
+		}
+	}
+	return acceptedTypes
+}
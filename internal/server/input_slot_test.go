@@ -0,0 +1,94 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSpxGetInputSlots(t *testing.T) {
+	newServer := func() *Server {
+		m := newTestFileMap()
+		m["MyAircraft.spx"] = []byte(`
+onStart => {
+	turn Right
+	step -10
+	setCostume "hero"
+	play "biu"
+	say "Hello", 2
+	echo "Hi"
+}
+`)
+		return New(newMapFSWithoutModTime(m), nil)
+	}
+	params := func(start, end Position) []SpxGetInputSlotsParams {
+		return []SpxGetInputSlotsParams{{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MyAircraft.spx"},
+			Range:        Range{Start: start, End: end},
+		}}
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer()
+		slots, err := s.spxGetInputSlots(params(Position{Line: 0}, Position{Line: 9}))
+		require.NoError(t, err)
+		require.Len(t, slots, 7)
+
+		assert.Equal(t, SpxInputSlot{
+			Kind: SpxInputSlotKindConstant,
+			Range: Range{
+				Start: Position{Line: 2, Character: 6},
+				End:   Position{Line: 2, Character: 11},
+			},
+			Value:         "Right",
+			AcceptedTypes: []string{"float64", "specialDir", "*TurningInfo"},
+			Candidates:    []string{"Down", "Left", "Right", "Up"},
+		}, slots[0])
+		assert.Equal(t, SpxInputSlot{
+			Kind: SpxInputSlotKindLiteral,
+			Range: Range{
+				Start: Position{Line: 3, Character: 6},
+				End:   Position{Line: 3, Character: 9},
+			},
+			Value:         "-10",
+			AcceptedTypes: []string{"float64", "int"},
+		}, slots[1])
+		assert.Equal(t, SpxInputSlotKindResourceName, slots[2].Kind)
+		assert.Equal(t, `"hero"`, slots[2].Value)
+		assert.Contains(t, slots[2].AcceptedTypes, "SpriteCostumeName")
+		assert.Equal(t, []string{`"hero"`}, slots[2].Candidates)
+		assert.Equal(t, SpxInputSlot{
+			Kind: SpxInputSlotKindResourceName,
+			Range: Range{
+				Start: Position{Line: 5, Character: 6},
+				End:   Position{Line: 5, Character: 11},
+			},
+			Value:         `"biu"`,
+			AcceptedTypes: []string{"Sound", "SoundName"},
+			Candidates:    []string{`"biu"`},
+		}, slots[3])
+		assert.Equal(t, SpxInputSlotKindLiteral, slots[4].Kind)
+		assert.Equal(t, `"Hello"`, slots[4].Value)
+		assert.Empty(t, slots[4].Candidates)
+		assert.Equal(t, SpxInputSlotKindLiteral, slots[5].Kind)
+		assert.Equal(t, "2", slots[5].Value)
+		assert.Equal(t, SpxInputSlotKindLiteral, slots[6].Kind)
+		assert.Equal(t, `"Hi"`, slots[6].Value)
+	})
+
+	t.Run("PartialRange", func(t *testing.T) {
+		s := newServer()
+		slots, err := s.spxGetInputSlots(params(Position{Line: 5, Character: 1}, Position{Line: 5, Character: 11}))
+		require.NoError(t, err)
+		require.Len(t, slots, 1)
+		assert.Equal(t, `"biu"`, slots[0].Value)
+	})
+
+	t.Run("MultipleDocuments", func(t *testing.T) {
+		s := newServer()
+		slots, err := s.spxGetInputSlots(append(params(Position{}, Position{}), params(Position{}, Position{})...))
+		require.EqualError(t, err, "spx.getInputSlots only supports one document at a time")
+		assert.Nil(t, slots)
+	})
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	gopast "github.com/goplus/gop/ast"
+)
+
+// lineIndex indexes the lines of a file content, so that protocol positions in
+// a position encoding and UTF-8 byte offsets are converted in O(log n) time
+// instead of rescanning the content. Lines of only ASCII characters, which are
+// the majority of spx source code, are converted without scanning them at all.
+type lineIndex struct {
+	content  string
+	encoding PositionEncodingKind
+
+	// lineStarts are the byte offsets of the starts of lines.
+	lineStarts []int
+
+	// asciiLines reports whether each line has only ASCII characters.
+	asciiLines []bool
+}
+
+// newLineIndex creates a new [lineIndex] for the given content, whose positions
+// are in the given position encoding.
+func newLineIndex(content string, encoding PositionEncodingKind) *lineIndex {
+	lineCount := strings.Count(content, "\n") + 1
+	x := &lineIndex{
+		content:    content,
+		encoding:   encoding,
+		lineStarts: make([]int, 1, lineCount),
+		asciiLines: make([]bool, 0, lineCount),
+	}
+	ascii := true
+	for i := range len(content) {
+		switch b := content[i]; {
+		case b == '\n':
+			x.lineStarts = append(x.lineStarts, i+1)
+			x.asciiLines = append(x.asciiLines, ascii)
+			ascii = true
+		case b >= utf8.RuneSelf:
+			ascii = false
+		}
+	}
+	x.asciiLines = append(x.asciiLines, ascii)
+	return x
+}
+
+// line returns the content of the given 0-based line without its newline. It
+// returns an empty string if the line does not exist.
+func (x *lineIndex) line(line int) string {
+	if line < 0 || line >= len(x.lineStarts) {
+		return ""
+	}
+	end := len(x.content)
+	if line+1 < len(x.lineStarts) {
+		end = x.lineStarts[line+1] - 1
+	}
+	return x.content[x.lineStarts[line]:end]
+}
+
+// byteColumn returns the UTF-8 byte offset in the given 0-based line of the
+// given character offset in the position encoding. See [encodingOffsetToUTF8].
+func (x *lineIndex) byteColumn(line, character int) int {
+	content := x.line(line)
+	if line < len(x.asciiLines) && x.asciiLines[line] {
+		return min(max(character, 0), len(content))
+	}
+	return encodingOffsetToUTF8(content, character, x.encoding)
+}
+
+// character returns the character offset in the position encoding of the
+// given UTF-8 byte offset in the given 0-based line. Offsets past the end of
+// the line, e.g., of errors at the end of the file, count its newline and the
+// following content. See [utf8OffsetToEncoding].
+func (x *lineIndex) character(line, byteColumn int) int {
+	if line < 0 || line >= len(x.lineStarts) {
+		return 0
+	}
+	rest := x.content[x.lineStarts[line]:]
+	if x.encoding == UTF8 || (x.asciiLines[line] && byteColumn <= len(x.line(line))) {
+		return min(max(byteColumn, 0), len(rest))
+	}
+	return utf8OffsetToEncoding(rest, byteColumn, x.encoding)
+}
+
+// offset returns the UTF-8 byte offset of the given position. The position is
+// clamped to the content bounds. See [positionOffset].
+func (x *lineIndex) offset(position Position) int {
+	line := int(position.Line)
+	if line >= len(x.lineStarts) {
+		return len(x.content)
+	}
+	return x.lineStarts[line] + x.byteColumn(line, int(position.Character))
+}
+
+// position returns the position of the given UTF-8 byte offset. The offset is
+// clamped to the content bounds. See [offsetPosition].
+func (x *lineIndex) position(offset int) Position {
+	offset = min(max(offset, 0), len(x.content))
+	line, found := slices.BinarySearch(x.lineStarts, offset)
+	if !found {
+		line--
+	}
+	return Position{
+		Line:      uint32(line),
+		Character: uint32(x.character(line, offset-x.lineStarts[line])),
+	}
+}
+
+// lineIndexOf returns the line index of the given AST file in the position
+// encoding of the compile result. It is built once and then shared by all
+// features converting positions in the file, e.g., command handlers.
+func (r *compileResult) lineIndexOf(astFile *gopast.File) *lineIndex {
+	if indexIface, ok := r.computedCache.lineIndexes.Load(astFile); ok {
+		return indexIface.(*lineIndex)
+	}
+	indexIface, _ := r.computedCache.lineIndexes.LoadOrStore(astFile, newLineIndex(string(astFile.Code), r.positionEncoding))
+	return indexIface.(*lineIndex)
+}
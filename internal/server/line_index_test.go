@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineIndex(t *testing.T) {
+	const content = "x := 1\n" +
+		"echo \"你好😀\", x\r\n" +
+		"\n" +
+		"y"
+
+	t.Run("Line", func(t *testing.T) {
+		x := newLineIndex(content, UTF16)
+		assert.Equal(t, "x := 1", x.line(0))
+		assert.Equal(t, "echo \"你好😀\", x\r", x.line(1))
+		assert.Equal(t, "", x.line(2))
+		assert.Equal(t, "y", x.line(3))
+		assert.Equal(t, "", x.line(4))
+		assert.Equal(t, "", x.line(-1))
+	})
+
+	t.Run("MatchesOneOffConversions", func(t *testing.T) {
+		for _, encoding := range []PositionEncodingKind{UTF16, UTF8} {
+			x := newLineIndex(content, encoding)
+			for offset := -1; offset <= len(content)+1; offset++ {
+				assert.Equal(t, offsetPosition(content, offset, encoding), x.position(offset), "%s offset %d", encoding, offset)
+			}
+			for line := range uint32(6) {
+				for character := range uint32(25) {
+					position := Position{Line: line, Character: character}
+					assert.Equal(t, positionOffset(content, position, encoding), x.offset(position), "%s position %v", encoding, position)
+				}
+			}
+		}
+	})
+
+	t.Run("PastEndOfLine", func(t *testing.T) {
+		x := newLineIndex(content, UTF16)
+		assert.Equal(t, 6, x.byteColumn(0, 100))
+		assert.Equal(t, 7, x.character(0, 7), "counting the newline")
+		assert.Equal(t, 1, x.character(3, 100))
+		assert.Equal(t, 0, x.character(4, 1))
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		x := newLineIndex("", UTF16)
+		assert.Equal(t, Position{}, x.position(1))
+		assert.Equal(t, 0, x.offset(Position{Line: 1, Character: 1}))
+	})
+}
+
+func TestCompileResultLineIndexOf(t *testing.T) {
+	s := New(newMapFSWithoutModTime(map[string][]byte{
+		"main.spx":          []byte("echo \"你好😀\", 1\nrun \"assets\", {Title: \"My Game\"}\n"),
+		"assets/index.json": []byte(`{}`),
+	}), nil)
+	result, err := s.compile()
+	require.NoError(t, err)
+	astFile := result.mainASTPkg.Files["main.spx"]
+	require.NotNil(t, astFile)
+
+	x := result.lineIndexOf(astFile)
+	assert.Same(t, x, result.lineIndexOf(astFile))
+	assert.Equal(t, UTF16, x.encoding)
+
+	position := result.toPosition(astFile, Position{Line: 0, Character: 13})
+	assert.Equal(t, 19, position.Offset)
+	assert.Equal(t, Position{Line: 0, Character: 13}, result.fromPosition(astFile, position))
+}
+
+// BenchmarkLineIndex benchmarks converting positions near the end of a file
+// of 1000 lines with and without a line index.
+func BenchmarkLineIndex(b *testing.B) {
+	var sb strings.Builder
+	for i := range 1000 {
+		fmt.Fprintf(&sb, "\tsay \"第%d行\", %d\n", i, i)
+	}
+	content := sb.String()
+	position := Position{Line: 990, Character: 10}
+
+	b.Run("Rescan", func(b *testing.B) {
+		for range b.N {
+			positionOffset(content, position, UTF16)
+		}
+	})
+	b.Run("Indexed", func(b *testing.B) {
+		x := newLineIndex(content, UTF16)
+		b.ResetTimer()
+		for range b.N {
+			x.offset(position)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"cmp"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+)
+
+// identWordPattern is the word pattern of valid identifiers used by linked
+// editing ranges.
+const identWordPattern = `[A-Za-z_][A-Za-z0-9_]*`
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_linkedEditingRange
+func (s *Server) textDocumentLinkedEditingRange(params *LinkedEditingRangeParams) (*LinkedEditingRanges, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+	position := result.toPosition(astFile, params.Position)
+
+	ident := result.identAtASTFilePosition(astFile, position)
+	if ident == nil {
+		return nil, nil
+	}
+	obj := result.typeInfo.ObjectOf(ident)
+	if _, ok := result.spxSpriteResourceAutoBindings[obj]; !ok {
+		return nil, nil
+	}
+	defIdent := result.defIdentFor(obj)
+	if defIdent == nil || result.nodeASTFile(defIdent) != astFile {
+		return nil, nil
+	}
+
+	idents := []*gopast.Ident{defIdent}
+	for _, refIdent := range result.refIdentsFor(obj) {
+		if result.nodeASTFile(refIdent) == astFile && refIdent.Name == defIdent.Name {
+			idents = append(idents, refIdent)
+		}
+	}
+	slices.SortFunc(idents, func(a, b *gopast.Ident) int {
+		return cmp.Compare(a.Pos(), b.Pos())
+	})
+
+	ranges := make([]Range, 0, len(idents))
+	for _, ident := range idents {
+		ranges = append(ranges, result.rangeForNode(ident))
+	}
+	return &LinkedEditingRanges{
+		Ranges:      ranges,
+		WordPattern: identWordPattern,
+	}, nil
+}
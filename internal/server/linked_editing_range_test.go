@@ -0,0 +1,104 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentLinkedEditingRange(t *testing.T) {
+	newServer := func() *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+
+MySprite.turn Right
+MySprite.step 10
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	MySprite.turn Left
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer()
+		params := &LinkedEditingRangeParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 2, Character: 1},
+			},
+		}
+
+		linkedEditingRanges, err := s.textDocumentLinkedEditingRange(params)
+		require.NoError(t, err)
+		require.NotNil(t, linkedEditingRanges)
+		assert.Equal(t, []Range{
+			{Start: Position{Line: 2, Character: 1}, End: Position{Line: 2, Character: 9}},
+			{Start: Position{Line: 5, Character: 0}, End: Position{Line: 5, Character: 8}},
+			{Start: Position{Line: 6, Character: 0}, End: Position{Line: 6, Character: 8}},
+		}, linkedEditingRanges.Ranges)
+		assert.Equal(t, identWordPattern, linkedEditingRanges.WordPattern)
+	})
+
+	t.Run("AtReference", func(t *testing.T) {
+		s := newServer()
+		params := &LinkedEditingRangeParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 6, Character: 3},
+			},
+		}
+
+		linkedEditingRanges, err := s.textDocumentLinkedEditingRange(params)
+		require.NoError(t, err)
+		require.NotNil(t, linkedEditingRanges)
+		assert.Len(t, linkedEditingRanges.Ranges, 3)
+	})
+
+	t.Run("InOtherFile", func(t *testing.T) {
+		s := newServer()
+		params := &LinkedEditingRangeParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 2, Character: 2},
+			},
+		}
+
+		linkedEditingRanges, err := s.textDocumentLinkedEditingRange(params)
+		require.NoError(t, err)
+		assert.Nil(t, linkedEditingRanges)
+	})
+
+	t.Run("NotSpriteAutoBinding", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	score int
+)
+
+score = 1
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+		params := &LinkedEditingRangeParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 2, Character: 1},
+			},
+		}
+
+		linkedEditingRanges, err := s.textDocumentLinkedEditingRange(params)
+		require.NoError(t, err)
+		assert.Nil(t, linkedEditingRanges)
+	})
+}
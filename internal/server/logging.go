@@ -0,0 +1,198 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+)
+
+// logLevelOff disables forwarding logs to the client in [Config.LogLevel].
+const logLevelOff = "off"
+
+// logLevels maps log level names to log levels.
+var logLevels = map[string]slog.Level{
+	"error":   slog.LevelError,
+	"warning": slog.LevelWarn,
+	"info":    slog.LevelInfo,
+	"debug":   slog.LevelDebug,
+}
+
+// WithLogger sets the logger that receives the logs of the server. Logs are
+// discarded by default, unless they are forwarded to the client.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = slog.New(&logHandler{s: s, next: logger.Handler()})
+	}
+}
+
+// logHandler is a [slog.Handler] that passes records to the logger of the
+// embedder, and forwards them to the client via window/logMessage according to
+// [Config.LogLevel] and via $/logTrace according to the trace value set by
+// the client.
+type logHandler struct {
+	s *Server
+
+	// next is the handler of the logger of the embedder, or nil if logs are
+	// not passed to the embedder.
+	next slog.Handler
+
+	// attrs are the attributes added by [logHandler.WithAttrs], with their
+	// keys qualified by groups.
+	attrs []slog.Attr
+
+	// groupPrefix is the prefix qualifying keys of attributes added later,
+	// e.g., "g1.g2.".
+	groupPrefix string
+}
+
+// Enabled implements [slog.Handler].
+func (h *logHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.next != nil && h.next.Enabled(ctx, level) {
+		return true
+	}
+	if minLevel, ok := h.s.clientLogLevel(); ok && level >= minLevel {
+		return true
+	}
+	return h.s.trace() != Off
+}
+
+// Handle implements [slog.Handler].
+func (h *logHandler) Handle(ctx context.Context, r slog.Record) error {
+	var errs []error
+	if h.next != nil && h.next.Enabled(ctx, r.Level) {
+		errs = append(errs, h.next.Handle(ctx, r))
+	}
+
+	// Errors of forwarding logs are not logged, which would forward them
+	// again.
+	if minLevel, ok := h.s.clientLogLevel(); ok && r.Level >= minLevel {
+		message := r.Message
+		if attrs := h.formatAttrs(r); attrs != "" {
+			message += " " + attrs
+		}
+		errs = append(errs, h.s.notify("window/logMessage", &LogMessageParams{
+			Type:    messageTypeForLevel(r.Level),
+			Message: message,
+		}))
+	}
+	if trace := h.s.trace(); trace != Off {
+		params := &LogTraceParams{Message: r.Message}
+		if trace == Verbose {
+			params.Verbose = h.formatAttrs(r)
+		}
+		errs = append(errs, h.s.notify("$/logTrace", params))
+	}
+	return errors.Join(errs...)
+}
+
+// WithAttrs implements [slog.Handler].
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	if h.next != nil {
+		h2.next = h.next.WithAttrs(attrs)
+	}
+	h2.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	h2.attrs = append(h2.attrs, h.attrs...)
+	for _, attr := range attrs {
+		attr.Key = h.groupPrefix + attr.Key
+		h2.attrs = append(h2.attrs, attr)
+	}
+	return &h2
+}
+
+// WithGroup implements [slog.Handler].
+func (h *logHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	if h.next != nil {
+		h2.next = h.next.WithGroup(name)
+	}
+	h2.groupPrefix += name + "."
+	return &h2
+}
+
+// formatAttrs formats the attributes of the handler and the given record as
+// space-separated key=value pairs.
+func (h *logHandler) formatAttrs(r slog.Record) string {
+	var sb strings.Builder
+	writeAttr := func(attr slog.Attr) {
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		fmt.Fprintf(&sb, "%s=%v", attr.Key, attr.Value.Resolve())
+	}
+	for _, attr := range h.attrs {
+		writeAttr(attr)
+	}
+	r.Attrs(func(attr slog.Attr) bool {
+		attr.Key = h.groupPrefix + attr.Key
+		writeAttr(attr)
+		return true
+	})
+	return sb.String()
+}
+
+// messageTypeForLevel returns the [MessageType] of window/logMessage for the
+// given log level.
+func messageTypeForLevel(level slog.Level) MessageType {
+	switch {
+	case level >= slog.LevelError:
+		return Error
+	case level >= slog.LevelWarn:
+		return Warning
+	case level >= slog.LevelInfo:
+		return Info
+	default:
+		return Debug
+	}
+}
+
+// clientLogLevel returns the minimum level of logs forwarded to the client via
+// window/logMessage, and whether logs are forwarded at all.
+func (s *Server) clientLogLevel() (slog.Level, bool) {
+	level, ok := logLevels[s.config().LogLevel]
+	return level, ok
+}
+
+// trace returns the trace value set by the client.
+func (s *Server) trace() TraceValue {
+	if trace := s.currentTrace.Load(); trace != nil {
+		return *trace
+	}
+	return Off
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#setTrace
+func (s *Server) setTrace(params *SetTraceParams) error {
+	switch params.Value {
+	case Off, Messages, Verbose:
+	default:
+		return fmt.Errorf("invalid trace value %q", params.Value)
+	}
+	s.currentTrace.Store(&params.Value)
+	return nil
+}
+
+// loggerContextKey is the context key of the logger of a request.
+type loggerContextKey struct{}
+
+// withRequestLogger returns a context carrying a logger whose records are
+// attributed to the request with the given ID.
+func (s *Server) withRequestLogger(ctx context.Context, id jsonrpc2.ID) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, s.logger.With("requestID", fmt.Sprint(id)))
+}
+
+// loggerFor returns the logger of the request carried by ctx, or the logger of
+// the server if there is none.
+func (s *Server) loggerFor(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerContextKey{}).(*slog.Logger); ok {
+		return logger
+	}
+	return s.logger
+}
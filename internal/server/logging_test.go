@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerLogging(t *testing.T) {
+	// newServer creates a server that records notifications sent to the
+	// client.
+	newServer := func(opts ...Option) (*Server, func() []*jsonrpc2.Notification) {
+		var (
+			notifications   []*jsonrpc2.Notification
+			notificationsMu sync.Mutex
+		)
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), messageReplierFunc(func(m jsonrpc2.Message) error {
+			if n, ok := m.(*jsonrpc2.Notification); ok {
+				notificationsMu.Lock()
+				notifications = append(notifications, n)
+				notificationsMu.Unlock()
+			}
+			return nil
+		}), opts...)
+		return s, func() []*jsonrpc2.Notification {
+			notificationsMu.Lock()
+			defer notificationsMu.Unlock()
+			return notifications
+		}
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s, notifications := newServer()
+		s.logger.Error("failed")
+		assert.Empty(t, notifications())
+	})
+
+	t.Run("WithLogger", func(t *testing.T) {
+		var buf bytes.Buffer
+		s, notifications := newServer(WithLogger(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{
+			ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey && len(groups) == 0 {
+					return slog.Attr{}
+				}
+				return a
+			},
+		}))))
+		s.logger.With("a", 1).WithGroup("g").Info("hello", "b", 2)
+		s.logger.Debug("ignored")
+		assert.Equal(t, "level=INFO msg=hello a=1 g.b=2\n", buf.String())
+		assert.Empty(t, notifications())
+	})
+
+	t.Run("LogMessage", func(t *testing.T) {
+		s, notifications := newServer()
+		s.setConfig(&Config{LogLevel: "warning"})
+		s.logger.With("a", 1).WithGroup("g").Error("failed", "b", 2)
+		s.logger.Info("ignored")
+
+		require.Len(t, notifications(), 1)
+		n := notifications()[0]
+		assert.Equal(t, "window/logMessage", n.Method())
+		var params LogMessageParams
+		require.NoError(t, json.Unmarshal(n.Params(), &params))
+		assert.Equal(t, Error, params.Type)
+		assert.Equal(t, "failed a=1 g.b=2", params.Message)
+	})
+
+	t.Run("LogTrace", func(t *testing.T) {
+		s, notifications := newServer()
+		s.logger.Info("ignored")
+		require.NoError(t, s.setTrace(&SetTraceParams{Value: Messages}))
+		s.logger.Debug("messages", "a", 1)
+		require.NoError(t, s.setTrace(&SetTraceParams{Value: Verbose}))
+		s.logger.Debug("verbose", "a", 1)
+
+		require.Len(t, notifications(), 2)
+		var params []LogTraceParams
+		for _, n := range notifications() {
+			assert.Equal(t, "$/logTrace", n.Method())
+			var p LogTraceParams
+			require.NoError(t, json.Unmarshal(n.Params(), &p))
+			params = append(params, p)
+		}
+		assert.Equal(t, []LogTraceParams{
+			{Message: "messages"},
+			{Message: "verbose", Verbose: "a=1"},
+		}, params)
+	})
+
+	t.Run("RequestID", func(t *testing.T) {
+		s, notifications := newServer()
+		require.NoError(t, s.setTrace(&SetTraceParams{Value: Verbose}))
+
+		c, err := jsonrpc2.NewCall(jsonrpc2.NewIntID(42), "test", nil)
+		require.NoError(t, err)
+		done := make(chan struct{})
+		s.runWithContextResponse(c, func(ctx context.Context) (any, error) {
+			defer close(done)
+			s.loggerFor(ctx).Debug("working")
+			return nil, nil
+		})
+		<-done
+
+		var traces []LogTraceParams
+		for _, n := range notifications() {
+			var params LogTraceParams
+			require.NoError(t, json.Unmarshal(n.Params(), &params))
+			traces = append(traces, params)
+		}
+		assert.Contains(t, traces, LogTraceParams{Message: "working", Verbose: "requestID=42"})
+	})
+
+	t.Run("InvalidTrace", func(t *testing.T) {
+		s, _ := newServer()
+		err := s.setTrace(&SetTraceParams{Value: "all"})
+		require.EqualError(t, err, `invalid trace value "all"`)
+		assert.Equal(t, Off, s.trace())
+	})
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"fmt"
+	"go/types"
+	"maps"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// loopVariableCaptureAnalyzer is an [analyzer] for loop variables captured by
+// spx event handlers and goroutines started in loops.
+var loopVariableCaptureAnalyzer = &analyzer{
+	name:     "loopVariableCapture",
+	requires: requireAST | requireTypeInfo,
+	run:      inspectForLoopVariableCaptures,
+}
+
+// inspectForLoopVariableCaptures inspects for loop variables referenced by spx
+// event handlers registered in the loop body, e.g.,
+// `for i in 1:3 { onClick => { echo i } }`, or by function literals started
+// as goroutines in it. They run after the iteration has ended, which is
+// rarely what the author expects.
+func inspectForLoopVariableCaptures(pass *analysisPass) {
+	result := pass.result
+	for _, spxFile := range slices.Sorted(maps.Keys(result.mainASTPkg.Files)) {
+		astFile := result.mainASTPkg.Files[spxFile]
+		gopast.Inspect(astFile, func(node gopast.Node) bool {
+			var (
+				idents []*gopast.Ident
+				body   *gopast.BlockStmt
+			)
+			switch node := node.(type) {
+			case *gopast.ForStmt:
+				if init, ok := node.Init.(*gopast.AssignStmt); ok && init.Tok == goptoken.DEFINE {
+					for _, lhs := range init.Lhs {
+						if ident, ok := lhs.(*gopast.Ident); ok {
+							idents = append(idents, ident)
+						}
+					}
+				}
+				body = node.Body
+			case *gopast.RangeStmt:
+				if node.Tok == goptoken.DEFINE {
+					for _, expr := range []gopast.Expr{node.Key, node.Value} {
+						if ident, ok := expr.(*gopast.Ident); ok {
+							idents = append(idents, ident)
+						}
+					}
+				}
+				body = node.Body
+			case *gopast.ForPhraseStmt:
+				for _, ident := range []*gopast.Ident{node.Key, node.Value} {
+					if ident != nil {
+						idents = append(idents, ident)
+					}
+				}
+				body = node.Body
+			}
+
+			loopVars := make(map[types.Object]*gopast.Ident)
+			for _, ident := range idents {
+				if v, ok := result.typeInfo.Defs[ident].(*types.Var); ok && ident.Name != "_" {
+					loopVars[v] = ident
+				}
+			}
+			if len(loopVars) > 0 && body != nil {
+				result.inspectForLoopVariableCapturesIn(pass, spxFile, body, loopVars)
+			}
+			return true
+		})
+	}
+}
+
+// inspectForLoopVariableCapturesIn inspects for the given loop variables
+// captured by spx event handlers and goroutines in the given loop body.
+func (r *compileResult) inspectForLoopVariableCapturesIn(pass *analysisPass, spxFile string, body *gopast.BlockStmt, loopVars map[types.Object]*gopast.Ident) {
+	report := func(funcLit gopast.Node, capturer string) {
+		reported := make(map[types.Object]struct{})
+		gopast.Inspect(funcLit, func(node gopast.Node) bool {
+			ident, ok := node.(*gopast.Ident)
+			if !ok {
+				return true
+			}
+			obj := r.typeInfo.Uses[ident]
+			loopVarIdent, ok := loopVars[obj]
+			if !ok {
+				return true
+			}
+			if _, ok := reported[obj]; ok {
+				return true
+			}
+			reported[obj] = struct{}{}
+
+			pass.report(spxFile, Diagnostic{
+				Severity: SeverityWarning,
+				Code:     DiagnosticCodeLoopVariableCapture,
+				Range:    r.rangeForNode(ident),
+				Message:  fmt.Sprintf("loop variable %q is captured by %s in the loop, which runs after the iteration has ended", ident.Name, capturer),
+				RelatedInformation: []DiagnosticRelatedInformation{{
+					Location: r.locationForNode(loopVarIdent),
+					Message:  fmt.Sprintf("loop variable %q", ident.Name),
+				}},
+			})
+			return true
+		})
+	}
+
+	gopast.Inspect(body, func(node gopast.Node) bool {
+		switch node := node.(type) {
+		case *gopast.GoStmt:
+			if funcLit, ok := node.Call.Fun.(*gopast.FuncLit); ok {
+				report(funcLit, "a goroutine started")
+				return false
+			}
+		case *gopast.CallExpr:
+			funcIdent := funcIdentOf(node.Fun)
+			if funcIdent == nil {
+				return true
+			}
+			funcObj := r.typeInfo.ObjectOf(funcIdent)
+			if !isSpxPkgObject(funcObj) {
+				return true
+			}
+			funcName, _ := parseGopFuncName(funcObj.Name())
+			if !isSpxEventHandlerFuncName(funcName) {
+				return true
+			}
+			hasFuncLit := false
+			for _, arg := range node.Args {
+				switch arg.(type) {
+				case *gopast.FuncLit, *gopast.LambdaExpr, *gopast.LambdaExpr2:
+					report(arg, "an event handler registered")
+					hasFuncLit = true
+				}
+			}
+			return !hasFuncLit
+		}
+		return true
+	})
+}
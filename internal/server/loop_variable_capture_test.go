@@ -0,0 +1,116 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerInspectForLoopVariableCaptures(t *testing.T) {
+	diagnosticsFor := func(t *testing.T, s *Server) []Diagnostic {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		return fullReport.Items
+	}
+	newServer := func(mainSpx string) *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(mainSpx),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+	}
+
+	t.Run("EventHandler", func(t *testing.T) {
+		s := newServer(`
+onStart => {
+	for i <- [1, 2, 3] {
+		onClick => {
+			echo i
+			echo i
+		}
+	}
+}
+run "assets", {Title: "My Game"}
+`)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, Diagnostic{
+			Severity: SeverityWarning,
+			Code:     DiagnosticCodeLoopVariableCapture,
+			Range: Range{
+				Start: Position{Line: 4, Character: 8},
+				End:   Position{Line: 4, Character: 9},
+			},
+			Message: `loop variable "i" is captured by an event handler registered in the loop, which runs after the iteration has ended`,
+			RelatedInformation: []DiagnosticRelatedInformation{{
+				Location: Location{
+					URI: "file:///main.spx",
+					Range: Range{
+						Start: Position{Line: 2, Character: 5},
+						End:   Position{Line: 2, Character: 6},
+					},
+				},
+				Message: `loop variable "i"`,
+			}},
+		}, diags[0])
+	})
+
+	t.Run("Goroutine", func(t *testing.T) {
+		s := newServer(`
+onStart => {
+	for i := 0; i < 3; i++ {
+		go func() {
+			echo i
+		}()
+	}
+}
+run "assets", {Title: "My Game"}
+`)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, DiagnosticCodeLoopVariableCapture, diags[0].Code)
+		assert.Equal(t, `loop variable "i" is captured by a goroutine started in the loop, which runs after the iteration has ended`, diags[0].Message)
+	})
+
+	t.Run("NotCaptured", func(t *testing.T) {
+		s := newServer(`
+onStart => {
+	for i <- [1, 2, 3] {
+		echo i
+		onClick => {
+			echo "clicked"
+		}
+		f := func() {
+			echo i
+		}
+		f()
+	}
+}
+run "assets", {Title: "My Game"}
+`)
+		assert.Empty(t, diagnosticsFor(t, s))
+	})
+
+	t.Run("DisabledAnalyzer", func(t *testing.T) {
+		s := newServer(`
+onStart => {
+	for i <- [1, 2, 3] {
+		onClick => {
+			echo i
+		}
+	}
+}
+run "assets", {Title: "My Game"}
+`)
+		err := s.workspaceDidChangeConfiguration(&DidChangeConfigurationParams{
+			Settings: map[string]any{"analyzers": map[string]any{"loopVariableCapture": false}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, diagnosticsFor(t, s))
+	})
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"go/types"
+	"io"
+	"maps"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+)
+
+// lsifVersion is the version of the LSIF format written by
+// [Analysis.WriteLSIF].
+const lsifVersion = "0.4.3"
+
+// WriteLSIF writes an LSIF dump of the analyzed project to w, with the
+// definitions, references and hovers of all identifiers in the classfiles, so
+// that the project can be navigated offline, e.g., by web viewers of shared
+// projects. The documents of the dump are the classfiles under the given
+// project root URI.
+//
+// See https://microsoft.github.io/language-server-protocol/specifications/lsif/0.4.0/specification/
+func (a *Analysis) WriteLSIF(w io.Writer, projectRootURI DocumentURI) error {
+	bw := bufio.NewWriter(w)
+	e := &lsifEmitter{enc: json.NewEncoder(bw)}
+	e.vertex("metaData", map[string]any{
+		"version":          lsifVersion,
+		"projectRoot":      projectRootURI,
+		"positionEncoding": "utf-16",
+		"toolInfo":         map[string]any{"name": "goxlsw"},
+	})
+	projectID := e.vertex("project", map[string]any{"kind": "gop"})
+
+	r := a.result
+	var (
+		documentIDs []int
+		rangeIDs    = make(map[*gopast.Ident]int)
+		documentOf  = make(map[int]int) // range ID -> document ID
+		objIdents   = make(map[types.Object][]*gopast.Ident)
+	)
+	for _, file := range slices.Sorted(maps.Keys(r.mainASTPkg.Files)) {
+		astFile := r.mainASTPkg.Files[file]
+		documentID := e.vertex("document", map[string]any{
+			"uri":        string(projectRootURI) + file,
+			"languageId": "spx",
+		})
+		documentIDs = append(documentIDs, documentID)
+
+		var fileRangeIDs []int
+		for _, ident := range lsifIdentsIn(r, astFile) {
+			rng := r.rangeForNode(ident)
+			rangeID := e.vertex("range", map[string]any{"start": rng.Start, "end": rng.End})
+			rangeIDs[ident] = rangeID
+			documentOf[rangeID] = documentID
+			fileRangeIDs = append(fileRangeIDs, rangeID)
+			obj := r.typeInfo.ObjectOf(ident)
+			objIdents[obj] = append(objIdents[obj], ident)
+		}
+		if len(fileRangeIDs) > 0 {
+			e.edge("contains", documentID, map[string]any{"inVs": fileRangeIDs})
+		}
+	}
+
+	// Emit the result sets of objects in the order of their first
+	// identifiers, so that the dump is deterministic.
+	objs := slices.Collect(maps.Keys(objIdents))
+	slices.SortFunc(objs, func(a, b types.Object) int {
+		return cmp.Compare(rangeIDs[objIdents[a][0]], rangeIDs[objIdents[b][0]])
+	})
+	for _, obj := range objs {
+		idents := objIdents[obj]
+		resultSetID := e.vertex("resultSet", nil)
+		for _, ident := range idents {
+			e.edge("next", rangeIDs[ident], map[string]any{"inV": resultSetID})
+		}
+
+		if hoverHTML, ok := r.hoverHTMLForIdent(idents[0]); ok {
+			hoverID := e.vertex("hoverResult", map[string]any{
+				"result": map[string]any{"contents": markupContentFor(hoverHTML, true)},
+			})
+			e.edge("textDocument/hover", resultSetID, map[string]any{"inV": hoverID})
+		}
+
+		var defRangeIDs, refRangeIDs []int
+		for _, ident := range idents {
+			if r.typeInfo.Defs[ident] == obj {
+				defRangeIDs = append(defRangeIDs, rangeIDs[ident])
+			} else {
+				refRangeIDs = append(refRangeIDs, rangeIDs[ident])
+			}
+		}
+		if len(defRangeIDs) == 0 {
+			continue // Defined outside the project, e.g., in spx.
+		}
+		definitionID := e.vertex("definitionResult", nil)
+		e.edge("textDocument/definition", resultSetID, map[string]any{"inV": definitionID})
+		e.items(definitionID, defRangeIDs, documentOf, "")
+
+		referenceID := e.vertex("referenceResult", nil)
+		e.edge("textDocument/references", resultSetID, map[string]any{"inV": referenceID})
+		e.items(referenceID, defRangeIDs, documentOf, "definitions")
+		e.items(referenceID, refRangeIDs, documentOf, "references")
+	}
+
+	if len(documentIDs) > 0 {
+		e.edge("contains", projectID, map[string]any{"inVs": documentIDs})
+	}
+	if e.err != nil {
+		return e.err
+	}
+	return bw.Flush()
+}
+
+// lsifIdentsIn returns the identifiers in the given AST file that are defined
+// or used in the main package, in the order of their positions.
+func lsifIdentsIn(r *compileResult, astFile *gopast.File) []*gopast.Ident {
+	var idents []*gopast.Ident
+	gopast.Inspect(astFile, func(node gopast.Node) bool {
+		ident, ok := node.(*gopast.Ident)
+		if !ok || ident.Name == "_" || !ident.Pos().IsValid() {
+			return true
+		}
+		if funcDecl, ok := r.mainASTPkgIdentToFuncDecl[ident]; ok && funcDecl.Shadow {
+			return true
+		}
+		if r.typeInfo.ObjectOf(ident) != nil {
+			idents = append(idents, ident)
+		}
+		return true
+	})
+	return idents
+}
+
+// lsifEmitter writes LSIF vertices and edges as JSON lines, assigning their
+// IDs in the order they are written.
+type lsifEmitter struct {
+	enc    *json.Encoder
+	lastID int
+	err    error
+}
+
+// vertex writes a vertex with the given label and properties, and returns its
+// ID.
+func (e *lsifEmitter) vertex(label string, props map[string]any) int {
+	return e.emit("vertex", label, props)
+}
+
+// edge writes an edge with the given label from the vertex with the given ID,
+// whose other properties, e.g., "inV" or "inVs", are given by props.
+func (e *lsifEmitter) edge(label string, outV int, props map[string]any) {
+	props["outV"] = outV
+	e.emit("edge", label, props)
+}
+
+// items writes the item edges from the result with the given ID to the ranges
+// with the given IDs, one per document. The property is omitted if it is
+// empty.
+func (e *lsifEmitter) items(resultID int, rangeIDs []int, documentOf map[int]int, property string) {
+	var documentIDs []int
+	byDocument := make(map[int][]int)
+	for _, rangeID := range rangeIDs {
+		documentID := documentOf[rangeID]
+		if _, ok := byDocument[documentID]; !ok {
+			documentIDs = append(documentIDs, documentID)
+		}
+		byDocument[documentID] = append(byDocument[documentID], rangeID)
+	}
+	for _, documentID := range documentIDs {
+		props := map[string]any{"inVs": byDocument[documentID], "document": documentID}
+		if property != "" {
+			props["property"] = property
+		}
+		e.edge("item", resultID, props)
+	}
+}
+
+// emit writes an element of the given type and label with the given
+// properties, and returns its ID. Once writing fails, later elements are
+// dropped and the error is kept in e.err.
+func (e *lsifEmitter) emit(typ, label string, props map[string]any) int {
+	e.lastID++
+	if e.err != nil {
+		return e.lastID
+	}
+	element := map[string]any{"id": e.lastID, "type": typ, "label": label}
+	for k, v := range props {
+		element[k] = v
+	}
+	e.err = e.enc.Encode(element)
+	return e.lastID
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysisWriteLSIF(t *testing.T) {
+	analysis, err := Analyze(context.Background(), newMapFSWithoutModTime(map[string][]byte{
+		"main.spx": []byte(`var (
+	count int
+)
+
+count = 1
+run "assets", {}
+`),
+		"MySprite.spx": []byte(`onStart => {
+	count++
+}
+`),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{}`),
+	}))
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, analysis.WriteLSIF(&buf, "file:///project/"))
+
+	// Parse the dump, checking that every element refers to earlier ones.
+	elements := make(map[int]map[string]any)
+	var edges []map[string]any
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var element map[string]any
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &element))
+		id := int(element["id"].(float64))
+		require.NotContains(t, elements, id)
+		elements[id] = element
+		if element["type"] == "edge" {
+			require.Contains(t, elements, int(element["outV"].(float64)))
+			edges = append(edges, element)
+		}
+	}
+	require.NoError(t, scanner.Err())
+	assert.Equal(t, "metaData", elements[1]["label"])
+	assert.Equal(t, "file:///project/", elements[1]["projectRoot"])
+
+	// outEdge returns the inV of the edge with the given label from the given
+	// vertex.
+	outEdge := func(outV int, label string) (int, bool) {
+		for _, edge := range edges {
+			if int(edge["outV"].(float64)) == outV && edge["label"] == label {
+				return int(edge["inV"].(float64)), true
+			}
+		}
+		return 0, false
+	}
+
+	var documents []string
+	for _, element := range elements {
+		if element["label"] == "document" {
+			documents = append(documents, element["uri"].(string))
+		}
+	}
+	assert.ElementsMatch(t, []string{"file:///project/MySprite.spx", "file:///project/main.spx"}, documents)
+
+	// Find the result set of the count variable via its definition range.
+	var countResultSetID int
+	for _, element := range elements {
+		if element["label"] != "range" {
+			continue
+		}
+		start := element["start"].(map[string]any)
+		if start["line"] == float64(1) && start["character"] == float64(1) {
+			id, ok := outEdge(int(element["id"].(float64)), "next")
+			require.True(t, ok)
+			countResultSetID = id
+		}
+	}
+	require.NotZero(t, countResultSetID)
+
+	hoverID, ok := outEdge(countResultSetID, "textDocument/hover")
+	require.True(t, ok)
+	contents := elements[hoverID]["result"].(map[string]any)["contents"].(map[string]any)
+	assert.Equal(t, "markdown", contents["kind"])
+	assert.Contains(t, contents["value"], "count")
+
+	definitionID, ok := outEdge(countResultSetID, "textDocument/definition")
+	require.True(t, ok)
+	referenceID, ok := outEdge(countResultSetID, "textDocument/references")
+	require.True(t, ok)
+
+	var definitionItems, referenceItems int
+	for _, edge := range edges {
+		if edge["label"] != "item" {
+			continue
+		}
+		switch int(edge["outV"].(float64)) {
+		case definitionID:
+			definitionItems += len(edge["inVs"].([]any))
+		case referenceID:
+			if edge["property"] == "references" {
+				referenceItems += len(edge["inVs"].([]any))
+			}
+		}
+	}
+	assert.Equal(t, 1, definitionItems)
+	assert.Equal(t, 2, referenceItems)
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"slices"
+	"time"
+
+	gopast "github.com/goplus/gop/ast"
+)
+
+// Names of the caches accounted against the memory budget.
+const (
+	memoryCacheCompileResults = "compileResults"
+	memoryCacheSemanticTokens = "semanticTokens"
+	memoryCachePackages       = "packages"
+)
+
+// Estimated sizes in bytes of the parts of a compile result. They are rough,
+// but good enough to weigh compile results against other cache entries.
+const (
+	astNodeBytes       = 64
+	typeInfoEntryBytes = 48
+)
+
+// WithMemoryBudget sets the budget in bytes of the estimated memory used by
+// the caches of the server, i.e., compile results of workspace folders with
+// their ASTs and type information, semantic tokens of documents and packages
+// loaded by [PackageLoader]. Once the budget is exceeded, the least recently
+// used cache entries are evicted until the caches fit in it again. The caches
+// are unlimited if the budget is not positive, which is the default.
+func WithMemoryBudget(bytes int64) Option {
+	return func(s *Server) {
+		s.memoryBudget = max(bytes, 0)
+	}
+}
+
+// memoryCacheEntry is an entry of a cache accounted against the memory budget.
+type memoryCacheEntry struct {
+	cache    string
+	bytes    int64
+	lastUsed time.Time
+
+	// evict evicts the entry and reports whether it did so, which it does not
+	// if the entry has been in use or replaced since it was collected. It is
+	// nil if the entry is in use and cannot be evicted.
+	evict func() bool
+}
+
+// memoryCacheEntries returns the entries of all caches accounted against the
+// memory budget. The compile cache of the given workspace folder, whose lock
+// must be held by the caller, is never evicted. If wait is false, compile
+// caches of other workspace folders that are in use are skipped instead of
+// waited for.
+func (s *Server) memoryCacheEntries(pinned *workspaceFolder, wait bool) []memoryCacheEntry {
+	var entries []memoryCacheEntry
+	for _, folder := range s.getWorkspaceFolders() {
+		if folder == pinned {
+			// The lock of the pinned folder is held by the caller, i.e.,
+			// [Server.compileWorkspaceFolder] storing a new compile cache
+			// of it, so its fields can be read without locking.
+			if cache := folder.lastCompileCache; cache != nil {
+				entries = append(entries, memoryCacheEntry{
+					cache:    memoryCacheCompileResults,
+					bytes:    cache.estimatedBytes,
+					lastUsed: cache.lastUsed,
+				})
+			}
+			continue
+		}
+		if wait {
+			folder.lastCompileCacheMu.Lock()
+		} else if !folder.lastCompileCacheMu.TryLock() {
+			continue
+		}
+		cache := folder.lastCompileCache
+		if cache == nil {
+			folder.lastCompileCacheMu.Unlock()
+			continue
+		}
+		// Copy the fields while holding the lock, as lastUsed is updated
+		// under it whenever the cache is hit.
+		bytes, lastUsed := cache.estimatedBytes, cache.lastUsed
+		folder.lastCompileCacheMu.Unlock()
+		entries = append(entries, memoryCacheEntry{
+			cache:    memoryCacheCompileResults,
+			bytes:    bytes,
+			lastUsed: lastUsed,
+			evict: func() bool {
+				if !folder.lastCompileCacheMu.TryLock() {
+					return false
+				}
+				defer folder.lastCompileCacheMu.Unlock()
+				if folder.lastCompileCache != cache {
+					return false
+				}
+				folder.lastCompileCache = nil
+				return true
+			},
+		})
+	}
+
+	s.lastSemanticTokensMu.Lock()
+	for documentURI, result := range s.lastSemanticTokens {
+		entries = append(entries, memoryCacheEntry{
+			cache:    memoryCacheSemanticTokens,
+			bytes:    int64(4*len(result.data) + len(result.id)),
+			lastUsed: result.lastUsed,
+			evict: func() bool {
+				s.lastSemanticTokensMu.Lock()
+				defer s.lastSemanticTokensMu.Unlock()
+				if s.lastSemanticTokens[documentURI].id != result.id {
+					return false
+				}
+				delete(s.lastSemanticTokens, documentURI)
+				return true
+			},
+		})
+	}
+	s.lastSemanticTokensMu.Unlock()
+
+	if s.packageCache != nil {
+		entries = append(entries, s.packageCache.memoryCacheEntries()...)
+	}
+	return entries
+}
+
+// enforceMemoryBudget evicts the least recently used cache entries until the
+// caches fit in the memory budget. See [Server.memoryCacheEntries] for the
+// given workspace folder.
+func (s *Server) enforceMemoryBudget(pinned *workspaceFolder) {
+	if s.memoryBudget <= 0 {
+		return
+	}
+	entries := s.memoryCacheEntries(pinned, false)
+	var total int64
+	for _, entry := range entries {
+		total += entry.bytes
+	}
+	if total <= s.memoryBudget {
+		return
+	}
+
+	slices.SortStableFunc(entries, func(a, b memoryCacheEntry) int {
+		return a.lastUsed.Compare(b.lastUsed)
+	})
+	for _, entry := range entries {
+		if total <= s.memoryBudget {
+			break
+		}
+		if entry.evict == nil || !entry.evict() {
+			continue
+		}
+		total -= entry.bytes
+		s.memoryEvictions.Add(1)
+		s.logger.Debug("evicted cache entry to fit in memory budget", "cache", entry.cache, "bytes", entry.bytes)
+	}
+}
+
+// spxGetMemoryStats gets the estimated memory usage of the caches of the
+// server.
+func (s *Server) spxGetMemoryStats() (*SpxMemoryStats, error) {
+	stats := &SpxMemoryStats{
+		Budget:    s.memoryBudget,
+		Evictions: s.memoryEvictions.Load(),
+		Caches: []SpxCacheMemoryStats{
+			{Name: memoryCacheCompileResults},
+			{Name: memoryCachePackages},
+			{Name: memoryCacheSemanticTokens},
+		},
+	}
+	for _, entry := range s.memoryCacheEntries(nil, true) {
+		i := slices.IndexFunc(stats.Caches, func(c SpxCacheMemoryStats) bool {
+			return c.Name == entry.cache
+		})
+		stats.Caches[i].Entries++
+		stats.Caches[i].Bytes += entry.bytes
+		stats.Total += entry.bytes
+	}
+	return stats, nil
+}
+
+// estimatedBytes returns the estimated memory used by the compile result,
+// i.e., its source code, ASTs and type information.
+func (r *compileResult) estimatedBytes() int64 {
+	var bytes int64
+	for _, astFile := range r.mainASTPkg.Files {
+		bytes += int64(len(astFile.Code))
+		gopast.Inspect(astFile, func(node gopast.Node) bool {
+			if node != nil {
+				bytes += astNodeBytes
+			}
+			return true
+		})
+	}
+	info := r.typeInfo
+	entries := len(info.Types) + len(info.Defs) + len(info.Uses) + len(info.Implicits) + len(info.Selections) + len(info.Scopes)
+	bytes += int64(entries) * typeInfoEntryBytes
+	return bytes
+}
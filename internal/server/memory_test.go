@@ -0,0 +1,167 @@
+package server
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSpxGetMemoryStats(t *testing.T) {
+	newServer := func(opts ...Option) *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+MySprite.turn Left
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	MySprite.turn Right
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil, opts...)
+	}
+	cacheStats := func(t *testing.T, stats *SpxMemoryStats, name string) SpxCacheMemoryStats {
+		for _, cache := range stats.Caches {
+			if cache.Name == name {
+				return cache
+			}
+		}
+		t.Fatalf("missing stats of cache %s", name)
+		return SpxCacheMemoryStats{}
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer()
+
+		stats, err := s.spxGetMemoryStats()
+		require.NoError(t, err)
+		require.NotNil(t, stats)
+		assert.Zero(t, stats.Budget)
+		assert.Zero(t, stats.Total)
+		assert.Zero(t, stats.Evictions)
+		require.Len(t, stats.Caches, 3)
+		assert.Equal(t, "compileResults", stats.Caches[0].Name)
+		assert.Equal(t, "packages", stats.Caches[1].Name)
+		assert.Equal(t, "semanticTokens", stats.Caches[2].Name)
+
+		_, err = s.textDocumentSemanticTokensFull(&SemanticTokensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+
+		stats, err = s.spxGetMemoryStats()
+		require.NoError(t, err)
+		compileResults := cacheStats(t, stats, memoryCacheCompileResults)
+		assert.Equal(t, 1, compileResults.Entries)
+		assert.Positive(t, compileResults.Bytes)
+		semanticTokens := cacheStats(t, stats, memoryCacheSemanticTokens)
+		assert.Equal(t, 1, semanticTokens.Entries)
+		assert.Positive(t, semanticTokens.Bytes)
+		assert.Equal(t, compileResults.Bytes+semanticTokens.Bytes, stats.Total)
+		assert.Zero(t, stats.Evictions)
+	})
+
+	t.Run("WithinBudget", func(t *testing.T) {
+		s := newServer(WithMemoryBudget(1 << 30))
+
+		_, err := s.textDocumentSemanticTokensFull(&SemanticTokensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+
+		stats, err := s.spxGetMemoryStats()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1<<30), stats.Budget)
+		assert.Equal(t, 1, cacheStats(t, stats, memoryCacheCompileResults).Entries)
+		assert.Equal(t, 1, cacheStats(t, stats, memoryCacheSemanticTokens).Entries)
+		assert.Zero(t, stats.Evictions)
+	})
+
+	t.Run("BudgetExceeded", func(t *testing.T) {
+		s := newServer(WithMemoryBudget(1))
+
+		// The compile result in use is kept when it is stored.
+		result, err := s.compile()
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		stats, err := s.spxGetMemoryStats()
+		require.NoError(t, err)
+		assert.Equal(t, 1, cacheStats(t, stats, memoryCacheCompileResults).Entries)
+		assert.Zero(t, stats.Evictions)
+
+		// Storing semantic tokens evicts the least recently used entries.
+		_, err = s.textDocumentSemanticTokensFull(&SemanticTokensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		stats, err = s.spxGetMemoryStats()
+		require.NoError(t, err)
+		assert.Zero(t, stats.Total)
+		assert.Zero(t, cacheStats(t, stats, memoryCacheCompileResults).Entries)
+		assert.Zero(t, cacheStats(t, stats, memoryCacheSemanticTokens).Entries)
+		assert.Equal(t, int64(2), stats.Evictions)
+
+		// Evicted entries are recomputed on demand.
+		tokens, err := s.textDocumentSemanticTokensFull(&SemanticTokensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, tokens)
+		assert.NotEmpty(t, tokens.Data)
+	})
+
+	t.Run("EntryInUse", func(t *testing.T) {
+		s := newServer(WithMemoryBudget(1 << 30))
+		_, err := s.compile()
+		require.NoError(t, err)
+		entries := s.memoryCacheEntries(nil, true)
+		require.Len(t, entries, 1)
+		require.NotNil(t, entries[0].evict)
+
+		// Entries that come into use after being collected are not evicted.
+		folder := s.defaultWorkspaceFolder()
+		folder.lastCompileCacheMu.Lock()
+		assert.False(t, entries[0].evict())
+		folder.lastCompileCacheMu.Unlock()
+		assert.NotNil(t, folder.lastCompileCache)
+
+		assert.True(t, entries[0].evict())
+		assert.Nil(t, folder.lastCompileCache)
+		assert.False(t, entries[0].evict())
+	})
+
+	t.Run("ConcurrentCompiles", func(t *testing.T) {
+		s := newServer(WithMemoryBudget(1 << 30))
+		_, err := s.compile()
+		require.NoError(t, err)
+
+		// Compile cache hits update lastUsed while stats are collected.
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				_, err := s.compile()
+				assert.NoError(t, err)
+			}()
+			go func() {
+				defer wg.Done()
+				_, err := s.spxGetMemoryStats()
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("NegativeBudget", func(t *testing.T) {
+		s := newServer(WithMemoryBudget(-1))
+		assert.Zero(t, s.memoryBudget)
+	})
+}
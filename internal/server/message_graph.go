@@ -0,0 +1,217 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/internal/util"
+)
+
+// spxMessageEvent is a broadcast of an spx message, or an onMsg handler
+// listening to it.
+type spxMessageEvent struct {
+	// message is the spx message.
+	message string
+
+	// messageExpr is the expression of the message.
+	messageExpr gopast.Expr
+
+	// callExpr is the broadcast call, or the call registering the onMsg
+	// handler.
+	callExpr *gopast.CallExpr
+
+	// astFile is the AST file containing the event.
+	astFile *gopast.File
+
+	// spxFile is the spx file containing the event.
+	spxFile string
+}
+
+// spxMessageEvents returns the broadcasts of spx messages and the onMsg
+// handlers listening to them in the main package, sorted by their positions.
+// Messages that are not constant strings are skipped.
+func (r *compileResult) spxMessageEvents() (broadcasts, listeners []spxMessageEvent) {
+	for _, spxFile := range slices.Sorted(maps.Keys(r.mainASTPkg.Files)) {
+		astFile := r.mainASTPkg.Files[spxFile]
+		gopast.Inspect(astFile, func(node gopast.Node) bool {
+			callExpr, ok := node.(*gopast.CallExpr)
+			if !ok || len(callExpr.Args) == 0 {
+				return true
+			}
+			funcIdent := funcIdentOf(callExpr.Fun)
+			if funcIdent == nil {
+				return true
+			}
+			funcObj := r.typeInfo.ObjectOf(funcIdent)
+			if !isSpxPkgObject(funcObj) {
+				return true
+			}
+			funcName, _ := parseGopFuncName(funcObj.Name())
+			if funcName != "broadcast" && (funcName != "onMsg" || len(callExpr.Args) < 2) {
+				return true
+			}
+
+			messageExpr := callExpr.Args[0]
+			message, ok := getStringLitOrConstValue(messageExpr, r.typeInfo.Types[messageExpr])
+			if !ok {
+				return true
+			}
+			event := spxMessageEvent{
+				message:     message,
+				messageExpr: messageExpr,
+				callExpr:    callExpr,
+				astFile:     astFile,
+				spxFile:     spxFile,
+			}
+			if funcName == "broadcast" {
+				broadcasts = append(broadcasts, event)
+			} else {
+				listeners = append(listeners, event)
+			}
+			return true
+		})
+	}
+	return
+}
+
+// spxMessageEventAtASTFilePosition returns the broadcast or onMsg handler
+// whose message is a string literal at the given position in the given AST
+// file, or nil if there is none.
+func (r *compileResult) spxMessageEventAtASTFilePosition(astFile *gopast.File, position goptoken.Position) *spxMessageEvent {
+	broadcasts, listeners := r.spxMessageEvents()
+	for _, event := range slices.Concat(broadcasts, listeners) {
+		if event.astFile != astFile {
+			continue
+		}
+		if _, ok := event.messageExpr.(*gopast.BasicLit); !ok {
+			continue
+		}
+		litPos := r.fset.Position(event.messageExpr.Pos())
+		litEnd := r.fset.Position(event.messageExpr.End())
+		if position.Line == litPos.Line &&
+			position.Column >= litPos.Column &&
+			position.Column <= litEnd.Column {
+			return &event
+		}
+	}
+	return nil
+}
+
+// spxGetMessageGraph gets the graph of spx messages in the workspace, i.e.,
+// which handlers broadcast which messages and which onMsg handlers listen to
+// them.
+func (s *Server) spxGetMessageGraph() (*SpxMessageGraph, error) {
+	result, err := s.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[string]*SpxMessageGraphNode)
+	nodeFor := func(message string) *SpxMessageGraphNode {
+		node, ok := nodes[message]
+		if !ok {
+			node = &SpxMessageGraphNode{
+				Message:    message,
+				Broadcasts: []SpxMessageEndpoint{},
+				Listeners:  []SpxMessageEndpoint{},
+			}
+			nodes[message] = node
+		}
+		return node
+	}
+	broadcasts, listeners := result.spxMessageEvents()
+	for _, broadcast := range broadcasts {
+		node := nodeFor(broadcast.message)
+		endpoint := result.spxMessageEndpointFor(broadcast)
+		if _, item, ok := result.callHierarchyCallerOf(broadcast.astFile, funcIdentOf(broadcast.callExpr.Fun)); ok {
+			endpoint.Handler = &item
+		}
+		node.Broadcasts = append(node.Broadcasts, endpoint)
+	}
+	for _, listener := range listeners {
+		node := nodeFor(listener.message)
+		endpoint := result.spxMessageEndpointFor(listener)
+		endpoint.Handler = util.ToPtr(result.callHierarchyItemForSpxEventHandler(listener.callExpr))
+		node.Listeners = append(node.Listeners, endpoint)
+	}
+
+	graph := &SpxMessageGraph{Messages: make([]SpxMessageGraphNode, 0, len(nodes))}
+	for _, message := range slices.Sorted(maps.Keys(nodes)) {
+		graph.Messages = append(graph.Messages, *nodes[message])
+	}
+	return graph, nil
+}
+
+// spxMessageEndpointFor returns the [SpxMessageEndpoint] for the given spx
+// message event without its handler.
+func (r *compileResult) spxMessageEndpointFor(event spxMessageEvent) SpxMessageEndpoint {
+	owner := "Game"
+	if event.spxFile != r.mainSpxFile {
+		owner = strings.TrimSuffix(path.Base(event.spxFile), ".spx")
+	}
+	return SpxMessageEndpoint{
+		Owner:    owner,
+		Location: r.locationForNode(event.messageExpr),
+	}
+}
+
+// spxMessageAnalyzer is an [analyzer] for spx messages without counterparts.
+var spxMessageAnalyzer = &analyzer{
+	name:     "spxMessage",
+	requires: requireAST | requireTypeInfo,
+	run:      inspectForSpxMessages,
+}
+
+// inspectForSpxMessages inspects for spx messages that are broadcast without
+// any onMsg handler listening to them, and onMsg handlers listening to
+// messages that are never broadcast. The diagnostics link to the locations of
+// the counterparts listening to or broadcasting other messages.
+func inspectForSpxMessages(pass *analysisPass) {
+	result := pass.result
+	broadcasts, listeners := result.spxMessageEvents()
+	hasMessage := func(events []spxMessageEvent, message string) bool {
+		return slices.ContainsFunc(events, func(event spxMessageEvent) bool {
+			return event.message == message
+		})
+	}
+	relatedInformationFor := func(events []spxMessageEvent, format string) []DiagnosticRelatedInformation {
+		var related []DiagnosticRelatedInformation
+		for _, event := range events {
+			related = append(related, DiagnosticRelatedInformation{
+				Location: result.locationForNode(event.messageExpr),
+				Message:  fmt.Sprintf(format, event.message),
+			})
+		}
+		return related
+	}
+
+	for _, broadcast := range broadcasts {
+		if hasMessage(listeners, broadcast.message) {
+			continue
+		}
+		pass.report(broadcast.spxFile, Diagnostic{
+			Severity:           SeverityWarning,
+			Code:               DiagnosticCodeUnhandledBroadcast,
+			Range:              result.rangeForNode(broadcast.messageExpr),
+			Message:            fmt.Sprintf("message %q is broadcast but no onMsg handler listens to it", broadcast.message),
+			RelatedInformation: relatedInformationFor(listeners, "onMsg handler listening to %q"),
+		})
+	}
+	for _, listener := range listeners {
+		if hasMessage(broadcasts, listener.message) {
+			continue
+		}
+		pass.report(listener.spxFile, Diagnostic{
+			Severity:           SeverityWarning,
+			Code:               DiagnosticCodeUnusedMessageHandler,
+			Range:              result.rangeForNode(listener.messageExpr),
+			Message:            fmt.Sprintf("onMsg handler listens to message %q but it is never broadcast", listener.message),
+			RelatedInformation: relatedInformationFor(broadcasts, "broadcast of %q"),
+		})
+	}
+}
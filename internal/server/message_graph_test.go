@@ -0,0 +1,216 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSpxGetMessageGraph(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+
+const msgOver = "over"
+
+onStart => {
+	broadcast "start"
+}
+onMsg msgOver, => {
+	broadcast "restart"
+}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onMsg "start", => {
+	broadcast "over"
+}
+onClick => {
+	msg := "dynamic"
+	broadcast msg
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		graph, err := s.spxGetMessageGraph()
+		require.NoError(t, err)
+		require.NotNil(t, graph)
+		require.Len(t, graph.Messages, 3)
+
+		over := graph.Messages[0]
+		assert.Equal(t, "over", over.Message)
+		require.Len(t, over.Broadcasts, 1)
+		assert.Equal(t, "MySprite", over.Broadcasts[0].Owner)
+		require.NotNil(t, over.Broadcasts[0].Handler)
+		assert.Equal(t, "onMsg", over.Broadcasts[0].Handler.Name)
+		assert.Equal(t, Location{
+			URI: "file:///MySprite.spx",
+			Range: Range{
+				Start: Position{Line: 2, Character: 11},
+				End:   Position{Line: 2, Character: 17},
+			},
+		}, over.Broadcasts[0].Location)
+		require.Len(t, over.Listeners, 1)
+		assert.Equal(t, "Game", over.Listeners[0].Owner)
+		require.NotNil(t, over.Listeners[0].Handler)
+		assert.Equal(t, "onMsg", over.Listeners[0].Handler.Name)
+		assert.Equal(t, Location{
+			URI: "file:///main.spx",
+			Range: Range{
+				Start: Position{Line: 10, Character: 6},
+				End:   Position{Line: 10, Character: 13},
+			},
+		}, over.Listeners[0].Location)
+
+		restart := graph.Messages[1]
+		assert.Equal(t, "restart", restart.Message)
+		require.Len(t, restart.Broadcasts, 1)
+		assert.Equal(t, "Game", restart.Broadcasts[0].Owner)
+		assert.Empty(t, restart.Listeners)
+
+		start := graph.Messages[2]
+		assert.Equal(t, "start", start.Message)
+		require.Len(t, start.Broadcasts, 1)
+		assert.Equal(t, "Game", start.Broadcasts[0].Owner)
+		require.NotNil(t, start.Broadcasts[0].Handler)
+		assert.Equal(t, "onStart", start.Broadcasts[0].Handler.Name)
+		require.Len(t, start.Listeners, 1)
+		assert.Equal(t, "MySprite", start.Listeners[0].Owner)
+	})
+
+	t.Run("TopLevelBroadcast", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+broadcast "hello"
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		graph, err := s.spxGetMessageGraph()
+		require.NoError(t, err)
+		require.Len(t, graph.Messages, 1)
+		require.Len(t, graph.Messages[0].Broadcasts, 1)
+		assert.Nil(t, graph.Messages[0].Broadcasts[0].Handler)
+		assert.Empty(t, graph.Messages[0].Listeners)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(`run "assets", {Title: "My Game"}`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		graph, err := s.spxGetMessageGraph()
+		require.NoError(t, err)
+		require.NotNil(t, graph)
+		assert.Empty(t, graph.Messages)
+	})
+}
+
+func TestServerInspectForSpxMessages(t *testing.T) {
+	newServer := func() *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+
+onStart => {
+	broadcast "start"
+	broadcast "nobody"
+}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onMsg "start", => {
+	say "started"
+}
+onMsg "never", => {
+	say "never"
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+	}
+	diagnosticsFor := func(t *testing.T, s *Server, uri DocumentURI) []Diagnostic {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		return fullReport.Items
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer()
+
+		mainDiags := diagnosticsFor(t, s, "file:///main.spx")
+		require.Len(t, mainDiags, 1)
+		assert.Equal(t, Diagnostic{
+			Severity: SeverityWarning,
+			Code:     DiagnosticCodeUnhandledBroadcast,
+			Range: Range{
+				Start: Position{Line: 7, Character: 11},
+				End:   Position{Line: 7, Character: 19},
+			},
+			Message: `message "nobody" is broadcast but no onMsg handler listens to it`,
+			RelatedInformation: []DiagnosticRelatedInformation{
+				{
+					Location: Location{
+						URI: "file:///MySprite.spx",
+						Range: Range{
+							Start: Position{Line: 1, Character: 6},
+							End:   Position{Line: 1, Character: 13},
+						},
+					},
+					Message: `onMsg handler listening to "start"`,
+				},
+				{
+					Location: Location{
+						URI: "file:///MySprite.spx",
+						Range: Range{
+							Start: Position{Line: 4, Character: 6},
+							End:   Position{Line: 4, Character: 13},
+						},
+					},
+					Message: `onMsg handler listening to "never"`,
+				},
+			},
+		}, mainDiags[0])
+
+		spriteDiags := diagnosticsFor(t, s, "file:///MySprite.spx")
+		require.Len(t, spriteDiags, 1)
+		assert.Equal(t, DiagnosticCodeUnusedMessageHandler, spriteDiags[0].Code)
+		assert.Equal(t, `onMsg handler listens to message "never" but it is never broadcast`, spriteDiags[0].Message)
+		assert.Equal(t, Range{
+			Start: Position{Line: 4, Character: 6},
+			End:   Position{Line: 4, Character: 13},
+		}, spriteDiags[0].Range)
+		require.Len(t, spriteDiags[0].RelatedInformation, 2)
+		assert.Equal(t, `broadcast of "start"`, spriteDiags[0].RelatedInformation[0].Message)
+		assert.Equal(t, `broadcast of "nobody"`, spriteDiags[0].RelatedInformation[1].Message)
+	})
+
+	t.Run("DisabledAnalyzers", func(t *testing.T) {
+		s := newServer()
+		err := s.workspaceDidChangeConfiguration(&DidChangeConfigurationParams{
+			Settings: map[string]any{"analyzers": map[string]any{
+				DiagnosticCodeUnhandledBroadcast:   false,
+				DiagnosticCodeUnusedMessageHandler: false,
+			}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, diagnosticsFor(t, s, "file:///main.spx"))
+		assert.Empty(t, diagnosticsFor(t, s, "file:///MySprite.spx"))
+	})
+}
@@ -0,0 +1,356 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"go/types"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goplus/goxlsw/internal"
+	"github.com/goplus/mod/gopmod"
+	gopmodload "github.com/goplus/mod/modload"
+)
+
+const (
+	// goModFile is the go.mod file of the workspace module.
+	goModFile = "go.mod"
+
+	// gopModFile is the optional gop.mod file of the workspace module.
+	gopModFile = "gop.mod"
+)
+
+// moduleFiles are the files the workspace module is loaded from.
+var moduleFiles = []string{goModFile, gopModFile}
+
+// spxModule is the module of a workspace folder, loaded from its go.mod and
+// gop.mod files. A workspace folder without a go.mod file has the default
+// module, which only has the built-in classfiles like spx.
+type spxModule struct {
+	// mod is the module with all resolvable classfiles imported.
+	mod *gopmod.Module
+
+	// deps is the module graph of the workspace module, mapping the paths of
+	// all modules it requires, directly or via the modules replaced with
+	// directories in the workspace, to their resolved versions.
+	deps map[string]spxModuleDep
+
+	// diagnostics are the problems found when loading the module, keyed by
+	// the module files.
+	diagnostics map[string][]Diagnostic
+
+	// positionEncoding is the position encoding of the ranges of diagnostics.
+	positionEncoding PositionEncodingKind
+
+	// packageCache imports the packages not bundled with the server, if the
+	// embedder supplies a [PackageLoader].
+	packageCache *packageCache
+}
+
+// spxModuleDep is a module in the module graph of [spxModule].
+type spxModuleDep struct {
+	// path is the path of the module.
+	path string
+
+	// version is the version of the module. It is empty if the module is
+	// replaced with a directory.
+	version string
+
+	// dir is the directory in the workspace the module is replaced with, if
+	// any.
+	dir string
+
+	// mod is the module loaded from dir. It is nil if dir is empty or has no
+	// go.mod file.
+	mod *gopmodload.Module
+
+	// requiredBy is the module file requiring the module, used to report the
+	// problems of the module.
+	requiredBy string
+
+	// line is the 1-based line of the require directive in requiredBy, or 0
+	// if unknown.
+	line int
+}
+
+// String returns the module path along with its version or replacement
+// directory, e.g., "github.com/goplus/yap@v0.8.0".
+func (d spxModuleDep) String() string {
+	switch {
+	case d.version != "":
+		return d.path + "@" + d.version
+	case d.dir != "":
+		return d.path + " => ./" + d.dir
+	}
+	return d.path
+}
+
+// loadSpxModule loads the module of the workspace from the given snapshot,
+// with the classfile project of the given kind registered. Problems with the
+// module files are reported as diagnostics instead of errors, and the default
+// module is used if the go.mod file cannot be loaded. The ranges of the
+// diagnostics are in the given position encoding.
+func loadSpxModule(snapshot fs.FS, kind *ClassfileKind, encoding PositionEncodingKind) (*spxModule, error) {
+	m := &spxModule{
+		deps:             make(map[string]spxModuleDep),
+		diagnostics:      make(map[string][]Diagnostic),
+		positionEncoding: encoding,
+	}
+	readFile := func(name string) ([]byte, error) {
+		return fs.ReadFile(snapshot, name)
+	}
+
+	loaded := gopmodload.Default
+	if _, err := fs.Stat(snapshot, goModFile); err == nil {
+		mod, err := gopmodload.LoadFromEx(goModFile, gopModFile, readFile)
+		if err != nil {
+			m.addLoadErrorDiagnostics(snapshot, goModFile, err)
+		} else {
+			loaded = mod
+			m.buildGraph(snapshot, loaded, readFile)
+		}
+	} else if _, err := fs.Stat(snapshot, gopModFile); err == nil {
+		m.diagnostics[gopModFile] = append(m.diagnostics[gopModFile], Diagnostic{
+			Severity: SeverityWarning,
+			Code:     DiagnosticCodeInvalidModule,
+			Message:  "gop.mod is ignored without a go.mod file",
+		})
+	}
+
+	// Classfile modules are resolved from the module graph instead of the
+	// module cache, which is not available to the server.
+	opt := *loaded.Opt
+	opt.Projects = slices.Clip(opt.Projects)
+	opt.ClassMods = nil
+	if !kind.isSpx() {
+		opt.Projects = append(opt.Projects, kind.project())
+	}
+	for _, classMod := range loaded.Opt.ClassMods {
+		if classMod == GetSpxPkg().Path() {
+			// The spx classfile is always available.
+			continue
+		}
+		projects, err := m.classfileProjectsOf(classMod)
+		if err != nil {
+			m.addDepDiagnostic(snapshot, m.deps[classMod], err.Error())
+			continue
+		}
+		opt.Projects = append(opt.Projects, projects...)
+	}
+	m.mod = gopmod.New(gopmodload.Module{File: loaded.File, Opt: &opt})
+	if err := m.mod.ImportClasses(); err != nil {
+		return nil, fmt.Errorf("failed to import classes: %w", err)
+	}
+	return m, nil
+}
+
+// buildGraph builds the module graph of the given workspace module. Versions
+// required by the workspace module take precedence over those required by
+// its dependencies, as the go.mod file of a module lists all modules needed
+// to build its packages since Go 1.17. Replacements only apply when declared
+// by the workspace module, following the semantics of the go command.
+func (m *spxModule) buildGraph(snapshot fs.FS, mainMod gopmodload.Module, readFile func(string) ([]byte, error)) {
+	type replacement struct{ path, version string }
+	replacements := make(map[string]replacement)
+	for _, r := range mainMod.Replace {
+		replacements[r.Old.Path] = replacement{path: r.New.Path, version: r.New.Version}
+	}
+
+	type pending struct {
+		mod        gopmodload.Module
+		requiredBy string
+	}
+	queue := []pending{{mod: mainMod, requiredBy: goModFile}}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, r := range p.mod.Require {
+			if _, ok := m.deps[r.Mod.Path]; ok || r.Mod.Path == "" {
+				continue
+			}
+			dep := spxModuleDep{path: r.Mod.Path, version: r.Mod.Version, requiredBy: p.requiredBy}
+			if r.Syntax != nil {
+				dep.line = r.Syntax.Start.Line
+			}
+			if repl, ok := replacements[r.Mod.Path]; ok {
+				dep.version = repl.version
+				if repl.version == "" {
+					dep.dir = workspaceDirOfReplacement(repl.path)
+				}
+			}
+			if dep.dir != "" {
+				depGoModFile := path.Join(dep.dir, goModFile)
+				if mod, err := gopmodload.LoadFromEx(depGoModFile, path.Join(dep.dir, gopModFile), readFile); err == nil {
+					dep.mod = &mod
+					queue = append(queue, pending{mod: mod, requiredBy: depGoModFile})
+				} else if _, statErr := fs.Stat(snapshot, depGoModFile); statErr == nil {
+					m.addLoadErrorDiagnostics(snapshot, depGoModFile, err)
+				}
+			}
+			m.deps[r.Mod.Path] = dep
+		}
+	}
+}
+
+// workspaceDirOfReplacement returns the workspace directory of the given
+// replacement path of the workspace module, e.g., "libs/foo" for "./libs/foo".
+// It returns an empty string if the replacement is a module path, or a
+// directory outside of the workspace.
+func workspaceDirOfReplacement(replacement string) string {
+	if !strings.HasPrefix(replacement, "./") && !strings.HasPrefix(replacement, "../") {
+		return ""
+	}
+	dir := path.Clean(replacement)
+	if dir == ".." || strings.HasPrefix(dir, "../") {
+		return ""
+	}
+	return dir
+}
+
+// classfileProjectsOf returns the classfile projects registered by the given
+// classfile module, which must be replaced with a directory in the workspace.
+func (m *spxModule) classfileProjectsOf(classMod string) ([]*gopmod.Project, error) {
+	dep, ok := m.deps[classMod]
+	if !ok {
+		return nil, fmt.Errorf("classfile module %s is not required", classMod)
+	}
+	if dep.mod == nil {
+		return nil, fmt.Errorf("classfile module %s is not available in the workspace", dep)
+	}
+	projects := dep.mod.Projects()
+	if len(projects) == 0 {
+		return nil, fmt.Errorf("module %s registers no classfiles in its gop.mod", dep)
+	}
+	return projects, nil
+}
+
+// depOf returns the module in the module graph providing the package with the
+// given path.
+func (m *spxModule) depOf(pkgPath string) (spxModuleDep, bool) {
+	var (
+		found spxModuleDep
+		ok    bool
+	)
+	for modPath, dep := range m.deps {
+		if pkgPath != modPath && !strings.HasPrefix(pkgPath, modPath+"/") {
+			continue
+		}
+		// The longest module path wins for nested modules.
+		if !ok || len(modPath) > len(found.path) {
+			found, ok = dep, true
+		}
+	}
+	return found, ok
+}
+
+// modVersionOf returns the version of the module in the module graph providing
+// the package with the given path, or empty if there is none or the module is
+// replaced with a directory.
+func (m *spxModule) modVersionOf(pkgPath string) string {
+	dep, _ := m.depOf(pkgPath)
+	return dep.version
+}
+
+// Import implements [types.Importer]. Packages are imported from the package
+// data, or else via the package cache, and the failures to import packages of
+// the module graph are reported along with the modules providing them.
+func (m *spxModule) Import(pkgPath string) (*types.Package, error) {
+	pkg, err := internal.Importer.Import(pkgPath)
+	if err == nil {
+		return pkg, nil
+	}
+	if m.packageCache != nil && errors.Is(err, fs.ErrNotExist) {
+		pkg, err = m.packageCache.importFrom(pkgPath, m.modVersionOf)
+		if err == nil || !errors.Is(err, fs.ErrNotExist) {
+			return pkg, err
+		}
+	}
+	if dep, ok := m.depOf(pkgPath); ok {
+		return nil, fmt.Errorf("package %s of module %s is not available for analysis", pkgPath, dep)
+	}
+	if modPath := m.mod.Path(); modPath != "" && !isStdPkgPath(pkgPath) && pkgPath != modPath && !strings.HasPrefix(pkgPath, modPath+"/") {
+		return nil, fmt.Errorf("no required module provides package %s", pkgPath)
+	}
+	return nil, err
+}
+
+// isStdPkgPath reports whether the given package path is of the standard
+// library, whose first path elements have no dots.
+func isStdPkgPath(pkgPath string) bool {
+	first, _, _ := strings.Cut(pkgPath, "/")
+	return !strings.Contains(first, ".")
+}
+
+// addDepDiagnostic adds a diagnostic with the given message for the given
+// module in the module graph, at its require directive.
+func (m *spxModule) addDepDiagnostic(snapshot fs.FS, dep spxModuleDep, msg string) {
+	file := dep.requiredBy
+	if file == "" {
+		file = goModFile
+	}
+	m.diagnostics[file] = append(m.diagnostics[file], Diagnostic{
+		Severity: SeverityError,
+		Code:     DiagnosticCodeInvalidModule,
+		Range:    lineRangeIn(snapshot, file, dep.line, m.positionEncoding),
+		Message:  msg,
+	})
+}
+
+// addLoadErrorDiagnostics adds the diagnostics of the given error loading the
+// given module file. Errors of module file syntax, like
+// "go.mod:5: unknown directive: foo", are reported at their lines.
+func (m *spxModule) addLoadErrorDiagnostics(snapshot fs.FS, file string, err error) {
+	// Strip the call stacks added by the module loader.
+	for {
+		wrapper, ok := err.(interface{ Unwrap() error })
+		if !ok || wrapper.Unwrap() == nil {
+			break
+		}
+		err = wrapper.Unwrap()
+	}
+	for _, msg := range strings.Split(err.Error(), "\n") {
+		if msg == "" {
+			continue
+		}
+		diagFile, line := file, 0
+		if name, rest, ok := strings.Cut(msg, ":"); ok && slices.Contains(moduleFiles, path.Base(name)) {
+			if lineText, text, ok := strings.Cut(rest, ":"); ok {
+				if n, err := strconv.Atoi(lineText); err == nil {
+					diagFile, line, msg = name, n, strings.TrimSpace(text)
+				}
+			}
+		}
+		m.diagnostics[diagFile] = append(m.diagnostics[diagFile], Diagnostic{
+			Severity: SeverityError,
+			Code:     DiagnosticCodeInvalidModule,
+			Range:    lineRangeIn(snapshot, diagFile, line, m.positionEncoding),
+			Message:  msg,
+		})
+	}
+}
+
+// lineRangeIn returns the range of the given 1-based line in the given file in
+// the given position encoding. It returns the zero range if the line is
+// unknown.
+func lineRangeIn(snapshot fs.FS, file string, line int, encoding PositionEncodingKind) Range {
+	if line <= 0 {
+		return Range{}
+	}
+	content, err := fs.ReadFile(snapshot, file)
+	if err != nil {
+		return Range{}
+	}
+	lines := strings.Split(string(content), "\n")
+	if line > len(lines) {
+		return Range{}
+	}
+	text := strings.TrimRight(lines[line-1], "\r")
+	start := len(text) - len(strings.TrimLeft(text, " \t"))
+	return Range{
+		Start: Position{Line: uint32(line - 1), Character: uint32(utf8OffsetToEncoding(text, start, encoding))},
+		End:   Position{Line: uint32(line - 1), Character: uint32(utf8OffsetToEncoding(text, len(text), encoding))},
+	}
+}
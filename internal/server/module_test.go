@@ -0,0 +1,223 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSpxModule(t *testing.T) {
+	t.Run("WithoutGoMod", func(t *testing.T) {
+		m, err := loadSpxModule(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`run "assets", {}`),
+		}), spxClassfileKind, UTF16)
+		require.NoError(t, err)
+		assert.Empty(t, m.diagnostics)
+		assert.Empty(t, m.deps)
+		_, ok := m.mod.LookupClass(".spx")
+		assert.True(t, ok)
+	})
+
+	t.Run("ModuleGraph", func(t *testing.T) {
+		m, err := loadSpxModule(newMapFSWithoutModTime(map[string][]byte{
+			"go.mod": []byte(`module example.com/game
+
+go 1.21
+
+require (
+	example.com/lib v1.2.0
+	example.com/local v0.0.0
+)
+
+replace example.com/local => ./libs/local
+`),
+			"libs/local/go.mod": []byte(`module example.com/local
+
+go 1.21
+
+require (
+	example.com/lib v1.0.0
+	example.com/indirect v0.3.0
+)
+`),
+		}), spxClassfileKind, UTF16)
+		require.NoError(t, err)
+		assert.Empty(t, m.diagnostics)
+		require.Len(t, m.deps, 3)
+		assert.Equal(t, "example.com/lib@v1.2.0", m.deps["example.com/lib"].String())
+		assert.Equal(t, "example.com/local => ./libs/local", m.deps["example.com/local"].String())
+		assert.NotNil(t, m.deps["example.com/local"].mod)
+		assert.Equal(t, "example.com/indirect@v0.3.0", m.deps["example.com/indirect"].String())
+		assert.Equal(t, "libs/local/go.mod", m.deps["example.com/indirect"].requiredBy)
+
+		dep, ok := m.depOf("example.com/lib/util")
+		require.True(t, ok)
+		assert.Equal(t, "example.com/lib", dep.path)
+		_, ok = m.depOf("example.com/library")
+		assert.False(t, ok)
+	})
+
+	t.Run("ClassfileModuleInWorkspace", func(t *testing.T) {
+		m, err := loadSpxModule(newMapFSWithoutModTime(map[string][]byte{
+			"go.mod": []byte(`module example.com/game
+
+go 1.21
+
+require example.com/foo v0.0.0 //gop:class
+
+replace example.com/foo => ./foo
+`),
+			"foo/go.mod": []byte(`module example.com/foo
+
+go 1.21
+`),
+			"foo/gop.mod": []byte(`gop 1.2
+
+project .foo FooApp example.com/foo
+`),
+		}), spxClassfileKind, UTF16)
+		require.NoError(t, err)
+		assert.Empty(t, m.diagnostics)
+		project, ok := m.mod.LookupClass(".foo")
+		require.True(t, ok)
+		assert.Equal(t, "FooApp", project.Class)
+		assert.Equal(t, []string{"example.com/foo"}, project.PkgPaths)
+		_, ok = m.mod.LookupClass(".spx")
+		assert.True(t, ok)
+	})
+
+	t.Run("MissingClassfileModule", func(t *testing.T) {
+		m, err := loadSpxModule(newMapFSWithoutModTime(map[string][]byte{
+			"go.mod": []byte(`module example.com/game
+
+go 1.21
+
+require github.com/goplus/yap v0.8.0 //gop:class
+`),
+		}), spxClassfileKind, UTF16)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]Diagnostic{
+			"go.mod": {{
+				Severity: SeverityError,
+				Code:     DiagnosticCodeInvalidModule,
+				Range: Range{
+					Start: Position{Line: 4, Character: 0},
+					End:   Position{Line: 4, Character: 48},
+				},
+				Message: "classfile module github.com/goplus/yap@v0.8.0 is not available in the workspace",
+			}},
+		}, m.diagnostics)
+		_, ok := m.mod.LookupClass(".spx")
+		assert.True(t, ok)
+	})
+
+	t.Run("SpxClassfileModule", func(t *testing.T) {
+		m, err := loadSpxModule(newMapFSWithoutModTime(map[string][]byte{
+			"go.mod": []byte(`module example.com/game
+
+go 1.21
+
+require github.com/goplus/spx v1.0.0 //gop:class
+`),
+		}), spxClassfileKind, UTF16)
+		require.NoError(t, err)
+		assert.Empty(t, m.diagnostics)
+	})
+
+	t.Run("InvalidGoMod", func(t *testing.T) {
+		m, err := loadSpxModule(newMapFSWithoutModTime(map[string][]byte{
+			"go.mod": []byte(`module example.com/game
+
+go 1.21
+foo bar
+`),
+		}), spxClassfileKind, UTF16)
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]Diagnostic{
+			"go.mod": {{
+				Severity: SeverityError,
+				Code:     DiagnosticCodeInvalidModule,
+				Range: Range{
+					Start: Position{Line: 3, Character: 0},
+					End:   Position{Line: 3, Character: 7},
+				},
+				Message: "unknown directive: foo",
+			}},
+		}, m.diagnostics)
+		_, ok := m.mod.LookupClass(".spx")
+		assert.True(t, ok)
+	})
+
+	t.Run("GopModWithoutGoMod", func(t *testing.T) {
+		m, err := loadSpxModule(newMapFSWithoutModTime(map[string][]byte{
+			"gop.mod": []byte("gop 1.2\n"),
+		}), spxClassfileKind, UTF16)
+		require.NoError(t, err)
+		require.Len(t, m.diagnostics["gop.mod"], 1)
+		assert.Equal(t, SeverityWarning, m.diagnostics["gop.mod"][0].Severity)
+	})
+}
+
+func TestServerCompileWithModule(t *testing.T) {
+	t.Run("ModuleDiagnostics", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`run "assets", {}`),
+			"go.mod": []byte(`module example.com/game
+
+go 1.21
+foo bar
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+		result, err := s.compile()
+		require.NoError(t, err)
+		diags := result.diagnostics["file:///go.mod"]
+		require.Len(t, diags, 1)
+		assert.Equal(t, DiagnosticCodeInvalidModule, diags[0].Code)
+		assert.Empty(t, result.diagnostics["file:///main.spx"])
+	})
+
+	t.Run("ImportFromRequiredModule", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`import (
+	"example.com/lib/util"
+	"example.com/other"
+)
+
+util.Do
+other.Do
+run "assets", {}
+`),
+			"go.mod": []byte(`module example.com/game
+
+go 1.21
+
+require example.com/lib v1.0.0
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+		result, err := s.compile()
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics["file:///go.mod"])
+
+		var messages []string
+		for _, diag := range result.diagnostics["file:///main.spx"] {
+			messages = append(messages, diag.Message)
+		}
+		assert.True(t, containsMessageWith(messages, "package example.com/lib/util of module example.com/lib@v1.0.0 is not available for analysis"), "messages: %v", messages)
+		assert.True(t, containsMessageWith(messages, "no required module provides package example.com/other"), "messages: %v", messages)
+	})
+}
+
+// containsMessageWith reports whether any of the given messages contains the
+// given substring.
+func containsMessageWith(messages []string, substr string) bool {
+	for _, msg := range messages {
+		if strings.Contains(msg, substr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+package server
+
+import (
+	"bytes"
+	"container/list"
+	"errors"
+	"fmt"
+	"go/types"
+	"io/fs"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/goplus/goxlsw/internal"
+)
+
+// PackageLoader supplies the export data of packages not bundled with the
+// server, e.g., math/rand or community packages, which lets embedders fetch
+// them on demand instead of shipping them with the server.
+type PackageLoader interface {
+	// LoadPackageExport returns the export data of the package with the given
+	// path, in the format of the .pkgexport files of the bundled package
+	// data, i.e., written by [golang.org/x/tools/go/gcexportdata.Write].
+	//
+	// It returns an error wrapping [fs.ErrNotExist] if the package is not
+	// available, which is remembered until the package falls out of the
+	// cache. It may block, e.g., while fetching the export data over HTTP.
+	//
+	// Loaded packages are cached along with the version of the module
+	// providing them in the module graph of the workspace, so the package is
+	// loaded again once its module is required at another version.
+	LoadPackageExport(pkgPath string) ([]byte, error)
+}
+
+// PackageLoaderFunc is a [PackageLoader] implemented by a function.
+type PackageLoaderFunc func(pkgPath string) ([]byte, error)
+
+// LoadPackageExport implements [PackageLoader].
+func (f PackageLoaderFunc) LoadPackageExport(pkgPath string) ([]byte, error) {
+	return f(pkgPath)
+}
+
+// defaultPackageCacheSize is the default number of packages loaded by
+// [PackageLoader] kept by [packageCache].
+const defaultPackageCacheSize = 64
+
+// WithPackageLoader sets the loader supplying the export data of packages not
+// bundled with the server. Loaded packages are kept in an LRU cache of the
+// given size, or a default size if it is not positive.
+func WithPackageLoader(loader PackageLoader, cacheSize int) Option {
+	return func(s *Server) {
+		if cacheSize <= 0 {
+			cacheSize = defaultPackageCacheSize
+		}
+		s.packageCache = newPackageCache(loader, cacheSize)
+	}
+}
+
+// packageCache imports packages via a [PackageLoader], keeping the most
+// recently used ones.
+type packageCache struct {
+	loader PackageLoader
+	size   int
+
+	// cache persists the loaded export data across sessions, if the embedder
+	// supplies a [Cache], and logger logs failures to persist it.
+	cache  Cache
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	entries map[packageCacheID]*list.Element
+	lru     *list.List // of *packageCacheEntry, most recently used first
+	loading map[packageCacheID]*packageCacheLoad
+}
+
+// packageCacheID identifies a package of [packageCache] by its path and the
+// version of the module providing it, which is empty if unknown, e.g., for
+// packages of the standard library.
+type packageCacheID struct {
+	pkgPath    string
+	modVersion string
+}
+
+// packageCacheEntry is an entry of [packageCache].
+type packageCacheEntry struct {
+	packageCacheID
+	pkg *types.Package
+	err error
+
+	// size is the size of the export data of the package, and lastUsed is
+	// when it was last imported. See [WithMemoryBudget].
+	size     int64
+	lastUsed time.Time
+}
+
+// packageCacheLoad is an in-flight load of [packageCache], shared by
+// concurrent imports of the same package.
+type packageCacheLoad struct {
+	done chan struct{}
+	pkg  *types.Package
+	err  error
+}
+
+// newPackageCache creates a new [packageCache].
+func newPackageCache(loader PackageLoader, size int) *packageCache {
+	return &packageCache{
+		loader:  loader,
+		size:    size,
+		logger:  slog.Default(),
+		entries: make(map[packageCacheID]*list.Element),
+		lru:     list.New(),
+		loading: make(map[packageCacheID]*packageCacheLoad),
+	}
+}
+
+// Import implements [types.Importer]. Packages are imported regardless of the
+// versions of the modules providing them. See [packageCache.importFrom].
+func (c *packageCache) Import(pkgPath string) (*types.Package, error) {
+	return c.importFrom(pkgPath, func(string) string { return "" })
+}
+
+// importFrom imports the package with the given path, where modVersionOf
+// returns the version of the module providing a package, or empty if unknown.
+// Packages provided by different versions of a module are cached separately,
+// and the dependencies of the package are resolved against the cached
+// packages of the versions returned by modVersionOf. Failures other than
+// unavailable packages, e.g., network errors, are not cached, so that they are
+// retried by the next import.
+func (c *packageCache) importFrom(pkgPath string, modVersionOf func(pkgPath string) string) (*types.Package, error) {
+	id := packageCacheID{pkgPath: pkgPath, modVersion: modVersionOf(pkgPath)}
+	c.mu.Lock()
+	if elem, ok := c.entries[id]; ok {
+		c.lru.MoveToFront(elem)
+		entry := elem.Value.(*packageCacheEntry)
+		entry.lastUsed = time.Now()
+		c.mu.Unlock()
+		return entry.pkg, entry.err
+	}
+	if load, ok := c.loading[id]; ok {
+		c.mu.Unlock()
+		<-load.done
+		return load.pkg, load.err
+	}
+	load := &packageCacheLoad{done: make(chan struct{})}
+	c.loading[id] = load
+	deps := c.packagesLocked(modVersionOf)
+	c.mu.Unlock()
+
+	var size int64
+	load.pkg, size, load.err = c.load(id, deps)
+
+	c.mu.Lock()
+	delete(c.loading, id)
+	if load.err == nil || errors.Is(load.err, fs.ErrNotExist) {
+		c.addLocked(&packageCacheEntry{
+			packageCacheID: id,
+			pkg:            load.pkg,
+			err:            load.err,
+			size:           size,
+			lastUsed:       time.Now(),
+		})
+	}
+	c.mu.Unlock()
+	close(load.done)
+	return load.pkg, load.err
+}
+
+// load loads the package with the given ID via the loader, resolving its
+// dependencies against the given packages. It also returns the size of the
+// export data of the package. The export data is read from and written to the
+// persistent cache, if any, so that it is only loaded once across sessions
+// for each version of the module providing it. Packages without a known module
+// version, e.g., those of the standard library or of modules replaced with
+// directories, are not persisted, as nothing in their key would change along
+// with their export data.
+func (c *packageCache) load(id packageCacheID, deps map[string]*types.Package) (*types.Package, int64, error) {
+	pkgPath := id.pkgPath
+	persistent := c.cache != nil && id.modVersion != ""
+	key := cacheKey(cacheKindPackageExport, pkgPath, id.modVersion)
+	if persistent {
+		export, err := c.cache.Get(key)
+		if err == nil {
+			pkg, err := internal.Importer.ImportExport(pkgPath, bytes.NewReader(export), deps)
+			if err == nil {
+				return pkg, int64(len(export)), nil
+			}
+			c.logger.Warn("failed to import cached package export", "pkgPath", pkgPath, "error", err)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			c.logger.Warn("failed to get cached package export", "pkgPath", pkgPath, "error", err)
+		}
+	}
+
+	export, err := c.loader.LoadPackageExport(pkgPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load package %s: %w", pkgPath, err)
+	}
+	pkg, err := internal.Importer.ImportExport(pkgPath, bytes.NewReader(export), deps)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to import package %s: %w", pkgPath, err)
+	}
+	if persistent {
+		if err := c.cache.Put(key, export); err != nil {
+			c.logger.Warn("failed to cache package export", "pkgPath", pkgPath, "error", err)
+		}
+	}
+	return pkg, int64(len(export)), nil
+}
+
+// packagesLocked returns the cached packages by their paths, of the versions
+// of the modules providing them returned by modVersionOf. It must be called
+// with c.mu held.
+func (c *packageCache) packagesLocked(modVersionOf func(pkgPath string) string) map[string]*types.Package {
+	pkgs := make(map[string]*types.Package, len(c.entries))
+	for id, elem := range c.entries {
+		if pkg := elem.Value.(*packageCacheEntry).pkg; pkg != nil && id.modVersion == modVersionOf(id.pkgPath) {
+			pkgs[id.pkgPath] = pkg
+		}
+	}
+	return pkgs
+}
+
+// addLocked adds the given entry as the most recently used one, evicting the
+// least recently used ones beyond the cache size. It must be called with c.mu
+// held.
+func (c *packageCache) addLocked(entry *packageCacheEntry) {
+	c.entries[entry.packageCacheID] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		oldest := c.lru.Back()
+		c.lru.Remove(oldest)
+		delete(c.entries, oldest.Value.(*packageCacheEntry).packageCacheID)
+	}
+}
+
+// memoryCacheEntries returns the entries of the cache accounted against the
+// memory budget. See [WithMemoryBudget].
+func (c *packageCache) memoryCacheEntries() []memoryCacheEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]memoryCacheEntry, 0, c.lru.Len())
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		entry := elem.Value.(*packageCacheEntry)
+		entries = append(entries, memoryCacheEntry{
+			cache:    memoryCachePackages,
+			bytes:    entry.size,
+			lastUsed: entry.lastUsed,
+			evict: func() bool {
+				c.mu.Lock()
+				defer c.mu.Unlock()
+				elem, ok := c.entries[entry.packageCacheID]
+				if !ok || elem.Value != entry {
+					return false
+				}
+				c.lru.Remove(elem)
+				delete(c.entries, entry.packageCacheID)
+				return true
+			},
+		})
+	}
+	return entries
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/token"
+	"go/types"
+	"io/fs"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/tools/go/gcexportdata"
+)
+
+// newTestPackageExport returns the export data of a package with the given
+// path and a function Hello returning a string.
+func newTestPackageExport(t *testing.T, pkgPath string) []byte {
+	pkg := types.NewPackage(pkgPath, path.Base(pkgPath))
+	sig := types.NewSignatureType(nil, nil, nil, nil, types.NewTuple(types.NewParam(token.NoPos, pkg, "", types.Typ[types.String])), false)
+	pkg.Scope().Insert(types.NewFunc(token.NoPos, pkg, "Hello", sig))
+	pkg.MarkComplete()
+
+	var buf bytes.Buffer
+	require.NoError(t, gcexportdata.Write(&buf, token.NewFileSet(), pkg))
+	return buf.Bytes()
+}
+
+func TestPackageCache(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		var loads []string
+		c := newPackageCache(PackageLoaderFunc(func(pkgPath string) ([]byte, error) {
+			loads = append(loads, pkgPath)
+			return newTestPackageExport(t, pkgPath), nil
+		}), 2)
+
+		pkg, err := c.Import("example.com/greet")
+		require.NoError(t, err)
+		assert.Equal(t, "greet", pkg.Name())
+		assert.NotNil(t, pkg.Scope().Lookup("Hello"))
+
+		cached, err := c.Import("example.com/greet")
+		require.NoError(t, err)
+		assert.Same(t, pkg, cached)
+		assert.Equal(t, []string{"example.com/greet"}, loads)
+	})
+
+	t.Run("Eviction", func(t *testing.T) {
+		var loads []string
+		c := newPackageCache(PackageLoaderFunc(func(pkgPath string) ([]byte, error) {
+			loads = append(loads, pkgPath)
+			return newTestPackageExport(t, pkgPath), nil
+		}), 2)
+
+		for _, pkgPath := range []string{"example.com/a", "example.com/b", "example.com/a", "example.com/c", "example.com/a", "example.com/b"} {
+			_, err := c.Import(pkgPath)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, []string{"example.com/a", "example.com/b", "example.com/c", "example.com/b"}, loads)
+	})
+
+	t.Run("NotExist", func(t *testing.T) {
+		loads := 0
+		c := newPackageCache(PackageLoaderFunc(func(pkgPath string) ([]byte, error) {
+			loads++
+			return nil, fmt.Errorf("no such package: %w", fs.ErrNotExist)
+		}), 2)
+
+		for range 2 {
+			_, err := c.Import("example.com/missing")
+			assert.ErrorIs(t, err, fs.ErrNotExist)
+		}
+		assert.Equal(t, 1, loads)
+	})
+
+	t.Run("TransientError", func(t *testing.T) {
+		loads := 0
+		c := newPackageCache(PackageLoaderFunc(func(pkgPath string) ([]byte, error) {
+			loads++
+			if loads == 1 {
+				return nil, errors.New("network error")
+			}
+			return newTestPackageExport(t, pkgPath), nil
+		}), 2)
+
+		_, err := c.Import("example.com/greet")
+		assert.EqualError(t, err, "failed to load package example.com/greet: network error")
+		_, err = c.Import("example.com/greet")
+		require.NoError(t, err)
+		assert.Equal(t, 2, loads)
+	})
+
+	t.Run("InvalidExportData", func(t *testing.T) {
+		c := newPackageCache(PackageLoaderFunc(func(pkgPath string) ([]byte, error) {
+			return []byte("invalid"), nil
+		}), 2)
+
+		_, err := c.Import("example.com/greet")
+		assert.ErrorContains(t, err, "failed to import package example.com/greet")
+	})
+}
+
+func TestServerWithPackageLoader(t *testing.T) {
+	files := map[string][]byte{
+		"main.spx": []byte(`import "example.com/greet"
+
+echo greet.hello
+run "assets", {}
+`),
+		"assets/index.json": []byte(`{}`),
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		var loads []string
+		s := New(newMapFSWithoutModTime(files), nil, WithPackageLoader(PackageLoaderFunc(func(pkgPath string) ([]byte, error) {
+			loads = append(loads, pkgPath)
+			if pkgPath != "example.com/greet" {
+				return nil, fs.ErrNotExist
+			}
+			return newTestPackageExport(t, pkgPath), nil
+		}), 0))
+		result, err := s.compile()
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics["file:///main.spx"])
+		assert.Equal(t, []string{"example.com/greet"}, loads)
+	})
+
+	t.Run("WithoutPackageLoader", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(files), nil)
+		result, err := s.compile()
+		require.NoError(t, err)
+		assert.NotEmpty(t, result.diagnostics["file:///main.spx"])
+	})
+}
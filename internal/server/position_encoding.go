@@ -0,0 +1,99 @@
+package server
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// negotiatePositionEncoding returns the position encoding to use with a client
+// of the given capabilities, which is UTF-8 if the client offers it, or the
+// mandatory UTF-16 otherwise.
+//
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#positionEncodingKind
+func negotiatePositionEncoding(caps *ClientCapabilities) PositionEncodingKind {
+	if caps != nil && caps.General != nil && slices.Contains(caps.General.PositionEncodings, UTF8) {
+		return UTF8
+	}
+	return UTF16
+}
+
+// positionEncoding returns the position encoding negotiated with the client.
+// See [negotiatePositionEncoding].
+func (s *Server) positionEncoding() PositionEncodingKind {
+	return negotiatePositionEncoding(s.clientCapabilities.Load())
+}
+
+// utf8OffsetToEncoding converts a UTF-8 byte offset in the given line to a
+// character offset in the given position encoding, which defaults to UTF-16.
+// The offset is clamped to the line bounds.
+func utf8OffsetToEncoding(line string, utf8Offset int, encoding PositionEncodingKind) int {
+	utf8Offset = min(max(utf8Offset, 0), len(line))
+	if encoding == UTF8 {
+		return utf8Offset
+	}
+
+	var offset int
+	for _, r := range line[:utf8Offset] {
+		offset += utf16.RuneLen(r)
+	}
+	return offset
+}
+
+// encodingOffsetToUTF8 converts a character offset in the given position
+// encoding, which defaults to UTF-16, to a UTF-8 byte offset in the given line.
+// The offset is clamped to the line bounds, and never splits a character.
+func encodingOffsetToUTF8(line string, offset int, encoding PositionEncodingKind) int {
+	if offset <= 0 {
+		return 0
+	}
+	if encoding == UTF8 {
+		offset = min(offset, len(line))
+		for offset < len(line) && !utf8.RuneStart(line[offset]) {
+			offset++
+		}
+		return offset
+	}
+
+	var units, utf8Offset int
+	for _, r := range line {
+		if units >= offset {
+			break
+		}
+		units += utf16.RuneLen(r)
+		utf8Offset += utf8.RuneLen(r)
+	}
+	return utf8Offset
+}
+
+// positionOffset returns the UTF-8 byte offset of the given position in the
+// given position encoding in the given content. The position is clamped to the
+// content bounds.
+func positionOffset(content string, position Position, encoding PositionEncodingKind) int {
+	offset := 0
+	for range position.Line {
+		i := strings.IndexByte(content[offset:], '\n')
+		if i < 0 {
+			return len(content)
+		}
+		offset += i + 1
+	}
+	line := content[offset:]
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	return offset + encodingOffsetToUTF8(line, int(position.Character), encoding)
+}
+
+// offsetPosition returns the position in the given position encoding of the
+// given UTF-8 byte offset in the given content. The offset is clamped to the
+// content bounds.
+func offsetPosition(content string, offset int, encoding PositionEncodingKind) Position {
+	offset = min(max(offset, 0), len(content))
+	lineStart := strings.LastIndexByte(content[:offset], '\n') + 1
+	return Position{
+		Line:      uint32(strings.Count(content[:lineStart], "\n")),
+		Character: uint32(utf8OffsetToEncoding(content[lineStart:], offset-lineStart, encoding)),
+	}
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiatePositionEncoding(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		caps *ClientCapabilities
+		want PositionEncodingKind
+	}{
+		{"NilCapabilities", nil, UTF16},
+		{"NoGeneralCapabilities", &ClientCapabilities{}, UTF16},
+		{"NoPositionEncodings", &ClientCapabilities{General: &GeneralClientCapabilities{}}, UTF16},
+		{"UTF16Only", &ClientCapabilities{General: &GeneralClientCapabilities{
+			PositionEncodings: []PositionEncodingKind{UTF16},
+		}}, UTF16},
+		{"UTF8Offered", &ClientCapabilities{General: &GeneralClientCapabilities{
+			PositionEncodings: []PositionEncodingKind{UTF16, UTF8},
+		}}, UTF8},
+		{"UTF32Only", &ClientCapabilities{General: &GeneralClientCapabilities{
+			PositionEncodings: []PositionEncodingKind{UTF32},
+		}}, UTF16},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, negotiatePositionEncoding(tt.caps))
+		})
+	}
+}
+
+func TestPositionEncodingOffsets(t *testing.T) {
+	// "a" is 1 byte, "你" is 3 bytes and 1 UTF-16 unit, "😀" is 4 bytes and
+	// 2 UTF-16 units.
+	const line = "a你😀b"
+
+	t.Run("UTF8OffsetToEncoding", func(t *testing.T) {
+		for _, tt := range []struct {
+			utf8Offset int
+			utf16      int
+		}{
+			{-1, 0},
+			{0, 0},
+			{1, 1},
+			{4, 2},
+			{8, 4},
+			{9, 5},
+			{100, 5},
+		} {
+			assert.Equal(t, tt.utf16, utf8OffsetToEncoding(line, tt.utf8Offset, UTF16), "UTF-8 offset %d", tt.utf8Offset)
+			assert.Equal(t, min(max(tt.utf8Offset, 0), len(line)), utf8OffsetToEncoding(line, tt.utf8Offset, UTF8), "UTF-8 offset %d", tt.utf8Offset)
+		}
+	})
+
+	t.Run("EncodingOffsetToUTF8", func(t *testing.T) {
+		for _, tt := range []struct {
+			utf16 int
+			want  int
+		}{
+			{-1, 0},
+			{0, 0},
+			{1, 1},
+			{2, 4},
+			{3, 8}, // Inside the surrogate pair.
+			{4, 8},
+			{5, 9},
+			{100, 9},
+		} {
+			assert.Equal(t, tt.want, encodingOffsetToUTF8(line, tt.utf16, UTF16), "UTF-16 offset %d", tt.utf16)
+		}
+		assert.Equal(t, 4, encodingOffsetToUTF8(line, 4, UTF8))
+		assert.Equal(t, 4, encodingOffsetToUTF8(line, 2, UTF8), "inside a character")
+		assert.Equal(t, 9, encodingOffsetToUTF8(line, 100, UTF8))
+	})
+
+	t.Run("DefaultsToUTF16", func(t *testing.T) {
+		assert.Equal(t, 4, utf8OffsetToEncoding(line, 8, ""))
+		assert.Equal(t, 8, encodingOffsetToUTF8(line, 4, ""))
+	})
+
+	t.Run("PositionOffset", func(t *testing.T) {
+		content := "x\n" + line + "\ny"
+		for _, encoding := range []PositionEncodingKind{UTF16, UTF8} {
+			for _, utf8Offset := range []int{0, 1, 2, 3, 6, 10, 11, 12, 13} {
+				position := offsetPosition(content, utf8Offset, encoding)
+				assert.Equal(t, utf8Offset, positionOffset(content, position, encoding), "%s offset %d", encoding, utf8Offset)
+			}
+		}
+		assert.Equal(t, Position{Line: 1, Character: 4}, offsetPosition(content, 10, UTF16))
+		assert.Equal(t, Position{Line: 1, Character: 8}, offsetPosition(content, 10, UTF8))
+	})
+}
+
+func TestServerPositionEncoding(t *testing.T) {
+	newServer := func(encoding PositionEncodingKind) *Server {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte("echo \"你好😀\", 1\nrun \"assets\", {Title: \"My Game\"}\n"),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+		s.clientCapabilities.Store(&ClientCapabilities{General: &GeneralClientCapabilities{
+			PositionEncodings: []PositionEncodingKind{encoding},
+		}})
+		return s
+	}
+
+	t.Run("SemanticTokens", func(t *testing.T) {
+		for _, tt := range []struct {
+			encoding PositionEncodingKind
+			want     []uint32
+		}{
+			{UTF16, []uint32{
+				0, 0, 4, 7, 8, // echo
+				0, 5, 6, 11, 0, // "你好😀"
+				0, 8, 1, 12, 0, // 1
+			}},
+			{UTF8, []uint32{
+				0, 0, 4, 7, 8, // echo
+				0, 5, 12, 11, 0, // "你好😀"
+				0, 14, 1, 12, 0, // 1
+			}},
+		} {
+			t.Run(string(tt.encoding), func(t *testing.T) {
+				s := newServer(tt.encoding)
+				tokens, err := s.textDocumentSemanticTokensFull(&SemanticTokensParams{
+					TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				})
+				require.NoError(t, err)
+				require.NotNil(t, tokens)
+				require.GreaterOrEqual(t, len(tokens.Data), len(tt.want))
+				assert.Equal(t, tt.want, tokens.Data[:len(tt.want)])
+			})
+		}
+	})
+
+	t.Run("DidChange", func(t *testing.T) {
+		for _, tt := range []struct {
+			encoding  PositionEncodingKind
+			character uint32
+		}{
+			{UTF16, 13},
+			{UTF8, 19},
+		} {
+			t.Run(string(tt.encoding), func(t *testing.T) {
+				s := newServer(tt.encoding)
+				err := s.textDocumentDidOpen(&DidOpenTextDocumentParams{
+					TextDocument: TextDocumentItem{
+						URI:     "file:///main.spx",
+						Version: 1,
+						Text:    "echo \"你好😀\", 1\nrun \"assets\", {Title: \"My Game\"}\n",
+					},
+				})
+				require.NoError(t, err)
+
+				// Replace the "1" after the string.
+				err = s.textDocumentDidChange(&DidChangeTextDocumentParams{
+					TextDocument: VersionedTextDocumentIdentifier{
+						TextDocumentIdentifier: TextDocumentIdentifier{URI: "file:///main.spx"},
+						Version:                2,
+					},
+					ContentChanges: []TextDocumentContentChangeEvent{{
+						Range: &Range{
+							Start: Position{Line: 0, Character: tt.character},
+							End:   Position{Line: 0, Character: tt.character + 1},
+						},
+						Text: "2",
+					}},
+				})
+				require.NoError(t, err)
+
+				content, err := fs.ReadFile(s.workspaceFolderSnapshot(s.defaultWorkspaceFolder()), "main.spx")
+				require.NoError(t, err)
+				assert.Equal(t, "echo \"你好😀\", 2\nrun \"assets\", {Title: \"My Game\"}\n", string(content))
+			})
+		}
+	})
+}
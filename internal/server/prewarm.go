@@ -0,0 +1,33 @@
+package server
+
+import (
+	"context"
+	"errors"
+)
+
+// Prewarm does the work that the first requests would otherwise wait for, so
+// that the first completion after opening a project is not a multi-second
+// stall. It imports the spx package from its export data, builds the tables of
+// builtin and spx definitions and of auto-importable packages, and compiles
+// the projects of all workspace folders, which also type-checks them. It can
+// be called right after creating the server, e.g., in the background while
+// the project is still being displayed, and returns early with the error of
+// ctx once ctx is done.
+func (s *Server) Prewarm(ctx context.Context) error {
+	for _, warm := range []func(){
+		func() { GetSpxPkg() },
+		func() { GetSpxPkgDefinitions() },
+		func() { GetBuiltinSpxDefinitions() },
+		func() { autoImportablePkgs() },
+	} {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		warm()
+	}
+
+	if _, err := s.compileWorkspaceFolders(ctx); err != nil && !errors.Is(err, errNoMainSpxFile) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerPrewarm(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(`run "assets", {Title: "My Game"}`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+		folder := s.defaultWorkspaceFolder()
+		require.Nil(t, folder.lastCompileCache)
+
+		require.NoError(t, s.Prewarm(context.Background()))
+		require.NotNil(t, folder.lastCompileCache)
+
+		// Later requests reuse the compile result.
+		result, err := s.compile()
+		require.NoError(t, err)
+		assert.Same(t, folder.lastCompileCache.result, result)
+	})
+
+	t.Run("Command", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(`run "assets", {Title: "My Game"}`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		result, err := s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{Command: "spx.prewarm"})
+		require.NoError(t, err)
+		assert.Nil(t, result)
+		assert.NotNil(t, s.defaultWorkspaceFolder().lastCompileCache)
+	})
+
+	t.Run("NoSpxFiles", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		assert.NoError(t, s.Prewarm(context.Background()))
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(`run "assets", {Title: "My Game"}`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.ErrorIs(t, s.Prewarm(ctx), context.Canceled)
+		assert.Nil(t, s.defaultWorkspaceFolder().lastCompileCache)
+	})
+}
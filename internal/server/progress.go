@@ -0,0 +1,88 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+)
+
+// workDoneProgressCreateTimeout is the timeout for the client to respond to a
+// window/workDoneProgress/create request.
+const workDoneProgressCreateTimeout = 5 * time.Second
+
+// workDoneProgress reports the progress of a long-running operation to the
+// client. A nil workDoneProgress reports nothing.
+//
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#workDoneProgress
+type workDoneProgress struct {
+	s     *Server
+	token ProgressToken
+}
+
+// beginWorkDoneProgress begins reporting the progress of a long-running
+// operation with the given title. The token is the one provided by the client
+// in the request params, if any. Otherwise, a server-initiated token is created
+// when the client supports it. It returns nil if no progress can be reported.
+func (s *Server) beginWorkDoneProgress(token ProgressToken, title string) *workDoneProgress {
+	if s.replier == nil {
+		return nil
+	}
+	if token == nil {
+		caps := s.clientCapabilities.Load()
+		if caps == nil || !caps.Window.WorkDoneProgress {
+			return nil
+		}
+
+		token = fmt.Sprintf("goxlsw/progress/%d", s.lastProgressTokenID.Add(1))
+		ctx, cancel := context.WithTimeout(context.Background(), workDoneProgressCreateTimeout)
+		defer cancel()
+		if err := s.call(ctx, "window/workDoneProgress/create", &WorkDoneProgressCreateParams{Token: token}, nil); err != nil {
+			return nil
+		}
+	}
+
+	p := &workDoneProgress{s: s, token: token}
+	p.notify(&WorkDoneProgressBegin{
+		Kind:  "begin",
+		Title: title,
+	})
+	return p
+}
+
+// report reports the progress with the given message and percentage in the
+// range [0, 100].
+func (p *workDoneProgress) report(message string, percentage uint32) {
+	if p == nil {
+		return
+	}
+	p.notify(&WorkDoneProgressReport{
+		Kind:       "report",
+		Message:    message,
+		Percentage: min(percentage, 100),
+	})
+}
+
+// end ends the progress with the given final message.
+func (p *workDoneProgress) end(message string) {
+	if p == nil {
+		return
+	}
+	p.notify(&WorkDoneProgressEnd{
+		Kind:    "end",
+		Message: message,
+	})
+}
+
+// notify sends a $/progress notification with the given value.
+func (p *workDoneProgress) notify(value any) {
+	n, err := jsonrpc2.NewNotification("$/progress", &ProgressParams{
+		Token: p.token,
+		Value: value,
+	})
+	if err != nil {
+		return
+	}
+	p.s.replier.ReplyMessage(n) // Progress is best-effort.
+}
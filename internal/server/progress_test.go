@@ -0,0 +1,134 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// progressRecorder records work done progress messages sent by the server.
+type progressRecorder struct {
+	mu        sync.Mutex
+	creates   []ProgressToken
+	kinds     []string
+	createErr error
+}
+
+// newProgressServer creates a server that supports work done progress and
+// records progress messages with the returned recorder.
+func newProgressServer(files map[string][]byte) (*Server, *progressRecorder) {
+	var (
+		s *Server
+		r = &progressRecorder{}
+	)
+	s = New(newMapFSWithoutModTime(files), messageReplierFunc(func(m jsonrpc2.Message) error {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		switch m := m.(type) {
+		case *jsonrpc2.Call:
+			if m.Method() != "window/workDoneProgress/create" {
+				return nil
+			}
+			var params WorkDoneProgressCreateParams
+			if err := UnmarshalJSON(m.Params(), &params); err != nil {
+				return err
+			}
+			r.creates = append(r.creates, params.Token)
+			resp, err := jsonrpc2.NewResponse(m.ID(), nil, r.createErr)
+			if err != nil {
+				return err
+			}
+			go s.HandleMessage(resp)
+		case *jsonrpc2.Notification:
+			if m.Method() != "$/progress" {
+				return nil
+			}
+			var params struct {
+				Token ProgressToken `json:"token"`
+				Value struct {
+					Kind string `json:"kind"`
+				} `json:"value"`
+			}
+			if err := UnmarshalJSON(m.Params(), &params); err != nil {
+				return err
+			}
+			r.kinds = append(r.kinds, params.Value.Kind)
+		}
+		return nil
+	}))
+	s.clientCapabilities.Store(&ClientCapabilities{
+		Window: WindowClientCapabilities{WorkDoneProgress: true},
+	})
+	return s, r
+}
+
+func TestServerCompileProgress(t *testing.T) {
+	files := map[string][]byte{
+		"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx":                       []byte(``),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{}`),
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s, r := newProgressServer(files)
+		_, err := s.compile()
+		require.NoError(t, err)
+		require.Len(t, r.creates, 1)
+		assert.Equal(t, []string{"begin", "report", "report", "report", "report", "end"}, r.kinds)
+
+		// Cached compilations report nothing.
+		_, err = s.compile()
+		require.NoError(t, err)
+		assert.Len(t, r.creates, 1)
+		assert.Len(t, r.kinds, 6)
+	})
+
+	t.Run("WorkDoneProgressUnsupported", func(t *testing.T) {
+		s, r := newProgressServer(files)
+		s.clientCapabilities.Store(&ClientCapabilities{})
+		_, err := s.compile()
+		require.NoError(t, err)
+		assert.Empty(t, r.creates)
+		assert.Empty(t, r.kinds)
+	})
+
+	t.Run("CreateFailed", func(t *testing.T) {
+		s, r := newProgressServer(files)
+		r.createErr = errors.New("not supported")
+		_, err := s.compile()
+		require.NoError(t, err)
+		assert.Len(t, r.creates, 1)
+		assert.Empty(t, r.kinds)
+	})
+}
+
+func TestServerSpxRenameResourcesProgress(t *testing.T) {
+	s, r := newProgressServer(map[string][]byte{
+		"main.spx": []byte(`
+play "Sound1"
+run "assets", {Title: "My Game"}
+`),
+		"assets/index.json":               []byte(`{}`),
+		"assets/sounds/Sound1/index.json": []byte(`{"path":"sound1.wav"}`),
+	})
+	result, err := s.compile()
+	require.NoError(t, err)
+	r.kinds = nil
+
+	_, err = s.spxRenameResourcesWithCompileResult(result, []SpxRenameResourceParams{
+		{
+			Resource: SpxResourceIdentifier{URI: "spx://resources/sounds/Sound1"},
+			NewName:  "Sound2",
+		},
+	}, "client-token")
+	require.NoError(t, err)
+	assert.Len(t, r.creates, 1, "client-provided token should not be created")
+	assert.Equal(t, []string{"begin", "report", "end"}, r.kinds)
+}
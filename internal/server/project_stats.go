@@ -0,0 +1,119 @@
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"go/types"
+	"io/fs"
+	"maps"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// spxGetProjectStats gets the statistics of the project in the workspace,
+// e.g., for teacher dashboards and grading rubrics.
+func (s *Server) spxGetProjectStats() (*SpxProjectStats, error) {
+	result, err := s.compile()
+	if err != nil {
+		return nil, err
+	}
+	snapshot := s.workspaceFolderSnapshot(s.defaultWorkspaceFolder())
+
+	stats := &SpxProjectStats{
+		Sprites:       len(result.mainPkgSpriteTypes),
+		EventHandlers: []SpxEventHandlerCount{},
+		Files:         make([]SpxFileStats, 0, len(result.mainASTPkg.Files)),
+		Handlers:      []SpxEventHandlerStats{},
+	}
+	handlerCounts := make(map[string]int)
+	for _, spxFile := range slices.Sorted(maps.Keys(result.mainASTPkg.Files)) {
+		astFile := result.mainASTPkg.Files[spxFile]
+		content, err := fs.ReadFile(snapshot, spxFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spx source file: %w", err)
+		}
+		fileStats := SpxFileStats{
+			URI:   s.toDocumentURI(spxFile),
+			Lines: linesOfCode(content),
+		}
+
+		gopast.Inspect(astFile, func(node gopast.Node) bool {
+			switch node := node.(type) {
+			case *gopast.CallExpr:
+				funcIdent, ok := node.Fun.(*gopast.Ident)
+				if !ok || result.spxEventHandlerCallExprFor(astFile, funcIdent) != node {
+					return true
+				}
+				fileStats.Handlers++
+				handlerCounts[funcIdent.Name]++
+				stats.Handlers = append(stats.Handlers, SpxEventHandlerStats{
+					Handler:    result.callHierarchyItemForSpxEventHandler(node),
+					Complexity: cyclomaticComplexityOf(node),
+				})
+			case *gopast.FuncDecl:
+				if node.Shadow {
+					return true
+				}
+				if _, ok := result.typeInfo.Defs[node.Name].(*types.Func); ok {
+					fileStats.Functions++
+				}
+			}
+			return true
+		})
+		stats.CustomFunctions += fileStats.Functions
+		stats.Files = append(stats.Files, fileStats)
+	}
+	for _, event := range slices.Sorted(maps.Keys(handlerCounts)) {
+		stats.EventHandlers = append(stats.EventHandlers, SpxEventHandlerCount{
+			Event: event,
+			Count: handlerCounts[event],
+		})
+	}
+	slices.SortStableFunc(stats.Handlers, func(a, b SpxEventHandlerStats) int {
+		return cmp.Compare(b.Complexity, a.Complexity)
+	})
+	return stats, nil
+}
+
+// linesOfCode returns the number of lines of the given source that are
+// neither blank nor line comments.
+func linesOfCode(content []byte) int {
+	var n int
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && !bytes.HasPrefix(line, []byte("//")) {
+			n++
+		}
+	}
+	return n
+}
+
+// cyclomaticComplexityOf returns an approximate cyclomatic complexity of the
+// given node, i.e., one plus the number of its branches, loops, non-default
+// cases and short-circuit operators.
+func cyclomaticComplexityOf(node gopast.Node) int {
+	complexity := 1
+	gopast.Inspect(node, func(node gopast.Node) bool {
+		switch node := node.(type) {
+		case *gopast.IfStmt, *gopast.ForStmt, *gopast.RangeStmt, *gopast.ForPhraseStmt, *gopast.ComprehensionExpr:
+			complexity++
+		case *gopast.CaseClause:
+			if node.List != nil {
+				complexity++
+			}
+		case *gopast.CommClause:
+			if node.Comm != nil {
+				complexity++
+			}
+		case *gopast.BinaryExpr:
+			if node.Op == goptoken.LAND || node.Op == goptoken.LOR {
+				complexity++
+			}
+		}
+		return true
+	})
+	return complexity
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSpxGetProjectStats(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+
+// Score returns the score.
+func score() int {
+	return 1
+}
+
+onStart => {
+	if score() > 0 && score() < 10 {
+		broadcast "start"
+	}
+}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onClick => {
+	for i := 0; i < 3; i++ {
+		switch i {
+		case 0:
+			say "zero"
+		default:
+			say "other"
+		}
+	}
+}
+onStart => {
+	say "hi"
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		stats, err := s.spxGetProjectStats()
+		require.NoError(t, err)
+		require.NotNil(t, stats)
+		assert.Equal(t, 1, stats.Sprites)
+		assert.Equal(t, 1, stats.CustomFunctions)
+		assert.Equal(t, []SpxEventHandlerCount{
+			{Event: "onClick", Count: 1},
+			{Event: "onStart", Count: 2},
+		}, stats.EventHandlers)
+		assert.Equal(t, []SpxFileStats{
+			{URI: "file:///MySprite.spx", Lines: 13, Handlers: 2},
+			{URI: "file:///main.spx", Lines: 12, Handlers: 1, Functions: 1},
+		}, stats.Files)
+
+		require.Len(t, stats.Handlers, 3)
+		assert.Equal(t, "onClick", stats.Handlers[0].Handler.Name)
+		assert.Equal(t, 3, stats.Handlers[0].Complexity)
+		assert.Equal(t, "onStart", stats.Handlers[1].Handler.Name)
+		assert.Equal(t, DocumentURI("file:///main.spx"), stats.Handlers[1].Handler.URI)
+		assert.Equal(t, 3, stats.Handlers[1].Complexity)
+		assert.Equal(t, "onStart", stats.Handlers[2].Handler.Name)
+		assert.Equal(t, DocumentURI("file:///MySprite.spx"), stats.Handlers[2].Handler.URI)
+		assert.Equal(t, 1, stats.Handlers[2].Complexity)
+	})
+
+	t.Run("NoMainSpxFile", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+
+		stats, err := s.spxGetProjectStats()
+		require.ErrorIs(t, err, errNoMainSpxFile)
+		assert.Nil(t, stats)
+	})
+}
@@ -29,6 +29,11 @@ type SpxRenameResourceParams struct {
 	Resource SpxResourceIdentifier `json:"resource"`
 	// The new name of the spx resource.
 	NewName string `json:"newName"`
+	// Whether to also rename the files of the spx resource, e.g.,
+	// "assets/sprites/OldName" to "assets/sprites/NewName". If set, the
+	// resulting [WorkspaceEdit] describes the modification with
+	// [WorkspaceEdit.DocumentChanges] instead of [WorkspaceEdit.Changes].
+	RenameFiles bool `json:"renameFiles,omitempty"`
 }
 
 // SpxResourceIdentifier identifies an spx resource.
@@ -45,11 +50,346 @@ func (u SpxResourceURI) HTML() string {
 	return fmt.Sprintf("<resource-preview resource=%s />\n", attr(string(u)))
 }
 
+// SpxGetResourceReferencesParams represents parameters to get the references
+// to an spx resource in the workspace.
+type SpxGetResourceReferencesParams struct {
+	// The spx resource.
+	Resource SpxResourceIdentifier `json:"resource"`
+}
+
+// SpxResourceReference represents a reference to an spx resource.
+type SpxResourceReference struct {
+	// The kind of the spx resource reference.
+	Kind SpxResourceRefKind `json:"kind"`
+	// The location of the spx resource reference.
+	Location Location `json:"location"`
+}
+
+// SpxResourceUsage represents the usage of an spx resource in the workspace.
+type SpxResourceUsage struct {
+	// The spx resource.
+	Resource SpxResourceIdentifier `json:"resource"`
+	// The number of references to the resource.
+	Count int `json:"count"`
+	// The references to the resource grouped by file, sorted by URI.
+	Files []SpxResourceUsageFile `json:"files"`
+}
+
+// SpxResourceUsageFile represents the references to an spx resource in a file.
+type SpxResourceUsageFile struct {
+	// The URI of the file.
+	URI DocumentURI `json:"uri"`
+	// The references in the file, sorted by position.
+	References []SpxResourceUsageReference `json:"references"`
+}
+
+// SpxResourceUsageReference represents a reference to an spx resource in a
+// file.
+type SpxResourceUsageReference struct {
+	// The kind of the spx resource reference.
+	Kind SpxResourceRefKind `json:"kind"`
+	// The name of the spx function called with the reference as an argument,
+	// or of the spx method called on it, e.g., "play" or "setCostume", or
+	// omitted if there is none.
+	Call string `json:"call,omitempty"`
+	// The range of the reference in the file.
+	Range Range `json:"range"`
+}
+
+// SpxCheckResourceDeletionParams represents parameters to check whether
+// deleting an spx resource would break code in the workspace.
+type SpxCheckResourceDeletionParams struct {
+	// The spx resource.
+	Resource SpxResourceIdentifier `json:"resource"`
+}
+
+// SpxResourceDeletionCheck represents the result of checking whether deleting
+// an spx resource would break code in the workspace.
+type SpxResourceDeletionCheck struct {
+	// Whether the spx resource can be deleted without breaking code.
+	Safe bool `json:"safe"`
+	// The references that would break, including references to nested
+	// resources such as costumes of a sprite.
+	References []SpxResourceReference `json:"references"`
+}
+
+// SpxGetInputSlotsParams represents parameters to get the input slots in a
+// range of a document.
+type SpxGetInputSlotsParams struct {
+	// The text document.
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	// The range to get the input slots in.
+	Range Range `json:"range"`
+}
+
+// SpxInputSlot represents an input slot, i.e., an argument of a command-style
+// call that can be edited with a widget, e.g., "biu" in `play "biu"`.
+type SpxInputSlot struct {
+	// The kind of the input slot.
+	Kind SpxInputSlotKind `json:"kind"`
+	// The range of the input slot in the document.
+	Range Range `json:"range"`
+	// The current value of the input slot in source code form, e.g., `"biu"`.
+	Value string `json:"value"`
+	// The types accepted by the input slot, e.g., "float64" and "specialDir"
+	// for `turn Right`.
+	AcceptedTypes []string `json:"acceptedTypes"`
+	// The candidate values of the input slot in source code form, e.g.,
+	// `"biu"` or `KeyA`. It is empty if the input slot accepts any value of its
+	// accepted types.
+	Candidates []string `json:"candidates,omitempty"`
+}
+
+// SpxInputSlotKind is the kind of an [SpxInputSlot].
+type SpxInputSlotKind string
+
+const (
+	// SpxInputSlotKindLiteral is the kind of input slots holding basic
+	// literals, e.g., `10` in `step 10`.
+	SpxInputSlotKindLiteral SpxInputSlotKind = "literal"
+
+	// SpxInputSlotKindResourceName is the kind of input slots holding spx
+	// resource names, e.g., `"biu"` in `play "biu"`.
+	SpxInputSlotKindResourceName SpxInputSlotKind = "resourceName"
+
+	// SpxInputSlotKindConstant is the kind of input slots holding predefined
+	// constants, e.g., `Right` in `turn Right` and `KeyA` in `onKey KeyA, => {}`.
+	SpxInputSlotKindConstant SpxInputSlotKind = "constant"
+)
+
+// SpxDiagnosticsSummary represents a summary of the diagnostics in the
+// workspace.
+type SpxDiagnosticsSummary struct {
+	// The diagnostic counts of the whole workspace.
+	SpxDiagnosticCounts
+	// The summaries of documents with diagnostics, sorted by their URIs.
+	Documents []SpxDocumentDiagnosticsSummary `json:"documents"`
+	// The most frequent diagnostic codes in descending order of their counts.
+	TopCodes []SpxDiagnosticCodeCount `json:"topCodes"`
+}
+
+// SpxDiagnosticCounts represents the numbers of diagnostics by severity.
+type SpxDiagnosticCounts struct {
+	// The number of errors. Diagnostics without severity are counted as
+	// errors.
+	Errors int `json:"errors"`
+	// The number of warnings.
+	Warnings int `json:"warnings"`
+	// The number of information diagnostics.
+	Information int `json:"information"`
+	// The number of hints.
+	Hints int `json:"hints"`
+}
+
+// SpxDocumentDiagnosticsSummary represents a summary of the diagnostics in a
+// document.
+type SpxDocumentDiagnosticsSummary struct {
+	// The document's URI.
+	URI DocumentURI `json:"uri"`
+	SpxDiagnosticCounts
+}
+
+// SpxDiagnosticCodeCount represents the number of diagnostics with a code.
+type SpxDiagnosticCodeCount struct {
+	// The diagnostic code, e.g., "typeError".
+	Code string `json:"code"`
+	// The number of diagnostics with the code.
+	Count int `json:"count"`
+}
+
+// SpxMessageGraph represents the graph of spx messages in the workspace.
+type SpxMessageGraph struct {
+	// The messages sorted by name.
+	Messages []SpxMessageGraphNode `json:"messages"`
+}
+
+// SpxMessageGraphNode represents an spx message with its broadcasts and
+// listeners. A message without listeners is an orphan broadcast.
+type SpxMessageGraphNode struct {
+	// The message, e.g., "gameOver" in `broadcast "gameOver"`.
+	Message string `json:"message"`
+	// The broadcasts of the message.
+	Broadcasts []SpxMessageEndpoint `json:"broadcasts"`
+	// The onMsg handlers listening to the message.
+	Listeners []SpxMessageEndpoint `json:"listeners"`
+}
+
+// SpxMessageEndpoint represents a broadcast of an spx message, or an onMsg
+// handler listening to it.
+type SpxMessageEndpoint struct {
+	// The owner of the endpoint, i.e., the sprite name, or "Game" for the
+	// stage.
+	Owner string `json:"owner"`
+	// For broadcasts, the innermost event handler or function enclosing the
+	// broadcast, or omitted if there is none. For listeners, the onMsg
+	// handler itself.
+	Handler *CallHierarchyItem `json:"handler,omitempty"`
+	// The location of the message argument.
+	Location Location `json:"location"`
+}
+
+// SpxMemoryStats represents the estimated memory usage of the caches of the
+// server.
+type SpxMemoryStats struct {
+	// The memory budget of the caches in bytes, or 0 if they are unlimited.
+	Budget int64 `json:"budget"`
+	// The estimated memory used by all caches in bytes.
+	Total int64 `json:"total"`
+	// The number of cache entries evicted to fit in the budget.
+	Evictions int64 `json:"evictions"`
+	// The memory usage of each cache, sorted by name.
+	Caches []SpxCacheMemoryStats `json:"caches"`
+}
+
+// SpxCacheMemoryStats represents the estimated memory usage of a cache of the
+// server.
+type SpxCacheMemoryStats struct {
+	// The name of the cache, i.e., "compileResults" for the ASTs and type
+	// information of workspace folders, "packages" for packages loaded on
+	// demand, or "semanticTokens" for the semantic tokens of documents.
+	Name string `json:"name"`
+	// The number of entries in the cache.
+	Entries int `json:"entries"`
+	// The estimated memory used by the cache in bytes.
+	Bytes int64 `json:"bytes"`
+}
+
+// SpxProjectStats represents the statistics of the project in the workspace.
+type SpxProjectStats struct {
+	// The number of sprites.
+	Sprites int `json:"sprites"`
+	// The numbers of event handlers per event type, sorted by event type.
+	EventHandlers []SpxEventHandlerCount `json:"eventHandlers"`
+	// The statistics of the spx source files, sorted by URI.
+	Files []SpxFileStats `json:"files"`
+	// The number of functions declared in the project.
+	CustomFunctions int `json:"customFunctions"`
+	// The statistics of the event handlers, sorted by complexity in
+	// descending order.
+	Handlers []SpxEventHandlerStats `json:"handlers"`
+}
+
+// SpxEventHandlerCount represents the number of event handlers of an event
+// type.
+type SpxEventHandlerCount struct {
+	// The event type, i.e., the name of the function registering the
+	// handlers, e.g., "onStart".
+	Event string `json:"event"`
+	// The number of event handlers of the event type.
+	Count int `json:"count"`
+}
+
+// SpxFileStats represents the statistics of an spx source file.
+type SpxFileStats struct {
+	// The URI of the file.
+	URI DocumentURI `json:"uri"`
+	// The number of lines that are neither blank nor line comments.
+	Lines int `json:"lines"`
+	// The number of event handlers registered in the file.
+	Handlers int `json:"handlers"`
+	// The number of functions declared in the file.
+	Functions int `json:"functions"`
+}
+
+// SpxEventHandlerStats represents the statistics of an event handler.
+type SpxEventHandlerStats struct {
+	// The event handler.
+	Handler CallHierarchyItem `json:"handler"`
+	// The approximate cyclomatic complexity of the handler, i.e., one plus the
+	// number of its branches, loops, non-default cases and short-circuit
+	// operators.
+	Complexity int `json:"complexity"`
+}
+
+// SpxUnusedResource represents an spx resource that is never referenced from
+// the code or other resource metadata.
+type SpxUnusedResource struct {
+	// The URI of the resource.
+	Resource SpxResourceURI `json:"resource"`
+	// The URI of the asset file of the resource, e.g., the image of a costume,
+	// or omitted if it is unknown.
+	File DocumentURI `json:"file,omitempty"`
+}
+
 // SpxGetDefinitionsParams represents parameters to get definitions at a
 // specific position in a document.
 type SpxGetDefinitionsParams struct {
 	// The text document position params.
 	TextDocumentPositionParams
+	// The kinds of definitions to get. If not empty, only definitions of any
+	// of the given kinds are returned.
+	Kinds []SpxDefinitionKind `json:"kinds,omitempty"`
+	// Whether to include the documentation of definitions.
+	IncludeDocumentation bool `json:"includeDocumentation,omitempty"`
+}
+
+// SpxGetDefinitionsPageParams represents parameters to get a page of
+// definitions at a specific position in a document.
+type SpxGetDefinitionsPageParams struct {
+	SpxGetDefinitionsParams
+	// The maximum number of definitions in the page. Zero means no limit.
+	Limit int `json:"limit,omitempty"`
+	// The cursor of the page, which is [SpxDefinitionsPage.NextCursor] of the
+	// previous page. If empty, the first page is returned.
+	Cursor string `json:"cursor,omitempty"`
+	// The result ID of a previous result. If the definitions are unchanged
+	// since then, an unchanged page without definitions is returned. It is
+	// ignored if Cursor is set.
+	PreviousResultID string `json:"previousResultId,omitempty"`
+}
+
+// SpxDefinitionsPage represents a page of definitions returned by
+// spx.getDefinitionsPage.
+type SpxDefinitionsPage struct {
+	// The kind of the page.
+	Kind SpxDefinitionsPageKind `json:"kind"`
+	// The result ID identifying all definitions across pages.
+	ResultID string `json:"resultId"`
+	// The definitions in the page. It is only set for full pages.
+	Items []SpxDefinitionInfo `json:"items,omitempty"`
+	// The cursor of the next page, or empty if this is the last page.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// SpxDefinitionsPageKind is the kind of an [SpxDefinitionsPage].
+type SpxDefinitionsPageKind string
+
+const (
+	// SpxDefinitionsPageFull is the kind of pages containing definitions.
+	SpxDefinitionsPageFull SpxDefinitionsPageKind = "full"
+
+	// SpxDefinitionsPageUnchanged is the kind of pages indicating that the
+	// definitions are unchanged since the previous result.
+	SpxDefinitionsPageUnchanged SpxDefinitionsPageKind = "unchanged"
+)
+
+// SpxDefinitionKind is the kind of an spx definition for filtering in
+// [SpxGetDefinitionsParams].
+type SpxDefinitionKind string
+
+const (
+	// SpxDefinitionKindEventHandler is the kind of event handler
+	// definitions, e.g., `onStart`.
+	SpxDefinitionKindEventHandler SpxDefinitionKind = "eventHandler"
+
+	// SpxDefinitionKindSpriteMethod is the kind of sprite method
+	// definitions, e.g., `Sprite.turn`.
+	SpxDefinitionKindSpriteMethod SpxDefinitionKind = "spriteMethod"
+
+	// SpxDefinitionKindUser is the kind of definitions in the main package.
+	SpxDefinitionKindUser SpxDefinitionKind = "user"
+)
+
+// SpxDefinitionInfo represents an spx definition returned by
+// spx.getDefinitions.
+type SpxDefinitionInfo struct {
+	SpxDefinitionIdentifier
+	// The overview of the definition, e.g., `func turn(degree float64)`. It
+	// is only set if documentation is requested.
+	Overview string `json:"overview,omitempty"`
+	// The detailed documentation of the definition. It is only set if
+	// documentation is requested.
+	Detail string `json:"detail,omitempty"`
 }
 
 // SpxDefinitionIdentifier identifies an spx definition.
@@ -98,6 +438,39 @@ type SpxResourceRefDocumentLinkData struct {
 	Kind SpxResourceRefKind `json:"kind"`
 }
 
+// CodeLensData represents data for a code lens that is resolved lazily in
+// codeLens/resolve.
+type CodeLensData struct {
+	// The kind of the code lens.
+	Kind CodeLensDataKind `json:"kind"`
+	// The document containing the code lens.
+	URI DocumentURI `json:"uri"`
+	// The start position of the code lens.
+	Position Position `json:"position"`
+	// The broadcast message, for [CodeLensDataKindBroadcast] only.
+	Message string `json:"message,omitempty"`
+}
+
+// CodeLensDataKind is the kind of a code lens.
+type CodeLensDataKind string
+
+const (
+	// CodeLensDataKindReferences is the kind of code lenses counting the
+	// references of a function.
+	CodeLensDataKindReferences CodeLensDataKind = "references"
+
+	// CodeLensDataKindBroadcast is the kind of code lenses counting the
+	// broadcasts triggering a message event handler.
+	CodeLensDataKindBroadcast CodeLensDataKind = "broadcast"
+)
+
+// AssetPathDocumentLinkData represents data for an asset path document link,
+// whose target is computed in documentLink/resolve.
+type AssetPathDocumentLinkData struct {
+	// The asset path relative to the workspace root.
+	Path string `json:"path"`
+}
+
 ////////////////////////////////////////////////////////////////////////////////
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification#documentUri
@@ -665,6 +1038,14 @@ type CodeAction struct {
 	Data *json.RawMessage `json:"data,omitempty"`
 }
 
+// CodeActionData represents data in a code action.
+type CodeActionData struct {
+	// The URI of the document the code action applies to.
+	URI DocumentURI `json:"uri"`
+	// The data of the diagnostic the code action resolves.
+	Diagnostic DiagnosticData `json:"diagnostic"`
+}
+
 // The Client Capabilities of a {@link CodeActionRequest}.
 //
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.17/specification#codeActionClientCapabilities
@@ -1150,6 +1531,13 @@ type CompletionItem struct {
 type CompletionItemData struct {
 	// The corresponding definition of the completion item.
 	Definition *SpxDefinitionIdentifier `json:"definition,omitempty"`
+
+	// The result ID of the completion list containing the completion item.
+	// It is used to resolve the completion item lazily.
+	ResultID string `json:"resultId,omitempty"`
+
+	// The index of the completion item in the completion list.
+	Index int `json:"index,omitempty"`
 }
 
 // In many cases the items of an actual completion result share the same
@@ -1549,6 +1937,76 @@ type Diagnostic struct {
 	Data *json.RawMessage `json:"data,omitempty"`
 }
 
+// DiagnosticData represents data in a diagnostic.
+type DiagnosticData struct {
+	// The kind of quick fix available for the diagnostic.
+	Fix DiagnosticFixKind `json:"fix"`
+	// The name of the identifier or the import path the quick fix applies to.
+	// For [DiagnosticFixCreateResource], it is the URI of the spx resource.
+	// For [DiagnosticFixUpdateMapSize], it is the key of the map dimension in
+	// index.json, i.e., "width" or "height".
+	Name string `json:"name"`
+	// The replacement of the diagnostic range suggested as a spelling fix,
+	// e.g., `"biu"` for the misspelled sound name `"bui"`, or as a fix of a
+	// mismatch, e.g., `480` for a window width exceeding the map width.
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// DiagnosticFixKind represents the kind of quick fix for a diagnostic.
+type DiagnosticFixKind string
+
+const (
+	// DiagnosticFixDeclareVar declares a missing variable.
+	DiagnosticFixDeclareVar DiagnosticFixKind = "declareVar"
+	// DiagnosticFixRemoveUnusedImport removes an unused import.
+	DiagnosticFixRemoveUnusedImport DiagnosticFixKind = "removeUnusedImport"
+	// DiagnosticFixCreateResource creates a stub of a missing spx resource.
+	DiagnosticFixCreateResource DiagnosticFixKind = "createResource"
+	// DiagnosticFixRemoveDeclaration removes the declaration of an unused
+	// symbol.
+	DiagnosticFixRemoveDeclaration DiagnosticFixKind = "removeDeclaration"
+	// DiagnosticFixUpdateMapSize updates the map size in index.json to the
+	// window size in the game config passed to run.
+	DiagnosticFixUpdateMapSize DiagnosticFixKind = "updateMapSize"
+	// DiagnosticFixRemoveZorderEntry removes a sprite from the zorder in
+	// index.json.
+	DiagnosticFixRemoveZorderEntry DiagnosticFixKind = "removeZorderEntry"
+)
+
+// Codes of diagnostics reported by the server. They are stable, and are also
+// the keys of diagnostic severity overrides in [Config], where their names,
+// e.g., "resourceNotFound", can be used instead.
+const (
+	DiagnosticCodeResourceNotFound         = "spx0001"
+	DiagnosticCodeUnhandledBroadcast       = "spx0002"
+	DiagnosticCodeParseError               = "spx0003"
+	DiagnosticCodeInvalidPackageName       = "spx0004"
+	DiagnosticCodeTypeError                = "spx0005"
+	DiagnosticCodeInvalidRunArgument       = "spx0006"
+	DiagnosticCodeInvalidResourceSet       = "spx0007"
+	DiagnosticCodeUnusedImport             = "spx0008"
+	DiagnosticCodeInvalidAutoBinding       = "spx0009"
+	DiagnosticCodeEmptyResourceName        = "spx0010"
+	DiagnosticCodeUnusedMessageHandler     = "spx0011"
+	DiagnosticCodeUnusedSymbol             = "spx0012"
+	DiagnosticCodeUnreachableCode          = "spx0013"
+	DiagnosticCodeMissingReturn            = "spx0014"
+	DiagnosticCodeShadowedVariable         = "spx0015"
+	DiagnosticCodeLoopVariableCapture      = "spx0016"
+	DiagnosticCodeUndefinedName            = "spx0017"
+	DiagnosticCodeTypeMismatch             = "spx0018"
+	DiagnosticCodeTooManyArguments         = "spx0019"
+	DiagnosticCodeNotEnoughArguments       = "spx0020"
+	DiagnosticCodeMismatchedTypes          = "spx0021"
+	DiagnosticCodeUnknownMember            = "spx0022"
+	DiagnosticCodeNotCallable              = "spx0023"
+	DiagnosticCodeMissingSpriteAutoBinding = "spx0024"
+	DiagnosticCodeInvalidModule            = "spx0025"
+	DiagnosticCodeMapSizeMismatch          = "spx0026"
+	DiagnosticCodeZorderSpriteNotFound     = "spx0027"
+	DiagnosticCodeInvalidResourceMetadata  = "spx0028"
+)
+
 // Client capabilities specific to diagnostic pull requests.
 //
 // @since 3.17.0
@@ -3745,7 +4203,7 @@ type OptionalVersionedTextDocumentIdentifier struct {
 	// (the server has not received an open notification before) the server can send
 	// `null` to indicate that the version is unknown and the content on disk is the
 	// truth (as specified with document content ownership).
-	Version int32 `json:"version"`
+	Version *int32 `json:"version"`
 	TextDocumentIdentifier
 }
 
@@ -8,6 +8,10 @@ import (
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_references
 func (s *Server) textDocumentReferences(params *ReferenceParams) ([]Location, error) {
+	if file, ok := s.spxResourceMetadataFileOf(params.TextDocument.URI); ok {
+		return spxResourceMetadataReferences(file, params), nil
+	}
+
 	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
 	if err != nil {
 		return nil, err
@@ -43,7 +47,34 @@ func (s *Server) textDocumentReferences(params *ReferenceParams) ([]Location, er
 		}
 	}
 
-	return deduplicateLocations(locations), nil
+	locations = deduplicateLocations(locations)
+	sortLocations(locations)
+	return locations, nil
+}
+
+// spxResourceMetadataReferences returns the code locations that reference the
+// spx resource whose metadata is at the given position in the given metadata
+// file of spx resources.
+func spxResourceMetadataReferences(file *spxResourceMetadataFile, params *ReferenceParams) []Location {
+	id := file.spxResourceIDAt(params.Position)
+	if id == nil {
+		return nil
+	}
+	result := file.result
+
+	var locations []Location
+	for _, ref := range result.spxResourceRefsFor(id) {
+		locations = append(locations, result.locationForNode(ref.Node))
+	}
+	if params.Context.IncludeDeclaration {
+		if location, ok := result.spxResourceMetadataLocation(id); ok {
+			locations = append(locations, location)
+		}
+	}
+
+	locations = deduplicateLocations(locations)
+	sortLocations(locations)
+	return locations
 }
 
 // findReferenceLocations returns all locations where the given object is referenced.
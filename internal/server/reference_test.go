@@ -88,6 +88,130 @@ onStart => {
 		})
 	})
 
+	t.Run("GameFieldAcrossFiles", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	Score int
+)
+Score = 1
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	Score++
+	println Score
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		refsWithDecl, err := s.textDocumentReferences(&ReferenceParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 3, Character: 10},
+			},
+			Context: ReferenceContext{
+				IncludeDeclaration: true,
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []Location{
+			{
+				URI: "file:///MySprite.spx",
+				Range: Range{
+					Start: Position{Line: 2, Character: 1},
+					End:   Position{Line: 2, Character: 6},
+				},
+			},
+			{
+				URI: "file:///MySprite.spx",
+				Range: Range{
+					Start: Position{Line: 3, Character: 9},
+					End:   Position{Line: 3, Character: 14},
+				},
+			},
+			{
+				URI: "file:///main.spx",
+				Range: Range{
+					Start: Position{Line: 2, Character: 1},
+					End:   Position{Line: 2, Character: 6},
+				},
+			},
+			{
+				URI: "file:///main.spx",
+				Range: Range{
+					Start: Position{Line: 4, Character: 0},
+					End:   Position{Line: 4, Character: 5},
+				},
+			},
+		}, refsWithDecl)
+
+		refsWithoutDecl, err := s.textDocumentReferences(&ReferenceParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+				Position:     Position{Line: 3, Character: 10},
+			},
+			Context: ReferenceContext{
+				IncludeDeclaration: false,
+			},
+		})
+		require.NoError(t, err)
+		require.Len(t, refsWithoutDecl, 3)
+		assert.NotContains(t, refsWithoutDecl, refsWithDecl[2])
+	})
+
+	t.Run("CustomFunc", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+func reset() {
+}
+
+onStart => {
+	reset
+}
+reset
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		refs, err := s.textDocumentReferences(&ReferenceParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 5},
+			},
+			Context: ReferenceContext{
+				IncludeDeclaration: true,
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []Location{
+			{
+				URI: "file:///main.spx",
+				Range: Range{
+					Start: Position{Line: 1, Character: 5},
+					End:   Position{Line: 1, Character: 10},
+				},
+			},
+			{
+				URI: "file:///main.spx",
+				Range: Range{
+					Start: Position{Line: 5, Character: 1},
+					End:   Position{Line: 5, Character: 6},
+				},
+			},
+			{
+				URI: "file:///main.spx",
+				Range: Range{
+					Start: Position{Line: 7, Character: 0},
+					End:   Position{Line: 7, Character: 5},
+				},
+			},
+		}, refs)
+	})
+
 	t.Run("InvalidPosition", func(t *testing.T) {
 		s := New(newMapFSWithoutModTime(map[string][]byte{
 			"main.spx": []byte(`var x int`),
@@ -110,4 +234,79 @@ onStart => {
 			},
 		})
 	})
+
+	t.Run("SpxResourceMetadata", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+onStart => {
+	play "explosion"
+	MySprite.setCostume "costume2"
+}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	setCostume "costume2"
+	play "explosion"
+}
+`),
+			"assets/index.json":                  []byte(`{"zorder": ["MySprite"]}`),
+			"assets/sounds/explosion/index.json": []byte(`{"path": "explosion.wav"}`),
+			"assets/sprites/MySprite/index.json": []byte(`{"costumes": [{"name": "costume1"}, {"name": "costume2"}]}`),
+		}), nil)
+		referencesAt := func(t *testing.T, uri DocumentURI, position Position, includeDeclaration bool) []Location {
+			locations, err := s.textDocumentReferences(&ReferenceParams{
+				TextDocumentPositionParams: TextDocumentPositionParams{
+					TextDocument: TextDocumentIdentifier{URI: uri},
+					Position:     position,
+				},
+				Context: ReferenceContext{IncludeDeclaration: includeDeclaration},
+			})
+			require.NoError(t, err)
+			return locations
+		}
+
+		assert.Equal(t, []Location{
+			{
+				URI: "file:///MySprite.spx",
+				Range: Range{
+					Start: Position{Line: 2, Character: 12},
+					End:   Position{Line: 2, Character: 22},
+				},
+			},
+			{
+				URI: "file:///main.spx",
+				Range: Range{
+					Start: Position{Line: 6, Character: 21},
+					End:   Position{Line: 6, Character: 31},
+				},
+			},
+		}, referencesAt(t, "file:///assets/sprites/MySprite/index.json", Position{Line: 0, Character: 48}, false))
+
+		assert.Equal(t, []Location{
+			{
+				URI: "file:///MySprite.spx",
+				Range: Range{
+					Start: Position{Line: 3, Character: 6},
+					End:   Position{Line: 3, Character: 17},
+				},
+			},
+			{
+				URI: "file:///assets/sounds/explosion/index.json",
+			},
+			{
+				URI: "file:///main.spx",
+				Range: Range{
+					Start: Position{Line: 5, Character: 6},
+					End:   Position{Line: 5, Character: 17},
+				},
+			},
+		}, referencesAt(t, "file:///assets/sounds/explosion/index.json", Position{Line: 0, Character: 3}, true))
+
+		assert.Empty(t, referencesAt(t, "file:///assets/sprites/MySprite/index.json", Position{Line: 0, Character: 28}, false))
+		assert.Empty(t, referencesAt(t, "file:///assets/index.json", Position{Line: 0, Character: 3}, false))
+	})
 }
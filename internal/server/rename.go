@@ -1,16 +1,21 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"go/types"
+	"maps"
+	"path"
 	"slices"
+	"strconv"
 
 	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
 	"github.com/goplus/goxlsw/internal/util"
 )
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_prepareRename
-func (s *Server) textDocumentPrepareRename(params *PrepareRenameParams) (*Range, error) {
+func (s *Server) textDocumentPrepareRename(params *PrepareRenameParams) (*PrepareRenameResult, error) {
 	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
 	if err != nil {
 		return nil, err
@@ -20,20 +25,84 @@ func (s *Server) textDocumentPrepareRename(params *PrepareRenameParams) (*Range,
 	}
 	position := result.toPosition(astFile, params.Position)
 
+	if spxResourceRef := result.spxResourceRefAtASTFilePosition(astFile, position); spxResourceRef != nil {
+		if lit, ok := spxResourceRef.Node.(*gopast.BasicLit); ok && lit.Kind == goptoken.STRING {
+			if name, err := strconv.Unquote(lit.Value); err == nil {
+				// Exclude quotes from the range.
+				litRange := result.rangeForNode(lit)
+				litRange.Start.Character++
+				litRange.End.Character--
+				return &PrepareRenameResult{
+					Range:       litRange,
+					Placeholder: name,
+				}, nil
+			}
+		}
+	}
+
+	if event := result.spxMessageEventAtASTFilePosition(astFile, position); event != nil {
+		// Exclude quotes from the range.
+		litRange := result.rangeForNode(event.messageExpr)
+		litRange.Start.Character++
+		litRange.End.Character--
+		return &PrepareRenameResult{
+			Range:       litRange,
+			Placeholder: event.message,
+		}, nil
+	}
+
 	ident := result.identAtASTFilePosition(astFile, position)
 	if ident == nil {
+		if word := wordAtASTFilePosition(astFile, position); goptoken.Lookup(word).IsKeyword() {
+			return nil, fmt.Errorf("cannot rename keyword %q", word)
+		}
 		return nil, nil
 	}
 	obj := result.typeInfo.ObjectOf(ident)
+	if obj == nil {
+		return nil, fmt.Errorf("cannot rename %q: no definition found", ident.Name)
+	}
+	if isBuiltinObject(obj) {
+		return nil, fmt.Errorf("cannot rename builtin %q", ident.Name)
+	}
+	if isSpxPkgObject(obj) {
+		return nil, fmt.Errorf("cannot rename spx builtin %q", ident.Name)
+	}
+	if !isMainPkgObject(obj) {
+		return nil, fmt.Errorf("cannot rename %q: it is defined in package %q", ident.Name, obj.Pkg().Path())
+	}
 	if !isRenameableObject(obj) {
-		return nil, nil
+		return nil, fmt.Errorf("cannot rename %q", ident.Name)
 	}
 	defIdent := result.defIdentFor(obj)
 	if defIdent == nil || !result.isInFset(defIdent.Pos()) {
-		return nil, nil
+		return nil, fmt.Errorf("cannot rename %q: it is generated automatically", ident.Name)
 	}
 
-	return util.ToPtr(result.rangeForNode(ident)), nil
+	return &PrepareRenameResult{
+		Range:       result.rangeForNode(ident),
+		Placeholder: ident.Name,
+	}, nil
+}
+
+// wordAtASTFilePosition returns the word consisting of letters, digits and
+// underscores at the given position in the given AST file.
+func wordAtASTFilePosition(astFile *gopast.File, position goptoken.Position) string {
+	isWordByte := func(b byte) bool {
+		return b == '_' || '0' <= b && b <= '9' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z'
+	}
+	code := astFile.Code
+	if position.Offset < 0 || position.Offset > len(code) {
+		return ""
+	}
+	start, end := position.Offset, position.Offset
+	for start > 0 && isWordByte(code[start-1]) {
+		start--
+	}
+	for end < len(code) && isWordByte(code[end]) {
+		end++
+	}
+	return string(code[start:end])
 }
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_rename
@@ -53,7 +122,11 @@ func (s *Server) textDocumentRename(params *RenameParams) (*WorkspaceEdit, error
 				URI: spxResourceRef.ID.URI(),
 			},
 			NewName: params.NewName,
-		}})
+		}}, params.WorkDoneToken)
+	}
+
+	if event := result.spxMessageEventAtASTFilePosition(astFile, position); event != nil {
+		return result.spxRenameMessage(event.message, params.NewName)
 	}
 
 	obj := result.typeInfo.ObjectOf(result.identAtASTFilePosition(astFile, position))
@@ -64,6 +137,9 @@ func (s *Server) textDocumentRename(params *RenameParams) (*WorkspaceEdit, error
 	if defIdent == nil || !result.isInFset(defIdent.Pos()) {
 		return nil, fmt.Errorf("failed to find definition of object %q", obj.Name())
 	}
+	if err := checkRenameConflict(result, obj, params.NewName); err != nil {
+		return nil, err
+	}
 
 	defLoc := result.locationForNode(defIdent)
 
@@ -86,6 +162,206 @@ func (s *Server) textDocumentRename(params *RenameParams) (*WorkspaceEdit, error
 	return &workspaceEdit, nil
 }
 
+// spxRenameMessage renames the spx message in every broadcast and onMsg
+// handler using it across the workspace. It fails if any of them refers to the
+// message through a constant, as renaming only the string literals would
+// silently disconnect them.
+func (r *compileResult) spxRenameMessage(message, newMessage string) (*WorkspaceEdit, error) {
+	if newMessage == "" {
+		return nil, errors.New("message cannot be empty")
+	}
+
+	broadcasts, listeners := r.spxMessageEvents()
+	workspaceEdit := WorkspaceEdit{Changes: make(map[DocumentURI][]TextEdit)}
+	for _, event := range slices.Concat(broadcasts, listeners) {
+		if event.message != message {
+			continue
+		}
+		if _, ok := event.messageExpr.(*gopast.BasicLit); !ok {
+			pos := r.fset.Position(event.messageExpr.Pos())
+			return nil, fmt.Errorf("cannot rename message %q: it is referred to through a constant at %s:%d:%d", message, event.spxFile, pos.Line, pos.Column)
+		}
+		documentURI := r.toDocumentURI(event.spxFile)
+		workspaceEdit.Changes[documentURI] = append(workspaceEdit.Changes[documentURI], TextEdit{
+			Range:   r.rangeForNode(event.messageExpr),
+			NewText: strconv.Quote(newMessage),
+		})
+	}
+	return &workspaceEdit, nil
+}
+
+// checkRenameConflict checks if the given object can be renamed to newName
+// without producing an invalid identifier, conflicting with an existing
+// object in the same scope, having any of its references shadowed by another
+// object named newName, or shadowing references to another object named
+// newName.
+func checkRenameConflict(result *compileResult, obj types.Object, newName string) error {
+	if newName == obj.Name() {
+		return nil
+	}
+	if !goptoken.IsIdentifier(newName) {
+		return fmt.Errorf("%q is not a valid identifier", newName)
+	}
+	if err := checkRenameDeclConflict(result, obj, newName); err != nil {
+		return err
+	}
+	if err := checkRenameShadowing(result, obj, newName); err != nil {
+		return err
+	}
+	return checkRenameCapture(result, obj, newName)
+}
+
+// checkRenameDeclConflict checks if renaming the given object to newName
+// conflicts with an existing object declared in the same scope or, for fields
+// and methods, in the same type.
+func checkRenameDeclConflict(result *compileResult, obj types.Object, newName string) error {
+	if scope := obj.Parent(); scope != nil {
+		if conflict := scope.Lookup(newName); conflict != nil {
+			return fmt.Errorf("%q already declared in this scope", newName)
+		}
+		return nil
+	}
+
+	// Fields and methods have no parent scope, so we have to look them up
+	// through their owner types.
+	mainPkgScope := result.mainPkg.Scope()
+	for _, name := range mainPkgScope.Names() {
+		typeName, ok := mainPkgScope.Lookup(name).(*types.TypeName)
+		if !ok {
+			continue
+		}
+		named, ok := typeName.Type().(*types.Named)
+		if !ok || !isNamedTypeOwnerOf(named, obj) {
+			continue
+		}
+		if conflict, _, _ := types.LookupFieldOrMethod(named, true, result.mainPkg, newName); conflict != nil {
+			return fmt.Errorf("%q already declared in %s", newName, named.Obj().Name())
+		}
+	}
+	return nil
+}
+
+// checkRenameShadowing checks if any reference to the given object would refer
+// to another object after renaming it to newName, e.g., a game var referred to
+// in a function declaring a local var named newName. Qualified references,
+// i.e., selectors and field keys of struct literals, are not resolved
+// lexically and thus never shadowed.
+func checkRenameShadowing(result *compileResult, obj types.Object, newName string) error {
+	for _, ident := range result.refIdentsFor(obj) {
+		if !result.isInFset(ident.Pos()) || isQualifiedIdent(result, ident, obj) {
+			continue
+		}
+		innermostScope := result.innermostScopeAt(ident.Pos())
+		if innermostScope == nil {
+			continue
+		}
+		scope, found := innermostScope.LookupParent(newName, ident.Pos())
+		if found == nil || found == obj || isScopeOrAncestor(scope, obj.Parent()) {
+			// Objects in the scope of the object or outer ones are shadowed
+			// by the renamed object itself.
+			continue
+		}
+		pos := result.fset.Position(ident.Pos())
+		return fmt.Errorf("%q would be shadowed by another declaration at %s:%d:%d", newName, result.posFilename(ident.Pos()), pos.Line, pos.Column)
+	}
+	return nil
+}
+
+// checkRenameCapture checks if any reference to another object named newName
+// would refer to the given object after renaming it, e.g., a constant referred
+// to in a function declaring a local var renamed to newName after it.
+func checkRenameCapture(result *compileResult, obj types.Object, newName string) error {
+	parent := obj.Parent()
+	if parent == nil {
+		return nil
+	}
+	isLocal := parent != result.mainPkg.Scope() && parent != types.Universe
+
+	var capturedPos goptoken.Pos
+	for ident, usedObj := range result.typeInfo.Uses {
+		if ident.Name != newName || usedObj == obj || !result.isInFset(ident.Pos()) {
+			continue
+		}
+		if isLocal && ident.Pos() <= obj.Pos() {
+			// Local objects are not in scope before their declarations.
+			continue
+		}
+		if capturedPos.IsValid() && ident.Pos() >= capturedPos {
+			continue
+		}
+		if isQualifiedIdent(result, ident, usedObj) {
+			continue
+		}
+		innermostScope := result.innermostScopeAt(ident.Pos())
+		if !isScopeOrAncestor(parent, innermostScope) {
+			continue
+		}
+		scope, found := innermostScope.LookupParent(newName, ident.Pos())
+		if found != usedObj || scope == parent || !isScopeOrAncestor(scope, parent) {
+			// Objects declared in scopes nested in the scope of the object
+			// still shadow the renamed object.
+			continue
+		}
+		capturedPos = ident.Pos()
+	}
+	if capturedPos.IsValid() {
+		pos := result.fset.Position(capturedPos)
+		return fmt.Errorf("%q would shadow another declaration referred to at %s:%d:%d", newName, result.posFilename(capturedPos), pos.Line, pos.Column)
+	}
+	return nil
+}
+
+// isQualifiedIdent reports whether the given identifier referring to the given
+// object is qualified, i.e., the selector of a selector expression or the key
+// of a struct literal referring to a field.
+func isQualifiedIdent(result *compileResult, ident *gopast.Ident, obj types.Object) bool {
+	astFile := result.nodeASTFile(ident)
+	if astFile == nil {
+		return false
+	}
+	path, _ := util.PathEnclosingInterval(astFile, ident.Pos(), ident.End())
+	if len(path) < 2 {
+		return false
+	}
+	switch parent := path[1].(type) {
+	case *gopast.SelectorExpr:
+		return parent.Sel == ident
+	case *gopast.KeyValueExpr:
+		field, ok := obj.(*types.Var)
+		return ok && field.IsField() && parent.Key == ident
+	}
+	return false
+}
+
+// isScopeOrAncestor reports whether scope is the given inner scope or one of
+// its ancestors. It reports false if inner is nil.
+func isScopeOrAncestor(scope, inner *types.Scope) bool {
+	for ; inner != nil; inner = inner.Parent() {
+		if inner == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// isNamedTypeOwnerOf reports whether the given field or method object is
+// directly declared by the named type.
+func isNamedTypeOwnerOf(named *types.Named, obj types.Object) bool {
+	for i := range named.NumMethods() {
+		if named.Method(i) == obj {
+			return true
+		}
+	}
+	if st, ok := named.Underlying().(*types.Struct); ok {
+		for i := range st.NumFields() {
+			if st.Field(i) == obj {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // spxRenameResourceAtRefs updates spx resource names at reference locations by
 // matching the spx resource ID.
 func (s *Server) spxRenameResourceAtRefs(result *compileResult, id SpxResourceID, newName string) map[DocumentURI][]TextEdit {
@@ -226,3 +502,38 @@ func (s *Server) spxRenameWidgetResource(result *compileResult, id SpxWidgetReso
 	}
 	return s.spxRenameResourceAtRefs(result, id, newName), nil
 }
+
+// spxRenameResourceFiles returns the file renames required to rename the spx
+// resource with the given ID. Sounds and sprites are stored in directories
+// named after them under the spx resource root directory, and sprites also
+// have their code files named after them. Other resources are not stored in
+// their own files, so they require no file renames.
+func (s *Server) spxRenameResourceFiles(result *compileResult, id SpxResourceID, newName string) []RenameFile {
+	renameFile := func(oldPath, newPath string) RenameFile {
+		return RenameFile{
+			Kind:   string(Rename),
+			OldURI: result.toDocumentURI(oldPath),
+			NewURI: result.toDocumentURI(newPath),
+		}
+	}
+
+	var renames []RenameFile
+	switch id := id.(type) {
+	case SpxSoundResourceID:
+		renames = append(renames, renameFile(
+			path.Join(result.spxResourceRootDir, "sounds", id.SoundName),
+			path.Join(result.spxResourceRootDir, "sounds", newName),
+		))
+	case SpxSpriteResourceID:
+		renames = append(renames, renameFile(
+			path.Join(result.spxResourceRootDir, "sprites", id.SpriteName),
+			path.Join(result.spxResourceRootDir, "sprites", newName),
+		))
+		for _, spxFile := range slices.Sorted(maps.Keys(result.mainASTPkg.Files)) {
+			if path.Base(spxFile) == id.SpriteName+".spx" {
+				renames = append(renames, renameFile(spxFile, path.Join(path.Dir(spxFile), newName+".spx")))
+			}
+		}
+	}
+	return renames
+}
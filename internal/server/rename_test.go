@@ -1,6 +1,7 @@
 package server
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -34,9 +35,12 @@ onStart => {
 		})
 		require.NoError(t, err)
 		require.NotNil(t, range1)
-		assert.Equal(t, Range{
-			Start: Position{Line: 2, Character: 1},
-			End:   Position{Line: 2, Character: 9},
+		assert.Equal(t, PrepareRenameResult{
+			Range: Range{
+				Start: Position{Line: 2, Character: 1},
+				End:   Position{Line: 2, Character: 9},
+			},
+			Placeholder: "MySprite",
 		}, *range1)
 
 		range2, err := s.textDocumentPrepareRename(&PrepareRenameParams{
@@ -47,9 +51,12 @@ onStart => {
 		})
 		require.NoError(t, err)
 		require.NotNil(t, range2)
-		assert.Equal(t, Range{
-			Start: Position{Line: 4, Character: 0},
-			End:   Position{Line: 4, Character: 8},
+		assert.Equal(t, PrepareRenameResult{
+			Range: Range{
+				Start: Position{Line: 4, Character: 0},
+				End:   Position{Line: 4, Character: 8},
+			},
+			Placeholder: "MySprite",
 		}, *range2)
 
 		range3, err := s.textDocumentPrepareRename(&PrepareRenameParams{
@@ -58,7 +65,7 @@ onStart => {
 				Position:     Position{Line: 2, Character: 10},
 			},
 		})
-		require.NoError(t, err)
+		require.EqualError(t, err, `cannot rename spx builtin "Sprite"`)
 		require.Nil(t, range3)
 	})
 
@@ -85,7 +92,7 @@ onClick => {
 				Position:     Position{Line: 2, Character: 5},
 			},
 		})
-		require.NoError(t, err)
+		require.EqualError(t, err, `cannot rename "this": it is generated automatically`)
 		require.Nil(t, range1)
 
 		range2, err := s.textDocumentPrepareRename(&PrepareRenameParams{
@@ -94,9 +101,119 @@ onClick => {
 				Position:     Position{Line: 2, Character: 5},
 			},
 		})
-		require.NoError(t, err)
+		require.EqualError(t, err, `cannot rename "this": it is generated automatically`)
 		require.Nil(t, range2)
 	})
+
+	t.Run("NonMainPkgObject", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+MySprite.turn Left
+println "hi"
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		result, err := s.textDocumentPrepareRename(&PrepareRenameParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 4, Character: 10},
+			},
+		})
+		require.EqualError(t, err, `cannot rename spx builtin "turn"`)
+		require.Nil(t, result)
+
+		result, err = s.textDocumentPrepareRename(&PrepareRenameParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 5, Character: 2},
+			},
+		})
+		require.EqualError(t, err, `cannot rename "println": it is defined in package "fmt"`)
+		require.Nil(t, result)
+	})
+
+	t.Run("Keyword", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		result, err := s.textDocumentPrepareRename(&PrepareRenameParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 1},
+			},
+		})
+		require.EqualError(t, err, `cannot rename keyword "var"`)
+		require.Nil(t, result)
+	})
+
+	t.Run("SpxResource", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+play "explosion"
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sounds/explosion/index.json": []byte(`{}`),
+		}), nil)
+
+		result, err := s.textDocumentPrepareRename(&PrepareRenameParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 8},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		assert.Equal(t, PrepareRenameResult{
+			Range: Range{
+				Start: Position{Line: 1, Character: 6},
+				End:   Position{Line: 1, Character: 15},
+			},
+			Placeholder: "explosion",
+		}, *result)
+	})
+
+	t.Run("SpxMessage", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+onStart => {
+	broadcast "game over"
+}
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		result, err := s.textDocumentPrepareRename(&PrepareRenameParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 2, Character: 14},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, result)
+		assert.Equal(t, PrepareRenameResult{
+			Range: Range{
+				Start: Position{Line: 2, Character: 12},
+				End:   Position{Line: 2, Character: 21},
+			},
+			Placeholder: "game over",
+		}, *result)
+	})
 }
 
 func TestServerTextDocumentRename(t *testing.T) {
@@ -288,6 +405,287 @@ onClick => {
 		require.EqualError(t, err, `failed to find definition of object "this"`)
 		require.Nil(t, mySpriteSpxWorkspaceEdit)
 	})
+
+	t.Run("GameField", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	Score int
+)
+Score = 1
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onStart => {
+	Score++
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		workspaceEdit, err := s.textDocumentRename(&RenameParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+			Position:     Position{Line: 2, Character: 1},
+			NewName:      "Points",
+		})
+		require.NoError(t, err)
+		require.NotNil(t, workspaceEdit)
+		require.NotNil(t, workspaceEdit.Changes)
+
+		mainSpxChanges := workspaceEdit.Changes["file:///main.spx"]
+		require.Len(t, mainSpxChanges, 2)
+		assert.Contains(t, mainSpxChanges, TextEdit{
+			Range: Range{
+				Start: Position{Line: 2, Character: 1},
+				End:   Position{Line: 2, Character: 6},
+			},
+			NewText: "Points",
+		})
+		assert.Contains(t, mainSpxChanges, TextEdit{
+			Range: Range{
+				Start: Position{Line: 4, Character: 0},
+				End:   Position{Line: 4, Character: 5},
+			},
+			NewText: "Points",
+		})
+
+		mySpriteSpxChanges := workspaceEdit.Changes["file:///MySprite.spx"]
+		require.Len(t, mySpriteSpxChanges, 1)
+		assert.Contains(t, mySpriteSpxChanges, TextEdit{
+			Range: Range{
+				Start: Position{Line: 2, Character: 1},
+				End:   Position{Line: 2, Character: 6},
+			},
+			NewText: "Points",
+		})
+	})
+
+	t.Run("InvalidName", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+const Foo = "bar"
+println Foo
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		for _, newName := range []string{"", "1Foo", "Foo Bar", "func"} {
+			workspaceEdit, err := s.textDocumentRename(&RenameParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 6},
+				NewName:      newName,
+			})
+			require.EqualError(t, err, fmt.Sprintf("%q is not a valid identifier", newName))
+			require.Nil(t, workspaceEdit)
+		}
+	})
+
+	t.Run("ConflictInScope", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+const Foo = "foo"
+const Bar = "bar"
+println Foo, Bar
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		workspaceEdit, err := s.textDocumentRename(&RenameParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 1, Character: 6},
+			NewName:      "Bar",
+		})
+		require.EqualError(t, err, `"Bar" already declared in this scope`)
+		require.Nil(t, workspaceEdit)
+	})
+
+	t.Run("ConflictWithGameField", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	Score int
+	Lives int
+)
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		workspaceEdit, err := s.textDocumentRename(&RenameParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 2, Character: 1},
+			NewName:      "Lives",
+		})
+		require.EqualError(t, err, `"Lives" already declared in Game`)
+		require.Nil(t, workspaceEdit)
+	})
+
+	t.Run("ShadowedReference", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	score int
+)
+func f() {
+	total := 1
+	score = total
+}
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		workspaceEdit, err := s.textDocumentRename(&RenameParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 2, Character: 1},
+			NewName:      "total",
+		})
+		require.EqualError(t, err, `"total" would be shadowed by another declaration at main.spx:7:2`)
+		require.Nil(t, workspaceEdit)
+
+		// Names not declared at any reference are fine.
+		workspaceEdit, err = s.textDocumentRename(&RenameParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 6, Character: 1},
+			NewName:      "count",
+		})
+		require.NoError(t, err)
+		require.NotNil(t, workspaceEdit)
+		assert.Len(t, workspaceEdit.Changes["file:///main.spx"], 2)
+	})
+
+	t.Run("ShadowedByOuterObject", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+const Total = 1
+func f() {
+	count := 2
+	println count
+}
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		// The renamed local var shadows the outer constant, which is fine.
+		workspaceEdit, err := s.textDocumentRename(&RenameParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 3, Character: 1},
+			NewName:      "Total",
+		})
+		require.NoError(t, err)
+		require.NotNil(t, workspaceEdit)
+		assert.Len(t, workspaceEdit.Changes["file:///main.spx"], 2)
+	})
+
+	t.Run("ShadowingOuterReference", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+const Total = 1
+func f() {
+	println Total
+	count := 2
+	println count, Total
+}
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		// The renamed local var would capture the later reference to the
+		// outer constant.
+		workspaceEdit, err := s.textDocumentRename(&RenameParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 4, Character: 1},
+			NewName:      "Total",
+		})
+		require.EqualError(t, err, `"Total" would shadow another declaration referred to at main.spx:6:17`)
+		require.Nil(t, workspaceEdit)
+	})
+
+	t.Run("SpxMessage", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+onStart => {
+	broadcast "game over"
+}
+onMsg "game over", => {
+	println "bye"
+}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onMsg "game over", => {
+	broadcast "restart"
+}
+`),
+			"assets/index.json":                  []byte(`{"zorder": ["MySprite"]}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		workspaceEdit, err := s.textDocumentRename(&RenameParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+			Position:     Position{Line: 1, Character: 8},
+			NewName:      "game ended",
+		})
+		require.NoError(t, err)
+		require.NotNil(t, workspaceEdit)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///MySprite.spx": {
+				{
+					Range: Range{
+						Start: Position{Line: 1, Character: 6},
+						End:   Position{Line: 1, Character: 17},
+					},
+					NewText: `"game ended"`,
+				},
+			},
+			"file:///main.spx": {
+				{
+					Range: Range{
+						Start: Position{Line: 5, Character: 11},
+						End:   Position{Line: 5, Character: 22},
+					},
+					NewText: `"game ended"`,
+				},
+				{
+					Range: Range{
+						Start: Position{Line: 7, Character: 6},
+						End:   Position{Line: 7, Character: 17},
+					},
+					NewText: `"game ended"`,
+				},
+			},
+		}, workspaceEdit.Changes)
+	})
+
+	t.Run("SpxMessageViaConstant", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+const GameOver = "game over"
+onStart => {
+	broadcast "game over"
+}
+onMsg GameOver, => {}
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		workspaceEdit, err := s.textDocumentRename(&RenameParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Position:     Position{Line: 3, Character: 14},
+			NewName:      "game ended",
+		})
+		require.EqualError(t, err, `cannot rename message "game over": it is referred to through a constant at main.spx:6:7`)
+		require.Nil(t, workspaceEdit)
+	})
 }
 
 func TestServerSpxRenameBackdropResource(t *testing.T) {
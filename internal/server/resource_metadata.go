@@ -0,0 +1,856 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/goplus/goxlsw/internal/util"
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// spxMetadataKind is the kind of a JSON value in the metadata files of spx
+// resources.
+type spxMetadataKind int
+
+const (
+	spxMetadataAny spxMetadataKind = iota
+	spxMetadataObject
+	spxMetadataArray
+	spxMetadataString
+	spxMetadataNumber
+	spxMetadataInteger
+	spxMetadataBoolean
+)
+
+// String implements [fmt.Stringer].
+func (k spxMetadataKind) String() string {
+	switch k {
+	case spxMetadataObject:
+		return "object"
+	case spxMetadataArray:
+		return "array"
+	case spxMetadataString:
+		return "string"
+	case spxMetadataNumber:
+		return "number"
+	case spxMetadataInteger:
+		return "integer"
+	case spxMetadataBoolean:
+		return "boolean"
+	}
+	return "any"
+}
+
+// article returns the kind prefixed with its indefinite article, e.g., "an
+// object".
+func (k spxMetadataKind) article() string {
+	switch k {
+	case spxMetadataObject, spxMetadataArray, spxMetadataInteger:
+		return "an " + k.String()
+	}
+	return "a " + k.String()
+}
+
+// spxMetadataSchema describes a JSON value in the metadata files of spx
+// resources, mirroring how spx decodes it.
+type spxMetadataSchema struct {
+	// kind is the kind of the value.
+	kind spxMetadataKind
+
+	// doc is the documentation of the value in Markdown.
+	doc string
+
+	// fields are the known fields of an object value.
+	fields map[string]*spxMetadataSchema
+
+	// values is the schema of the field values of an object value used as a
+	// map, e.g., animations keyed by their names.
+	values *spxMetadataSchema
+
+	// elem is the schema of the elements of an array value.
+	elem *spxMetadataSchema
+
+	// enum is the valid values of a string value, or nil if any string is
+	// valid.
+	enum []string
+}
+
+// spxCostumeMetadataSchema is the schema of costumes and backdrops.
+var spxCostumeMetadataSchema = &spxMetadataSchema{
+	kind: spxMetadataObject,
+	fields: map[string]*spxMetadataSchema{
+		"name":             {kind: spxMetadataString, doc: "The name of the costume, by which it is referenced in code."},
+		"path":             {kind: spxMetadataString, doc: "The path of the image file, relative to the directory of this file."},
+		"x":                {kind: spxMetadataNumber, doc: "The x coordinate of the rotation center in the image."},
+		"y":                {kind: spxMetadataNumber, doc: "The y coordinate of the rotation center in the image."},
+		"faceRight":        {kind: spxMetadataNumber, doc: "The direction the image faces, in degrees, when the costume faces right."},
+		"bitmapResolution": {kind: spxMetadataInteger, doc: "The resolution of the image, e.g., `2` for images drawn at twice the size."},
+	},
+}
+
+// spxAnimationMetadataSchema is the schema of sprite animations.
+var spxAnimationMetadataSchema = func() *spxMetadataSchema {
+	action := &spxMetadataSchema{
+		kind: spxMetadataObject,
+		fields: map[string]*spxMetadataSchema{
+			"play": {kind: spxMetadataString, doc: "The name of the sound to play."},
+			"costumes": {
+				kind: spxMetadataObject,
+				doc:  "The range of costumes to play as frames.",
+				fields: map[string]*spxMetadataSchema{
+					"from": {doc: "The name or index of the first costume."},
+					"to":   {doc: "The name or index of the last costume."},
+				},
+			},
+		},
+	}
+	return &spxMetadataSchema{
+		kind: spxMetadataObject,
+		fields: map[string]*spxMetadataSchema{
+			"duration":       {kind: spxMetadataNumber, doc: "The duration of the animation in seconds."},
+			"fps":            {kind: spxMetadataNumber, doc: "The frames per second of the animation."},
+			"from":           {doc: "The value the animation starts from."},
+			"to":             {doc: "The value the animation ends at."},
+			"frameFrom":      {kind: spxMetadataString, doc: "The name of the first costume of the frames."},
+			"frameTo":        {kind: spxMetadataString, doc: "The name of the last costume of the frames."},
+			"frameFps":       {kind: spxMetadataInteger, doc: "The frames per second of the costume frames."},
+			"stepDuration":   {kind: spxMetadataNumber, doc: "The duration of moving a step in seconds, for `step` animations."},
+			"turnToDuration": {kind: spxMetadataNumber, doc: "The duration of turning in seconds, for `turnTo` animations."},
+			"anitype":        {kind: spxMetadataInteger, doc: "The type of the animation: `0` for frames, `1` for moving, `2` for turning and `3` for gliding."},
+			"onStart":        {kind: action.kind, doc: "The action taken when the animation starts.", fields: action.fields},
+			"onPlay":         {kind: action.kind, doc: "The action taken when the animation plays.", fields: action.fields},
+			"isLoop":         {kind: spxMetadataBoolean, doc: "Whether the animation loops."},
+			"isKeepOnStop":   {kind: spxMetadataBoolean, doc: "Whether the sprite stays on the last frame instead of the default animation when the animation stops."},
+		},
+	}
+}()
+
+// spxCostumeSetMetadataFields are the fields shared by costume sets.
+var spxCostumeSetMetadataFields = map[string]*spxMetadataSchema{
+	"path":             {kind: spxMetadataString, doc: "The path of the image file containing all costumes, relative to the directory of this file."},
+	"faceRight":        {kind: spxMetadataNumber, doc: "The direction the image faces, in degrees, when the costumes face right."},
+	"bitmapResolution": {kind: spxMetadataInteger, doc: "The resolution of the image, e.g., `2` for images drawn at twice the size."},
+}
+
+// spxCostumeSetPartMetadataFields are the fields of costume sets that slice an
+// image into costumes.
+var spxCostumeSetPartMetadataFields = map[string]*spxMetadataSchema{
+	"nx": {kind: spxMetadataInteger, doc: "The number of costumes in a row of the image."},
+	"rect": {
+		kind: spxMetadataObject,
+		doc:  "The rectangle of the image containing the costumes.",
+		fields: map[string]*spxMetadataSchema{
+			"x": {kind: spxMetadataNumber, doc: "The x coordinate of the rectangle."},
+			"y": {kind: spxMetadataNumber, doc: "The y coordinate of the rectangle."},
+			"w": {kind: spxMetadataNumber, doc: "The width of the rectangle."},
+			"h": {kind: spxMetadataNumber, doc: "The height of the rectangle."},
+		},
+	},
+	"items": {
+		kind: spxMetadataArray,
+		doc:  "The groups of costumes in the image, in order.",
+		elem: &spxMetadataSchema{
+			kind: spxMetadataObject,
+			fields: map[string]*spxMetadataSchema{
+				"namePrefix": {kind: spxMetadataString, doc: "The prefix of the costume names, followed by their indexes in the group."},
+				"n":          {kind: spxMetadataInteger, doc: "The number of costumes in the group."},
+			},
+		},
+	},
+}
+
+// spxGameMetadataSchema is the schema of the index.json in the root directory
+// of spx resources.
+var spxGameMetadataSchema = &spxMetadataSchema{
+	kind: spxMetadataObject,
+	fields: map[string]*spxMetadataSchema{
+		"zorder": {
+			kind: spxMetadataArray,
+			doc:  "The sprites and widgets on the stage from back to front, as sprite names or widget objects.",
+			elem: &spxMetadataSchema{},
+		},
+		"backdrops": {
+			kind: spxMetadataArray,
+			doc:  "The backdrops of the stage.",
+			elem: spxCostumeMetadataSchema,
+		},
+		"backdropIndex": {kind: spxMetadataInteger, doc: "The index of the backdrop shown when the game starts."},
+		"map": {
+			kind: spxMetadataObject,
+			doc:  "The size and mode of the map.",
+			fields: map[string]*spxMetadataSchema{
+				"width":  {kind: spxMetadataInteger, doc: "The width of the map. The window is clamped to it."},
+				"height": {kind: spxMetadataInteger, doc: "The height of the map. The window is clamped to it."},
+				"mode": {
+					kind: spxMetadataString,
+					doc:  "How backdrops are drawn on the map.",
+					enum: []string{"fill", "repeat", "fillRatio", "fillCut"},
+				},
+			},
+		},
+		"camera": {
+			kind: spxMetadataObject,
+			doc:  "The camera of the stage.",
+			fields: map[string]*spxMetadataSchema{
+				"on": {kind: spxMetadataString, doc: "The name of the sprite the camera follows."},
+			},
+		},
+		"run": {
+			kind: spxMetadataObject,
+			doc:  "The game config used when `run` is called without one in `main.spx`.",
+			fields: map[string]*spxMetadataSchema{
+				"title":            {kind: spxMetadataString, doc: "The title of the window."},
+				"width":            {kind: spxMetadataInteger, doc: "The width of the window."},
+				"height":           {kind: spxMetadataInteger, doc: "The height of the window."},
+				"keyDuration":      {kind: spxMetadataInteger, doc: "The duration of a key press in milliseconds."},
+				"screenshotKey":    {kind: spxMetadataString, doc: "The key capturing screenshots."},
+				"fullScreen":       {kind: spxMetadataBoolean, doc: "Whether the game runs in full screen."},
+				"pauseOnUnfocused": {kind: spxMetadataBoolean, doc: "Whether the game pauses when the window is unfocused."},
+			},
+		},
+		"scenes":              {kind: spxMetadataArray, doc: "Deprecated: use `backdrops` instead.", elem: spxCostumeMetadataSchema},
+		"costumes":            {kind: spxMetadataArray, doc: "Deprecated: use `backdrops` instead.", elem: spxCostumeMetadataSchema},
+		"currentCostumeIndex": {kind: spxMetadataInteger, doc: "Deprecated: use `backdropIndex` instead."},
+		"sceneIndex":          {kind: spxMetadataInteger, doc: "Deprecated: use `backdropIndex` instead."},
+	},
+}
+
+// spxSpriteMetadataSchema is the schema of the index.json of sprites.
+var spxSpriteMetadataSchema = &spxMetadataSchema{
+	kind: spxMetadataObject,
+	fields: map[string]*spxMetadataSchema{
+		"heading": {kind: spxMetadataNumber, doc: "The direction the sprite faces when the game starts, in degrees."},
+		"x":       {kind: spxMetadataNumber, doc: "The x coordinate of the sprite when the game starts."},
+		"y":       {kind: spxMetadataNumber, doc: "The y coordinate of the sprite when the game starts."},
+		"size":    {kind: spxMetadataNumber, doc: "The size of the sprite when the game starts, e.g., `1` for the original size."},
+		"rotationStyle": {
+			kind: spxMetadataString,
+			doc:  "How the sprite is drawn when it turns.",
+			enum: []string{"normal", "left-right", "none"},
+		},
+		"costumes": {
+			kind: spxMetadataArray,
+			doc:  "The costumes of the sprite.",
+			elem: spxCostumeMetadataSchema,
+		},
+		"costumeSet": {
+			kind:   spxMetadataObject,
+			doc:    "The costumes of the sprite sliced from a single image.",
+			fields: merged(spxCostumeSetMetadataFields, spxCostumeSetPartMetadataFields),
+		},
+		"costumeMPSet": {
+			kind: spxMetadataObject,
+			doc:  "The costumes of the sprite sliced from multiple parts of a single image.",
+			fields: merged(spxCostumeSetMetadataFields, map[string]*spxMetadataSchema{
+				"parts": {
+					kind: spxMetadataArray,
+					doc:  "The parts of the image containing the costumes.",
+					elem: &spxMetadataSchema{kind: spxMetadataObject, fields: spxCostumeSetPartMetadataFields},
+				},
+			}),
+		},
+		"costumeIndex":        {kind: spxMetadataInteger, doc: "The index of the costume shown when the game starts."},
+		"currentCostumeIndex": {kind: spxMetadataInteger, doc: "Deprecated: use `costumeIndex` instead."},
+		"fAnimations":         {kind: spxMetadataObject, doc: "The frame animations of the sprite, keyed by their names.", values: spxAnimationMetadataSchema},
+		"mAnimations":         {kind: spxMetadataObject, doc: "The moving animations of the sprite, keyed by their names.", values: spxAnimationMetadataSchema},
+		"tAnimations":         {kind: spxMetadataObject, doc: "The turning animations of the sprite, keyed by their names.", values: spxAnimationMetadataSchema},
+		"visible":             {kind: spxMetadataBoolean, doc: "Whether the sprite is visible when the game starts."},
+		"isDraggable":         {kind: spxMetadataBoolean, doc: "Whether the sprite can be dragged by the mouse."},
+		"pivot": {
+			kind: spxMetadataObject,
+			doc:  "The pivot of the sprite, relative to its rotation center.",
+			fields: map[string]*spxMetadataSchema{
+				"x": {kind: spxMetadataNumber},
+				"y": {kind: spxMetadataNumber},
+			},
+		},
+		"defaultAnimation": {kind: spxMetadataString, doc: "The name of the animation played when no other animation is playing."},
+		"animBindings": {
+			kind:   spxMetadataObject,
+			doc:    "The animations played for actions, e.g., `{\"step\": \"walk\"}`.",
+			values: &spxMetadataSchema{kind: spxMetadataString},
+		},
+	},
+}
+
+// spxSoundMetadataSchema is the schema of the index.json of sounds.
+var spxSoundMetadataSchema = &spxMetadataSchema{
+	kind: spxMetadataObject,
+	fields: map[string]*spxMetadataSchema{
+		"path":        {kind: spxMetadataString, doc: "The path of the audio file, relative to the directory of this file."},
+		"rate":        {kind: spxMetadataInteger, doc: "The sample rate of the audio."},
+		"sampleCount": {kind: spxMetadataInteger, doc: "The number of samples of the audio."},
+	},
+}
+
+// merged returns a new map with the entries of all the given maps.
+func merged[M ~map[K]V, K comparable, V any](ms ...M) M {
+	result := make(M)
+	for _, m := range ms {
+		maps.Copy(result, m)
+	}
+	return result
+}
+
+// spxResourceMetadataSchemaFor returns the schema of the given metadata file
+// relative to the spx resource root directory, or nil if it is not a metadata
+// file of spx resources.
+func spxResourceMetadataSchemaFor(file string) *spxMetadataSchema {
+	if file == "index.json" {
+		return spxGameMetadataSchema
+	}
+	if ok, _ := path.Match("sprites/*/index.json", file); ok {
+		return spxSpriteMetadataSchema
+	}
+	if ok, _ := path.Match("sounds/*/index.json", file); ok {
+		return spxSoundMetadataSchema
+	}
+	return nil
+}
+
+// spxResourceMetadataFileFor returns the path of the given file relative to the
+// spx resource root directory and its schema, if the file is a metadata file
+// of spx resources.
+func (r *compileResult) spxResourceMetadataFileFor(file string) (string, *spxMetadataSchema, bool) {
+	rel, ok := strings.CutPrefix(file, r.spxResourceRootDir+"/")
+	if !ok {
+		return "", nil, false
+	}
+	schema := spxResourceMetadataSchemaFor(rel)
+	return rel, schema, schema != nil
+}
+
+// inspectForInvalidSpxResourceMetadata inspects the metadata files of spx
+// resources for syntax errors and schema violations, i.e., values of wrong
+// kinds, which fail loading the resources, and unknown fields or invalid
+// values, which are ignored by spx. Fields prefixed with "builder_" are
+// reserved for XBuilder and are never unknown. The files are validated
+// concurrently with at most the given number of workers, except for those
+// unchanged since the given previous validations, if any, whose violations
+// are reused.
+func (r *compileResult) inspectForInvalidSpxResourceMetadata(rootFS *vfs.MapFS, workers int, prev map[string]spxResourceMetadataValidation) {
+	files := []string{"index.json"}
+	for _, pattern := range []string{"sprites/*/index.json", "sounds/*/index.json"} {
+		matches, _ := fs.Glob(rootFS, pattern)
+		files = append(files, matches...)
+	}
+	contents := make([][]byte, len(files))
+	violations := make([][]spxMetadataViolation, len(files))
+	r.spxResourceMetadataValidations = make(map[string]spxResourceMetadataValidation, len(files))
+	var changed []int
+	for i, file := range files {
+		content, err := fs.ReadFile(rootFS, file)
+		if err != nil {
+			continue
+		}
+		contents[i] = content
+		hash, _ := rootFS.FileHash(file)
+		if validation, ok := prev[file]; ok && validation.hash == hash {
+			violations[i] = validation.violations
+			r.spxResourceMetadataValidations[file] = validation
+			continue
+		}
+		changed = append(changed, i)
+	}
+	forEachConcurrently(workers, len(changed), func(j int) {
+		i := changed[j]
+		violations[i] = validateSpxResourceMetadata(contents[i], spxResourceMetadataSchemaFor(files[i]))
+	})
+	for _, i := range changed {
+		hash, _ := rootFS.FileHash(files[i])
+		r.spxResourceMetadataValidations[files[i]] = spxResourceMetadataValidation{hash: hash, violations: violations[i]}
+	}
+
+	for i, file := range files {
+		documentURI := r.toDocumentURI(path.Join(r.spxResourceRootDir, file))
+		for _, v := range violations[i] {
+			r.addDiagnostics(documentURI, Diagnostic{
+				Severity: v.severity,
+				Code:     DiagnosticCodeInvalidResourceMetadata,
+				Range:    v.span.rangeIn(contents[i], r.positionEncoding),
+				Message:  v.message,
+			})
+		}
+	}
+}
+
+// spxResourceMetadataValidation is the result of validating a metadata file of
+// spx resources.
+type spxResourceMetadataValidation struct {
+	// hash is the content hash of the validated file.
+	hash [sha256.Size]byte
+
+	// violations are the violations found in the file.
+	violations []spxMetadataViolation
+}
+
+// spxMetadataViolation is a violation of the schema of a metadata file.
+type spxMetadataViolation struct {
+	span     jsonSpan
+	severity DiagnosticSeverity
+	message  string
+}
+
+// validateSpxResourceMetadata validates the given content of a metadata file
+// against the given schema.
+func validateSpxResourceMetadata(content []byte, schema *spxMetadataSchema) []spxMetadataViolation {
+	var v any
+	if err := json.Unmarshal(content, &v); err != nil {
+		span := jsonSpan{start: len(content), end: len(content)}
+		if syntaxErr := (*json.SyntaxError)(nil); errors.As(err, &syntaxErr) {
+			offset := max(int(syntaxErr.Offset)-1, 0)
+			span = jsonSpan{start: offset, end: min(offset+1, len(content))}
+		}
+		return []spxMetadataViolation{{
+			span:     span,
+			severity: SeverityError,
+			message:  fmt.Sprintf("syntax error: %v", err),
+		}}
+	}
+
+	var violations []spxMetadataViolation
+	start := len(content) - len(bytes.TrimLeft(content, " \t\r\n"))
+	end := len(bytes.TrimRight(content, " \t\r\n"))
+	validateSpxMetadataValue(content, jsonSpan{start: start, end: end}, schema, "the metadata", &violations)
+	return violations
+}
+
+// validateSpxMetadataValue validates the value of the given span in content
+// against the given schema, where desc describes the value in messages.
+func validateSpxMetadataValue(content []byte, span jsonSpan, schema *spxMetadataSchema, desc string, violations *[]spxMetadataViolation) {
+	raw := content[span.start:span.end]
+	kind := jsonValueKind(raw)
+	if kind == spxMetadataAny || schema.kind == spxMetadataAny {
+		// Null is valid for any kind.
+		return
+	}
+	if kind != schema.kind && (kind != spxMetadataInteger || schema.kind != spxMetadataNumber) {
+		*violations = append(*violations, spxMetadataViolation{
+			span:     span,
+			severity: SeverityError,
+			message:  fmt.Sprintf("%s must be %s, but got %s", desc, schema.kind.article(), kind.article()),
+		})
+		return
+	}
+
+	switch kind {
+	case spxMetadataObject:
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.Token()
+		for dec.More() {
+			keyStart := span.start + int(dec.InputOffset())
+			tok, err := dec.Token()
+			if err != nil {
+				return
+			}
+			key, _ := tok.(string)
+			keyStart += bytes.IndexByte(content[keyStart:], '"')
+			keySpan := jsonSpan{start: keyStart, end: span.start + int(dec.InputOffset())}
+			valueSpan, ok := decodeJSONSpan(dec)
+			if !ok {
+				return
+			}
+			valueSpan.start += span.start
+			valueSpan.end += span.start
+
+			fieldSchema := schema.fields[key]
+			if fieldSchema == nil {
+				fieldSchema = schema.values
+			}
+			if fieldSchema == nil {
+				if schema.fields != nil && !strings.HasPrefix(key, "builder_") {
+					message := fmt.Sprintf("unknown field %q", key)
+					if suggestion, ok := spellingSuggestionFor(key, slices.Sorted(maps.Keys(schema.fields))); ok {
+						message += fmt.Sprintf(", did you mean %q?", suggestion)
+					}
+					*violations = append(*violations, spxMetadataViolation{
+						span:     keySpan,
+						severity: SeverityWarning,
+						message:  message,
+					})
+				}
+				continue
+			}
+			validateSpxMetadataValue(content, valueSpan, fieldSchema, fmt.Sprintf("field %q", key), violations)
+		}
+	case spxMetadataArray:
+		if schema.elem == nil {
+			return
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.Token()
+		for dec.More() {
+			elemSpan, ok := decodeJSONSpan(dec)
+			if !ok {
+				return
+			}
+			elemSpan.start += span.start
+			elemSpan.end += span.start
+			validateSpxMetadataValue(content, elemSpan, schema.elem, "element of "+desc, violations)
+		}
+	case spxMetadataString:
+		if schema.enum == nil {
+			return
+		}
+		var s string
+		if json.Unmarshal(raw, &s) == nil && !slices.Contains(schema.enum, s) {
+			*violations = append(*violations, spxMetadataViolation{
+				span:     span,
+				severity: SeverityWarning,
+				message:  fmt.Sprintf("invalid value %q for %s, valid values are %s", s, desc, quotedList(schema.enum)),
+			})
+		}
+	}
+}
+
+// jsonValueKind returns the kind of the given valid JSON value, or
+// [spxMetadataAny] for null.
+func jsonValueKind(raw []byte) spxMetadataKind {
+	if len(raw) == 0 {
+		return spxMetadataAny
+	}
+	switch raw[0] {
+	case '{':
+		return spxMetadataObject
+	case '[':
+		return spxMetadataArray
+	case '"':
+		return spxMetadataString
+	case 't', 'f':
+		return spxMetadataBoolean
+	case 'n':
+		return spxMetadataAny
+	}
+	if bytes.ContainsAny(raw, ".eE") {
+		return spxMetadataNumber
+	}
+	return spxMetadataInteger
+}
+
+// quotedList returns the given strings quoted and separated by commas.
+func quotedList(ss []string) string {
+	quoted := make([]string, len(ss))
+	for i, s := range ss {
+		quoted[i] = strconv.Quote(s)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// jsonCursor is the syntactic context of an offset in a possibly incomplete
+// JSON document.
+type jsonCursor struct {
+	// path is the object keys leading to the innermost container of the
+	// offset, with "[]" for array elements.
+	path []string
+
+	// key is the key at the offset if inKey is true, or otherwise the key of
+	// the value at the offset in an object.
+	key string
+
+	// inKey reports whether the offset is at a key of an object.
+	inKey bool
+
+	// inObject reports whether the innermost container is an object.
+	inObject bool
+
+	// str is the span of the string at the offset, including its quotes, or
+	// nil if the offset is not in a string.
+	str *jsonSpan
+
+	// siblingKeys are the other keys of the innermost object.
+	siblingKeys []string
+}
+
+// jsonCursorAt returns the syntactic context of the given offset in the given
+// JSON document. It tolerates incomplete documents being edited.
+func jsonCursorAt(content []byte, offset int) jsonCursor {
+	type frame struct {
+		isObject  bool
+		key       string
+		expectKey bool
+		keys      []string
+	}
+	var (
+		stack  []frame
+		cursor jsonCursor
+		depth  = -1 // The depth of the innermost container once found.
+	)
+	capture := func() {
+		depth = len(stack)
+		for _, f := range stack[:max(depth-1, 0)] {
+			if f.isObject {
+				cursor.path = append(cursor.path, f.key)
+			} else {
+				cursor.path = append(cursor.path, "[]")
+			}
+		}
+		if depth > 0 && stack[depth-1].isObject {
+			cursor.inObject = true
+			cursor.key = stack[depth-1].key
+			cursor.inKey = stack[depth-1].expectKey
+		}
+	}
+
+	for i := 0; i <= len(content); i++ {
+		if depth < 0 && i >= offset {
+			capture()
+		}
+		if i == len(content) {
+			break
+		}
+		f := &frame{}
+		if len(stack) > 0 {
+			f = &stack[len(stack)-1]
+		}
+		switch c := content[i]; c {
+		case '{', '[':
+			stack = append(stack, frame{isObject: c == '{', expectKey: c == '{'})
+		case '}', ']':
+			if len(stack) == depth {
+				cursor.siblingKeys = f.keys
+				return cursor
+			}
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case ',':
+			if f.isObject {
+				f.key, f.expectKey = "", true
+			}
+		case ':':
+			if f.isObject {
+				f.expectKey = false
+			}
+		case '"':
+			end := i + 1
+			for end < len(content) && content[end] != '"' && content[end] != '\n' {
+				if content[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(content))
+			if depth < 0 && offset < end {
+				capture()
+				cursor.str = &jsonSpan{start: i, end: end}
+				if cursor.inKey {
+					cursor.key = jsonUnquotePrefix(content[i:offset])
+				}
+			} else if f.isObject && f.expectKey {
+				f.key = jsonUnquotePrefix(content[i:end])
+				f.keys = append(f.keys, f.key)
+			}
+			i = end - 1
+		}
+	}
+	if depth > 0 {
+		cursor.siblingKeys = stack[depth-1].keys
+	}
+	return cursor
+}
+
+// jsonUnquotePrefix unquotes the given possibly unterminated JSON string.
+func jsonUnquotePrefix(quoted []byte) string {
+	var s string
+	if json.Unmarshal(quoted, &s) == nil {
+		return s
+	}
+	s, _ = strings.CutPrefix(string(quoted), `"`)
+	return strings.TrimSuffix(s, `"`)
+}
+
+// schemaAt returns the schema of the innermost container at the given path,
+// or nil if it is unknown.
+func (s *spxMetadataSchema) schemaAt(keys []string) *spxMetadataSchema {
+	schema := s
+	for _, key := range keys {
+		switch {
+		case key == "[]" && schema.elem != nil:
+			schema = schema.elem
+		case schema.fields[key] != nil:
+			schema = schema.fields[key]
+		case schema.values != nil:
+			schema = schema.values
+		default:
+			return nil
+		}
+	}
+	return schema
+}
+
+// spxResourceMetadataFile is a metadata file of spx resources in a workspace.
+type spxResourceMetadataFile struct {
+	result  *compileResult
+	rel     string // path relative to the spx resource root directory
+	content []byte
+	lines   *lineIndex
+	schema  *spxMetadataSchema
+}
+
+// spxResourceMetadataFileOf returns the metadata file of spx resources of the
+// given document, if it is one.
+func (s *Server) spxResourceMetadataFileOf(uri DocumentURI) (*spxResourceMetadataFile, bool) {
+	if path.Base(string(uri)) != "index.json" {
+		return nil, false
+	}
+	folder, file, err := s.workspaceFolderFor(uri)
+	if err != nil {
+		return nil, false
+	}
+	result, err := s.compileWorkspaceFolder(context.Background(), folder)
+	if err != nil {
+		return nil, false
+	}
+	rel, schema, ok := result.spxResourceMetadataFileFor(file)
+	if !ok {
+		return nil, false
+	}
+	content, err := fs.ReadFile(s.workspaceFolderSnapshot(folder), file)
+	if err != nil {
+		return nil, false
+	}
+	return &spxResourceMetadataFile{
+		result:  result,
+		rel:     rel,
+		content: content,
+		lines:   newLineIndex(string(content), result.positionEncoding),
+		schema:  schema,
+	}, true
+}
+
+// spxResourceMetadataHover returns the hover of the field key at the given
+// position in the given metadata file of spx resources.
+func (s *Server) spxResourceMetadataHover(file *spxResourceMetadataFile, position Position) *Hover {
+	content := file.content
+	cursor := jsonCursorAt(content, file.lines.offset(position)+1)
+	if !cursor.inKey || cursor.str == nil {
+		return nil
+	}
+	container := file.schema.schemaAt(cursor.path)
+	if container == nil {
+		return nil
+	}
+	field := container.fields[jsonUnquotePrefix(content[cursor.str.start:cursor.str.end])]
+	if field == nil {
+		return nil
+	}
+
+	value := fmt.Sprintf("**%s** *%s*", jsonUnquotePrefix(content[cursor.str.start:cursor.str.end]), field.kind)
+	if field.doc != "" {
+		value += "\n\n" + field.doc
+	}
+	if field.enum != nil {
+		value += "\n\nValid values: " + quotedList(field.enum)
+	}
+	return &Hover{
+		Contents: markupContentFor(value, s.clientSupportsHoverMarkdown()),
+		Range: Range{
+			Start: file.lines.position(cursor.str.start),
+			End:   file.lines.position(cursor.str.end),
+		},
+	}
+}
+
+// spxResourceMetadataCompletion returns the completion items of field keys or
+// enum values at the given position in the given metadata file of spx
+// resources.
+func spxResourceMetadataCompletion(file *spxResourceMetadataFile, position Position) []CompletionItem {
+	cursor := jsonCursorAt(file.content, file.lines.offset(position))
+	if !cursor.inObject {
+		return nil
+	}
+	container := file.schema.schemaAt(cursor.path)
+	if container == nil {
+		return nil
+	}
+
+	items := []CompletionItem{}
+	if !cursor.inKey {
+		field := container.fields[cursor.key]
+		if field == nil || field.enum == nil || cursor.str == nil {
+			return items
+		}
+		for _, value := range field.enum {
+			items = append(items, CompletionItem{
+				Label:            value,
+				Kind:             EnumMemberCompletion,
+				Detail:           cursor.key,
+				InsertText:       value,
+				InsertTextFormat: util.ToPtr(PlainTextTextFormat),
+			})
+		}
+		return items
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(container.fields)) {
+		if slices.Contains(cursor.siblingKeys, key) {
+			continue
+		}
+		field := container.fields[key]
+		insertText := strconv.Quote(key) + ": "
+		if cursor.str != nil {
+			insertText = key
+		}
+		item := CompletionItem{
+			Label:            key,
+			Kind:             PropertyCompletion,
+			Detail:           field.kind.String(),
+			InsertText:       insertText,
+			InsertTextFormat: util.ToPtr(PlainTextTextFormat),
+		}
+		if field.doc != "" {
+			item.Documentation = &Or_CompletionItem_documentation{Value: MarkupContent{Kind: Markdown, Value: field.doc}}
+		}
+		if strings.HasPrefix(field.doc, "Deprecated:") {
+			item.Tags = []CompletionItemTag{ComplDeprecated}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// spxResourceIDAt returns the ID of the spx resource whose metadata is at the
+// given position, e.g., the costume whose name is at the position in the
+// "costumes" of a sprite. It returns nil if there is no such resource.
+func (f *spxResourceMetadataFile) spxResourceIDAt(position Position) SpxResourceID {
+	cursor := jsonCursorAt(f.content, f.lines.offset(position)+1)
+	var name string
+	if cursor.str != nil {
+		name = jsonUnquotePrefix(f.content[cursor.str.start:cursor.str.end])
+	}
+	isNameValue := cursor.str != nil && !cursor.inKey && cursor.key == "name"
+
+	dirName := path.Base(path.Dir(f.rel))
+	switch spxResourceMetadataSchemaFor(f.rel) {
+	case spxGameMetadataSchema:
+		switch {
+		case isNameValue && slices.Equal(cursor.path, []string{"backdrops", "[]"}):
+			return SpxBackdropResourceID{BackdropName: name}
+		case isNameValue && slices.Equal(cursor.path, []string{"zorder", "[]"}):
+			return SpxWidgetResourceID{WidgetName: name}
+		case cursor.str != nil && !cursor.inObject && slices.Equal(cursor.path, []string{"zorder"}):
+			return SpxSpriteResourceID{SpriteName: name}
+		}
+	case spxSpriteMetadataSchema:
+		switch {
+		case isNameValue && slices.Equal(cursor.path, []string{"costumes", "[]"}):
+			return SpxSpriteCostumeResourceID{SpriteName: dirName, CostumeName: name}
+		case cursor.str != nil && cursor.inKey && slices.Equal(cursor.path, []string{"fAnimations"}):
+			return SpxSpriteAnimationResourceID{SpriteName: dirName, AnimationName: name}
+		}
+		return SpxSpriteResourceID{SpriteName: dirName}
+	case spxSoundMetadataSchema:
+		return SpxSoundResourceID{SoundName: dirName}
+	}
+	return nil
+}
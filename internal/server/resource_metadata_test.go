@@ -0,0 +1,241 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerInspectForInvalidSpxResourceMetadata(t *testing.T) {
+	diagnosticsFor := func(t *testing.T, s *Server, uri DocumentURI) []Diagnostic {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		return fullReport.Items
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`run "assets", {Title: "My Game"}`),
+			"assets/index.json": []byte(`{
+  "mpa": {},
+  "map": {"width": 480, "mode": "fil"},
+  "backdropIndex": "0",
+  "builder_id": "abc"
+}`),
+			"assets/sprites/MySprite/index.json": []byte(`{"costumes": [{"name": "c1", "x": 1.5}, "c2"], "visible": true}`),
+		}), nil)
+
+		assert.Equal(t, []Diagnostic{
+			{
+				Severity: SeverityWarning,
+				Code:     DiagnosticCodeInvalidResourceMetadata,
+				Range: Range{
+					Start: Position{Line: 1, Character: 2},
+					End:   Position{Line: 1, Character: 7},
+				},
+				Message: `unknown field "mpa", did you mean "map"?`,
+			},
+			{
+				Severity: SeverityWarning,
+				Code:     DiagnosticCodeInvalidResourceMetadata,
+				Range: Range{
+					Start: Position{Line: 2, Character: 32},
+					End:   Position{Line: 2, Character: 37},
+				},
+				Message: `invalid value "fil" for field "mode", valid values are "fill", "repeat", "fillRatio", "fillCut"`,
+			},
+			{
+				Severity: SeverityError,
+				Code:     DiagnosticCodeInvalidResourceMetadata,
+				Range: Range{
+					Start: Position{Line: 3, Character: 19},
+					End:   Position{Line: 3, Character: 22},
+				},
+				Message: `field "backdropIndex" must be an integer, but got a string`,
+			},
+		}, diagnosticsFor(t, s, "file:///assets/index.json"))
+
+		assert.Equal(t, []Diagnostic{
+			{
+				Severity: SeverityError,
+				Code:     DiagnosticCodeInvalidResourceMetadata,
+				Range: Range{
+					Start: Position{Line: 0, Character: 40},
+					End:   Position{Line: 0, Character: 44},
+				},
+				Message: `element of field "costumes" must be an object, but got a string`,
+			},
+		}, diagnosticsFor(t, s, "file:///assets/sprites/MySprite/index.json"))
+	})
+
+	t.Run("SyntaxError", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(`run "assets", {Title: "My Game"}`),
+			"assets/index.json": []byte(`{"map": }`),
+		}), nil)
+
+		diags := diagnosticsFor(t, s, "file:///assets/index.json")
+		require.Len(t, diags, 1)
+		assert.Equal(t, SeverityError, diags[0].Severity)
+		assert.Equal(t, Range{
+			Start: Position{Line: 0, Character: 8},
+			End:   Position{Line: 0, Character: 9},
+		}, diags[0].Range)
+		assert.Contains(t, diags[0].Message, "syntax error: ")
+	})
+
+	t.Run("Valid", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":                           []byte(`run "assets", {Title: "My Game"}`),
+			"assets/index.json":                  []byte(`{"zorder": ["MySprite", {"name": "score"}], "map": null, "run": {"width": 480}}`),
+			"assets/sprites/MySprite/index.json": []byte(`{"fAnimations": {"walk": {"frameFrom": "c1", "onStart": {"play": "Step"}}}, "x": 10}`),
+			"assets/sounds/Step/index.json":      []byte(`{"path": "step.wav", "rate": 44100}`),
+		}), nil)
+
+		assert.Empty(t, diagnosticsFor(t, s, "file:///assets/index.json"))
+		assert.Empty(t, diagnosticsFor(t, s, "file:///assets/sprites/MySprite/index.json"))
+		assert.Empty(t, diagnosticsFor(t, s, "file:///assets/sounds/Step/index.json"))
+	})
+}
+
+func TestServerSpxResourceMetadataHover(t *testing.T) {
+	s := New(newMapFSWithoutModTime(map[string][]byte{
+		"main.spx":          []byte(`run "assets", {Title: "My Game"}`),
+		"assets/index.json": []byte(`{"map": {"mode": "fill"}, "unknown": 1}`),
+	}), nil)
+
+	t.Run("Field", func(t *testing.T) {
+		hover, err := s.textDocumentHover(&HoverParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///assets/index.json"},
+				Position:     Position{Line: 0, Character: 12},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, hover)
+		assert.Equal(t, MarkupContent{
+			Kind:  Markdown,
+			Value: "**mode** *string*\n\nHow backdrops are drawn on the map.\n\nValid values: \"fill\", \"repeat\", \"fillRatio\", \"fillCut\"",
+		}, hover.Contents)
+		assert.Equal(t, Range{
+			Start: Position{Line: 0, Character: 9},
+			End:   Position{Line: 0, Character: 15},
+		}, hover.Range)
+	})
+
+	t.Run("UnknownField", func(t *testing.T) {
+		hover, err := s.textDocumentHover(&HoverParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///assets/index.json"},
+				Position:     Position{Line: 0, Character: 29},
+			},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, hover)
+	})
+
+	t.Run("Value", func(t *testing.T) {
+		hover, err := s.textDocumentHover(&HoverParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///assets/index.json"},
+				Position:     Position{Line: 0, Character: 20},
+			},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, hover)
+	})
+}
+
+func TestServerSpxResourceMetadataCompletion(t *testing.T) {
+	newServer := func(indexJSON string) *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":                           []byte(`run "assets", {Title: "My Game"}`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(indexJSON),
+		}), nil)
+	}
+	completeAt := func(t *testing.T, s *Server, position Position) []CompletionItem {
+		items, err := s.textDocumentCompletion(context.Background(), &CompletionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///assets/sprites/MySprite/index.json"},
+				Position:     position,
+			},
+		})
+		require.NoError(t, err)
+		return items
+	}
+	labelsOf := func(items []CompletionItem) []string {
+		labels := make([]string, 0, len(items))
+		for _, item := range items {
+			labels = append(labels, item.Label)
+		}
+		return labels
+	}
+
+	t.Run("Keys", func(t *testing.T) {
+		s := newServer("{\n  \"x\": 1,\n  \n  \"y\": 2\n}")
+		items := completeAt(t, s, Position{Line: 2, Character: 2})
+		labels := labelsOf(items)
+		assert.Contains(t, labels, "costumes")
+		assert.Contains(t, labels, "rotationStyle")
+		assert.NotContains(t, labels, "x")
+		assert.NotContains(t, labels, "y")
+
+		for _, item := range items {
+			if item.Label == "costumes" {
+				assert.Equal(t, PropertyCompletion, item.Kind)
+				assert.Equal(t, "array", item.Detail)
+				assert.Equal(t, `"costumes": `, item.InsertText)
+			}
+		}
+	})
+
+	t.Run("KeyInString", func(t *testing.T) {
+		s := newServer(`{"costumes": [{"na"}]}`)
+		items := completeAt(t, s, Position{Line: 0, Character: 18})
+		assert.Equal(t, []string{"bitmapResolution", "faceRight", "name", "path", "x", "y"}, labelsOf(items))
+		assert.Equal(t, "name", items[2].InsertText)
+	})
+
+	t.Run("EnumValues", func(t *testing.T) {
+		s := newServer(`{"rotationStyle": ""}`)
+		items := completeAt(t, s, Position{Line: 0, Character: 19})
+		assert.Equal(t, []string{"normal", "left-right", "none"}, labelsOf(items))
+		assert.Equal(t, EnumMemberCompletion, items[0].Kind)
+	})
+
+	t.Run("NestedKeys", func(t *testing.T) {
+		s := newServer(`{"fAnimations": {"walk": {"isLoop": true, }}}`)
+		labels := labelsOf(completeAt(t, s, Position{Line: 0, Character: 41}))
+		assert.Contains(t, labels, "frameFrom")
+		assert.Contains(t, labels, "onStart")
+		assert.NotContains(t, labels, "isLoop")
+	})
+}
+
+func TestJSONCursorAt(t *testing.T) {
+	content := []byte(`{"a": {"b": [{"c": "d", "e"`)
+
+	cursor := jsonCursorAt(content, 21)
+	assert.Equal(t, []string{"a", "b", "[]"}, cursor.path)
+	assert.Equal(t, "c", cursor.key)
+	assert.False(t, cursor.inKey)
+	assert.True(t, cursor.inObject)
+	assert.Equal(t, &jsonSpan{start: 19, end: 22}, cursor.str)
+
+	cursor = jsonCursorAt(content, 26)
+	assert.Equal(t, []string{"a", "b", "[]"}, cursor.path)
+	assert.Equal(t, "e", cursor.key)
+	assert.True(t, cursor.inKey)
+	assert.Equal(t, []string{"c"}, cursor.siblingKeys)
+
+	cursor = jsonCursorAt(content, 13)
+	assert.Equal(t, []string{"a", "b"}, cursor.path)
+	assert.False(t, cursor.inObject)
+}
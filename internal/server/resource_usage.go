@@ -0,0 +1,92 @@
+package server
+
+import (
+	"cmp"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+	"github.com/goplus/goxlsw/internal/util"
+)
+
+// spxGetResourceUsages gets the usages of all spx resources in the workspace,
+// i.e., their references grouped by file, e.g., for an asset usage panel.
+func (s *Server) spxGetResourceUsages() ([]SpxResourceUsage, error) {
+	result, err := s.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := result.spxResourceSet.ids()
+	for _, sprite := range result.spxResourceSet.sprites {
+		for _, costume := range sprite.Costumes {
+			ids = append(ids, costume.ID)
+		}
+		for _, animation := range sprite.Animations {
+			ids = append(ids, animation.ID)
+		}
+	}
+	slices.SortFunc(ids, func(a, b SpxResourceID) int {
+		return cmp.Compare(a.URI(), b.URI())
+	})
+
+	usages := make([]SpxResourceUsage, 0, len(ids))
+	for _, id := range ids {
+		usage := SpxResourceUsage{
+			Resource: SpxResourceIdentifier{URI: id.URI()},
+			Files:    []SpxResourceUsageFile{},
+		}
+		for _, ref := range result.spxResourceRefsFor(id) {
+			documentURI := result.nodeDocumentURI(ref.Node)
+			if n := len(usage.Files); n == 0 || usage.Files[n-1].URI != documentURI {
+				usage.Files = append(usage.Files, SpxResourceUsageFile{URI: documentURI})
+			}
+			file := &usage.Files[len(usage.Files)-1]
+			file.References = append(file.References, SpxResourceUsageReference{
+				Kind:  ref.Kind,
+				Call:  result.spxResourceRefCall(ref),
+				Range: result.rangeForNode(ref.Node),
+			})
+			usage.Count++
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
+
+// spxResourceRefCall returns the name of the spx function called with the
+// given spx resource reference as an argument, or of the spx method called on
+// it, e.g., "play" in `play Meow` or "setCostume" in `MySprite.setCostume "c1"`.
+// It returns an empty string if there is no such call.
+func (r *compileResult) spxResourceRefCall(ref SpxResourceRef) string {
+	astFile := r.nodeASTFile(ref.Node)
+	if astFile == nil {
+		return ""
+	}
+	path, _ := util.PathEnclosingInterval(astFile, ref.Node.Pos(), ref.Node.End())
+	if len(path) < 2 {
+		return ""
+	}
+
+	var funcIdent *gopast.Ident
+	switch parent := path[1].(type) {
+	case *gopast.CallExpr:
+		if expr, ok := ref.Node.(gopast.Expr); !ok || !slices.Contains(parent.Args, expr) {
+			return ""
+		}
+		funcIdent = funcIdentOf(parent.Fun)
+	case *gopast.SelectorExpr:
+		if parent.X != ref.Node {
+			return ""
+		}
+		funcIdent = parent.Sel
+	}
+	if funcIdent == nil {
+		return ""
+	}
+	funcObj := r.typeInfo.ObjectOf(funcIdent)
+	if !isSpxPkgObject(funcObj) {
+		return ""
+	}
+	funcName, _ := parseGopFuncName(funcObj.Name())
+	return funcName
+}
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSpxGetResourceUsages(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+	Meow     Sound
+)
+
+onStart => {
+	play Meow
+	MySprite.setCostume "costume2"
+}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onClick => {
+	setCostume "costume1"
+	play "Meow"
+}
+`),
+			"assets/index.json":                  []byte(`{"zorder":["MySprite"]}`),
+			"assets/sounds/Meow/index.json":      []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1"},{"name":"costume2"},{"name":"costume3"}]}`),
+		}), nil)
+
+		usages, err := s.spxGetResourceUsages()
+		require.NoError(t, err)
+		require.Len(t, usages, 5)
+
+		meow := usages[0]
+		assert.Equal(t, SpxResourceURI("spx://resources/sounds/Meow"), meow.Resource.URI)
+		assert.Equal(t, 3, meow.Count)
+		assert.Equal(t, []SpxResourceUsageFile{
+			{
+				URI: "file:///MySprite.spx",
+				References: []SpxResourceUsageReference{
+					{
+						Kind:  SpxResourceRefKindStringLiteral,
+						Call:  "play",
+						Range: Range{Start: Position{Line: 3, Character: 6}, End: Position{Line: 3, Character: 12}},
+					},
+				},
+			},
+			{
+				URI: "file:///main.spx",
+				References: []SpxResourceUsageReference{
+					{
+						Kind:  SpxResourceRefKindAutoBinding,
+						Range: Range{Start: Position{Line: 3, Character: 1}, End: Position{Line: 3, Character: 5}},
+					},
+					{
+						Kind:  SpxResourceRefKindAutoBindingReference,
+						Call:  "play",
+						Range: Range{Start: Position{Line: 7, Character: 6}, End: Position{Line: 7, Character: 10}},
+					},
+				},
+			},
+		}, meow.Files)
+
+		mySprite := usages[1]
+		assert.Equal(t, SpxResourceURI("spx://resources/sprites/MySprite"), mySprite.Resource.URI)
+		require.Len(t, mySprite.Files, 1)
+		assert.Contains(t, mySprite.Files[0].References, SpxResourceUsageReference{
+			Kind:  SpxResourceRefKindAutoBindingReference,
+			Call:  "setCostume",
+			Range: Range{Start: Position{Line: 8, Character: 1}, End: Position{Line: 8, Character: 9}},
+		})
+
+		costume1 := usages[2]
+		assert.Equal(t, SpxResourceURI("spx://resources/sprites/MySprite/costumes/costume1"), costume1.Resource.URI)
+		assert.Equal(t, 1, costume1.Count)
+		require.Len(t, costume1.Files, 1)
+		assert.Equal(t, "setCostume", costume1.Files[0].References[0].Call)
+
+		costume3 := usages[4]
+		assert.Equal(t, SpxResourceURI("spx://resources/sprites/MySprite/costumes/costume3"), costume3.Resource.URI)
+		assert.Zero(t, costume3.Count)
+		assert.Empty(t, costume3.Files)
+	})
+
+	t.Run("NoMainSpxFile", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+
+		usages, err := s.spxGetResourceUsages()
+		require.ErrorIs(t, err, errNoMainSpxFile)
+		assert.Nil(t, usages)
+	})
+}
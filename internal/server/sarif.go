@@ -0,0 +1,171 @@
+package server
+
+import "context"
+
+// sarifSchemaURI is the URI of the JSON schema of SARIF 2.1.0.
+const sarifSchemaURI = "https://json.schemastore.org/sarif-2.1.0.json"
+
+// sarifSrcRootBaseID is the base ID the artifact URIs of [SarifLog] are
+// relative to, i.e., the workspace root.
+const sarifSrcRootBaseID = "%SRCROOT%"
+
+// SarifLog is a log of diagnostics in the SARIF 2.1.0 format, which can be
+// ingested by GitHub code scanning and other dashboards. Only the properties
+// used by the server are defined.
+//
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+type SarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SarifRun `json:"runs"`
+}
+
+// SarifRun is a run of a tool in [SarifLog].
+type SarifRun struct {
+	Tool    SarifTool     `json:"tool"`
+	Results []SarifResult `json:"results"`
+
+	// OriginalURIBaseIDs maps the base IDs of artifact URIs to their
+	// absolute URIs, if known.
+	OriginalURIBaseIDs map[string]SarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+}
+
+// SarifTool is the tool of [SarifRun].
+type SarifTool struct {
+	Driver SarifToolComponent `json:"driver"`
+}
+
+// SarifToolComponent describes the tool and the rules of its results.
+type SarifToolComponent struct {
+	Name           string                     `json:"name"`
+	InformationURI string                     `json:"informationUri,omitempty"`
+	Rules          []SarifReportingDescriptor `json:"rules"`
+}
+
+// SarifReportingDescriptor describes a rule, i.e., a diagnostic code.
+type SarifReportingDescriptor struct {
+	ID      string `json:"id"`
+	Name    string `json:"name,omitempty"`
+	HelpURI string `json:"helpUri,omitempty"`
+}
+
+// SarifResult is a result of [SarifRun], i.e., a diagnostic.
+type SarifResult struct {
+	RuleID    string          `json:"ruleId,omitempty"`
+	RuleIndex *int            `json:"ruleIndex,omitempty"`
+	Level     string          `json:"level"`
+	Message   SarifMessage    `json:"message"`
+	Locations []SarifLocation `json:"locations"`
+}
+
+// SarifMessage is a plain text message.
+type SarifMessage struct {
+	Text string `json:"text"`
+}
+
+// SarifLocation is a location of [SarifResult].
+type SarifLocation struct {
+	PhysicalLocation SarifPhysicalLocation `json:"physicalLocation"`
+}
+
+// SarifPhysicalLocation is a region of an artifact, i.e., a file.
+type SarifPhysicalLocation struct {
+	ArtifactLocation SarifArtifactLocation `json:"artifactLocation"`
+	Region           SarifRegion           `json:"region"`
+}
+
+// SarifArtifactLocation is the location of an artifact, with a URI relative to
+// the given base ID if any.
+type SarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// SarifRegion is a region of an artifact, with 1-based lines and columns
+// counted in UTF-16 code units, the same as those of LSP.
+type SarifRegion struct {
+	StartLine   uint32 `json:"startLine"`
+	StartColumn uint32 `json:"startColumn"`
+	EndLine     uint32 `json:"endLine"`
+	EndColumn   uint32 `json:"endColumn"`
+}
+
+// NewSarifLog returns a [SarifLog] of the given diagnostics, whose files are
+// relative to the source root with the given URI. The URI of the source root
+// is omitted if it is empty.
+func NewSarifLog(diagnostics []AnalysisDiagnostic, srcRootURI string) *SarifLog {
+	run := SarifRun{
+		Tool: SarifTool{Driver: SarifToolComponent{
+			Name:           "goxlsw",
+			InformationURI: "https://github.com/goplus/goxlsw",
+			Rules:          []SarifReportingDescriptor{},
+		}},
+		Results: []SarifResult{},
+	}
+	if srcRootURI != "" {
+		run.OriginalURIBaseIDs = map[string]SarifArtifactLocation{
+			sarifSrcRootBaseID: {URI: srcRootURI},
+		}
+	}
+
+	ruleIndexes := make(map[string]int)
+	for _, diag := range diagnostics {
+		result := SarifResult{
+			Level:   sarifLevelFor(diag.Severity),
+			Message: SarifMessage{Text: diag.Message},
+			Locations: []SarifLocation{{PhysicalLocation: SarifPhysicalLocation{
+				ArtifactLocation: SarifArtifactLocation{URI: diag.File, URIBaseID: sarifSrcRootBaseID},
+				Region: SarifRegion{
+					StartLine:   diag.Range.Start.Line + 1,
+					StartColumn: diag.Range.Start.Character + 1,
+					EndLine:     diag.Range.End.Line + 1,
+					EndColumn:   diag.Range.End.Character + 1,
+				},
+			}}},
+		}
+		if code, ok := diag.Code.(string); ok && code != "" {
+			index, ok := ruleIndexes[code]
+			if !ok {
+				index = len(run.Tool.Driver.Rules)
+				ruleIndexes[code] = index
+				rule := SarifReportingDescriptor{ID: code}
+				if info, ok := diagnosticCodeInfos[code]; ok {
+					rule.Name = info.name
+					rule.HelpURI = diagnosticCodeDescriptionBaseURL + code
+				}
+				run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+			}
+			result.RuleID = code
+			result.RuleIndex = &index
+		}
+		run.Results = append(run.Results, result)
+	}
+	return &SarifLog{
+		Schema:  sarifSchemaURI,
+		Version: "2.1.0",
+		Runs:    []SarifRun{run},
+	}
+}
+
+// sarifLevelFor returns the SARIF level of the given severity. Diagnostics
+// without severity are errors.
+func sarifLevelFor(severity DiagnosticSeverity) string {
+	switch severity {
+	case SeverityWarning:
+		return "warning"
+	case SeverityInformation, SeverityHint:
+		return "note"
+	default:
+		return "error"
+	}
+}
+
+// spxExportDiagnostics exports the diagnostics in all workspace folders as a
+// [SarifLog], with severity overrides in settings applied.
+func (s *Server) spxExportDiagnostics(ctx context.Context) (*SarifLog, error) {
+	results, err := s.compileWorkspaceFolders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return NewSarifLog(s.analysisDiagnosticsOf(results), string(s.workspaceRootURI)), nil
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSarifLog(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		log := NewSarifLog([]AnalysisDiagnostic{
+			{File: "main.spx", Diagnostic: Diagnostic{
+				Severity: SeverityError,
+				Code:     DiagnosticCodeResourceNotFound,
+				Range:    Range{Start: Position{Line: 1, Character: 5}, End: Position{Line: 1, Character: 13}},
+				Message:  `sound resource "Sound1" not found`,
+			}},
+			{File: "main.spx", Diagnostic: Diagnostic{
+				Severity: SeverityHint,
+				Code:     DiagnosticCodeUnusedImport,
+				Message:  `"fmt" imported and not used`,
+			}},
+			{File: "MySprite.spx", Diagnostic: Diagnostic{
+				Severity: SeverityWarning,
+				Code:     DiagnosticCodeResourceNotFound,
+				Message:  `sprite costume resource "costume1" not found`,
+			}},
+			{File: "MySprite.spx", Diagnostic: Diagnostic{
+				Message: "custom",
+			}},
+		}, "file:///project/")
+		assert.Equal(t, "2.1.0", log.Version)
+		require.Len(t, log.Runs, 1)
+		run := log.Runs[0]
+		assert.Equal(t, "goxlsw", run.Tool.Driver.Name)
+		assert.Equal(t, []SarifReportingDescriptor{
+			{ID: DiagnosticCodeResourceNotFound, Name: "resourceNotFound", HelpURI: diagnosticCodeDescriptionBaseURL + DiagnosticCodeResourceNotFound},
+			{ID: DiagnosticCodeUnusedImport, Name: "unusedImport", HelpURI: diagnosticCodeDescriptionBaseURL + DiagnosticCodeUnusedImport},
+		}, run.Tool.Driver.Rules)
+		assert.Equal(t, map[string]SarifArtifactLocation{"%SRCROOT%": {URI: "file:///project/"}}, run.OriginalURIBaseIDs)
+
+		require.Len(t, run.Results, 4)
+		assert.Equal(t, DiagnosticCodeResourceNotFound, run.Results[0].RuleID)
+		assert.Equal(t, 0, *run.Results[0].RuleIndex)
+		assert.Equal(t, "error", run.Results[0].Level)
+		assert.Equal(t, SarifPhysicalLocation{
+			ArtifactLocation: SarifArtifactLocation{URI: "main.spx", URIBaseID: "%SRCROOT%"},
+			Region:           SarifRegion{StartLine: 2, StartColumn: 6, EndLine: 2, EndColumn: 14},
+		}, run.Results[0].Locations[0].PhysicalLocation)
+		assert.Equal(t, "note", run.Results[1].Level)
+		assert.Equal(t, 1, *run.Results[1].RuleIndex)
+		assert.Equal(t, "warning", run.Results[2].Level)
+		assert.Equal(t, 0, *run.Results[2].RuleIndex)
+		assert.Empty(t, run.Results[3].RuleID)
+		assert.Nil(t, run.Results[3].RuleIndex)
+		assert.Equal(t, "error", run.Results[3].Level)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		data, err := json.Marshal(NewSarifLog(nil, ""))
+		require.NoError(t, err)
+		assert.JSONEq(t, `{
+			"$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+			"version": "2.1.0",
+			"runs": [{
+				"tool": {"driver": {"name": "goxlsw", "informationUri": "https://github.com/goplus/goxlsw", "rules": []}},
+				"results": []
+			}]
+		}`, string(data))
+	})
+}
+
+func TestServerSpxExportDiagnostics(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+import "fmt"
+
+play "Sound1"
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+		result, err := s.workspaceExecuteCommand(context.Background(), &ExecuteCommandParams{Command: "spx.exportDiagnostics"})
+		require.NoError(t, err)
+		log, ok := result.(*SarifLog)
+		require.True(t, ok)
+		require.Len(t, log.Runs, 1)
+		assert.Equal(t, "file:///", log.Runs[0].OriginalURIBaseIDs["%SRCROOT%"].URI)
+
+		results := log.Runs[0].Results
+		require.Len(t, results, 2)
+		assert.Equal(t, DiagnosticCodeUnusedImport, results[0].RuleID)
+		assert.Equal(t, uint32(2), results[0].Locations[0].PhysicalLocation.Region.StartLine)
+		assert.Equal(t, DiagnosticCodeResourceNotFound, results[1].RuleID)
+		assert.Equal(t, "main.spx", results[1].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	})
+
+	t.Run("EmptyWorkspace", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		log, err := s.spxExportDiagnostics(context.Background())
+		require.EqualError(t, err, "no valid main.spx file found in main package")
+		assert.Nil(t, log)
+	})
+}
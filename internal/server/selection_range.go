@@ -0,0 +1,70 @@
+package server
+
+import (
+	"bytes"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/internal/util"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_selectionRange
+func (s *Server) textDocumentSelectionRange(params *SelectionRangeParams) ([]SelectionRange, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	selectionRanges := make([]SelectionRange, 0, len(params.Positions))
+	for _, position := range params.Positions {
+		selectionRanges = append(selectionRanges, result.selectionRangeAt(astFile, position))
+	}
+	return selectionRanges, nil
+}
+
+// selectionRangeAt returns the selection range at the given position in the
+// given AST file. Its parents are the ranges of the enclosing nodes, from the
+// innermost to the whole file.
+func (r *compileResult) selectionRangeAt(astFile *gopast.File, position Position) SelectionRange {
+	tokenFile := r.fset.File(astFile.Pos())
+	eofPos := goptoken.Pos(tokenFile.Base() + tokenFile.Size())
+	lastLine := astFile.Code[bytes.LastIndexByte(astFile.Code, '\n')+1:]
+	fileRange := Range{
+		Start: Position{Line: 0, Character: 0},
+		End: Position{
+			Line:      uint32(bytes.Count(astFile.Code, []byte("\n"))),
+			Character: uint32(utf8OffsetToEncoding(string(lastLine), len(lastLine), r.positionEncoding)),
+		},
+	}
+
+	// Collect ranges from the outermost to the innermost.
+	ranges := []Range{fileRange}
+	pos := r.posAt(astFile, position)
+	path, _ := util.PathEnclosingInterval(astFile, pos, pos)
+	for i := len(path) - 1; i >= 0; i-- {
+		node := path[i]
+		if _, ok := node.(*gopast.File); ok {
+			continue
+		}
+		if !node.Pos().IsValid() || !node.End().IsValid() || node.End() > eofPos {
+			continue
+		}
+		nodeRange := r.rangeForASTFileNode(astFile, node)
+		if nodeRange == ranges[len(ranges)-1] {
+			continue
+		}
+		ranges = append(ranges, nodeRange)
+	}
+
+	var selectionRange *SelectionRange
+	for _, rng := range ranges {
+		selectionRange = &SelectionRange{
+			Range:  rng,
+			Parent: selectionRange,
+		}
+	}
+	return *selectionRange
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentSelectionRange(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`var score int
+onStart => {
+	score = score + 1
+}
+`),
+		}), nil)
+		params := &SelectionRangeParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Positions:    []Position{{Line: 2, Character: 17}},
+		}
+
+		selectionRanges, err := s.textDocumentSelectionRange(params)
+		require.NoError(t, err)
+		require.Len(t, selectionRanges, 1)
+
+		var ranges []Range
+		for selectionRange := &selectionRanges[0]; selectionRange != nil; selectionRange = selectionRange.Parent {
+			ranges = append(ranges, selectionRange.Range)
+		}
+		assert.Equal(t, []Range{
+			{Start: Position{Line: 2, Character: 17}, End: Position{Line: 2, Character: 18}}, // 1
+			{Start: Position{Line: 2, Character: 9}, End: Position{Line: 2, Character: 18}},  // score + 1
+			{Start: Position{Line: 2, Character: 1}, End: Position{Line: 2, Character: 18}},  // score = score + 1
+			{Start: Position{Line: 1, Character: 11}, End: Position{Line: 3, Character: 1}},  // handler body
+			{Start: Position{Line: 1, Character: 8}, End: Position{Line: 3, Character: 1}},   // lambda
+			{Start: Position{Line: 1, Character: 0}, End: Position{Line: 3, Character: 1}},   // onStart call
+			{Start: Position{Line: 0, Character: 0}, End: Position{Line: 4, Character: 0}},   // file
+		}, ranges)
+	})
+
+	t.Run("MultiplePositions", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`var score int
+run "assets", {Title: "My Game"}
+`),
+		}), nil)
+		params := &SelectionRangeParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Positions: []Position{
+				{Line: 0, Character: 5},
+				{Line: 1, Character: 1},
+			},
+		}
+
+		selectionRanges, err := s.textDocumentSelectionRange(params)
+		require.NoError(t, err)
+		require.Len(t, selectionRanges, 2)
+		assert.Equal(t, Range{
+			Start: Position{Line: 0, Character: 4},
+			End:   Position{Line: 0, Character: 9},
+		}, selectionRanges[0].Range)
+		assert.Equal(t, Range{
+			Start: Position{Line: 1, Character: 0},
+			End:   Position{Line: 1, Character: 3},
+		}, selectionRanges[1].Range)
+	})
+
+	t.Run("NonSpxFile", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		params := &SelectionRangeParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///notexist.gop"},
+		}
+
+		selectionRanges, err := s.textDocumentSelectionRange(params)
+		require.EqualError(t, err, `file "notexist.gop" does not have .spx extension`)
+		assert.Nil(t, selectionRanges)
+	})
+}
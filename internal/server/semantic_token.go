@@ -4,7 +4,9 @@ import (
 	"go/types"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	gopast "github.com/goplus/gop/ast"
 	goptoken "github.com/goplus/gop/token"
@@ -29,6 +31,7 @@ var (
 		NumberType,
 		OperatorType,
 		LabelType,
+		EventType,
 	}
 
 	// semanticTokenModifiersLegend defines the semantic token modifiers we
@@ -38,9 +41,22 @@ var (
 		ModReadonly,
 		ModStatic,
 		ModDefaultLibrary,
+		modSpxResource,
+		modSpxOverloaded,
 	}
 )
 
+const (
+	// modSpxResource is the custom semantic token modifier for identifiers
+	// and string literals that refer to spx resources, such as sprites
+	// auto-bound to Game fields or sound names passed to `play`.
+	modSpxResource SemanticTokenModifiers = "spxResource"
+
+	// modSpxOverloaded is the custom semantic token modifier for Go+
+	// overloaded functions and methods, such as `Sprite.turn`.
+	modSpxOverloaded SemanticTokenModifiers = "spxOverloaded"
+)
+
 // getSemanticTokenTypeIndex returns the index of the given token type in the legend.
 func getSemanticTokenTypeIndex(tokenType SemanticTokenTypes) uint32 {
 	idx := slices.Index(semanticTokenTypesLegend, tokenType)
@@ -69,9 +85,191 @@ type semanticTokenInfo struct {
 	tokenModifiers []SemanticTokenModifiers
 }
 
+// semanticTokensResult is a semantic tokens result previously sent to the
+// client, kept for computing deltas.
+type semanticTokensResult struct {
+	id       string
+	data     []uint32
+	lastUsed time.Time
+}
+
+// semanticTokensLegend returns the semantic tokens legend of the server.
+func semanticTokensLegend() SemanticTokensLegend {
+	legend := SemanticTokensLegend{
+		TokenTypes:     make([]string, 0, len(semanticTokenTypesLegend)),
+		TokenModifiers: make([]string, 0, len(semanticTokenModifiersLegend)),
+	}
+	for _, tokenType := range semanticTokenTypesLegend {
+		legend.TokenTypes = append(legend.TokenTypes, string(tokenType))
+	}
+	for _, tokenModifier := range semanticTokenModifiersLegend {
+		legend.TokenModifiers = append(legend.TokenModifiers, string(tokenModifier))
+	}
+	return legend
+}
+
+// clientSemanticTokensLegend returns the semantic tokens legend negotiated with
+// the client, which only contains the standard token types and modifiers the
+// client supports. Custom spx modifiers are always kept as clients ignore
+// modifiers they do not understand.
+func (s *Server) clientSemanticTokensLegend() SemanticTokensLegend {
+	legend := semanticTokensLegend()
+	clientCapabilities := s.clientCapabilities.Load()
+	if clientCapabilities == nil {
+		return legend
+	}
+	clientSemanticTokens := clientCapabilities.TextDocument.SemanticTokens
+	if len(clientSemanticTokens.TokenTypes) > 0 {
+		legend.TokenTypes = slices.DeleteFunc(legend.TokenTypes, func(tokenType string) bool {
+			return !slices.Contains(clientSemanticTokens.TokenTypes, tokenType)
+		})
+	}
+	if len(clientSemanticTokens.TokenModifiers) > 0 {
+		legend.TokenModifiers = slices.DeleteFunc(legend.TokenModifiers, func(tokenModifier string) bool {
+			isCustom := tokenModifier == string(modSpxResource) || tokenModifier == string(modSpxOverloaded)
+			return !isCustom && !slices.Contains(clientSemanticTokens.TokenModifiers, tokenModifier)
+		})
+	}
+	return legend
+}
+
+// tailorSemanticTokens re-encodes the given semantic tokens data, which is
+// encoded with the full legend of the server, for the given legend. Tokens of
+// types missing from the legend are dropped, as are missing modifiers.
+func tailorSemanticTokens(data []uint32, legend SemanticTokensLegend) []uint32 {
+	if len(legend.TokenTypes) == len(semanticTokenTypesLegend) && len(legend.TokenModifiers) == len(semanticTokenModifiersLegend) {
+		return data
+	}
+
+	typeIndexes := make([]int, len(semanticTokenTypesLegend))
+	for i, tokenType := range semanticTokenTypesLegend {
+		typeIndexes[i] = slices.Index(legend.TokenTypes, string(tokenType))
+	}
+	modifierIndexes := make([]int, len(semanticTokenModifiersLegend))
+	for i, tokenModifier := range semanticTokenModifiersLegend {
+		modifierIndexes[i] = slices.Index(legend.TokenModifiers, string(tokenModifier))
+	}
+
+	var (
+		tailored           = make([]uint32, 0, len(data))
+		line, char         uint32
+		prevLine, prevChar uint32
+	)
+	for i := 0; i+4 < len(data); i += 5 {
+		if deltaLine := data[i]; deltaLine > 0 {
+			line += deltaLine
+			char = data[i+1]
+		} else {
+			char += data[i+1]
+		}
+
+		typeIndex := data[i+3]
+		if int(typeIndex) >= len(typeIndexes) || typeIndexes[typeIndex] < 0 {
+			continue
+		}
+		var modifiersMask uint32
+		for bit, modifierIndex := range modifierIndexes {
+			if modifierIndex >= 0 && data[i+4]&(1<<uint32(bit)) != 0 {
+				modifiersMask |= 1 << uint32(modifierIndex)
+			}
+		}
+
+		if line == prevLine {
+			tailored = append(tailored, 0, char-prevChar, data[i+2], uint32(typeIndexes[typeIndex]), modifiersMask)
+		} else {
+			tailored = append(tailored, line-prevLine, char, data[i+2], uint32(typeIndexes[typeIndex]), modifiersMask)
+		}
+		prevLine = line
+		prevChar = char
+	}
+	return tailored
+}
+
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_semanticTokens
-func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (tokens *SemanticTokens, err error) {
-	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (*SemanticTokens, error) {
+	tokens, err := s.computeSemanticTokens(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		return nil, nil
+	}
+	tokens.Data = tailorSemanticTokens(tokens.Data, s.clientSemanticTokensLegend())
+	tokens.ResultID, _, _ = s.swapSemanticTokensResult(params.TextDocument.URI, tokens.Data)
+	return tokens, nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#semanticTokens_deltaRequest
+func (s *Server) textDocumentSemanticTokensFullDelta(params *SemanticTokensDeltaParams) (any, error) {
+	tokens, err := s.computeSemanticTokens(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if tokens == nil {
+		return nil, nil
+	}
+	tokens.Data = tailorSemanticTokens(tokens.Data, s.clientSemanticTokensLegend())
+
+	resultID, prev, ok := s.swapSemanticTokensResult(params.TextDocument.URI, tokens.Data)
+	if !ok || prev.id != params.PreviousResultID {
+		tokens.ResultID = resultID
+		return tokens, nil
+	}
+	return &SemanticTokensDelta{
+		ResultID: resultID,
+		Edits:    diffSemanticTokens(prev.data, tokens.Data),
+	}, nil
+}
+
+// swapSemanticTokensResult records data as the latest semantic tokens result
+// for the given document and returns its new result ID, along with the
+// previously recorded result if any.
+func (s *Server) swapSemanticTokensResult(documentURI DocumentURI, data []uint32) (resultID string, prev semanticTokensResult, ok bool) {
+	resultID = strconv.FormatUint(s.semanticTokensResultID.Add(1), 10)
+
+	s.lastSemanticTokensMu.Lock()
+	prev, ok = s.lastSemanticTokens[documentURI]
+	s.lastSemanticTokens[documentURI] = semanticTokensResult{id: resultID, data: data, lastUsed: time.Now()}
+	s.lastSemanticTokensMu.Unlock()
+
+	s.enforceMemoryBudget(nil)
+	return
+}
+
+// diffSemanticTokens computes the edits that transform the old semantic tokens
+// data into the new one. It produces at most one edit covering the changed
+// tokens between the common prefix and suffix. Edits are aligned to whole
+// tokens, each of which consists of 5 integers.
+func diffSemanticTokens(oldData, newData []uint32) []SemanticTokensEdit {
+	const tokenLen = 5
+
+	prefixLen := 0
+	for prefixLen < len(oldData) && prefixLen < len(newData) && oldData[prefixLen] == newData[prefixLen] {
+		prefixLen++
+	}
+	if prefixLen == len(oldData) && prefixLen == len(newData) {
+		return []SemanticTokensEdit{}
+	}
+	prefixLen -= prefixLen % tokenLen
+
+	suffixLen := 0
+	for suffixLen < len(oldData)-prefixLen && suffixLen < len(newData)-prefixLen &&
+		oldData[len(oldData)-1-suffixLen] == newData[len(newData)-1-suffixLen] {
+		suffixLen++
+	}
+	suffixLen -= suffixLen % tokenLen
+
+	return []SemanticTokensEdit{{
+		Start:       uint32(prefixLen),
+		DeleteCount: uint32(len(oldData) - prefixLen - suffixLen),
+		Data:        slices.Clone(newData[prefixLen : len(newData)-suffixLen]),
+	}}
+}
+
+// computeSemanticTokens computes the semantic tokens for the given document.
+// It returns nil if the document cannot be found.
+func (s *Server) computeSemanticTokens(documentURI DocumentURI) (tokens *SemanticTokens, err error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(documentURI)
 	if err != nil {
 		return nil, err
 	}
@@ -79,14 +277,14 @@ func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (t
 		return nil, nil
 	}
 
-	if tokensIface, ok := result.computedCache.semanticTokens.Load(params.TextDocument.URI); ok {
+	if tokensIface, ok := result.computedCache.semanticTokens.Load(documentURI); ok {
 		return &SemanticTokens{
 			Data: tokensIface.([]uint32),
 		}, nil
 	}
 	defer func() {
 		if err == nil {
-			result.computedCache.semanticTokens.Store(params.TextDocument.URI, slices.Clip(tokens.Data))
+			result.computedCache.semanticTokens.Store(documentURI, slices.Clip(tokens.Data))
 		}
 	}()
 
@@ -110,6 +308,14 @@ func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (t
 		})
 	}
 
+	spxResourceRefNodes := make(map[gopast.Node]struct{})
+	for _, ref := range result.spxResourceRefs {
+		if result.nodeASTFile(ref.Node) == astFile {
+			spxResourceRefNodes[ref.Node] = struct{}{}
+		}
+	}
+	spxEventHandlerCallees := make(map[*gopast.Ident]struct{})
+
 	gopast.Inspect(astFile, func(node gopast.Node) bool {
 		if node == nil || !node.Pos().IsValid() {
 			return true
@@ -177,11 +383,16 @@ func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (t
 				tokenType = VariableType
 				modifiers = append(modifiers, ModStatic, ModReadonly)
 			case *types.Func:
-				if obj.Type().(*types.Signature).Recv() != nil {
+				if _, ok := spxEventHandlerCallees[node]; ok {
+					tokenType = EventType
+				} else if obj.Type().(*types.Signature).Recv() != nil {
 					tokenType = MethodType
 				} else {
 					tokenType = FunctionType
 				}
+				if isGopOverloadedFuncName(obj.Name()) || isGopOverloadableFunc(obj) {
+					modifiers = append(modifiers, modSpxOverloaded)
+				}
 			case *types.PkgName:
 				tokenType = NamespaceType
 			case *types.Label:
@@ -193,16 +404,25 @@ func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (t
 			if obj.Pkg() != nil && obj.Pkg().Path() != "main" && !strings.Contains(obj.Pkg().Path(), ".") {
 				modifiers = append(modifiers, ModDefaultLibrary)
 			}
+			if _, ok := spxResourceRefNodes[node]; ok {
+				modifiers = append(modifiers, modSpxResource)
+			}
 			addToken(node.Pos(), node.End(), tokenType, modifiers)
 		case *gopast.BasicLit:
-			var tokenType SemanticTokenTypes
+			var (
+				tokenType SemanticTokenTypes
+				modifiers []SemanticTokenModifiers
+			)
 			switch node.Kind {
 			case goptoken.STRING, goptoken.CHAR, goptoken.CSTRING:
 				tokenType = StringType
 			case goptoken.INT, goptoken.FLOAT, goptoken.IMAG, goptoken.RAT:
 				tokenType = NumberType
 			}
-			addToken(node.ValuePos, node.ValuePos+goptoken.Pos(len(node.Value)), tokenType, nil)
+			if _, ok := spxResourceRefNodes[node]; ok {
+				modifiers = append(modifiers, modSpxResource)
+			}
+			addToken(node.ValuePos, node.ValuePos+goptoken.Pos(len(node.Value)), tokenType, modifiers)
 
 			if node.Extra != nil && len(node.Extra.Parts) > 0 {
 				pos := node.ValuePos
@@ -258,6 +478,10 @@ func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (t
 			}
 			addToken(node.Rparen, node.Rparen+1, OperatorType, nil)
 		case *gopast.CallExpr:
+			if funcIdent, ok := node.Fun.(*gopast.Ident); ok && len(node.Args) > 0 &&
+				isSpxEventHandlerFuncName(funcIdent.Name) && isSpxPkgObject(result.typeInfo.ObjectOf(funcIdent)) {
+				spxEventHandlerCallees[funcIdent] = struct{}{}
+			}
 			addToken(node.Lparen, node.Lparen+1, OperatorType, nil)
 			addToken(node.Rparen, node.Rparen+1, OperatorType, nil)
 			if node.Ellipsis.IsValid() {
@@ -484,9 +708,11 @@ func (s *Server) textDocumentSemanticTokensFull(params *SemanticTokensParams) (t
 		start := result.fset.Position(info.startPos)
 		end := result.fset.Position(info.endPos)
 
-		line := uint32(start.Line - 1)
-		char := uint32(start.Column - 1)
-		length := uint32(end.Offset - start.Offset)
+		startPosition := result.fromPosition(astFile, start)
+		line := startPosition.Line
+		char := startPosition.Character
+		text := string(astFile.Code[start.Offset:end.Offset])
+		length := uint32(utf8OffsetToEncoding(text, len(text), result.positionEncoding))
 		if line < prevLine || (line == prevLine && char < prevChar) {
 			continue
 		}
@@ -1,8 +1,12 @@
 package server
 
 import (
+	"maps"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/goplus/goxlsw/internal/vfs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -34,13 +38,13 @@ onStart => {
 		assert.Equal(t, []uint32{
 			1, 0, 3, 9, 0, // var
 			0, 4, 1, 13, 0, // (
-			1, 1, 8, 5, 1, // MySprite
+			1, 1, 8, 5, 17, // MySprite
 			0, 9, 6, 1, 0, // Sprite
 			0, 0, 6, 2, 0, // Sprite
 			1, 0, 1, 13, 0, // )
-			1, 0, 8, 5, 0, // MySprite
+			1, 0, 8, 5, 16, // MySprite
 			0, 8, 1, 13, 0, // .
-			0, 1, 4, 8, 0, // turn
+			0, 1, 4, 8, 32, // turn
 			0, 5, 4, 5, 6, // Left
 			1, 0, 3, 7, 0, // run
 			0, 4, 8, 11, 0, // assets
@@ -57,14 +61,209 @@ onStart => {
 		require.NoError(t, err)
 		require.NotNil(t, mySpriteTokens)
 		assert.Equal(t, []uint32{
-			1, 0, 7, 8, 0, // onStart
+			1, 0, 7, 15, 0, // onStart
 			0, 8, 2, 13, 0, // =>
 			0, 3, 1, 13, 0, // {
-			1, 1, 8, 5, 0, // MySprite
+			1, 1, 8, 5, 16, // MySprite
 			0, 8, 1, 13, 0, // .
-			0, 1, 4, 8, 0, // turn
+			0, 1, 4, 8, 32, // turn
 			0, 5, 5, 5, 6, // Right
 			1, 0, 1, 13, 0, // }
 		}, mySpriteTokens.Data)
 	})
+
+	t.Run("SpxResourceStringLit", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+play "Sound1"
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":               []byte(`{}`),
+			"assets/sounds/Sound1/index.json": []byte(`{"path":"sound.wav"}`),
+		}), nil)
+
+		tokens, err := s.textDocumentSemanticTokensFull(&SemanticTokensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, tokens)
+		assert.Equal(t, []uint32{
+			1, 0, 4, 8, 32, // play
+			0, 5, 8, 11, 16, // "Sound1"
+			1, 0, 3, 7, 0, // run
+			0, 4, 8, 11, 0, // assets
+			0, 10, 1, 13, 0, // {
+			0, 1, 5, 6, 0, // Title
+			0, 5, 1, 13, 0, // :
+			0, 2, 9, 11, 0, // My Game
+			0, 9, 1, 13, 0, // }
+		}, tokens.Data)
+	})
+}
+
+func TestServerTextDocumentSemanticTokensFullDelta(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		files := map[string]vfs.MapFile{
+			"main.spx": {
+				Content: []byte(`
+var (
+	Score int
+)
+run "assets", {Title: "My Game"}
+`),
+				ModTime: time.Unix(1, 0),
+			},
+			"assets/index.json": {Content: []byte(`{}`)},
+		}
+		s := New(vfs.NewMapFS(func() map[string]vfs.MapFile {
+			return maps.Clone(files)
+		}), nil)
+
+		fullTokens, err := s.textDocumentSemanticTokensFull(&SemanticTokensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, fullTokens)
+		require.NotEmpty(t, fullTokens.ResultID)
+
+		unchanged, err := s.textDocumentSemanticTokensFullDelta(&SemanticTokensDeltaParams{
+			TextDocument:     TextDocumentIdentifier{URI: "file:///main.spx"},
+			PreviousResultID: fullTokens.ResultID,
+		})
+		require.NoError(t, err)
+		require.IsType(t, &SemanticTokensDelta{}, unchanged)
+		unchangedDelta := unchanged.(*SemanticTokensDelta)
+		assert.NotEqual(t, fullTokens.ResultID, unchangedDelta.ResultID)
+		assert.Empty(t, unchangedDelta.Edits)
+
+		files["main.spx"] = vfs.MapFile{
+			Content: []byte(`
+var (
+	Score int
+	Lives int
+)
+run "assets", {Title: "My Game"}
+`),
+			ModTime: time.Unix(2, 0),
+		}
+		changed, err := s.textDocumentSemanticTokensFullDelta(&SemanticTokensDeltaParams{
+			TextDocument:     TextDocumentIdentifier{URI: "file:///main.spx"},
+			PreviousResultID: unchangedDelta.ResultID,
+		})
+		require.NoError(t, err)
+		require.IsType(t, &SemanticTokensDelta{}, changed)
+		changedDelta := changed.(*SemanticTokensDelta)
+		require.Len(t, changedDelta.Edits, 1)
+		assert.Equal(t, uint32(25), changedDelta.Edits[0].Start)
+		assert.Equal(t, uint32(0), changedDelta.Edits[0].DeleteCount)
+
+		changedFullTokens, err := s.textDocumentSemanticTokensFull(&SemanticTokensParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, changedFullTokens)
+		edit := changedDelta.Edits[0]
+		patched := slices.Concat(
+			fullTokens.Data[:edit.Start],
+			edit.Data,
+			fullTokens.Data[edit.Start+edit.DeleteCount:],
+		)
+		assert.Equal(t, changedFullTokens.Data, patched)
+	})
+
+	t.Run("UnknownPreviousResultID", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(`run "assets", {Title: "My Game"}`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		tokens, err := s.textDocumentSemanticTokensFullDelta(&SemanticTokensDeltaParams{
+			TextDocument:     TextDocumentIdentifier{URI: "file:///main.spx"},
+			PreviousResultID: "unknown",
+		})
+		require.NoError(t, err)
+		require.IsType(t, &SemanticTokens{}, tokens)
+		assert.NotEmpty(t, tokens.(*SemanticTokens).ResultID)
+		assert.NotEmpty(t, tokens.(*SemanticTokens).Data)
+	})
+}
+
+func TestDiffSemanticTokens(t *testing.T) {
+	t.Run("Identical", func(t *testing.T) {
+		edits := diffSemanticTokens([]uint32{1, 0, 3, 9, 0}, []uint32{1, 0, 3, 9, 0})
+		assert.Empty(t, edits)
+	})
+
+	t.Run("ChangedMiddleToken", func(t *testing.T) {
+		edits := diffSemanticTokens(
+			[]uint32{1, 0, 3, 9, 0, 0, 4, 1, 13, 0, 1, 1, 8, 5, 1},
+			[]uint32{1, 0, 3, 9, 0, 0, 4, 2, 13, 0, 1, 1, 8, 5, 1},
+		)
+		assert.Equal(t, []SemanticTokensEdit{{
+			Start:       5,
+			DeleteCount: 5,
+			Data:        []uint32{0, 4, 2, 13, 0},
+		}}, edits)
+	})
+
+	t.Run("RemovedAllTokens", func(t *testing.T) {
+		edits := diffSemanticTokens([]uint32{1, 0, 3, 9, 0}, []uint32{})
+		assert.Equal(t, []SemanticTokensEdit{{
+			Start:       0,
+			DeleteCount: 5,
+			Data:        []uint32{},
+		}}, edits)
+	})
+}
+
+func TestServerClientSemanticTokensLegend(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		s.clientCapabilities.Store(&ClientCapabilities{
+			TextDocument: TextDocumentClientCapabilities{
+				SemanticTokens: SemanticTokensClientCapabilities{
+					TokenTypes:     []string{"keyword", "method", "class"},
+					TokenModifiers: []string{"defaultLibrary"},
+				},
+			},
+		})
+		assert.Equal(t, SemanticTokensLegend{
+			TokenTypes:     []string{"method", "keyword"},
+			TokenModifiers: []string{"defaultLibrary", "spxResource", "spxOverloaded"},
+		}, s.clientSemanticTokensLegend())
+	})
+
+	t.Run("NoClientPreference", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		s.clientCapabilities.Store(&ClientCapabilities{})
+		assert.Equal(t, semanticTokensLegend(), s.clientSemanticTokensLegend())
+	})
+}
+
+func TestTailorSemanticTokens(t *testing.T) {
+	legend := SemanticTokensLegend{
+		TokenTypes:     []string{"keyword", "method"},
+		TokenModifiers: []string{"defaultLibrary", "spxOverloaded"},
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		data := tailorSemanticTokens(
+			[]uint32{1, 0, 3, 9, 0, 0, 4, 1, 13, 0, 1, 1, 8, 8, 40},
+			legend,
+		)
+		assert.Equal(t, []uint32{1, 0, 3, 0, 0, 1, 1, 8, 1, 3}, data)
+	})
+
+	t.Run("DroppedFirstTokenOfLine", func(t *testing.T) {
+		data := tailorSemanticTokens(
+			[]uint32{1, 0, 3, 13, 0, 0, 4, 2, 9, 0},
+			legend,
+		)
+		assert.Equal(t, []uint32{1, 4, 2, 0, 0}, data)
+	})
+
+	t.Run("FullLegend", func(t *testing.T) {
+		data := []uint32{1, 0, 3, 13, 0}
+		assert.Equal(t, data, tailorSemanticTokens(data, semanticTokensLegend()))
+	})
 }
@@ -1,10 +1,14 @@
 package server
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/goplus/goxlsw/internal/jsonrpc2"
 	"github.com/goplus/goxlsw/internal/vfs"
@@ -17,25 +21,108 @@ type MessageReplier interface {
 	// The message can be one of:
 	//   - [jsonrpc2.Response]: sent in response to a call.
 	//   - [jsonrpc2.Notification]: sent for server-initiated notifications.
+	//   - [jsonrpc2.Call]: sent for server-initiated calls, whose responses
+	//     are expected to be passed to [Server.HandleMessage].
 	ReplyMessage(m jsonrpc2.Message) error
 }
 
 // Server is the core language server implementation that handles LSP messages.
 type Server struct {
-	workspaceRootURI   DocumentURI
-	workspaceRootFS    *vfs.MapFS
-	replier            MessageReplier
-	lastCompileCache   *compileCache
-	lastCompileCacheMu sync.Mutex
+	workspaceRootURI    DocumentURI
+	workspaceRootFS     *vfs.MapFS
+	replier             MessageReplier
+	logger              *slog.Logger
+	workspaceRootFolder *workspaceFolder
+	workspaceFolders    []*workspaceFolder
+	workspaceFoldersMu  sync.Mutex
+
+	clientCapabilities atomic.Pointer[ClientCapabilities]
+	currentConfig      atomic.Pointer[Config]
+	currentTrace       atomic.Pointer[TraceValue]
+	telemetry          Telemetry
+
+	semanticTokensResultID atomic.Uint64
+	lastSemanticTokens     map[DocumentURI]semanticTokensResult
+	lastSemanticTokensMu   sync.Mutex
+
+	completionResultID atomic.Uint64
+	lastCompletion     completionResult
+	lastCompletionMu   sync.Mutex
+
+	documentOverlay   map[string]vfs.MapFile
+	documentVersions  map[string]int32
+	documentOverlayMu sync.Mutex
+
+	diagnosticsDebounceDelay time.Duration
+
+	commandMetrics commandMetrics
+
+	extraAnalyzers []*analyzer
+	analyzersMu    sync.Mutex
+
+	analysisWorkers int
+
+	memoryBudget    int64
+	memoryEvictions atomic.Int64
+
+	packageCache        *packageCache
+	cache               Cache
+	extraClassfileKinds []*ClassfileKind
+
+	lastProgressTokenID atomic.Uint64
+
+	cancelFuncs   map[jsonrpc2.ID]context.CancelFunc
+	cancelFuncsMu sync.Mutex
+
+	lastCallID     atomic.Int64
+	pendingCalls   map[jsonrpc2.ID]chan *jsonrpc2.Response
+	pendingCallsMu sync.Mutex
+}
+
+// defaultWorkspaceRootURI is the default URI of the workspace root.
+const defaultWorkspaceRootURI = "file:///"
+
+// Option configures a [Server] created by [New].
+type Option func(s *Server)
+
+// WithWorkspaceRootURI sets the URI of the workspace root, which is where the
+// file system passed to [New] is mounted. It defaults to "file:///".
+func WithWorkspaceRootURI(uri DocumentURI) Option {
+	return func(s *Server) {
+		if !strings.HasSuffix(string(uri), "/") {
+			uri += "/"
+		}
+		s.workspaceRootURI = uri
+	}
 }
 
 // New creates a new Server instance.
-func New(mapFS *vfs.MapFS, replier MessageReplier) *Server {
-	return &Server{
-		workspaceRootURI: "file:///", // TODO: Allow setting this via the `initialize` request.
+func New(mapFS *vfs.MapFS, replier MessageReplier, opts ...Option) *Server {
+	s := &Server{
+		workspaceRootURI: defaultWorkspaceRootURI,
 		workspaceRootFS:  mapFS,
 		replier:          replier,
+
+		lastSemanticTokens: make(map[DocumentURI]semanticTokensResult),
+		documentOverlay:    make(map[string]vfs.MapFile),
+		documentVersions:   make(map[string]int32),
+		cancelFuncs:        make(map[jsonrpc2.ID]context.CancelFunc),
+		pendingCalls:       make(map[jsonrpc2.ID]chan *jsonrpc2.Response),
+		telemetry:          noopTelemetry{},
+
+		analysisWorkers:          defaultAnalysisWorkers(),
+		diagnosticsDebounceDelay: defaultDiagnosticsDebounceDelay,
+	}
+	s.logger = slog.New(&logHandler{s: s})
+	for _, opt := range opts {
+		opt(s)
 	}
+	if s.packageCache != nil {
+		s.packageCache.cache = s.cache
+		s.packageCache.logger = s.logger
+	}
+	s.workspaceRootFolder, _ = newWorkspaceFolder(s.workspaceRootURI, WorkspaceFolder{URI: URI(s.workspaceRootURI)})
+	return s
 }
 
 // HandleMessage handles an incoming LSP message.
@@ -45,21 +132,26 @@ func (s *Server) HandleMessage(m jsonrpc2.Message) error {
 		return s.handleCall(m)
 	case *jsonrpc2.Notification:
 		return s.handleNotification(m)
+	case *jsonrpc2.Response:
+		return s.handleResponse(m)
 	}
 	return fmt.Errorf("unsupported message type: %T", m)
 }
 
 // handleCall handles a call message.
 func (s *Server) handleCall(c *jsonrpc2.Call) error {
+	s.logger.Debug("received request", "requestID", fmt.Sprint(c.ID()), "method", c.Method())
 	switch c.Method() {
 	case "initialize":
 		var params InitializeParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		return errors.New("TODO")
+		s.runWithResponse(c, func() (any, error) {
+			return s.initialize(&params)
+		})
 	case "shutdown":
-		s.runWithResponse(c.ID(), func() (any, error) {
+		s.runWithResponse(c, func() (any, error) {
 			return nil, nil // Protocol conformance only.
 		})
 	case "textDocument/hover":
@@ -67,7 +159,7 @@ func (s *Server) handleCall(c *jsonrpc2.Call) error {
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
+		s.runWithResponse(c, func() (any, error) {
 			return s.textDocumentHover(&params)
 		})
 	case "textDocument/completion":
@@ -75,15 +167,23 @@ func (s *Server) handleCall(c *jsonrpc2.Call) error {
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentCompletion(&params)
+		s.runWithContextResponse(c, func(ctx context.Context) (any, error) {
+			return s.textDocumentCompletion(ctx, &params)
+		})
+	case "completionItem/resolve":
+		var params CompletionItem
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.completionItemResolve(&params)
 		})
 	case "textDocument/signatureHelp":
 		var params SignatureHelpParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
+		s.runWithResponse(c, func() (any, error) {
 			return s.textDocumentSignatureHelp(&params)
 		})
 	case "textDocument/declaration":
@@ -91,7 +191,7 @@ func (s *Server) handleCall(c *jsonrpc2.Call) error {
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
+		s.runWithResponse(c, func() (any, error) {
 			return s.textDocumentDeclaration(&params)
 		})
 	case "textDocument/definition":
@@ -99,7 +199,7 @@ func (s *Server) handleCall(c *jsonrpc2.Call) error {
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
+		s.runWithResponse(c, func() (any, error) {
 			return s.textDocumentDefinition(&params)
 		})
 	case "textDocument/typeDefinition":
@@ -107,7 +207,7 @@ func (s *Server) handleCall(c *jsonrpc2.Call) error {
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
+		s.runWithResponse(c, func() (any, error) {
 			return s.textDocumentTypeDefinition(&params)
 		})
 	case "textDocument/implementation":
@@ -115,7 +215,7 @@ func (s *Server) handleCall(c *jsonrpc2.Call) error {
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
+		s.runWithResponse(c, func() (any, error) {
 			return s.textDocumentImplementation(&params)
 		})
 	case "textDocument/references":
@@ -123,7 +223,7 @@ func (s *Server) handleCall(c *jsonrpc2.Call) error {
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
+		s.runWithResponse(c, func() (any, error) {
 			return s.textDocumentReferences(&params)
 		})
 	case "textDocument/documentHighlight":
@@ -131,47 +231,171 @@ func (s *Server) handleCall(c *jsonrpc2.Call) error {
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
+		s.runWithResponse(c, func() (any, error) {
 			return s.textDocumentDocumentHighlight(&params)
 		})
+	case "textDocument/codeLens":
+		var params CodeLensParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.textDocumentCodeLens(&params)
+		})
+	case "codeLens/resolve":
+		var params CodeLens
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.codeLensResolve(&params)
+		})
 	case "textDocument/documentLink":
 		var params DocumentLinkParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
+		s.runWithResponse(c, func() (any, error) {
 			return s.textDocumentDocumentLink(&params)
 		})
+	case "documentLink/resolve":
+		var params DocumentLink
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.documentLinkResolve(&params)
+		})
+	case "textDocument/foldingRange":
+		var params FoldingRangeParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.textDocumentFoldingRange(&params)
+		})
+	case "textDocument/linkedEditingRange":
+		var params LinkedEditingRangeParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.textDocumentLinkedEditingRange(&params)
+		})
+	case "textDocument/selectionRange":
+		var params SelectionRangeParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.textDocumentSelectionRange(&params)
+		})
+	case "textDocument/prepareCallHierarchy":
+		var params CallHierarchyPrepareParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.textDocumentPrepareCallHierarchy(&params)
+		})
+	case "callHierarchy/incomingCalls":
+		var params CallHierarchyIncomingCallsParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.callHierarchyIncomingCalls(&params)
+		})
+	case "callHierarchy/outgoingCalls":
+		var params CallHierarchyOutgoingCallsParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.callHierarchyOutgoingCalls(&params)
+		})
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			symbols, err := s.textDocumentDocumentSymbol(&params)
+			if err != nil || symbols == nil || s.clientSupportsHierarchicalDocumentSymbols() {
+				return symbols, err
+			}
+			return flattenDocumentSymbols(params.TextDocument.URI, symbols), nil
+		})
 	case "textDocument/diagnostic":
 		var params DocumentDiagnosticParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.textDocumentDiagnostic(&params)
+		s.runWithContextResponse(c, func(ctx context.Context) (any, error) {
+			return s.textDocumentDiagnostic(ctx, &params)
 		})
 	case "workspace/diagnostic":
 		var params WorkspaceDiagnosticParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.workspaceDiagnostic(&params)
+		s.runWithContextResponse(c, func(ctx context.Context) (any, error) {
+			return s.workspaceDiagnostic(ctx, &params)
+		})
+	case "textDocument/codeAction":
+		var params CodeActionParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.textDocumentCodeAction(&params)
+		})
+	case "codeAction/resolve":
+		var params CodeAction
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.codeActionResolve(&params)
 		})
 	case "textDocument/formatting":
 		var params DocumentFormattingParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
+		s.runWithResponse(c, func() (any, error) {
 			return s.textDocumentFormatting(&params)
 		})
+	case "textDocument/willSaveWaitUntil":
+		var params WillSaveTextDocumentParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.textDocumentWillSaveWaitUntil(&params)
+		})
+	case "textDocument/rangeFormatting":
+		var params DocumentRangeFormattingParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.textDocumentRangeFormatting(&params)
+		})
+	case "textDocument/onTypeFormatting":
+		var params DocumentOnTypeFormattingParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.textDocumentOnTypeFormatting(&params)
+		})
 	case "textDocument/prepareRename":
 		var params PrepareRenameParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
+		s.runWithResponse(c, func() (any, error) {
 			return s.textDocumentPrepareRename(&params)
 		})
 	case "textDocument/rename":
@@ -179,24 +403,48 @@ func (s *Server) handleCall(c *jsonrpc2.Call) error {
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
+		s.runWithResponse(c, func() (any, error) {
 			return s.textDocumentRename(&params)
 		})
+	case "textDocument/inlayHint":
+		var params InlayHintParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.textDocumentInlayHint(&params)
+		})
 	case "textDocument/semanticTokens/full":
 		var params SemanticTokensParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
+		s.runWithResponse(c, func() (any, error) {
 			return s.textDocumentSemanticTokensFull(&params)
 		})
+	case "textDocument/semanticTokens/full/delta":
+		var params SemanticTokensDeltaParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithResponse(c, func() (any, error) {
+			return s.textDocumentSemanticTokensFullDelta(&params)
+		})
+	case "workspace/symbol":
+		var params WorkspaceSymbolParams
+		if err := UnmarshalJSON(c.Params(), &params); err != nil {
+			return s.replyParseError(c.ID(), err)
+		}
+		s.runWithContextResponse(c, func(ctx context.Context) (any, error) {
+			return s.workspaceSymbol(ctx, &params)
+		})
 	case "workspace/executeCommand":
 		var params ExecuteCommandParams
 		if err := UnmarshalJSON(c.Params(), &params); err != nil {
 			return s.replyParseError(c.ID(), err)
 		}
-		s.runWithResponse(c.ID(), func() (any, error) {
-			return s.workspaceExecuteCommand(&params)
+		s.runWithContextResponse(c, func(ctx context.Context) (any, error) {
+			return s.workspaceExecuteCommand(ctx, &params)
 		})
 	default:
 		return s.replyMethodNotFound(c.ID(), c.Method())
@@ -212,7 +460,39 @@ func (s *Server) handleNotification(n *jsonrpc2.Notification) error {
 		if err := UnmarshalJSON(n.Params(), &params); err != nil {
 			return fmt.Errorf("failed to parse initialized params: %w", err)
 		}
-		return errors.New("TODO")
+		s.registerWatchedFiles()
+		return nil
+	case "$/cancelRequest":
+		var params CancelParams
+		if err := UnmarshalJSON(n.Params(), &params); err != nil {
+			return fmt.Errorf("failed to parse cancelRequest params: %w", err)
+		}
+		s.cancelRequest(params.ID)
+		return nil
+	case "$/setTrace":
+		var params SetTraceParams
+		if err := UnmarshalJSON(n.Params(), &params); err != nil {
+			return fmt.Errorf("failed to parse setTrace params: %w", err)
+		}
+		return s.setTrace(&params)
+	case "workspace/didChangeConfiguration":
+		var params DidChangeConfigurationParams
+		if err := UnmarshalJSON(n.Params(), &params); err != nil {
+			return fmt.Errorf("failed to parse didChangeConfiguration params: %w", err)
+		}
+		return s.workspaceDidChangeConfiguration(&params)
+	case "workspace/didChangeWatchedFiles":
+		var params DidChangeWatchedFilesParams
+		if err := UnmarshalJSON(n.Params(), &params); err != nil {
+			return fmt.Errorf("failed to parse didChangeWatchedFiles params: %w", err)
+		}
+		return s.workspaceDidChangeWatchedFiles(&params)
+	case "workspace/didChangeWorkspaceFolders":
+		var params DidChangeWorkspaceFoldersParams
+		if err := UnmarshalJSON(n.Params(), &params); err != nil {
+			return fmt.Errorf("failed to parse didChangeWorkspaceFolders params: %w", err)
+		}
+		return s.workspaceDidChangeWorkspaceFolders(&params)
 	case "exit":
 		return nil // Protocol conformance only.
 	case "textDocument/didOpen":
@@ -220,13 +500,13 @@ func (s *Server) handleNotification(n *jsonrpc2.Notification) error {
 		if err := UnmarshalJSON(n.Params(), &params); err != nil {
 			return fmt.Errorf("failed to parse didOpen params: %w", err)
 		}
-		return errors.New("TODO")
+		return s.textDocumentDidOpen(&params)
 	case "textDocument/didChange":
 		var params DidChangeTextDocumentParams
 		if err := UnmarshalJSON(n.Params(), &params); err != nil {
 			return fmt.Errorf("failed to parse didChange params: %w", err)
 		}
-		return errors.New("TODO")
+		return s.textDocumentDidChange(&params)
 	case "textDocument/didSave":
 		var params DidSaveTextDocumentParams
 		if err := UnmarshalJSON(n.Params(), &params); err != nil {
@@ -238,15 +518,79 @@ func (s *Server) handleNotification(n *jsonrpc2.Notification) error {
 		if err := UnmarshalJSON(n.Params(), &params); err != nil {
 			return fmt.Errorf("failed to parse didClose params: %w", err)
 		}
-		return errors.New("TODO")
+		return s.textDocumentDidClose(&params)
+	}
+	return nil
+}
+
+// handleResponse handles a response message to a server-initiated call.
+func (s *Server) handleResponse(r *jsonrpc2.Response) error {
+	s.pendingCallsMu.Lock()
+	respChan, ok := s.pendingCalls[r.ID()]
+	delete(s.pendingCalls, r.ID())
+	s.pendingCallsMu.Unlock()
+	if !ok {
+		return fmt.Errorf("no pending call found for response ID: %v", r.ID())
 	}
+	respChan <- r
 	return nil
 }
 
-// publishDiagnostics sends diagnostic notifications to the client.
-func (s *Server) publishDiagnostics(uri DocumentURI, diagnostics []Diagnostic) error {
+// call sends a call to the client and waits for its response. The result of
+// the response is unmarshaled into result if it is not nil.
+func (s *Server) call(ctx context.Context, method string, params, result any) error {
+	id := jsonrpc2.NewIntID(s.lastCallID.Add(1))
+	c, err := jsonrpc2.NewCall(id, method, params)
+	if err != nil {
+		return fmt.Errorf("failed to create %s call: %w", method, err)
+	}
+
+	respChan := make(chan *jsonrpc2.Response, 1)
+	s.pendingCallsMu.Lock()
+	s.pendingCalls[id] = respChan
+	s.pendingCallsMu.Unlock()
+	defer func() {
+		s.pendingCallsMu.Lock()
+		delete(s.pendingCalls, id)
+		s.pendingCallsMu.Unlock()
+	}()
+
+	if err := s.replier.ReplyMessage(c); err != nil {
+		return fmt.Errorf("failed to send %s call: %w", method, err)
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case resp := <-respChan:
+		if err := resp.Err(); err != nil {
+			return fmt.Errorf("%s call failed: %w", method, err)
+		}
+		if result == nil {
+			return nil
+		}
+		if err := UnmarshalJSON(resp.Result(), result); err != nil {
+			return fmt.Errorf("failed to parse %s result: %w", method, err)
+		}
+		return nil
+	}
+}
+
+// notify sends a notification to the client.
+func (s *Server) notify(method string, params any) error {
+	n, err := jsonrpc2.NewNotification(method, params)
+	if err != nil {
+		return fmt.Errorf("failed to create %s notification: %w", method, err)
+	}
+	return s.replier.ReplyMessage(n)
+}
+
+// publishDiagnostics sends diagnostic notifications to the client. The version
+// is the one of the document the diagnostics are computed from, or 0 if the
+// document is not open.
+func (s *Server) publishDiagnostics(uri DocumentURI, version int32, diagnostics []Diagnostic) error {
 	params := &PublishDiagnosticsParams{
 		URI:         uri,
+		Version:     version,
 		Diagnostics: diagnostics,
 	}
 	n, err := jsonrpc2.NewNotification("textDocument/publishDiagnostics", params)
@@ -257,20 +601,61 @@ func (s *Server) publishDiagnostics(uri DocumentURI, diagnostics []Diagnostic) e
 }
 
 // run runs the given function in a goroutine and replies to the client with any
-// errors.
-func (s *Server) run(id jsonrpc2.ID, fn func() error) {
+// errors. The context passed to fn is canceled when the client cancels the call
+// via $/cancelRequest, in which case a request cancelled error is replied. It
+// also carries a logger attributing records to the call.
+func (s *Server) run(c *jsonrpc2.Call, fn func(ctx context.Context) error) {
+	id := c.ID()
+	ctx, cancel := context.WithCancel(s.withRequestLogger(context.Background(), id))
+	s.cancelFuncsMu.Lock()
+	s.cancelFuncs[id] = cancel
+	s.cancelFuncsMu.Unlock()
 	go func() {
-		if err := fn(); err != nil {
-			s.replyError(id, err)
+		defer func() {
+			s.cancelFuncsMu.Lock()
+			delete(s.cancelFuncs, id)
+			s.cancelFuncsMu.Unlock()
+			cancel()
+		}()
+		logger := s.loggerFor(ctx)
+		start := time.Now()
+		err := fn(ctx)
+		if err != nil && ctx.Err() != nil {
+			err = jsonrpc2.ErrRequestCancelled
+		}
+		duration := time.Since(start)
+		s.telemetry.RequestHandled(c.Method(), duration, err)
+		if err != nil {
+			logger.Debug("request failed", "error", err, "duration", duration)
+			if err := s.replyError(id, err); err != nil {
+				logger.Error("failed to reply error", "error", err)
+			}
+			return
 		}
+		logger.Debug("handled request", "duration", duration)
 	}()
 }
 
 // runWithResponse runs the given function in a goroutine and handles the response.
-func (s *Server) runWithResponse(id jsonrpc2.ID, fn func() (any, error)) {
-	s.run(id, func() error {
-		result, err := fn()
-		resp, err := jsonrpc2.NewResponse(id, result, err)
+func (s *Server) runWithResponse(c *jsonrpc2.Call, fn func() (any, error)) {
+	s.runWithContextResponse(c, func(context.Context) (any, error) {
+		return fn()
+	})
+}
+
+// runWithContextResponse is like [Server.runWithResponse] but passes the
+// context of the call to fn, which is expected to abandon its work once the
+// context is done.
+func (s *Server) runWithContextResponse(c *jsonrpc2.Call, fn func(ctx context.Context) (any, error)) {
+	s.run(c, func(ctx context.Context) error {
+		result, err := fn(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			return err
+		}
+		resp, err := jsonrpc2.NewResponse(c.ID(), result, nil)
 		if err != nil {
 			return err
 		}
@@ -278,6 +663,27 @@ func (s *Server) runWithResponse(id jsonrpc2.ID, fn func() (any, error)) {
 	})
 }
 
+// cancelRequest cancels the in-flight call with the given ID, which is either
+// a number or a string.
+func (s *Server) cancelRequest(id any) {
+	var callID jsonrpc2.ID
+	switch id := id.(type) {
+	case float64:
+		callID = jsonrpc2.NewIntID(int64(id))
+	case string:
+		callID = jsonrpc2.NewStringID(id)
+	default:
+		return
+	}
+
+	s.cancelFuncsMu.Lock()
+	cancel, ok := s.cancelFuncs[callID]
+	s.cancelFuncsMu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
 // replyError replies to the client with an error response.
 func (s *Server) replyError(id jsonrpc2.ID, err error) error {
 	resp, err := jsonrpc2.NewResponse(id, nil, err)
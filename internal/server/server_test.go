@@ -1,6 +1,18 @@
 package server
 
-import "github.com/goplus/goxlsw/internal/vfs"
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
 
 func newMapFSWithoutModTime(files map[string][]byte) *vfs.MapFS {
 	return vfs.NewMapFS(func() map[string]vfs.MapFile {
@@ -11,3 +23,249 @@ func newMapFSWithoutModTime(files map[string][]byte) *vfs.MapFS {
 		return fileMap
 	})
 }
+
+// messageReplierFunc is a [MessageReplier] implemented by a function.
+type messageReplierFunc func(m jsonrpc2.Message) error
+
+// ReplyMessage implements [MessageReplier].
+func (f messageReplierFunc) ReplyMessage(m jsonrpc2.Message) error {
+	return f(m)
+}
+
+func TestNew(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		assert.Equal(t, DocumentURI("file:///"), s.workspaceRootURI)
+		assert.Equal(t, DocumentURI("file:///"), s.defaultWorkspaceFolder().uri)
+	})
+
+	t.Run("WithWorkspaceRootURI", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil, WithWorkspaceRootURI("file:///home/user/project"))
+		assert.Equal(t, DocumentURI("file:///home/user/project/"), s.workspaceRootURI)
+		assert.Equal(t, DocumentURI("file:///home/user/project/main.spx"), s.toDocumentURI("main.spx"))
+
+		_, _, astFile, err := s.compileAndGetASTFileForDocumentURI("file:///home/user/project/main.spx")
+		require.NoError(t, err)
+		assert.NotNil(t, astFile)
+	})
+}
+
+func TestServerCancelRequest(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		responses := make(chan *jsonrpc2.Response, 1)
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), messageReplierFunc(func(m jsonrpc2.Message) error {
+			if resp, ok := m.(*jsonrpc2.Response); ok {
+				responses <- resp
+			}
+			return nil
+		}))
+
+		c, err := jsonrpc2.NewCall(jsonrpc2.NewIntID(1), "test", nil)
+		require.NoError(t, err)
+		started := make(chan struct{})
+		s.runWithContextResponse(c, func(ctx context.Context) (any, error) {
+			close(started)
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+		<-started
+
+		n, err := jsonrpc2.NewNotification("$/cancelRequest", CancelParams{ID: 1})
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(n))
+
+		resp := <-responses
+		assert.Equal(t, jsonrpc2.NewIntID(1), resp.ID())
+		var wireErr *jsonrpc2.WireError
+		require.ErrorAs(t, resp.Err(), &wireErr)
+		assert.EqualValues(t, -32800, wireErr.Code)
+	})
+
+	t.Run("UnknownID", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		n, err := jsonrpc2.NewNotification("$/cancelRequest", CancelParams{ID: "foo"})
+		require.NoError(t, err)
+		assert.NoError(t, s.HandleMessage(n))
+	})
+}
+
+func TestServerCompileWithContext(t *testing.T) {
+	t.Run("Canceled", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		result, err := s.compileWithContext(ctx)
+		require.ErrorIs(t, err, context.Canceled)
+		assert.Nil(t, result)
+		assert.Nil(t, s.defaultWorkspaceFolder().lastCompileCache)
+
+		result, err = s.compile()
+		require.NoError(t, err)
+		assert.NotNil(t, result)
+	})
+}
+
+func TestServerCompileWorkspaceFolderCache(t *testing.T) {
+	files := map[string][]byte{
+		"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx":                       []byte(`onStart => {}`),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{}`),
+		"assets/sprites/MySprite/c1.png":     []byte(`png`),
+	}
+	s := New(newMapFSWithoutModTime(files), nil)
+	result, err := s.compile()
+	require.NoError(t, err)
+
+	t.Run("Unchanged", func(t *testing.T) {
+		cached, err := s.compile()
+		require.NoError(t, err)
+		assert.Same(t, result, cached)
+	})
+
+	t.Run("UnrelatedFileChanged", func(t *testing.T) {
+		files["assets/sprites/MySprite/c1.png"] = []byte(`new png`)
+		cached, err := s.compile()
+		require.NoError(t, err)
+		assert.Same(t, result, cached)
+	})
+
+	t.Run("SpxFileChanged", func(t *testing.T) {
+		files["MySprite.spx"] = []byte(`onStart => { println "hi" }`)
+		recompiled, err := s.compile()
+		require.NoError(t, err)
+		assert.NotSame(t, result, recompiled)
+		result = recompiled
+	})
+
+	t.Run("SpxResourceMetadataChanged", func(t *testing.T) {
+		files["assets/sprites/MySprite/index.json"] = []byte(`{"costumes": [{"name": "c1", "path": "c1.png"}]}`)
+		recompiled, err := s.compile()
+		require.NoError(t, err)
+		assert.NotSame(t, result, recompiled)
+		assert.NotNil(t, recompiled.spxResourceSet.Sprite("MySprite").Costume("c1"))
+		result = recompiled
+	})
+
+	t.Run("SpxResourceMetadataAdded", func(t *testing.T) {
+		files["assets/sounds/Meow/index.json"] = []byte(`{"path": "meow.wav"}`)
+		recompiled, err := s.compile()
+		require.NoError(t, err)
+		assert.NotSame(t, result, recompiled)
+		assert.NotNil(t, recompiled.spxResourceSet.Sound("Meow"))
+	})
+}
+
+func TestServerCompileReusesSpxResources(t *testing.T) {
+	fileMap := map[string]vfs.MapFile{
+		"main.spx":                           vfs.NewMapFile([]byte(`run "assets", {Title: "My Game"}`), time.Time{}),
+		"MySprite.spx":                       vfs.NewMapFile([]byte(`onStart => {}`), time.Time{}),
+		"assets/index.json":                  vfs.NewMapFile([]byte(`{"mpa": {}}`), time.Time{}),
+		"assets/sprites/MySprite/index.json": vfs.NewMapFile([]byte(`{"costumes": [{"name": "c1", "path": "c1.png"}]}`), time.Time{}),
+	}
+	s := New(vfs.NewMapFS(func() map[string]vfs.MapFile {
+		return maps.Clone(fileMap)
+	}), nil)
+	result, err := s.compile()
+	require.NoError(t, err)
+	require.Len(t, result.diagnostics["file:///assets/index.json"], 1)
+
+	t.Run("SpxFileChanged", func(t *testing.T) {
+		fileMap["MySprite.spx"] = vfs.NewMapFile([]byte(`onStart => { println "hi" }`), time.Time{})
+		recompiled, err := s.compile()
+		require.NoError(t, err)
+		require.NotSame(t, result, recompiled)
+		assert.Same(t, result.spxResourceSet.Sprite("MySprite"), recompiled.spxResourceSet.Sprite("MySprite"))
+		assert.Equal(t, result.diagnostics["file:///assets/index.json"], recompiled.diagnostics["file:///assets/index.json"])
+		prevViolations := result.spxResourceMetadataValidations["index.json"].violations
+		violations := recompiled.spxResourceMetadataValidations["index.json"].violations
+		require.Len(t, violations, 1)
+		assert.Same(t, &prevViolations[0], &violations[0])
+		result = recompiled
+	})
+
+	t.Run("SpxResourceMetadataChanged", func(t *testing.T) {
+		fileMap["assets/sprites/MySprite/index.json"] = vfs.NewMapFile([]byte(`{"costumes": [{"name": "c2", "path": "c2.png"}]}`), time.Time{})
+		recompiled, err := s.compile()
+		require.NoError(t, err)
+		assert.NotSame(t, result.spxResourceSet.Sprite("MySprite"), recompiled.spxResourceSet.Sprite("MySprite"))
+		assert.NotNil(t, recompiled.spxResourceSet.Sprite("MySprite").Costume("c2"))
+		assert.Len(t, recompiled.diagnostics["file:///assets/index.json"], 1)
+		result = recompiled
+	})
+
+	t.Run("SpxResourceMetadataFixed", func(t *testing.T) {
+		fileMap["assets/index.json"] = vfs.NewMapFile([]byte(`{"map": {}}`), time.Time{})
+		recompiled, err := s.compile()
+		require.NoError(t, err)
+		assert.Empty(t, recompiled.diagnostics["file:///assets/index.json"])
+	})
+}
+
+func TestServerIsCompileInput(t *testing.T) {
+	s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+	result := &compileResult{spxResourceRootDir: "assets"}
+	for _, tt := range []struct {
+		file string
+		want bool
+	}{
+		{"main.spx", true},
+		{"sprites/MySprite.spx", true},
+		{"go.mod", true},
+		{"gop.mod", true},
+		{"lib/go.mod", true},
+		{"assets/index.json", true},
+		{"assets/sprites/MySprite/index.json", true},
+		{"assets/sounds/Meow/index.json", true},
+		{"assets/sprites/MySprite/c1.png", false},
+		{"assets/widgets.json", false},
+		{"index.json", false},
+		{"README.md", false},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			assert.Equal(t, tt.want, s.isCompileInput(result, tt.file))
+		})
+	}
+}
+
+// BenchmarkCompileAfterSpxFileChange benchmarks compiling projects of
+// different sizes after a single sprite file changes, as when typing in the
+// editor. The whole project is type checked again, so the time grows with the
+// number of sprites. See [compileCache].
+func BenchmarkCompileAfterSpxFileChange(b *testing.B) {
+	for _, sprites := range []int{10, 20, 50} {
+		b.Run(fmt.Sprintf("Sprites%d", sprites), func(b *testing.B) {
+			fileMap := make(map[string]vfs.MapFile)
+			for name, content := range newBenchmarkSpxProject(sprites) {
+				fileMap[name] = vfs.NewMapFile(content, time.Time{})
+			}
+			s := New(vfs.NewMapFS(func() map[string]vfs.MapFile {
+				return maps.Clone(fileMap)
+			}), nil)
+			if _, err := s.compile(); err != nil {
+				b.Fatal(err)
+			}
+
+			sprite0 := fileMap["Sprite0.spx"].Content
+			b.ResetTimer()
+			for i := range b.N {
+				fileMap["Sprite0.spx"] = vfs.NewMapFile(fmt.Appendf(slices.Clip(sprite0), "\n// edit %d\n", i), time.Time{})
+				if _, err := s.compile(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package server
+
+import (
+	"fmt"
+	"go/types"
+	"maps"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// shadowedVariableAnalyzer is an [analyzer] for variables declared in spx
+// event handlers and other function literals that shadow outer ones.
+var shadowedVariableAnalyzer = &analyzer{
+	name:     "shadowedVariable",
+	requires: requireAST | requireTypeInfo,
+	run:      inspectForShadowedVariables,
+}
+
+// inspectForShadowedVariables inspects for variables declared in function
+// literals, e.g., `onClick => { score := 0 }`, that shadow variables declared
+// outside of them, including fields of the class of the spx file. Such
+// declarations are usually meant to be assignments.
+func inspectForShadowedVariables(pass *analysisPass) {
+	result := pass.result
+	for _, spxFile := range slices.Sorted(maps.Keys(result.mainASTPkg.Files)) {
+		astFile := result.mainASTPkg.Files[spxFile]
+		classType := result.spxClassTypeFor(spxFile)
+		var path []gopast.Node
+		gopast.Inspect(astFile, func(node gopast.Node) bool {
+			if node == nil {
+				path = path[:len(path)-1]
+				return true
+			}
+			path = append(path, node)
+
+			var idents []*gopast.Ident
+			switch node := node.(type) {
+			case *gopast.AssignStmt:
+				if node.Tok == goptoken.DEFINE {
+					for _, lhs := range node.Lhs {
+						if ident, ok := lhs.(*gopast.Ident); ok {
+							idents = append(idents, ident)
+						}
+					}
+				}
+			case *gopast.ValueSpec:
+				idents = node.Names
+			case *gopast.RangeStmt:
+				if node.Tok == goptoken.DEFINE {
+					for _, expr := range []gopast.Expr{node.Key, node.Value} {
+						if ident, ok := expr.(*gopast.Ident); ok {
+							idents = append(idents, ident)
+						}
+					}
+				}
+			case *gopast.ForPhraseStmt:
+				for _, ident := range []*gopast.Ident{node.Key, node.Value} {
+					if ident != nil {
+						idents = append(idents, ident)
+					}
+				}
+			}
+			if len(idents) == 0 {
+				return true
+			}
+			funcLit := innermostFuncLitIn(path)
+			if funcLit == nil {
+				return true
+			}
+
+			for _, ident := range idents {
+				v, ok := result.typeInfo.Defs[ident].(*types.Var)
+				if !ok || ident.Name == "_" {
+					continue
+				}
+				shadowed := result.shadowedVarOf(v, funcLit, classType)
+				if shadowed == nil {
+					continue
+				}
+				pass.report(spxFile, Diagnostic{
+					Severity: SeverityWarning,
+					Code:     DiagnosticCodeShadowedVariable,
+					Range:    result.rangeForNode(ident),
+					Message:  fmt.Sprintf("declaration of %q shadows declaration at line %d", ident.Name, result.fset.Position(shadowed.Pos()).Line),
+					RelatedInformation: []DiagnosticRelatedInformation{{
+						Location: result.locationForPos(shadowed.Pos()),
+						Message:  fmt.Sprintf("shadowed declaration of %q", ident.Name),
+					}},
+				})
+			}
+			return true
+		})
+	}
+}
+
+// innermostFuncLitIn returns the innermost function literal or lambda
+// expression in the given path of nodes. It returns nil if the innermost
+// function is a function declaration or there is no function at all.
+func innermostFuncLitIn(path []gopast.Node) gopast.Node {
+	for _, node := range slices.Backward(path) {
+		switch node.(type) {
+		case *gopast.FuncLit, *gopast.LambdaExpr, *gopast.LambdaExpr2:
+			return node
+		case *gopast.FuncDecl:
+			return nil
+		}
+	}
+	return nil
+}
+
+// shadowedVarOf returns the variable declared outside of the given function
+// literal that is shadowed by the given variable declared inside of it. The
+// fields declared in the given class type are also taken into account. It
+// returns nil if no such variable exists.
+func (r *compileResult) shadowedVarOf(v *types.Var, funcLit gopast.Node, classType *types.Named) *types.Var {
+	if scope := v.Parent(); scope != nil && scope.Parent() != nil {
+		if _, obj := scope.Parent().LookupParent(v.Name(), v.Pos()); obj != nil {
+			outer, ok := obj.(*types.Var)
+			if !ok || !outer.Pos().IsValid() || outer.Pos() >= funcLit.Pos() {
+				return nil
+			}
+			return outer
+		}
+	}
+
+	if classType == nil {
+		return nil
+	}
+	st, ok := classType.Underlying().(*types.Struct)
+	if !ok {
+		return nil
+	}
+	for i := range st.NumFields() {
+		field := st.Field(i)
+		if field.Name() == v.Name() && !field.Embedded() && isMainPkgObject(field) && field.Pos().IsValid() {
+			return field
+		}
+	}
+	return nil
+}
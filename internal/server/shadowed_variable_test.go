@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerInspectForShadowedVariables(t *testing.T) {
+	diagnosticsFor := func(t *testing.T, s *Server) []Diagnostic {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		return fullReport.Items
+	}
+	newServer := func(mainSpx string) *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(mainSpx),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+	}
+
+	t.Run("ClassField", func(t *testing.T) {
+		s := newServer(`
+var (
+	score int
+)
+onStart => {
+	score = 2
+}
+onClick => {
+	score := 1
+	echo score
+}
+run "assets", {Title: "My Game"}
+`)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, Diagnostic{
+			Severity: SeverityWarning,
+			Code:     DiagnosticCodeShadowedVariable,
+			Range: Range{
+				Start: Position{Line: 8, Character: 1},
+				End:   Position{Line: 8, Character: 6},
+			},
+			Message: `declaration of "score" shadows declaration at line 3`,
+			RelatedInformation: []DiagnosticRelatedInformation{{
+				Location: Location{
+					URI: "file:///main.spx",
+					Range: Range{
+						Start: Position{Line: 2, Character: 1},
+						End:   Position{Line: 2, Character: 1},
+					},
+				},
+				Message: `shadowed declaration of "score"`,
+			}},
+		}, diags[0])
+	})
+
+	t.Run("OuterLocalVariable", func(t *testing.T) {
+		s := newServer(`
+onStart => {
+	count := 1
+	onClick => {
+		for count <- [1, 2] {
+			echo count
+		}
+	}
+	echo count
+}
+run "assets", {Title: "My Game"}
+`)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, DiagnosticCodeShadowedVariable, diags[0].Code)
+		assert.Equal(t, Range{
+			Start: Position{Line: 4, Character: 6},
+			End:   Position{Line: 4, Character: 11},
+		}, diags[0].Range)
+		assert.Equal(t, `declaration of "count" shadows declaration at line 3`, diags[0].Message)
+	})
+
+	t.Run("NotShadowedAcrossFuncLits", func(t *testing.T) {
+		s := newServer(`
+func show() {
+	x := 1
+	echo x
+}
+onStart => {
+	show
+	x := 2
+	if x > 1 {
+		x := 3
+		echo x
+	}
+}
+run "assets", {Title: "My Game"}
+`)
+		assert.Empty(t, diagnosticsFor(t, s))
+	})
+
+	t.Run("DisabledAnalyzer", func(t *testing.T) {
+		s := newServer(`
+var (
+	score int
+)
+onStart => {
+	score = 2
+}
+onClick => {
+	score := 1
+	echo score
+}
+run "assets", {Title: "My Game"}
+`)
+		err := s.workspaceDidChangeConfiguration(&DidChangeConfigurationParams{
+			Settings: map[string]any{"analyzers": map[string]any{"shadowedVariable": false}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, diagnosticsFor(t, s))
+	})
+}
@@ -0,0 +1,56 @@
+package server
+
+import "fmt"
+
+// openMetadataFileAction is the action of the prompt about a malformed metadata
+// file of spx resources that opens the file.
+const openMetadataFileAction = "Open"
+
+// promptMalformedSpxResourceMetadataFile prompts the user via
+// window/showMessageRequest to open the given malformed metadata file of spx
+// resources in the given workspace folder, and opens it via window/showDocument
+// if the user accepts. The user is prompted once until the file changes to
+// another one, and only if the client supports window/showDocument.
+//
+// It must be called with folder.lastCompileCacheMu held.
+func (s *Server) promptMalformedSpxResourceMetadataFile(folder *workspaceFolder, metadataFile string) {
+	if metadataFile == folder.lastPromptedMetadataFile {
+		return
+	}
+	folder.lastPromptedMetadataFile = metadataFile
+	if metadataFile == "" || !s.clientSupportsShowDocument() {
+		return
+	}
+
+	go func() {
+		var action *MessageActionItem
+		if err := s.call(folder.ctx, "window/showMessageRequest", &ShowMessageRequestParams{
+			Type:    Error,
+			Message: fmt.Sprintf("%s is malformed — open it?", metadataFile),
+			Actions: []MessageActionItem{{Title: openMetadataFileAction}},
+		}, &action); err != nil {
+			s.logger.Warn("failed to prompt malformed metadata file", "file", metadataFile, "error", err)
+			return
+		}
+		if action == nil || action.Title != openMetadataFileAction {
+			return
+		}
+		if err := s.call(folder.ctx, "window/showDocument", &ShowDocumentParams{
+			URI:       URI(folder.toDocumentURI(metadataFile)),
+			TakeFocus: true,
+		}, nil); err != nil {
+			s.logger.Warn("failed to open malformed metadata file", "file", metadataFile, "error", err)
+		}
+	}()
+}
+
+// clientSupportsShowDocument reports whether the client supports
+// window/showDocument.
+func (s *Server) clientSupportsShowDocument() bool {
+	clientCapabilities := s.clientCapabilities.Load()
+	if clientCapabilities == nil {
+		return false
+	}
+	showDocument := clientCapabilities.Window.ShowDocument
+	return showDocument != nil && showDocument.Support
+}
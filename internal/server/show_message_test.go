@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerPromptMalformedSpxResourceMetadataFile(t *testing.T) {
+	newFiles := func() map[string][]byte {
+		return map[string][]byte{
+			"main.spx": []byte(`
+onStart => {
+	play "biu"
+}
+
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":            []byte(`{}`),
+			"assets/sounds/biu/index.json": []byte(`{"path":`),
+		}
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		calls := make(chan *jsonrpc2.Call, 2)
+		var s *Server
+		s = New(newMapFSWithoutModTime(newFiles()), messageReplierFunc(func(m jsonrpc2.Message) error {
+			call, ok := m.(*jsonrpc2.Call)
+			if !ok {
+				return nil
+			}
+			calls <- call
+			var result any
+			if call.Method() == "window/showMessageRequest" {
+				result = MessageActionItem{Title: openMetadataFileAction}
+			}
+			resp, err := jsonrpc2.NewResponse(call.ID(), result, nil)
+			if err != nil {
+				return err
+			}
+			go s.HandleMessage(resp)
+			return nil
+		}))
+		s.clientCapabilities.Store(&ClientCapabilities{
+			Window: WindowClientCapabilities{ShowDocument: &ShowDocumentClientCapabilities{Support: true}},
+		})
+
+		_, err := s.compile()
+		require.NoError(t, err)
+
+		nextCall := func() *jsonrpc2.Call {
+			select {
+			case call := <-calls:
+				return call
+			case <-time.After(time.Second):
+				t.Fatal("timed out waiting for call")
+				return nil
+			}
+		}
+		call := nextCall()
+		assert.Equal(t, "window/showMessageRequest", call.Method())
+		var showMessageRequestParams ShowMessageRequestParams
+		require.NoError(t, json.Unmarshal(call.Params(), &showMessageRequestParams))
+		assert.Equal(t, Error, showMessageRequestParams.Type)
+		assert.Equal(t, "assets/sounds/biu/index.json is malformed — open it?", showMessageRequestParams.Message)
+
+		call = nextCall()
+		assert.Equal(t, "window/showDocument", call.Method())
+		var showDocumentParams ShowDocumentParams
+		require.NoError(t, json.Unmarshal(call.Params(), &showDocumentParams))
+		assert.Equal(t, URI("file:///assets/sounds/biu/index.json"), showDocumentParams.URI)
+
+		// The user is not prompted again about the same file.
+		s.defaultWorkspaceFolder().resetCompileCache()
+		_, err = s.compile()
+		require.NoError(t, err)
+		select {
+		case call := <-calls:
+			t.Errorf("unexpected call: %s", call.Method())
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("ShowDocumentNotSupported", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newFiles()), messageReplierFunc(func(m jsonrpc2.Message) error {
+			t.Errorf("unexpected message: %v", m)
+			return nil
+		}))
+		s.clientCapabilities.Store(&ClientCapabilities{})
+
+		_, err := s.compile()
+		require.NoError(t, err)
+	})
+}
@@ -2,7 +2,12 @@ package server
 
 import (
 	"go/types"
+	"slices"
 	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	"github.com/goplus/goxlsw/internal/util"
 )
 
 // See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_signatureHelp
@@ -15,20 +20,63 @@ func (s *Server) textDocumentSignatureHelp(params *SignatureHelpParams) (*Signat
 		return nil, nil
 	}
 	position := result.toPosition(astFile, params.Position)
+	pos := result.posAt(astFile, params.Position)
 
-	obj := result.typeInfo.ObjectOf(result.identAtASTFilePosition(astFile, position))
-	if obj == nil {
-		return nil, nil
+	var (
+		funcIdent *gopast.Ident
+		callExpr  *gopast.CallExpr
+	)
+	if ident := result.identAtASTFilePosition(astFile, position); ident != nil {
+		if _, ok := result.typeInfo.ObjectOf(ident).(*types.Func); ok {
+			funcIdent = ident
+		}
+	}
+	if funcIdent == nil {
+		callExpr = enclosingCallExpr(astFile, pos)
+		if callExpr == nil {
+			return nil, nil
+		}
+		funcIdent = funcIdentOf(callExpr.Fun)
+		if funcIdent == nil {
+			return nil, nil
+		}
 	}
 
-	fun, ok := obj.(*types.Func)
+	fun, ok := result.typeInfo.ObjectOf(funcIdent).(*types.Func)
 	if !ok {
 		return nil, nil
 	}
-	sig, ok := fun.Type().(*types.Signature)
-	if !ok {
-		return nil, nil
+	overloads := result.funcOverloadsFor(funcIdent, fun)
+
+	help := &SignatureHelp{
+		Signatures: make([]SignatureInformation, 0, len(overloads)),
 	}
+	for _, overload := range overloads {
+		help.Signatures = append(help.Signatures, makeSignatureInformation(overload))
+	}
+	if callExpr != nil {
+		help.ActiveParameter = uint32(activeArgIndex(callExpr, pos))
+	}
+	help.ActiveSignature = uint32(activeSignatureIndex(overloads, fun, int(help.ActiveParameter)))
+	return help, nil
+}
+
+// funcOverloadsFor returns all overloads of the given function referenced by
+// the given identifier. It returns the function itself if it is not a Go+
+// overloaded function.
+func (r *compileResult) funcOverloadsFor(funcIdent *gopast.Ident, fun *types.Func) []*types.Func {
+	if _, overloads := getFuncAndOverloadsType(r, funcIdent); len(overloads) > 0 {
+		return overloads
+	}
+	if overloads := expandGopOverloadableFunc(fun); len(overloads) > 0 {
+		return overloads
+	}
+	return []*types.Func{fun}
+}
+
+// makeSignatureInformation makes a [SignatureInformation] for the given function.
+func makeSignatureInformation(fun *types.Func) SignatureInformation {
+	sig := fun.Type().(*types.Signature)
 
 	var paramsInfo []ParameterInformation
 	for i := range sig.Params().Len() {
@@ -39,7 +87,11 @@ func (s *Server) textDocumentSignatureHelp(params *SignatureHelpParams) (*Signat
 		})
 	}
 
-	label := fun.Name() + "("
+	name := fun.Name()
+	if isGopOverloadedFuncName(name) {
+		name, _ = parseGopFuncName(name)
+	}
+	label := name + "("
 	if sig.Params().Len() > 0 {
 		var paramLabels []string
 		for _, p := range paramsInfo {
@@ -57,11 +109,57 @@ func (s *Server) textDocumentSignatureHelp(params *SignatureHelpParams) (*Signat
 		label += " (" + strings.Join(returnTypes, ", ") + ")"
 	}
 
-	return &SignatureHelp{
-		Signatures: []SignatureInformation{{
-			Label: label,
-			// TODO: Add documentation.
-			Parameters: paramsInfo,
-		}},
-	}, nil
+	return SignatureInformation{
+		Label: label,
+		// TODO: Add documentation.
+		Parameters: paramsInfo,
+	}
+}
+
+// activeSignatureIndex returns the index of the active signature among the
+// given overloads. It prefers the overload chosen by the type checker, and
+// otherwise falls back to the first overload that accepts enough arguments.
+func activeSignatureIndex(overloads []*types.Func, fun *types.Func, activeParam int) int {
+	if i := slices.IndexFunc(overloads, func(overload *types.Func) bool {
+		return overload == fun || (overload.Name() == fun.Name() && overload.Pkg() == fun.Pkg())
+	}); i >= 0 {
+		return i
+	}
+	for i, overload := range overloads {
+		sig := overload.Type().(*types.Signature)
+		if sig.Variadic() || sig.Params().Len() > activeParam {
+			return i
+		}
+	}
+	return 0
+}
+
+// enclosingCallExpr returns the innermost call expression whose arguments
+// enclose the given position.
+func enclosingCallExpr(astFile *gopast.File, pos goptoken.Pos) *gopast.CallExpr {
+	path, _ := util.PathEnclosingInterval(astFile, pos-1, pos)
+	for _, node := range path {
+		callExpr, ok := node.(*gopast.CallExpr)
+		if !ok {
+			continue
+		}
+		if pos > callExpr.Fun.End() {
+			return callExpr
+		}
+	}
+	return nil
+}
+
+// activeArgIndex returns the index of the argument of the given call
+// expression at the given position.
+func activeArgIndex(callExpr *gopast.CallExpr, pos goptoken.Pos) int {
+	for i, arg := range callExpr.Args {
+		if pos <= arg.End() {
+			return i
+		}
+	}
+	if n := len(callExpr.Args); n > 0 {
+		return n - 1
+	}
+	return 0
 }
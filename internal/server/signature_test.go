@@ -1,6 +1,7 @@
 package server
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -46,4 +47,80 @@ onStart => {
 			},
 		}, help.Signatures[0])
 	})
+
+	t.Run("Overloads", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+MySprite.turn Left
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		help, err := s.textDocumentSignatureHelp(&SignatureHelpParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 4, Character: 16},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, help)
+		require.Greater(t, len(help.Signatures), 1)
+		for _, sig := range help.Signatures {
+			assert.True(t, strings.HasPrefix(sig.Label, "turn("), sig.Label)
+		}
+		assert.Equal(t, uint32(0), help.ActiveParameter)
+		activeSig := help.Signatures[help.ActiveSignature]
+		require.Len(t, activeSig.Parameters, 1)
+		assert.Equal(t, "dir specialDir", activeSig.Parameters[0].Label)
+	})
+
+	t.Run("ActiveParameter", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+func add(a, b int) int {
+	return a + b
+}
+println add(1, 2)
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		help, err := s.textDocumentSignatureHelp(&SignatureHelpParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 4, Character: 15},
+			},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, help)
+		require.Len(t, help.Signatures, 1)
+		assert.Equal(t, "add(a int, b int) (int)", help.Signatures[0].Label)
+		assert.Equal(t, uint32(0), help.ActiveSignature)
+		assert.Equal(t, uint32(1), help.ActiveParameter)
+	})
+
+	t.Run("OutsideCall", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+x := 1
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+
+		help, err := s.textDocumentSignatureHelp(&SignatureHelpParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Position:     Position{Line: 1, Character: 0},
+			},
+		})
+		require.NoError(t, err)
+		assert.Nil(t, help)
+	})
 }
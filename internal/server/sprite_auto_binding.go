@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"go/types"
+	"slices"
+
+	goptoken "github.com/goplus/gop/token"
+)
+
+// spriteAutoBindingAnalyzer is an [analyzer] for sprites without auto-binding
+// vars in main.spx.
+var spriteAutoBindingAnalyzer = &analyzer{
+	name:     "spriteAutoBinding",
+	requires: requireAST | requireTypeInfo | requireResourceSet,
+	run:      inspectForMissingSpriteAutoBindings,
+}
+
+// inspectForMissingSpriteAutoBindings inspects for sprites that are defined by
+// both spx files and sprite resources, but are not bound by auto-binding vars
+// like `var MySprite MySprite` in main.spx. The diagnostics can be fixed by
+// adding the vars to the first var block of main.spx, and are not reported if
+// main.spx cannot be parsed.
+func inspectForMissingSpriteAutoBindings(pass *analysisPass) {
+	result := pass.result
+	mainASTFile := result.mainASTPkg.Files[result.mainSpxFile]
+	if mainASTFile == nil || len(mainASTFile.Decls) == 0 || result.mainPkgGameType == nil {
+		return
+	}
+	if slices.ContainsFunc(result.diagnostics[result.documentURIs[result.mainSpxFile]], func(diag Diagnostic) bool {
+		return diag.Code == DiagnosticCodeParseError
+	}) {
+		// The var blocks of main.spx may be incomplete.
+		return
+	}
+	gameStruct, ok := result.mainPkgGameType.Underlying().(*types.Struct)
+	if !ok {
+		return
+	}
+	hasField := func(name string) bool {
+		for i := range gameStruct.NumFields() {
+			if gameStruct.Field(i).Name() == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	diagRange := result.rangeForPos(mainASTFile.Decls[0].Pos())
+	if firstVarBlock := result.firstVarBlocks[mainASTFile]; firstVarBlock != nil {
+		diagRange = Range{
+			Start: result.rangeForPos(firstVarBlock.TokPos).Start,
+			End:   result.rangeForPos(firstVarBlock.TokPos + goptoken.Pos(len(goptoken.VAR.String()))).End,
+		}
+	}
+	for _, spriteType := range result.mainPkgSpriteTypes {
+		spriteName := spriteType.Obj().Name()
+		if result.spxResourceSet.Sprite(spriteName) == nil || hasField(spriteName) {
+			continue
+		}
+		pass.report(result.mainSpxFile, Diagnostic{
+			Severity: SeverityInformation,
+			Code:     DiagnosticCodeMissingSpriteAutoBinding,
+			Range:    diagRange,
+			Message:  fmt.Sprintf("sprite %q has no auto-binding var in main.spx", spriteName),
+			RelatedInformation: []DiagnosticRelatedInformation{{
+				Location: Location{URI: result.documentURIs[spriteName+".spx"]},
+				Message:  fmt.Sprintf("sprite %q is defined here", spriteName),
+			}},
+			Data: makeDiagnosticData(DiagnosticData{
+				Fix:  DiagnosticFixDeclareVar,
+				Name: spriteName,
+			}),
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerInspectForMissingSpriteAutoBindings(t *testing.T) {
+	diagnosticsFor := func(t *testing.T, s *Server) []Diagnostic {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		return fullReport.Items
+	}
+	newServer := func(mainSpx string, sprites ...string) *Server {
+		fileMap := map[string][]byte{
+			"main.spx":          []byte(mainSpx),
+			"MySprite.spx":      []byte(`onStart => {}`),
+			"assets/index.json": []byte(`{}`),
+		}
+		for _, sprite := range sprites {
+			fileMap["assets/sprites/"+sprite+"/index.json"] = []byte(`{}`)
+		}
+		return New(newMapFSWithoutModTime(fileMap), nil)
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer(`
+var (
+	score int
+)
+onStart => {
+	score = 1
+}
+run "assets", {Title: "My Game"}
+`, "MySprite")
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, Diagnostic{
+			Severity: SeverityInformation,
+			Code:     DiagnosticCodeMissingSpriteAutoBinding,
+			Range: Range{
+				Start: Position{Line: 1, Character: 0},
+				End:   Position{Line: 1, Character: 3},
+			},
+			Message: `sprite "MySprite" has no auto-binding var in main.spx`,
+			RelatedInformation: []DiagnosticRelatedInformation{{
+				Location: Location{URI: "file:///MySprite.spx"},
+				Message:  `sprite "MySprite" is defined here`,
+			}},
+			Data: makeDiagnosticData(DiagnosticData{
+				Fix:  DiagnosticFixDeclareVar,
+				Name: "MySprite",
+			}),
+		}, diags[0])
+
+		actions, err := s.textDocumentCodeAction(&CodeActionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+			Context:      CodeActionContext{Diagnostics: diags},
+		})
+		require.NoError(t, err)
+		require.Len(t, actions, 2)
+		assert.Equal(t, `Add sprite auto-binding var "MySprite" to main.spx`, actions[0].Title)
+		require.NotNil(t, actions[0].Edit)
+		assert.Equal(t, map[DocumentURI][]TextEdit{
+			"file:///main.spx": {{
+				Range: Range{
+					Start: Position{Line: 3, Character: 0},
+					End:   Position{Line: 3, Character: 0},
+				},
+				NewText: "\tMySprite MySprite\n",
+			}},
+		}, actions[0].Edit.Changes)
+	})
+
+	t.Run("WithoutVarBlock", func(t *testing.T) {
+		s := newServer(`
+run "assets", {Title: "My Game"}
+`, "MySprite")
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 1)
+		assert.Equal(t, DiagnosticCodeMissingSpriteAutoBinding, diags[0].Code)
+		assert.Equal(t, Range{
+			Start: Position{Line: 1, Character: 0},
+			End:   Position{Line: 1, Character: 0},
+		}, diags[0].Range)
+	})
+
+	t.Run("WithoutSpriteResource", func(t *testing.T) {
+		s := newServer(`
+run "assets", {Title: "My Game"}
+`)
+		assert.Empty(t, diagnosticsFor(t, s))
+	})
+
+	t.Run("Bound", func(t *testing.T) {
+		s := newServer(`
+var (
+	MySprite MySprite
+)
+run "assets", {Title: "My Game"}
+`, "MySprite")
+		assert.Empty(t, diagnosticsFor(t, s))
+	})
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+)
+
+// spxSpecialConstantDocs documents the spx constants that have no doc comments
+// in spx, e.g., directions and special objects. Keys are documented by
+// [spxKeyConstantDoc].
+var spxSpecialConstantDocs = map[string]string{
+	"Right":      "The direction pointing right, i.e., 90 degrees, e.g., `turnTo Right`.",
+	"Left":       "The direction pointing left, i.e., -90 degrees, e.g., `turnTo Left`.",
+	"Up":         "The direction pointing up, i.e., 0 degrees, e.g., `turnTo Up`.",
+	"Down":       "The direction pointing down, i.e., 180 degrees, e.g., `turnTo Down`.",
+	"Mouse":      "The mouse pointer, e.g., `goto Mouse` or `touching Mouse`.",
+	"Edge":       "Any edge of the stage, e.g., `touching Edge`.",
+	"EdgeLeft":   "The left edge of the stage, e.g., `touching EdgeLeft`.",
+	"EdgeTop":    "The top edge of the stage, e.g., `touching EdgeTop`.",
+	"EdgeRight":  "The right edge of the stage, e.g., `touching EdgeRight`.",
+	"EdgeBottom": "The bottom edge of the stage, e.g., `touching EdgeBottom`.",
+	"KeyAny":     "Any key, e.g., `keyPressed(KeyAny)` reports whether any key is pressed.",
+	"KeyMax":     "The upper bound of key values, which is not a key itself.",
+}
+
+// spxKeyNames are the names of keys whose constants are not named after the
+// characters they type, keyed by the constant names without the "Key" prefix.
+var spxKeyNames = map[string]string{
+	"Apostrophe":   "apostrophe (`'`)",
+	"Backslash":    "backslash (`\\`)",
+	"Comma":        "comma (`,`)",
+	"Equal":        "equal sign (`=`)",
+	"GraveAccent":  "grave accent (`` ` ``)",
+	"LeftBracket":  "left bracket (`[`)",
+	"Minus":        "minus (`-`)",
+	"Period":       "period (`.`)",
+	"RightBracket": "right bracket (`]`)",
+	"Semicolon":    "semicolon (`;`)",
+	"Slash":        "slash (`/`)",
+	"Up":           "up arrow",
+	"Down":         "down arrow",
+	"Left":         "left arrow",
+	"Right":        "right arrow",
+	"KPDecimal":    "decimal point on the numeric keypad",
+	"KPDivide":     "divide on the numeric keypad",
+	"KPEnter":      "enter on the numeric keypad",
+	"KPEqual":      "equal sign on the numeric keypad",
+	"KPMultiply":   "multiply on the numeric keypad",
+	"KPSubtract":   "subtract on the numeric keypad",
+}
+
+// spxConstantDoc returns the documentation of the spx constant with the given
+// name if it has no doc comment in spx, e.g., "The A key." for KeyA.
+func spxConstantDoc(name string) (string, bool) {
+	if doc, ok := spxSpecialConstantDocs[name]; ok {
+		return doc, true
+	}
+	return spxKeyConstantDoc(name)
+}
+
+// spxKeyConstantDoc returns the documentation of the spx key constant with the
+// given name, e.g., "The A key." for KeyA.
+func spxKeyConstantDoc(name string) (string, bool) {
+	key, ok := strings.CutPrefix(name, "Key")
+	if !ok || key == "" {
+		return "", false
+	}
+	if keyName, ok := spxKeyNames[key]; ok {
+		return fmt.Sprintf("The %s key, e.g., `onKey %s, => {}`.", keyName, name), true
+	}
+	if digit, ok := strings.CutPrefix(key, "KP"); ok && len(digit) == 1 {
+		return fmt.Sprintf("The %s key on the numeric keypad, e.g., `onKey %s, => {}`.", digit, name), true
+	}
+	if len(key) == 1 || key[0] == 'F' && strings.Trim(key[1:], "0123456789") == "" {
+		return fmt.Sprintf("The %s key, e.g., `onKey %s, => {}`.", key, name), true
+	}
+
+	// Split the name into words, e.g., "CapsLock" into "caps lock".
+	var words []string
+	for i, start := 1, 0; i <= len(key); i++ {
+		if i == len(key) || key[i] >= 'A' && key[i] <= 'Z' {
+			words = append(words, strings.ToLower(key[start:i]))
+			start = i
+		}
+	}
+	return fmt.Sprintf("The %s key, e.g., `onKey %s, => {}`.", strings.Join(words, " "), name), true
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpxConstantDoc(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		doc  string
+	}{
+		{"KeyA", "The A key, e.g., `onKey KeyA, => {}`."},
+		{"Key0", "The 0 key, e.g., `onKey Key0, => {}`."},
+		{"KeyF12", "The F12 key, e.g., `onKey KeyF12, => {}`."},
+		{"KeyKP5", "The 5 key on the numeric keypad, e.g., `onKey KeyKP5, => {}`."},
+		{"KeyCapsLock", "The caps lock key, e.g., `onKey KeyCapsLock, => {}`."},
+		{"KeyUp", "The up arrow key, e.g., `onKey KeyUp, => {}`."},
+		{"KeyComma", "The comma (`,`) key, e.g., `onKey KeyComma, => {}`."},
+		{"Left", "The direction pointing left, i.e., -90 degrees, e.g., `turnTo Left`."},
+		{"Mouse", "The mouse pointer, e.g., `goto Mouse` or `touching Mouse`."},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, ok := spxConstantDoc(tt.name)
+			assert.True(t, ok)
+			assert.Equal(t, tt.doc, doc)
+		})
+	}
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, ok := spxConstantDoc("StateDie")
+		assert.False(t, ok)
+
+		_, ok = spxConstantDoc("Key")
+		assert.False(t, ok)
+	})
+}
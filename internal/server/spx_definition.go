@@ -39,6 +39,7 @@ func (def SpxDefinition) CompletionItem() CompletionItem {
 	return CompletionItem{
 		Label:            def.CompletionItemLabel,
 		Kind:             def.CompletionItemKind,
+		Detail:           def.Overview,
 		Documentation:    &Or_CompletionItem_documentation{Value: MarkupContent{Kind: Markdown, Value: def.HTML()}},
 		InsertText:       def.CompletionItemInsertText,
 		InsertTextFormat: &def.CompletionItemInsertTextFormat,
@@ -466,29 +467,37 @@ func GetSpxDefinitionsForPkg(pkg *types.Package, pkgDoc *pkgdoc.PkgDoc) (defs []
 	defs = make([]SpxDefinition, 0, len(names))
 	for _, name := range names {
 		if obj := pkg.Scope().Lookup(name); obj != nil && obj.Exported() {
-			switch obj := obj.(type) {
-			case *types.Var:
-				defs = append(defs, GetSpxDefinitionForVar(obj, "", false, pkgDoc))
-			case *types.Const:
-				defs = append(defs, GetSpxDefinitionForConst(obj, pkgDoc))
-			case *types.TypeName:
-				defs = append(defs, GetSpxDefinitionForType(obj, pkgDoc))
-			case *types.Func:
-				if funcOverloads := expandGopOverloadableFunc(obj); funcOverloads != nil {
-					for _, funcOverload := range funcOverloads {
-						defs = append(defs, GetSpxDefinitionForFunc(funcOverload, "", pkgDoc))
-					}
-				} else {
-					defs = append(defs, GetSpxDefinitionForFunc(obj, "", pkgDoc))
-				}
-			case *types.PkgName:
-				defs = append(defs, GetSpxDefinitionForPkg(obj, pkgDoc))
-			}
+			defs = append(defs, spxDefinitionsForPkgMember(obj, pkgDoc)...)
 		}
 	}
 	return slices.Clip(defs)
 }
 
+// spxDefinitionsForPkgMember returns the spx definitions for the given member
+// of a package, which are more than one for overloaded functions.
+func spxDefinitionsForPkgMember(obj types.Object, pkgDoc *pkgdoc.PkgDoc) []SpxDefinition {
+	switch obj := obj.(type) {
+	case *types.Var:
+		return []SpxDefinition{GetSpxDefinitionForVar(obj, "", false, pkgDoc)}
+	case *types.Const:
+		return []SpxDefinition{GetSpxDefinitionForConst(obj, pkgDoc)}
+	case *types.TypeName:
+		return []SpxDefinition{GetSpxDefinitionForType(obj, pkgDoc)}
+	case *types.Func:
+		if funcOverloads := expandGopOverloadableFunc(obj); funcOverloads != nil {
+			defs := make([]SpxDefinition, 0, len(funcOverloads))
+			for _, funcOverload := range funcOverloads {
+				defs = append(defs, GetSpxDefinitionForFunc(funcOverload, "", pkgDoc))
+			}
+			return defs
+		}
+		return []SpxDefinition{GetSpxDefinitionForFunc(obj, "", pkgDoc)}
+	case *types.PkgName:
+		return []SpxDefinition{GetSpxDefinitionForPkg(obj, pkgDoc)}
+	}
+	return nil
+}
+
 // nonMainPkgSpxDefCacheForVars is a cache of non-main package spx definitions
 // for variables.
 var nonMainPkgSpxDefCacheForVars sync.Map // map[nonMainPkgSpxDefCacheForVarsKey]SpxDefinition
@@ -593,6 +602,9 @@ func GetSpxDefinitionForConst(c *types.Const, pkgDoc *pkgdoc.PkgDoc) (def SpxDef
 	if pkgDoc != nil {
 		detail = pkgDoc.Consts[c.Name()]
 	}
+	if detail == "" && isSpxPkgObject(c) {
+		detail, _ = spxConstantDoc(c.Name())
+	}
 
 	def = SpxDefinition{
 		TypeHint: c.Type(),
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"slices"
+	"sync"
+)
+
+// spxDefinitionCatalogEntry is an entry of [spxDefinitionCatalog].
+type spxDefinitionCatalogEntry struct {
+	// info is the definition info with documentation.
+	info SpxDefinitionInfo
+
+	// id is the string form of the definition identifier, which is used to
+	// deduplicate definitions.
+	id string
+
+	// kinds are the spx definition kinds of the definition.
+	kinds []SpxDefinitionKind
+
+	// fileScopeOnly reports whether the definition is only available in
+	// file scopes. See [FileScopeSpxDefinitions].
+	fileScopeOnly bool
+}
+
+// isOfKind reports whether the definition is of any of the given kinds. It
+// reports true if no kinds are given.
+func (e *spxDefinitionCatalogEntry) isOfKind(kinds []SpxDefinitionKind) bool {
+	return len(kinds) == 0 || slices.ContainsFunc(kinds, func(kind SpxDefinitionKind) bool {
+		return slices.Contains(e.kinds, kind)
+	})
+}
+
+// spxDefinitionCatalog returns the catalog of definitions that are available
+// in every spx file regardless of the project, i.e., the spx package, builtin,
+// general and file scope definitions, deduplicated in that order. It is built
+// once and shared by all requests, which only filter it.
+var spxDefinitionCatalog = sync.OnceValue(newSpxDefinitionCatalog)
+
+// newSpxDefinitionCatalog builds the catalog of [spxDefinitionCatalog].
+func newSpxDefinitionCatalog() []spxDefinitionCatalogEntry {
+	var catalog []spxDefinitionCatalogEntry
+	seenIDs := make(map[string]struct{})
+	add := func(defs []SpxDefinition, fileScopeOnly bool) {
+		for _, def := range defs {
+			id := def.ID.String()
+			if _, ok := seenIDs[id]; ok {
+				continue
+			}
+			seenIDs[id] = struct{}{}
+
+			entry := spxDefinitionCatalogEntry{
+				info: SpxDefinitionInfo{
+					SpxDefinitionIdentifier: def.ID,
+					Overview:                def.Overview,
+					Detail:                  def.Detail,
+				},
+				id:            id,
+				fileScopeOnly: fileScopeOnly,
+			}
+			for _, kind := range []SpxDefinitionKind{
+				SpxDefinitionKindEventHandler,
+				SpxDefinitionKindSpriteMethod,
+				SpxDefinitionKindUser,
+			} {
+				// Catalog definitions are never in the main package, so
+				// they do not depend on the sprite types of a project.
+				if spxDefinitionIsOfKind(def.ID, kind, nil) {
+					entry.kinds = append(entry.kinds, kind)
+				}
+			}
+			catalog = append(catalog, entry)
+		}
+	}
+	add(GetSpxPkgDefinitions(), false)
+	add(GetBuiltinSpxDefinitions(), false)
+	add(GeneralSpxDefinitions, false)
+	add(FileScopeSpxDefinitions, true)
+	return slices.Clip(catalog)
+}
+
+// appendSpxDefinitionCatalogInfos appends the infos of the catalog
+// definitions of any of the given kinds to infos and returns the extended
+// slice. It skips definitions in seenIDs and, unless inFileScope is true,
+// those only available in file scopes. Documentation is only included if
+// includeDocumentation is true.
+func appendSpxDefinitionCatalogInfos(infos []SpxDefinitionInfo, kinds []SpxDefinitionKind, inFileScope, includeDocumentation bool, seenIDs map[string]struct{}) []SpxDefinitionInfo {
+	catalog := spxDefinitionCatalog()
+	for i := range catalog {
+		entry := &catalog[i]
+		if entry.fileScopeOnly && !inFileScope {
+			continue
+		}
+		if _, ok := seenIDs[entry.id]; ok {
+			continue
+		}
+		if !entry.isOfKind(kinds) {
+			continue
+		}
+		info := entry.info
+		if !includeDocumentation {
+			info = SpxDefinitionInfo{SpxDefinitionIdentifier: info.SpxDefinitionIdentifier}
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
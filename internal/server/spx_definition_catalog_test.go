@@ -0,0 +1,152 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/goplus/goxlsw/internal/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpxDefinitionCatalog(t *testing.T) {
+	catalog := spxDefinitionCatalog()
+	require.NotEmpty(t, catalog)
+	assert.Same(t, &catalog[0], &spxDefinitionCatalog()[0])
+
+	t.Run("Deduplicated", func(t *testing.T) {
+		seenIDs := make(map[string]struct{}, len(catalog))
+		for _, entry := range catalog {
+			assert.Equal(t, entry.info.SpxDefinitionIdentifier.String(), entry.id)
+			assert.NotContains(t, seenIDs, entry.id)
+			seenIDs[entry.id] = struct{}{}
+		}
+		for _, def := range FileScopeSpxDefinitions {
+			assert.Contains(t, seenIDs, def.ID.String())
+		}
+	})
+
+	t.Run("Kinds", func(t *testing.T) {
+		entryOf := func(id SpxDefinitionIdentifier) *spxDefinitionCatalogEntry {
+			for i := range catalog {
+				if catalog[i].id == id.String() {
+					return &catalog[i]
+				}
+			}
+			t.Fatalf("missing catalog entry %s", id)
+			return nil
+		}
+
+		clone := entryOf(SpxDefinitionIdentifier{
+			Package:    util.ToPtr(GetSpxPkg().Path()),
+			Name:       util.ToPtr("Sprite.clone"),
+			OverloadID: util.ToPtr("0"),
+		})
+		assert.Equal(t, []SpxDefinitionKind{SpxDefinitionKindSpriteMethod}, clone.kinds)
+		assert.True(t, clone.isOfKind(nil))
+		assert.True(t, clone.isOfKind([]SpxDefinitionKind{SpxDefinitionKindUser, SpxDefinitionKindSpriteMethod}))
+		assert.False(t, clone.isOfKind([]SpxDefinitionKind{SpxDefinitionKindUser}))
+
+		camera := entryOf(SpxDefinitionIdentifier{
+			Package: util.ToPtr(GetSpxPkg().Path()),
+			Name:    util.ToPtr("Camera"),
+		})
+		assert.Empty(t, camera.kinds)
+
+		println := entryOf(SpxDefinitionIdentifier{
+			Package: util.ToPtr("builtin"),
+			Name:    util.ToPtr("println"),
+		})
+		assert.Empty(t, println.kinds)
+	})
+
+	t.Run("AppendInfos", func(t *testing.T) {
+		allInfos := appendSpxDefinitionCatalogInfos(nil, nil, true, true, nil)
+		assert.Len(t, allInfos, len(catalog))
+		assert.Equal(t, catalog[0].info, allInfos[0])
+
+		infos := appendSpxDefinitionCatalogInfos(nil, nil, false, false, map[string]struct{}{catalog[0].id: {}})
+		assert.Len(t, infos, len(catalog)-1-countFileScopeOnly(catalog))
+		for _, info := range infos {
+			assert.NotEqual(t, catalog[0].id, info.String())
+			assert.Empty(t, info.Overview)
+			assert.Empty(t, info.Detail)
+		}
+
+		prefix := []SpxDefinitionInfo{{SpxDefinitionIdentifier: SpxDefinitionIdentifier{
+			Package: util.ToPtr("main"),
+			Name:    util.ToPtr("MySprite"),
+		}}}
+		infos = appendSpxDefinitionCatalogInfos(prefix, []SpxDefinitionKind{SpxDefinitionKindSpriteMethod}, true, false, nil)
+		require.Greater(t, len(infos), 1)
+		assert.Equal(t, prefix[0], infos[0])
+		for _, info := range infos[1:] {
+			assert.True(t, spxDefinitionIsOfKind(info.SpxDefinitionIdentifier, SpxDefinitionKindSpriteMethod, nil))
+		}
+
+		assert.Nil(t, appendSpxDefinitionCatalogInfos(nil, []SpxDefinitionKind{SpxDefinitionKindUser}, true, true, nil))
+	})
+}
+
+// countFileScopeOnly returns the number of catalog entries only available in
+// file scopes.
+func countFileScopeOnly(catalog []spxDefinitionCatalogEntry) int {
+	var n int
+	for _, entry := range catalog {
+		if entry.fileScopeOnly {
+			n++
+		}
+	}
+	return n
+}
+
+// BenchmarkSpxDefinitionCatalog benchmarks getting the catalog definitions of
+// a request by rebuilding the catalog, as was done per request before, and by
+// filtering the shared catalog.
+func BenchmarkSpxDefinitionCatalog(b *testing.B) {
+	spxDefinitionCatalog()
+
+	b.Run("Rebuild", func(b *testing.B) {
+		for range b.N {
+			for range newSpxDefinitionCatalog() {
+			}
+		}
+	})
+	b.Run("Cached", func(b *testing.B) {
+		for range b.N {
+			appendSpxDefinitionCatalogInfos(nil, nil, true, false, nil)
+		}
+	})
+}
+
+// BenchmarkServerSpxGetDefinitions benchmarks getting the definitions at a
+// position in a compiled project.
+func BenchmarkServerSpxGetDefinitions(b *testing.B) {
+	s := New(newMapFSWithoutModTime(map[string][]byte{
+		"main.spx": []byte(`
+var (
+	MySprite Sprite
+)
+MySprite.turn Left
+run "assets", {Title: "My Game"}
+`),
+		"MySprite.spx":                       []byte("onStart => {\n\tMySprite.turn Right\n}\n"),
+		"assets/index.json":                  []byte(`{}`),
+		"assets/sprites/MySprite/index.json": []byte(`{}`),
+	}), nil)
+	params := []SpxGetDefinitionsParams{{
+		TextDocumentPositionParams: TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+			Position:     Position{Line: 1, Character: 1},
+		},
+	}}
+	if _, err := s.spxGetDefinitions(params); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for range b.N {
+		if _, err := s.spxGetDefinitions(params); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
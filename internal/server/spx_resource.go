@@ -1,10 +1,12 @@
 package server
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
+	"log/slog"
 	"net/url"
 	"path"
 	"slices"
@@ -77,45 +79,123 @@ type SpxResourceSet struct {
 	sounds    map[string]*SpxSoundResource
 	sprites   map[string]*SpxSpriteResource
 	widgets   map[string]*SpxWidgetResource
+
+	// defaultBackdrop is the name of the backdrop shown when the game starts,
+	// or empty if there is none.
+	defaultBackdrop string
+
+	// mapWidth and mapHeight are the size of the map in index.json, or zero
+	// if it is determined by the backdrops.
+	mapWidth, mapHeight int
+
+	// zorder is the names of the sprites in the zorder of index.json, with
+	// empty strings in place of other shapes like widgets.
+	zorder []string
+
+	// metadata is the content of index.json.
+	metadata []byte
+}
+
+// SpxResourceMetadataError is the error of a malformed metadata file of spx
+// resources.
+type SpxResourceMetadataError struct {
+	// Path is the path of the metadata file relative to the spx resource
+	// root directory.
+	Path string
+
+	// Err is the error of parsing the metadata file.
+	Err error
+}
+
+// Error implements [error].
+func (e *SpxResourceMetadataError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error of parsing the metadata file.
+func (e *SpxResourceMetadataError) Unwrap() error {
+	return e.Err
 }
 
 // NewSpxResourceSet creates a new spx resource set.
 func NewSpxResourceSet(rootFS fs.FS) (*SpxResourceSet, error) {
-	set := &SpxResourceSet{
-		backdrops: make(map[string]*SpxBackdropResource),
-		sounds:    make(map[string]*SpxSoundResource),
-		sprites:   make(map[string]*SpxSpriteResource),
-		widgets:   make(map[string]*SpxWidgetResource),
-	}
+	return newSpxResourceSet(rootFS, 1, nil, nil)
+}
 
-	// Read and parse the main index.json for backdrops and widgets.
-	metadata, err := fs.ReadFile(rootFS, "index.json")
+// newSpxResourceSet creates a new spx resource set, parsing the metadata files
+// concurrently with at most the given number of workers. The parsed metadata
+// files are read from and written to the given persistent cache, if any,
+// keyed by their contents, and failures to use the cache are logged to the
+// given logger.
+func newSpxResourceSet(rootFS fs.FS, workers int, cache Cache, logger *slog.Logger) (*SpxResourceSet, error) {
+	files, err := readSpxResourceIndexFiles(rootFS)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read index.json: %w", err)
+		return nil, err
+	}
+	return files.resourceSet(rootFS, workers, cache, logger)
+}
+
+// resourceSet creates the spx resource set of the metadata files read from the
+// given root directory. See [newSpxResourceSet].
+func (files *spxResourceIndexFiles) resourceSet(rootFS fs.FS, workers int, cache Cache, logger *slog.Logger) (*SpxResourceSet, error) {
+	var key string
+	if cache != nil {
+		key = files.cacheKey(rootFS)
+		data, err := cache.Get(key)
+		if err == nil {
+			var assets spxResourceSetData
+			if err := json.Unmarshal(data, &assets); err == nil && len(assets.Sounds) == len(files.sounds) && len(assets.Sprites) == len(files.sprites) {
+				return assets.resourceSet(files), nil
+			}
+			logger.Warn("failed to decode cached spx resources", "error", err)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			logger.Warn("failed to get cached spx resources", "error", err)
+		}
 	}
 
-	var assets struct {
-		Backdrops []SpxBackdropResource `json:"backdrops"`
-		Zorder    []json.RawMessage     `json:"zorder"`
+	assets, err := parseSpxResourceIndexFiles(files, workers)
+	if err != nil {
+		return nil, err
 	}
-	if err := json.Unmarshal(metadata, &assets); err != nil {
-		return nil, fmt.Errorf("failed to parse index.json: %w", err)
+	if cache != nil {
+		data, err := json.Marshal(assets)
+		if err == nil {
+			err = cache.Put(key, data)
+		}
+		if err != nil {
+			logger.Warn("failed to cache spx resources", "error", err)
+		}
 	}
+	return assets.resourceSet(files), nil
+}
 
-	// Process backdrops.
-	for _, backdrop := range assets.Backdrops {
-		backdrop.ID = SpxBackdropResourceID{BackdropName: backdrop.Name}
-		set.backdrops[backdrop.Name] = &backdrop
-	}
+// spxResourceIndexFiles are the index.json files of spx resources.
+type spxResourceIndexFiles struct {
+	// index is the content of the main index.json.
+	index []byte
 
-	// Process widgets from zorder.
-	for _, item := range assets.Zorder {
-		var widget SpxWidgetResource
-		if err := json.Unmarshal(item, &widget); err == nil && widget.Name != "" {
-			widget.ID = SpxWidgetResourceID{WidgetName: widget.Name}
-			set.widgets[widget.Name] = &widget
-		}
+	// sounds and sprites are the index.json files of the sounds and sprites,
+	// in the order of their directories.
+	sounds, sprites []spxResourceIndexFile
+}
+
+// spxResourceIndexFile is the index.json file of a sound or sprite.
+type spxResourceIndexFile struct {
+	// name is the name of the directory of the resource.
+	name string
+
+	// content is the content of the file.
+	content []byte
+}
+
+// readSpxResourceIndexFiles reads the metadata files of the spx resources
+// in the given root directory.
+func readSpxResourceIndexFiles(rootFS fs.FS) (*spxResourceIndexFiles, error) {
+	index, err := fs.ReadFile(rootFS, "index.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index.json: %w", err)
 	}
+	files := &spxResourceIndexFiles{index: index}
 
 	// Read sounds directory.
 	soundEntries, err := fs.ReadDir(rootFS, "sounds")
@@ -126,20 +206,11 @@ func NewSpxResourceSet(rootFS fs.FS) (*SpxResourceSet, error) {
 		if !entry.IsDir() {
 			continue
 		}
-
-		soundName := entry.Name()
-		soundMetadata, err := fs.ReadFile(rootFS, path.Join("sounds", soundName, "index.json"))
+		content, err := fs.ReadFile(rootFS, path.Join("sounds", entry.Name(), "index.json"))
 		if err != nil {
 			return nil, fmt.Errorf("failed to read sound metadata: %w", err)
 		}
-
-		var sound SpxSoundResource
-		if err := json.Unmarshal(soundMetadata, &sound); err != nil {
-			return nil, fmt.Errorf("failed to parse sound metadata: %w", err)
-		}
-		sound.Name = soundName
-		sound.ID = SpxSoundResourceID{SoundName: soundName}
-		set.sounds[soundName] = &sound
+		files.sounds = append(files.sounds, spxResourceIndexFile{name: entry.Name(), content: content})
 	}
 
 	// Read sprites directory.
@@ -151,20 +222,187 @@ func NewSpxResourceSet(rootFS fs.FS) (*SpxResourceSet, error) {
 		if !entry.IsDir() {
 			continue
 		}
-
-		spriteName := entry.Name()
-		spriteMetadata, err := fs.ReadFile(rootFS, path.Join("sprites", spriteName, "index.json"))
+		content, err := fs.ReadFile(rootFS, path.Join("sprites", entry.Name(), "index.json"))
 		if err != nil {
 			return nil, fmt.Errorf("failed to read sprite metadata: %w", err)
 		}
+		files.sprites = append(files.sprites, spxResourceIndexFile{name: entry.Name(), content: content})
+	}
+	return files, nil
+}
+
+// spxResourceSetDataVersion is the version of the format of
+// [spxResourceSetData], which is part of its [Cache] key so that entries of
+// older formats are not decoded.
+const spxResourceSetDataVersion = "1"
+
+// fileHasher is implemented by file systems that know the content hashes of
+// their files, like [vfs.MapFS].
+type fileHasher interface {
+	FileHash(name string) ([sha256.Size]byte, bool)
+}
 
-		sprite := SpxSpriteResource{
-			ID:   SpxSpriteResourceID{SpriteName: spriteName},
-			Name: spriteName,
+// cacheKey returns the [Cache] key of the parsed metadata files read from the
+// given root directory. The content hashes of the files known by rootFS are
+// used if it implements [fileHasher], so that they are not hashed again.
+func (files *spxResourceIndexFiles) cacheKey(rootFS fs.FS) string {
+	hasher, _ := rootFS.(fileHasher)
+	hashOf := func(name string, content []byte) string {
+		if hasher != nil {
+			if hash, ok := hasher.FileHash(name); ok {
+				return string(hash[:])
+			}
 		}
-		if err := json.Unmarshal(spriteMetadata, &sprite); err != nil {
-			return nil, fmt.Errorf("failed to parse sprite metadata: %w", err)
+		hash := sha256.Sum256(content)
+		return string(hash[:])
+	}
+
+	inputs := make([]string, 0, 2+2*(len(files.sounds)+len(files.sprites)))
+	inputs = append(inputs, spxResourceSetDataVersion, hashOf("index.json", files.index))
+	for _, file := range files.sounds {
+		name := path.Join("sounds", file.name, "index.json")
+		inputs = append(inputs, name, hashOf(name, file.content))
+	}
+	for _, file := range files.sprites {
+		name := path.Join("sprites", file.name, "index.json")
+		inputs = append(inputs, name, hashOf(name, file.content))
+	}
+	return cacheKey(cacheKindSpxResources, inputs...)
+}
+
+// spxResourceSetData is the data of [SpxResourceSet] parsed from the metadata
+// files, which is also the form persisted in [Cache].
+type spxResourceSetData struct {
+	Backdrops       []SpxBackdropResource `json:"backdrops"`
+	DefaultBackdrop string                `json:"defaultBackdrop"`
+	MapWidth        int                   `json:"mapWidth"`
+	MapHeight       int                   `json:"mapHeight"`
+	Zorder          []string              `json:"zorder"`
+	Widgets         []SpxWidgetResource   `json:"widgets"`
+
+	// Sounds and Sprites are in the order of the files in
+	// [spxResourceIndexFiles].
+	Sounds  []SpxSoundResource  `json:"sounds"`
+	Sprites []SpxSpriteResource `json:"sprites"`
+}
+
+// parseSpxResourceIndexFiles parses the given metadata files. The files of
+// sounds and sprites are parsed concurrently with at most the given number of
+// workers, and the error of the first malformed one is returned.
+func parseSpxResourceIndexFiles(files *spxResourceIndexFiles, workers int) (*spxResourceSetData, error) {
+	var assets struct {
+		Backdrops     []SpxBackdropResource `json:"backdrops"`
+		BackdropIndex *int                  `json:"backdropIndex"`
+		Map           json.RawMessage       `json:"map"`
+		Zorder        []json.RawMessage     `json:"zorder"`
+	}
+	if err := json.Unmarshal(files.index, &assets); err != nil {
+		return nil, fmt.Errorf("failed to parse index.json: %w", &SpxResourceMetadataError{Path: "index.json", Err: err})
+	}
+	data := &spxResourceSetData{Backdrops: assets.Backdrops}
+
+	// Process the map size leniently, so that a malformed map does not fail
+	// loading other resources.
+	var mapSize struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	}
+	if len(assets.Map) > 0 && json.Unmarshal(assets.Map, &mapSize) == nil {
+		data.MapWidth, data.MapHeight = mapSize.Width, mapSize.Height
+	}
+
+	backdropIndex := 0
+	if assets.BackdropIndex != nil {
+		backdropIndex = *assets.BackdropIndex
+	}
+	if backdropIndex >= 0 && backdropIndex < len(assets.Backdrops) {
+		data.DefaultBackdrop = assets.Backdrops[backdropIndex].Name
+	}
+
+	// Process sprites and widgets from zorder.
+	data.Zorder = make([]string, len(assets.Zorder))
+	for i, item := range assets.Zorder {
+		if err := json.Unmarshal(item, &data.Zorder[i]); err == nil {
+			continue
+		}
+		var widget SpxWidgetResource
+		if err := json.Unmarshal(item, &widget); err == nil && widget.Name != "" {
+			data.Widgets = append(data.Widgets, widget)
+		}
+	}
+
+	data.Sounds = make([]SpxSoundResource, len(files.sounds))
+	soundErrs := make([]error, len(files.sounds))
+	data.Sprites = make([]SpxSpriteResource, len(files.sprites))
+	spriteErrs := make([]error, len(files.sprites))
+	forEachConcurrently(workers, len(files.sounds)+len(files.sprites), func(i int) {
+		if i < len(files.sounds) {
+			soundErrs[i] = json.Unmarshal(files.sounds[i].content, &data.Sounds[i])
+			return
+		}
+		i -= len(files.sounds)
+		data.Sprites[i].Name = files.sprites[i].name
+		spriteErrs[i] = json.Unmarshal(files.sprites[i].content, &data.Sprites[i])
+	})
+	for i, err := range soundErrs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse sound metadata: %w", &SpxResourceMetadataError{
+				Path: path.Join("sounds", files.sounds[i].name, "index.json"),
+				Err:  err,
+			})
+		}
+	}
+	for i, err := range spriteErrs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse sprite metadata: %w", &SpxResourceMetadataError{
+				Path: path.Join("sprites", files.sprites[i].name, "index.json"),
+				Err:  err,
+			})
 		}
+	}
+	return data, nil
+}
+
+// resourceSet returns the resource set of the data parsed from the given
+// metadata files.
+func (data *spxResourceSetData) resourceSet(files *spxResourceIndexFiles) *SpxResourceSet {
+	set := &SpxResourceSet{
+		backdrops:       make(map[string]*SpxBackdropResource),
+		sounds:          make(map[string]*SpxSoundResource),
+		sprites:         make(map[string]*SpxSpriteResource),
+		widgets:         make(map[string]*SpxWidgetResource),
+		defaultBackdrop: data.DefaultBackdrop,
+		mapWidth:        data.MapWidth,
+		mapHeight:       data.MapHeight,
+		zorder:          data.Zorder,
+		metadata:        files.index,
+	}
+
+	// Process backdrops.
+	for _, backdrop := range data.Backdrops {
+		backdrop.ID = SpxBackdropResourceID{BackdropName: backdrop.Name}
+		set.backdrops[backdrop.Name] = &backdrop
+	}
+
+	// Process widgets.
+	for _, widget := range data.Widgets {
+		widget.ID = SpxWidgetResourceID{WidgetName: widget.Name}
+		set.widgets[widget.Name] = &widget
+	}
+
+	// Process sounds.
+	for i, sound := range data.Sounds {
+		soundName := files.sounds[i].name
+		sound.Name = soundName
+		sound.ID = SpxSoundResourceID{SoundName: soundName}
+		set.sounds[soundName] = &sound
+	}
+
+	// Process sprites.
+	for i, sprite := range data.Sprites {
+		spriteName := files.sprites[i].name
+		sprite.ID = SpxSpriteResourceID{SpriteName: spriteName}
+		sprite.metadata = files.sprites[i].content
 
 		// Process costumes.
 		for i, costume := range sprite.Costumes {
@@ -198,8 +436,7 @@ func NewSpxResourceSet(rootFS fs.FS) (*SpxResourceSet, error) {
 
 		set.sprites[spriteName] = &sprite
 	}
-
-	return set, nil
+	return set
 }
 
 // Backdrop returns the backdrop with the given name. It returns nil if not found.
@@ -241,6 +478,26 @@ type SpxBackdropResource struct {
 	Path string                `json:"path"`
 }
 
+// spxResourceContains reports whether the spx resource with the given ID is
+// the one with the given parent ID or nested in it, e.g., a costume of a
+// sprite.
+func spxResourceContains(parentID, id SpxResourceID) bool {
+	if id == parentID {
+		return true
+	}
+	spriteID, ok := parentID.(SpxSpriteResourceID)
+	if !ok {
+		return false
+	}
+	switch id := id.(type) {
+	case SpxSpriteCostumeResourceID:
+		return id.SpriteName == spriteID.SpriteName
+	case SpxSpriteAnimationResourceID:
+		return id.SpriteName == spriteID.SpriteName
+	}
+	return false
+}
+
 // SpxBackdropResourceID is the ID of an spx backdrop resource.
 type SpxBackdropResourceID struct {
 	BackdropName string
@@ -279,8 +536,17 @@ func (id SpxSoundResourceID) URI() SpxResourceURI {
 }
 
 type spxSpriteFAnimation struct {
-	FrameFrom string `json:"frameFrom"`
-	FrameTo   string `json:"frameTo"`
+	FrameFrom string                    `json:"frameFrom"`
+	FrameTo   string                    `json:"frameTo"`
+	OnStart   *spxSpriteAnimationAction `json:"onStart"`
+	OnPlay    *spxSpriteAnimationAction `json:"onPlay"`
+}
+
+// spxSpriteAnimationAction is an action performed when an animation starts or
+// plays.
+type spxSpriteAnimationAction struct {
+	// Play is the name of the sound to play.
+	Play string `json:"play"`
 }
 
 // SpxSpriteResource represents an spx sprite resource.
@@ -294,6 +560,9 @@ type SpxSpriteResource struct {
 	FAnimations      map[string]spxSpriteFAnimation `json:"fAnimations"`
 	Animations       []SpxSpriteAnimationResource   `json:"-"`
 	DefaultAnimation string                         `json:"defaultAnimation"`
+
+	// metadata is the content of the index.json of the sprite.
+	metadata []byte
 }
 
 // SpxSpriteResourceID is the ID of an spx sprite resource.
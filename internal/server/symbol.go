@@ -0,0 +1,302 @@
+package server
+
+import (
+	"context"
+	"go/types"
+	"maps"
+	"path"
+	"slices"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_documentSymbol
+func (s *Server) textDocumentDocumentSymbol(params *DocumentSymbolParams) ([]DocumentSymbol, error) {
+	result, _, astFile, err := s.compileAndGetASTFileForDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	if astFile == nil {
+		return nil, nil
+	}
+
+	return result.documentSymbolsForASTFile(astFile), nil
+}
+
+// clientSupportsHierarchicalDocumentSymbols reports whether the client supports
+// hierarchical document symbols. The response of textDocument/documentSymbol
+// falls back to flat symbol information otherwise.
+func (s *Server) clientSupportsHierarchicalDocumentSymbols() bool {
+	clientCapabilities := s.clientCapabilities.Load()
+	return clientCapabilities == nil || clientCapabilities.TextDocument.DocumentSymbol.HierarchicalDocumentSymbolSupport
+}
+
+// flattenDocumentSymbols flattens the given hierarchical document symbols of
+// the given document into symbol information, with the names of their parents
+// as container names.
+func flattenDocumentSymbols(documentURI DocumentURI, symbols []DocumentSymbol) []SymbolInformation {
+	infos := []SymbolInformation{}
+	var flatten func(symbols []DocumentSymbol, containerName string)
+	flatten = func(symbols []DocumentSymbol, containerName string) {
+		for _, symbol := range symbols {
+			infos = append(infos, SymbolInformation{
+				Name:          symbol.Name,
+				Kind:          symbol.Kind,
+				Tags:          symbol.Tags,
+				Deprecated:    symbol.Deprecated,
+				Location:      Location{URI: documentURI, Range: symbol.Range},
+				ContainerName: containerName,
+			})
+			flatten(symbol.Children, symbol.Name)
+		}
+	}
+	flatten(symbols, "")
+	return infos
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#workspace_symbol
+func (s *Server) workspaceSymbol(ctx context.Context, params *WorkspaceSymbolParams) ([]WorkspaceSymbol, error) {
+	results, err := s.compileWorkspaceFolders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := []WorkspaceSymbol{}
+	for _, result := range results {
+		symbols = append(symbols, result.workspaceSymbols(params.Query)...)
+	}
+	return symbols, nil
+}
+
+// workspaceSymbols returns the symbols matching the given query in the
+// compiled workspace folder.
+func (r *compileResult) workspaceSymbols(query string) []WorkspaceSymbol {
+	var symbols []WorkspaceSymbol
+	for _, spxFile := range slices.Sorted(maps.Keys(r.mainASTPkg.Files)) {
+		astFile := r.mainASTPkg.Files[spxFile]
+		documentURI := r.documentURIs[spxFile]
+		containerName := path.Base(spxFile)
+
+		className := strings.TrimSuffix(containerName, r.classfileKind.Ext)
+		if spxFile == r.mainSpxFile {
+			className = "Game"
+		}
+		if _, ok := fuzzyMatch(query, className); ok {
+			symbols = append(symbols, WorkspaceSymbol{
+				Location: OrPLocation_workspace_symbol{Value: Location{URI: documentURI}},
+				BaseSymbolInformation: BaseSymbolInformation{
+					Name:          className,
+					Kind:          Class,
+					ContainerName: containerName,
+				},
+			})
+		}
+
+		var collect func(docSymbols []DocumentSymbol)
+		collect = func(docSymbols []DocumentSymbol) {
+			for _, docSymbol := range docSymbols {
+				if _, ok := fuzzyMatch(query, docSymbol.Name); ok {
+					symbols = append(symbols, WorkspaceSymbol{
+						Location: OrPLocation_workspace_symbol{Value: Location{
+							URI:   documentURI,
+							Range: docSymbol.SelectionRange,
+						}},
+						BaseSymbolInformation: BaseSymbolInformation{
+							Name:          docSymbol.Name,
+							Kind:          docSymbol.Kind,
+							ContainerName: containerName,
+						},
+					})
+				}
+				collect(docSymbol.Children)
+			}
+		}
+		collect(r.documentSymbolsForASTFile(astFile))
+	}
+	return symbols
+}
+
+// documentSymbolsForASTFile returns the document symbols for the given AST file.
+func (r *compileResult) documentSymbolsForASTFile(astFile *gopast.File) []DocumentSymbol {
+	symbols := []DocumentSymbol{}
+	for _, decl := range astFile.Decls {
+		switch decl := decl.(type) {
+		case *gopast.GenDecl:
+			symbols = append(symbols, r.documentSymbolsForGenDecl(decl)...)
+		case *gopast.FuncDecl:
+			if decl.Shadow {
+				if decl.Body != nil {
+					symbols = append(symbols, r.documentSymbolsForStmts(decl.Body.List)...)
+				}
+				continue
+			}
+			if symbol, ok := r.documentSymbolForFuncDecl(decl); ok {
+				symbols = append(symbols, symbol)
+			}
+		}
+	}
+	return symbols
+}
+
+// documentSymbolsForGenDecl returns the document symbols for the given
+// general declaration.
+func (r *compileResult) documentSymbolsForGenDecl(decl *gopast.GenDecl) []DocumentSymbol {
+	var symbols []DocumentSymbol
+	for _, spec := range decl.Specs {
+		switch spec := spec.(type) {
+		case *gopast.ValueSpec:
+			for _, name := range spec.Names {
+				if name.Name == "_" || !name.Pos().IsValid() {
+					continue
+				}
+				obj := r.typeInfo.ObjectOf(name)
+				if obj == nil {
+					continue
+				}
+
+				kind := Variable
+				switch {
+				case decl.Tok == goptoken.CONST:
+					kind = Constant
+				case r.isDefinedInFirstVarBlock(obj):
+					kind = Field
+				}
+				symbols = append(symbols, DocumentSymbol{
+					Name:           name.Name,
+					Detail:         getSimplifiedTypeString(obj.Type()),
+					Kind:           kind,
+					Range:          r.rangeForNode(spec),
+					SelectionRange: r.rangeForNode(name),
+				})
+			}
+		case *gopast.TypeSpec:
+			if spec.Name == nil || !spec.Name.Pos().IsValid() {
+				continue
+			}
+			obj := r.typeInfo.ObjectOf(spec.Name)
+			if obj == nil {
+				continue
+			}
+
+			symbol := DocumentSymbol{
+				Name:           spec.Name.Name,
+				Kind:           Class,
+				Range:          r.rangeForNode(spec),
+				SelectionRange: r.rangeForNode(spec.Name),
+			}
+			switch typ := spec.Type.(type) {
+			case *gopast.StructType:
+				symbol.Kind = Struct
+				symbol.Children = r.documentSymbolsForFieldList(typ.Fields, Field)
+			case *gopast.InterfaceType:
+				symbol.Kind = Interface
+				symbol.Children = r.documentSymbolsForFieldList(typ.Methods, Method)
+			}
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+// documentSymbolsForFieldList returns the document symbols for the named
+// entries of the given field list.
+func (r *compileResult) documentSymbolsForFieldList(fields *gopast.FieldList, kind SymbolKind) []DocumentSymbol {
+	if fields == nil {
+		return nil
+	}
+	var symbols []DocumentSymbol
+	for _, field := range fields.List {
+		for _, name := range field.Names {
+			obj := r.typeInfo.ObjectOf(name)
+			if obj == nil {
+				continue
+			}
+			symbols = append(symbols, DocumentSymbol{
+				Name:           name.Name,
+				Detail:         getSimplifiedTypeString(obj.Type()),
+				Kind:           kind,
+				Range:          r.rangeForNode(field),
+				SelectionRange: r.rangeForNode(name),
+			})
+		}
+	}
+	return symbols
+}
+
+// documentSymbolForFuncDecl returns the document symbol for the given function
+// declaration.
+func (r *compileResult) documentSymbolForFuncDecl(decl *gopast.FuncDecl) (DocumentSymbol, bool) {
+	if decl.Name == nil || !decl.Name.Pos().IsValid() {
+		return DocumentSymbol{}, false
+	}
+	obj := r.typeInfo.ObjectOf(decl.Name)
+	if obj == nil {
+		return DocumentSymbol{}, false
+	}
+
+	kind := Function
+	if decl.Recv != nil && len(decl.Recv.List) > 0 {
+		kind = Method
+	}
+	symbol := DocumentSymbol{
+		Name:           decl.Name.Name,
+		Detail:         getSimplifiedTypeString(obj.Type()),
+		Kind:           kind,
+		Range:          r.rangeForNode(decl),
+		SelectionRange: r.rangeForNode(decl.Name),
+	}
+	if decl.Body != nil {
+		symbol.Children = r.documentSymbolsForStmts(decl.Body.List)
+	}
+	return symbol, true
+}
+
+// documentSymbolsForStmts returns the document symbols for spx event handlers
+// registered by the given statements. Event handlers nested in other event
+// handlers are returned as children.
+func (r *compileResult) documentSymbolsForStmts(stmts []gopast.Stmt) []DocumentSymbol {
+	var symbols []DocumentSymbol
+	for _, stmt := range stmts {
+		exprStmt, ok := stmt.(*gopast.ExprStmt)
+		if !ok {
+			continue
+		}
+		callExpr, ok := exprStmt.X.(*gopast.CallExpr)
+		if !ok {
+			continue
+		}
+		funcIdent, ok := callExpr.Fun.(*gopast.Ident)
+		if !ok || !isSpxEventHandlerFuncName(funcIdent.Name) || !isSpxPkgObject(r.typeInfo.ObjectOf(funcIdent)) {
+			continue
+		}
+
+		symbol := DocumentSymbol{
+			Name:           funcIdent.Name,
+			Kind:           Event,
+			Range:          r.rangeForNode(stmt),
+			SelectionRange: r.rangeForNode(funcIdent),
+		}
+		for _, arg := range callExpr.Args {
+			switch arg := arg.(type) {
+			case *gopast.LambdaExpr2:
+				if arg.Body != nil {
+					symbol.Children = append(symbol.Children, r.documentSymbolsForStmts(arg.Body.List)...)
+				}
+			case *gopast.FuncLit:
+				if arg.Body != nil {
+					symbol.Children = append(symbol.Children, r.documentSymbolsForStmts(arg.Body.List)...)
+				}
+			default:
+				if symbol.Detail == "" {
+					if tv, ok := r.typeInfo.Types[arg]; ok && tv.Value != nil && types.AssignableTo(tv.Type, types.Typ[types.String]) {
+						symbol.Detail = tv.Value.ExactString()
+					}
+				}
+			}
+		}
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
@@ -0,0 +1,290 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentDocumentSymbol(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+	Score    int
+)
+
+const MaxScore = 100
+
+type Point struct {
+	X int
+	Y int
+}
+
+func reset() {
+	Score = 0
+}
+
+onStart => {
+	reset
+}
+
+onMsg "win", => {
+	println "win"
+}
+
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		symbols, err := s.textDocumentDocumentSymbol(&DocumentSymbolParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		require.Len(t, symbols, 7)
+
+		assert.Equal(t, DocumentSymbol{
+			Name:   "MySprite",
+			Detail: "Sprite",
+			Kind:   Field,
+			Range: Range{
+				Start: Position{Line: 2, Character: 1},
+				End:   Position{Line: 2, Character: 16},
+			},
+			SelectionRange: Range{
+				Start: Position{Line: 2, Character: 1},
+				End:   Position{Line: 2, Character: 9},
+			},
+		}, symbols[0])
+		assert.Equal(t, "Score", symbols[1].Name)
+		assert.Equal(t, Field, symbols[1].Kind)
+		assert.Equal(t, "int", symbols[1].Detail)
+
+		assert.Equal(t, "MaxScore", symbols[2].Name)
+		assert.Equal(t, Constant, symbols[2].Kind)
+
+		assert.Equal(t, "Point", symbols[3].Name)
+		assert.Equal(t, Struct, symbols[3].Kind)
+		require.Len(t, symbols[3].Children, 2)
+		assert.Equal(t, "X", symbols[3].Children[0].Name)
+		assert.Equal(t, Field, symbols[3].Children[0].Kind)
+		assert.Equal(t, "Y", symbols[3].Children[1].Name)
+
+		assert.Equal(t, "reset", symbols[4].Name)
+		assert.Equal(t, Method, symbols[4].Kind)
+		assert.Equal(t, "func()", symbols[4].Detail)
+		assert.Equal(t, Range{
+			Start: Position{Line: 13, Character: 0},
+			End:   Position{Line: 15, Character: 1},
+		}, symbols[4].Range)
+		assert.Equal(t, Range{
+			Start: Position{Line: 13, Character: 5},
+			End:   Position{Line: 13, Character: 10},
+		}, symbols[4].SelectionRange)
+
+		assert.Equal(t, DocumentSymbol{
+			Name: "onStart",
+			Kind: Event,
+			Range: Range{
+				Start: Position{Line: 17, Character: 0},
+				End:   Position{Line: 19, Character: 1},
+			},
+			SelectionRange: Range{
+				Start: Position{Line: 17, Character: 0},
+				End:   Position{Line: 17, Character: 7},
+			},
+		}, symbols[5])
+
+		assert.Equal(t, "onMsg", symbols[6].Name)
+		assert.Equal(t, Event, symbols[6].Kind)
+		assert.Equal(t, `"win"`, symbols[6].Detail)
+	})
+
+	t.Run("NestedEventHandlers", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+var (
+	count int
+)
+
+onStart => {
+	onClick => {
+		count++
+	}
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+
+		symbols, err := s.textDocumentDocumentSymbol(&DocumentSymbolParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///MySprite.spx"},
+		})
+		require.NoError(t, err)
+		require.Len(t, symbols, 2)
+		assert.Equal(t, "count", symbols[0].Name)
+		assert.Equal(t, Field, symbols[0].Kind)
+		assert.Equal(t, "onStart", symbols[1].Name)
+		require.Len(t, symbols[1].Children, 1)
+		assert.Equal(t, "onClick", symbols[1].Children[0].Name)
+		assert.Equal(t, Event, symbols[1].Children[0].Kind)
+	})
+
+	t.Run("NonSpxFile", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+
+		symbols, err := s.textDocumentDocumentSymbol(&DocumentSymbolParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.gop"},
+		})
+		require.EqualError(t, err, `file "main.gop" does not have .spx extension`)
+		require.Nil(t, symbols)
+	})
+}
+
+func TestFlattenDocumentSymbols(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		onClickRange := Range{
+			Start: Position{Line: 2, Character: 1},
+			End:   Position{Line: 4, Character: 2},
+		}
+		onStartRange := Range{
+			Start: Position{Line: 1, Character: 0},
+			End:   Position{Line: 5, Character: 1},
+		}
+		infos := flattenDocumentSymbols("file:///MySprite.spx", []DocumentSymbol{
+			{
+				Name:  "onStart",
+				Kind:  Event,
+				Range: onStartRange,
+				Children: []DocumentSymbol{
+					{Name: "onClick", Kind: Event, Range: onClickRange},
+				},
+			},
+		})
+		assert.Equal(t, []SymbolInformation{
+			{
+				Name:     "onStart",
+				Kind:     Event,
+				Location: Location{URI: "file:///MySprite.spx", Range: onStartRange},
+			},
+			{
+				Name:          "onClick",
+				Kind:          Event,
+				Location:      Location{URI: "file:///MySprite.spx", Range: onClickRange},
+				ContainerName: "onStart",
+			},
+		}, infos)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		assert.Empty(t, flattenDocumentSymbols("file:///main.spx", nil))
+	})
+}
+
+func TestServerWorkspaceSymbol(t *testing.T) {
+	newTestServer := func() *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite Sprite
+	Score    int
+)
+
+func reset() {
+	Score = 0
+}
+
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+func myTurn() {
+}
+
+onStart => {
+	myTurn
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newTestServer()
+
+		symbols, err := s.workspaceSymbol(context.Background(), &WorkspaceSymbolParams{Query: "MySpr"})
+		require.NoError(t, err)
+		assert.Equal(t, []WorkspaceSymbol{
+			{
+				Location: OrPLocation_workspace_symbol{Value: Location{URI: "file:///MySprite.spx"}},
+				BaseSymbolInformation: BaseSymbolInformation{
+					Name:          "MySprite",
+					Kind:          Class,
+					ContainerName: "MySprite.spx",
+				},
+			},
+			{
+				Location: OrPLocation_workspace_symbol{Value: Location{
+					URI: "file:///main.spx",
+					Range: Range{
+						Start: Position{Line: 2, Character: 1},
+						End:   Position{Line: 2, Character: 9},
+					},
+				}},
+				BaseSymbolInformation: BaseSymbolInformation{
+					Name:          "MySprite",
+					Kind:          Field,
+					ContainerName: "main.spx",
+				},
+			},
+		}, symbols)
+	})
+
+	t.Run("Fuzzy", func(t *testing.T) {
+		s := newTestServer()
+
+		symbols, err := s.workspaceSymbol(context.Background(), &WorkspaceSymbolParams{Query: "mtn"})
+		require.NoError(t, err)
+		require.Len(t, symbols, 1)
+		assert.Equal(t, "myTurn", symbols[0].Name)
+		assert.Equal(t, Method, symbols[0].Kind)
+		assert.Equal(t, "MySprite.spx", symbols[0].ContainerName)
+	})
+
+	t.Run("EmptyQuery", func(t *testing.T) {
+		s := newTestServer()
+
+		symbols, err := s.workspaceSymbol(context.Background(), &WorkspaceSymbolParams{})
+		require.NoError(t, err)
+		names := make([]string, 0, len(symbols))
+		for _, symbol := range symbols {
+			names = append(names, symbol.Name)
+		}
+		assert.Equal(t, []string{"MySprite", "myTurn", "onStart", "Game", "MySprite", "Score", "reset"}, names)
+	})
+
+	t.Run("NoMatch", func(t *testing.T) {
+		s := newTestServer()
+
+		symbols, err := s.workspaceSymbol(context.Background(), &WorkspaceSymbolParams{Query: "xyz"})
+		require.NoError(t, err)
+		assert.Empty(t, symbols)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		s := newTestServer()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := s.workspaceSymbol(ctx, &WorkspaceSymbolParams{Query: "MySpr"})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
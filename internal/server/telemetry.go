@@ -0,0 +1,33 @@
+package server
+
+import "time"
+
+// Telemetry receives measurements of the server, which can be implemented by
+// the embedder to collect metrics such as request latencies, error counts and
+// analysis durations. Its methods may be called concurrently.
+type Telemetry interface {
+	// RequestHandled is called once a request with the given method has been
+	// handled, where err is the error replied to the client if any.
+	RequestHandled(method string, duration time.Duration, err error)
+
+	// AnalysisCompleted is called once the spx project in the given workspace
+	// folder has been analyzed, where err is the error of the analysis if
+	// any. Analyses served from cache are not reported.
+	AnalysisCompleted(workspaceFolder DocumentURI, files int, duration time.Duration, err error)
+}
+
+// WithTelemetry sets the [Telemetry] that receives measurements of the server.
+func WithTelemetry(telemetry Telemetry) Option {
+	return func(s *Server) {
+		s.telemetry = telemetry
+	}
+}
+
+// noopTelemetry is a [Telemetry] that discards all measurements.
+type noopTelemetry struct{}
+
+// RequestHandled implements [Telemetry].
+func (noopTelemetry) RequestHandled(string, time.Duration, error) {}
+
+// AnalysisCompleted implements [Telemetry].
+func (noopTelemetry) AnalysisCompleted(DocumentURI, int, time.Duration, error) {}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingTelemetry is a [Telemetry] that records all measurements.
+type recordingTelemetry struct {
+	mu       sync.Mutex
+	requests []recordedRequest
+	analyses []recordedAnalysis
+}
+
+type recordedRequest struct {
+	method string
+	err    error
+}
+
+type recordedAnalysis struct {
+	workspaceFolder DocumentURI
+	files           int
+	err             error
+}
+
+// RequestHandled implements [Telemetry].
+func (t *recordingTelemetry) RequestHandled(method string, duration time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.requests = append(t.requests, recordedRequest{method: method, err: err})
+}
+
+// AnalysisCompleted implements [Telemetry].
+func (t *recordingTelemetry) AnalysisCompleted(workspaceFolder DocumentURI, files int, duration time.Duration, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.analyses = append(t.analyses, recordedAnalysis{workspaceFolder: workspaceFolder, files: files, err: err})
+}
+
+func TestServerTelemetry(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		telemetry := &recordingTelemetry{}
+		responses := make(chan *jsonrpc2.Response, 2)
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+onStart => {
+	echo "Hello"
+}
+
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}), messageReplierFunc(func(m jsonrpc2.Message) error {
+			if resp, ok := m.(*jsonrpc2.Response); ok {
+				responses <- resp
+			}
+			return nil
+		}), WithTelemetry(telemetry))
+
+		c, err := jsonrpc2.NewCall(jsonrpc2.NewIntID(1), "textDocument/documentSymbol", DocumentSymbolParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(c))
+		require.NoError(t, (<-responses).Err())
+
+		c, err = jsonrpc2.NewCall(jsonrpc2.NewIntID(2), "textDocument/documentSymbol", DocumentSymbolParams{
+			TextDocument: TextDocumentIdentifier{URI: "untitled:main.spx"},
+		})
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(c))
+		require.Error(t, (<-responses).Err())
+
+		require.Eventually(t, func() bool {
+			telemetry.mu.Lock()
+			defer telemetry.mu.Unlock()
+			return len(telemetry.requests) == 2
+		}, time.Second, time.Millisecond)
+		var errCount int
+		for _, request := range telemetry.requests {
+			assert.Equal(t, "textDocument/documentSymbol", request.method)
+			if request.err != nil {
+				errCount++
+			}
+		}
+		assert.Equal(t, 1, errCount)
+		assert.Equal(t, []recordedAnalysis{{workspaceFolder: "file:///", files: 1}}, telemetry.analyses)
+	})
+}
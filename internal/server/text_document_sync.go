@@ -0,0 +1,108 @@
+package server
+
+import (
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_didOpen
+func (s *Server) textDocumentDidOpen(params *DidOpenTextDocumentParams) error {
+	spxFile, err := s.fromDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return fmt.Errorf("failed to get file path from document uri %q: %w", params.TextDocument.URI, err)
+	}
+
+	s.documentOverlayMu.Lock()
+	s.documentOverlay[spxFile] = vfs.NewMapFile([]byte(params.TextDocument.Text), time.Now())
+	s.documentVersions[spxFile] = params.TextDocument.Version
+	s.documentOverlayMu.Unlock()
+
+	s.scheduleDiagnosticsFor(params.TextDocument.URI)
+	return nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_didChange
+func (s *Server) textDocumentDidChange(params *DidChangeTextDocumentParams) error {
+	spxFile, err := s.fromDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return fmt.Errorf("failed to get file path from document uri %q: %w", params.TextDocument.URI, err)
+	}
+
+	s.documentOverlayMu.Lock()
+	file, ok := s.documentOverlay[spxFile]
+	if !ok {
+		s.documentOverlayMu.Unlock()
+		return fmt.Errorf("document %q is not open", params.TextDocument.URI)
+	}
+	content := string(file.Content)
+	encoding := s.positionEncoding()
+	for _, change := range params.ContentChanges {
+		if change.Range == nil {
+			content = change.Text
+			continue
+		}
+		start := positionOffset(content, change.Range.Start, encoding)
+		end := max(positionOffset(content, change.Range.End, encoding), start)
+		content = content[:start] + change.Text + content[end:]
+	}
+	s.documentOverlay[spxFile] = vfs.NewMapFile([]byte(content), time.Now())
+	s.documentVersions[spxFile] = params.TextDocument.Version
+	s.documentOverlayMu.Unlock()
+
+	s.scheduleDiagnosticsFor(params.TextDocument.URI)
+	return nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#textDocument_didClose
+func (s *Server) textDocumentDidClose(params *DidCloseTextDocumentParams) error {
+	spxFile, err := s.fromDocumentURI(params.TextDocument.URI)
+	if err != nil {
+		return fmt.Errorf("failed to get file path from document uri %q: %w", params.TextDocument.URI, err)
+	}
+
+	s.documentOverlayMu.Lock()
+	delete(s.documentOverlay, spxFile)
+	delete(s.documentVersions, spxFile)
+	s.documentOverlayMu.Unlock()
+
+	// The file on disk, which may have just been saved, is used from now on.
+	s.workspaceRootFS.Invalidate()
+
+	s.lastSemanticTokensMu.Lock()
+	delete(s.lastSemanticTokens, params.TextDocument.URI)
+	s.lastSemanticTokensMu.Unlock()
+
+	s.scheduleDiagnosticsFor(params.TextDocument.URI)
+	return nil
+}
+
+// scheduleDiagnosticsFor schedules publishing the diagnostics of the workspace
+// folder containing the given document. See [Server.scheduleDiagnostics].
+func (s *Server) scheduleDiagnosticsFor(documentURI DocumentURI) {
+	if folder, _, err := s.workspaceFolderFor(documentURI); err == nil {
+		s.scheduleDiagnostics(folder)
+	}
+}
+
+// openDocumentVersions returns the versions of open documents, keyed by their
+// paths relative to the workspace root.
+func (s *Server) openDocumentVersions() map[string]int32 {
+	s.documentOverlayMu.Lock()
+	defer s.documentOverlayMu.Unlock()
+	return maps.Clone(s.documentVersions)
+}
+
+// snapshot returns a snapshot of the workspace file system with the contents
+// of open documents overlaid on top of it.
+func (s *Server) snapshot() *vfs.MapFS {
+	s.documentOverlayMu.Lock()
+	overlay := maps.Clone(s.documentOverlay)
+	s.documentOverlayMu.Unlock()
+	if len(overlay) == 0 {
+		return s.workspaceRootFS.Snapshot()
+	}
+	return s.workspaceRootFS.WithOverlay(overlay).Snapshot()
+}
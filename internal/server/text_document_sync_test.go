@@ -0,0 +1,139 @@
+package server
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerTextDocumentSync(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`var score int
+`),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+		uri := DocumentURI("file:///main.spx")
+
+		err := s.textDocumentDidOpen(&DidOpenTextDocumentParams{
+			TextDocument: TextDocumentItem{
+				URI:     uri,
+				Version: 1,
+				Text:    "var score int\nscore = 1\n",
+			},
+		})
+		require.NoError(t, err)
+		content, err := fs.ReadFile(s.snapshot(), "main.spx")
+		require.NoError(t, err)
+		assert.Equal(t, "var score int\nscore = 1\n", string(content))
+
+		err = s.textDocumentDidChange(&DidChangeTextDocumentParams{
+			TextDocument: VersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: TextDocumentIdentifier{URI: uri},
+				Version:                2,
+			},
+			ContentChanges: []TextDocumentContentChangeEvent{
+				{
+					Range: &Range{
+						Start: Position{Line: 1, Character: 8},
+						End:   Position{Line: 1, Character: 9},
+					},
+					Text: "42",
+				},
+				{
+					Range: &Range{
+						Start: Position{Line: 2, Character: 0},
+						End:   Position{Line: 2, Character: 0},
+					},
+					Text: "echo score\n",
+				},
+			},
+		})
+		require.NoError(t, err)
+		content, err = fs.ReadFile(s.snapshot(), "main.spx")
+		require.NoError(t, err)
+		assert.Equal(t, "var score int\nscore = 42\necho score\n", string(content))
+
+		result, err := s.compile()
+		require.NoError(t, err)
+		assert.Empty(t, result.diagnostics[uri])
+
+		err = s.textDocumentDidClose(&DidCloseTextDocumentParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+		})
+		require.NoError(t, err)
+		content, err = fs.ReadFile(s.snapshot(), "main.spx")
+		require.NoError(t, err)
+		assert.Equal(t, "var score int\n", string(content))
+	})
+
+	t.Run("FullChange", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`var score int
+`),
+		}), nil)
+		uri := DocumentURI("file:///main.spx")
+
+		err := s.textDocumentDidOpen(&DidOpenTextDocumentParams{
+			TextDocument: TextDocumentItem{URI: uri, Version: 1, Text: "var score int\n"},
+		})
+		require.NoError(t, err)
+		err = s.textDocumentDidChange(&DidChangeTextDocumentParams{
+			TextDocument: VersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: TextDocumentIdentifier{URI: uri},
+				Version:                2,
+			},
+			ContentChanges: []TextDocumentContentChangeEvent{
+				{Text: "var name string\n"},
+			},
+		})
+		require.NoError(t, err)
+		content, err := fs.ReadFile(s.snapshot(), "main.spx")
+		require.NoError(t, err)
+		assert.Equal(t, "var name string\n", string(content))
+	})
+
+	t.Run("UTF16Change", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		uri := DocumentURI("file:///main.spx")
+
+		err := s.textDocumentDidOpen(&DidOpenTextDocumentParams{
+			TextDocument: TextDocumentItem{URI: uri, Version: 1, Text: "echo \"你好😀\", 1\n"},
+		})
+		require.NoError(t, err)
+		err = s.textDocumentDidChange(&DidChangeTextDocumentParams{
+			TextDocument: VersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: TextDocumentIdentifier{URI: uri},
+				Version:                2,
+			},
+			ContentChanges: []TextDocumentContentChangeEvent{
+				{
+					Range: &Range{
+						Start: Position{Line: 0, Character: 13},
+						End:   Position{Line: 0, Character: 14},
+					},
+					Text: "2",
+				},
+			},
+		})
+		require.NoError(t, err)
+		content, err := fs.ReadFile(s.snapshot(), "main.spx")
+		require.NoError(t, err)
+		assert.Equal(t, "echo \"你好😀\", 2\n", string(content))
+	})
+
+	t.Run("ChangeWithoutOpen", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+
+		err := s.textDocumentDidChange(&DidChangeTextDocumentParams{
+			TextDocument: VersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: TextDocumentIdentifier{URI: "file:///main.spx"},
+				Version:                1,
+			},
+			ContentChanges: []TextDocumentContentChangeEvent{{Text: "var score int\n"}},
+		})
+		require.EqualError(t, err, `document "file:///main.spx" is not open`)
+	})
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+)
+
+// typeErrorRewording rewords the type errors matching its pattern into a
+// beginner-friendly message with a specific diagnostic code.
+type typeErrorRewording struct {
+	// code is the diagnostic code of the reworded type errors.
+	code string
+
+	// pattern matches the messages of the type errors. Its named groups are
+	// the placeholders of message, e.g., {type}.
+	pattern *regexp.Regexp
+
+	// message is the default template of the reworded messages.
+	message string
+}
+
+// typeErrorRewordings are the rewordings of common type errors, tried in order.
+var typeErrorRewordings = []typeErrorRewording{
+	{
+		code:    DiagnosticCodeUndefinedName,
+		pattern: regexp.MustCompile(`^undefined: (?P<name>.+)$`),
+		message: "{name} is not defined",
+	},
+	{
+		code:    DiagnosticCodeTypeMismatch,
+		pattern: regexp.MustCompile(`(?s)^cannot use (?P<value>.+) \(type (?:untyped )?(?P<type>.+)\) as type (?P<expected>.+) in (?P<context>.+)$`),
+		message: "expected a value of type {expected} here, but {value} has type {type}",
+	},
+	{
+		code:    DiagnosticCodeTooManyArguments,
+		pattern: regexp.MustCompile(`(?s)^too many arguments in call to (?:this\.)?(?P<func>\S+)\n\thave \((?P<have>.*)\)\n\twant \((?P<want>.*)\)$`),
+		message: "{func} is called with too many arguments, it expects ({want}) but got ({have})",
+	},
+	{
+		code:    DiagnosticCodeNotEnoughArguments,
+		pattern: regexp.MustCompile(`(?s)^not enough arguments in call to (?:this\.)?(?P<func>\S+)\n\thave \((?P<have>.*)\)\n\twant \((?P<want>.*)\)$`),
+		message: "{func} is called with too few arguments, it expects ({want}) but got ({have})",
+	},
+	{
+		code:    DiagnosticCodeMismatchedTypes,
+		pattern: regexp.MustCompile(`^invalid operation: (?P<expr>.+) \(mismatched types (?P<left>.+) and (?P<right>.+)\)$`),
+		message: "values of types {left} and {right} cannot be used together in {expr}",
+	},
+	{
+		code:    DiagnosticCodeUnknownMember,
+		pattern: regexp.MustCompile(`^(?P<expr>.+)\.(?P<member>[^.\s]+) undefined \(type (?P<type>.+) has no field or method \S+\)$`),
+		message: "{type} has no field or method named {member}",
+	},
+	{
+		code:    DiagnosticCodeNotCallable,
+		pattern: regexp.MustCompile(`^cannot call non-function (?P<name>[^(]+)\(.*\) \(type (?P<type>.+)\)$`),
+		message: "{name} has type {type}, which is not a function and cannot be called",
+	},
+}
+
+// isTypeErrorRewordingCode reports whether the given diagnostic code is the
+// code of a type error rewording.
+func isTypeErrorRewordingCode(code string) bool {
+	for _, rewording := range typeErrorRewordings {
+		if rewording.code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// rewordTypeError rewords the given type error message with the message
+// templates overridden by the config. It reports false if no rewording matches
+// the message, or the matching one is turned off.
+func (c *Config) rewordTypeError(msg string) (code, reworded string, ok bool) {
+	for _, rewording := range typeErrorRewordings {
+		matches := rewording.pattern.FindStringSubmatch(msg)
+		if matches == nil {
+			continue
+		}
+		template := rewording.message
+		if override, ok := c.Diagnostics.Messages[rewording.code]; ok {
+			if override == diagnosticMessageOff {
+				return "", "", false
+			}
+			template = override
+		}
+		var oldnew []string
+		for i, name := range rewording.pattern.SubexpNames() {
+			if name != "" {
+				oldnew = append(oldnew, "{"+name+"}", matches[i])
+			}
+		}
+		return rewording.code, strings.NewReplacer(oldnew...).Replace(template), true
+	}
+	return "", "", false
+}
+
+// rewordTypeErrorDiagnostics rewords the messages of the type error
+// diagnostics in the compile result, and replaces their codes with the
+// specific ones. The original messages are kept as related information.
+func (r *compileResult) rewordTypeErrorDiagnostics(config *Config) {
+	for uri, diags := range r.diagnostics {
+		for i, diag := range diags {
+			if diag.Code != DiagnosticCodeTypeError {
+				continue
+			}
+			code, message, ok := config.rewordTypeError(diag.Message)
+			if !ok {
+				continue
+			}
+			diags[i].Code = code
+			diags[i].Message = message
+			diags[i].RelatedInformation = append(diag.RelatedInformation, DiagnosticRelatedInformation{
+				Location: Location{URI: uri, Range: diag.Range},
+				Message:  diag.Message,
+			})
+		}
+	}
+}
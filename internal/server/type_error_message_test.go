@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerRewordTypeErrorDiagnostics(t *testing.T) {
+	const mainSpx = `
+var (
+	n int
+)
+func f(a int) int {
+	return a
+}
+onStart => {
+	n = "hi"
+	f 1, 2
+	n = n + "x"
+}
+run "assets", {Title: "My Game"}
+`
+	diagnosticsFor := func(t *testing.T, s *Server) []Diagnostic {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		return fullReport.Items
+	}
+	newServer := func() *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx":          []byte(mainSpx),
+			"assets/index.json": []byte(`{}`),
+		}), nil)
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer()
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 3)
+		assert.Equal(t, Diagnostic{
+			Severity: SeverityError,
+			Code:     DiagnosticCodeTypeMismatch,
+			Range: Range{
+				Start: Position{Line: 8, Character: 5},
+				End:   Position{Line: 8, Character: 5},
+			},
+			Message: `expected a value of type int here, but "hi" has type string`,
+			RelatedInformation: []DiagnosticRelatedInformation{{
+				Location: Location{
+					URI: "file:///main.spx",
+					Range: Range{
+						Start: Position{Line: 8, Character: 5},
+						End:   Position{Line: 8, Character: 5},
+					},
+				},
+				Message: `cannot use "hi" (type untyped string) as type int in assignment`,
+			}},
+		}, diags[0])
+		assert.Equal(t, DiagnosticCodeTooManyArguments, diags[1].Code)
+		assert.Equal(t, "f is called with too many arguments, it expects (a int) but got (untyped int, untyped int)", diags[1].Message)
+		assert.Equal(t, DiagnosticCodeMismatchedTypes, diags[2].Code)
+		assert.Equal(t, `values of types int and untyped string cannot be used together in n + "x"`, diags[2].Message)
+	})
+
+	t.Run("MessageOverrides", func(t *testing.T) {
+		s := newServer()
+		err := s.workspaceDidChangeConfiguration(&DidChangeConfigurationParams{
+			Settings: map[string]any{"diagnostics": map[string]any{"messages": map[string]any{
+				DiagnosticCodeTypeMismatch:     "{value} is not a {expected}",
+				DiagnosticCodeTooManyArguments: "off",
+			}}},
+		})
+		require.NoError(t, err)
+		diags := diagnosticsFor(t, s)
+		require.Len(t, diags, 3)
+		assert.Equal(t, DiagnosticCodeTypeMismatch, diags[0].Code)
+		assert.Equal(t, `"hi" is not a int`, diags[0].Message)
+		assert.Equal(t, DiagnosticCodeTypeError, diags[1].Code)
+		assert.Equal(t, "too many arguments in call to this.f\n\thave (untyped int, untyped int)\n\twant (a int)", diags[1].Message)
+		assert.Empty(t, diags[1].RelatedInformation)
+	})
+
+	t.Run("Unmatched", func(t *testing.T) {
+		config := &Config{}
+		_, _, ok := config.rewordTypeError("compileExprLHS failed: unknown - *ast.BasicLit")
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"cmp"
+	"path"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+	"github.com/goplus/goxlsw/internal/util"
+)
+
+// spxUnusedResource is an spx resource that is never referenced.
+type spxUnusedResource struct {
+	// id is the ID of the resource.
+	id SpxResourceID
+
+	// file is the asset file of the resource relative to the workspace root,
+	// e.g., the image of a costume.
+	file string
+}
+
+// spxUnusedResources returns the backdrops, sounds and sprite costumes in the
+// spx resource set that are never referenced from the code or other resource
+// metadata, sorted by their URIs.
+//
+// The default backdrop and the default costumes of sprites, the costumes of
+// animations and the sounds played by animations are referenced by the
+// metadata. All costumes of a sprite are referenced if they are cycled through
+// by nextCostume or prevCostume in its code, and so are all backdrops by
+// nextBackdrop or prevBackdrop. Resources selected by non-constant names
+// cannot be detected, so the results are only candidates for removal.
+func (r *compileResult) spxUnusedResources() []spxUnusedResource {
+	set := &r.spxResourceSet
+	referenced := make(map[SpxResourceID]bool)
+	for _, ref := range r.spxResourceRefs {
+		referenced[ref.ID] = true
+	}
+	if set.defaultBackdrop != "" {
+		referenced[SpxBackdropResourceID{BackdropName: set.defaultBackdrop}] = true
+	}
+	for _, sprite := range set.sprites {
+		if sprite.CostumeIndex >= 0 && sprite.CostumeIndex < len(sprite.Costumes) {
+			referenced[sprite.Costumes[sprite.CostumeIndex].ID] = true
+		}
+		for i, costume := range sprite.Costumes {
+			if slices.ContainsFunc(sprite.Animations, func(anim SpxSpriteAnimationResource) bool {
+				return anim.includeCostume(i)
+			}) {
+				referenced[costume.ID] = true
+			}
+		}
+		for _, fAnim := range sprite.FAnimations {
+			for _, action := range []*spxSpriteAnimationAction{fAnim.OnStart, fAnim.OnPlay} {
+				if action != nil && action.Play != "" {
+					referenced[SpxSoundResourceID{SoundName: action.Play}] = true
+				}
+			}
+		}
+	}
+
+	// The cycling functions may be used without calls, e.g., as the
+	// command-style statement `nextCostume`.
+	cyclesBackdrops := false
+	costumeCyclingSprites := make(map[*SpxSpriteResource]bool)
+	for ident, obj := range r.typeInfo.Uses {
+		if !isSpxPkgObject(obj) {
+			continue
+		}
+		switch funcName, _ := parseGopFuncName(obj.Name()); funcName {
+		case "nextBackdrop", "prevBackdrop":
+			cyclesBackdrops = true
+		case "nextCostume", "prevCostume":
+			var fun gopast.Expr = ident
+			path, _ := util.PathEnclosingInterval(r.nodeASTFile(ident), ident.Pos(), ident.End())
+			if len(path) > 1 {
+				if sel, ok := path[1].(*gopast.SelectorExpr); ok && sel.Sel == ident {
+					fun = sel
+				}
+			}
+			if sprite := r.spxSpriteResourceForCall(r.nodeFilename(ident), &gopast.CallExpr{Fun: fun}); sprite != nil {
+				costumeCyclingSprites[sprite] = true
+			}
+		}
+	}
+
+	var unused []spxUnusedResource
+	for _, backdrop := range set.backdrops {
+		if !cyclesBackdrops && !referenced[backdrop.ID] {
+			unused = append(unused, spxUnusedResource{
+				id:   backdrop.ID,
+				file: r.spxResourceFile(backdrop.Path),
+			})
+		}
+	}
+	for _, sound := range set.sounds {
+		if !referenced[sound.ID] {
+			unused = append(unused, spxUnusedResource{
+				id:   sound.ID,
+				file: r.spxResourceFile(path.Join("sounds", sound.Name, sound.Path)),
+			})
+		}
+	}
+	for _, sprite := range set.sprites {
+		if costumeCyclingSprites[sprite] {
+			continue
+		}
+		for _, costume := range sprite.Costumes {
+			if !referenced[costume.ID] {
+				unused = append(unused, spxUnusedResource{
+					id:   costume.ID,
+					file: r.spxResourceFile(path.Join("sprites", sprite.Name, costume.Path)),
+				})
+			}
+		}
+	}
+	slices.SortFunc(unused, func(a, b spxUnusedResource) int {
+		return cmp.Compare(a.id.URI(), b.id.URI())
+	})
+	return unused
+}
+
+// spxResourceFile returns the path relative to the workspace root of the
+// given asset file relative to the spx resource root directory. It returns an
+// empty string if the asset file is unknown.
+func (r *compileResult) spxResourceFile(file string) string {
+	if file == "" {
+		return ""
+	}
+	return path.Join(r.spxResourceRootDir, file)
+}
+
+// spxGetUnusedResources gets the backdrops, sounds and sprite costumes in the
+// workspace that are never referenced, so that they can be removed before
+// publishing the project.
+func (s *Server) spxGetUnusedResources() ([]SpxUnusedResource, error) {
+	result, err := s.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	unused := result.spxUnusedResources()
+	resources := make([]SpxUnusedResource, 0, len(unused))
+	for _, u := range unused {
+		resource := SpxUnusedResource{Resource: u.id.URI()}
+		if u.file != "" {
+			resource.File = s.toDocumentURI(u.file)
+		}
+		resources = append(resources, resource)
+	}
+	return resources, nil
+}
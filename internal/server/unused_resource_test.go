@@ -0,0 +1,79 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerSpxGetUnusedResources(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+	Meow     Sound
+)
+
+onStart => {
+	play Meow
+	startBackdrop "backdrop2"
+}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onClick => {
+	setCostume "costume2"
+}
+`),
+			"assets/index.json":                  []byte(`{"backdrops":[{"name":"backdrop1","path":"backdrop1.png"},{"name":"backdrop2","path":"backdrop2.png"},{"name":"backdrop3","path":"backdrop3.png"}],"zorder":[{"name":"MySprite"}]}`),
+			"assets/sounds/Meow/index.json":      []byte(`{"path":"meow.wav"}`),
+			"assets/sounds/Step/index.json":      []byte(`{"path":"step.wav"}`),
+			"assets/sounds/Roar/index.json":      []byte(`{"path":"roar.wav"}`),
+			"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1","path":"costume1.png"},{"name":"costume2","path":"costume2.png"},{"name":"costume3","path":"costume3.png"},{"name":"walk1","path":"walk1.png"},{"name":"walk2","path":"walk2.png"}],"costumeIndex":0,"fAnimations":{"walk":{"frameFrom":"walk1","frameTo":"walk2","onStart":{"play":"Step"}}}}`),
+		}), nil)
+
+		resources, err := s.spxGetUnusedResources()
+		require.NoError(t, err)
+		assert.Equal(t, []SpxUnusedResource{
+			{Resource: "spx://resources/backdrops/backdrop3", File: "file:///assets/backdrop3.png"},
+			{Resource: "spx://resources/sounds/Roar", File: "file:///assets/sounds/Roar/roar.wav"},
+			{Resource: "spx://resources/sprites/MySprite/costumes/costume3", File: "file:///assets/sprites/MySprite/costume3.png"},
+		}, resources)
+	})
+
+	t.Run("CyclingCostumesAndBackdrops", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+
+onClick => {
+	nextBackdrop
+}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onClick => {
+	nextCostume
+}
+`),
+			"assets/index.json":                  []byte(`{"backdrops":[{"name":"backdrop1","path":"backdrop1.png"},{"name":"backdrop2","path":"backdrop2.png"}],"zorder":[{"name":"MySprite"}]}`),
+			"assets/sprites/MySprite/index.json": []byte(`{"costumes":[{"name":"costume1","path":"costume1.png"},{"name":"costume2","path":"costume2.png"}]}`),
+		}), nil)
+
+		resources, err := s.spxGetUnusedResources()
+		require.NoError(t, err)
+		assert.Empty(t, resources)
+	})
+
+	t.Run("NoMainSpxFile", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+
+		resources, err := s.spxGetUnusedResources()
+		require.ErrorIs(t, err, errNoMainSpxFile)
+		assert.Nil(t, resources)
+	})
+}
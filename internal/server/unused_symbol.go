@@ -0,0 +1,100 @@
+package server
+
+import (
+	"fmt"
+	"go/types"
+	"maps"
+	"slices"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+)
+
+// unusedSymbolAnalyzer is an [analyzer] for unused variables and functions. It
+// requires the resource set to tell auto-binding variables.
+var unusedSymbolAnalyzer = &analyzer{
+	name:     "unusedSymbol",
+	requires: requireAST | requireTypeInfo | requireResourceSet,
+	run:      inspectForUnusedSymbols,
+}
+
+// inspectForUnusedSymbols inspects for top-level variables and functions in
+// spx files that are never used. Auto-binding variables are used by the spx
+// engine, and spx event handlers are called by it, so neither is reported.
+func inspectForUnusedSymbols(pass *analysisPass) {
+	result := pass.result
+	usedObjs := make(map[types.Object]struct{})
+	for _, obj := range result.typeInfo.Uses {
+		usedObjs[obj] = struct{}{}
+	}
+	isUsed := func(obj types.Object) bool {
+		_, ok := usedObjs[obj]
+		return ok
+	}
+
+	for _, spxFile := range slices.Sorted(maps.Keys(result.mainASTPkg.Files)) {
+		astFile := result.mainASTPkg.Files[spxFile]
+		for _, decl := range astFile.Decls {
+			switch decl := decl.(type) {
+			case *gopast.GenDecl:
+				if decl.Tok != goptoken.VAR {
+					continue
+				}
+				for _, spec := range decl.Specs {
+					valueSpec, ok := spec.(*gopast.ValueSpec)
+					if !ok {
+						continue
+					}
+					for _, name := range valueSpec.Names {
+						v, ok := result.typeInfo.Defs[name].(*types.Var)
+						if !ok || name.Name == "_" || isUsed(v) || result.isSpxAutoBindingVar(v) {
+							continue
+						}
+						pass.report(spxFile, result.unusedSymbolDiagnostic(name, len(valueSpec.Names) == 1, "variable %q is unused"))
+					}
+				}
+			case *gopast.FuncDecl:
+				if decl.Shadow || !decl.IsClass || decl.Name == nil {
+					continue
+				}
+				fun, ok := result.typeInfo.Defs[decl.Name].(*types.Func)
+				if !ok || isUsed(fun) || isSpxEventHandlerFuncName(fun.Name()) {
+					continue
+				}
+				pass.report(spxFile, result.unusedSymbolDiagnostic(decl.Name, true, "function %q is unused"))
+			}
+		}
+	}
+}
+
+// isSpxAutoBindingVar reports whether the given variable is an spx resource
+// auto-binding, or is declared like one for a sprite without resources.
+func (r *compileResult) isSpxAutoBindingVar(v *types.Var) bool {
+	if _, ok := r.spxSoundResourceAutoBindings[v]; ok {
+		return true
+	}
+	if _, ok := r.spxSpriteResourceAutoBindings[v]; ok {
+		return true
+	}
+	named, ok := v.Type().(*types.Named)
+	return ok && named.Obj().Name() == v.Name() && slices.Contains(r.mainPkgSpriteTypes, named)
+}
+
+// unusedSymbolDiagnostic returns the diagnostic for the unused symbol with the
+// given identifier. The quick fix removing its declaration is only available
+// if removable is true, i.e., the declaration declares nothing else.
+func (r *compileResult) unusedSymbolDiagnostic(ident *gopast.Ident, removable bool, format string) Diagnostic {
+	diag := Diagnostic{
+		Severity: SeverityWarning,
+		Code:     DiagnosticCodeUnusedSymbol,
+		Range:    r.rangeForNode(ident),
+		Message:  fmt.Sprintf(format, ident.Name),
+	}
+	if removable {
+		diag.Data = makeDiagnosticData(DiagnosticData{
+			Fix:  DiagnosticFixRemoveDeclaration,
+			Name: ident.Name,
+		})
+	}
+	return diag
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerInspectForUnusedSymbols(t *testing.T) {
+	newServer := func() *Server {
+		return New(newMapFSWithoutModTime(map[string][]byte{
+			"main.spx": []byte(`
+var (
+	MySprite MySprite
+	Sound1   Sound
+	score    int
+	lives    int
+	a, b     int
+)
+
+func reset() {
+	lives = 3
+}
+
+func unusedFunc() {}
+
+onStart => {
+	reset
+}
+run "assets", {Title: "My Game"}
+`),
+			"MySprite.spx": []byte(`
+onClick => {
+	echo score
+}
+`),
+			"assets/index.json":                  []byte(`{}`),
+			"assets/sounds/Sound1/index.json":    []byte(`{}`),
+			"assets/sprites/MySprite/index.json": []byte(`{}`),
+		}), nil)
+	}
+	diagnosticsFor := func(t *testing.T, s *Server, uri DocumentURI) []Diagnostic {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: uri},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		return fullReport.Items
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		s := newServer()
+		diags := diagnosticsFor(t, s, "file:///main.spx")
+		require.Len(t, diags, 3)
+		assert.Equal(t, Diagnostic{
+			Severity: SeverityWarning,
+			Code:     DiagnosticCodeUnusedSymbol,
+			Range: Range{
+				Start: Position{Line: 6, Character: 1},
+				End:   Position{Line: 6, Character: 2},
+			},
+			Message: `variable "a" is unused`,
+			Tags:    []DiagnosticTag{Unnecessary},
+		}, diags[0])
+		assert.Equal(t, `variable "b" is unused`, diags[1].Message)
+		assert.Nil(t, diags[1].Data)
+		assert.Equal(t, Diagnostic{
+			Severity: SeverityWarning,
+			Code:     DiagnosticCodeUnusedSymbol,
+			Range: Range{
+				Start: Position{Line: 13, Character: 5},
+				End:   Position{Line: 13, Character: 15},
+			},
+			Message: `function "unusedFunc" is unused`,
+			Tags:    []DiagnosticTag{Unnecessary},
+			Data: makeDiagnosticData(DiagnosticData{
+				Fix:  DiagnosticFixRemoveDeclaration,
+				Name: "unusedFunc",
+			}),
+		}, diags[2])
+
+		assert.Empty(t, diagnosticsFor(t, s, "file:///MySprite.spx"))
+	})
+
+	t.Run("DisabledAnalyzer", func(t *testing.T) {
+		s := newServer()
+		err := s.workspaceDidChangeConfiguration(&DidChangeConfigurationParams{
+			Settings: map[string]any{"analyzers": map[string]any{DiagnosticCodeUnusedSymbol: false}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, diagnosticsFor(t, s, "file:///main.spx"))
+	})
+}
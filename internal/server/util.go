@@ -1,15 +1,20 @@
 package server
 
 import (
+	"cmp"
+	"encoding/json"
 	"fmt"
 	"go/constant"
 	"go/types"
+	"hash/fnv"
+	"html"
 	"html/template"
 	"io/fs"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
-	"unicode/utf16"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/goplus/gogen"
@@ -17,8 +22,9 @@ import (
 	goptoken "github.com/goplus/gop/token"
 )
 
-// listSpxFiles returns a list of .spx files in the rootFS.
-func listSpxFiles(rootFS fs.ReadDirFS) ([]string, error) {
+// listClassfiles returns a list of the classfiles with the given extension,
+// e.g., ".spx", in the rootFS.
+func listClassfiles(rootFS fs.ReadDirFS, ext string) ([]string, error) {
 	entries, err := fs.ReadDir(rootFS, ".")
 	if err != nil {
 		return nil, err
@@ -28,7 +34,7 @@ func listSpxFiles(rootFS fs.ReadDirFS) ([]string, error) {
 		if entry.IsDir() {
 			continue
 		}
-		if !strings.HasSuffix(entry.Name(), ".spx") {
+		if !strings.HasSuffix(entry.Name(), ext) {
 			continue
 		}
 		files = append(files, entry.Name())
@@ -87,6 +93,157 @@ func deduplicateLocations(locations []Location) []Location {
 	return result
 }
 
+// sortLocations sorts locations by document URI and then by range start, so
+// results do not depend on map iteration order.
+func sortLocations(locations []Location) {
+	slices.SortFunc(locations, func(a, b Location) int {
+		if c := cmp.Compare(a.URI, b.URI); c != 0 {
+			return c
+		}
+		if c := cmp.Compare(a.Range.Start.Line, b.Range.Start.Line); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Range.Start.Character, b.Range.Start.Character)
+	})
+}
+
+// comparePositions compares two positions. It returns a negative number if a
+// is before b, zero if they are equal, and a positive number otherwise.
+func comparePositions(a, b Position) int {
+	if c := cmp.Compare(a.Line, b.Line); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Character, b.Character)
+}
+
+// isRangeOverlapping reports whether the two ranges overlap. Empty ranges are
+// considered overlapping with ranges that contain them.
+func isRangeOverlapping(a, b Range) bool {
+	return comparePositions(a.Start, b.End) <= 0 && comparePositions(b.Start, a.End) <= 0
+}
+
+// Scores of the bonuses used by [fuzzyMatch].
+const (
+	// fuzzyScoreMatch is the score of each matched character.
+	fuzzyScoreMatch = 1
+
+	// fuzzyScoreWordStart is the bonus of matching a character that starts a
+	// word, e.g., "T" in "onTouchStart".
+	fuzzyScoreWordStart = 3
+
+	// fuzzyScoreConsecutive is the bonus of matching a character right after
+	// the previously matched one.
+	fuzzyScoreConsecutive = 2
+
+	// fuzzyScorePrefix is the bonus of the pattern being a case-insensitive
+	// prefix of the candidate.
+	fuzzyScorePrefix = 4
+)
+
+// fuzzyMatch reports whether the given pattern matches the given candidate as
+// a case-insensitive subsequence, and returns the score of the best match.
+// Matches at word starts (e.g., camel case humps) and consecutive matches are
+// scored higher, so that "tch" matches "onTouchStart" better than "catch".
+func fuzzyMatch(pattern, candidate string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+	p := []rune(pattern)
+	c := []rune(candidate)
+	if len(p) > len(c) {
+		return 0, false
+	}
+	for i := range p {
+		p[i] = unicode.ToLower(p[i])
+	}
+
+	// prev[i] is the best score of matching the pattern so far with its last
+	// character matched at c[i], or -1 if there is no such match.
+	prev := make([]int, len(c))
+	curr := make([]int, len(c))
+	for j := range p {
+		best := -1 // Best score of prev[:i-1].
+		for i := range c {
+			curr[i] = -1
+			if j > 0 && i >= 2 {
+				best = max(best, prev[i-2])
+			}
+			if unicode.ToLower(c[i]) != p[j] {
+				continue
+			}
+			score := fuzzyScoreMatch
+			if isFuzzyWordStart(c, i) {
+				score += fuzzyScoreWordStart
+			}
+			if j == 0 {
+				curr[i] = score
+				continue
+			}
+			if i == 0 {
+				continue
+			}
+			if prev[i-1] >= 0 {
+				curr[i] = prev[i-1] + score + fuzzyScoreConsecutive
+			}
+			if best >= 0 {
+				curr[i] = max(curr[i], best+score)
+			}
+		}
+		prev, curr = curr, prev
+	}
+
+	score := -1
+	for _, s := range prev {
+		score = max(score, s)
+	}
+	if score < 0 {
+		return 0, false
+	}
+	if hasFoldPrefix(candidate, pattern) {
+		score += fuzzyScorePrefix
+	}
+	return score, true
+}
+
+// isFuzzyWordStart reports whether c[i] starts a word in c.
+func isFuzzyWordStart(c []rune, i int) bool {
+	if i == 0 {
+		return true
+	}
+	prev, curr := c[i-1], c[i]
+	switch {
+	case !isIdentRune(prev):
+		return isIdentRune(curr)
+	case unicode.IsLower(prev) || unicode.IsDigit(prev):
+		return unicode.IsUpper(curr)
+	case prev == '_':
+		return curr != '_'
+	}
+	return false
+}
+
+// isIdentRune reports whether r can be part of an identifier.
+func isIdentRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// hasFoldPrefix reports whether s begins with prefix under Unicode
+// case-folding.
+func hasFoldPrefix(s, prefix string) bool {
+	for prefix != "" {
+		if s == "" {
+			return false
+		}
+		r1, n1 := utf8.DecodeRuneInString(s)
+		r2, n2 := utf8.DecodeRuneInString(prefix)
+		if unicode.ToLower(r1) != unicode.ToLower(r2) {
+			return false
+		}
+		s, prefix = s[n1:], prefix[n2:]
+	}
+	return true
+}
+
 // toLowerCamelCase converts the first character of a Go identifier to lowercase.
 func toLowerCamelCase(s string) string {
 	if s == "" {
@@ -327,36 +484,140 @@ func attr(value string) string {
 	return fmt.Sprintf(`"%s"`, template.HTMLEscapeString(value))
 }
 
-// utf16OffsetToUTF8 converts a UTF-16 offset to a UTF-8 offset in the given string.
-func utf16OffsetToUTF8(s string, utf16Offset int) int {
-	if utf16Offset <= 0 {
-		return 0
-	}
+// htmlTagRE is the regular expression of HTML tags in markdown contents.
+var htmlTagRE = regexp.MustCompile(`</?[a-zA-Z][^<>]*>`)
 
-	var utf16Units, utf8Bytes int
-	for _, r := range s {
-		if utf16Units >= utf16Offset {
-			break
+// markdownToPlainText converts the given markdown content, which may contain
+// HTML elements, code fences, code spans and strong emphasis, to plain text for
+// clients without markdown support.
+func markdownToPlainText(markdown string) string {
+	var sb strings.Builder
+	for _, line := range strings.Split(htmlTagRE.ReplaceAllString(markdown, ""), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			continue
 		}
-		utf16Units += utf16.RuneLen(r)
-		utf8Bytes += utf8.RuneLen(r)
+		line = strings.NewReplacer("**", "", "`", "").Replace(line)
+		sb.WriteString(strings.TrimRight(line, " \t"))
+		sb.WriteByte('\n')
+	}
+	text := html.UnescapeString(sb.String())
+	for strings.Contains(text, "\n\n\n") {
+		text = strings.ReplaceAll(text, "\n\n\n", "\n\n")
+	}
+	return strings.TrimSpace(text)
+}
+
+// markupContentFor returns the given markdown value as a [MarkupContent] of
+// markdown kind, or plain text kind if markdown is false.
+func markupContentFor(value string, markdown bool) MarkupContent {
+	if markdown {
+		return MarkupContent{Kind: Markdown, Value: value}
+	}
+	return MarkupContent{Kind: PlainText, Value: markdownToPlainText(value)}
+}
+
+// supportsMarkdown reports whether markdown is among the given content formats
+// supported by the client. An empty list means the client has no preference.
+func supportsMarkdown(formats []MarkupKind) bool {
+	return len(formats) == 0 || slices.Contains(formats, Markdown)
+}
+
+// applyTextEditsToContent applies the given non-overlapping text edits, whose
+// positions are in the given position encoding, to the given content and
+// returns the result.
+func applyTextEditsToContent(content []byte, edits []TextEdit, encoding PositionEncodingKind) []byte {
+	if len(edits) == 0 {
+		return content
+	}
+	text := string(content)
+	sortedEdits := slices.Clone(edits)
+	slices.SortStableFunc(sortedEdits, func(a, b TextEdit) int {
+		return comparePositions(b.Range.Start, a.Range.Start)
+	})
+	for _, edit := range sortedEdits {
+		start := positionOffset(text, edit.Range.Start, encoding)
+		end := max(positionOffset(text, edit.Range.End, encoding), start)
+		text = text[:start] + edit.NewText + text[end:]
 	}
-	return utf8Bytes
+	return []byte(text)
 }
 
-// utf8OffsetToUTF16 converts a UTF-8 offset to a UTF-16 offset in the given string.
-func utf8OffsetToUTF16(s string, utf8Offset int) int {
-	if utf8Offset <= 0 {
-		return 0
+// contentResultID returns a result ID derived from the JSON encoding of v, so
+// that equal contents always have the same result ID. It returns an empty
+// string if v cannot be encoded.
+func contentResultID(v any) string {
+	h := fnv.New64a()
+	if err := json.NewEncoder(h).Encode(v); err != nil {
+		return ""
 	}
+	return strconv.FormatUint(h.Sum64(), 16)
+}
 
-	var utf8Bytes, utf16Units int
-	for _, r := range s {
-		if utf8Bytes >= utf8Offset {
-			break
+// editDistance returns the Levenshtein distance between the given strings in
+// runes, with transpositions of adjacent runes counted as single edits, so that
+// common typos like "bui" for "biu" are close.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prevPrev := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prevPrev[j-2]+1)
+			}
+		}
+		prevPrev, prev, curr = prev, curr, prevPrev
+	}
+	return prev[len(rb)]
+}
+
+// spellingSuggestionFor returns the candidate nearest to the given misspelled
+// name by case-insensitive [editDistance]. Candidates farther than half the
+// length of the name are not suggested, and ties are broken by the
+// case-sensitive distance, the difference in length and then the order of the
+// candidates. It returns false if there is
+// no such candidate.
+func spellingSuggestionFor(name string, candidates []string) (string, bool) {
+	nameLen := utf8.RuneCountInString(name)
+	maxDistance := nameLen / 2
+	lowerName := strings.ToLower(name)
+
+	var (
+		suggestion        string
+		suggestionDist    int
+		suggestionRawDist int
+		suggestionLenDiff int
+	)
+	for _, candidate := range candidates {
+		if candidate == name {
+			continue
+		}
+		dist := editDistance(lowerName, strings.ToLower(candidate))
+		if dist > maxDistance {
+			continue
+		}
+		rawDist := editDistance(name, candidate)
+		lenDiff := utf8.RuneCountInString(candidate) - nameLen
+		if lenDiff < 0 {
+			lenDiff = -lenDiff
+		}
+		if suggestion == "" || cmp.Or(
+			cmp.Compare(dist, suggestionDist),
+			cmp.Compare(rawDist, suggestionRawDist),
+			cmp.Compare(lenDiff, suggestionLenDiff),
+		) < 0 {
+			suggestion, suggestionDist, suggestionRawDist, suggestionLenDiff = candidate, dist, rawDist, lenDiff
 		}
-		utf8Bytes += utf8.RuneLen(r)
-		utf16Units += utf16.RuneLen(r)
 	}
-	return utf16Units
+	return suggestion, suggestion != ""
 }
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// watchedFilesRegistrationID is the ID of the dynamic registration of
+// workspace/didChangeWatchedFiles.
+const watchedFilesRegistrationID = "goxlsw/watchedFiles"
+
+// watchedFilesRegistrationTimeout is the timeout of registering for
+// workspace/didChangeWatchedFiles.
+const watchedFilesRegistrationTimeout = 5 * time.Second
+
+// watchedFileGlobPatterns are glob patterns of files whose changes made outside
+// the editor affect the analysis, e.g., resource metadata updated by the asset
+// panel, or module files updated by the go command.
+var watchedFileGlobPatterns = []string{
+	"**/*.spx",
+	"**/*.json",
+	"**/go.mod",
+	"**/gop.mod",
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#workspace_didChangeWatchedFiles
+func (s *Server) workspaceDidChangeWatchedFiles(params *DidChangeWatchedFilesParams) error {
+	// Make the changes visible if the workspace files are cached, e.g., by
+	// the local file system of vfs.NewLocalMapFS.
+	s.workspaceRootFS.Invalidate()
+
+	// The compile caches detect changes to their inputs by themselves, so
+	// they are kept for the next compilations to reuse what is unchanged.
+	// See [compileCache].
+	changed := false
+	for _, change := range params.Changes {
+		_, relPath, err := s.workspaceFolderFor(change.URI)
+		if err != nil {
+			continue // Not in any workspace folder.
+		}
+
+		// Modifications to classfiles are usually made in the editor,
+		// which refreshes diagnostics by itself.
+		if s.isClassfile(relPath) && change.Type == Changed {
+			continue
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+
+	// Resource reference diagnostics may be affected by the changes.
+	s.refreshDiagnostics()
+	return nil
+}
+
+// registerWatchedFiles asks the client to send workspace/didChangeWatchedFiles
+// for [watchedFileGlobPatterns] and the classfiles of other classfile kinds if
+// the client supports registering it dynamically.
+func (s *Server) registerWatchedFiles() {
+	caps := s.clientCapabilities.Load()
+	if caps == nil || !caps.Workspace.DidChangeWatchedFiles.DynamicRegistration {
+		return
+	}
+	patterns := slices.Clone(watchedFileGlobPatterns)
+	for _, kind := range s.extraClassfileKinds {
+		patterns = append(patterns, "**/*"+kind.Ext)
+	}
+	watchers := make([]FileSystemWatcher, 0, len(patterns))
+	for _, pattern := range patterns {
+		watchers = append(watchers, FileSystemWatcher{
+			GlobPattern: GlobPattern{Value: pattern},
+		})
+	}
+	params := RegistrationParams{
+		Registrations: []Registration{{
+			ID:              watchedFilesRegistrationID,
+			Method:          "workspace/didChangeWatchedFiles",
+			RegisterOptions: DidChangeWatchedFilesRegistrationOptions{Watchers: watchers},
+		}},
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), watchedFilesRegistrationTimeout)
+		defer cancel()
+		s.call(ctx, "client/registerCapability", params, nil)
+	}()
+}
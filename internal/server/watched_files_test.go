@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/jsonrpc2"
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerWorkspaceDidChangeWatchedFiles(t *testing.T) {
+	newFiles := func() map[string][]byte {
+		return map[string][]byte{
+			"main.spx": []byte(`
+onStart => {
+	play "biu"
+}
+
+run "assets", {Title: "My Game"}
+`),
+			"assets/index.json": []byte(`{}`),
+		}
+	}
+	diagnosticMessagesFor := func(t *testing.T, s *Server) []string {
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///main.spx"},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		var messages []string
+		for _, diagnostic := range fullReport.Items {
+			messages = append(messages, diagnostic.Message)
+		}
+		return messages
+	}
+
+	t.Run("Normal", func(t *testing.T) {
+		files := newFiles()
+		s := New(newMapFSWithoutModTime(files), nil)
+		assert.Equal(t, []string{`sound resource "biu" not found`}, diagnosticMessagesFor(t, s))
+
+		files["assets/sounds/biu/index.json"] = []byte(`{"path":"biu.wav"}`)
+		err := s.workspaceDidChangeWatchedFiles(&DidChangeWatchedFilesParams{
+			Changes: []FileEvent{{URI: "file:///assets/sounds/biu/index.json", Type: Created}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, diagnosticMessagesFor(t, s))
+	})
+
+	t.Run("CompileCacheKept", func(t *testing.T) {
+		files := newFiles()
+		files["MySprite.spx"] = []byte(`onStart => {}`)
+		files["assets/sprites/MySprite/index.json"] = []byte(`{"costumes":[{"name":"c1","path":"c1.png"}],"costume":"c1"}`)
+		s := New(newMapFSWithoutModTime(files), nil)
+		result, err := s.compile()
+		require.NoError(t, err)
+
+		// Changes to files that are not compile inputs keep the cached
+		// result as a whole.
+		files["assets/sprites/MySprite/c1.png"] = []byte(`png`)
+		err = s.workspaceDidChangeWatchedFiles(&DidChangeWatchedFilesParams{
+			Changes: []FileEvent{{URI: "file:///assets/sprites/MySprite/c1.png", Type: Created}},
+		})
+		require.NoError(t, err)
+		cached, err := s.compile()
+		require.NoError(t, err)
+		assert.Same(t, result, cached)
+
+		// Changes to compile inputs recompile the project, reusing what is
+		// unchanged from the cached result.
+		files["assets/sounds/biu/index.json"] = []byte(`{"path":"biu.wav"}`)
+		err = s.workspaceDidChangeWatchedFiles(&DidChangeWatchedFilesParams{
+			Changes: []FileEvent{{URI: "file:///assets/sounds/biu/index.json", Type: Created}},
+		})
+		require.NoError(t, err)
+		recompiled, err := s.compile()
+		require.NoError(t, err)
+		assert.NotSame(t, result, recompiled)
+		assert.NotNil(t, recompiled.spxResourceSet.Sound("biu"))
+		prevViolations := result.spxResourceMetadataValidations["sprites/MySprite/index.json"].violations
+		violations := recompiled.spxResourceMetadataValidations["sprites/MySprite/index.json"].violations
+		require.Len(t, violations, 1)
+		assert.Same(t, &prevViolations[0], &violations[0])
+	})
+
+	t.Run("LocalFS", func(t *testing.T) {
+		dir := t.TempDir()
+		for name, content := range newFiles() {
+			p := filepath.Join(dir, filepath.FromSlash(name))
+			require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+			require.NoError(t, os.WriteFile(p, content, 0o644))
+		}
+		s := New(vfs.NewLocalMapFS(dir), nil)
+		assert.Equal(t, []string{`sound resource "biu" not found`}, diagnosticMessagesFor(t, s))
+
+		// The cached listing of the local file system is invalidated.
+		p := filepath.Join(dir, "assets", "sounds", "biu", "index.json")
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		require.NoError(t, os.WriteFile(p, []byte(`{"path":"biu.wav"}`), 0o644))
+		err := s.workspaceDidChangeWatchedFiles(&DidChangeWatchedFilesParams{
+			Changes: []FileEvent{{URI: "file:///assets/sounds/biu/index.json", Type: Created}},
+		})
+		require.NoError(t, err)
+		assert.Empty(t, diagnosticMessagesFor(t, s))
+	})
+
+	t.Run("SpxFileChanged", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newFiles()), nil)
+		_, err := s.compile()
+		require.NoError(t, err)
+
+		err = s.workspaceDidChangeWatchedFiles(&DidChangeWatchedFilesParams{
+			Changes: []FileEvent{{URI: "file:///main.spx", Type: Changed}},
+		})
+		require.NoError(t, err)
+		assert.NotNil(t, s.defaultWorkspaceFolder().lastCompileCache)
+	})
+
+	t.Run("OutsideWorkspaceFolders", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(newFiles()), nil)
+		require.NoError(t, s.setWorkspaceFolders([]WorkspaceFolder{{URI: "file:///projA"}}))
+
+		err := s.workspaceDidChangeWatchedFiles(&DidChangeWatchedFilesParams{
+			Changes: []FileEvent{{URI: "file:///projB/assets/index.json", Type: Changed}},
+		})
+		require.NoError(t, err)
+	})
+}
+
+func TestServerRegisterWatchedFiles(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		calls := make(chan *jsonrpc2.Call, 1)
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), messageReplierFunc(func(m jsonrpc2.Message) error {
+			if call, ok := m.(*jsonrpc2.Call); ok {
+				calls <- call
+			}
+			return nil
+		}))
+		s.clientCapabilities.Store(&ClientCapabilities{
+			Workspace: WorkspaceClientCapabilities{
+				DidChangeWatchedFiles: DidChangeWatchedFilesClientCapabilities{DynamicRegistration: true},
+			},
+		})
+
+		n, err := jsonrpc2.NewNotification("initialized", InitializedParams{})
+		require.NoError(t, err)
+		require.NoError(t, s.HandleMessage(n))
+
+		var call *jsonrpc2.Call
+		select {
+		case call = <-calls:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for client/registerCapability")
+		}
+		assert.Equal(t, "client/registerCapability", call.Method())
+		var params RegistrationParams
+		require.NoError(t, json.Unmarshal(call.Params(), &params))
+		require.Len(t, params.Registrations, 1)
+		assert.Equal(t, watchedFilesRegistrationID, params.Registrations[0].ID)
+		assert.Equal(t, "workspace/didChangeWatchedFiles", params.Registrations[0].Method)
+	})
+
+	t.Run("DynamicRegistrationNotSupported", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), messageReplierFunc(func(m jsonrpc2.Message) error {
+			t.Errorf("unexpected message: %v", m)
+			return nil
+		}))
+		s.clientCapabilities.Store(&ClientCapabilities{})
+		s.registerWatchedFiles()
+	})
+}
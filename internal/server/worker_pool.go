@@ -0,0 +1,57 @@
+package server
+
+import (
+	"runtime"
+	"sync"
+)
+
+// defaultAnalysisWorkers returns the default maximum number of concurrent
+// workers of an analysis, which is GOMAXPROCS, or 1 in WebAssembly, where
+// goroutines run on a single thread and only add scheduling overhead.
+func defaultAnalysisWorkers() int {
+	if runtime.GOARCH == "wasm" {
+		return 1
+	}
+	return runtime.GOMAXPROCS(0)
+}
+
+// WithAnalysisWorkers sets the maximum number of concurrent workers analyzing
+// a project, e.g., parsing its spx files and running analyzers. It defaults to
+// GOMAXPROCS, or 1 in WebAssembly, if it is not positive.
+func WithAnalysisWorkers(workers int) Option {
+	return func(s *Server) {
+		if workers <= 0 {
+			workers = defaultAnalysisWorkers()
+		}
+		s.analysisWorkers = workers
+	}
+}
+
+// forEachConcurrently calls fn for each index in [0, n) with at most the given
+// number of concurrent workers, and returns once all calls have returned. The
+// calls are made in the current goroutine if there is only one worker.
+func forEachConcurrently(workers, n int, fn func(i int)) {
+	if workers <= 1 || n <= 1 {
+		for i := range n {
+			fn(i)
+		}
+		return
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				fn(i)
+			}
+		}()
+	}
+	for i := range n {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
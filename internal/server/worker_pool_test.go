@@ -0,0 +1,153 @@
+package server
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForEachConcurrently(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		var (
+			calls       [10]atomic.Int32
+			running     atomic.Int32
+			maxRunning  atomic.Int32
+			workerCount = 3
+		)
+		forEachConcurrently(workerCount, len(calls), func(i int) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			calls[i].Add(1)
+		})
+		for i := range calls {
+			assert.Equal(t, int32(1), calls[i].Load(), "index %d", i)
+		}
+		assert.LessOrEqual(t, maxRunning.Load(), int32(workerCount))
+	})
+
+	t.Run("SingleWorker", func(t *testing.T) {
+		var order []int
+		forEachConcurrently(1, 5, func(i int) {
+			order = append(order, i)
+		})
+		assert.Equal(t, []int{0, 1, 2, 3, 4}, order)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		forEachConcurrently(4, 0, func(i int) {
+			t.Errorf("unexpected call with index %d", i)
+		})
+	})
+}
+
+func TestWithAnalysisWorkers(t *testing.T) {
+	s := New(newMapFSWithoutModTime(map[string][]byte{}), nil, WithAnalysisWorkers(2))
+	assert.Equal(t, 2, s.analysisWorkers)
+
+	s = New(newMapFSWithoutModTime(map[string][]byte{}), nil, WithAnalysisWorkers(0))
+	assert.Equal(t, defaultAnalysisWorkers(), s.analysisWorkers)
+}
+
+func TestNewSpxResourceSetWorkers(t *testing.T) {
+	files := map[string][]byte{
+		"assets/index.json": []byte(`{"zorder":["Sprite0"]}`),
+	}
+	for i := range 10 {
+		files[fmt.Sprintf("assets/sounds/Sound%d/index.json", i)] = []byte(fmt.Sprintf(`{"path":"sound%d.wav"}`, i))
+		files[fmt.Sprintf("assets/sprites/Sprite%d/index.json", i)] = []byte(`{"costumes":[{"name":"c0","path":"c0.png"},{"name":"c1","path":"c1.png"}],"fAnimations":{"walk":{"frameFrom":"c0","frameTo":"c1"}}}`)
+	}
+	rootFS := newMapFSWithoutModTime(files).Sub("assets")
+
+	t.Run("Normal", func(t *testing.T) {
+		want, err := newSpxResourceSet(rootFS, 1, nil, nil)
+		require.NoError(t, err)
+		got, err := newSpxResourceSet(rootFS, 4, nil, nil)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+		assert.Len(t, got.sounds, 10)
+		assert.Len(t, got.sprites, 10)
+	})
+
+	t.Run("MalformedFiles", func(t *testing.T) {
+		malformed := newMapFSWithoutModTime(files).WithOverlay(map[string]vfs.MapFile{
+			"assets/sprites/Sprite3/index.json": {Content: []byte(`{`)},
+			"assets/sprites/Sprite7/index.json": {Content: []byte(`{`)},
+		}).Sub("assets")
+		_, err := newSpxResourceSet(malformed, 4, nil, nil)
+		var metadataErr *SpxResourceMetadataError
+		require.ErrorAs(t, err, &metadataErr)
+		assert.Equal(t, "sprites/Sprite3/index.json", metadataErr.Path)
+	})
+}
+
+// newBenchmarkSpxProject returns the files of a well-formed project of the
+// given number of sprites, each with 20 costumes and an animation.
+func newBenchmarkSpxProject(sprites int) map[string][]byte {
+	files := map[string][]byte{
+		"assets/index.json": []byte(`{}`),
+	}
+	spriteMetadata := `{"costumeIndex":0,"costumes":[`
+	for i := range 20 {
+		if i > 0 {
+			spriteMetadata += ","
+		}
+		spriteMetadata += fmt.Sprintf(`{"name":"c%d","path":"c%d.svg","x":10,"y":20,"bitmapResolution":2}`, i, i)
+	}
+	spriteMetadata += `],"fAnimations":{"walk":{"frameFrom":"c0","frameTo":"c9"}},"defaultAnimation":"walk"}`
+	mainSpx := "var (\n"
+	for i := range sprites {
+		name := fmt.Sprintf("Sprite%d", i)
+		mainSpx += fmt.Sprintf("\t%s %s\n", name, name)
+		files[name+".spx"] = []byte(fmt.Sprintf(`
+var count int
+
+onStart => {
+	for i := 1; i <= 10; i++ {
+		count += i
+		step 10
+		turn 15
+		if touching(Edge) {
+			broadcast "edge %d"
+		}
+	}
+}
+
+onMsg "edge %d", => {
+	say "bounced", 1
+}
+`, i, (i+1)%sprites))
+		files[fmt.Sprintf("assets/sprites/%s/index.json", name)] = []byte(spriteMetadata)
+	}
+	mainSpx += ")\nrun \"assets\", {Title: \"My Game\"}\n"
+	files["main.spx"] = []byte(mainSpx)
+	return files
+}
+
+// BenchmarkCompileWorkers benchmarks compiling a project of 50 sprites with
+// different numbers of analysis workers.
+func BenchmarkCompileWorkers(b *testing.B) {
+	files := newBenchmarkSpxProject(50)
+	for _, workers := range []int{1, 4} {
+		b.Run(fmt.Sprintf("Workers%d", workers), func(b *testing.B) {
+			s := New(newMapFSWithoutModTime(files), nil, WithAnalysisWorkers(workers))
+			folder := s.defaultWorkspaceFolder()
+			for range b.N {
+				if _, err := s.compileAt(folder, s.workspaceFolderSnapshot(folder)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// workspaceFoldersRegistrationID is the ID under which the client registers
+// workspace/didChangeWorkspaceFolders.
+const workspaceFoldersRegistrationID = "goxlsw/workspaceFolders"
+
+// workspaceFolder is a folder in the workspace that hosts an spx project. Each
+// workspace folder has its own compile state.
+type workspaceFolder struct {
+	// uri is the URI of the workspace folder, which always ends with "/".
+	uri DocumentURI
+
+	// name is the name of the workspace folder.
+	name string
+
+	// dir is the directory of the workspace folder relative to the
+	// workspace root, or empty for the workspace root itself.
+	dir string
+
+	// ctx is done once the workspace folder is removed from the workspace,
+	// which abandons its in-flight analysis.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	lastCompileCache   *compileCache
+	lastCompileCacheMu sync.Mutex
+
+	// lastPromptedMetadataFile is the malformed metadata file of spx
+	// resources the user was last prompted about. It is guarded by
+	// lastCompileCacheMu.
+	lastPromptedMetadataFile string
+
+	// diagnosticsTimer is the scheduled run of the background diagnostics,
+	// and cancelDiagnostics cancels the in-flight one. diagnosticsGeneration
+	// tells whether a run has been superseded, and publishedDiagnostics are the
+	// result IDs of the diagnostics last published for each document. They are
+	// all guarded by diagnosticsMu.
+	diagnosticsTimer      *time.Timer
+	cancelDiagnostics     context.CancelFunc
+	diagnosticsGeneration uint64
+	publishedDiagnostics  map[DocumentURI]string
+	diagnosticsMu         sync.Mutex
+}
+
+// newWorkspaceFolder creates a new workspace folder for the given
+// [WorkspaceFolder], which must be located in the workspace root.
+func newWorkspaceFolder(rootURI DocumentURI, folder WorkspaceFolder) (*workspaceFolder, error) {
+	uri := workspaceFolderURI(folder)
+	dir, ok := strings.CutPrefix(string(uri), string(rootURI))
+	if !ok {
+		return nil, fmt.Errorf("workspace folder URI %q does not have workspace root URI %q as prefix", folder.URI, rootURI)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &workspaceFolder{
+		uri:    uri,
+		name:   folder.Name,
+		dir:    strings.TrimSuffix(dir, "/"),
+		ctx:    ctx,
+		cancel: cancel,
+	}, nil
+}
+
+// workspaceFolderURI returns the normalized URI of the given [WorkspaceFolder],
+// which always ends with "/".
+func workspaceFolderURI(folder WorkspaceFolder) DocumentURI {
+	uri := string(folder.URI)
+	if !strings.HasSuffix(uri, "/") {
+		uri += "/"
+	}
+	return DocumentURI(uri)
+}
+
+// fromDocumentURI returns the path relative to the workspace folder from a
+// [DocumentURI].
+func (f *workspaceFolder) fromDocumentURI(documentURI DocumentURI) (string, error) {
+	relPath, ok := strings.CutPrefix(string(documentURI), string(f.uri))
+	if !ok {
+		return "", fmt.Errorf("document URI %q does not have workspace folder URI %q as prefix", documentURI, f.uri)
+	}
+	return relPath, nil
+}
+
+// toDocumentURI returns the [DocumentURI] for a path relative to the workspace
+// folder.
+func (f *workspaceFolder) toDocumentURI(path string) DocumentURI {
+	return DocumentURI(string(f.uri) + path)
+}
+
+// resetCompileCache drops the cached compile result of the workspace folder.
+func (f *workspaceFolder) resetCompileCache() {
+	f.lastCompileCacheMu.Lock()
+	f.lastCompileCache = nil
+	f.lastCompileCacheMu.Unlock()
+}
+
+// unload abandons the in-flight analysis of the workspace folder and drops its
+// compile state.
+func (f *workspaceFolder) unload() {
+	f.cancel()
+	f.stopDiagnostics()
+	f.resetCompileCache()
+}
+
+// setWorkspaceFolders replaces the workspace folders of the server. Existing
+// workspace folders that are still present keep their compile state, while
+// others are unloaded.
+func (s *Server) setWorkspaceFolders(folders []WorkspaceFolder) error {
+	s.workspaceFoldersMu.Lock()
+	defer s.workspaceFoldersMu.Unlock()
+	return s.setWorkspaceFoldersLocked(folders)
+}
+
+// setWorkspaceFoldersLocked is like [Server.setWorkspaceFolders] but must be
+// called with s.workspaceFoldersMu held.
+func (s *Server) setWorkspaceFoldersLocked(folders []WorkspaceFolder) error {
+	existing := make(map[DocumentURI]*workspaceFolder, len(s.workspaceFolders))
+	for _, folder := range s.workspaceFolders {
+		existing[folder.uri] = folder
+	}
+	workspaceFolders := make([]*workspaceFolder, 0, len(folders))
+	for _, folder := range folders {
+		uri := workspaceFolderURI(folder)
+		if slices.ContainsFunc(workspaceFolders, func(f *workspaceFolder) bool { return f.uri == uri }) {
+			continue
+		}
+		if workspaceFolder, ok := existing[uri]; ok {
+			workspaceFolders = append(workspaceFolders, workspaceFolder)
+			delete(existing, uri)
+			continue
+		}
+		workspaceFolder, err := newWorkspaceFolder(s.workspaceRootURI, folder)
+		if err != nil {
+			return err
+		}
+		workspaceFolders = append(workspaceFolders, workspaceFolder)
+	}
+	for _, folder := range existing {
+		folder.unload()
+	}
+	s.workspaceFolders = workspaceFolders
+	return nil
+}
+
+// See https://microsoft.github.io/language-server-protocol/specifications/lsp/3.18/specification/#workspace_didChangeWorkspaceFolders
+func (s *Server) workspaceDidChangeWorkspaceFolders(params *DidChangeWorkspaceFoldersParams) error {
+	s.workspaceFoldersMu.Lock()
+	folders := make([]WorkspaceFolder, 0, len(s.workspaceFolders)+len(params.Event.Added))
+	for _, folder := range s.workspaceFolders {
+		removed := slices.ContainsFunc(params.Event.Removed, func(removed WorkspaceFolder) bool {
+			return workspaceFolderURI(removed) == folder.uri
+		})
+		if !removed {
+			folders = append(folders, WorkspaceFolder{URI: URI(folder.uri), Name: folder.name})
+		}
+	}
+	folders = append(folders, params.Event.Added...)
+	err := s.setWorkspaceFoldersLocked(folders)
+	s.workspaceFoldersMu.Unlock()
+	if err != nil {
+		return fmt.Errorf("invalid workspace folders: %w", err)
+	}
+
+	// Diagnostics of removed workspace folders are no longer reported.
+	s.refreshDiagnostics()
+	return nil
+}
+
+// getWorkspaceFolders returns the workspace folders of the server. The
+// workspace root is served as the only workspace folder if there are no
+// workspace folders.
+func (s *Server) getWorkspaceFolders() []*workspaceFolder {
+	s.workspaceFoldersMu.Lock()
+	defer s.workspaceFoldersMu.Unlock()
+	if len(s.workspaceFolders) == 0 {
+		return []*workspaceFolder{s.workspaceRootFolder}
+	}
+	return s.workspaceFolders
+}
+
+// defaultWorkspaceFolder returns the workspace folder used by requests that are
+// not scoped to a document, which is the first one.
+func (s *Server) defaultWorkspaceFolder() *workspaceFolder {
+	return s.getWorkspaceFolders()[0]
+}
+
+// workspaceFolderFor returns the innermost workspace folder containing the
+// given [DocumentURI], along with the path relative to it.
+func (s *Server) workspaceFolderFor(documentURI DocumentURI) (*workspaceFolder, string, error) {
+	var (
+		found   *workspaceFolder
+		relPath string
+	)
+	for _, folder := range s.getWorkspaceFolders() {
+		if found != nil && len(folder.uri) <= len(found.uri) {
+			continue
+		}
+		if p, err := folder.fromDocumentURI(documentURI); err == nil {
+			found, relPath = folder, p
+		}
+	}
+	if found == nil {
+		return nil, "", fmt.Errorf("document URI %q is not in any workspace folder", documentURI)
+	}
+	return found, relPath, nil
+}
+
+// workspaceFolderSnapshot returns a snapshot of the file system of the given
+// workspace folder with the contents of open documents overlaid on top of it.
+func (s *Server) workspaceFolderSnapshot(folder *workspaceFolder) *vfs.MapFS {
+	return s.snapshot().Sub(folder.dir)
+}
@@ -0,0 +1,267 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMultiRootTestServer(t *testing.T) *Server {
+	s := New(newMapFSWithoutModTime(map[string][]byte{
+		"projA/main.spx": []byte(`
+var (
+	MySprite MySprite
+)
+
+func sayHi() {}
+
+onStart => {
+	sayHi
+}
+
+run "assets", {Title: "Project A"}
+`),
+		"projA/MySprite.spx": []byte(`
+func greet() {}
+`),
+		"projA/assets/index.json":                  []byte(`{}`),
+		"projA/assets/sprites/MySprite/index.json": []byte(`{}`),
+		"projB/main.spx": []byte(`
+import "fmt"
+
+func greet() {}
+
+onStart => {
+	greet
+}
+
+run "assets", {Title: "Project B"}
+`),
+		"projB/assets/index.json": []byte(`{}`),
+	}), nil)
+	require.NoError(t, s.setWorkspaceFolders([]WorkspaceFolder{
+		{URI: "file:///projA", Name: "projA"},
+		{URI: "file:///projB/", Name: "projB"},
+	}))
+	return s
+}
+
+func TestNewWorkspaceFolder(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		folder, err := newWorkspaceFolder("file:///", WorkspaceFolder{URI: "file:///foo/bar", Name: "bar"})
+		require.NoError(t, err)
+		assert.Equal(t, DocumentURI("file:///foo/bar/"), folder.uri)
+		assert.Equal(t, "bar", folder.name)
+		assert.Equal(t, "foo/bar", folder.dir)
+	})
+
+	t.Run("Root", func(t *testing.T) {
+		folder, err := newWorkspaceFolder("file:///", WorkspaceFolder{URI: "file:///"})
+		require.NoError(t, err)
+		assert.Equal(t, DocumentURI("file:///"), folder.uri)
+		assert.Empty(t, folder.dir)
+	})
+
+	t.Run("OutsideWorkspaceRoot", func(t *testing.T) {
+		_, err := newWorkspaceFolder("file:///", WorkspaceFolder{URI: "https://example.com/foo"})
+		require.EqualError(t, err, `workspace folder URI "https://example.com/foo" does not have workspace root URI "file:///" as prefix`)
+	})
+}
+
+func TestServerWorkspaceFolderFor(t *testing.T) {
+	s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+	require.NoError(t, s.setWorkspaceFolders([]WorkspaceFolder{
+		{URI: "file:///foo"},
+		{URI: "file:///foo/bar"},
+	}))
+
+	t.Run("Normal", func(t *testing.T) {
+		folder, relPath, err := s.workspaceFolderFor("file:///foo/main.spx")
+		require.NoError(t, err)
+		assert.Equal(t, DocumentURI("file:///foo/"), folder.uri)
+		assert.Equal(t, "main.spx", relPath)
+	})
+
+	t.Run("Innermost", func(t *testing.T) {
+		folder, relPath, err := s.workspaceFolderFor("file:///foo/bar/main.spx")
+		require.NoError(t, err)
+		assert.Equal(t, DocumentURI("file:///foo/bar/"), folder.uri)
+		assert.Equal(t, "main.spx", relPath)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		_, _, err := s.workspaceFolderFor("file:///baz/main.spx")
+		require.EqualError(t, err, `document URI "file:///baz/main.spx" is not in any workspace folder`)
+	})
+
+	t.Run("DefaultWorkspaceFolder", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		folder, relPath, err := s.workspaceFolderFor("file:///main.spx")
+		require.NoError(t, err)
+		assert.Same(t, s.defaultWorkspaceFolder(), folder)
+		assert.Equal(t, "main.spx", relPath)
+	})
+}
+
+func TestServerMultiRootWorkspace(t *testing.T) {
+	t.Run("Diagnostics", func(t *testing.T) {
+		s := newMultiRootTestServer(t)
+
+		report, err := s.textDocumentDiagnostic(context.Background(), &DocumentDiagnosticParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///projB/main.spx"},
+		})
+		require.NoError(t, err)
+		fullReport, ok := report.Value.(RelatedFullDocumentDiagnosticReport)
+		require.True(t, ok)
+		require.Len(t, fullReport.Items, 1)
+		assert.Equal(t, DiagnosticCodeUnusedImport, fullReport.Items[0].Code)
+
+		workspaceReport, err := s.workspaceDiagnostic(context.Background(), &WorkspaceDiagnosticParams{})
+		require.NoError(t, err)
+		var uris []DocumentURI
+		for _, item := range workspaceReport.Items {
+			uris = append(uris, item.Value.(WorkspaceFullDocumentDiagnosticReport).URI)
+		}
+		assert.ElementsMatch(t, []DocumentURI{
+			"file:///projA/main.spx",
+			"file:///projA/MySprite.spx",
+			"file:///projB/main.spx",
+		}, uris)
+	})
+
+	t.Run("WorkspaceSymbol", func(t *testing.T) {
+		s := newMultiRootTestServer(t)
+
+		symbols, err := s.workspaceSymbol(context.Background(), &WorkspaceSymbolParams{Query: "greet"})
+		require.NoError(t, err)
+		var uris []DocumentURI
+		for _, symbol := range symbols {
+			uris = append(uris, symbol.Location.Value.(Location).URI)
+		}
+		assert.ElementsMatch(t, []DocumentURI{
+			"file:///projA/MySprite.spx",
+			"file:///projB/main.spx",
+		}, uris)
+	})
+
+	t.Run("Definition", func(t *testing.T) {
+		s := newMultiRootTestServer(t)
+
+		def, err := s.textDocumentDefinition(&DefinitionParams{
+			TextDocumentPositionParams: TextDocumentPositionParams{
+				TextDocument: TextDocumentIdentifier{URI: "file:///projA/main.spx"},
+				Position:     Position{Line: 8, Character: 1},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, Location{
+			URI: "file:///projA/main.spx",
+			Range: Range{
+				Start: Position{Line: 5, Character: 5},
+				End:   Position{Line: 5, Character: 10},
+			},
+		}, def)
+	})
+
+	t.Run("PerFolderCompileState", func(t *testing.T) {
+		s := newMultiRootTestServer(t)
+		folders := s.getWorkspaceFolders()
+		require.Len(t, folders, 2)
+
+		_, _, _, err := s.compileAndGetASTFileForDocumentURI("file:///projA/main.spx")
+		require.NoError(t, err)
+		assert.NotNil(t, folders[0].lastCompileCache)
+		assert.Nil(t, folders[1].lastCompileCache)
+	})
+
+	t.Run("Initialize", func(t *testing.T) {
+		s := New(newMapFSWithoutModTime(map[string][]byte{}), nil)
+		_, err := s.initialize(&InitializeParams{
+			WorkspaceFoldersInitializeParams: WorkspaceFoldersInitializeParams{
+				WorkspaceFolders: []WorkspaceFolder{{URI: "file:///projA", Name: "projA"}},
+			},
+		})
+		require.NoError(t, err)
+		folders := s.getWorkspaceFolders()
+		require.Len(t, folders, 1)
+		assert.Equal(t, DocumentURI("file:///projA/"), folders[0].uri)
+
+		_, err = s.initialize(&InitializeParams{
+			WorkspaceFoldersInitializeParams: WorkspaceFoldersInitializeParams{
+				WorkspaceFolders: []WorkspaceFolder{{URI: "untitled:projA"}},
+			},
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestServerWorkspaceDidChangeWorkspaceFolders(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		s := newMultiRootTestServer(t)
+		_, err := s.compileWorkspaceFolders(context.Background())
+		require.NoError(t, err)
+		folders := s.getWorkspaceFolders()
+		require.Len(t, folders, 2)
+		projA, projB := folders[0], folders[1]
+
+		err = s.workspaceDidChangeWorkspaceFolders(&DidChangeWorkspaceFoldersParams{
+			Event: WorkspaceFoldersChangeEvent{
+				Added:   []WorkspaceFolder{{URI: "file:///projC", Name: "projC"}},
+				Removed: []WorkspaceFolder{{URI: "file:///projB", Name: "projB"}},
+			},
+		})
+		require.NoError(t, err)
+		folders = s.getWorkspaceFolders()
+		require.Len(t, folders, 2)
+		assert.Same(t, projA, folders[0])
+		assert.NotNil(t, projA.lastCompileCache)
+		assert.Equal(t, DocumentURI("file:///projC/"), folders[1].uri)
+
+		assert.ErrorIs(t, projB.ctx.Err(), context.Canceled)
+		assert.Nil(t, projB.lastCompileCache)
+		_, err = s.compileWorkspaceFolder(context.Background(), projB)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		_, _, err = s.workspaceFolderFor("file:///projB/main.spx")
+		assert.Error(t, err)
+	})
+
+	t.Run("RemoveAll", func(t *testing.T) {
+		s := newMultiRootTestServer(t)
+		err := s.workspaceDidChangeWorkspaceFolders(&DidChangeWorkspaceFoldersParams{
+			Event: WorkspaceFoldersChangeEvent{
+				Removed: []WorkspaceFolder{{URI: "file:///projA"}, {URI: "file:///projB"}},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []*workspaceFolder{s.workspaceRootFolder}, s.getWorkspaceFolders())
+	})
+
+	t.Run("AddExisting", func(t *testing.T) {
+		s := newMultiRootTestServer(t)
+		projA := s.getWorkspaceFolders()[0]
+		err := s.workspaceDidChangeWorkspaceFolders(&DidChangeWorkspaceFoldersParams{
+			Event: WorkspaceFoldersChangeEvent{
+				Added: []WorkspaceFolder{{URI: "file:///projA/"}},
+			},
+		})
+		require.NoError(t, err)
+		folders := s.getWorkspaceFolders()
+		require.Len(t, folders, 2)
+		assert.Same(t, projA, folders[0])
+		assert.NoError(t, projA.ctx.Err())
+	})
+
+	t.Run("InvalidFolder", func(t *testing.T) {
+		s := newMultiRootTestServer(t)
+		err := s.workspaceDidChangeWorkspaceFolders(&DidChangeWorkspaceFoldersParams{
+			Event: WorkspaceFoldersChangeEvent{
+				Added: []WorkspaceFolder{{URI: "untitled:projC"}},
+			},
+		})
+		require.Error(t, err)
+		assert.Len(t, s.getWorkspaceFolders(), 2)
+	})
+}
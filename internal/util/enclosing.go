@@ -471,6 +471,12 @@ func childrenOf(n ast.Node) []ast.Node {
 
 	children = slices.DeleteFunc(children, isNilNode)
 
+	// Drop nodes without valid positions, such as bare tokens that are absent
+	// in command-style calls and declarations generated for classfiles.
+	children = slices.DeleteFunc(children, func(child ast.Node) bool {
+		return !child.Pos().IsValid()
+	})
+
 	// TODO(adonovan): opt: merge the logic of ast.Inspect() into
 	// the switch above so we can make interleaved callbacks for
 	// both Nodes and Tokens in the right order and avoid the need
@@ -0,0 +1,88 @@
+package vfs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// localMapFSListingTTL is how long the listing of files of [NewLocalMapFS] is
+// reused before walking the directory again.
+const localMapFSListingTTL = time.Second
+
+// NewLocalMapFS creates a new map file system backed by the given directory of
+// the local file system. Hidden files and directories are skipped.
+//
+// Files are listed by walking the directory at most once per
+// [localMapFSListingTTL], or again after [MapFS.Invalidate], so that the many
+// calls made while handling a request share a listing. Contents of files are
+// only read when the files are opened or hashed, so that assets like images
+// and sounds are usually never read, and may thus be newer than the listing,
+// even in snapshots. Files are reused across listings, along
+// with their loaded contents and hashes, as long as their modification times
+// and sizes stay the same.
+func NewLocalMapFS(dir string) *MapFS {
+	var (
+		fileMap  map[string]MapFile
+		listedAt time.Time
+		mu       sync.Mutex
+	)
+	mapFS := NewMapFS(func() map[string]MapFile {
+		mu.Lock()
+		defer mu.Unlock()
+		if fileMap == nil || time.Since(listedAt) >= localMapFSListingTTL {
+			fileMap = listLocalFiles(dir, fileMap)
+			listedAt = time.Now()
+		}
+		return fileMap
+	})
+	mapFS.invalidate = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		listedAt = time.Time{}
+	}
+	return mapFS
+}
+
+// listLocalFiles lists the files in the given directory of the local file
+// system for [NewLocalMapFS]. Files in lastFileMap are reused as long as
+// their modification times and sizes stay the same.
+func listLocalFiles(dir string, lastFileMap map[string]MapFile) map[string]MapFile {
+	fileMap := make(map[string]MapFile, len(lastFileMap))
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries.
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		relPath, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil
+		}
+		name := filepath.ToSlash(relPath)
+
+		if mf, ok := lastFileMap[name]; ok && mf.ModTime.Equal(info.ModTime()) && mf.size() == info.Size() {
+			fileMap[name] = mf
+			return nil
+		}
+		fileMap[name] = newLazyMapFile(info.Size(), info.ModTime(), func() ([]byte, error) {
+			return os.ReadFile(p)
+		})
+		return nil
+	})
+	return fileMap
+}
@@ -0,0 +1,116 @@
+package vfs
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewLocalMapFS(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeFile("main.spx", "run \"assets\", {}")
+	writeFile("assets/index.json", "{}")
+	writeFile(".git/config", "")
+	writeFile(".hidden.spx", "")
+
+	t.Run("Normal", func(t *testing.T) {
+		fsys := NewLocalMapFS(dir)
+
+		got, err := fs.ReadFile(fsys, "assets/index.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []byte("{}"); !bytes.Equal(got, want) {
+			t.Errorf("content mismatch: got %q, want %q", got, want)
+		}
+
+		for _, name := range []string{".git/config", ".hidden.spx"} {
+			if _, err := fs.ReadFile(fsys, name); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("expected fs.ErrNotExist for %q, got %v", name, err)
+			}
+		}
+	})
+
+	t.Run("Changes", func(t *testing.T) {
+		fsys := NewLocalMapFS(dir)
+		snapshot := fsys.Snapshot()
+
+		writeFile("MySprite.spx", "onStart => {}")
+		defer os.Remove(filepath.Join(dir, "MySprite.spx"))
+		modTime := time.Now().Add(time.Minute)
+		writeFile("main.spx", "run \"assets\", {Title: \"My Game\"}")
+		if err := os.Chtimes(filepath.Join(dir, "main.spx"), modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := fs.ReadFile(fsys, "MySprite.spx"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected the listing to be cached, got %v", err)
+		}
+		fsys.Invalidate()
+
+		if _, err := fs.ReadFile(snapshot, "MySprite.spx"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected fs.ErrNotExist, got %v", err)
+		}
+		got, err := fs.ReadFile(fsys, "MySprite.spx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []byte("onStart => {}"); !bytes.Equal(got, want) {
+			t.Errorf("content mismatch: got %q, want %q", got, want)
+		}
+		got, err = fs.ReadFile(fsys, "main.spx")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []byte("run \"assets\", {Title: \"My Game\"}"); !bytes.Equal(got, want) {
+			t.Errorf("content mismatch: got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("LazyContent", func(t *testing.T) {
+		writeFile("assets/sounds/Meow/meow.wav", "wav")
+		fsys := NewLocalMapFS(dir)
+		snapshot := fsys.Snapshot()
+
+		// Contents are not read when listing files.
+		if err := os.Remove(filepath.Join(dir, "assets/sounds/Meow/meow.wav")); err != nil {
+			t.Fatal(err)
+		}
+		info, err := fs.Stat(snapshot, "assets/sounds/Meow/meow.wav")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := info.Size(), int64(len("wav")); got != want {
+			t.Errorf("size mismatch: got %d, want %d", got, want)
+		}
+		if _, err := fs.ReadFile(snapshot, "assets/sounds/Meow/meow.wav"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected fs.ErrNotExist, got %v", err)
+		}
+	})
+
+	t.Run("ReusedFiles", func(t *testing.T) {
+		fsys := NewLocalMapFS(dir)
+		a := fsys.Snapshot().getFileMap()["main.spx"]
+		if _, err := a.content(); err != nil {
+			t.Fatal(err)
+		}
+		fsys.Invalidate()
+		b := fsys.Snapshot().getFileMap()["main.spx"]
+		if a.lazy != b.lazy || a.hash != b.hash {
+			t.Error("expected unchanged files to be reused across listings")
+		}
+	})
+}
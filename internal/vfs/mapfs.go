@@ -1,19 +1,124 @@
 package vfs
 
 import (
+	"crypto/sha256"
 	"io"
 	"io/fs"
 	"maps"
 	"path"
 	"slices"
 	"strings"
+	"sync"
 	"time"
 )
 
 // MapFile represents a file's content and metadata in the map file system.
+// Its content must not be modified once the file is in a map file system, as
+// the content hash is cached.
 type MapFile struct {
+	// Content is the content of the file. It is nil for files whose content
+	// is loaded lazily, e.g., by [NewLocalMapFS], which is only loaded when
+	// the file is opened or hashed.
 	Content []byte
+
 	ModTime time.Time
+
+	// hash is the lazily computed content hash shared by copies of the file.
+	// It is set for files created by [NewMapFile] and files of snapshots.
+	hash *mapFileHash
+
+	// lazy loads the content of the file if it is loaded lazily.
+	lazy *lazyMapFileContent
+}
+
+// NewMapFile creates a new [MapFile] with the given content and modification
+// time. Unlike a composite literal, copies of the returned file share its
+// lazily computed content hash, so a file reused across calls of a
+// [GetFileMapFunc] is hashed at most once.
+func NewMapFile(content []byte, modTime time.Time) MapFile {
+	return MapFile{
+		Content: content,
+		ModTime: modTime,
+		hash:    new(mapFileHash),
+	}
+}
+
+// newLazyMapFile creates a new [MapFile] of the given size and modification
+// time whose content is loaded by load on first use. Copies of the returned
+// file share the loaded content and its hash.
+func newLazyMapFile(size int64, modTime time.Time, load func() ([]byte, error)) MapFile {
+	return MapFile{
+		ModTime: modTime,
+		hash:    new(mapFileHash),
+		lazy:    &lazyMapFileContent{size: size, load: load},
+	}
+}
+
+// lazyMapFileContent is the lazily loaded content of a [MapFile].
+type lazyMapFileContent struct {
+	size    int64
+	load    func() ([]byte, error)
+	once    sync.Once
+	content []byte
+	err     error
+}
+
+// content returns the content of the file, loading it if necessary.
+func (mf MapFile) content() ([]byte, error) {
+	if mf.lazy == nil {
+		return mf.Content, nil
+	}
+	mf.lazy.once.Do(func() {
+		mf.lazy.content, mf.lazy.err = mf.lazy.load()
+		mf.lazy.load = nil
+	})
+	return mf.lazy.content, mf.lazy.err
+}
+
+// size returns the size of the file content without loading it.
+func (mf MapFile) size() int64 {
+	if mf.lazy == nil {
+		return int64(len(mf.Content))
+	}
+	return mf.lazy.size
+}
+
+// mapFileHash is the lazily computed content hash of a [MapFile].
+type mapFileHash struct {
+	once sync.Once
+	sum  [sha256.Size]byte
+}
+
+// Hash returns the SHA-256 hash of the file content. For files created by
+// [NewMapFile] and files of snapshots, it is computed once and then shared by
+// all copies of the file. It returns the zero hash if a lazily loaded content
+// cannot be read.
+func (mf MapFile) Hash() [sha256.Size]byte {
+	if mf.hash == nil {
+		return mf.computeHash()
+	}
+	mf.hash.once.Do(func() {
+		mf.hash.sum = mf.computeHash()
+	})
+	return mf.hash.sum
+}
+
+// computeHash computes the hash returned by [MapFile.Hash].
+func (mf MapFile) computeHash() [sha256.Size]byte {
+	content, err := mf.content()
+	if err != nil {
+		return [sha256.Size]byte{}
+	}
+	return sha256.Sum256(content)
+}
+
+// sameContent reports whether the file has the same content as the other one.
+// Copies of the same file are never read or hashed to tell so.
+func (mf MapFile) sameContent(other MapFile) bool {
+	if mf.hash != nil && mf.hash == other.hash {
+		return true
+	}
+	return mf.size() == other.size() && mf.Hash() == other.Hash()
 }
 
 // GetFileMapFunc is the type for function that returns a map of files.
@@ -25,6 +130,9 @@ type MapFS struct {
 	fileMode      fs.FileMode
 	dirMode       fs.FileMode
 	snapshottedAt time.Time
+
+	// invalidate discards the files cached by getFileMap, if any.
+	invalidate func()
 }
 
 // NewMapFS creates a new map file system.
@@ -42,7 +150,19 @@ func (mfs *MapFS) Snapshot() *MapFS {
 	if !mfs.snapshottedAt.IsZero() {
 		return mfs
 	}
-	fileMap := mfs.getFileMap()
+	fileMap := maps.Clone(mfs.getFileMap())
+	var hashes []mapFileHash
+	for name, mf := range fileMap {
+		if mf.hash != nil {
+			continue
+		}
+		if hashes == nil {
+			hashes = make([]mapFileHash, len(fileMap))
+		}
+		mf.hash = &hashes[0]
+		hashes = hashes[1:]
+		fileMap[name] = mf
+	}
 	mapFS := NewMapFS(func() map[string]MapFile {
 		return fileMap
 	})
@@ -56,16 +176,119 @@ func (mfs *MapFS) SnapshottedAt() time.Time {
 	return mfs.snapshottedAt
 }
 
+// MapFSChanges describes the changes of a [MapFS] since a snapshot of it.
+type MapFSChanges struct {
+	// Added are the sorted paths of files added since the snapshot.
+	Added []string
+
+	// Removed are the sorted paths of files removed since the snapshot.
+	Removed []string
+
+	// Modified are the sorted paths of files whose content changed since the
+	// snapshot. Files whose modification time changed but content did not are
+	// not included.
+	Modified []string
+}
+
+// IsEmpty reports whether there are no changes.
+func (c MapFSChanges) IsEmpty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Modified) == 0
+}
+
+// ChangedSince returns the changes of the map file system since the given
+// snapshot, so that only what depends on changed files needs to be
+// invalidated. Contents are compared by their hashes, which are computed at
+// most once per file of each snapshot. See [MapFile.Hash].
+func (mfs *MapFS) ChangedSince(snapshot *MapFS) MapFSChanges {
+	oldFileMap := snapshot.getFileMap()
+	newFileMap := mfs.getFileMap()
+
+	var changes MapFSChanges
+	for name, mf := range newFileMap {
+		oldMF, ok := oldFileMap[name]
+		switch {
+		case !ok:
+			changes.Added = append(changes.Added, name)
+		case !mf.sameContent(oldMF):
+			changes.Modified = append(changes.Modified, name)
+		}
+	}
+	for name := range oldFileMap {
+		if _, ok := newFileMap[name]; !ok {
+			changes.Removed = append(changes.Removed, name)
+		}
+	}
+	slices.Sort(changes.Added)
+	slices.Sort(changes.Removed)
+	slices.Sort(changes.Modified)
+	return changes
+}
+
+// FileHash returns the content hash of the file with the given name, or false
+// if there is no such file. See [MapFile.Hash].
+func (mfs *MapFS) FileHash(name string) ([sha256.Size]byte, bool) {
+	mf, ok := mfs.getFileMap()[cleanPath(name)]
+	if !ok {
+		return [sha256.Size]byte{}, false
+	}
+	return mf.Hash(), true
+}
+
+// Invalidate discards the files cached by the map file system, if any, so that
+// the next call sees the current files, e.g., after being notified of changes
+// made outside the editor. It has no effect on snapshots.
+func (mfs *MapFS) Invalidate() {
+	if mfs.invalidate != nil {
+		mfs.invalidate()
+	}
+}
+
 // WithOverlay returns a new [MapFS] that overlays the given files on top of the
 // existing files. Files in the overlay take precedence over existing files with
 // the same name.
 func (mfs *MapFS) WithOverlay(overlay map[string]MapFile) *MapFS {
 	getFileMap := mfs.getFileMap
-	return NewMapFS(func() map[string]MapFile {
+	mapFS := NewMapFS(func() map[string]MapFile {
 		fileMap := maps.Clone(getFileMap())
 		maps.Copy(fileMap, overlay)
 		return fileMap
 	})
+	mapFS.invalidate = mfs.invalidate
+	return mapFS
+}
+
+// Sub returns a new [MapFS] corresponding to the subtree rooted at dir. It
+// returns the same instance if dir is the root. The returned instance is a
+// snapshot if the existing one is.
+func (mfs *MapFS) Sub(dir string) *MapFS {
+	dir = cleanPath(dir)
+	if dir == "" {
+		return mfs
+	}
+	prefix := dir + "/"
+	subFileMapOf := func(fileMap map[string]MapFile) map[string]MapFile {
+		subFileMap := make(map[string]MapFile)
+		for name, mf := range fileMap {
+			if subName, ok := strings.CutPrefix(name, prefix); ok {
+				subFileMap[subName] = mf
+			}
+		}
+		return subFileMap
+	}
+	if !mfs.snapshottedAt.IsZero() {
+		fileMap := subFileMapOf(mfs.getFileMap())
+		mapFS := NewMapFS(func() map[string]MapFile {
+			return fileMap
+		})
+		mapFS.snapshottedAt = mfs.snapshottedAt
+		return mapFS
+	}
+	getFileMap := mfs.getFileMap
+	mapFS := NewMapFS(func() map[string]MapFile {
+		return subFileMapOf(getFileMap())
+	})
+	mapFS.invalidate = mfs.invalidate
+	return mapFS
 }
 
 // Open implements [fs.ReadDirFS].
@@ -81,9 +304,13 @@ func (mfs *MapFS) Open(name string) (fs.File, error) {
 	if !ok {
 		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
 	}
+	content, err := mf.content()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
 	return &file{
 		name:    name,
-		content: mf.Content,
+		content: content,
 		mode:    mfs.fileMode,
 		modTime: mf.ModTime,
 	}, nil
@@ -153,7 +380,7 @@ func (mfs *MapFS) ReadDir(name string) ([]fs.DirEntry, error) {
 		mf := fileMap[prefix+f]
 		entries = append(entries, &dirEntry{
 			name:    f,
-			size:    int64(len(mf.Content)),
+			size:    mf.size(),
 			mode:    mfs.fileMode,
 			modTime: mf.ModTime,
 			isDir:   false,
@@ -191,7 +418,7 @@ func (mfs *MapFS) Stat(name string) (fs.FileInfo, error) {
 	if ok {
 		return &fileInfo{
 			name:    path.Base(name),
-			size:    int64(len(mf.Content)),
+			size:    mf.size(),
 			mode:    mfs.fileMode,
 			modTime: mf.ModTime,
 			isDir:   false,
@@ -2,10 +2,15 @@ package vfs
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"errors"
 	"io"
 	"io/fs"
+	"maps"
 	"path"
+	"slices"
 	"testing"
+	"time"
 )
 
 func newTestMapFS() (fs.FS, map[string]MapFile) {
@@ -97,6 +102,173 @@ func TestMapFSWithOverlay(t *testing.T) {
 	})
 }
 
+func TestMapFSSub(t *testing.T) {
+	fsys, files := newTestMapFS()
+
+	t.Run("Normal", func(t *testing.T) {
+		subFS := fsys.(*MapFS).Sub("dir")
+
+		got, err := fs.ReadFile(subFS, "bar.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []byte("bar"); !bytes.Equal(got, want) {
+			t.Errorf("content mismatch: got %q, want %q", got, want)
+		}
+
+		got, err = fs.ReadFile(subFS, "subdir/another.txt")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []byte("another"); !bytes.Equal(got, want) {
+			t.Errorf("content mismatch: got %q, want %q", got, want)
+		}
+
+		if _, err := fs.ReadFile(subFS, "foo.txt"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected fs.ErrNotExist, got %v", err)
+		}
+	})
+
+	t.Run("Root", func(t *testing.T) {
+		mapFS := fsys.(*MapFS)
+		if subFS := mapFS.Sub("."); subFS != mapFS {
+			t.Error("expected the same instance for the root")
+		}
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		snapshot := fsys.(*MapFS).Snapshot()
+		subFS := snapshot.Sub("other")
+		if !subFS.SnapshottedAt().Equal(snapshot.SnapshottedAt()) {
+			t.Errorf("snapshot time mismatch: got %v, want %v", subFS.SnapshottedAt(), snapshot.SnapshottedAt())
+		}
+
+		files["other/new.txt"] = MapFile{Content: []byte("new")}
+		defer delete(files, "other/new.txt")
+		if _, err := fs.ReadFile(subFS, "new.txt"); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("expected fs.ErrNotExist, got %v", err)
+		}
+	})
+}
+
+func TestMapFileHash(t *testing.T) {
+	mf := MapFile{Content: []byte("foo")}
+	if got, want := mf.Hash(), sha256.Sum256([]byte("foo")); got != want {
+		t.Errorf("hash mismatch: got %x, want %x", got, want)
+	}
+
+	fsys, _ := newTestMapFS()
+	snapshot := fsys.(*MapFS).Snapshot()
+	a := snapshot.getFileMap()["foo.txt"]
+	b := snapshot.getFileMap()["foo.txt"]
+	if a.hash == nil || a.hash != b.hash {
+		t.Fatal("expected copies of a snapshot file to share their hash")
+	}
+	if got, want := a.Hash(), mf.Hash(); got != want {
+		t.Errorf("hash mismatch: got %x, want %x", got, want)
+	}
+	if got, want := snapshot.Sub("dir").getFileMap()["bar.txt"].hash, snapshot.getFileMap()["dir/bar.txt"].hash; got != want {
+		t.Error("expected sub file systems of a snapshot to share file hashes")
+	}
+
+	t.Run("NewMapFile", func(t *testing.T) {
+		files := map[string]MapFile{"foo.txt": NewMapFile([]byte("foo"), time.Time{})}
+		fsys := NewMapFS(func() map[string]MapFile { return files })
+		a := fsys.Snapshot().getFileMap()["foo.txt"]
+		b := fsys.Snapshot().getFileMap()["foo.txt"]
+		if a.hash != files["foo.txt"].hash || b.hash != a.hash {
+			t.Error("expected snapshots to share the hash of a file created by NewMapFile")
+		}
+	})
+
+	t.Run("FileHash", func(t *testing.T) {
+		got, ok := snapshot.FileHash("/dir/bar.txt")
+		if !ok {
+			t.Fatal("expected the hash of an existing file")
+		}
+		if want := sha256.Sum256([]byte("bar")); got != want {
+			t.Errorf("hash mismatch: got %x, want %x", got, want)
+		}
+		if _, ok := snapshot.FileHash("missing.txt"); ok {
+			t.Error("expected no hash for a missing file")
+		}
+	})
+}
+
+func TestMapFSChangedSince(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		fsys, files := newTestMapFS()
+		snapshot := fsys.(*MapFS).Snapshot()
+
+		newFiles := maps.Clone(files)
+		newFiles["new.txt"] = MapFile{Content: []byte("new")}
+		delete(newFiles, "other/file.txt")
+		newFiles["foo.txt"] = MapFile{Content: []byte("modified foo")}
+		newFiles["dir/bar.txt"] = MapFile{Content: []byte("bar"), ModTime: time.Now()}
+		newFS := NewMapFS(func() map[string]MapFile {
+			return newFiles
+		})
+
+		changes := newFS.ChangedSince(snapshot)
+		if want := []string{"new.txt"}; !slices.Equal(changes.Added, want) {
+			t.Errorf("added mismatch: got %v, want %v", changes.Added, want)
+		}
+		if want := []string{"other/file.txt"}; !slices.Equal(changes.Removed, want) {
+			t.Errorf("removed mismatch: got %v, want %v", changes.Removed, want)
+		}
+		if want := []string{"foo.txt"}; !slices.Equal(changes.Modified, want) {
+			t.Errorf("modified mismatch: got %v, want %v", changes.Modified, want)
+		}
+		if changes.IsEmpty() {
+			t.Error("expected changes")
+		}
+	})
+
+	t.Run("SameContent", func(t *testing.T) {
+		fsys, files := newTestMapFS()
+		snapshot := fsys.(*MapFS).Snapshot()
+
+		// Files replaced with the same content are not reported.
+		files["foo.txt"] = MapFile{Content: []byte("foo")}
+		if changes := fsys.(*MapFS).Snapshot().ChangedSince(snapshot); !changes.IsEmpty() {
+			t.Errorf("expected no changes, got %+v", changes)
+		}
+	})
+
+	t.Run("SharedFiles", func(t *testing.T) {
+		var loads int
+		files := map[string]MapFile{
+			"large.png": newLazyMapFile(3, time.Time{}, func() ([]byte, error) {
+				loads++
+				return []byte("png"), nil
+			}),
+		}
+		fsys := NewMapFS(func() map[string]MapFile {
+			return files
+		})
+		snapshot := fsys.Snapshot()
+
+		// Copies of unchanged files are neither read nor hashed.
+		if changes := fsys.Snapshot().ChangedSince(snapshot); !changes.IsEmpty() {
+			t.Errorf("expected no changes, got %+v", changes)
+		}
+		if loads != 0 {
+			t.Errorf("expected no loads, got %d", loads)
+		}
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		fsys, _ := newTestMapFS()
+		snapshot := fsys.(*MapFS).Snapshot()
+		if changes := snapshot.ChangedSince(snapshot); !changes.IsEmpty() {
+			t.Errorf("expected no changes, got %+v", changes)
+		}
+		if changes := fsys.(*MapFS).ChangedSince(snapshot); !changes.IsEmpty() {
+			t.Errorf("expected no changes, got %+v", changes)
+		}
+	})
+}
+
 func TestMapFSOpen(t *testing.T) {
 	fsys, files := newTestMapFS()
 
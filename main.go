@@ -6,6 +6,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
+	"sync"
 	"syscall/js"
 	"time"
 
@@ -23,23 +25,43 @@ type Spxls struct {
 
 // NewSpxls creates a new instance of [Spxls].
 func NewSpxls(this js.Value, args []js.Value) any {
-	if len(args) != 2 {
-		return errors.New("NewSpxls: expected 2 arguments")
+	if len(args) < 2 || len(args) > 4 {
+		return newCodedError(jsonrpc2.ErrInvalidParams, "NewSpxls: expected 2 to 4 arguments")
 	}
 	if args[0].Type() != js.TypeFunction {
-		return errors.New("NewSpxls: filesProvider argument must be a function")
+		return newCodedError(jsonrpc2.ErrInvalidParams, "NewSpxls: filesProvider argument must be a function")
 	}
 	if args[1].Type() != js.TypeFunction {
-		return errors.New("NewSpxls: messageReplier argument must be a function")
+		return newCodedError(jsonrpc2.ErrInvalidParams, "NewSpxls: messageReplier argument must be a function")
+	}
+	var opts []server.Option
+	if len(args) >= 3 && !args[2].IsUndefined() && !args[2].IsNull() {
+		if args[2].Type() != js.TypeFunction {
+			return newCodedError(jsonrpc2.ErrInvalidParams, "NewSpxls: packageLoader argument must be a function")
+		}
+		opts = append(opts, server.WithPackageLoader(jsPackageLoader(args[2]), 0))
+	}
+	if len(args) == 4 && !args[3].IsUndefined() && !args[3].IsNull() {
+		if args[3].Type() != js.TypeObject || args[3].Get("get").Type() != js.TypeFunction || args[3].Get("put").Type() != js.TypeFunction {
+			return newCodedError(jsonrpc2.ErrInvalidParams, "NewSpxls: cache argument must be an object with get and put methods")
+		}
+		opts = append(opts, server.WithCache(jsCache{obj: args[3]}))
 	}
 	filesProvider := args[0]
 	s := &Spxls{
 		messageReplier: args[1],
 	}
+	var (
+		lastFiles   map[string]vfs.MapFile
+		lastFilesMu sync.Mutex
+	)
 	s.server = server.New(vfs.NewMapFS(func() map[string]vfs.MapFile {
+		lastFilesMu.Lock()
+		defer lastFilesMu.Unlock()
 		files := filesProvider.Invoke()
-		return ConvertJSFilesToMap(files)
-	}), s)
+		lastFiles = ConvertJSFilesToMap(files, lastFiles)
+		return lastFiles
+	}), s, opts...)
 	return js.ValueOf(map[string]any{
 		"handleMessage": JSFuncOfWithError(s.HandleMessage),
 	})
@@ -48,15 +70,15 @@ func NewSpxls(this js.Value, args []js.Value) any {
 // HandleMessage handles incoming LSP messages from the client.
 func (s *Spxls) HandleMessage(this js.Value, args []js.Value) any {
 	if len(args) != 1 {
-		return errors.New("Spxls.HandleMessage: expected 1 argument")
+		return newCodedError(jsonrpc2.ErrInvalidParams, "Spxls.HandleMessage: expected 1 argument")
 	}
 	if args[0].Type() != js.TypeObject {
-		return errors.New("Spxls.HandleMessage: message argument must be an object")
+		return newCodedError(jsonrpc2.ErrInvalidParams, "Spxls.HandleMessage: message argument must be an object")
 	}
 	rawMessage := js.Global().Get("JSON").Call("stringify", args[0]).String()
 	message, err := jsonrpc2.DecodeMessage([]byte(rawMessage))
 	if err != nil {
-		return fmt.Errorf("Spxls.HandleMessage: %w", err)
+		return newCodedError(jsonrpc2.ErrParse, "Spxls.HandleMessage: %v", err)
 	}
 	if err := s.server.HandleMessage(message); err != nil {
 		return fmt.Errorf("Spxls.HandleMessage: %w", err)
@@ -87,27 +109,171 @@ func (s *Spxls) ReplyMessage(m jsonrpc2.Message) (err error) {
 	return nil
 }
 
+// jsPackageLoader returns a [server.PackageLoader] calling the given
+// JavaScript function with package paths. The function returns the export
+// data as a Uint8Array or an ArrayBuffer, or null if the package is not
+// available, either directly or via a Promise.
+func jsPackageLoader(fn js.Value) server.PackageLoader {
+	return server.PackageLoaderFunc(func(pkgPath string) (export []byte, err error) {
+		// Catch potential panics during JavaScript execution.
+		defer func() {
+			if r := recover(); r != nil {
+				if jsErr, ok := r.(js.Error); ok {
+					err = fmt.Errorf("client error: %w", jsErr)
+				} else {
+					err = fmt.Errorf("client panic: %v", r)
+				}
+			}
+		}()
+
+		value := fn.Invoke(pkgPath)
+		if value.InstanceOf(js.Global().Get("Promise")) {
+			if value, err = awaitJSPromise(value); err != nil {
+				return nil, err
+			}
+		}
+		if value.IsNull() || value.IsUndefined() {
+			return nil, fmt.Errorf("package %q is not provided: %w", pkgPath, fs.ErrNotExist)
+		}
+		return JSBytes(value), nil
+	})
+}
+
+// jsCache is a [server.Cache] calling the get and put methods of a JavaScript
+// object, e.g., backed by IndexedDB. The get method returns the data as a
+// Uint8Array or an ArrayBuffer, or null if there is none, and both methods may
+// return Promises.
+type jsCache struct {
+	obj js.Value
+}
+
+// Get implements [server.Cache].
+func (c jsCache) Get(key string) (data []byte, err error) {
+	// Catch potential panics during JavaScript execution.
+	defer func() {
+		if r := recover(); r != nil {
+			if jsErr, ok := r.(js.Error); ok {
+				err = fmt.Errorf("client error: %w", jsErr)
+			} else {
+				err = fmt.Errorf("client panic: %v", r)
+			}
+		}
+	}()
+
+	value := c.obj.Call("get", key)
+	if value.InstanceOf(js.Global().Get("Promise")) {
+		if value, err = awaitJSPromise(value); err != nil {
+			return nil, err
+		}
+	}
+	if value.IsNull() || value.IsUndefined() {
+		return nil, fmt.Errorf("cache entry %q is not found: %w", key, fs.ErrNotExist)
+	}
+	return JSBytes(value), nil
+}
+
+// Put implements [server.Cache].
+func (c jsCache) Put(key string, data []byte) (err error) {
+	// Catch potential panics during JavaScript execution.
+	defer func() {
+		if r := recover(); r != nil {
+			if jsErr, ok := r.(js.Error); ok {
+				err = fmt.Errorf("client error: %w", jsErr)
+			} else {
+				err = fmt.Errorf("client panic: %v", r)
+			}
+		}
+	}()
+
+	array := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(array, data)
+	value := c.obj.Call("put", key, array)
+	if value.InstanceOf(js.Global().Get("Promise")) {
+		_, err = awaitJSPromise(value)
+	}
+	return err
+}
+
+// awaitJSPromise blocks until the given JavaScript Promise settles, and
+// returns its value or its rejection reason as an error. It must not be called
+// from the goroutine running JavaScript callbacks, which would deadlock.
+func awaitJSPromise(promise js.Value) (js.Value, error) {
+	type settlement struct {
+		value js.Value
+		err   error
+	}
+	settled := make(chan settlement, 1)
+	onFulfilled := js.FuncOf(func(this js.Value, args []js.Value) any {
+		settled <- settlement{value: args[0]}
+		return nil
+	})
+	defer onFulfilled.Release()
+	onRejected := js.FuncOf(func(this js.Value, args []js.Value) any {
+		settled <- settlement{err: fmt.Errorf("client error: %v", js.Global().Get("String").Invoke(args[0]).String())}
+		return nil
+	})
+	defer onRejected.Release()
+	promise.Call("then", onFulfilled, onRejected)
+	s := <-settled
+	return s.value, s.err
+}
+
 // JSFuncOfWithError returns a function to be used by JavaScript that can return
-// an error.
+// an error. Returned errors are converted by [JSError].
 func JSFuncOfWithError(fn func(this js.Value, args []js.Value) any) js.Func {
 	return js.FuncOf(func(this js.Value, args []js.Value) any {
 		result := fn(this, args)
 		if err, ok := result.(error); ok {
-			return js.Global().Get("Error").New(err.Error())
+			return JSError(err)
 		}
 		return result
 	})
 }
 
-// JSUint8ArrayToBytes converts a JavaScript Uint8Array to a []byte.
-func JSUint8ArrayToBytes(uint8Array js.Value) []byte {
-	b := make([]byte, uint8Array.Length())
-	js.CopyBytesToGo(b, uint8Array)
+// JSError converts an error to a JavaScript Error. Its code property is set to
+// the code of the [jsonrpc2.WireError] in the error chain, or the code of
+// [jsonrpc2.ErrUnknown] if there is none. Its data property is set to the data
+// of the [jsonrpc2.WireError] if any.
+func JSError(err error) js.Value {
+	jsErr := js.Global().Get("Error").New(err.Error())
+	jsErr.Set("name", "SpxlsError")
+	var wireErr *jsonrpc2.WireError
+	if !errors.As(err, &wireErr) {
+		errors.As(jsonrpc2.ErrUnknown, &wireErr)
+	}
+	jsErr.Set("code", wireErr.Code)
+	if wireErr.Data != nil {
+		jsErr.Set("data", js.Global().Get("JSON").Call("parse", string(*wireErr.Data)))
+	}
+	return jsErr
+}
+
+// newCodedError returns a [jsonrpc2.WireError] with the code of the given
+// JSON-RPC error and a formatted message.
+func newCodedError(code error, format string, args ...any) error {
+	var wireErr *jsonrpc2.WireError
+	errors.As(code, &wireErr)
+	return &jsonrpc2.WireError{
+		Code:    wireErr.Code,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// JSBytes converts a JavaScript Uint8Array or ArrayBuffer to a []byte.
+func JSBytes(value js.Value) []byte {
+	if value.InstanceOf(js.Global().Get("ArrayBuffer")) {
+		value = js.Global().Get("Uint8Array").New(value)
+	}
+	b := make([]byte, value.Length())
+	js.CopyBytesToGo(b, value)
 	return b
 }
 
-// ConvertJSFilesToMap converts a JavaScript object of files to a map.
-func ConvertJSFilesToMap(files js.Value) map[string]vfs.MapFile {
+// ConvertJSFilesToMap converts a JavaScript object of files to a map. Contents
+// of files in lastFiles are reused as long as their modification times and
+// sizes stay the same, which avoids copying and hashing large files on every
+// call.
+func ConvertJSFilesToMap(files js.Value, lastFiles map[string]vfs.MapFile) map[string]vfs.MapFile {
 	if files.Type() != js.TypeObject {
 		return nil
 	}
@@ -116,16 +282,26 @@ func ConvertJSFilesToMap(files js.Value) map[string]vfs.MapFile {
 	for i := range keys.Length() {
 		key := keys.Index(i).String()
 		value := files.Get(key)
-		if value.InstanceOf(js.Global().Get("Object")) {
-			result[key] = vfs.MapFile{
-				Content: JSUint8ArrayToBytes(value.Get("content")),
-				ModTime: time.UnixMilli(int64(value.Get("modTime").Int())),
-			}
+		if !value.InstanceOf(js.Global().Get("Object")) {
+			continue
+		}
+		content := value.Get("content")
+		modTime := time.UnixMilli(int64(value.Get("modTime").Int()))
+		if mf, ok := lastFiles[key]; ok && mf.ModTime.Equal(modTime) && len(mf.Content) == jsByteLength(content) {
+			result[key] = mf
+			continue
 		}
+		result[key] = vfs.NewMapFile(JSBytes(content), modTime)
 	}
 	return result
 }
 
+// jsByteLength returns the byte length of a JavaScript Uint8Array or
+// ArrayBuffer.
+func jsByteLength(value js.Value) int {
+	return value.Get("byteLength").Int()
+}
+
 func main() {
 	js.Global().Set("NewSpxls", JSFuncOfWithError(NewSpxls))
 	select {}
@@ -0,0 +1,325 @@
+// Package xgoanalysis analyzes Go+ classfile projects, e.g., spx games, with
+// the same pipeline as the language server but without speaking LSP, so that
+// batch tools like graders, CI checks and asset auditors can reuse it.
+package xgoanalysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/types"
+	"io"
+	"io/fs"
+	"strings"
+
+	gopast "github.com/goplus/gop/ast"
+	goptoken "github.com/goplus/gop/token"
+	goptypesutil "github.com/goplus/gop/x/typesutil"
+	"github.com/goplus/goxlsw/internal/server"
+	"github.com/goplus/goxlsw/internal/vfs"
+)
+
+// ErrNoProject is the error returned by [LoadProject] if there is no project
+// classfile, e.g., main.spx.
+var ErrNoProject = server.ErrNoProject
+
+// Project is an analyzed project.
+type Project struct {
+	// Kind is the name of the classfile kind of the project, e.g., "spx".
+	Kind string
+
+	// MainFile is the project classfile, e.g., "main.spx".
+	MainFile string
+
+	// Fset is the file set of the classfiles.
+	Fset *goptoken.FileSet
+
+	// Files maps the classfiles to their ASTs.
+	Files map[string]*gopast.File
+
+	// Pkg is the main package of the project.
+	Pkg *types.Package
+
+	// TypeInfo is the type information of the main package.
+	TypeInfo *goptypesutil.Info
+
+	// Resources are the URIs of the backdrops, sounds, sprites and widgets of
+	// an spx project, e.g., "spx://resources/sounds/Meow", sorted.
+	Resources []string
+
+	// ResourceRefs are the references to spx resources in the classfiles.
+	ResourceRefs []ResourceRef
+
+	// UnusedResources are the URIs of the backdrops, sounds and sprite
+	// costumes of an spx project that are never referenced from the
+	// classfiles or other resource metadata, sorted. Resources selected by
+	// non-constant names cannot be detected, so they are only candidates for
+	// removal.
+	UnusedResources []string
+
+	// Diagnostics are the diagnostics of the files of the project, sorted by
+	// their files and positions.
+	Diagnostics []Diagnostic
+
+	analysis *server.Analysis
+}
+
+// HasErrors reports whether any diagnostic of the project is an error.
+func (p *Project) HasErrors() bool {
+	for _, diag := range p.Diagnostics {
+		if diag.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteLSIF writes an LSIF dump of the definitions, references and hovers of
+// all identifiers in the classfiles to w, e.g., for offline code navigation in
+// web viewers. The documents of the dump are the classfiles under the given
+// project root URI, e.g., "file:///home/alice/game/".
+func (p *Project) WriteLSIF(w io.Writer, projectRootURI string) error {
+	if !strings.HasSuffix(projectRootURI, "/") {
+		projectRootURI += "/"
+	}
+	return p.analysis.WriteLSIF(w, server.DocumentURI(projectRootURI))
+}
+
+// ResourceRef is a reference to an spx resource.
+type ResourceRef struct {
+	// Resource is the URI of the referenced resource.
+	Resource string `json:"resource"`
+
+	// Kind is the kind of the reference, e.g., "stringLiteral".
+	Kind string `json:"kind"`
+
+	// File is the classfile of the reference.
+	File string `json:"file"`
+
+	// Range is the range of the reference in the file.
+	Range Range `json:"range"`
+}
+
+// Diagnostic is a diagnostic of a project.
+type Diagnostic struct {
+	// File is the file of the diagnostic relative to the project root.
+	File string `json:"file"`
+
+	// Range is the range of the diagnostic in the file.
+	Range Range `json:"range"`
+
+	// Severity is the severity of the diagnostic.
+	Severity Severity `json:"severity"`
+
+	// Code is the code of the diagnostic, e.g., "spx0001", if any.
+	Code string `json:"code,omitempty"`
+
+	// Message is the message of the diagnostic.
+	Message string `json:"message"`
+}
+
+// String returns the diagnostic in the form of "file:line:col: severity:
+// message", with 1-based lines and columns.
+func (d Diagnostic) String() string {
+	s := fmt.Sprintf("%s:%d:%d: %s: %s", d.File, d.Range.Start.Line+1, d.Range.Start.Character+1, d.Severity, d.Message)
+	if d.Code != "" {
+		s += " (" + d.Code + ")"
+	}
+	return s
+}
+
+// WriteSARIF writes the given diagnostics to w as a SARIF 2.1.0 log, which can
+// be ingested by GitHub code scanning and other dashboards. The files of the
+// diagnostics are used as URIs relative to the source root.
+func WriteSARIF(w io.Writer, diagnostics []Diagnostic) error {
+	analysisDiags := make([]server.AnalysisDiagnostic, 0, len(diagnostics))
+	for _, diag := range diagnostics {
+		analysisDiag := server.AnalysisDiagnostic{
+			File: diag.File,
+			Diagnostic: server.Diagnostic{
+				Range:    diag.Range,
+				Severity: server.DiagnosticSeverity(diag.Severity),
+				Message:  diag.Message,
+			},
+		}
+		if diag.Code != "" {
+			analysisDiag.Code = diag.Code
+		}
+		analysisDiags = append(analysisDiags, analysisDiag)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(server.NewSarifLog(analysisDiags, ""))
+}
+
+// Range is a range in a file, with 0-based lines and UTF-16 characters as in
+// LSP.
+type Range = server.Range
+
+// Position is a position in a file, with a 0-based line and UTF-16 character
+// as in LSP.
+type Position = server.Position
+
+// Severity is the severity of a [Diagnostic].
+type Severity int
+
+// Severities of diagnostics, the same as in LSP.
+const (
+	SeverityError Severity = iota + 1
+	SeverityWarning
+	SeverityInformation
+	SeverityHint
+)
+
+// String returns the name of the severity, e.g., "error".
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInformation:
+		return "information"
+	case SeverityHint:
+		return "hint"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// MarshalText implements [encoding.TextMarshaler].
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements [encoding.TextUnmarshaler].
+func (s *Severity) UnmarshalText(text []byte) error {
+	for severity := SeverityError; severity <= SeverityHint; severity++ {
+		if string(text) == severity.String() {
+			*s = severity
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown severity %q", text)
+}
+
+// Option configures [LoadProject].
+type Option func(opts *[]server.Option)
+
+// PackageLoader supplies the export data of packages not bundled with the
+// analysis, see [WithPackageLoader].
+type PackageLoader = server.PackageLoader
+
+// WithPackageLoader sets the loader supplying the export data of packages not
+// bundled with the analysis, e.g., those of required modules.
+func WithPackageLoader(loader PackageLoader) Option {
+	return func(opts *[]server.Option) {
+		*opts = append(*opts, server.WithPackageLoader(loader, 0))
+	}
+}
+
+// ClassfileKind describes a Go+ classfile framework other than spx, see
+// [WithClassfileKind].
+type ClassfileKind = server.ClassfileKind
+
+// WithClassfileKind registers the given classfile kind, so that projects of
+// it can be analyzed besides spx ones.
+func WithClassfileKind(kind ClassfileKind) Option {
+	return func(opts *[]server.Option) {
+		*opts = append(*opts, server.WithClassfileKind(kind))
+	}
+}
+
+// LoadProject analyzes the project at the root of the given file system.
+// Hidden files and directories are skipped. It returns an error wrapping
+// [ErrNoProject] if there is no project classfile.
+func LoadProject(fsys fs.FS, opts ...Option) (*Project, error) {
+	return LoadProjectContext(context.Background(), fsys, opts...)
+}
+
+// LoadProjectContext is like [LoadProject] but abandons the analysis once ctx
+// is done.
+func LoadProjectContext(ctx context.Context, fsys fs.FS, opts ...Option) (*Project, error) {
+	fileMap, err := readFileMap(fsys)
+	if err != nil {
+		return nil, err
+	}
+	var serverOpts []server.Option
+	for _, opt := range opts {
+		opt(&serverOpts)
+	}
+	analysis, err := server.Analyze(ctx, vfs.NewMapFS(func() map[string]vfs.MapFile {
+		return fileMap
+	}), serverOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	project := &Project{
+		Kind:     analysis.ClassfileKind,
+		MainFile: analysis.MainFile,
+		Fset:     analysis.Fset,
+		Files:    analysis.Files,
+		Pkg:      analysis.Pkg,
+		TypeInfo: analysis.TypeInfo,
+		analysis: analysis,
+	}
+	for _, id := range analysis.Resources {
+		project.Resources = append(project.Resources, string(id.URI()))
+	}
+	for _, ref := range analysis.ResourceRefs {
+		project.ResourceRefs = append(project.ResourceRefs, ResourceRef{
+			Resource: string(ref.ID.URI()),
+			Kind:     string(ref.Kind),
+			File:     ref.File,
+			Range:    ref.Range,
+		})
+	}
+	for _, id := range analysis.UnusedResources {
+		project.UnusedResources = append(project.UnusedResources, string(id.URI()))
+	}
+	for _, diag := range analysis.Diagnostics {
+		code, _ := diag.Code.(string)
+		project.Diagnostics = append(project.Diagnostics, Diagnostic{
+			File:     diag.File,
+			Range:    diag.Range,
+			Severity: Severity(diag.Severity),
+			Code:     code,
+			Message:  diag.Message,
+		})
+	}
+	return project, nil
+}
+
+// readFileMap reads the regular files in the given file system, skipping
+// hidden files and directories.
+func readFileMap(fsys fs.FS) (map[string]vfs.MapFile, error) {
+	fileMap := make(map[string]vfs.MapFile)
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+		fileMap[name] = vfs.MapFile{Content: content, ModTime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project files: %w", err)
+	}
+	return fileMap, nil
+}
@@ -0,0 +1,162 @@
+package xgoanalysis
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadProject(t *testing.T) {
+	t.Run("Normal", func(t *testing.T) {
+		project, err := LoadProject(fstest.MapFS{
+			"main.spx": {Data: []byte(`play "Meow"
+play "Missing"
+undefinedVar
+run "assets", {}
+`)},
+			"Cat.spx":                       {Data: []byte(`onStart => {}`)},
+			"assets/index.json":             {Data: []byte(`{}`)},
+			"assets/sounds/Meow/index.json": {Data: []byte(`{}`)},
+			"assets/sounds/Roar/index.json": {Data: []byte(`{}`)},
+			"assets/sprites/Cat/index.json": {Data: []byte(`{}`)},
+			".git/config":                   {Data: []byte(`[core]`)},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "spx", project.Kind)
+		assert.Equal(t, "main.spx", project.MainFile)
+		assert.Len(t, project.Files, 2)
+		assert.NotNil(t, project.Pkg.Scope().Lookup("Cat"))
+		assert.Equal(t, []string{"spx://resources/sounds/Meow", "spx://resources/sounds/Roar", "spx://resources/sprites/Cat"}, project.Resources)
+		assert.Equal(t, []string{"spx://resources/sounds/Roar"}, project.UnusedResources)
+		assert.Contains(t, project.ResourceRefs, ResourceRef{
+			Resource: "spx://resources/sounds/Meow",
+			Kind:     "stringLiteral",
+			File:     "main.spx",
+			Range: Range{
+				Start: Position{Line: 0, Character: 5},
+				End:   Position{Line: 0, Character: 11},
+			},
+		})
+
+		assert.True(t, project.HasErrors())
+		var messages []string
+		for _, diag := range project.Diagnostics {
+			assert.Equal(t, "main.spx", diag.File)
+			messages = append(messages, diag.String())
+		}
+		assert.Equal(t, []string{
+			`main.spx:1:1: information: sprite "Cat" has no auto-binding var in main.spx (spx0024)`,
+			`main.spx:2:6: error: sound resource "Missing" not found (spx0001)`,
+			`main.spx:3:1: error: undefinedVar is not defined (spx0017)`,
+		}, messages)
+	})
+
+	t.Run("LSIF", func(t *testing.T) {
+		project, err := LoadProject(fstest.MapFS{
+			"main.spx":          {Data: []byte(`run "assets", {}`)},
+			"assets/index.json": {Data: []byte(`{}`)},
+		})
+		require.NoError(t, err)
+		var buf bytes.Buffer
+		require.NoError(t, project.WriteLSIF(&buf, "file:///game"))
+		assert.Contains(t, buf.String(), `"projectRoot":"file:///game/"`)
+		assert.Contains(t, buf.String(), `"uri":"file:///game/main.spx"`)
+	})
+
+	t.Run("NoErrors", func(t *testing.T) {
+		project, err := LoadProject(fstest.MapFS{
+			"main.spx":          {Data: []byte(`run "assets", {}`)},
+			"assets/index.json": {Data: []byte(`{}`)},
+		})
+		require.NoError(t, err)
+		assert.False(t, project.HasErrors())
+		assert.Empty(t, project.Diagnostics)
+	})
+
+	t.Run("NoProject", func(t *testing.T) {
+		_, err := LoadProject(fstest.MapFS{
+			"README.md": {Data: []byte(`# Game`)},
+		})
+		assert.ErrorIs(t, err, ErrNoProject)
+	})
+}
+
+func TestDiagnosticJSON(t *testing.T) {
+	data, err := json.Marshal(Diagnostic{
+		File:     "main.spx",
+		Severity: SeverityWarning,
+		Message:  "unused variable",
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"file": "main.spx",
+		"range": {"start": {"line": 0, "character": 0}, "end": {"line": 0, "character": 0}},
+		"severity": "warning",
+		"message": "unused variable"
+	}`, string(data))
+
+	var diag Diagnostic
+	require.NoError(t, json.Unmarshal(data, &diag))
+	assert.Equal(t, SeverityWarning, diag.Severity)
+	assert.Error(t, json.Unmarshal([]byte(`{"severity": "fatal"}`), &diag))
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, WriteSARIF(&buf, []Diagnostic{
+		{
+			File:     "game/main.spx",
+			Range:    Range{Start: Position{Line: 2, Character: 0}, End: Position{Line: 2, Character: 12}},
+			Severity: SeverityError,
+			Code:     "spx0017",
+			Message:  "undefinedVar is not defined",
+		},
+		{
+			File:     "game/main.spx",
+			Severity: SeverityWarning,
+			Message:  "custom",
+		},
+	}))
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	assert.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	require.Len(t, log.Runs[0].Tool.Driver.Rules, 1)
+	assert.Equal(t, "spx0017", log.Runs[0].Tool.Driver.Rules[0].ID)
+	require.Len(t, log.Runs[0].Results, 2)
+	assert.Equal(t, "spx0017", log.Runs[0].Results[0].RuleID)
+	assert.Equal(t, "game/main.spx", log.Runs[0].Results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	assert.Equal(t, 3, log.Runs[0].Results[0].Locations[0].PhysicalLocation.Region.StartLine)
+	assert.Empty(t, log.Runs[0].Results[1].RuleID)
+	assert.Equal(t, "warning", log.Runs[0].Results[1].Level)
+}